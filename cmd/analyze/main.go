@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/artifacts"
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+	"github.com/scttfrdmn/geoschem-aws/internal/shutdown"
+	"github.com/scttfrdmn/geoschem-aws/internal/ssh"
+	"github.com/scttfrdmn/geoschem-aws/internal/storage"
+)
+
+// jupyterPort is where Jupyter listens on the instance, bound to loopback so it's only
+// reachable through the tunnel
+const jupyterPort = 8888
+
+// Where the run output is mounted and notebooks are kept on the instance
+const (
+	outputMount  = "~/run-output"
+	notebooksDir = "~/notebooks"
+)
+
+func main() {
+	var (
+		configFile    = flag.String("config", "", "Configuration file with AWS settings (optional)")
+		profile       = flag.String("profile", "aws", "AWS profile to use")
+		region        = flag.String("region", "us-west-2", "AWS region")
+		subnetID      = flag.String("subnet", "", "Subnet ID for the analysis instance")
+		sgID          = flag.String("security-group", "", "Security Group ID for the analysis instance")
+		instanceType  = flag.String("instance-type", "r7i.xlarge", "Instance type; the image must match its architecture")
+		image         = flag.String("image", "", "GCPy analysis image (required; see geoschem-aws image -with-analysis)")
+		output        = flag.String("output", "", "S3 URI of the run output to analyze (required)")
+		localPort     = flag.Int("port", jupyterPort, "Local port for the Jupyter tunnel")
+		maxHours      = flag.Float64("max-hours", 8, "End the session and terminate the instance after this many hours")
+		keepInstance  = flag.Bool("keep-instance", false, "Keep the instance running when the session ends")
+		keepArtifacts = flag.Bool("keep-artifacts", false, artifacts.FlagUsage)
+	)
+	flag.Parse()
+	artifacts.SetKeep(*keepArtifacts)
+
+	if *image == "" || *output == "" {
+		log.Fatal("Both -image and -output are required")
+	}
+
+	buildConfig := &common.BuildConfig{AWS: common.AWSConfig{Profile: *profile, Region: *region}}
+	if *configFile != "" {
+		loaded, err := common.LoadBuildConfig(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		buildConfig = loaded
+	}
+	if *subnetID != "" {
+		buildConfig.AWS.SubnetID = *subnetID
+	}
+	if *sgID != "" {
+		buildConfig.AWS.SecurityGroup = *sgID
+	}
+	if buildConfig.AWS.SubnetID == "" || buildConfig.AWS.SecurityGroup == "" {
+		log.Fatal("A subnet and security group are required (-subnet and -security-group, or -config)")
+	}
+
+	arch := common.InstanceArchitecture(*instanceType)
+	buildConfig.Architectures = map[string]common.ArchConfig{arch: {InstanceType: *instanceType}}
+	buildConfig.Tagging.BuildTag = "analysis"
+
+	mountCmd, err := storage.S3MountCommand(*output, outputMount, arch)
+	if err != nil {
+		log.Fatalf("Invalid -output: %v", err)
+	}
+	token, err := jupyterToken()
+	if err != nil {
+		log.Fatalf("Failed to generate Jupyter token: %v", err)
+	}
+
+	// The session lasts until Ctrl-C or -max-hours, whichever comes first
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*maxHours*float64(time.Hour)))
+	defer cancel()
+	ctx, interrupts := shutdown.Trap(ctx)
+	defer interrupts.Stop()
+	removeArtifacts := shutdown.Register(ctx, "temporary files", artifacts.Cleanup)
+	defer func() {
+		if err := removeArtifacts(); err != nil {
+			log.Printf("Warning: failed to remove temporary files: %v", err)
+		}
+	}()
+
+	cfg, err := common.LoadSDKConfig(ctx, buildConfig.AWS.Profile, buildConfig.AWS.Region)
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+
+	fmt.Printf("🔬 Launching %s to analyze %s\n", *instanceType, *output)
+	sshBuilder := builder.NewSSHBuilder(cfg)
+	instanceID, err := sshBuilder.BuildWithSSH(ctx, buildConfig, arch)
+	if err != nil {
+		log.Fatalf("Failed to launch analysis instance: %v", err)
+	}
+	cleanup := func() error { return nil }
+	if !*keepInstance {
+		cleanup = shutdown.Register(ctx, "analysis instance "+instanceID, func(ctx context.Context) error {
+			fmt.Println("\n🧹 Terminating analysis instance...")
+			return sshBuilder.CleanupInstance(ctx, instanceID)
+		})
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			log.Printf("Error terminating instance %s: %v", instanceID, err)
+		}
+	}()
+
+	if err := sshBuilder.PrepareInstance(ctx, true); err != nil {
+		interrupts.Fatalf("Failed to prepare instance: %v", err)
+	}
+	if err := docker.NewDockerBuilder(sshBuilder.Runner()).PullImage(ctx, *image); err != nil {
+		interrupts.Fatalf("Failed to pull %s: %v", *image, err)
+	}
+
+	fmt.Printf("🪣 Mounting %s read-only with mountpoint-s3...\n", *output)
+	if out, err := sshBuilder.ExecuteCommand(ctx, mountCmd); err != nil {
+		interrupts.Fatalf("Failed to mount %s: %v, output: %s", *output, err, out)
+	}
+
+	fmt.Println("📓 Starting Jupyter...")
+	// FUSE mounts can't be relabeled for SELinux, so the container runs unconfined
+	startCmd := fmt.Sprintf("mkdir -p %[1]s && podman run -d --name geoschem-jupyter --security-opt label=disable "+
+		"-p 127.0.0.1:%[2]d:%[2]d -v %[3]s:/workspace/output:ro -v %[1]s:/workspace/notebooks %[4]s "+
+		"jupyter lab --ip=0.0.0.0 --port=%[2]d --no-browser --notebook-dir=/workspace --ServerApp.token=%[5]s",
+		notebooksDir, jupyterPort, outputMount, *image, token)
+	if out, err := sshBuilder.ExecuteCommand(ctx, startCmd); err != nil {
+		interrupts.Fatalf("Failed to start Jupyter: %v, output: %s", err, out)
+	}
+	waitCmd := fmt.Sprintf("for i in $(seq 90); do curl -s -o /dev/null http://127.0.0.1:%d/api && exit 0; sleep 2; done; "+
+		"podman logs --tail 20 geoschem-jupyter; exit 1", jupyterPort)
+	if out, err := sshBuilder.ExecuteCommand(ctx, waitCmd); err != nil {
+		interrupts.Fatalf("Jupyter did not start: %v, output: %s", err, out)
+	}
+
+	fmt.Printf("\n✅ Jupyter is ready: http://localhost:%d/lab?token=%s\n", *localPort, token)
+	fmt.Println("   Run output is in output/; notebooks/ is on the instance, so copy work to S3 before ending.")
+	fmt.Printf("   Press Ctrl-C to end the session (ends on its own after %.0f hours).\n", *maxHours)
+
+	err = sshBuilder.GetSSHClient().Forward(ctx, fmt.Sprintf("127.0.0.1:%d", *localPort), fmt.Sprintf("127.0.0.1:%d", jupyterPort))
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		interrupts.Fatalf("Tunnel failed: %v", err)
+	}
+
+	if *keepInstance {
+		user := "<user>"
+		if hostOS, err := buildConfig.HostOS.Resolve(); err == nil {
+			user = hostOS.SSHUser
+		}
+		keyFile := sshBuilder.KeyPath()
+		if fetch := ssh.FetchKeyCommand(keyFile); fetch != "" {
+			fmt.Printf("\n💡 To fetch the key: %s\n", fetch)
+			keyFile = "key.pem"
+		}
+		fmt.Printf("💡 Instance %s kept running; reopen the tunnel with:\n   ssh -i %s -N -L %d:127.0.0.1:%d %s@%s\n",
+			instanceID, keyFile, *localPort, jupyterPort, user, sshBuilder.PublicIP())
+		fmt.Println("🗑️  Don't forget to terminate the instance manually!")
+	}
+}
+
+// jupyterToken returns a random token so only the tunnel's user can open Jupyter
+func jupyterToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
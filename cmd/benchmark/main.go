@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/benchmark"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/shutdown"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: benchmark compare [flags] <imageA> <imageB>\n\n")
+	fmt.Fprintf(os.Stderr, "Runs identical short simulations with both images on identical instances\n")
+	fmt.Fprintf(os.Stderr, "and reports throughput, cost, and diagnostic differences.\n\n")
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "compare" {
+		usage()
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	var (
+		profile      = fs.String("profile", "aws", "AWS profile to use")
+		region       = fs.String("region", "us-west-2", "AWS region")
+		subnetID     = fs.String("subnet", "", "Subnet ID for instances (required)")
+		sgID         = fs.String("security-group", "", "Security Group ID (required)")
+		instanceType = fs.String("instance-type", "c5.2xlarge", "Instance type used for both runs")
+		simulation   = fs.String("simulation", "fullchem", "Simulation type")
+		resolution   = fs.String("resolution", "4x5", "Grid resolution")
+		startDate    = fs.String("start-date", "2019-07-01", "Simulation start date (YYYY-MM-DD)")
+		endDate      = fs.String("end-date", "2019-07-02", "Simulation end date (YYYY-MM-DD)")
+		dataSource   = fs.String("data-source", "", "S3 URI of input data to sync onto the instances (optional)")
+		diagnostics  = fs.String("diagnostics", strings.Join(benchmark.DefaultDiagnostics, ","), "Comma-separated variables to compare")
+		shareResults = fs.String("share-results", "", "Opt in to uploading anonymized throughput to this shared S3 dataset (s3://bucket/prefix)")
+		restartID    = fs.String("restart", "", "Restart ID from the restart registry to initialize both runs")
+		metField     = fs.String("met", benchmark.DefaultMetField, "Met field the runs read: MERRA2, GEOSFP, GEOSIT")
+		noPreflight  = fs.Bool("skip-preflight", false, "Skip the input checks before the simulations start")
+	)
+	fs.Usage = func() {
+		usage()
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[2:])
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *subnetID == "" || *sgID == "" {
+		log.Fatal("Both -subnet and -security-group are required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+	defer cancel()
+
+	// Interrupts cancel the runs, and wait for their instances to terminate before exiting
+	ctx, interrupts := shutdown.Trap(ctx)
+	defer interrupts.Stop()
+
+	cfg, err := common.LoadSDKConfig(ctx, *profile, *region)
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+
+	buildConfig := &common.BuildConfig{
+		AWS: common.AWSConfig{
+			Region:        *region,
+			Profile:       *profile,
+			SubnetID:      *subnetID,
+			SecurityGroup: *sgID,
+		},
+	}
+
+	benchConfig := benchmark.Config{
+		InstanceType:  *instanceType,
+		Simulation:    *simulation,
+		Resolution:    *resolution,
+		StartDate:     *startDate,
+		EndDate:       *endDate,
+		DataSource:    *dataSource,
+		Diagnostics:   strings.Split(*diagnostics, ","),
+		ShareDataset:  *shareResults,
+		MetField:      *metField,
+		SkipPreflight: *noPreflight,
+	}
+
+	if *restartID != "" {
+		store, err := state.OpenDefault()
+		if err != nil {
+			log.Fatalf("Failed to open state store: %v", err)
+		}
+		start, err := time.Parse("2006-01-02", *startDate)
+		if err != nil {
+			log.Fatalf("Invalid start date: %v", err)
+		}
+		restart, err := state.NewRestartRegistry(store).Resolve(*restartID, state.RestartRequest{
+			Simulation: *simulation,
+			Resolution: *resolution,
+			StartDate:  start,
+		})
+		if err != nil {
+			log.Fatalf("Invalid restart: %v", err)
+		}
+		benchConfig.RestartURI = restart.S3URI
+	}
+
+	fmt.Printf("🏁 Comparing %s vs %s on %s\n", fs.Arg(0), fs.Arg(1), *instanceType)
+
+	runner := benchmark.NewRunner(cfg, buildConfig)
+	comparison, err := runner.Compare(ctx, benchConfig, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		log.Fatalf("Benchmark failed: %v", err)
+	}
+
+	fmt.Println()
+	fmt.Print(comparison.Report())
+
+	recordPerformance(benchConfig, comparison.A, comparison.B)
+
+	if comparison.A.Err != nil || comparison.B.Err != nil {
+		os.Exit(1)
+	}
+}
+
+// recordPerformance keeps successful measurements locally for wall-time prediction
+func recordPerformance(config benchmark.Config, results ...*benchmark.Result) {
+	var records []common.PerformanceRecord
+	for _, result := range results {
+		if result.Err == nil {
+			records = append(records, benchmark.PerformanceRecord(config, result))
+		}
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	store, err := state.OpenDefault()
+	if err == nil {
+		err = state.NewPerformanceLog(store).Append(records...)
+	}
+	if err != nil {
+		fmt.Printf("Warning: could not record benchmark performance: %v\n", err)
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/artifacts"
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/campaign"
+	"github.com/scttfrdmn/geoschem-aws/internal/catalog"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/runner"
+	"github.com/scttfrdmn/geoschem-aws/internal/shutdown"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: campaign <command> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  plan <manifest>   Expand a campaign manifest and estimate its runs and cost\n")
+	fmt.Fprintf(os.Stderr, "  run <manifest>    Run the campaign's unfinished runs within the vCPU budget\n")
+	fmt.Fprintf(os.Stderr, "  status [name]     Show a campaign's runs, or list campaigns\n")
+	fmt.Fprintf(os.Stderr, "  report <name>     Roll up a campaign's cost, failures and output\n")
+	fmt.Fprintf(os.Stderr, "  delete <name>     Forget a campaign (its output is kept)\n\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	store, err := state.OpenDefault()
+	if err != nil {
+		log.Fatalf("Failed to open state store: %v", err)
+	}
+
+	switch os.Args[1] {
+	case "plan":
+		runPlan(store, os.Args[2:])
+	case "run":
+		runRun(store, os.Args[2:])
+	case "status":
+		runStatus(store, os.Args[2:])
+	case "report":
+		runReport(store, os.Args[2:])
+	case "delete":
+		runDelete(store, os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+func runPlan(store *state.Store, args []string) {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	performanceData := fs.String("performance-data", "", "Additional benchmark records (newline-delimited JSON) for prediction")
+	metYears := fs.String("met-years", "", metYearsUsage)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: campaign plan [flags] <manifest>")
+	}
+
+	manifest, plans := expand(store, fs.Arg(0), *performanceData, *metYears)
+	fmt.Printf("📋 Campaign %s: %s\n\n", manifest.Name, manifest.Image)
+	if years := manifest.Sweep.MetYears; len(years) > 0 {
+		fmt.Printf("🌦️  Met-year ensemble over %d met years; emissions stay on %d\n\n", len(years), plans[0].Run.Config.EmissionsYear)
+	}
+	fmt.Print(campaign.FormatPlans(plans, min(manifest.Concurrency(), len(plans))))
+	if manifest.MaxVCPUs > 0 {
+		fmt.Printf("   (at most %d vCPUs at once)\n", manifest.MaxVCPUs)
+	}
+}
+
+func runRun(store *state.Store, args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var (
+		configFile      = fs.String("config", "", "Configuration file with AWS settings and the runs scheduler (optional)")
+		profile         = fs.String("profile", "aws", "AWS profile to use")
+		region          = fs.String("region", "us-west-2", "AWS region")
+		scheduler       = fs.String("scheduler", "", "Where to run: ec2, batch, slurm, eks (default: runs.scheduler from -config, else ec2)")
+		subnetID        = fs.String("subnet", "", "Subnet ID for run instances")
+		sgID            = fs.String("security-group", "", "Security Group ID for run instances")
+		performanceData = fs.String("performance-data", "", "Additional benchmark records (newline-delimited JSON) for prediction")
+		metYears        = fs.String("met-years", "", metYearsUsage)
+		catalogTable    = fs.String("catalog", "", "Results catalog table to record each finished run's output in (see 'results create')")
+		keepArtifacts   = fs.Bool("keep-artifacts", false, artifacts.FlagUsage)
+	)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: campaign run [flags] <manifest>")
+	}
+	artifacts.SetKeep(*keepArtifacts)
+
+	manifest, plans := expand(store, fs.Arg(0), *performanceData, *metYears)
+
+	buildConfig := &common.BuildConfig{AWS: common.AWSConfig{Profile: *profile, Region: *region}}
+	if *configFile != "" {
+		loaded, err := common.LoadBuildConfig(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		buildConfig = loaded
+	}
+	if *subnetID != "" {
+		buildConfig.AWS.SubnetID = *subnetID
+	}
+	if *sgID != "" {
+		buildConfig.AWS.SecurityGroup = *sgID
+	}
+	if *scheduler != "" {
+		buildConfig.Runs.Scheduler = *scheduler
+	}
+	if err := buildConfig.Runs.Validate(); err != nil {
+		log.Fatalf("Invalid scheduler settings: %v", err)
+	}
+	if buildConfig.Runs.SchedulerName() == common.SchedulerEC2 && (buildConfig.AWS.SubnetID == "" || buildConfig.AWS.SecurityGroup == "") {
+		log.Fatal("-subnet and -security-group are required to run on EC2")
+	}
+	if err := manifest.CheckEmissions(context.Background(), awscli.New(buildConfig.AWS.Profile, buildConfig.AWS.Region)); err != nil {
+		log.Fatalf("Invalid emissions overrides: %v", err)
+	}
+
+	// Interrupts stop new runs; schedulers stop the running ones as they unwind
+	ctx, interrupts := shutdown.Trap(context.Background())
+	defer interrupts.Stop()
+	removeArtifacts := shutdown.Register(ctx, "temporary files", artifacts.Cleanup)
+	defer func() {
+		if err := removeArtifacts(); err != nil {
+			log.Printf("Warning: failed to remove temporary files: %v", err)
+		}
+	}()
+
+	cfg, err := common.LoadSDKConfig(ctx, buildConfig.AWS.Profile, buildConfig.AWS.Region)
+	if err != nil {
+		interrupts.Fatalf("Failed to load AWS config: %v", err)
+	}
+	runScheduler, err := runner.NewScheduler(cfg, buildConfig)
+	if err != nil {
+		interrupts.Fatalf("%v", err)
+	}
+
+	concurrency, err := campaign.FitConcurrency(plans, manifest.Concurrency(), vcpuBudget(ctx, cfg, buildConfig, manifest))
+	if err != nil {
+		interrupts.Fatalf("%v", err)
+	}
+
+	campaigns := state.NewCampaigns(store)
+	record, err := campaign.Track(campaigns, fs.Arg(0), manifest, plans, runScheduler.Name())
+	if err != nil {
+		interrupts.Fatalf("Failed to record campaign: %v", err)
+	}
+	cost, _ := campaign.Totals(plans)
+	fmt.Printf("📋 Campaign %s: %d runs, estimated $%.2f\n", manifest.Name, len(plans), cost)
+
+	var results *catalog.Catalog
+	if *catalogTable != "" {
+		results = catalog.New(buildConfig.AWS.Profile, buildConfig.AWS.Region, *catalogTable)
+	}
+	runErr := campaign.Execute(ctx, store, runScheduler, record, plans, manifest, concurrency, results, buildConfig.AWS)
+	if record, err = campaigns.Get(manifest.Name); err == nil && record != nil {
+		fmt.Println()
+		fmt.Print(campaign.Report(record))
+	}
+	if runErr != nil {
+		interrupts.Fatalf("Campaign incomplete: %v", runErr)
+	}
+}
+
+// vcpuBudget returns the vCPUs the campaign may use at once: the manifest's max_vcpus, or the
+// free On-Demand quota for EC2 runs. Other schedulers enforce their own limits; 0 means none here.
+func vcpuBudget(ctx context.Context, cfg aws.Config, buildConfig *common.BuildConfig, manifest *campaign.Manifest) int {
+	if manifest.MaxVCPUs > 0 {
+		return manifest.MaxVCPUs
+	}
+	if buildConfig.Runs.SchedulerName() != common.SchedulerEC2 {
+		return 0
+	}
+	limit, used, err := common.NewQuotaChecker(cfg, buildConfig.AWS.Region).StandardVCPUUsage(ctx)
+	if err != nil {
+		fmt.Printf("⚠️  Skipping the vCPU quota check: %v\n", err)
+		return 0
+	}
+	fmt.Printf("📊 On-Demand vCPU quota: %d of %d in use\n", used, limit)
+	return max(limit-used, 0)
+}
+
+func runStatus(store *state.Store, args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	fs.Parse(args)
+	campaigns := state.NewCampaigns(store)
+
+	if fs.NArg() == 0 {
+		records, err := campaigns.List()
+		if err != nil {
+			log.Fatalf("Failed to list campaigns: %v", err)
+		}
+		if len(records) == 0 {
+			fmt.Println("No campaigns have run")
+			return
+		}
+		fmt.Printf("%-28s %-17s %s\n", "CAMPAIGN", "UPDATED", "PROGRESS")
+		for _, record := range records {
+			fmt.Printf("%-28s %-17s %s\n", record.Name, record.UpdatedAt.Local().Format("2006-01-02 15:04"), campaign.Summary(&record))
+		}
+		return
+	}
+
+	fmt.Print(campaign.Status(getCampaign(campaigns, fs.Arg(0))))
+}
+
+func runReport(store *state.Store, args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the campaign record as JSON")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: campaign report [flags] <name>")
+	}
+
+	record := getCampaign(state.NewCampaigns(store), fs.Arg(0))
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(record); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+	fmt.Print(campaign.Report(record))
+}
+
+func runDelete(store *state.Store, args []string) {
+	if len(args) != 1 {
+		log.Fatal("Usage: campaign delete <name>")
+	}
+	if err := state.NewCampaigns(store).Delete(args[0]); err != nil {
+		log.Fatalf("Failed to delete campaign: %v", err)
+	}
+	fmt.Printf("🗑️  Forgot campaign %s; its output is unchanged\n", args[0])
+}
+
+// getCampaign loads a campaign by name or exits
+func getCampaign(campaigns *state.Campaigns, name string) *state.CampaignRecord {
+	record, err := campaigns.Get(name)
+	if err != nil {
+		log.Fatalf("Failed to load campaign: %v", err)
+	}
+	if record == nil {
+		log.Fatalf("Campaign %s not found; start it with 'campaign run <manifest>'", name)
+	}
+	return record
+}
+
+// metYearsUsage describes the -met-years flag of plan and run
+const metYearsUsage = "Run the base period as a met-year ensemble over these years, e.g. 2010-2019 (overrides the manifest's years and met_years)"
+
+// expand loads a manifest, expands its runs and estimates them. Met years, if given, replace
+// the manifest's year sweep with a met-year ensemble.
+func expand(store *state.Store, path, performanceData, metYears string) (*campaign.Manifest, []campaign.Plan) {
+	manifest, err := campaign.LoadManifest(path)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if metYears != "" {
+		years, err := campaign.ParseYears(metYears)
+		if err != nil {
+			log.Fatalf("Invalid -met-years: %v", err)
+		}
+		manifest.Sweep.Years = nil
+		manifest.Sweep.MetYears = years
+	}
+	runs, err := manifest.Expand()
+	if err != nil {
+		log.Fatalf("Invalid campaign: %v", err)
+	}
+	predictor, err := state.NewPerformanceLog(store).LoadPredictor(performanceData)
+	if err != nil {
+		log.Fatalf("Failed to load performance data: %v", err)
+	}
+	plans, err := campaign.Estimate(runs, predictor, manifest.InstanceType)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return manifest, plans
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/data"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: data <command> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  plan    List required input files with size, staging time, and cost estimates\n")
+	fmt.Fprintf(os.Stderr, "  stage   Copy exactly the files a run reads from %s to your bucket or an FSx mount\n\n", data.SourceBucket)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "plan":
+		runPlan(os.Args[2:])
+	case "stage":
+		runStage(os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+// specFlags defines the flags describing a run on fs and returns a function that builds
+// its data plan once fs is parsed
+func specFlags(fs *flag.FlagSet) func() *data.Plan {
+	var (
+		simulation = fs.String("simulation", "fullchem", "Simulation type: fullchem, aerosol, TransportTracers, CH4, CO2, Hg")
+		resolution = fs.String("resolution", "4x5", "Grid resolution (4x5, 2x2.5, 0.5x0.625, 0.25x0.3125, or C48/C90/C180/...)")
+		metField   = fs.String("met", "MERRA2", "Met field: MERRA2, GEOSFP, GEOSIT")
+		startDate  = fs.String("start-date", "2019-07-01", "Simulation start date (YYYY-MM-DD)")
+		endDate    = fs.String("end-date", "2019-08-01", "Simulation end date (YYYY-MM-DD)")
+	)
+	return func() *data.Plan {
+		start, err := time.Parse("2006-01-02", *startDate)
+		if err != nil {
+			log.Fatalf("Invalid start date: %v", err)
+		}
+		end, err := time.Parse("2006-01-02", *endDate)
+		if err != nil {
+			log.Fatalf("Invalid end date: %v", err)
+		}
+
+		plan, err := data.BuildPlan(data.RunSpec{
+			Simulation: *simulation,
+			Resolution: *resolution,
+			MetField:   *metField,
+			StartDate:  start,
+			EndDate:    end,
+		})
+		if err != nil {
+			log.Fatalf("Failed to build data plan: %v", err)
+		}
+		return plan
+	}
+}
+
+func runPlan(args []string) {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	buildPlan := specFlags(fs)
+	var (
+		region     = fs.String("region", "us-west-2", "Region data will be staged into")
+		throughput = fs.Float64("throughput", data.DefaultThroughputMBps, "Expected staging throughput in MB/s")
+		listFiles  = fs.Bool("files", false, "List every required file")
+	)
+	fs.Parse(args)
+
+	plan := buildPlan()
+
+	estimate := plan.Estimate(data.TransferOptions{
+		DestinationRegion: *region,
+		ThroughputMBps:    *throughput,
+	})
+
+	fmt.Printf("📦 Input Data Plan: %s %s, %s met (%s grid), %s → %s\n\n",
+		plan.Spec.Simulation, plan.Spec.Resolution, plan.Spec.MetField, plan.MetGrid,
+		plan.Spec.StartDate.Format("2006-01-02"), plan.Spec.EndDate.Format("2006-01-02"))
+
+	if *listFiles {
+		for _, file := range plan.Files {
+			path := data.SourceBucket + "/" + file.Path
+			if file.Directory {
+				fmt.Printf("   %-10s ~%10s  %s  (%s)\n", file.Category, data.FormatBytes(file.Bytes), path, file.Note)
+			} else {
+				fmt.Printf("   %-10s  %10s  %s\n", file.Category, data.FormatBytes(file.Bytes), path)
+			}
+		}
+		fmt.Println()
+	}
+
+	byCategory := plan.BytesByCategory()
+	var categories []string
+	for category := range byCategory {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	fmt.Printf("By category:\n")
+	for _, category := range categories {
+		fmt.Printf("   %-10s %10s\n", category, data.FormatBytes(byCategory[category]))
+	}
+
+	fmt.Printf("\nTotal: %s in %d entries (sizes are estimates)\n", data.FormatBytes(plan.TotalBytes), len(plan.Files))
+	fmt.Printf("⏱️  Staging time: ~%s at %.0f MB/s\n", estimate.Duration.Round(time.Minute), *throughput)
+	if estimate.CrossRegion {
+		fmt.Printf("💰 Transfer: $%.2f (%s → %s inter-region)\n", estimate.TransferCost, data.SourceRegion, *region)
+	} else {
+		fmt.Printf("💰 Transfer: $0.00 (same region as %s)\n", data.SourceBucket)
+	}
+	fmt.Printf("💰 Requests: $%.2f\n", estimate.RequestCost)
+	fmt.Printf("💾 Storage per month: $%.2f on EBS gp3, $%.2f on S3 Standard\n", estimate.StorageCostEBS, estimate.StorageCostS3)
+
+	if plan.TotalBytes > 1<<40 {
+		fmt.Printf("\n⚠️  This run needs more than 1 TiB of input. Consider reading met fields directly from %s in %s.\n",
+			data.SourceBucket, data.SourceRegion)
+	}
+}
+
+func runStage(args []string) {
+	fs := flag.NewFlagSet("stage", flag.ExitOnError)
+	buildPlan := specFlags(fs)
+	var (
+		destination = fs.String("dest", "", "Where to stage: s3://bucket/prefix, or a directory such as an FSx for Lustre mount (required)")
+		hemcoConfig = fs.String("hemco-config", "", "The run directory's HEMCO_Config.rc; emissions are staged only with it")
+		version     = fs.String("version", "", "GEOS-Chem version whose restart file is preferred, e.g. 14.4.3 (default: newest)")
+		profile     = fs.String("profile", "aws", "AWS profile for an S3 destination")
+		region      = fs.String("region", "us-west-2", "Region of the destination bucket")
+		manifestOut = fs.String("manifest", "", "Also write the manifest to this local file")
+		dryRun      = fs.Bool("dry-run", false, "Resolve and print the manifest without copying anything")
+	)
+	fs.Parse(args)
+
+	if *destination == "" && !*dryRun {
+		log.Fatal("-dest is required")
+	}
+	plan := buildPlan()
+
+	opts := data.ManifestOptions{Version: *version}
+	if *hemcoConfig != "" {
+		file, err := os.Open(*hemcoConfig)
+		if err != nil {
+			log.Fatalf("Failed to open HEMCO config: %v", err)
+		}
+		opts.HEMCO, err = data.ReadHEMCOConfig(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("Failed to read HEMCO config: %v", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("🔍 Resolving the inputs of %s %s in %s...\n", plan.Spec.Simulation, plan.Spec.Resolution, data.SourceBucket)
+	manifest, err := data.ResolveManifest(ctx, data.NewBucketLister(), plan, opts)
+	if err != nil {
+		log.Fatalf("Failed to resolve manifest: %v", err)
+	}
+
+	byCategory := manifest.BytesByCategory()
+	var categories []string
+	for category := range byCategory {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	fmt.Printf("\n📋 Manifest %s: %d files, %s\n", manifest.Name(), len(manifest.Files), data.FormatBytes(manifest.TotalBytes))
+	for _, category := range categories {
+		fmt.Printf("   %-10s %10s\n", category, data.FormatBytes(byCategory[category]))
+	}
+	for _, missing := range manifest.Missing {
+		fmt.Printf("⚠️  Not in %s: %s\n", data.SourceBucket, missing)
+	}
+	for _, note := range manifest.Notes {
+		fmt.Printf("ℹ️  %s\n", note)
+	}
+
+	if *manifestOut != "" {
+		encoded, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode manifest: %v", err)
+		}
+		if err := os.WriteFile(*manifestOut, encoded, 0644); err != nil {
+			log.Fatalf("Failed to write manifest: %v", err)
+		}
+		fmt.Printf("📝 Manifest written to %s\n", *manifestOut)
+	}
+	if *dryRun {
+		return
+	}
+
+	fmt.Printf("\n🚚 Staging to %s...\n", *destination)
+	if err := data.Stage(ctx, manifest, data.StageOptions{Destination: *destination, Profile: *profile, Region: *region}); err != nil {
+		log.Fatalf("Staging failed: %v", err)
+	}
+	fmt.Printf("✅ Staged %s; manifest at %s\n", data.FormatBytes(manifest.TotalBytes), data.ManifestPath(*destination, manifest))
+	if strings.HasPrefix(*destination, "s3://") {
+		fmt.Printf("   Use it as a run's data source: -data-source %s\n", strings.TrimSuffix(*destination, "/"))
+	}
+}
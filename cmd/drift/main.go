@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/drift"
+)
+
+func main() {
+	var (
+		configFile = flag.String("config", "", "Configuration whose Batch compute environment, queues and job definition are checked")
+		profile    = flag.String("profile", "", "AWS profile to use (default: the config's, or aws)")
+		region     = flag.String("region", "", "AWS region (default: the config's, or us-west-2)")
+		terraform  = flag.String("terraform", "", "Comma-separated initialized Terraform directories to check with a refresh-only plan")
+		stacks     = flag.String("stack", "", "Comma-separated CloudFormation stacks to run drift detection on")
+		timeout    = flag.Duration("timeout", 15*time.Minute, "Give up after this long")
+	)
+	flag.Parse()
+
+	if *configFile == "" && *terraform == "" && *stacks == "" {
+		fmt.Fprintln(os.Stderr, "Nothing to check: pass -config, -terraform or -stack")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	config := &common.BuildConfig{AWS: common.AWSConfig{Profile: "aws", Region: "us-west-2"}}
+	if *configFile != "" {
+		loaded, err := common.LoadBuildConfig(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		config = loaded
+	}
+	if *profile != "" {
+		config.AWS.Profile = *profile
+	}
+	if *region != "" {
+		config.AWS.Region = *region
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	cli := awscli.New(config.AWS.Profile, config.AWS.Region)
+
+	var findings []drift.Finding
+	for _, dir := range splitList(*terraform) {
+		fmt.Printf("🔍 Refreshing Terraform state in %s...\n", dir)
+		found, err := drift.Terraform(ctx, dir)
+		if err != nil {
+			log.Fatalf("Terraform drift check failed: %v", err)
+		}
+		findings = append(findings, found...)
+	}
+	for _, stack := range splitList(*stacks) {
+		fmt.Printf("🔍 Detecting drift in stack %s...\n", stack)
+		found, err := drift.CloudFormation(ctx, cli, stack)
+		if err != nil {
+			log.Fatalf("CloudFormation drift check failed: %v", err)
+		}
+		findings = append(findings, found...)
+	}
+	if *configFile != "" {
+		fmt.Printf("🔍 Checking Batch resources in %s...\n", *configFile)
+		found, err := drift.Batch(ctx, cli, config)
+		if err != nil {
+			log.Fatalf("Batch check failed: %v", err)
+		}
+		findings = append(findings, found...)
+	}
+
+	fmt.Println()
+	fmt.Print(drift.Format(findings))
+	if len(findings) > 0 {
+		os.Exit(1)
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
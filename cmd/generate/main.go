@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/rundir"
+	"github.com/scttfrdmn/geoschem-aws/internal/runner"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: generate <command> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  slurm   Write an sbatch script that runs a platform-built image with Apptainer on any Slurm cluster\n")
+	fmt.Fprintf(os.Stderr, "  rundir  Write a GeosChem Classic run directory without createRunDir.sh\n\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "slurm":
+		runSlurm(os.Args[2:])
+	case "rundir":
+		runRunDir(os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+func runSlurm(args []string) {
+	fs := flag.NewFlagSet("slurm", flag.ExitOnError)
+	var (
+		image        = fs.String("image", "", "GeosChem image to run, pulled into the SIF file when it's missing")
+		sif          = fs.String("sif", "", "Apptainer SIF file on the cluster (default: named after the image, in the submit directory)")
+		mode         = fs.String("mode", runner.ModeClassic, "Model: classic (OpenMP, one node) or gchp (MPI)")
+		simulation   = fs.String("simulation", "fullchem", "Simulation type")
+		resolution   = fs.String("resolution", "4x5", "Grid resolution (C48, C90, ... for gchp)")
+		startDate    = fs.String("start-date", "2019-07-01", "Simulation start date (YYYY-MM-DD)")
+		endDate      = fs.String("end-date", "2019-08-01", "Simulation end date (YYYY-MM-DD)")
+		cpus         = fs.Int("cpus", 32, "OpenMP threads for classic runs")
+		nodes        = fs.Int("nodes", 1, "Nodes for gchp runs")
+		tasksPerNode = fs.Int("tasks-per-node", 0, "MPI ranks per node for gchp runs")
+		memory       = fs.String("mem", "", "Memory per node, e.g. 128G (default: the partition's)")
+		timeLimit    = fs.Duration("time", 24*time.Hour, "Wall-clock limit")
+		partition    = fs.String("partition", "", "Slurm partition (default: the cluster's default)")
+		account      = fs.String("account", "", "Slurm account to charge")
+		mpi          = fs.String("mpi", "", "MPI in the image: openmpi, intelmpi, mpich (default: from the image tag)")
+		dataDir      = fs.String("data-dir", "", "Input data (ExtData) directory on the cluster (required)")
+		outputDir    = fs.String("output-dir", "", "Output directory on the cluster (default: output in the submit directory)")
+		restartFile  = fs.String("restart-file", "", "Initial restart file on the cluster (default: the image's template restart)")
+		modules      = fs.String("modules", "", "Comma-separated environment modules to load, e.g. apptainer,openmpi/4.1")
+		output       = fs.String("o", "", "Write the script to this file instead of stdout")
+	)
+	fs.Parse(args)
+
+	spec := runner.SlurmScriptSpec{
+		Image:        *image,
+		SIF:          *sif,
+		Mode:         *mode,
+		Simulation:   *simulation,
+		Resolution:   *resolution,
+		StartDate:    *startDate,
+		EndDate:      *endDate,
+		Nodes:        *nodes,
+		TasksPerNode: *tasksPerNode,
+		CPUs:         *cpus,
+		Memory:       *memory,
+		TimeLimit:    *timeLimit,
+		Partition:    *partition,
+		Account:      *account,
+		MPI:          *mpi,
+		DataDir:      *dataDir,
+		OutputDir:    *outputDir,
+		RestartFile:  *restartFile,
+	}
+	if *modules != "" {
+		spec.Modules = strings.Split(*modules, ",")
+	}
+
+	script, err := runner.GenerateSlurmScript(spec)
+	if err != nil {
+		log.Fatalf("Invalid run configuration: %v", err)
+	}
+
+	if *output == "" {
+		fmt.Print(script)
+		return
+	}
+	if err := os.WriteFile(*output, []byte(script), 0755); err != nil {
+		log.Fatalf("Failed to write %s: %v", *output, err)
+	}
+	fmt.Printf("✅ Wrote %s; submit it with: sbatch %s\n", *output, *output)
+}
+
+func runRunDir(args []string) {
+	fs := flag.NewFlagSet("rundir", flag.ExitOnError)
+	var (
+		simulation      = fs.String("simulation", "TransportTracers", "Simulation type: "+strings.Join(rundir.SimulationNames(), ", "))
+		resolution      = fs.String("resolution", "4x5", "Grid resolution: 4x5, 2x2.5, 0.5x0.625, 0.25x0.3125")
+		metField        = fs.String("met", "MERRA2", "Met field: MERRA2, GEOSFP, GEOSIT")
+		startDate       = fs.String("start-date", "2019-07-01", "Simulation start date (YYYY-MM-DD)")
+		endDate         = fs.String("end-date", "2019-08-01", "Simulation end date (YYYY-MM-DD)")
+		levels          = fs.Int("levels", rundir.DefaultLevels, "Vertical levels: 72 or 47")
+		diagnostics     = fs.String("diagnostics", "SpeciesConc:daily", "Comma-separated Collection:frequency diagnostics ("+strings.Join(rundir.CollectionNames(), ", ")+"; hourly, daily, monthly or end)")
+		checkpoint      = fs.String("checkpoint", "", "Write restart files daily or monthly (default: at the end)")
+		species         = fs.String("species", "", "Comma-separated species to carry (default: the simulation's)")
+		speciesDatabase = fs.String("species-database", "", "Upstream species_database.yml to take the species from (required for fullchem, aerosol and Hg)")
+		emissions       = fs.String("emissions", "", "YAML list of base emissions for HEMCO_Config.rc")
+		dataDir         = fs.String("data-dir", rundir.DefaultDataDir, "Input data (ExtData) directory where the run reads it")
+		output          = fs.String("o", "", "Directory to write the run directory to (required)")
+	)
+	fs.Parse(args)
+
+	if *output == "" {
+		log.Fatalf("-o is required")
+	}
+	start, err := time.Parse("2006-01-02", *startDate)
+	if err != nil {
+		log.Fatalf("Invalid start date: %v", err)
+	}
+	end, err := time.Parse("2006-01-02", *endDate)
+	if err != nil {
+		log.Fatalf("Invalid end date: %v", err)
+	}
+
+	spec := rundir.Spec{
+		Simulation:      *simulation,
+		Resolution:      *resolution,
+		MetField:        *metField,
+		StartDate:       start,
+		EndDate:         end,
+		Levels:          *levels,
+		Checkpoint:      *checkpoint,
+		DataDir:         *dataDir,
+		SpeciesDatabase: *speciesDatabase,
+	}
+	if spec.Diagnostics, err = rundir.ParseDiagnostics(*diagnostics); err != nil {
+		log.Fatalf("Invalid diagnostics: %v", err)
+	}
+	if *species != "" {
+		spec.Species = strings.Split(*species, ",")
+	}
+	if *emissions != "" {
+		if spec.Emissions, err = rundir.LoadEmissions(*emissions); err != nil {
+			log.Fatalf("Failed to load emissions: %v", err)
+		}
+	}
+
+	runDir, err := rundir.Generate(spec)
+	if err != nil {
+		log.Fatalf("Invalid run configuration: %v", err)
+	}
+	if err := runDir.Write(*output); err != nil {
+		log.Fatalf("Failed to write run directory: %v", err)
+	}
+	for _, name := range runDir.Names() {
+		fmt.Printf("   %s\n", name)
+	}
+	fmt.Printf("✅ Wrote %s %s run directory %s\n", *simulation, *resolution, *output)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/bootstrap"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// newBootstrapCommand returns the bootstrap command, which creates or discovers the VPC,
+// subnets, security group, ECR repository and instance profile builds need, and writes
+// their IDs into the config file
+func newBootstrapCommand(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bootstrap",
+		Short: "Create the VPC, subnets, security group, ECR repository and IAM role builds need",
+		Args:  cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	var (
+		vpc        = fs.String("vpc", "", "Use this existing VPC and its subnets (or 'default') instead of creating a dedicated one")
+		zones      = fs.Int("zones", 2, "Availability zones to create subnets in")
+		repository = fs.String("repository", "geoschem", "ECR repository name")
+		sshCIDR    = fs.String("ssh-cidr", "", "Source allowed to reach SSH (default: your current public IP)")
+		ssmOnly    = fs.Bool("ssm-only", false, "Allow no SSH; for the ssm execution backend")
+	)
+	cmd.MarkFlagsMutuallyExclusive("ssm-only", "ssh-cidr")
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		region := g.regionOr(configRegion(g.config))
+		cfg, err := common.LoadSDKConfig(ctx, g.profile, region)
+		if err != nil {
+			log.Fatalf("Failed to load AWS config: %v", err)
+		}
+
+		fmt.Printf("🏗️  Bootstrapping GeosChem resources in %s...\n", region)
+		resources, err := bootstrap.New(cfg, g.profile, region).Run(ctx, bootstrap.Options{
+			VPC:        *vpc,
+			Zones:      *zones,
+			Repository: *repository,
+			SSHCIDR:    *sshCIDR,
+			SSMOnly:    *ssmOnly,
+		})
+		for _, created := range resources.Created {
+			fmt.Printf("   ➕ %s\n", created)
+		}
+		if err != nil {
+			log.Fatalf("Bootstrap failed (run it again to continue where it stopped): %v", err)
+		}
+
+		fmt.Printf("✅ VPC %s, subnets %s, security group %s\n", resources.VPCID,
+			strings.Join(resources.SubnetIDs, ", "), resources.SecurityGroupID)
+		fmt.Printf("   ECR repository %s, instance profile %s\n", resources.RepositoryURI, resources.InstanceProfile)
+		if err := bootstrap.WriteConfig(g.config, g.profile, region, resources); err != nil {
+			log.Fatalf("Failed to update %s: %v", g.config, err)
+		}
+		fmt.Printf("📝 Wrote the IDs to %s\n", g.config)
+		if len(resources.Created) > 0 {
+			fmt.Println("   New instance profiles can take a few seconds before EC2 accepts them.")
+		}
+	}
+	return cmd
+}
+
+// newTeardownCommand returns the teardown command, which deletes what bootstrap created and
+// clears it from the config file
+func newTeardownCommand(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "teardown",
+		Short: "Delete what bootstrap created",
+		Args:  cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	var (
+		dryRun           = fs.Bool("dry-run", false, "List what would be deleted without deleting it")
+		repository       = fs.String("repository", "", "ECR repository name (default: the config's ecr_repository)")
+		deleteRepository = fs.Bool("delete-repository", false, "Also delete the ECR repository and every image in it")
+	)
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		region := g.regionOr(configRegion(g.config))
+		cfg, err := common.LoadSDKConfig(ctx, g.profile, region)
+		if err != nil {
+			log.Fatalf("Failed to load AWS config: %v", err)
+		}
+		if *repository == "" {
+			if config, err := common.LoadBuildConfig(g.config); err == nil && config.ECRRepository != "" {
+				*repository = path.Base(config.ECRRepository)
+			}
+		}
+
+		steps, removed, err := bootstrap.New(cfg, g.profile, region).Plan(ctx, bootstrap.TeardownOptions{
+			Repository:       *repository,
+			DeleteRepository: *deleteRepository,
+		})
+		if err != nil {
+			log.Fatalf("Teardown failed: %v", err)
+		}
+		if len(steps) == 0 {
+			fmt.Printf("Nothing created by bootstrap found in %s\n", region)
+			return
+		}
+
+		for _, step := range steps {
+			fmt.Printf("🧹 %s\n", step.Description)
+			if *dryRun {
+				continue
+			}
+			if err := step.Run(ctx); err != nil {
+				log.Fatalf("   ❌ %v\nRun teardown again once the error is resolved; finished deletions are skipped", err)
+			}
+		}
+		if *dryRun {
+			return
+		}
+
+		if err := bootstrap.ClearConfig(g.config, removed); err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("Warning: could not clear the deleted IDs from %s: %v\n", g.config, err)
+		}
+		fmt.Printf("✅ Removed %d resources\n", len(steps))
+		if !*deleteRepository && *repository != "" {
+			fmt.Printf("   ECR repository %s kept; pass --delete-repository to delete it with its images\n", *repository)
+		}
+	}
+	return cmd
+}
+
+// configRegion returns the config file's region, or the default when there is no config
+func configRegion(configFile string) string {
+	if config, err := common.LoadBuildConfig(configFile); err == nil {
+		return config.AWS.Region
+	}
+	return defaultRegion
+}
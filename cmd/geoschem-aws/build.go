@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/artifacts"
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/shutdown"
+)
+
+// newBuildCommand returns the build command, which builds one combination, every
+// combination for an architecture, or the whole matrix from the config file
+func newBuildCommand(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build matrix combinations from the config file and push them to ECR",
+		Args:  cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	var (
+		arch          = fs.String("arch", "", "Architecture: x86_64 or arm64")
+		compiler      = fs.String("compiler", "", "Compiler: intel2024, gcc13, aocc4")
+		mpi           = fs.String("mpi", "", "MPI: intelmpi, openmpi, mpich")
+		all           = fs.Bool("all", false, "Build all combinations for --arch")
+		matrix        = fs.Bool("matrix", false, "Build the complete matrix")
+		checkQuotas   = fs.Bool("check-quotas", false, "Check AWS quotas before building (always done for --matrix)")
+		keepGoing     = fs.Bool("keep-going", false, "Build every combination even after failures; fail only if critical combinations fail")
+		resume        = fs.Bool("resume", false, "With --all or --matrix, skip the combinations the last build with the same settings finished and retry the rest")
+		concurrency   = fs.Int("concurrency", 0, "Combinations to build at once with --all or --matrix (overrides config file)")
+		backend       = fs.String("backend", "", "Execution backend for builds: ssh, ssm, batch (overrides config file)")
+		transport     = fs.String("transport", "", "How build instances are driven: ssh, or ssm for private subnets with no public IP, key pair or port 22 (same as --backend)")
+		manifest      = fs.Bool("manifest", false, "Also push a multi-arch manifest list combining each image with its other-architecture build (sets push.manifest)")
+		sourceDir     = fs.String("source-dir", "", "Local working copy to build instead of cloning source.repo, uncommitted changes included (ssh backend; sets source.local)")
+		keepArtifacts = fs.Bool("keep-artifacts", false, artifacts.FlagUsage)
+	)
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		artifacts.SetKeep(*keepArtifacts)
+
+		config := g.loadConfig()
+		if *keepGoing {
+			config.Execution.KeepGoing = true
+		}
+		if *resume {
+			if !*all && !*matrix {
+				log.Fatal("--resume needs --all or --matrix")
+			}
+			config.Execution.Resume = true
+		}
+		if *manifest {
+			config.Push.Manifest = true
+		}
+		if *sourceDir != "" {
+			config.Source.Local = *sourceDir
+		}
+		if *concurrency < 0 {
+			log.Fatalf("Invalid concurrency: %d", *concurrency)
+		}
+		if *concurrency > 0 {
+			config.Execution.Concurrency = *concurrency
+		}
+		if *transport != "" {
+			if *transport != common.BackendSSH && *transport != common.BackendSSM {
+				log.Fatalf("Invalid --transport '%s' (expected ssh or ssm)", *transport)
+			}
+			if *backend != "" && *backend != *transport {
+				log.Fatalf("--transport %s conflicts with --backend %s", *transport, *backend)
+			}
+			*backend = *transport
+		}
+		if *backend != "" {
+			config.Execution.Backend = *backend
+			if err := config.Execution.Validate(); err != nil {
+				log.Fatalf("Invalid backend: %v", err)
+			}
+		}
+
+		ctx := context.Background()
+		b := newBuilder(ctx, config.AWS.Profile, config.AWS.Region)
+
+		if *checkQuotas || *matrix {
+			fmt.Println("\n🔍 Checking AWS quotas...")
+			if err := b.CheckQuotas(ctx); err != nil {
+				log.Printf("Warning: Could not check quotas: %v", err)
+				fmt.Println("Continuing with build (quota check failed)...")
+			}
+			fmt.Println()
+		}
+
+		// Interrupts cancel the builds, which terminate their instances before the command exits
+		ctx, interrupts := shutdown.Trap(ctx)
+		defer interrupts.Stop()
+
+		var err error
+		switch {
+		case *matrix:
+			fmt.Println("Building complete matrix...")
+			err = b.BuildMatrix(ctx, config)
+		case *all:
+			if *arch == "" {
+				log.Fatal("--arch required with --all")
+			}
+			fmt.Printf("Building all combinations for %s...\n", *arch)
+			err = b.BuildAllForArch(ctx, config, *arch)
+		default:
+			if *arch == "" || *compiler == "" || *mpi == "" {
+				log.Fatal("--arch, --compiler, and --mpi required for single build")
+			}
+			fmt.Printf("Building single combination: %s-%s-%s\n", *arch, *compiler, *mpi)
+			err = b.BuildSingle(ctx, config, *arch, *compiler, *mpi)
+		}
+		if err != nil {
+			if *all || *matrix {
+				fmt.Println("Rerun with --resume to keep the combinations that built")
+			}
+			log.Fatalf("Build failed: %v", err)
+		}
+
+		fmt.Println("Build completed successfully!")
+	}
+	return cmd
+}
+
+// newBuilder prints the version and AWS settings and creates a builder, or exits
+func newBuilder(ctx context.Context, profile, region string) *builder.Builder {
+	fmt.Printf("%s v%s\n", common.Name, common.GetVersion())
+	fmt.Printf("Using AWS Profile: %s, Region: %s\n", profile, region)
+
+	b, err := builder.New(ctx, profile, region)
+	if err != nil {
+		log.Fatalf("Failed to initialize builder: %v", err)
+	}
+	return b
+}
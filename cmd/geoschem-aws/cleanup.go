@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// newCleanupCommand returns the cleanup command, which removes resources left behind by
+// failed builds, or reports the matrix images nobody pulled or ran so they can be dropped
+// from the config
+func newCleanupCommand(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Remove resources left behind by failed builds, or report unused images",
+		Args:  cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	var (
+		dryRun     = fs.Bool("dry-run", false, "List leftover resources without removing them")
+		imageUsage = fs.Bool("image-usage", false, "Report ECR pulls and recorded runs of each matrix image, flagging unused combinations")
+		usageDays  = fs.Int("usage-days", 90, "With --image-usage, days without pulls or runs before a combination counts as unused")
+	)
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		config := g.loadConfig()
+		ctx := context.Background()
+		b := newBuilder(ctx, config.AWS.Profile, config.AWS.Region)
+
+		if *imageUsage {
+			var records []common.PerformanceRecord
+			if store, err := state.OpenDefault(); err == nil {
+				records, _ = state.NewPerformanceLog(store).Records()
+			}
+			since := time.Now().AddDate(0, 0, -*usageDays)
+			usages, err := b.ImageUsage(ctx, config, records, since)
+			if err != nil {
+				log.Fatalf("Image usage failed: %v", err)
+			}
+			fmt.Print(builder.FormatImageUsage(usages, since))
+			return
+		}
+
+		if err := b.Janitor(ctx, *dryRun); err != nil {
+			log.Fatalf("Janitor failed: %v", err)
+		}
+	}
+	return cmd
+}
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/artifacts"
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+	"github.com/scttfrdmn/geoschem-aws/internal/geoschem"
+	"github.com/scttfrdmn/geoschem-aws/internal/shutdown"
+	"github.com/scttfrdmn/geoschem-aws/internal/ssh"
+)
+
+// imageHost is a build instance driven over SSH or SSM
+type imageHost interface {
+	builder.BuildHost
+	TestDockerConnection(ctx context.Context) error
+}
+
+// newImageCommand returns the image command, which builds one image from a named build
+// configuration on its own instance and pushes it to ECR
+func newImageCommand(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "image",
+		Short: "Build one image from a named build configuration on its own instance",
+		Args:  cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	var (
+		buildConfig     = fs.String("build-config", "geoschem-gcc-x86_64", "Build configuration name (see --list)")
+		mpi             = fs.String("mpi", "", "MPI implementation: openmpi, mpich, intelmpi (default: openmpi)")
+		sourceRepo      = fs.String("repo", "https://github.com/geoschem/GeosChem.git", "Source repository URL")
+		sourceBranch    = fs.String("branch", "main", "Source branch/tag")
+		sourceDir       = fs.String("source-dir", "", "Local working copy to upload instead of cloning --repo, uncommitted changes included (SSH only)")
+		submodules      = fs.String("submodules", "", "Refs to check out in submodules instead of the commits --branch pins, e.g. src/HEMCO=3.9.0,src/GEOS-Chem=14.4.3")
+		patches         = fs.String("patches", "", "Patch files or pull requests to apply before building, each [path=]patch, e.g. fix.patch,src/GEOS-Chem=geoschem/geos-chem#2345")
+		tokenSecret     = fs.String("token-secret", "", "Secrets Manager secret holding a GitHub token for a private https:// --repo")
+		deployKeySecret = fs.String("deploy-key-secret", "", "Secrets Manager secret holding an SSH deploy key for a private git@ --repo")
+		dockerfilePath  = fs.String("dockerfile", "", "Dockerfile in the source checkout (default: "+geoschem.DefaultDockerfile+")")
+		buildContext    = fs.String("build-context", "", "Build context directory in the source checkout (default: the Dockerfile's directory)")
+		localDockerfile = fs.String("local-dockerfile", "", "Dockerfile on local disk to upload and build in --build-context instead of --dockerfile")
+		imageTag        = fs.String("tag", "latest", "Docker image tag")
+		optimization    = fs.String("optimization", "", "Compiler optimization preset (default: portable)")
+		mathLibrary     = fs.String("math-library", "", "Math library stack: default, aocl (default: per configuration)")
+		mapl            = fs.String("mapl", "", "MAPL version for GCHP configurations (default: "+geoschem.DefaultMAPLVersion+")")
+		ompThreads      = fs.Int("omp-threads", 0, "Default OMP_NUM_THREADS baked into the image (0 = all vCPUs)")
+		ompStackSize    = fs.String("omp-stacksize", "", "Default OMP_STACKSIZE baked into the image (default: 500m)")
+		baseImage       = fs.String("base-image", "", "Container base image (dnf-based, default: per configuration)")
+		hostOS          = fs.String("host-os", "", "Build instance OS: rocky8, rocky9, rocky10, alma9, al2023, ubuntu22, ubuntu24 (default: rocky9)")
+		amiPattern      = fs.String("ami-pattern", "", "Override the AMI name filter ({arch} expands to the AMI architecture)")
+		amiOwner        = fs.String("ami-owner", "", "Override the AMI owner account")
+		subnetID        = fs.String("subnet", "", "Subnet ID for instance (required)")
+		sgID            = fs.String("security-group", "", "Security Group ID (required)")
+		transport       = fs.String("transport", common.BackendSSH, "How to drive the build instance: ssh, or ssm for private subnets with no public IP, key pair or port 22")
+		ecrRepository   = fs.String("ecr", "", "ECR repository URL for pushing (optional)")
+		ecrStrategy     = fs.String("ecr-strategy", docker.RepoSingle, "ECR layout: single, per-arch, or per-image")
+		ccacheS3        = fs.String("ccache-s3", "", "S3 prefix for a compiler cache shared across builds (optional)")
+		pullThrough     = fs.String("pull-through", "", "ECR pull-through cache prefix for Docker Hub base images (optional)")
+		withDeps        = fs.Bool("deps", false, "Build FROM a dependencies image, reusing the published one when ECR has it")
+		depsImage       = fs.String("deps-image", "", "Published dependencies image to build FROM (implies --deps)")
+		rebuildDeps     = fs.Bool("rebuild-deps", false, "Rebuild the dependencies image even when ECR has it")
+		depsOnly        = fs.Bool("deps-only", false, "Build and push only the dependencies image")
+		depsStack       = fs.String("deps-stack", "", "Dependency stack version (default: compatible with the GEOS-Chem version, see --list)")
+		coreCount       = fs.Int("core-count", 0, "Physical cores to enable on the build instance (0 = instance default)")
+		disableSMT      = fs.Bool("disable-smt", false, "Disable hyperthreading on the build instance")
+		skipBuild       = fs.Bool("skip-build", false, "Skip Docker build (test SSH only)")
+		skipPush        = fs.Bool("skip-push", false, "Skip ECR push")
+		pushParallel    = fs.Int("push-parallel", 0, "Layers to upload to ECR at once (0 = podman default)")
+		pushCompress    = fs.String("push-compression", "", "Layer compression for the ECR push: gzip, zstd, zstd:chunked (default: gzip)")
+		manifest        = fs.Bool("manifest", false, "Also push a multi-arch manifest list with the other architecture's image, when ECR has it")
+		skipUpdate      = fs.Bool("skip-update", false, "Skip system package updates (faster)")
+		skipCleanup     = fs.Bool("keep-instance", false, "Keep instance running after build")
+		keepArtifacts   = fs.Bool("keep-artifacts", false, artifacts.FlagUsage)
+		instanceConnect = fs.Bool("instance-connect", false, "Push a one-time key with EC2 Instance Connect instead of importing a key pair")
+		keyStorage      = fs.String("key-storage", "", "Where the generated private key is kept: file, passphrase (GEOSCHEM_KEY_PASSPHRASE), ssm, secretsmanager (default: file)")
+		keyType         = fs.String("key-type", "", "Generated SSH key type: ed25519 or rsa4096 (default: ed25519)")
+		listConfigs     = fs.Bool("list", false, "List available build configurations")
+		withAnalysis    = fs.Bool("with-analysis", false, "Also build the GCPy analysis image for the architecture")
+	)
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		region := g.regionOr(defaultRegion)
+		artifacts.SetKeep(*keepArtifacts)
+
+		// List available configurations if requested
+		if *listConfigs {
+			fmt.Print(geoschem.ListAvailableConfigs())
+			fmt.Print(geoschem.ListOptimizationPresets(""))
+			fmt.Print(geoschem.ListAnalysisConfigs())
+			fmt.Print(geoschem.ListDependencyStacks())
+			return
+		}
+
+		// Validate required parameters
+		if *subnetID == "" || *sgID == "" {
+			log.Fatal("Both --subnet and --security-group are required")
+		}
+		switch *transport {
+		case common.BackendSSH:
+		case common.BackendSSM:
+			if *instanceConnect || *keyStorage != "" || *keyType != "" {
+				log.Fatal("--instance-connect, --key-storage and --key-type apply to SSH; --transport ssm uses no keys")
+			}
+			if *sourceDir != "" {
+				log.Fatal("--source-dir uploads over SSH; use --transport ssh")
+			}
+		default:
+			log.Fatalf("Invalid --transport '%s' (expected ssh or ssm)", *transport)
+		}
+		cacheConfig := common.CacheConfig{CcacheS3: *ccacheS3}
+		if *pullThrough != "" {
+			cacheConfig.PullThrough = map[string]string{"docker.io": *pullThrough}
+		}
+		if err := cacheConfig.Validate(); err != nil {
+			log.Fatalf("Invalid cache settings: %v", err)
+		}
+		pushConfig := common.PushConfig{ParallelUploads: *pushParallel, Compression: *pushCompress, Manifest: *manifest}
+		if err := pushConfig.Validate(); err != nil {
+			log.Fatalf("Invalid push settings: %v", err)
+		}
+		if err := docker.ValidateRepositoryStrategy(*ecrStrategy); err != nil {
+			log.Fatalf("Invalid --ecr-strategy: %v", err)
+		}
+		var submoduleRefs map[string]string
+		if *submodules != "" {
+			if *sourceDir != "" {
+				log.Fatal("--submodules applies to clones; check the refs out in --source-dir instead")
+			}
+			refs, err := common.ParseSubmoduleRefs(*submodules)
+			if err != nil {
+				log.Fatalf("Invalid --submodules: %v", err)
+			}
+			submoduleRefs = refs
+		}
+		var sourcePatches []common.SourcePatch
+		if *patches != "" {
+			parsed, err := common.ParsePatches(*patches, *sourceRepo)
+			if err != nil {
+				log.Fatalf("Invalid --patches: %v", err)
+			}
+			sourcePatches = parsed
+		}
+		if *tokenSecret != "" || *deployKeySecret != "" {
+			if *sourceDir != "" {
+				log.Fatal("--token-secret and --deploy-key-secret apply to clones; --source-dir uploads the working copy")
+			}
+			if err := common.ValidateCloneCredentials(*sourceRepo, *tokenSecret, *deployKeySecret); err != nil {
+				log.Fatalf("Invalid clone credentials: %v", err)
+			}
+		}
+		dockerfile := common.DockerfileConfig{Path: *dockerfilePath, Context: *buildContext, Local: *localDockerfile}
+		if err := dockerfile.Validate(); err != nil {
+			log.Fatalf("Invalid Dockerfile: %v", err)
+		}
+		if *depsOnly && *depsImage != "" {
+			log.Fatal("--deps-only builds the dependencies image, so it can't be combined with --deps-image")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour) // Extended timeout for builds
+		defer cancel()
+
+		// Interrupts cancel the build and terminate the instance before exiting
+		ctx, interrupts := shutdown.Trap(ctx)
+		defer interrupts.Stop()
+		removeArtifacts := shutdown.Register(ctx, "temporary files", artifacts.Cleanup)
+		defer func() {
+			if err := removeArtifacts(); err != nil {
+				log.Printf("Warning: failed to remove temporary files: %v", err)
+			}
+		}()
+
+		// Load AWS config
+		cfg, err := common.LoadSDKConfig(ctx, g.profile, region)
+		if err != nil {
+			log.Fatalf("Failed to load AWS config: %v", err)
+		}
+
+		// Get GeosChem build configuration
+		geosBuildConfig, err := geoschem.GetBuildConfigByName(*buildConfig)
+		if err != nil {
+			log.Fatalf("Invalid build configuration: %v", err)
+		}
+
+		if *mpi != "" {
+			geosBuildConfig.MPI = *mpi
+		}
+		if *optimization != "" {
+			geosBuildConfig.Optimization = *optimization
+		}
+		if *mathLibrary != "" {
+			geosBuildConfig.MathLibrary = *mathLibrary
+		}
+		if *mapl != "" {
+			geosBuildConfig.MAPL = *mapl
+		}
+		geosBuildConfig.OpenMP.NumThreads = *ompThreads
+		if *ompStackSize != "" {
+			geosBuildConfig.OpenMP.StackSize = *ompStackSize
+		}
+		if *baseImage != "" {
+			geosBuildConfig.BaseImage = *baseImage
+		}
+		geosBuildConfig.DependencyStack = *depsStack
+		builder.UseDockerfile(dockerfile, geosBuildConfig)
+
+		hostOSConfig := common.HostOSConfig{
+			Name:           *hostOS,
+			AMINamePattern: *amiPattern,
+			AMIOwner:       *amiOwner,
+		}
+		resolvedHostOS, err := hostOSConfig.Resolve()
+		if err != nil {
+			log.Fatalf("Invalid host OS: %v", err)
+		}
+
+		// Validate configuration
+		err = geosBuildConfig.Validate()
+		if err != nil {
+			log.Fatalf("Build configuration validation failed: %v", err)
+		}
+
+		// CPU options for the build instance
+		var cpuOptions *common.CPUOptions
+		if *coreCount > 0 || *disableSMT {
+			cpuOptions = &common.CPUOptions{CoreCount: *coreCount}
+			if *disableSMT {
+				cpuOptions.ThreadsPerCore = 1
+			}
+		}
+
+		// SSH needs a key and a reachable port 22; SSM needs only the agent and instance profile
+		var host imageHost
+		var sshBuilder *builder.SSHBuilder
+		if *transport == common.BackendSSM {
+			host = builder.NewSSMHost(cfg)
+		} else {
+			sshBuilder = builder.NewSSHBuilder(cfg)
+			host = sshBuilder
+		}
+
+		// Create build configuration for AWS
+		awsBuildConfig := &common.BuildConfig{
+			AWS: common.AWSConfig{
+				Region:          region,
+				Profile:         g.profile,
+				SubnetID:        *subnetID,
+				SecurityGroup:   *sgID,
+				InstanceConnect: *instanceConnect,
+				KeyStorage:      *keyStorage,
+				KeyType:         *keyType,
+			},
+			Architectures: map[string]common.ArchConfig{
+				"x86_64": {
+					InstanceType: "c5.2xlarge", // 8 vCPU for faster builds
+					CPUOptions:   cpuOptions,
+				},
+				"arm64": {
+					InstanceType: "c8g.2xlarge", // 8 vCPU Graviton4
+					CPUOptions:   cpuOptions,
+				},
+			},
+			HostOS:  hostOSConfig,
+			Tagging: common.TaggingConfig{BuildTag: geosBuildConfig.Name},
+			Source: common.SourceConfig{
+				Repo: *sourceRepo, Branch: *sourceBranch, Local: *sourceDir, Submodules: submoduleRefs, Patches: sourcePatches, Dockerfile: dockerfile,
+				TokenSecret: *tokenSecret, DeployKeySecret: *deployKeySecret,
+			},
+		}
+
+		// Pack the local source tree before launching, so a problem with it costs no instance time
+		removeSource, err := builder.PackLocalSource(ctx, awsBuildConfig)
+		if err != nil {
+			log.Fatalf("Failed to pack %s: %v", *sourceDir, err)
+		}
+		defer removeSource()
+		if err := builder.ReadPatches(awsBuildConfig); err != nil {
+			log.Fatalf("Failed to read patches: %v", err)
+		}
+		if err := builder.ReadDockerfiles(awsBuildConfig); err != nil {
+			log.Fatalf("Failed to read Dockerfile: %v", err)
+		}
+		dockerfile = awsBuildConfig.Source.Dockerfile
+		if err := builder.CheckDockerfile(awsBuildConfig.Source, dockerfile, geosBuildConfig); err != nil {
+			log.Fatalf("Invalid Dockerfile: %v", err)
+		}
+		if err := builder.NewSourceChecker().Check(ctx, awsBuildConfig.Source, dockerfile, geosBuildConfig); err != nil {
+			log.Fatalf("Source check failed: %v", err)
+		}
+
+		var instanceID string
+
+		fmt.Printf("🚀 Starting GeosChem build: %s\n", geosBuildConfig.Name)
+		fmt.Printf("📋 Configuration:\n")
+		fmt.Printf("   Architecture: %s\n", geosBuildConfig.Architecture)
+		fmt.Printf("   Compiler: %s\n", geosBuildConfig.Compiler)
+		if geosBuildConfig.ModelName() == geoschem.ModelGCHP {
+			fmt.Printf("   Model: GCHP (ESMF %s, MAPL %s)\n", geosBuildConfig.Dependencies.ESMF, geosBuildConfig.MAPLVersion())
+		}
+		fmt.Printf("   MPI: %s %s\n", geosBuildConfig.MPIName(), geosBuildConfig.MPIVersion())
+		fmt.Printf("   Optimization: %s\n", geosBuildConfig.OptimizationName())
+		fmt.Printf("   Math Library: %s\n", geosBuildConfig.MathLibraryName())
+		if geosBuildConfig.DependencyStack != "" {
+			fmt.Printf("   Dependency Stack: %s (GEOS-Chem %s)\n", geosBuildConfig.DependencyStack, geosBuildConfig.GeosChemVersion())
+		}
+		fmt.Printf("   Host OS: %s\n", resolvedHostOS.DisplayName)
+		fmt.Printf("   Transport: %s\n", *transport)
+		if source := awsBuildConfig.Source; source.Archive != "" {
+			fmt.Printf("   Source: %s@%s (local, %s)\n", source.Local, source.Branch, source.Revision)
+		} else {
+			fmt.Printf("   Source: %s@%s\n", *sourceRepo, *sourceBranch)
+		}
+		if *submodules != "" {
+			fmt.Printf("   Submodules: %s\n", *submodules)
+		}
+		if *patches != "" {
+			fmt.Printf("   Patches: %s\n", *patches)
+		}
+		if dockerfile.Local != "" {
+			fmt.Printf("   Dockerfile: %s (local, built in %s)\n", dockerfile.Local, geosBuildConfig.BuildContextDir())
+		} else if dockerfile.IsSet() {
+			fmt.Printf("   Dockerfile: %s (built in %s)\n", geosBuildConfig.GetDockerfilePath(), geosBuildConfig.BuildContextDir())
+		}
+		if *tokenSecret != "" {
+			fmt.Printf("   Clone Token: %s (Secrets Manager)\n", *tokenSecret)
+		} else if *deployKeySecret != "" {
+			fmt.Printf("   Deploy Key: %s (Secrets Manager)\n", *deployKeySecret)
+		}
+		fmt.Printf("   Tag: %s\n", *imageTag)
+
+		// Step 1: Launch instance and connect over the transport
+		fmt.Println("\n=== Step 1: Launch Build Instance ===")
+		instanceID, err = host.Launch(ctx, awsBuildConfig, geosBuildConfig.Architecture)
+		if err != nil {
+			log.Fatalf("Failed to setup build instance: %v", err)
+		}
+		cleanup := func() error { return nil }
+		if !*skipCleanup {
+			cleanup = shutdown.Register(ctx, "build instance "+instanceID, func(ctx context.Context) error {
+				fmt.Println("\n🧹 Cleaning up instance...")
+				return host.Cleanup(ctx)
+			})
+		}
+
+		// Step 2: Prepare instance
+		fmt.Println("\n=== Step 2: Prepare Build Environment ===")
+		err = host.Prepare(ctx, *skipUpdate)
+		if err != nil {
+			interrupts.Fatalf("Failed to prepare instance: %v", err)
+		}
+
+		// Step 3: Test Docker
+		fmt.Println("\n=== Step 3: Verify Docker Installation ===")
+		err = host.TestDockerConnection(ctx)
+		if err != nil {
+			interrupts.Fatalf("Docker verification failed: %v", err)
+		}
+
+		if !*skipBuild {
+			// Step 4: Build Docker container
+			fmt.Println("\n=== Step 4: Build GeosChem Container ===")
+
+			// Create Docker builder
+			dockerBuilder := docker.NewDockerBuilder(host.Runner())
+
+			// Convert to Docker build config
+			dockerBuildConfig := geosBuildConfig.ToDockerBuildConfig(*sourceRepo, *sourceBranch, *imageTag)
+			dockerBuildConfig.SourceSubmodules = submoduleRefs
+			dockerBuildConfig.SourceTokenSecret = *tokenSecret
+			dockerBuildConfig.SourceDeployKeySecret = *deployKeySecret
+			dockerBuildConfig.CcacheURI = *ccacheS3
+			dockerBuildConfig.PullThrough = cacheConfig.PullThrough
+			dockerBuildConfig.RepositoryStrategy = *ecrStrategy
+			dockerBuildConfig.Push = docker.PushOptions(pushConfig)
+			builder.UseLocalSource(awsBuildConfig.Source, dockerBuildConfig)
+			builder.UsePatches(awsBuildConfig.Source, dockerBuildConfig)
+			builder.UseLocalDockerfile(dockerfile, dockerBuildConfig)
+
+			if *depsOnly {
+				// The dependencies image takes the model's place in the steps below
+				dockerBuildConfig = geosBuildConfig.ToDependenciesBuildConfig(*sourceRepo, *sourceBranch)
+				dockerBuildConfig.SourceSubmodules = submoduleRefs
+				dockerBuildConfig.SourceTokenSecret = *tokenSecret
+				dockerBuildConfig.SourceDeployKeySecret = *deployKeySecret
+				dockerBuildConfig.CcacheURI = *ccacheS3
+				dockerBuildConfig.PullThrough = cacheConfig.PullThrough
+				dockerBuildConfig.RepositoryStrategy = *ecrStrategy
+				dockerBuildConfig.Push = docker.PushOptions(pushConfig)
+				builder.UseLocalSource(awsBuildConfig.Source, dockerBuildConfig)
+				builder.UsePatches(awsBuildConfig.Source, dockerBuildConfig)
+			} else {
+				job := builder.BuildJob{
+					Name:            geosBuildConfig.Name,
+					Architecture:    geosBuildConfig.Architecture,
+					Docker:          dockerBuildConfig,
+					GeosChemVersion: geosBuildConfig.GeosChemVersion(),
+				}
+				if !*skipPush {
+					job.ECRRepository = *ecrRepository
+				}
+				deps := common.DependenciesImageConfig{Enabled: *withDeps, Image: *depsImage, Rebuild: *rebuildDeps}
+				if err := builder.PlanDependencies(ctx, cfg, deps, geosBuildConfig, &job); err != nil {
+					interrupts.Fatalf("Failed to plan dependencies image: %v", err)
+				}
+				builder.UseLocalSource(awsBuildConfig.Source, job.Dependencies)
+				if err := builder.BuildDependencies(ctx, dockerBuilder, job); err != nil {
+					interrupts.Fatalf("Dependencies image failed: %v", err)
+				}
+			}
+
+			// Execute Docker build
+			err = dockerBuilder.BuildContainer(ctx, dockerBuildConfig)
+			if err != nil {
+				interrupts.Fatalf("Docker build failed: %v", err)
+			}
+
+			// Show image information
+			imageInfo, err := dockerBuilder.GetImageInfo(ctx, dockerBuildConfig)
+			if err != nil {
+				log.Printf("Warning: Could not get image info: %v", err)
+			} else {
+				fmt.Printf("\n📊 Built Images:\n%s\n", imageInfo)
+			}
+
+			// Step 5: Push to ECR if requested
+			if *ecrRepository != "" && !*skipPush {
+				fmt.Println("\n=== Step 5: Push to ECR ===")
+				err = dockerBuilder.PushToECR(ctx, dockerBuildConfig, *ecrRepository)
+				if err != nil {
+					interrupts.Fatalf("ECR push failed: %v", err)
+				}
+			}
+
+			// Step 6: Cleanup images to save space
+			fmt.Println("\n=== Step 6: Cleanup Build Artifacts ===")
+			err = dockerBuilder.CleanupImages(ctx, dockerBuildConfig)
+			if err != nil {
+				log.Printf("Warning: Cleanup failed: %v", err)
+			}
+			if err := dockerBuilder.CleanupArtifacts(ctx, dockerBuildConfig); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+
+			// Step 7: Build the matching analysis image on the same instance
+			if *withAnalysis {
+				fmt.Println("\n=== Step 7: Build GCPy Analysis Image ===")
+				analysisConfig, err := geoschem.AnalysisConfigForArch(geosBuildConfig.Architecture)
+				if err != nil {
+					interrupts.Fatalf("Invalid analysis configuration: %v", err)
+				}
+
+				analysisBuildConfig := analysisConfig.ToDockerBuildConfig(*sourceRepo, *sourceBranch, *imageTag)
+				analysisBuildConfig.SourceSubmodules = submoduleRefs
+				analysisBuildConfig.SourceTokenSecret = *tokenSecret
+				analysisBuildConfig.SourceDeployKeySecret = *deployKeySecret
+				analysisBuildConfig.RepositoryStrategy = *ecrStrategy
+				analysisBuildConfig.Push = docker.PushOptions(pushConfig)
+				builder.UseLocalSource(awsBuildConfig.Source, analysisBuildConfig)
+				builder.UsePatches(awsBuildConfig.Source, analysisBuildConfig)
+				if err := dockerBuilder.BuildContainer(ctx, analysisBuildConfig); err != nil {
+					interrupts.Fatalf("Analysis image build failed: %v", err)
+				}
+
+				if *ecrRepository != "" && !*skipPush {
+					if err := dockerBuilder.PushToECR(ctx, analysisBuildConfig, *ecrRepository); err != nil {
+						interrupts.Fatalf("Analysis image ECR push failed: %v", err)
+					}
+				}
+
+				if err := dockerBuilder.CleanupImages(ctx, analysisBuildConfig); err != nil {
+					log.Printf("Warning: Analysis image cleanup failed: %v", err)
+				}
+				if err := dockerBuilder.CleanupArtifacts(ctx, analysisBuildConfig); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+			}
+		}
+
+		fmt.Println("\n🎉 GeosChem build completed successfully!")
+
+		if *skipCleanup {
+			fmt.Println("⚠️  Instance kept running as requested.")
+			if sshBuilder == nil {
+				fmt.Printf("💡 To connect: aws ssm start-session --target %s\n", instanceID)
+			} else if *instanceConnect {
+				fmt.Printf("💡 To connect: aws ec2-instance-connect ssh --instance-id %s --os-user %s\n", instanceID, resolvedHostOS.SSHUser)
+			} else if fetch := ssh.FetchKeyCommand(sshBuilder.KeyPath()); fetch != "" {
+				fmt.Printf("💡 To connect: %s\n   ssh -i key.pem %s@<instance-ip>\n", fetch, resolvedHostOS.SSHUser)
+			} else {
+				fmt.Printf("💡 To connect: ssh -i %s %s@<instance-ip>\n", sshBuilder.KeyPath(), resolvedHostOS.SSHUser)
+			}
+			fmt.Println("🗑️  Don't forget to terminate the instance manually!")
+		} else if err := cleanup(); err != nil {
+			log.Printf("Error cleaning up instance: %v", err)
+		}
+	}
+	return cmd
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/profiling"
+)
+
+// defaultRegion is used when neither --region nor a config file names one
+const defaultRegion = "us-west-2"
+
+// globals are the flags every command shares. They can be given before the command or
+// among its own flags.
+type globals struct {
+	profile   string
+	region    string
+	config    string
+	profiling profiling.Options
+	stop      func() // Stops profiling; nil until a command starts it
+}
+
+// register adds the shared flags to the root command, for every command to inherit
+func (g *globals) register(fs *pflag.FlagSet) {
+	fs.StringVar(&g.profile, "profile", "aws", "AWS profile to use")
+	fs.StringVar(&g.region, "region", "", "AWS region (overrides the config file)")
+	fs.StringVar(&g.config, "config", "config/build-matrix.yaml", "Config file path")
+	fs.StringVar(&g.profiling.Addr, "debug-addr", "", "Serve pprof endpoints at this address (such as localhost:6060) while the command runs")
+	fs.StringVar(&g.profiling.TraceFile, "trace", "", "Write a runtime execution trace of the command to this file")
+}
+
+// regionOr returns the --region flag, or the fallback when it isn't set
+func (g *globals) regionOr(fallback string) string {
+	if g.region != "" {
+		return g.region
+	}
+	return fallback
+}
+
+// loadConfig loads the config file with the profile and region flags applied, or exits
+func (g *globals) loadConfig() *common.BuildConfig {
+	config, err := common.LoadBuildConfig(g.config)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if g.profile != "" {
+		config.AWS.Profile = g.profile
+	}
+	config.AWS.Region = g.regionOr(config.AWS.Region)
+	return config
+}
+
+// startProfiling starts the profiling the shared flags ask for, once a command's flags are
+// parsed
+func (g *globals) startProfiling() error {
+	stop, err := profiling.Start(g.profiling)
+	if err != nil {
+		return fmt.Errorf("starting profiling: %w", err)
+	}
+	g.stop = stop
+	return nil
+}
+
+// stopProfiling finishes what startProfiling started
+func (g *globals) stopProfiling() {
+	if g.stop != nil {
+		g.stop()
+	}
+}
+
+// newRootCommand returns geoschem-aws with its commands
+func newRootCommand(g *globals) *cobra.Command {
+	root := &cobra.Command{
+		Use:   "geoschem-aws",
+		Short: "Build GeosChem images and run simulations on AWS",
+		Long: "Build GeosChem images and run simulations on AWS.\n\n" +
+			"Profile a command with --debug-addr localhost:6060 (pprof) or --trace file.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return g.startProfiling()
+		},
+	}
+	g.register(root.PersistentFlags())
+
+	root.AddCommand(
+		newBootstrapCommand(g),
+		newTeardownCommand(g),
+		newBuildCommand(g),
+		newImageCommand(g),
+		newManifestCommand(g),
+		newQuotaCommand(g),
+		newRecommendCommand(g),
+		newRunCommand(g),
+		newWarmPoolCommand(g),
+		newSSHTestCommand(g),
+		newCleanupCommand(g),
+		&cobra.Command{
+			Use:   "version",
+			Short: "Show version information",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				fmt.Println(common.GetVersionInfo())
+			},
+		},
+	)
+	return root
+}
+
+func main() {
+	g := &globals{}
+	err := newRootCommand(g).Execute()
+	g.stopProfiling()
+	if err != nil {
+		os.Exit(1)
+	}
+}
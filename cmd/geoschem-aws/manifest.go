@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+)
+
+// newManifestCommand returns the manifest command, which combines already-pushed
+// per-architecture images into one multi-arch tag with the local podman
+func newManifestCommand(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "manifest [<target> <image> [<image>...]]",
+		Short: "Combine pushed x86_64 and arm64 images into one multi-arch tag",
+		Long: "Pushes a manifest list combining images already in ECR, using the local podman: the\n" +
+			"matrix images of --compiler and --mpi, or the images given after the target.",
+	}
+	fs := cmd.Flags()
+	var (
+		compiler = fs.String("compiler", "", "Compiler of the matrix images to combine, e.g. gcc13")
+		mpi      = fs.String("mpi", "openmpi", "MPI of the matrix images to combine")
+	)
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		var target string
+		var images []string
+		switch {
+		case len(args) >= 2:
+			target, images = args[0], args[1:]
+		case len(args) == 0 && *compiler != "":
+			config := g.loadConfig()
+			var err error
+			target, images, err = builder.MatrixManifest(config, *compiler, *mpi)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+		default:
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		fmt.Printf("📋 Combining into %s:\n", target)
+		for _, image := range images {
+			fmt.Printf("   - %s\n", image)
+		}
+		if err := docker.NewDockerBuilder(docker.LocalRunner{}).PushManifest(context.Background(), target, images); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+	return cmd
+}
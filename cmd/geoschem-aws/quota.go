@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// newQuotaCommand returns the quota command, which checks the quotas builds depend on, or
+// requests an increase of one
+func newQuotaCommand(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "quota",
+		Short: "Check AWS quotas, or request an increase",
+		Args:  cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	var (
+		requestIncrease = fs.Bool("request-increase", false, "Request a quota increase (with --service, --quota-code, --desired-value)")
+		service         = fs.String("service", "", "Service Quotas service code for --request-increase (e.g. ec2)")
+		quotaCode       = fs.String("quota-code", "", "Quota code for --request-increase (e.g. L-1216C47A)")
+		desiredValue    = fs.Float64("desired-value", 0, "New quota value for --request-increase")
+	)
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		config := g.loadConfig()
+		ctx := context.Background()
+		b := newBuilder(ctx, config.AWS.Profile, config.AWS.Region)
+
+		if *requestIncrease {
+			if *service == "" || *quotaCode == "" || *desiredValue <= 0 {
+				log.Fatalf("--request-increase needs --service, --quota-code, and --desired-value")
+			}
+			if err := b.RequestQuotaIncrease(ctx, *service, *quotaCode, *desiredValue); err != nil {
+				log.Fatalf("Quota increase request failed: %v", err)
+			}
+			return
+		}
+
+		if err := b.CheckQuotas(ctx); err != nil {
+			log.Fatalf("Quota check failed: %v", err)
+		}
+	}
+	return cmd
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/geoschem"
+	"github.com/scttfrdmn/geoschem-aws/internal/runflow"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// newRecommendCommand returns the recommend command, which recommends instance types for a
+// workload, ranked by predicted cost per model year, with what past runs of the workload
+// measured
+func newRecommendCommand(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "recommend",
+		Short: "Recommend instance types for a workload",
+		Args:  cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	var (
+		gridRes      = fs.String("grid-resolution", "4x5", "Grid resolution (4x5, 2x2.5, 0.5x0.625, or C48-C360 for GCHP)")
+		speciesCount = fs.Int("species-count", 100, "Number of chemical species")
+		budget       = fs.Float64("budget-per-hour", 0, "Maximum cost per hour (0 = no limit)")
+		priority     = fs.String("priority", "balanced", "Optimization priority (cost, performance, balanced)")
+		disableSMT   = fs.Bool("disable-smt", false, "Plan for one thread per physical core (hyperthreading disabled)")
+		ompThreads   = fs.Int("omp-threads", 0, "OpenMP threads per process to validate against recommendations (0 = skip)")
+		mpiProcs     = fs.Int("mpi-procs", 1, "MPI processes per instance to validate against recommendations")
+		mode         = fs.String("mode", "classic", "Model mode for recommendations (classic, gchp)")
+		cores        = fs.Int("cores", 0, "Total GCHP cores to plan for (0 = minimum for the resolution)")
+		maxNodes     = fs.Int("max-nodes", 1, "Maximum instances a GCHP run may span")
+		nestedDomain = fs.String("nested-domain", "", "Classic nested-grid domain (AS, EU, NA)")
+		simulation   = fs.String("simulation", "fullchem", "Simulation type used to predict cost per model year")
+		outputGB     = fs.Float64("output-gb", 50, "Expected run output size in GB, for storage recommendations")
+		static       = fs.Bool("static-pricing", false, "Use the built-in price list instead of the Pricing API and the region's instance types")
+	)
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		region := g.regionOr(defaultRegion)
+		cfg, err := common.LoadSDKConfig(ctx, g.profile, region)
+		if err != nil {
+			log.Fatalf("Failed to load AWS config: %v", err)
+		}
+
+		selector := common.NewInstanceSelector(cfg, g.profile, region)
+		selector.StaticPricing = *static
+		workload := common.WorkloadProfile{
+			GridResolution: *gridRes,
+			SpeciesCount:   *speciesCount,
+			BudgetPerHour:  *budget,
+			Priority:       *priority,
+			Architecture:   "any", // Allow both x86_64 and ARM64
+			DisableSMT:     *disableSMT,
+			Mode:           *mode,
+			Cores:          *cores,
+			MaxNodes:       *maxNodes,
+		}
+		if *nestedDomain != "" {
+			domain, err := common.GetNestedDomain(*nestedDomain)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			workload.Nested = domain
+		}
+		if err := workload.Validate(); err != nil {
+			log.Fatalf("Invalid workload: %v", err)
+		}
+
+		recommendations, err := selector.GetRecommendations(ctx, workload)
+		if err != nil {
+			log.Fatalf("Failed to get recommendations: %v", err)
+		}
+
+		// Local benchmark data ranks the recommendations by predicted cost per model year, and
+		// instances with no more memory than this workload has run out of are left out, as runs
+		// leave them out
+		var records []common.PerformanceRecord
+		var sizing []common.SizingRecord
+		var learned *state.WorkloadRecord
+		if store, err := state.OpenDefault(); err == nil {
+			records, _ = state.NewPerformanceLog(store).Records()
+			sizing, _ = state.NewSizingLog(store).Lookup(*simulation, *gridRes, *nestedDomain)
+			learned, _ = state.NewWorkloadProfiles(store).Lookup(*simulation, *gridRes, *nestedDomain)
+		}
+		recommendations = runflow.ExcludeOutOfMemory(recommendations, learned)
+		if len(recommendations) == 0 && learned != nil {
+			log.Fatalf("No recommended instance has more than %.0f GB; raise --budget-per-hour or --max-nodes",
+				learned.InsufficientMemoryGB)
+		}
+
+		fmt.Println(common.FormatRecommendations(recommendations, workload))
+
+		request := common.PredictionRequest{
+			Simulation: *simulation,
+			Workload:   workload,
+			ModelDays:  365,
+		}
+		fmt.Println(common.FormatPredictions(common.NewPredictor(records).Rank(request, recommendations), request))
+		// Past runs' measured usage shows where the profile-based sizing was off
+		if suggestions := common.FormatSizingSuggestions(sizing, recommendations); suggestions != "" {
+			fmt.Println(suggestions)
+		}
+		fmt.Println(common.FormatStorageTradeoffs(workload, *outputGB))
+
+		// Warn when the planned thread layout doesn't fit the recommended instances
+		if *ompThreads > 0 {
+			for _, rec := range recommendations {
+				for _, warning := range geoschem.CheckThreadTopology(*ompThreads, *mpiProcs, workload.PhysicalCores(rec)) {
+					fmt.Printf("%s: %s\n", rec.InstanceType, warning)
+				}
+			}
+		}
+	}
+	return cmd
+}
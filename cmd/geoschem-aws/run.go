@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/benchmark"
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+	"github.com/scttfrdmn/geoschem-aws/internal/catalog"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/hemco"
+	"github.com/scttfrdmn/geoschem-aws/internal/runflow"
+	"github.com/scttfrdmn/geoschem-aws/internal/runner"
+	"github.com/scttfrdmn/geoschem-aws/internal/shutdown"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// newRunCommand returns the run command, which submits a GeosChem Classic simulation to the
+// config's AWS Batch queue. The job definition is generated from the workload profile, so
+// Batch picks any instance that fits. Its diff subcommand compares two recorded runs
+// instead.
+func newRunCommand(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Submit a simulation to AWS Batch with resources sized from its workload",
+		Args:  cobra.NoArgs,
+	}
+	cmd.AddCommand(newRunDiffCommand(g))
+	fs := cmd.Flags()
+	var (
+		image         = fs.String("image", "", "Container image to run (default: the matrix image for --arch, --compiler, --mpi in ECR)")
+		arch          = fs.String("arch", "x86_64", "Architecture of the matrix image to run")
+		compiler      = fs.String("compiler", "gcc13", "Compiler of the matrix image to run")
+		mpi           = fs.String("mpi", "openmpi", "MPI of the matrix image to run")
+		simulation    = fs.String("simulation", "fullchem", "Simulation type")
+		resolution    = fs.String("resolution", "4x5", "Grid resolution")
+		nestedDomain  = fs.String("nested-domain", "", "Nested-grid domain (AS, EU, NA)")
+		speciesCount  = fs.Int("species-count", 100, "Number of chemical species, for sizing the job's memory")
+		duration      = fs.Int("duration", 0, "Job time limit in hours (0 = twice the predicted wall clock, at least 2)")
+		startDate     = fs.String("start-date", "2019-07-01", "Simulation start date (YYYY-MM-DD)")
+		endDate       = fs.String("end-date", "2019-08-01", "Simulation end date (YYYY-MM-DD)")
+		dataSource    = fs.String("data-source", "", "S3 URI of input data the container stages in")
+		output        = fs.String("output", "", "S3 URI to copy the run output to (required)")
+		metField      = fs.String("met", benchmark.DefaultMetField, "Met field the run reads: MERRA2, GEOSFP, GEOSIT")
+		emissions     = fs.String("emissions", "", "YAML file of emissions overrides (scale factors, inventories, masks) merged into HEMCO_Config.rc")
+		checkpoint    = fs.String("checkpoint", benchmark.CheckpointMonthly, "How often to write restart files: daily, monthly, or empty for the run directory's setting")
+		jobQueue      = fs.String("job-queue", "", "Batch job queue (overrides runs.batch.job_queue)")
+		catalogTable  = fs.String("catalog", "", "Results catalog table: skip runs it already holds and record the output (see 'results create')")
+		rerun         = fs.Bool("rerun", false, "Run even if the results catalog already holds a matching output")
+		skipPreflight = fs.Bool("skip-preflight", false, "Skip the input checks before the simulation starts")
+		retryOnOOM    = fs.Bool("retry-on-oom", false, "Rerun with the memory of the next larger instance when the simulation runs out of memory")
+		dryRun        = fs.Bool("dry-run", false, "Show the generated job resources and predicted cost without submitting")
+	)
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		config := g.loadConfig()
+		if *jobQueue != "" {
+			config.Runs.Batch.JobQueue = *jobQueue
+		}
+		config.Runs.Scheduler = common.SchedulerBatch
+		if err := config.Runs.Validate(); err != nil && !*dryRun {
+			log.Fatalf("%v", err)
+		}
+		if *output == "" && !*dryRun {
+			log.Fatal("--output is required so the output outlives the container")
+		}
+		if *duration < 0 {
+			log.Fatalf("Invalid duration: %d", *duration)
+		}
+
+		workload := common.WorkloadProfile{
+			GridResolution: *resolution,
+			SpeciesCount:   *speciesCount,
+			Duration:       *duration,
+			Architecture:   *arch,
+		}
+		if *nestedDomain != "" {
+			domain, err := common.GetNestedDomain(*nestedDomain)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			workload.Nested = domain
+		}
+		if workload.IsGCHP() {
+			log.Fatal("Batch runs GeosChem Classic; GCHP resolutions are not supported yet")
+		}
+		if err := workload.Validate(); err != nil {
+			log.Fatalf("Invalid workload: %v", err)
+		}
+
+		if *image == "" {
+			matrixImage, err := builder.MatrixImage(config, *arch, *compiler, *mpi)
+			if err != nil {
+				log.Fatalf("Cannot resolve image: %v", err)
+			}
+			*image = matrixImage
+		}
+
+		runConfig := benchmark.Config{
+			Simulation:    *simulation,
+			Resolution:    *resolution,
+			StartDate:     *startDate,
+			EndDate:       *endDate,
+			DataSource:    *dataSource,
+			Diagnostics:   benchmark.DefaultDiagnostics,
+			OutputURI:     *output,
+			MetField:      *metField,
+			SkipPreflight: *skipPreflight,
+			Checkpoint:    *checkpoint,
+		}
+		if *emissions != "" {
+			overrides, err := hemco.LoadOverrides(*emissions)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			runConfig.Emissions = overrides
+		}
+		modelDays, err := runConfig.ModelDays()
+		if err != nil {
+			log.Fatalf("Invalid run period: %v", err)
+		}
+
+		// Catch inputs that cannot work before paying for anything
+		if !*skipPreflight {
+			preflight := runConfig.Preflight()
+			fmt.Print(preflight.Report())
+			if err := preflight.Err(); err != nil {
+				log.Fatalf("Run would fail: %v", err)
+			}
+		}
+
+		store, err := state.OpenDefault()
+		if err != nil {
+			log.Fatalf("Failed to open state store: %v", err)
+		}
+		predictor, err := state.NewPerformanceLog(store).LoadPredictor("")
+		if err != nil {
+			log.Fatalf("Failed to load performance data: %v", err)
+		}
+		// Don't size the job for memory this workload has already run out of
+		learned, err := state.NewWorkloadProfiles(store).Lookup(*simulation, *resolution, *nestedDomain)
+		if err != nil {
+			log.Fatalf("Failed to load workload profile: %v", err)
+		}
+
+		// Predict on the cheapest instance the job fits; Batch may place it on a larger one
+		resources := runner.WorkloadResources(workload)
+		candidates := slices.DeleteFunc(common.InstanceCatalog(), func(instance common.InstanceRecommendation) bool {
+			return instance.Architecture != *arch || instance.VCPUs < resources.VCPUs ||
+				int(instance.Memory*1024)*9/10 < resources.MemoryMiB
+		})
+		candidates = runflow.ExcludeOutOfMemory(candidates, learned)
+		request := common.PredictionRequest{
+			Simulation: *simulation,
+			Workload:   workload,
+			ModelDays:  modelDays,
+		}
+		predictions := predictor.Rank(request, candidates)
+		if len(predictions) == 0 {
+			log.Fatalf("No %s instance type can run %s %s", *arch, *simulation, workload.Description())
+		}
+		selected := predictions[0]
+		runConfig.InstanceType = selected.InstanceType
+
+		if workload.Duration == 0 {
+			workload.Duration = int(math.Ceil(max(2*selected.WallClock, 2*time.Hour).Hours()))
+			resources = runner.WorkloadResources(workload)
+		}
+		if instance, err := common.LookupInstance(selected.InstanceType); learned != nil && err == nil {
+			// Reserve the memory of an instance larger than the one the workload ran out of
+			resources.MemoryMiB = max(resources.MemoryMiB, int(instance.Memory*1024)*9/10)
+		}
+		if err := runConfig.Validate(); err != nil {
+			log.Fatalf("Invalid run configuration: %v", err)
+		}
+
+		fmt.Printf("📦 Image: %s\n", *image)
+		fmt.Printf("🧮 Job resources for %s %s: %d vCPUs, %d MiB, %d h limit\n",
+			*simulation, workload.Description(), resources.VCPUs, resources.MemoryMiB, workload.Duration)
+		fmt.Printf("⏱️  Estimated wall clock on %s: %s\n", selected.InstanceType, common.FormatWallClock(selected.WallClock))
+		fmt.Printf("💰 Estimated cost: $%.2f ($%.2f per model year)\n", selected.Cost, selected.CostPerModelYear)
+		if selected.Samples == 0 {
+			fmt.Printf("⚠️  No benchmark data for this configuration; run 'benchmark compare' to improve the estimate\n")
+		}
+
+		if *dryRun {
+			fmt.Println("\nDry run: nothing was submitted")
+			return
+		}
+		if len(runConfig.Emissions) > 0 && runConfig.DataSource != "" {
+			cli := awscli.New(config.AWS.Profile, config.AWS.Region)
+			if err := hemco.CheckStaged(context.Background(), cli, runConfig.DataSource, runConfig.Emissions); err != nil {
+				log.Fatalf("Invalid emissions overrides: %v", err)
+			}
+		}
+
+		var results *catalog.Catalog
+		if *catalogTable != "" {
+			results = catalog.New(config.AWS.Profile, config.AWS.Region, *catalogTable)
+			if !*rerun {
+				cataloged, err := runflow.Cataloged(context.Background(), results, runConfig, *image)
+				if err != nil {
+					log.Fatalf("%v", err)
+				}
+				if cataloged {
+					return
+				}
+			}
+		}
+
+		// Interrupts cancel the wait, which terminates the Batch job before the command exits
+		ctx, interrupts := shutdown.Trap(context.Background())
+		defer interrupts.Stop()
+
+		scheduler := runner.NewBatchScheduler(config)
+		fmt.Printf("\n🚀 Submitting %s %s to Batch queue %s\n", *simulation, workload.Description(), config.Runs.Batch.JobQueue)
+		run := &runflow.Run{
+			Job: runner.Job{
+				Name:      fmt.Sprintf("geoschem-%s-%d", *simulation, time.Now().Unix()),
+				Image:     *image,
+				Config:    runConfig,
+				TimeLimit: resources.TimeLimit,
+				Resources: &resources,
+			},
+			NestedDomain: *nestedDomain,
+			RetryOnOOM:   *retryOnOOM,
+			ConfigFiles:  map[string]string{"config": g.config, "emissions": *emissions},
+			Profile:      config.AWS.Profile,
+			Region:       config.AWS.Region,
+		}
+		result := runflow.Execute(ctx, store, scheduler, run)
+		if result.Err != nil {
+			interrupts.Fatalf("Run failed: %v", result.Err)
+		}
+
+		fmt.Printf("\n✅ Run complete in %s\n", common.FormatWallClock(result.WallClock))
+		fmt.Printf("   Throughput: %.1f model days per day, cost about $%.2f\n", result.Throughput, result.Cost)
+		fmt.Printf("   Output: %s\n", *output)
+
+		// Batch picks the instance; the recorded type is the one the job was sized and priced for
+		runflow.Record(context.Background(), store, run, scheduler.Name(), result, results)
+	}
+	return cmd
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/provenance"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// newRunDiffCommand returns the run diff command, which compares the recorded provenance of
+// two runs and reports every difference, exiting non-zero when there are any. Without runs
+// it lists the recorded ones.
+func newRunDiffCommand(g *globals) *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff [<run A> <run B>]",
+		Short: "Compare the recorded provenance of two runs",
+		Long: "Compare the recorded provenance of two runs.\n\n" +
+			"Runs are given by provenance ID or output location; outputs recorded elsewhere are read from\n" +
+			"their " + provenance.SidecarName + ". Without runs, lists the recorded runs.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 && len(args) != 2 {
+				return fmt.Errorf("takes two runs, or none to list them; got %d", len(args))
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			store, err := state.OpenDefault()
+			if err != nil {
+				log.Fatalf("Failed to open state store: %v", err)
+			}
+
+			if len(args) == 0 {
+				records, err := provenance.List(store)
+				if err != nil {
+					log.Fatalf("Failed to list runs: %v", err)
+				}
+				if len(records) == 0 {
+					fmt.Println("No recorded runs")
+					return
+				}
+				fmt.Printf("%-12s %-17s %-16s %-26s %s\n", "ID", "RECORDED", "INSTANCE", "RUN", "OUTPUT")
+				for _, record := range records {
+					run := fmt.Sprintf("%s %s %s", record.Settings["simulation"], record.Settings["resolution"], record.Settings["start_date"])
+					fmt.Printf("%-12s %-17s %-16s %-26s %s\n", record.ID, record.RecordedAt.Local().Format("2006-01-02 15:04"),
+						record.InstanceType, run, record.OutputURI)
+				}
+				return
+			}
+
+			cli := awscli.New(g.profile, g.regionOr(defaultRegion))
+			a, err := findRun(store, cli, args[0])
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			b, err := findRun(store, cli, args[1])
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			differences := provenance.Diff(a, b)
+			fmt.Print(provenance.FormatDiff(a, b, differences))
+			if len(differences) > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+// findRun looks a run up in the local state store, falling back to the provenance sidecar
+// for outputs that someone else ran
+func findRun(store *state.Store, cli *awscli.Client, ref string) (*provenance.Record, error) {
+	record, err := provenance.Find(store, ref)
+	if err == nil || !strings.HasPrefix(ref, "s3://") {
+		return record, err
+	}
+	return provenance.ReadSidecar(context.Background(), cli, ref)
+}
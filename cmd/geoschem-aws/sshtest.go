@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/shutdown"
+	"github.com/scttfrdmn/geoschem-aws/internal/ssh"
+)
+
+// newSSHTestCommand returns the ssh-test command, which launches a small instance and
+// checks SSH, podman and the AWS CLI on it
+func newSSHTestCommand(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ssh-test",
+		Short: "Launch an instance and check SSH, podman and the AWS CLI on it",
+		Args:  cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	var (
+		arch            = fs.String("arch", "x86_64", "Architecture (x86_64 or arm64)")
+		subnetID        = fs.String("subnet", "", "Subnet ID for instance (required)")
+		sgID            = fs.String("security-group", "", "Security Group ID (required)")
+		hostOS          = fs.String("host-os", "", "Instance OS: rocky8, rocky9, rocky10, alma9, al2023, ubuntu22, ubuntu24 (default: rocky9)")
+		skipCleanup     = fs.Bool("keep-instance", false, "Keep instance running after test")
+		instanceConnect = fs.Bool("instance-connect", false, "Push a one-time key with EC2 Instance Connect instead of importing a key pair")
+		keyStorage      = fs.String("key-storage", "", "Where the generated private key is kept: file, passphrase (GEOSCHEM_KEY_PASSPHRASE), ssm, secretsmanager (default: file)")
+		keyType         = fs.String("key-type", "", "Generated SSH key type: ed25519 or rsa4096 (default: ed25519)")
+	)
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		region := g.regionOr(defaultRegion)
+
+		if *subnetID == "" || *sgID == "" {
+			log.Fatal("Both --subnet and --security-group are required")
+		}
+
+		hostOSConfig := common.HostOSConfig{Name: *hostOS}
+		resolvedHostOS, err := hostOSConfig.Resolve()
+		if err != nil {
+			log.Fatalf("Invalid host OS: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+
+		// Interrupts cancel the test and terminate the instance before exiting
+		ctx, interrupts := shutdown.Trap(ctx)
+		defer interrupts.Stop()
+
+		// Load AWS config
+		cfg, err := common.LoadSDKConfig(ctx, g.profile, region)
+		if err != nil {
+			log.Fatalf("Failed to load AWS config: %v", err)
+		}
+
+		// Create SSH builder
+		sshBuilder := builder.NewSSHBuilder(cfg)
+
+		// Create build configuration
+		buildConfig := &common.BuildConfig{
+			AWS: common.AWSConfig{
+				Region:          region,
+				Profile:         g.profile,
+				SubnetID:        *subnetID,
+				SecurityGroup:   *sgID,
+				InstanceConnect: *instanceConnect,
+				KeyStorage:      *keyStorage,
+				KeyType:         *keyType,
+			},
+			Architectures: map[string]common.ArchConfig{
+				"x86_64": {
+					InstanceType: "t3.medium",
+				},
+				"arm64": {
+					InstanceType: "t4g.medium",
+				},
+			},
+			HostOS: hostOSConfig,
+		}
+
+		var instanceID string
+
+		// Terminates the instance once launched; the interrupt handler runs it too
+		terminate := func() error { return nil }
+		cleanup := func() {
+			if err := terminate(); err != nil {
+				log.Printf("Error cleaning up instance: %v", err)
+			}
+		}
+
+		fmt.Printf("🚀 Testing SSH connectivity for architecture: %s\n", *arch)
+		fmt.Printf("Using subnet: %s, security group: %s\n", *subnetID, *sgID)
+
+		// Step 1: Launch instance and establish SSH
+		fmt.Println("\n=== Step 1: Launch Instance and Establish SSH ===")
+		instanceID, err = sshBuilder.BuildWithSSH(ctx, buildConfig, *arch)
+		if instanceID != "" && !*skipCleanup {
+			terminate = shutdown.Register(ctx, "test instance "+instanceID, func(ctx context.Context) error {
+				fmt.Println("\nCleaning up instance...")
+				return sshBuilder.CleanupInstance(ctx, instanceID)
+			})
+		}
+		if err != nil {
+			log.Printf("Failed to build with SSH: %v", err)
+			cleanup()
+			os.Exit(1)
+		}
+
+		// Step 2: Basic system info
+		fmt.Println("\n=== Step 2: Basic System Information ===")
+		commands := []struct {
+			desc string
+			cmd  string
+		}{
+			{"Operating System", "cat /etc/os-release | head -2"},
+			{"Architecture", "uname -m"},
+			{"CPU Info", "lscpu | grep 'Model name'"},
+			{"Memory Info", "free -h"},
+			{"Disk Space", "df -h /"},
+			{"Network Info", "ip route get 1.1.1.1"},
+		}
+
+		for _, cmdInfo := range commands {
+			fmt.Printf("\n--- %s ---\n", cmdInfo.desc)
+			output, err := sshBuilder.ExecuteCommand(ctx, cmdInfo.cmd)
+			if err != nil {
+				log.Printf("Command failed: %v", err)
+				continue
+			}
+			fmt.Print(output)
+		}
+
+		// Step 3: Prepare instance (install Docker, etc.)
+		fmt.Println("\n=== Step 3: Prepare Build Environment ===")
+		err = sshBuilder.PrepareInstance(ctx, false)
+		if err != nil {
+			log.Printf("Failed to prepare instance: %v", err)
+			cleanup()
+			return
+		}
+
+		// Step 4: Test Docker
+		fmt.Println("\n=== Step 4: Test Docker Installation ===")
+		err = sshBuilder.TestDockerConnection(ctx)
+		if err != nil {
+			log.Printf("Docker test failed: %v", err)
+			cleanup()
+			return
+		}
+
+		// Step 5: Test AWS CLI
+		fmt.Println("\n=== Step 5: Test AWS CLI ===")
+		output, err := sshBuilder.ExecuteCommand(ctx, "aws --version")
+		if err != nil {
+			log.Printf("AWS CLI test failed: %v", err)
+		} else {
+			fmt.Printf("AWS CLI Version: %s\n", output)
+		}
+
+		fmt.Println("\n🎉 SSH connectivity test completed successfully!")
+
+		if *skipCleanup {
+			fmt.Println("⚠️  Instance kept running as requested. Don't forget to terminate it manually!")
+			// Show connection info
+			fmt.Printf("\nTo connect to the instance manually:\n")
+			if *instanceConnect {
+				fmt.Printf("aws ec2-instance-connect ssh --instance-id %s --os-user %s\n", instanceID, resolvedHostOS.SSHUser)
+			} else if fetch := ssh.FetchKeyCommand(sshBuilder.KeyPath()); fetch != "" {
+				fmt.Printf("%s\nssh -i key.pem %s@<instance-ip>\n", fetch, resolvedHostOS.SSHUser)
+			} else {
+				fmt.Printf("ssh -i %s %s@<instance-ip>\n", sshBuilder.KeyPath(), resolvedHostOS.SSHUser)
+			}
+		} else {
+			cleanup()
+		}
+	}
+	return cmd
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/runner"
+)
+
+// newWarmPoolCommand returns the warm-pool command, which schedules, shows, or removes the
+// Batch warm pool in runs.batch.warm_pool
+func newWarmPoolCommand(g *globals) *cobra.Command {
+	return &cobra.Command{
+		Use:   "warm-pool <apply|status|remove>",
+		Short: "Keep Batch run capacity warm during working hours",
+		Long: "Keep Batch run capacity warm during working hours.\n\n" +
+			"  apply   Schedule the minimum vCPUs for working hours and set the current minimum\n" +
+			"  status  Show the compute environment's capacity and the schedules\n" +
+			"  remove  Delete the schedules and drop the minimum to zero",
+		ValidArgs: []string{"apply", "status", "remove"},
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Run: func(cmd *cobra.Command, args []string) {
+			config := g.loadConfig()
+			warmPool, err := runner.NewWarmPool(config)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			settings := warmPool.Config()
+			ctx := context.Background()
+
+			switch args[0] {
+			case "apply":
+				if err := warmPool.Apply(ctx); err != nil {
+					log.Fatalf("Failed to apply warm pool: %v", err)
+				}
+				fmt.Printf("✅ %s keeps %d vCPUs warm %s %s-%s (%s)\n", settings.ComputeEnvironment, settings.MinVCPUs,
+					settings.Days, settings.Start, settings.End, settings.Timezone)
+				if settings.Active(time.Now()) {
+					fmt.Printf("🔥 Inside working hours: minimum raised to %d vCPUs now\n", settings.MinVCPUs)
+				} else {
+					fmt.Println("💤 Outside working hours: minimum set to 0 until the next start")
+				}
+			case "status":
+				status, err := warmPool.Status(ctx)
+				if err != nil {
+					log.Fatalf("%v", err)
+				}
+				fmt.Printf("Compute environment: %s (%s)\n", status.ComputeEnvironment, status.State)
+				fmt.Printf("vCPUs:               %d min, %d desired, %d max\n", status.MinVCPUs, status.DesiredVCPUs, status.MaxVCPUs)
+				fmt.Printf("Working hours:       %s %s-%s (%s), %d vCPUs\n", settings.Days, settings.Start, settings.End,
+					settings.Timezone, settings.MinVCPUs)
+				start, stop := warmPool.ScheduleNames()
+				for _, name := range []string{start, stop} {
+					state, ok := status.Schedules[name]
+					if !ok {
+						state = "not created (run 'warm-pool apply')"
+					}
+					fmt.Printf("Schedule:            %s %s\n", name, state)
+				}
+				if settings.Active(time.Now()) && status.MinVCPUs < settings.MinVCPUs {
+					fmt.Printf("⚠️  Inside working hours but the minimum is %d; run 'warm-pool apply'\n", status.MinVCPUs)
+				}
+			case "remove":
+				if err := warmPool.Remove(ctx); err != nil {
+					log.Fatalf("Failed to remove warm pool: %v", err)
+				}
+				fmt.Printf("🗑️  Removed the warm pool schedules; %s scales from zero again\n", settings.ComputeEnvironment)
+			}
+		},
+	}
+}
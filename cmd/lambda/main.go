@@ -0,0 +1,56 @@
+// Command lambda is the entry point of the event automation functions. The function's image
+// command names the handler to run; see docker/lambda and terraform/modules/automation.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/automation"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatalf("Usage: lambda <handler>\nHandlers: %s", strings.Join(handlerNames(), ", "))
+	}
+	settings, err := automation.SettingsFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid function configuration: %v", err)
+	}
+	handlers, err := automation.New(context.Background(), settings)
+	if err != nil {
+		log.Fatalf("Failed to initialize: %v", err)
+	}
+
+	start, ok := handlerStarters(handlers)[os.Args[1]]
+	if !ok {
+		log.Fatalf("Unknown handler %q; handlers: %s", os.Args[1], strings.Join(handlerNames(), ", "))
+	}
+	fmt.Printf("Starting the %s handler\n", os.Args[1])
+	start()
+}
+
+// handlerStarters maps the names an image command can give to the handlers they start
+func handlerStarters(h *automation.Handlers) map[string]func() {
+	return map[string]func(){
+		"janitor":           func() { lambda.Start(h.Janitor) },
+		"spot-interruption": func() { lambda.Start(h.SpotInterruption) },
+		"build-completed":   func() { lambda.Start(h.BuildCompleted) },
+		"output-written":    func() { lambda.Start(h.OutputWritten) },
+	}
+}
+
+func handlerNames() []string {
+	var names []string
+	for name := range handlerStarters(nil) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
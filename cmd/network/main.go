@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/network"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: network <command> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  create-sg   Create a least-privilege security group for build and run instances\n")
+	fmt.Fprintf(os.Stderr, "  audit       Flag over-permissive rules in the security groups a config uses\n")
+	fmt.Fprintf(os.Stderr, "  efa-rules   Allow the all-traffic rules within a security group that EFA needs\n\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "create-sg":
+		runCreate(os.Args[2:])
+	case "audit":
+		runAudit(os.Args[2:])
+	case "efa-rules":
+		runEFARules(os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+func runCreate(args []string) {
+	fs := flag.NewFlagSet("create-sg", flag.ExitOnError)
+	var (
+		profile = fs.String("profile", "aws", "AWS profile to use")
+		region  = fs.String("region", "us-west-2", "AWS region")
+		vpcID   = fs.String("vpc", "", "VPC for the security group (required)")
+		name    = fs.String("name", "geoschem-instances", "Security group name")
+		sshCIDR = fs.String("ssh-cidr", "", "Source allowed to reach SSH (default: your current public IP)")
+		ssmOnly = fs.Bool("ssm-only", false, "Allow no SSH; for the ssm execution backend")
+	)
+	fs.Parse(args)
+
+	if *vpcID == "" {
+		log.Fatal("-vpc is required")
+	}
+	if *ssmOnly && *sshCIDR != "" {
+		log.Fatal("-ssm-only and -ssh-cidr are mutually exclusive")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	spec := network.GroupSpec{Name: *name, VPCID: *vpcID, SSHCIDR: *sshCIDR}
+	if !*ssmOnly && spec.SSHCIDR == "" {
+		cidr, err := network.CallerCIDR(ctx)
+		if err != nil {
+			log.Fatalf("%v; pass -ssh-cidr or -ssm-only", err)
+		}
+		spec.SSHCIDR = cidr
+	}
+
+	cfg, err := common.LoadSDKConfig(ctx, *profile, *region)
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+
+	groupID, err := network.NewManager(cfg).CreateSecurityGroup(ctx, spec)
+	if err != nil {
+		if groupID != "" {
+			log.Fatalf("Security group %s is incomplete: %v", groupID, err)
+		}
+		log.Fatalf("Failed to create security group: %v", err)
+	}
+
+	fmt.Printf("✅ Created %s (%s)\n", groupID, *name)
+	if spec.SSHCIDR != "" {
+		fmt.Printf("   Ingress: SSH from %s, all traffic within the group\n", spec.SSHCIDR)
+		fmt.Println("   Your IP changes? Create a new group or edit the SSH rule.")
+	} else {
+		fmt.Println("   Ingress: all traffic within the group only (SSM)")
+	}
+	fmt.Println("   Egress:  HTTPS and HTTP, all traffic within the group")
+	fmt.Printf("\nSet aws.security_group: %q in config/build-matrix.yaml\n", groupID)
+}
+
+func runAudit(args []string) {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	var (
+		configFile = fs.String("config", "config/build-matrix.yaml", "Configuration naming the security group")
+		groupID    = fs.String("security-group", "", "Audit this group instead of the config's")
+	)
+	fs.Parse(args)
+
+	config, err := common.LoadBuildConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	groups := []string{config.AWS.SecurityGroup}
+	if *groupID != "" {
+		groups = []string{*groupID}
+	}
+	if groups[0] == "" {
+		log.Fatalf("%s names no security group; pass -security-group", *configFile)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cfg, err := common.LoadSDKConfig(ctx, config.AWS.Profile, config.AWS.Region)
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+
+	sshNeeded := config.Execution.BackendName() == common.BackendSSH
+	findings, err := network.NewManager(cfg).Audit(ctx, groups, sshNeeded)
+	if err != nil {
+		log.Fatalf("Audit failed: %v", err)
+	}
+	fmt.Print(network.FormatFindings(findings))
+
+	failed := false
+	if usesEFA(config) {
+		for _, group := range groups {
+			missing, err := network.NewManager(cfg).MissingEFARules(ctx, group)
+			if err != nil {
+				log.Fatalf("Audit failed: %v", err)
+			}
+			for _, rule := range missing {
+				fmt.Printf("❌ %s: EFA needs %s; run: network efa-rules -security-group %s\n", group, rule, group)
+				failed = true
+			}
+		}
+	}
+	for _, finding := range findings {
+		if finding.Severity == network.SeverityHigh {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func runEFARules(args []string) {
+	fs := flag.NewFlagSet("efa-rules", flag.ExitOnError)
+	var (
+		configFile = fs.String("config", "config/build-matrix.yaml", "Configuration naming the security group")
+		groupID    = fs.String("security-group", "", "Add the rules to this group instead of the config's")
+	)
+	fs.Parse(args)
+
+	config, err := common.LoadBuildConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	group := config.AWS.SecurityGroup
+	if *groupID != "" {
+		group = *groupID
+	}
+	if group == "" {
+		log.Fatalf("%s names no security group; pass -security-group", *configFile)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cfg, err := common.LoadSDKConfig(ctx, config.AWS.Profile, config.AWS.Region)
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+
+	added, err := network.NewManager(cfg).AddEFARules(ctx, group)
+	for _, rule := range added {
+		fmt.Printf("✅ %s: added %s\n", group, rule)
+	}
+	if err != nil {
+		log.Fatalf("Failed to add EFA rules: %v", err)
+	}
+	if len(added) == 0 {
+		fmt.Printf("✅ %s already allows EFA traffic between its members\n", group)
+	}
+}
+
+// usesEFA reports whether any architecture in the config launches with EFA
+func usesEFA(config *common.BuildConfig) bool {
+	for _, archConfig := range config.Architectures {
+		if archConfig.EFA {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/queue"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: queue <command> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  create       Create the shared run queue table and set its limits\n")
+	fmt.Fprintf(os.Stderr, "  policy       Change the queue's vCPU and per-user limits\n")
+	fmt.Fprintf(os.Stderr, "  status       Show running jobs and queued jobs with position and estimated start\n")
+	fmt.Fprintf(os.Stderr, "  cancel <id>  Remove a job from the queue\n\n")
+	fmt.Fprintf(os.Stderr, "Runs join the queue with 'run-geoschem -queue'.\n\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "create":
+		runCreate(os.Args[2:])
+	case "policy":
+		runPolicy(os.Args[2:])
+	case "status":
+		runStatus(os.Args[2:])
+	case "cancel":
+		runCancel(os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+// queueFlags adds the flags every command needs to locate the queue
+func queueFlags(fs *flag.FlagSet) func() *queue.Queue {
+	profile := fs.String("profile", "aws", "AWS profile to use")
+	region := fs.String("region", "us-west-2", "AWS region")
+	table := fs.String("table", queue.DefaultTable, "DynamoDB table holding the queue")
+	return func() *queue.Queue {
+		return queue.New(*profile, *region, *table)
+	}
+}
+
+func policyFlags(fs *flag.FlagSet) (*int, *int) {
+	vcpuLimit := fs.Int("vcpu-limit", 0, "vCPUs shared by all queued runs, usually the account's On-Demand vCPU quota (0 = unlimited)")
+	perUser := fs.Int("per-user", queue.DefaultPerUserLimit, "Runs one user may have running at once (0 = unlimited)")
+	return vcpuLimit, perUser
+}
+
+func runCreate(args []string) {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	open := queueFlags(fs)
+	vcpuLimit, perUser := policyFlags(fs)
+	fs.Parse(args)
+
+	q := open()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	fmt.Printf("🗂️  Creating run queue table %s...\n", q.Table())
+	if err := q.CreateTable(ctx, queue.Policy{VCPULimit: *vcpuLimit, PerUserLimit: *perUser}); err != nil {
+		log.Fatalf("Failed to create queue: %v", err)
+	}
+	fmt.Printf("✅ Queue ready: %s\n", describePolicy(queue.Policy{VCPULimit: *vcpuLimit, PerUserLimit: *perUser}))
+}
+
+func runPolicy(args []string) {
+	fs := flag.NewFlagSet("policy", flag.ExitOnError)
+	open := queueFlags(fs)
+	vcpuLimit, perUser := policyFlags(fs)
+	fs.Parse(args)
+
+	policy := queue.Policy{VCPULimit: *vcpuLimit, PerUserLimit: *perUser}
+	if err := open().SetPolicy(context.Background(), policy); err != nil {
+		log.Fatalf("%v", err)
+	}
+	fmt.Printf("✅ Queue policy: %s\n", describePolicy(policy))
+}
+
+func runStatus(args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	open := queueFlags(fs)
+	fs.Parse(args)
+
+	policy, jobs, err := open().State(context.Background())
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	now := time.Now().UTC()
+
+	fmt.Printf("Queue policy: %s\n\n", describePolicy(policy))
+
+	usedVCPUs := 0
+	var active []queue.Job
+	for _, job := range jobs {
+		if job.Status == queue.StatusRunning {
+			active = append(active, job)
+			usedVCPUs += job.VCPUs
+		}
+	}
+	fmt.Printf("🏃 Running (%d jobs, %d vCPUs)\n", len(active), usedVCPUs)
+	if len(active) > 0 {
+		fmt.Printf("%-17s %-12s %-10s %-14s %6s %-13s %-13s %s\n", "ID", "USER", "PRIORITY", "INSTANCE", "VCPUS", "STARTED", "EST. END", "RUN")
+		for _, job := range active {
+			end := formatTime(job.StartedAt.Add(time.Duration(job.EstimatedHours * float64(time.Hour))))
+			if !job.PreemptRequestedAt.IsZero() {
+				end = "preempting"
+			}
+			fmt.Printf("%-17s %-12s %-10s %-14s %6d %-13s %-13s %s\n", job.ID, job.User, job.PriorityClass(), job.InstanceType, job.VCPUs,
+				formatTime(job.StartedAt), end, job.Description)
+		}
+	}
+
+	slots := queue.Plan(jobs, policy, now)
+	fmt.Printf("\n⏳ Queued (%d jobs)\n", len(slots))
+	if len(slots) > 0 {
+		fmt.Printf("%4s %-17s %-12s %-10s %-14s %6s %-13s %-13s %s\n", "POS", "ID", "USER", "PRIORITY", "INSTANCE", "VCPUS", "SUBMITTED", "EST. START", "RUN")
+		for _, slot := range slots {
+			start := "now"
+			switch {
+			case slot.EstimatedStart.IsZero():
+				start = "never (too big)"
+			case !slot.StartsNow(now):
+				start = formatTime(slot.EstimatedStart)
+			}
+			fmt.Printf("%4d %-17s %-12s %-10s %-14s %6d %-13s %-13s %s\n", slot.Position, slot.Job.ID, slot.Job.User,
+				slot.Job.PriorityClass(), slot.Job.InstanceType, slot.Job.VCPUs, formatTime(slot.Job.SubmittedAt), start, slot.Job.Description)
+		}
+	}
+}
+
+func runCancel(args []string) {
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	open := queueFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: queue cancel [flags] <id>")
+	}
+	if err := open().Cancel(context.Background(), fs.Arg(0)); err != nil {
+		log.Fatalf("Failed to cancel: %v", err)
+	}
+	fmt.Printf("🗑️  Cancelled %s (a running job keeps running; terminate its instance separately)\n", fs.Arg(0))
+}
+
+func describePolicy(policy queue.Policy) string {
+	vcpus := "unlimited vCPUs"
+	if policy.VCPULimit > 0 {
+		vcpus = fmt.Sprintf("%d vCPUs shared", policy.VCPULimit)
+	}
+	perUser := "no per-user limit"
+	if policy.PerUserLimit > 0 {
+		perUser = fmt.Sprintf("%d running jobs per user", policy.PerUserLimit)
+	}
+	return vcpus + ", " + perUser
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("Jan 2 15:04")
+}
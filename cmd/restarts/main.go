@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: restarts <command> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  register     Record a restart file from elsewhere; runs register the one they end with\n")
+	fmt.Fprintf(os.Stderr, "  list         List registered restart files\n")
+	fmt.Fprintf(os.Stderr, "  show <id>    Show a restart file and validate it against a planned run\n")
+	fmt.Fprintf(os.Stderr, "  delete <id>  Remove a restart from the registry (the S3 object is kept)\n\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	store, err := state.OpenDefault()
+	if err != nil {
+		log.Fatalf("Failed to open state store: %v", err)
+	}
+	registry := state.NewRestartRegistry(store)
+
+	switch os.Args[1] {
+	case "register":
+		runRegister(registry, os.Args[2:])
+	case "list":
+		runList(registry, os.Args[2:])
+	case "show":
+		runShow(registry, os.Args[2:])
+	case "delete":
+		runDelete(registry, os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+func runRegister(registry *state.RestartRegistry, args []string) {
+	fs := flag.NewFlagSet("register", flag.ExitOnError)
+	var (
+		s3URI      = fs.String("s3", "", "S3 URI of the restart file (required)")
+		modelDate  = fs.String("date", "", "Model date the restart is valid for, YYYY-MM-DD (required)")
+		simulation = fs.String("simulation", "fullchem", "Simulation type that produced the restart")
+		resolution = fs.String("resolution", "4x5", "Grid resolution of the restart")
+		species    = fs.Int("species", 0, "Number of species in the restart (optional)")
+		image      = fs.String("image", "", "Container image that produced the restart (optional)")
+		runID      = fs.String("run-id", "", "Run that produced the restart (optional)")
+	)
+	fs.Parse(args)
+
+	if *s3URI == "" || *modelDate == "" {
+		log.Fatal("Both -s3 and -date are required")
+	}
+	date, err := time.Parse("2006-01-02", *modelDate)
+	if err != nil {
+		log.Fatalf("Invalid date: %v", err)
+	}
+
+	record, err := registry.Register(state.RestartRecord{
+		S3URI:        *s3URI,
+		ModelDate:    date,
+		Simulation:   *simulation,
+		Resolution:   *resolution,
+		SpeciesCount: *species,
+		Image:        *image,
+		RunID:        *runID,
+	})
+	if err != nil {
+		log.Fatalf("Failed to register restart: %v", err)
+	}
+
+	fmt.Printf("✅ Registered restart %s (%s %s @ %s)\n", record.ID, record.Simulation, record.Resolution, record.ModelDate.Format("2006-01-02"))
+}
+
+func runList(registry *state.RestartRegistry, args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	var (
+		simulation = fs.String("simulation", "", "Filter by simulation type")
+		resolution = fs.String("resolution", "", "Filter by grid resolution")
+	)
+	fs.Parse(args)
+
+	records, err := registry.List(*simulation, *resolution)
+	if err != nil {
+		log.Fatalf("Failed to list restarts: %v", err)
+	}
+	if len(records) == 0 {
+		fmt.Println("No restart files registered")
+		return
+	}
+
+	fmt.Printf("%-16s %-12s %-18s %-12s %s\n", "ID", "MODEL DATE", "SIMULATION", "RESOLUTION", "LOCATION")
+	for _, record := range records {
+		fmt.Printf("%-16s %-12s %-18s %-12s %s\n", record.ID, record.ModelDate.Format("2006-01-02"),
+			record.Simulation, record.Resolution, record.S3URI)
+	}
+}
+
+func runShow(registry *state.RestartRegistry, args []string) {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	var (
+		simulation = fs.String("simulation", "", "Validate against this simulation type")
+		resolution = fs.String("resolution", "", "Validate against this grid resolution")
+		startDate  = fs.String("start-date", "", "Validate against this run start date (YYYY-MM-DD)")
+	)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: restarts show [flags] <id>")
+	}
+
+	record, err := registry.Get(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	fmt.Printf("ID:          %s\n", record.ID)
+	fmt.Printf("Location:    %s\n", record.S3URI)
+	fmt.Printf("Model date:  %s\n", record.ModelDate.Format("2006-01-02 15:04"))
+	fmt.Printf("Simulation:  %s\n", record.Simulation)
+	fmt.Printf("Resolution:  %s\n", record.Resolution)
+	if record.SpeciesCount > 0 {
+		fmt.Printf("Species:     %d\n", record.SpeciesCount)
+	}
+	fmt.Printf("Config hash: %s\n", record.ConfigHash)
+	if record.Image != "" {
+		fmt.Printf("Image:       %s\n", record.Image)
+	}
+	if record.RunID != "" {
+		fmt.Printf("Run:         %s\n", record.RunID)
+	}
+
+	request := state.RestartRequest{Simulation: *simulation, Resolution: *resolution}
+	if *startDate != "" {
+		request.StartDate, err = time.Parse("2006-01-02", *startDate)
+		if err != nil {
+			log.Fatalf("Invalid start date: %v", err)
+		}
+	}
+	if *simulation != "" || *resolution != "" || *startDate != "" {
+		if err := record.Validate(request); err != nil {
+			fmt.Printf("\n❌ Not usable for this run: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\n✅ Compatible with the requested run\n")
+	}
+}
+
+func runDelete(registry *state.RestartRegistry, args []string) {
+	if len(args) != 1 {
+		log.Fatal("Usage: restarts delete <id>")
+	}
+	if err := registry.Delete(args[0]); err != nil {
+		log.Fatalf("Failed to delete restart: %v", err)
+	}
+	fmt.Printf("🗑️  Removed restart %s from the registry\n", args[0])
+}
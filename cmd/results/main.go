@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/catalog"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/data"
+	"github.com/scttfrdmn/geoschem-aws/internal/provenance"
+	"github.com/scttfrdmn/geoschem-aws/internal/runner"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: results <command> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  create       Create the shared results catalog table\n")
+	fmt.Fprintf(os.Stderr, "  search       Find recorded simulation outputs\n")
+	fmt.Fprintf(os.Stderr, "  show <id>    Show everything recorded about one output\n")
+	fmt.Fprintf(os.Stderr, "  download <id|s3-uri>\n")
+	fmt.Fprintf(os.Stderr, "               Estimate egress, then download all or part of an output (resumable)\n")
+	fmt.Fprintf(os.Stderr, "  index <id|s3-uri>\n")
+	fmt.Fprintf(os.Stderr, "               Build kerchunk references so xarray can open the output lazily from S3\n")
+	fmt.Fprintf(os.Stderr, "  publish <id>... | -all\n")
+	fmt.Fprintf(os.Stderr, "               Register runs and their timeseries/budget diagnostics as Glue tables for Athena\n")
+	fmt.Fprintf(os.Stderr, "  add          Record an existing output in S3\n")
+	fmt.Fprintf(os.Stderr, "  delete <id>  Remove an output from the catalog (the S3 objects are kept)\n\n")
+	fmt.Fprintf(os.Stderr, "Runs are recorded automatically with 'run-geoschem -catalog'.\n\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "create":
+		runCreate(os.Args[2:])
+	case "search":
+		runSearch(os.Args[2:])
+	case "show":
+		runShow(os.Args[2:])
+	case "download":
+		runDownload(os.Args[2:])
+	case "index":
+		runIndex(os.Args[2:])
+	case "publish":
+		runPublish(os.Args[2:])
+	case "add":
+		runAdd(os.Args[2:])
+	case "delete":
+		runDelete(os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+// catalogFlags adds the flags every command needs to locate the catalog
+func catalogFlags(fs *flag.FlagSet) func() *catalog.Catalog {
+	profile := fs.String("profile", "aws", "AWS profile to use")
+	region := fs.String("region", "us-west-2", "AWS region")
+	table := fs.String("table", catalog.DefaultTable, "DynamoDB table holding the catalog")
+	return func() *catalog.Catalog {
+		return catalog.New(*profile, *region, *table)
+	}
+}
+
+func runCreate(args []string) {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	open := catalogFlags(fs)
+	fs.Parse(args)
+
+	c := open()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	fmt.Printf("🗂️  Creating results catalog table %s...\n", c.Table())
+	if err := c.CreateTable(ctx); err != nil {
+		log.Fatalf("Failed to create catalog: %v", err)
+	}
+	fmt.Println("✅ Catalog ready")
+}
+
+func runSearch(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	open := catalogFlags(fs)
+	var (
+		simulation = fs.String("simulation", "", "Filter by simulation type")
+		resolution = fs.String("resolution", "", "Filter by grid resolution")
+		metField   = fs.String("met", "", "Filter by met field")
+		image      = fs.String("image", "", "Filter by image reference or digest")
+		configHash = fs.String("config-hash", "", "Filter by configuration hash")
+		runConfig  = fs.String("run-config-hash", "", "Filter by the run config hash in the provenance sidecar")
+		user       = fs.String("user", "", "Filter by who ran it")
+		start      = fs.String("start-date", "", "Only runs covering this start date (YYYY-MM-DD)")
+		end        = fs.String("end-date", "", "Only runs covering this end date (YYYY-MM-DD)")
+	)
+	fs.Parse(args)
+
+	entries, err := open().Search(context.Background(), catalog.Query{
+		Simulation:    *simulation,
+		Resolution:    *resolution,
+		MetField:      *metField,
+		ConfigHash:    *configHash,
+		RunConfigHash: *runConfig,
+		Image:         *image,
+		User:          *user,
+		Start:         *start,
+		End:           *end,
+	})
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if len(entries) == 0 {
+		fmt.Println("No matching results")
+		return
+	}
+
+	fmt.Printf("%-16s %-14s %-10s %-24s %10s %9s %s\n", "ID", "SIMULATION", "RESOLUTION", "DATES", "SIZE", "COST", "LOCATION")
+	for _, entry := range entries {
+		fmt.Printf("%-16s %-14s %-10s %-24s %10s %9s %s\n", entry.ID, entry.Simulation, entry.Resolution,
+			entry.StartDate+" to "+entry.EndDate, data.FormatBytes(entry.SizeBytes),
+			fmt.Sprintf("$%.2f", entry.Cost), entry.OutputURI)
+	}
+}
+
+func runShow(args []string) {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	open := catalogFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: results show [flags] <id>")
+	}
+	entry, err := open().Get(context.Background(), fs.Arg(0))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	fmt.Printf("ID:           %s\n", entry.ID)
+	fmt.Printf("Location:     %s\n", entry.OutputURI)
+	fmt.Printf("Size:         %s in %d objects\n", data.FormatBytes(entry.SizeBytes), entry.Objects)
+	fmt.Printf("Simulation:   %s\n", entry.Simulation)
+	fmt.Printf("Resolution:   %s\n", entry.Resolution)
+	fmt.Printf("Dates:        %s to %s\n", entry.StartDate, entry.EndDate)
+	if entry.MetField != "" {
+		fmt.Printf("Met field:    %s\n", entry.MetField)
+	}
+	if entry.Image != "" {
+		fmt.Printf("Image:        %s\n", entry.Image)
+	}
+	if entry.ImageDigest != "" {
+		fmt.Printf("Digest:       %s\n", entry.ImageDigest)
+	}
+	if entry.ConfigHash != "" {
+		fmt.Printf("Config hash:  %s\n", entry.ConfigHash)
+	}
+	if entry.InstanceType != "" {
+		fmt.Printf("Ran on:       %s via %s\n", entry.InstanceType, entry.Scheduler)
+	}
+	if entry.ProvenanceID != "" {
+		fmt.Printf("Provenance:   %s (run config %s) in %s\n", entry.ProvenanceID, entry.RunConfigHash, provenance.SidecarURI(entry.OutputURI))
+	}
+	if entry.Indexed {
+		fmt.Printf("References:   %s\n", catalog.IndexURI(entry.OutputURI))
+		fmt.Printf("Open with:    %s\n", catalog.OpenSnippet(entry.OutputURI, "SpeciesConc"))
+	}
+	fmt.Printf("Cost:         $%.2f\n", entry.Cost)
+	if entry.User != "" {
+		fmt.Printf("Recorded by:  %s on %s\n", entry.User, entry.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+}
+
+func runDownload(args []string) {
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	open := catalogFlags(fs)
+	var (
+		dest        = fs.String("dest", "", "Local directory to download into (default: the catalog ID or last path element)")
+		collections = fs.String("collections", "", "Comma-separated diagnostic collections to download, e.g. SpeciesConc,AerosolMass")
+		include     = fs.String("include", "", "Comma-separated file name patterns to download as well, e.g. '*.log,*.yml'")
+		maxCost     = fs.Float64("max-cost", 1.0, "Refuse downloads whose estimated egress cost exceeds this many USD")
+		yes         = fs.Bool("yes", false, "Download even if the estimate exceeds -max-cost")
+		dryRun      = fs.Bool("dry-run", false, "Show the estimate without downloading")
+	)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: results download [flags] <id|s3-uri>")
+	}
+	c := open()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	outputURI := fs.Arg(0)
+	if !strings.HasPrefix(outputURI, "s3://") {
+		entry, err := c.Get(ctx, outputURI)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		outputURI = entry.OutputURI
+		if *dest == "" {
+			*dest = entry.ID
+		}
+	}
+	if *dest == "" {
+		*dest = path.Base(strings.TrimSuffix(outputURI, "/"))
+	}
+
+	plan, err := c.PlanDownload(ctx, outputURI, *dest, catalog.Selection{
+		Collections: splitList(*collections),
+		Patterns:    splitList(*include),
+	})
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if len(plan.Objects) == 0 {
+		log.Fatalf("Nothing under %s matches the selection", outputURI)
+	}
+
+	fmt.Printf("📦 %s: %d files, %s selected\n", outputURI, len(plan.Objects), data.FormatBytes(plan.TotalBytes))
+	byCollection := plan.BytesByCollection()
+	names := make([]string, 0, len(byCollection))
+	for name := range byCollection {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("   %-24s %10s\n", name, data.FormatBytes(byCollection[name]))
+	}
+	if plan.PresentBytes > 0 {
+		fmt.Printf("   Resuming: %s already in %s (%d files complete)\n", data.FormatBytes(plan.PresentBytes), *dest, plan.CompleteFiles)
+	}
+	fmt.Printf("💸 Estimated egress: %s, $%.2f at $%.2f/GB (the monthly free allowance is not subtracted; downloads to EC2 in the same region are free)\n",
+		data.FormatBytes(plan.TransferBytes()), plan.EgressCost(), catalog.InternetEgressPerGB)
+
+	if *dryRun {
+		fmt.Println("Dry run: nothing was downloaded")
+		return
+	}
+	if plan.EgressCost() > *maxCost && !*yes {
+		log.Fatalf("Estimated egress $%.2f exceeds -max-cost $%.2f; narrow the download with -collections/-include, or pass -yes",
+			plan.EgressCost(), *maxCost)
+	}
+
+	err = c.Download(ctx, plan, *dest, func(object catalog.Object, done int) {
+		fmt.Printf("   [%d/%d] %s (%s)\n", done, len(plan.Objects), path.Base(object.Key), data.FormatBytes(object.Size))
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Fatalf("Download interrupted; run the same command again to resume")
+		}
+		log.Fatalf("Download failed (run the same command again to resume): %v", err)
+	}
+	fmt.Printf("✅ Downloaded to %s\n", *dest)
+}
+
+func runIndex(args []string) {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	profile := fs.String("profile", "aws", "AWS profile to use")
+	region := fs.String("region", "us-west-2", "AWS region")
+	table := fs.String("table", catalog.DefaultTable, "DynamoDB table holding the catalog")
+	image := fs.String("image", "", "GCPy analysis image with the indexer (built with 'geoschem-aws image -with-analysis', required)")
+	configFile := fs.String("config", "", "Configuration whose runs.fargate queue runs the indexer instead of local docker (optional)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *image == "" {
+		log.Fatal("Usage: results index -image <analysis-image> [flags] <id|s3-uri>")
+	}
+	c := catalog.New(*profile, *region, *table)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	outputURI := fs.Arg(0)
+	id := outputURI
+	if strings.HasPrefix(outputURI, "s3://") {
+		id = catalog.EntryID(outputURI)
+	}
+	entry, err := c.Get(ctx, id)
+	if err != nil && !strings.HasPrefix(outputURI, "s3://") {
+		log.Fatalf("%v", err)
+	}
+	if entry != nil {
+		outputURI = entry.OutputURI
+	}
+
+	indexer, where := analysisRunner(*configFile, *profile, *region)
+	fmt.Printf("🗂️  Building kerchunk references for %s %s...\n", outputURI, where)
+	if err := catalog.Index(ctx, indexer, *image, outputURI); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Keep the catalog entry's size and index flag current
+	if entry != nil {
+		if _, err := c.RecordOutput(ctx, *entry); err != nil {
+			fmt.Printf("Warning: could not update %s: %v\n", entry.ID, err)
+		}
+	}
+	fmt.Printf("✅ References written to %s\n", catalog.IndexURI(outputURI))
+	fmt.Printf("   Open with: %s\n", catalog.OpenSnippet(outputURI, "SpeciesConc"))
+}
+
+func runPublish(args []string) {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	profile := fs.String("profile", "aws", "AWS profile to use")
+	region := fs.String("region", "us-west-2", "AWS region")
+	table := fs.String("table", catalog.DefaultTable, "DynamoDB table holding the catalog")
+	location := fs.String("location", "", "S3 prefix the Athena tables read from, e.g. s3://geoschem-lake/tables (required)")
+	database := fs.String("database", catalog.DefaultDatabase, "Glue database for the runs and diagnostics tables")
+	image := fs.String("image", "", "GCPy analysis image; when set, also tabulate timeseries and budgets from the output")
+	species := fs.String("species", "", "Comma-separated SpeciesConc variables to tabulate (default: O3 and CO)")
+	all := fs.Bool("all", false, "Publish every entry in the catalog")
+	configFile := fs.String("config", "", "Configuration whose runs.fargate queue runs the tabulation instead of local docker (optional)")
+	fs.Parse(args)
+
+	if *location == "" || (fs.NArg() == 0 && !*all) {
+		log.Fatal("Usage: results publish -location <s3-uri> [flags] <id>... | -all")
+	}
+	c := catalog.New(*profile, *region, *table)
+	lake, err := catalog.NewLake(c, *database, *location)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var entries []catalog.Entry
+	if *all {
+		if entries, err = c.Search(ctx, catalog.Query{}); err != nil {
+			log.Fatalf("%v", err)
+		}
+	} else {
+		for _, id := range fs.Args() {
+			entry, err := c.Get(ctx, id)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			entries = append(entries, *entry)
+		}
+	}
+
+	if err := lake.Setup(ctx); err != nil {
+		log.Fatalf("%v", err)
+	}
+	tabulator, where := analysisRunner(*configFile, *profile, *region)
+	if *image != "" {
+		fmt.Printf("Tabulating diagnostics %s\n", where)
+	}
+
+	failed := 0
+	for _, entry := range entries {
+		if err := lake.PublishRun(ctx, entry); err != nil {
+			fmt.Printf("❌ %s: %v\n", entry.ID, err)
+			failed++
+			continue
+		}
+		if *image != "" {
+			if err := lake.Tabulate(ctx, tabulator, *image, entry, splitList(*species)); err != nil {
+				fmt.Printf("⚠️  %s: published without diagnostics: %v\n", entry.ID, err)
+				continue
+			}
+		}
+		fmt.Printf("✅ %s published\n", entry.ID)
+	}
+
+	fmt.Printf("\nQuery with Athena, e.g.:\n")
+	fmt.Printf("  SELECT r.simulation, r.resolution, d.time, d.value FROM %[1]s.diagnostics d\n", lake.Database())
+	fmt.Printf("    JOIN %[1]s.runs r ON r.id = d.run_id WHERE d.variable = 'SpeciesConc_O3' ORDER BY d.time\n", lake.Database())
+	if failed > 0 {
+		log.Fatalf("%d of %d runs could not be published", failed, len(entries))
+	}
+}
+
+// analysisRunner runs the analysis image on the config's Fargate queue when it has one,
+// otherwise with local docker, and says where
+func analysisRunner(configFile, profile, region string) (catalog.AnalysisRunner, string) {
+	if configFile == "" {
+		return catalog.LocalAnalysis{Profile: profile, Region: region}, "locally"
+	}
+	config, err := common.LoadBuildConfig(configFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if !config.Runs.Fargate.Enabled() {
+		return catalog.LocalAnalysis{Profile: profile, Region: region}, "locally"
+	}
+	fargate, err := runner.NewFargateRunner(config)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return fargate, "on Fargate"
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func runAdd(args []string) {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	open := catalogFlags(fs)
+	var (
+		output       = fs.String("output", "", "S3 URI of the run output (required)")
+		simulation   = fs.String("simulation", "fullchem", "Simulation type that produced the output")
+		resolution   = fs.String("resolution", "4x5", "Grid resolution of the output")
+		metField     = fs.String("met", "", "Met field the run read")
+		start        = fs.String("start-date", "", "Simulation start date, YYYY-MM-DD (required)")
+		end          = fs.String("end-date", "", "Simulation end date, YYYY-MM-DD (required)")
+		image        = fs.String("image", "", "Container image that produced the output (optional)")
+		instanceType = fs.String("instance-type", "", "Instance type the run used (optional)")
+		cost         = fs.Float64("cost", 0, "Compute cost of the run in USD (optional)")
+	)
+	fs.Parse(args)
+
+	if *output == "" || *start == "" || *end == "" {
+		log.Fatal("-output, -start-date and -end-date are required")
+	}
+
+	entry, err := open().RecordOutput(context.Background(), catalog.Entry{
+		Image:        *image,
+		Simulation:   *simulation,
+		Resolution:   *resolution,
+		MetField:     *metField,
+		StartDate:    *start,
+		EndDate:      *end,
+		OutputURI:    *output,
+		Cost:         *cost,
+		InstanceType: *instanceType,
+		User:         common.CurrentUser(),
+	})
+	if err != nil {
+		log.Fatalf("Failed to record output: %v", err)
+	}
+	fmt.Printf("✅ Recorded %s (%s in %d objects)\n", entry.ID, data.FormatBytes(entry.SizeBytes), entry.Objects)
+}
+
+func runDelete(args []string) {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	open := catalogFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: results delete [flags] <id>")
+	}
+	if err := open().Delete(context.Background(), fs.Arg(0)); err != nil {
+		log.Fatalf("%v", err)
+	}
+	fmt.Printf("🗑️  Removed %s from the catalog (the output in S3 was kept)\n", fs.Arg(0))
+}
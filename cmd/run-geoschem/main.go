@@ -0,0 +1,724 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/artifacts"
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/benchmark"
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+	"github.com/scttfrdmn/geoschem-aws/internal/catalog"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/hemco"
+	"github.com/scttfrdmn/geoschem-aws/internal/notify"
+	"github.com/scttfrdmn/geoschem-aws/internal/queue"
+	"github.com/scttfrdmn/geoschem-aws/internal/runflow"
+	"github.com/scttfrdmn/geoschem-aws/internal/runner"
+	"github.com/scttfrdmn/geoschem-aws/internal/shutdown"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+	"github.com/scttfrdmn/geoschem-aws/internal/storage"
+)
+
+func main() {
+	// pause and resume act on existing runs; anything else starts a run
+	var resuming *state.PausedRun
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "pause":
+			runPause(os.Args[2:])
+			return
+		case "resume":
+			if resuming = runResume(os.Args[2:]); resuming == nil {
+				return
+			}
+		}
+	}
+
+	var (
+		profile         = flag.String("profile", "aws", "AWS profile to use")
+		region          = flag.String("region", "us-west-2", "AWS region")
+		configFile      = flag.String("config", "", "Configuration file with AWS settings and the runs scheduler (optional)")
+		scheduler       = flag.String("scheduler", "", "Where to run: ec2, batch, slurm, eks (default: runs.scheduler from -config, else ec2)")
+		subnetID        = flag.String("subnet", "", "Subnet ID for the run instance")
+		sgID            = flag.String("security-group", "", "Security Group ID for the run instance")
+		image           = flag.String("image", "", "GeosChem container image to run")
+		instanceType    = flag.String("instance-type", "", "Instance type (default: lowest predicted cost per model year)")
+		simulation      = flag.String("simulation", "fullchem", "Simulation type")
+		resolution      = flag.String("resolution", "4x5", "Grid resolution")
+		nestedDomain    = flag.String("nested-domain", "", "Nested-grid domain (AS, EU, NA)")
+		startDate       = flag.String("start-date", "2019-07-01", "Simulation start date (YYYY-MM-DD)")
+		endDate         = flag.String("end-date", "2019-08-01", "Simulation end date (YYYY-MM-DD)")
+		dataSource      = flag.String("data-source", "", "S3 URI of input data to sync onto the instance")
+		restartID       = flag.String("restart", "", "Restart ID from the restart registry")
+		output          = flag.String("output", "", "S3 URI to copy the run output to")
+		efsID           = flag.String("efs", "", "EFS file system for a shared run directory and output (see 'storage efs create')")
+		fsxID           = flag.String("fsx", "", "FSx for Lustre file system serving the inputs and holding the run directory (see 'storage fsx create'); runs.fsx can create one per run")
+		notifyTopic     = flag.String("notify-topic", "", "SNS topic ARN that receives the completion notification and output summary")
+		catalogTable    = flag.String("catalog", "", "Results catalog table: skip runs it already holds and record the output (see 'results create')")
+		indexImage      = flag.String("index-image", "", "GCPy analysis image; build kerchunk references over -output after the run")
+		rerun           = flag.Bool("rerun", false, "Run even if the results catalog already holds a matching output")
+		queueTable      = flag.String("queue", "", "Wait for a fair share of the account's vCPUs in this run queue table (see 'queue create')")
+		priority        = flag.String("priority", queue.PriorityNormal, "Priority class in the run queue: urgent, normal, or scavenger (Spot, preempted for higher classes)")
+		performanceData = flag.String("performance-data", "", "Additional benchmark records (newline-delimited JSON) for prediction")
+		disableSMT      = flag.Bool("disable-smt", false, "Plan for one thread per physical core, and launch with SMT off on instances that have it (ec2 scheduler)")
+		efa             = flag.Bool("efa", false, "Launch with an Elastic Fabric Adapter for MPI between nodes (ec2 scheduler; security group must allow all traffic within itself)")
+		placementGroup  = flag.String("placement-group", "", "Cluster placement group to launch into, created if missing (default: placement.cluster_group from -config)")
+		metField        = flag.String("met", benchmark.DefaultMetField, "Met field the run reads: MERRA2, GEOSFP, GEOSIT")
+		emissions       = flag.String("emissions", "", "YAML file of emissions overrides (scale factors, inventories, masks) merged into HEMCO_Config.rc")
+		skipPreflight   = flag.Bool("skip-preflight", false, "Skip the input checks before the simulation starts")
+		retryOnOOM      = flag.Bool("retry-on-oom", false, "Rerun on the next larger memory instance when the simulation runs out of memory")
+		chunk           = flag.String("chunk", "", "Split a long run into year or month chunks chained by restart files; rerunning continues after the last complete chunk")
+		checkpoint      = flag.String("checkpoint", benchmark.CheckpointMonthly, "How often to write restart files a paused run resumes from: daily, monthly, or empty for the run directory's setting")
+		dryRun          = flag.Bool("dry-run", false, "Show predicted wall-clock time and cost without launching anything")
+		keepArtifacts   = flag.Bool("keep-artifacts", false, artifacts.FlagUsage)
+	)
+	flag.Parse()
+	artifacts.SetKeep(*keepArtifacts)
+	if err := queue.ValidatePriority(*priority); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if err := runner.ValidateChunk(*chunk); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	workload := common.WorkloadProfile{
+		GridResolution: *resolution,
+		Architecture:   "any",
+		DisableSMT:     *disableSMT,
+	}
+	if *nestedDomain != "" {
+		domain, err := common.GetNestedDomain(*nestedDomain)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		workload.Nested = domain
+	}
+	if workload.IsGCHP() {
+		log.Fatal("run-geoschem runs GeosChem Classic; GCHP resolutions are not supported yet")
+	}
+
+	runConfig := benchmark.Config{
+		InstanceType:  *instanceType,
+		Simulation:    *simulation,
+		Resolution:    *resolution,
+		StartDate:     *startDate,
+		EndDate:       *endDate,
+		DataSource:    *dataSource,
+		Diagnostics:   benchmark.DefaultDiagnostics,
+		OutputURI:     *output,
+		EFSID:         *efsID,
+		MetField:      *metField,
+		IndexImage:    *indexImage,
+		SkipPreflight: *skipPreflight,
+		Checkpoint:    *checkpoint,
+		Spot:          *priority == queue.PriorityScavenger,
+		EFA:           *efa,
+		DisableSMT:    *disableSMT,
+	}
+	if *emissions != "" {
+		overrides, err := hemco.LoadOverrides(*emissions)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		runConfig.Emissions = overrides
+	}
+	if runConfig.Spot {
+		// Preemption waits for the next checkpoint; daily ones keep the wait and lost work short
+		checkpointSet := false
+		flag.Visit(func(f *flag.Flag) { checkpointSet = checkpointSet || f.Name == "checkpoint" })
+		if !checkpointSet {
+			runConfig.Checkpoint = benchmark.CheckpointDaily
+		}
+	}
+	modelDays, err := runConfig.ModelDays()
+	if err != nil {
+		log.Fatalf("Invalid run period: %v", err)
+	}
+
+	// Catch inputs that cannot work before predicting or paying for anything
+	if !*skipPreflight {
+		preflight := runConfig.Preflight()
+		fmt.Print(preflight.Report())
+		if err := preflight.Err(); err != nil {
+			log.Fatalf("Run would fail: %v", err)
+		}
+	}
+
+	store, err := state.OpenDefault()
+	if err != nil {
+		log.Fatalf("Failed to open state store: %v", err)
+	}
+	predictor, err := state.NewPerformanceLog(store).LoadPredictor(*performanceData)
+	if err != nil {
+		log.Fatalf("Failed to load performance data: %v", err)
+	}
+
+	request := common.PredictionRequest{
+		Simulation: *simulation,
+		Workload:   workload,
+		ModelDays:  modelDays,
+	}
+
+	// Don't repeat an instance size this workload has already run out of memory on
+	workloads := state.NewWorkloadProfiles(store)
+	learned, err := workloads.Lookup(*simulation, *resolution, *nestedDomain)
+	if err != nil {
+		log.Fatalf("Failed to load workload profile: %v", err)
+	}
+
+	candidates := common.InstanceCatalog()
+	if *instanceType != "" {
+		instance, err := common.LookupInstance(*instanceType)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		candidates = []common.InstanceRecommendation{*instance}
+		if learned != nil && instance.Memory <= learned.InsufficientMemoryGB {
+			fmt.Printf("⚠️  This workload ran out of memory on %s (%.0f GB); %s has %.0f GB\n",
+				learned.OOMInstanceType, learned.InsufficientMemoryGB, instance.InstanceType, instance.Memory)
+		}
+	} else if *efa {
+		candidates = slices.DeleteFunc(candidates, func(instance common.InstanceRecommendation) bool {
+			return !instance.EFA
+		})
+	}
+	if *instanceType == "" {
+		candidates = runflow.ExcludeOutOfMemory(candidates, learned)
+	}
+
+	predictions := predictor.Rank(request, candidates)
+	if len(predictions) == 0 {
+		log.Fatalf("No instance type can run %s %s", *simulation, workload.Description())
+	}
+	if !*dryRun && *instanceType == "" && len(predictions) > 5 {
+		predictions = predictions[:5]
+	}
+	fmt.Print(common.FormatPredictions(predictions, request))
+
+	selected := predictions[0]
+	fmt.Printf("\n⏱️  Estimated wall clock on %s: %s\n", selected.InstanceType, common.FormatWallClock(selected.WallClock))
+	fmt.Printf("💰 Estimated cost: $%.2f ($%.2f per model year)\n", selected.Cost, selected.CostPerModelYear)
+	if selected.Samples == 0 {
+		fmt.Printf("⚠️  No benchmark data for this configuration; run 'benchmark compare' to improve the estimate\n")
+	}
+
+	if *dryRun {
+		fmt.Println("\nDry run: no resources were created")
+		return
+	}
+
+	buildConfig, err := loadRunConfig(*configFile, *profile, *region, *subnetID, *sgID, *scheduler, *fsxID)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if err := checkSchedulerFlags(buildConfig, *image, *output, *efsID, *dataSource); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if *priority == queue.PriorityScavenger && buildConfig.Runs.SchedulerName() != common.SchedulerEC2 {
+		log.Fatal("-priority scavenger runs on Spot instances, which needs the ec2 scheduler")
+	}
+	if *placementGroup != "" {
+		buildConfig.Placement.ClusterGroup = *placementGroup
+	}
+	if (*efa || buildConfig.Placement.ClusterGroup != "") && buildConfig.Runs.SchedulerName() != common.SchedulerEC2 {
+		log.Fatal("-efa and cluster placement groups apply to instances the ec2 scheduler launches")
+	}
+	if *priority != queue.PriorityNormal && *queueTable == "" {
+		fmt.Printf("⚠️  -priority only orders runs in a queue; without -queue the run starts now\n")
+	}
+	awsProfile, awsRegion := buildConfig.AWS.Profile, buildConfig.AWS.Region
+	runConfig.InstanceType = selected.InstanceType
+
+	if *restartID != "" {
+		start, _ := time.Parse("2006-01-02", *startDate)
+		restart, err := state.NewRestartRegistry(store).Resolve(*restartID, state.RestartRequest{
+			Simulation: *simulation,
+			Resolution: *resolution,
+			StartDate:  start,
+		})
+		if err != nil {
+			log.Fatalf("Invalid restart: %v", err)
+		}
+		runConfig.RestartURI = restart.S3URI
+	}
+
+	if err := runConfig.Validate(); err != nil {
+		log.Fatalf("Invalid run configuration: %v", err)
+	}
+	if len(runConfig.Emissions) > 0 && runConfig.DataSource != "" {
+		cli := awscli.New(awsProfile, awsRegion)
+		if err := hemco.CheckStaged(context.Background(), cli, runConfig.DataSource, runConfig.Emissions); err != nil {
+			log.Fatalf("Invalid emissions overrides: %v", err)
+		}
+	}
+	if *chunk != "" {
+		chunks, err := runner.SplitChunks(runConfig, *chunk)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Printf("🧩 Running in %d %s chunks, each continuing from the previous chunk's restart\n", len(chunks), *chunk)
+	}
+
+	var notifier *notify.Notifier
+	if *notifyTopic != "" {
+		if notifier, err = notify.New(awsProfile, *notifyTopic); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	var results *catalog.Catalog
+	if *catalogTable != "" {
+		results = catalog.New(awsProfile, awsRegion, *catalogTable)
+		if !*rerun {
+			cataloged, err := runflow.Cataloged(context.Background(), results, runConfig, *image)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			if cataloged {
+				return
+			}
+		}
+	}
+
+	// Waiting in the queue doesn't count against the run's timeout
+	var runQueue *queue.Queue
+	var queuedJob *queue.Job
+	if *queueTable != "" {
+		runQueue = queue.New(awsProfile, awsRegion, *queueTable)
+		queuedJob, err = submitToQueue(runQueue, runConfig, selected, *priority, awsProfile, awsRegion)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		runConfig.QueueJob = queuedJob.ID
+	}
+
+	// Allow twice the prediction before giving up, with a floor for short runs
+	timeout := 2 * selected.WallClock
+	if timeout < 2*time.Hour {
+		timeout = 2 * time.Hour
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Interrupts cancel the run; schedulers stop their jobs as they unwind
+	ctx, interrupts := shutdown.Trap(ctx)
+	defer interrupts.Stop()
+	removeArtifacts := shutdown.Register(ctx, "temporary files", artifacts.Cleanup)
+	defer func() {
+		if err := removeArtifacts(); err != nil {
+			log.Printf("Warning: failed to remove temporary files: %v", err)
+		}
+	}()
+
+	cfg, err := common.LoadSDKConfig(ctx, awsProfile, awsRegion)
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+
+	runScheduler, err := runner.NewScheduler(cfg, buildConfig)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// One file system serves every chunk and retry; it's released as soon as the run ends
+	runFSx, releaseFSx, err := runner.PrepareFSx(ctx, buildConfig, runJobName(runConfig)+"-"+time.Now().UTC().Format("20060102T150405"))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	finishFSx := shutdown.Register(ctx, "FSx file system "+runFSx, releaseFSx)
+	runConfig.FSxID = runFSx
+
+	fmt.Printf("\n🚀 Running %s %s on %s via %s\n", *simulation, workload.Description(), selected.InstanceType, runScheduler.Name())
+	run := &runflow.Run{
+		Job: runner.Job{
+			Name:      runJobName(runConfig),
+			Image:     *image,
+			Config:    runConfig,
+			TimeLimit: timeout,
+		},
+		NestedDomain: *nestedDomain,
+		Chunk:        *chunk,
+		RetryOnOOM:   *retryOnOOM,
+		ConfigFiles:  map[string]string{"config": *configFile, "emissions": *emissions},
+		Profile:      awsProfile,
+		Region:       awsRegion,
+	}
+	result := runflow.Execute(ctx, store, runScheduler, run)
+	runConfig = run.Job.Config
+	if err := finishFSx(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if errors.Is(result.Err, benchmark.ErrPaused) {
+		paused, err := recordPause(store, runConfig, *image, result.Checkpoint, awsProfile, awsRegion)
+		if err != nil {
+			log.Fatalf("Run paused at %s, but recording it failed: %v; resume with -restart using %s",
+				result.Checkpoint.ModelDate.Format("2006-01-02"), err, result.Checkpoint.S3URI)
+		}
+		if queuedJob != nil {
+			if err := runQueue.Finish(context.Background(), queuedJob.ID, queue.StatusPaused); err != nil {
+				fmt.Printf("Warning: could not release queue slot %s: %v\n", queuedJob.ID, err)
+			}
+		}
+		if notifier != nil {
+			message := fmt.Sprintf("Run: %s\nCheckpoint: %s\nResume with: run-geoschem resume %s\n", paused.Description, result.Checkpoint.S3URI, paused.ID)
+			if err := notifier.Send(context.Background(), "GeosChem run paused: "+paused.Description, message); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}
+		finishResume(store, resuming)
+
+		fmt.Printf("\n⏸️  Run paused at %s after %s ($%.2f so far)\n", paused.ResumeDate, common.FormatWallClock(result.WallClock),
+			pausedCost(runConfig, result))
+		fmt.Printf("   Checkpoint: %s (restart %s)\n", result.Checkpoint.S3URI, paused.RestartID)
+		if paused.InstanceID != "" {
+			fmt.Printf("   Instance %s is stopped; resuming terminates it\n", paused.InstanceID)
+		}
+		fmt.Printf("   Resume with: run-geoschem resume %s\n", paused.ID)
+		return
+	}
+	if notifier != nil {
+		subject, message := completionMessage(runConfig, *image, result)
+		if err := notifier.Send(context.Background(), subject, message); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	if queuedJob != nil {
+		status := queue.StatusDone
+		if result.Err != nil {
+			status = queue.StatusFailed
+		}
+		if err := runQueue.Finish(context.Background(), queuedJob.ID, status); err != nil {
+			fmt.Printf("Warning: could not release queue slot %s: %v\n", queuedJob.ID, err)
+		}
+	}
+	if result.Err != nil {
+		log.Fatalf("Run failed: %v", result.Err)
+	}
+
+	fmt.Printf("\n✅ Run complete in %s (predicted %s)\n", common.FormatWallClock(result.WallClock), common.FormatWallClock(selected.WallClock))
+	fmt.Printf("   Throughput: %.1f model days/day, cost: $%.2f\n", result.Throughput, result.Cost)
+	if *output != "" {
+		fmt.Printf("   Output: %s\n", *output)
+	}
+	if *efsID != "" {
+		fmt.Printf("   Run directory kept on EFS %s under %s/runs\n", *efsID, storage.DefaultEFSMountPath)
+	}
+	if fsx := buildConfig.Runs.FSx.WithDefaults(); runFSx != "" && fsx.KeepsOutput() {
+		where := "FSx " + runFSx
+		if fsx.AfterRun == common.FSxHibernate {
+			where = fsx.DataRepository
+		}
+		fmt.Printf("   Run directory kept in %s under runs/\n", where)
+	}
+
+	// Only the ec2 scheduler indexes on the run instance; index the rest on the Fargate
+	// queue when one is configured, otherwise from here
+	if *indexImage != "" && *output != "" && !result.Indexed {
+		var indexer catalog.AnalysisRunner = catalog.LocalAnalysis{Profile: awsProfile, Region: awsRegion}
+		where := "locally"
+		if buildConfig.Runs.Fargate.Enabled() {
+			fargate, err := runner.NewFargateRunner(buildConfig)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			indexer, where = fargate, "on Fargate"
+		}
+		fmt.Printf("🗂️  Building kerchunk references for %s %s...\n", *output, where)
+		if err := catalog.Index(context.Background(), indexer, *indexImage, *output); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		} else {
+			result.Indexed = true
+		}
+	}
+	if result.Indexed {
+		fmt.Printf("   References: %s\n", catalog.IndexURI(*output))
+	}
+
+	// Keep what determined the results so runs can be compared with 'geoschem-aws run diff'
+	runflow.Record(context.Background(), store, run, runScheduler.Name(), result, results)
+	finishResume(store, resuming)
+}
+
+// loadRunConfig reads the optional config file and applies the command-line overrides
+func loadRunConfig(configFile, profile, region, subnetID, sgID, scheduler, fsxID string) (*common.BuildConfig, error) {
+	buildConfig := &common.BuildConfig{
+		AWS: common.AWSConfig{Profile: profile, Region: region},
+	}
+	if configFile != "" {
+		loaded, err := common.LoadBuildConfig(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", configFile, err)
+		}
+		buildConfig = loaded
+	}
+	if subnetID != "" {
+		buildConfig.AWS.SubnetID = subnetID
+	}
+	if sgID != "" {
+		buildConfig.AWS.SecurityGroup = sgID
+	}
+	if scheduler != "" {
+		buildConfig.Runs.Scheduler = scheduler
+	}
+	if fsxID != "" {
+		buildConfig.Runs.FSx.FileSystemID = fsxID
+	}
+	if err := buildConfig.Runs.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scheduler settings: %w", err)
+	}
+	return buildConfig, nil
+}
+
+// checkSchedulerFlags rejects flag combinations the selected scheduler can't honor
+func checkSchedulerFlags(buildConfig *common.BuildConfig, image, output, efsID, dataSource string) error {
+	if image == "" {
+		return fmt.Errorf("-image is required to run")
+	}
+
+	switch buildConfig.Runs.SchedulerName() {
+	case common.SchedulerEC2:
+		if buildConfig.AWS.SubnetID == "" || buildConfig.AWS.SecurityGroup == "" {
+			return fmt.Errorf("-subnet and -security-group are required to run on EC2")
+		}
+		if output == "" && efsID == "" && !buildConfig.Runs.FSx.KeepsOutput() {
+			return fmt.Errorf("either -output or -efs is required so the output outlives the instance")
+		}
+	case common.SchedulerBatch, common.SchedulerEKS:
+		if output == "" {
+			return fmt.Errorf("-output is required on %s so the output outlives the container", buildConfig.Runs.SchedulerName())
+		}
+	}
+	if efsID != "" && buildConfig.Runs.SchedulerName() != common.SchedulerEC2 {
+		return fmt.Errorf("-efs is only supported on the ec2 scheduler; mount EFS in the compute environment instead")
+	}
+	if buildConfig.Runs.FSx.Enabled() {
+		if efsID != "" {
+			return fmt.Errorf("-efs and FSx both hold the run directory; use one")
+		}
+		if dataSource != "" {
+			return fmt.Errorf("with FSx the inputs load from its data repository; stage them there (data stage -dest) instead of passing -data-source")
+		}
+	}
+	return nil
+}
+
+// runJobName names the run for schedulers, which allow letters, digits, '-' and '_'
+func runJobName(config benchmark.Config) string {
+	name := fmt.Sprintf("geoschem-%s-%s-%s", config.Simulation, config.Resolution, strings.ReplaceAll(config.StartDate, "-", ""))
+	return strings.ReplaceAll(name, ".", "p")
+}
+
+// submitToQueue joins the run queue and blocks until the run's turn, preempting running
+// scavengers when they hold the capacity it needs. An interrupt while waiting withdraws the job.
+func submitToQueue(runQueue *queue.Queue, config benchmark.Config, selected common.Prediction, priority, profile, region string) (*queue.Job, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	instance, err := common.LookupInstance(selected.InstanceType)
+	if err != nil {
+		return nil, err
+	}
+	job, err := runQueue.Submit(ctx, queue.Job{
+		User:           common.CurrentUser(),
+		Description:    fmt.Sprintf("%s %s %s to %s", config.Simulation, config.Resolution, config.StartDate, config.EndDate),
+		InstanceType:   selected.InstanceType,
+		VCPUs:          instance.VCPUs,
+		EstimatedHours: selected.WallClock.Hours(),
+		Priority:       priority,
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("🗂️  Submitted %s to queue %s\n", job.ID, runQueue.Table())
+
+	// Scavengers checkpoint and terminate; the process running each records it as paused
+	preempt := func(ctx context.Context, victim queue.Job) error {
+		cfg, err := common.LoadSDKConfig(ctx, profile, region)
+		if err != nil {
+			return err
+		}
+		return benchmark.PreemptQueuedRun(ctx, cfg, victim.ID)
+	}
+	if err := runQueue.WaitForTurn(ctx, job.ID, 30*time.Second, preempt); err != nil {
+		if ctx.Err() != nil {
+			if cancelErr := runQueue.Cancel(context.Background(), job.ID); cancelErr != nil {
+				fmt.Printf("Warning: could not withdraw %s: %v\n", job.ID, cancelErr)
+			}
+			return nil, fmt.Errorf("interrupted while queued; %s withdrawn", job.ID)
+		}
+		return nil, fmt.Errorf("waiting in queue: %w", err)
+	}
+	fmt.Printf("▶️  %s is starting\n", job.ID)
+	return job, nil
+}
+
+// completionMessage describes the finished run, including the output summary, for notifications
+func completionMessage(config benchmark.Config, image string, result *benchmark.Result) (string, string) {
+	run := fmt.Sprintf("%s %s %s to %s", config.Simulation, config.Resolution, config.StartDate, config.EndDate)
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "Run: %s\nImage: %s\nInstance: %s\n", run, image, config.InstanceType)
+
+	if result.Err != nil {
+		fmt.Fprintf(&message, "\n❌ Failed: %v\n", result.Err)
+		return "GeosChem run failed: " + run, message.String()
+	}
+
+	fmt.Fprintf(&message, "Wall clock: %s\nThroughput: %.1f model days/day\nCost: $%.2f\n",
+		common.FormatWallClock(result.WallClock), result.Throughput, result.Cost)
+	if config.OutputURI != "" {
+		fmt.Fprintf(&message, "Output: %s\n", config.OutputURI)
+	}
+
+	subject := "GeosChem run complete: " + run
+	if result.Summary != nil {
+		message.WriteString("\n" + result.Summary.Report())
+		if !result.Summary.OK() {
+			subject = "GeosChem run needs review: " + run
+		}
+	}
+	return subject, message.String()
+}
+
+// runPause asks a running simulation to stop at its next restart checkpoint
+func runPause(args []string) {
+	fs := flag.NewFlagSet("pause", flag.ExitOnError)
+	var (
+		profile   = fs.String("profile", "aws", "AWS profile to use")
+		region    = fs.String("region", "us-west-2", "AWS region")
+		terminate = fs.Bool("terminate", false, "Terminate the instance instead of stopping it")
+	)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: run-geoschem pause [flags] <instance-id>")
+	}
+
+	ctx := context.Background()
+	cfg, err := common.LoadSDKConfig(ctx, *profile, *region)
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+	mode := benchmark.PauseStop
+	if *terminate {
+		mode = benchmark.PauseTerminate
+	}
+	if err := benchmark.RequestPause(ctx, cfg, fs.Arg(0), mode); err != nil {
+		log.Fatalf("Failed to pause: %v", err)
+	}
+	fmt.Printf("⏸️  Asked the run on %s to pause at its next restart checkpoint\n", fs.Arg(0))
+	fmt.Printf("   The run-geoschem process running it saves the checkpoint, then the instance is %s\n",
+		map[string]string{benchmark.PauseStop: "stopped", benchmark.PauseTerminate: "terminated"}[mode])
+}
+
+// runResume lists paused runs, or prepares the command line to resume one from its
+// checkpoint and returns it. A stopped instance is terminated first: the run resumes on
+// fresh capacity, and the checkpoint and output are in S3.
+func runResume(args []string) *state.PausedRun {
+	fs := flag.NewFlagSet("resume", flag.ExitOnError)
+	fs.Parse(args)
+
+	store, err := state.OpenDefault()
+	if err != nil {
+		log.Fatalf("Failed to open state store: %v", err)
+	}
+	pausedRuns := state.NewPausedRuns(store)
+
+	if fs.NArg() == 0 {
+		runs, err := pausedRuns.List()
+		if err != nil {
+			log.Fatalf("Failed to list paused runs: %v", err)
+		}
+		if len(runs) == 0 {
+			fmt.Println("No paused runs")
+			return nil
+		}
+		fmt.Printf("%-16s %-17s %-12s %s\n", "ID", "PAUSED", "RESUMES AT", "RUN")
+		for _, run := range runs {
+			fmt.Printf("%-16s %-17s %-12s %s\n", run.ID, run.PausedAt.Local().Format("2006-01-02 15:04"), run.ResumeDate, run.Description)
+		}
+		return nil
+	}
+
+	paused, err := pausedRuns.Get(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	fmt.Printf("▶️  Resuming %s from %s\n", paused.Description, paused.ResumeDate)
+
+	if paused.InstanceID != "" {
+		ctx := context.Background()
+		cfg, err := common.LoadSDKConfig(ctx, paused.Profile, paused.Region)
+		if err != nil {
+			log.Fatalf("Failed to load AWS config: %v", err)
+		}
+		if err := builder.NewFromConfig(cfg, paused.Region).TerminateInstance(ctx, paused.InstanceID); err != nil {
+			fmt.Printf("Warning: could not terminate stopped instance %s: %v\n", paused.InstanceID, err)
+		}
+	}
+
+	// Later flags override earlier ones, so the run continues from the checkpoint
+	os.Args = append([]string{os.Args[0]}, paused.Args...)
+	os.Args = append(os.Args, "-start-date", paused.ResumeDate, "-restart", paused.RestartID)
+	return paused
+}
+
+// recordPause registers a paused run's checkpoint as a restart and records the run so
+// 'run-geoschem resume' can continue it from there
+func recordPause(store *state.Store, config benchmark.Config, image string, checkpoint *benchmark.Checkpoint, profile, region string) (*state.PausedRun, error) {
+	restart, err := state.NewRestartRegistry(store).Register(state.RestartRecord{
+		S3URI:      checkpoint.S3URI,
+		ModelDate:  checkpoint.ModelDate,
+		Simulation: config.Simulation,
+		Resolution: config.Resolution,
+		ConfigHash: config.ConfigHash(image),
+		Image:      image,
+		RunID:      runJobName(config),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	paused := state.PausedRun{
+		Description: fmt.Sprintf("%s %s %s to %s", config.Simulation, config.Resolution, config.StartDate, config.EndDate),
+		Args:        os.Args[1:],
+		RestartID:   restart.ID,
+		ResumeDate:  checkpoint.ModelDate.Format("2006-01-02"),
+		EndDate:     config.EndDate,
+		Profile:     profile,
+		Region:      region,
+		Owner:       common.CurrentUser(),
+	}
+	if checkpoint.Mode == benchmark.PauseStop {
+		paused.InstanceID = checkpoint.InstanceID
+	}
+	return state.NewPausedRuns(store).Add(paused)
+}
+
+// finishResume forgets the paused run a run resumed, once it has finished or paused again
+func finishResume(store *state.Store, resumed *state.PausedRun) {
+	if resumed == nil {
+		return
+	}
+	if err := state.NewPausedRuns(store).Remove(resumed.ID); err != nil {
+		fmt.Printf("Warning: could not remove paused run %s: %v\n", resumed.ID, err)
+	}
+}
+
+// pausedCost estimates what a paused run has cost so far
+func pausedCost(config benchmark.Config, result *benchmark.Result) float64 {
+	instance, err := common.LookupInstance(config.InstanceType)
+	if err != nil {
+		return 0
+	}
+	return instance.PricePerHour * result.WallClock.Hours()
+}
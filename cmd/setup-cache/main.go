@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+)
+
+// setup-cache creates ECR pull-through cache rules for the registries Dockerfiles pull base
+// images from, and prints the cache.pull_through settings that make builds use them
+func main() {
+	var (
+		profile      = flag.String("profile", "aws", "AWS profile to use")
+		region       = flag.String("region", "us-west-2", "AWS region (where builds run)")
+		registries   = flag.String("registries", "docker.io,ghcr.io", "Upstream registries to cache: docker.io, ghcr.io, quay.io, public.ecr.aws")
+		dockerSecret = flag.String("dockerhub-secret", "", "Secrets Manager ARN of Docker Hub credentials (secret name must start with ecr-pullthroughcache/)")
+		ghcrSecret   = flag.String("ghcr-secret", "", "Secrets Manager ARN of GitHub Container Registry credentials (secret name must start with ecr-pullthroughcache/)")
+	)
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var selected []string
+	for _, registry := range strings.Split(*registries, ",") {
+		if registry = strings.TrimSpace(registry); registry != "" {
+			selected = append(selected, registry)
+		}
+	}
+	if len(selected) == 0 {
+		log.Fatal("-registries names no registries")
+	}
+
+	b, err := builder.New(ctx, *profile, *region)
+	if err != nil {
+		log.Fatalf("Failed to initialize builder: %v", err)
+	}
+
+	secrets := map[string]string{"docker.io": *dockerSecret, "ghcr.io": *ghcrSecret}
+	caches, err := b.SetupPullThroughCache(ctx, selected, secrets)
+	if err != nil {
+		log.Fatalf("Failed to set up pull-through caches: %v", err)
+	}
+
+	fmt.Println("\nAdd to config/build-matrix.yaml (or pass -pull-through to geoschem-aws image for Docker Hub):")
+	fmt.Println("cache:")
+	fmt.Println("  pull_through:")
+	registriesSorted := make([]string, 0, len(caches))
+	for registry := range caches {
+		registriesSorted = append(registriesSorted, registry)
+	}
+	sort.Strings(registriesSorted)
+	for _, registry := range registriesSorted {
+		fmt.Printf("    %s: %q\n", registry, caches[registry])
+	}
+	fmt.Println("\nBuild instance profiles need ecr:BatchImportUpstreamImage and ecr:CreateRepository to fill the caches.")
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/storage"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: storage <command> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  efs create     Create a shared EFS file system for run directories and output\n")
+	fmt.Fprintf(os.Stderr, "  efs show       Show an EFS file system\n")
+	fmt.Fprintf(os.Stderr, "  efs delete     Delete an EFS file system and all data on it\n")
+	fmt.Fprintf(os.Stderr, "  fsx create     Create an FSx for Lustre file system linked to an S3 data repository\n")
+	fmt.Fprintf(os.Stderr, "  fsx show       Show an FSx for Lustre file system and how to mount it\n")
+	fmt.Fprintf(os.Stderr, "  fsx export     Write new and changed files to the data repository\n")
+	fmt.Fprintf(os.Stderr, "  fsx hibernate  Export to the data repository, then delete the file system\n")
+	fmt.Fprintf(os.Stderr, "  fsx delete     Delete an FSx for Lustre file system; files not exported are lost\n")
+	fmt.Fprintf(os.Stderr, "  options        Compare storage options for a given output size\n\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "efs":
+		if len(os.Args) < 3 {
+			usage()
+			os.Exit(1)
+		}
+		runEFS(os.Args[2], os.Args[3:])
+	case "fsx":
+		if len(os.Args) < 3 {
+			usage()
+			os.Exit(1)
+		}
+		runFSx(os.Args[2], os.Args[3:])
+	case "options":
+		runOptions(os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+func runEFS(command string, args []string) {
+	fs := flag.NewFlagSet("efs "+command, flag.ExitOnError)
+	var (
+		profile  = fs.String("profile", "aws", "AWS profile to use")
+		region   = fs.String("region", "us-west-2", "AWS region")
+		name     = fs.String("name", "geoschem-shared", "File system name (create)")
+		subnetID = fs.String("subnet", "", "Subnet for the mount target (create)")
+		sgID     = fs.String("security-group", "", "Security group allowing NFS (TCP 2049) from run instances (create)")
+		id       = fs.String("id", "", "File system ID (show, delete)")
+	)
+	fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Minute)
+	defer cancel()
+
+	manager := storage.NewEFSManager(*profile, *region)
+
+	switch command {
+	case "create":
+		if *subnetID == "" || *sgID == "" {
+			log.Fatal("Both -subnet and -security-group are required")
+		}
+		fileSystem, err := manager.Create(ctx, *name, *subnetID, *sgID)
+		if err != nil {
+			log.Fatalf("Failed to create EFS: %v", err)
+		}
+		fmt.Printf("\nUse it with: run-geoschem -efs %s ...\n", fileSystem.FileSystemID)
+		fmt.Printf("Mount manually with:\n  %s\n", storage.MountCommand(fileSystem.FileSystemID, *region, storage.DefaultEFSMountPath))
+	case "show":
+		if *id == "" {
+			log.Fatal("-id is required")
+		}
+		fileSystem, err := manager.Describe(ctx, *id)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Printf("ID:          %s\n", fileSystem.FileSystemID)
+		fmt.Printf("Name:        %s\n", fileSystem.Name)
+		fmt.Printf("State:       %s\n", fileSystem.LifeCycleState)
+		fmt.Printf("Throughput:  %s\n", fileSystem.ThroughputMode)
+		fmt.Printf("Size:        %.1f GB\n", float64(fileSystem.SizeInBytes.Value)/1e9)
+	case "delete":
+		if *id == "" {
+			log.Fatal("-id is required")
+		}
+		fmt.Printf("🗑️  Deleting EFS %s and all data on it...\n", *id)
+		if err := manager.Delete(ctx, *id); err != nil {
+			log.Fatalf("Failed to delete EFS: %v", err)
+		}
+		fmt.Printf("✅ Deleted %s\n", *id)
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+func runFSx(command string, args []string) {
+	fs := flag.NewFlagSet("fsx "+command, flag.ExitOnError)
+	var (
+		profile    = fs.String("profile", "aws", "AWS profile to use")
+		region     = fs.String("region", "us-west-2", "AWS region")
+		name       = fs.String("name", "geoschem-lustre", "File system name (create)")
+		subnetID   = fs.String("subnet", "", "Subnet for the file system, in the AZ of the run instances (create)")
+		sgID       = fs.String("security-group", "", "Security group allowing Lustre (TCP 988, 1018-1023) between its members (create)")
+		repository = fs.String("data-repository", "", "s3:// prefix to link, e.g. inputs staged with 'data stage' (create)")
+		deployment = fs.String("deployment-type", common.FSxScratch, "SCRATCH_2 or PERSISTENT_2 (create)")
+		storageGiB = fs.Int("storage-gib", 1200, "Capacity: 1200 or a multiple of 2400 (create)")
+		throughput = fs.Int("throughput-per-tib", 0, "PERSISTENT_2 MB/s per TiB: 125, 250, 500 or 1000 (create)")
+		id         = fs.String("id", "", "File system ID (show, export, hibernate, delete)")
+	)
+	fs.Parse(args)
+
+	// Exports of large run directories take a while
+	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+	defer cancel()
+
+	manager := storage.NewFSxManager(*profile, *region)
+
+	switch command {
+	case "create":
+		if *subnetID == "" || *sgID == "" || *repository == "" {
+			log.Fatal("-subnet, -security-group and -data-repository are required")
+		}
+		config := common.FSxConfig{DataRepository: *repository, DeploymentType: *deployment, StorageGiB: *storageGiB, ThroughputPerTiB: *throughput}
+		if err := config.Validate(); err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Printf("💰 ~$%.2f per hour until it's deleted\n", config.HourlyCost())
+		fileSystem, err := manager.Create(ctx, *name, *subnetID, *sgID, config)
+		if err != nil {
+			log.Fatalf("Failed to create FSx: %v", err)
+		}
+		fmt.Printf("\nUse it with: run-geoschem -fsx %s ... (or runs.fsx.file_system_id)\n", fileSystem.FileSystemID)
+		fmt.Printf("Mount it on run instances, or Batch hosts in their launch template's user data, with:\n  %s\n",
+			storage.LustreMountCommand(fileSystem, storage.DefaultFSxMountPath))
+	case "show":
+		if *id == "" {
+			log.Fatal("-id is required")
+		}
+		fileSystem, err := manager.Describe(ctx, *id)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Printf("ID:          %s\n", fileSystem.FileSystemID)
+		fmt.Printf("Name:        %s\n", fileSystem.Name())
+		fmt.Printf("State:       %s\n", fileSystem.Lifecycle)
+		fmt.Printf("Deployment:  %s\n", fileSystem.LustreConfiguration.DeploymentType)
+		fmt.Printf("Capacity:    %d GiB\n", fileSystem.StorageCapacity)
+		if importPath := fileSystem.LustreConfiguration.DataRepositoryConfiguration.ImportPath; importPath != "" {
+			fmt.Printf("Repository:  %s\n", importPath)
+		}
+		fmt.Printf("Mount with:  %s\n", storage.LustreMountCommand(fileSystem, storage.DefaultFSxMountPath))
+	case "export":
+		if *id == "" {
+			log.Fatal("-id is required")
+		}
+		if err := manager.Export(ctx, *id); err != nil {
+			log.Fatalf("Failed to export FSx: %v", err)
+		}
+		fmt.Printf("✅ Exported %s\n", *id)
+	case "hibernate":
+		if *id == "" {
+			log.Fatal("-id is required")
+		}
+		if err := manager.Hibernate(ctx, *id); err != nil {
+			log.Fatalf("Failed to hibernate FSx: %v", err)
+		}
+		fmt.Printf("✅ Exported and deleted %s; 'fsx create' with the same -data-repository brings the files back\n", *id)
+	case "delete":
+		if *id == "" {
+			log.Fatal("-id is required")
+		}
+		fmt.Printf("🗑️  Deleting FSx %s; files not exported to its repository are lost...\n", *id)
+		if err := manager.Delete(ctx, *id); err != nil {
+			log.Fatalf("Failed to delete FSx: %v", err)
+		}
+		fmt.Printf("✅ Deleting %s\n", *id)
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+func runOptions(args []string) {
+	fs := flag.NewFlagSet("options", flag.ExitOnError)
+	var (
+		outputGB = fs.Float64("output-gb", 50, "Expected run output size in GB")
+		maxNodes = fs.Int("max-nodes", 1, "Instances sharing the run directory")
+	)
+	fs.Parse(args)
+
+	profile := common.WorkloadProfile{Mode: "classic"}
+	if *maxNodes > 1 {
+		profile = common.WorkloadProfile{Mode: "gchp", MaxNodes: *maxNodes}
+	}
+	fmt.Print(common.FormatStorageTradeoffs(profile, *outputGB))
+}
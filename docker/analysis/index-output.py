@@ -0,0 +1,93 @@
+#!/usr/bin/env python
+"""Build kerchunk reference indexes over GeosChem NetCDF output in S3.
+
+Usage: geoschem-index-output s3://bucket/run-output [--workers N]
+
+Writes one combined reference file per diagnostic collection to
+<output>/kerchunk/<collection>.json, concatenated along time, plus
+<output>/kerchunk/index.json listing them. Only HDF5 metadata is read, so
+indexing costs a few range requests per file rather than a full download.
+
+Open a collection lazily with:
+
+    xr.open_dataset("reference://", engine="zarr", backend_kwargs={
+        "consolidated": False,
+        "storage_options": {"fo": "s3://.../kerchunk/SpeciesConc.json",
+                            "remote_protocol": "s3"}})
+"""
+
+import argparse
+import json
+import sys
+from collections import defaultdict
+from concurrent.futures import ThreadPoolExecutor
+
+import fsspec
+from kerchunk.combine import MultiZarrToZarr
+from kerchunk.hdf import SingleHdf5ToZarr
+
+INDEX_DIR = "kerchunk"
+
+# Coordinates that are the same in every file of a collection
+IDENTICAL_DIMS = ["lat", "lon", "lev", "ilev", "lat_bnds", "lon_bnds", "hyam", "hybm", "hyai", "hybi", "P0", "AREA"]
+
+
+def collection_of(path):
+    """GEOSChem.SpeciesConc.20190701_0000z.nc4 -> SpeciesConc"""
+    parts = path.rsplit("/", 1)[-1].split(".")
+    if len(parts) >= 4 and parts[0] in ("GEOSChem", "GCHP"):
+        return parts[1]
+    return None
+
+
+def translate(fs, path):
+    with fs.open(path, "rb") as f:
+        # Small variables are inlined so opening a dataset needs fewer requests
+        return SingleHdf5ToZarr(f, "s3://" + path, inline_threshold=300).translate()
+
+
+def main():
+    parser = argparse.ArgumentParser(description=__doc__.split("\n")[0])
+    parser.add_argument("output", help="S3 URI of the run output")
+    parser.add_argument("--workers", type=int, default=16, help="Files translated in parallel")
+    args = parser.parse_args()
+
+    output = args.output.rstrip("/")
+    if not output.startswith("s3://"):
+        sys.exit("output must be an s3:// URI")
+
+    fs = fsspec.filesystem("s3")
+    collections = defaultdict(list)
+    for path in fs.find(output[len("s3://"):]):
+        if path.endswith((".nc4", ".nc")) and collection_of(path):
+            collections[collection_of(path)].append(path)
+    if not collections:
+        sys.exit(f"no GeosChem diagnostic files under {output}")
+
+    index = {}
+    with ThreadPoolExecutor(max_workers=args.workers) as pool:
+        for collection, paths in sorted(collections.items()):
+            paths.sort()
+            refs = list(pool.map(lambda p: translate(fs, p), paths))
+            if len(refs) == 1:
+                combined = refs[0]
+            else:
+                combined = MultiZarrToZarr(
+                    refs,
+                    remote_protocol="s3",
+                    concat_dims=["time"],
+                    identical_dims=IDENTICAL_DIMS,
+                ).translate()
+
+            target = f"{output}/{INDEX_DIR}/{collection}.json"
+            with fs.open(target[len("s3://"):], "w") as f:
+                json.dump(combined, f)
+            index[collection] = {"references": target, "files": len(paths)}
+            print(f"indexed {collection}: {len(paths)} files -> {target}", flush=True)
+
+    with fs.open(f"{output[len('s3://'):]}/{INDEX_DIR}/index.json", "w") as f:
+        json.dump(index, f, indent=2)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,102 @@
+#!/usr/bin/env python
+"""Reduce GeosChem output in S3 to tabular diagnostics for Athena.
+
+Usage: geoschem-tabulate-output s3://bucket/run-output s3://lake/diagnostics/<run-id>.json
+           --run-id ID [--species SpeciesConc_O3,SpeciesConc_CO]
+
+Writes newline-delimited JSON rows (run_id, kind, collection, variable, time,
+value, units):
+
+  timeseries  area-weighted surface global mean of each selected species
+  budget      global total of every Budget* variable in the Budget collection
+"""
+
+import argparse
+import json
+import sys
+
+import fsspec
+import numpy as np
+import xarray as xr
+
+DEFAULT_SPECIES = "SpeciesConc_O3,SpeciesConc_CO"
+
+
+def collection_files(fs, output, collection):
+    prefix = f"GEOSChem.{collection}."
+    return sorted(p for p in fs.find(output[len("s3://"):]) if p.rsplit("/", 1)[-1].startswith(prefix))
+
+
+def open_files(fs, paths):
+    return xr.open_mfdataset([fs.open(p) for p in paths], engine="h5netcdf", combine="by_coords")
+
+
+def area_weights(ds):
+    if "AREA" in ds:
+        return ds["AREA"]
+    # Files written without AREA still have regular lat-lon grids
+    return np.cos(np.deg2rad(ds["lat"])) * xr.ones_like(ds["lon"])
+
+
+def rows_for(ds, run_id, kind, collection, variables, reduce):
+    for name in variables:
+        series = reduce(ds[name]).compute()
+        units = ds[name].attrs.get("units", "")
+        for time, value in zip(series["time"].values, series.values):
+            yield {
+                "run_id": run_id,
+                "kind": kind,
+                "collection": collection,
+                "variable": name,
+                "time": np.datetime_as_string(time, unit="s") + "Z",
+                "value": float(value),
+                "units": units,
+            }
+
+
+def main():
+    parser = argparse.ArgumentParser(description=__doc__.split("\n")[0])
+    parser.add_argument("output", help="S3 URI of the run output")
+    parser.add_argument("dest", help="S3 URI of the JSON lines file to write")
+    parser.add_argument("--run-id", required=True, help="Catalog ID stored in every row")
+    parser.add_argument("--species", default=DEFAULT_SPECIES, help="Comma-separated SpeciesConc variables")
+    args = parser.parse_args()
+
+    output = args.output.rstrip("/")
+    fs = fsspec.filesystem("s3")
+    rows = []
+
+    paths = collection_files(fs, output, "SpeciesConc")
+    if paths:
+        ds = open_files(fs, paths)
+        weights = area_weights(ds)
+        species = [s for s in args.species.split(",") if s in ds]
+
+        def surface_mean(da):
+            if "lev" in da.dims:
+                da = da.isel(lev=0)
+            return da.weighted(weights).mean(("lat", "lon"))
+
+        rows.extend(rows_for(ds, args.run_id, "timeseries", "SpeciesConc", species, surface_mean))
+
+    paths = collection_files(fs, output, "Budget")
+    if paths:
+        ds = open_files(fs, paths)
+        budgets = [name for name in ds.data_vars if name.startswith("Budget")]
+
+        def global_total(da):
+            return da.sum([d for d in da.dims if d != "time"])
+
+        rows.extend(rows_for(ds, args.run_id, "budget", "Budget", budgets, global_total))
+
+    if not rows:
+        sys.exit(f"no SpeciesConc or Budget output under {output}")
+
+    with fs.open(args.dest[len("s3://"):], "w") as f:
+        for row in rows:
+            f.write(json.dumps(row) + "\n")
+    print(f"wrote {len(rows)} rows to {args.dest}", flush=True)
+
+
+if __name__ == "__main__":
+    main()
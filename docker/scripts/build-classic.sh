@@ -0,0 +1,37 @@
+#!/bin/bash
+# Build GEOS-Chem Classic from /opt/geoschem/source/geoschem into /opt/geoschem/classic/bin
+# against the Spack library stack of the dependencies image
+# Usage: build-classic.sh <gcc|arm|aocc> [mpi]
+
+set -euo pipefail
+
+COMPILER="${1:-gcc}"
+SOURCE_DIR=/opt/geoschem/source/geoschem
+BUILD_DIR=/opt/geoschem/classic/build
+INSTALL_DIR=/opt/geoschem/classic/bin
+
+source /opt/spack/share/spack/setup-env.sh
+
+# Compilers other than the system GCC were installed by Spack in the dependencies image
+case "$COMPILER" in
+    gcc) export CC=gcc CXX=g++ FC=gfortran ;;
+    arm) spack load acfl; export CC=armclang CXX=armclang++ FC=armflang ;;
+    aocc) spack load aocc; export CC=clang CXX=clang++ FC=flang ;;
+    *) echo "Error: unknown compiler $COMPILER (expected gcc, arm or aocc)" >&2; exit 1 ;;
+esac
+spack load netcdf-c netcdf-fortran hdf5
+
+# Classic runs on one node with OpenMP; CMake takes FFLAGS and LDFLAGS from the environment,
+# and the installed binary keeps the rpath to the Spack libraries it linked
+cmake -S "$SOURCE_DIR" -B "$BUILD_DIR" \
+    -DCMAKE_BUILD_TYPE=Release \
+    -DCMAKE_INSTALL_RPATH_USE_LINK_PATH=ON \
+    -DOMP=y \
+    -DRUNDIR="$INSTALL_DIR"
+cmake --build "$BUILD_DIR" -j"$(nproc)"
+cmake --install "$BUILD_DIR"
+
+# run-classic.sh runs the model as bin/geoschem
+ln -sf gcclassic "$INSTALL_DIR/geoschem"
+rm -rf "$BUILD_DIR"
+echo "Built GEOS-Chem Classic with $COMPILER in $INSTALL_DIR"
@@ -0,0 +1,47 @@
+#!/bin/bash
+# Build GCHP from /opt/geoschem/source/gchp into /opt/geoschem/gchp/bin against the MPI in
+# /opt/mpi and the Spack library stack (ESMF included) of the dependencies image
+# Usage: build-gchp.sh <gcc|intel> <openmpi|mpich|intelmpi>
+
+set -euo pipefail
+
+COMPILER="${1:-gcc}"
+MPI_IMPLEMENTATION="${2:-openmpi}"
+SOURCE_DIR=/opt/geoschem/source/gchp
+BUILD_DIR=/opt/geoschem/gchp/build
+INSTALL_DIR=/opt/geoschem/gchp/bin
+
+source /opt/spack/share/spack/setup-env.sh
+
+# The oneAPI compilers were installed by Spack in the dependencies image
+case "$COMPILER" in
+    gcc) export CC=gcc CXX=g++ FC=gfortran ;;
+    intel) spack load intel-oneapi-compilers; export CC=icx CXX=icpx FC=ifx ;;
+    *) echo "Error: unknown compiler $COMPILER (expected gcc or intel)" >&2; exit 1 ;;
+esac
+spack load netcdf-c netcdf-fortran hdf5 esmf parallel-netcdf
+
+# GCHP finds ESMF through its esmf.mk, and MPI through the wrappers in /opt/mpi/bin; Intel
+# MPI names the wrappers for its own compilers differently
+ESMFMKFILE="$(find "$(spack location -i esmf)" -name esmf.mk | head -n 1)"
+export ESMFMKFILE
+MPI_OPTIONS=()
+if [[ "$MPI_IMPLEMENTATION" == "intelmpi" && "$COMPILER" == "intel" ]]; then
+    MPI_OPTIONS=(-DMPI_C_COMPILER=mpiicx -DMPI_CXX_COMPILER=mpiicpx -DMPI_Fortran_COMPILER=mpiifx)
+fi
+
+cmake -S "$SOURCE_DIR" -B "$BUILD_DIR" \
+    -DCMAKE_BUILD_TYPE=Release \
+    -DCMAKE_INSTALL_RPATH_USE_LINK_PATH=ON \
+    -DRUNDIR="$INSTALL_DIR" \
+    "${MPI_OPTIONS[@]}"
+cmake --build "$BUILD_DIR" -j"$(nproc)"
+cmake --install "$BUILD_DIR"
+
+# run-gchp.sh runs the model as bin/gchp
+if [[ ! -x "$INSTALL_DIR/gchp" ]]; then
+    echo "Error: the GCHP build didn't install $INSTALL_DIR/gchp" >&2
+    exit 1
+fi
+rm -rf "$BUILD_DIR"
+echo "Built GCHP with $COMPILER and $MPI_IMPLEMENTATION in $INSTALL_DIR"
@@ -1,5 +1,5 @@
 #!/bin/bash
-# GeosChem Container Entrypoint - Supports both Classic and GCHP modes
+# GeosChem Container Entrypoint - Classic and GCHP modes, for the model the image was built with
 
 set -e
 
@@ -34,9 +34,25 @@ print_usage() {
     echo "  --output-dir DIR      Output directory (default: /workspace/output)"
     echo "  --start-date DATE     Start date (YYYY-MM-DD)"
     echo "  --end-date DATE       End date (YYYY-MM-DD)"
+    echo "  --omp-threads N       OpenMP threads per process (default: \$OMP_NUM_THREADS or all vCPUs)"
+    echo "  --omp-stacksize SIZE  OpenMP per-thread stack size (default: \$OMP_STACKSIZE or 500m)"
+    echo "  --restart-file FILE   Initial restart file (default: template restart)"
+    echo "  --checkpoint-frequency daily|monthly"
+    echo "                        Write restart files this often (default: the template's setting)"
+    echo "  --emissions-year YEAR Read emissions for this year whatever the met year (default: the simulation year)"
+    echo "  --hemco-overrides JSON"
+    echo "                        Scale factors, inventories and masks merged into HEMCO_Config.rc"
+    echo "  --run-dir DIR         Run directory to copy instead of the image's template (Classic only)"
     echo "  --dry-run             Show commands without executing"
     echo "  --debug               Enable debug output"
     echo ""
+    echo "Environment (for schedulers that can't mount host directories):"
+    echo "  GEOSCHEM_DATA_SOURCE  S3 URI synced into the data directory before the run"
+    echo "  GEOSCHEM_DATA_SYNC_FILTERS"
+    echo "                        aws s3 sync --exclude/--include filters limiting what is staged"
+    echo "  GEOSCHEM_RESTART_URI  S3 URI of the initial restart file"
+    echo "  GEOSCHEM_OUTPUT_URI   S3 URI the output directory is copied to after the run"
+    echo ""
     echo "Examples:"
     echo "  # Classic 4x5 full chemistry simulation"  
     echo "  $0 classic --simulation fullchem --resolution 4x5"
@@ -88,6 +104,34 @@ while [[ $# -gt 0 ]]; do
             END_DATE="$2"
             shift 2
             ;;
+        --omp-threads)
+            OMP_THREADS_ARG="$2"
+            shift 2
+            ;;
+        --omp-stacksize)
+            OMP_STACKSIZE_ARG="$2"
+            shift 2
+            ;;
+        --restart-file)
+            RESTART_FILE="$2"
+            shift 2
+            ;;
+        --checkpoint-frequency)
+            CHECKPOINT_FREQUENCY="$2"
+            shift 2
+            ;;
+        --emissions-year)
+            EMISSIONS_YEAR="$2"
+            shift 2
+            ;;
+        --hemco-overrides)
+            HEMCO_OVERRIDES="$2"
+            shift 2
+            ;;
+        --run-dir)
+            SOURCE_RUN_DIR="$2"
+            shift 2
+            ;;
         --dry-run)
             DRY_RUN=1
             shift
@@ -140,6 +184,12 @@ if [[ -z "$RESOLUTION" ]]; then
     exit 1
 fi
 
+# Images build one model (GEOSCHEM_MODEL)
+if [[ "$MODE" == "classic" || "$MODE" == "gchp" ]] && [[ -d "${GEOSCHEM_ROOT:-/opt/geoschem}" && ! -d "${GEOSCHEM_ROOT:-/opt/geoschem}/$MODE" ]]; then
+    echo "Error: this image was built for $(ls "${GEOSCHEM_ROOT:-/opt/geoschem}" | grep -x -E 'classic|gchp' || echo 'another model'), not $MODE"
+    exit 1
+fi
+
 # Mode-specific validation
 if [[ "$MODE" == "gchp" && -z "$CORES" ]]; then
     echo "Error: GCHP mode requires --cores specification"
@@ -147,10 +197,46 @@ if [[ "$MODE" == "gchp" && -z "$CORES" ]]; then
     exit 1
 fi
 
+if [[ "$MODE" == "gchp" && -n "$SOURCE_RUN_DIR" ]]; then
+    echo "Error: --run-dir is only supported in classic mode"
+    exit 1
+fi
+
 # Set default directories if not specified
 DATA_DIR="${DATA_DIR:-/workspace/data}"
 OUTPUT_DIR="${OUTPUT_DIR:-/workspace/output}"
 
+# Configure OpenMP from the instance topology
+VCPUS=$(nproc)
+if [[ -n "$OMP_THREADS_ARG" ]]; then
+    OMP_NUM_THREADS="$OMP_THREADS_ARG"
+elif [[ -z "$OMP_NUM_THREADS" || "$OMP_NUM_THREADS" == "0" ]]; then
+    if [[ "$MODE" == "gchp" ]]; then
+        OMP_NUM_THREADS=1
+    else
+        OMP_NUM_THREADS="$VCPUS"
+    fi
+fi
+export OMP_NUM_THREADS
+export OMP_STACKSIZE="${OMP_STACKSIZE_ARG:-${OMP_STACKSIZE:-500m}}"
+export OMP_PROC_BIND="${OMP_PROC_BIND:-true}"
+export OMP_PLACES="${OMP_PLACES:-cores}"
+ulimit -s unlimited 2>/dev/null || echo "Warning: could not raise stack limit, large runs may segfault"
+
+# Warn when threads x processes doesn't match the available vCPUs
+if ! [[ "$OMP_NUM_THREADS" =~ ^[0-9]+$ ]] || [[ "$OMP_NUM_THREADS" -lt 1 ]]; then
+    echo "Error: OMP_NUM_THREADS must be a positive integer, got '$OMP_NUM_THREADS'"
+    exit 1
+fi
+PROCS=1
+[[ "$MODE" == "gchp" ]] && PROCS="$CORES"
+TOTAL_THREADS=$((PROCS * OMP_NUM_THREADS))
+if [[ "$TOTAL_THREADS" -gt "$VCPUS" ]]; then
+    echo "Warning: $PROCS process(es) x $OMP_NUM_THREADS thread(s) = $TOTAL_THREADS exceeds $VCPUS vCPUs (oversubscribed)"
+elif [[ "$TOTAL_THREADS" -lt $((VCPUS / 2)) ]]; then
+    echo "Warning: $PROCS process(es) x $OMP_NUM_THREADS thread(s) = $TOTAL_THREADS uses less than half of $VCPUS vCPUs"
+fi
+
 # Create output directories
 mkdir -p "$OUTPUT_DIR" "$CONFIG_DIR"
 
@@ -164,36 +250,70 @@ echo "Mode: $MODE"
 echo "Simulation: $SIMULATION"
 echo "Resolution: $RESOLUTION"
 [[ "$MODE" == "gchp" ]] && echo "Cores: $CORES"
+echo "OpenMP: $OMP_NUM_THREADS threads, stack $OMP_STACKSIZE, bind $OMP_PROC_BIND ($VCPUS vCPUs)"
 echo "Config: $CONFIG_DIR"
 echo "Data: $DATA_DIR"
 echo "Output: $OUTPUT_DIR"
 echo "================================================"
 
-# Execute the appropriate runner
+# Stage inputs from S3 when a scheduler passes them in the environment (Batch, Slurm, Kubernetes)
+if [[ -n "$GEOSCHEM_DATA_SOURCE" ]]; then
+    echo "Staging input data from $GEOSCHEM_DATA_SOURCE"
+    mkdir -p "$DATA_DIR"
+    # Filters are whitespace-separated; read -a splits them without expanding the wildcards
+    read -ra SYNC_FILTERS <<< "${GEOSCHEM_DATA_SYNC_FILTERS:-}"
+    aws s3 sync --only-show-errors --no-sign-request "${SYNC_FILTERS[@]}" "$GEOSCHEM_DATA_SOURCE" "$DATA_DIR"
+fi
+if [[ -n "$GEOSCHEM_RESTART_URI" ]]; then
+    echo "Fetching restart file $GEOSCHEM_RESTART_URI"
+    mkdir -p /workspace/restart
+    aws s3 cp --only-show-errors "$GEOSCHEM_RESTART_URI" /workspace/restart/restart.nc4
+    # Restarts written by earlier images have no summary
+    aws s3 cp --only-show-errors "${GEOSCHEM_RESTART_URI}.json" /workspace/restart/restart.nc4.json 2>/dev/null || true
+    RESTART_FILE=/workspace/restart/restart.nc4
+fi
+if [[ -n "$RESTART_FILE" && -f "${RESTART_FILE}.json" ]]; then
+    python3 /usr/local/bin/restart-summary.py --verify "$RESTART_FILE" "${RESTART_FILE}.json" || exit 1
+fi
+
+# Select the appropriate runner
 if [[ "$MODE" == "classic" ]]; then
-    exec /usr/local/bin/run-classic.sh \
-        --simulation "$SIMULATION" \
-        --resolution "$RESOLUTION" \
-        --config-dir "$CONFIG_DIR" \
-        --data-dir "$DATA_DIR" \
-        --output-dir "$OUTPUT_DIR" \
-        ${START_DATE:+--start-date "$START_DATE"} \
-        ${END_DATE:+--end-date "$END_DATE"} \
-        ${DRY_RUN:+--dry-run}
-        
+    RUNNER=(/usr/local/bin/run-classic.sh
+        --simulation "$SIMULATION"
+        --resolution "$RESOLUTION"
+        ${SOURCE_RUN_DIR:+--run-dir "$SOURCE_RUN_DIR"})
 elif [[ "$MODE" == "gchp" ]]; then
-    exec /usr/local/bin/run-gchp.sh \
-        --simulation "$SIMULATION" \
-        --resolution "$RESOLUTION" \
-        --cores "$CORES" \
-        --config-dir "$CONFIG_DIR" \
-        --data-dir "$DATA_DIR" \
-        --output-dir "$OUTPUT_DIR" \
-        ${START_DATE:+--start-date "$START_DATE"} \
-        ${END_DATE:+--end-date "$END_DATE"} \
-        ${DRY_RUN:+--dry-run}
-        
+    RUNNER=(/usr/local/bin/run-gchp.sh
+        --simulation "$SIMULATION"
+        --resolution "$RESOLUTION"
+        --cores "$CORES")
 else
     echo "Error: Unknown mode '$MODE'"
     exit 1
-fi
\ No newline at end of file
+fi
+RUNNER+=(--config-dir "$CONFIG_DIR"
+    --data-dir "$DATA_DIR"
+    --output-dir "$OUTPUT_DIR"
+    ${START_DATE:+--start-date "$START_DATE"}
+    ${END_DATE:+--end-date "$END_DATE"}
+    ${RESTART_FILE:+--restart-file "$RESTART_FILE"}
+    ${CHECKPOINT_FREQUENCY:+--checkpoint-frequency "$CHECKPOINT_FREQUENCY"}
+    ${EMISSIONS_YEAR:+--emissions-year "$EMISSIONS_YEAR"}
+    ${HEMCO_OVERRIDES:+--hemco-overrides "$HEMCO_OVERRIDES"}
+    ${DRY_RUN:+--dry-run})
+
+STATUS=0
+"${RUNNER[@]}" || STATUS=$?
+
+# Summarize the restart files written (variables, time steps, checksum); chunked runs
+# check each boundary restart against the previous one's summary
+if [[ -z "$DRY_RUN" ]]; then
+    python3 /usr/local/bin/restart-summary.py "$OUTPUT_DIR" || echo "Warning: failed to summarize restart files"
+fi
+
+# Copy the output (including logs of a failed run) before the container goes away
+if [[ -n "$GEOSCHEM_OUTPUT_URI" ]]; then
+    echo "Copying output to $GEOSCHEM_OUTPUT_URI"
+    aws s3 sync --only-show-errors "$OUTPUT_DIR" "$GEOSCHEM_OUTPUT_URI" || echo "Warning: failed to copy output to $GEOSCHEM_OUTPUT_URI"
+fi
+exit $STATUS
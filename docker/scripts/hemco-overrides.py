@@ -0,0 +1,102 @@
+#!/usr/bin/env python3
+"""Merge emissions overrides into a run directory's HEMCO_Config.rc.
+
+Usage: hemco-overrides.py HEMCO_Config.rc HEMCO_DATA_DIR OVERRIDES_JSON
+
+Each override names base emissions containers (a glob) and adds a constant scale factor,
+replaces the file they read, or adds a regional mask. New scale factors and masks get IDs
+above those already in the file. Every file an override reads must be in the staged data.
+"""
+import fnmatch
+import glob
+import json
+import os
+import re
+import sys
+
+
+def section(lines, name):
+    begin = end = None
+    for i, line in enumerate(lines):
+        if re.match(r"#+\s*BEGIN SECTION " + name + r"\b", line):
+            begin = i
+        elif re.match(r"#+\s*END SECTION " + name + r"\b", line):
+            end = i
+    if begin is None or end is None:
+        sys.exit("Error: HEMCO_Config.rc has no %s section" % name)
+    return begin, end
+
+
+def staged(data_dir, file):
+    # HEMCO tokens ($YYYY, $MM, ...) match any value
+    return bool(glob.glob(os.path.join(data_dir, re.sub(r"\$[A-Z]+", "*", file))))
+
+
+def main():
+    if len(sys.argv) != 4:
+        sys.exit(__doc__)
+    config_path, data_dir, overrides = sys.argv[1], sys.argv[2], json.loads(sys.argv[3])
+
+    missing = []
+    for override in overrides:
+        for file in (override.get("file"), (override.get("mask") or {}).get("file")):
+            if file and not staged(data_dir, file):
+                missing.append(file)
+    if missing:
+        sys.exit("Error: emissions override files not in %s: %s" % (data_dir, ", ".join(missing)))
+
+    with open(config_path) as f:
+        lines = f.read().split("\n")
+
+    ids = []
+    for name in ("SCALE FACTORS", "MASKS"):
+        begin, end = section(lines, name)
+        ids += [int(line.split()[0]) for line in lines[begin:end] if re.match(r"\s*\d+\s", line)]
+    next_id = max(ids + [0]) + 1
+
+    scale_lines, mask_lines = [], []
+    base_begin, base_end = section(lines, "BASE EMISSIONS")
+    for n, override in enumerate(overrides, 1):
+        added = []
+        if override.get("scale") is not None:
+            scale_lines.append("%d OVERRIDE_SCALE_%d %s - - - xy 1 1" % (next_id, n, override["scale"]))
+            added.append(next_id)
+            next_id += 1
+        mask = override.get("mask")
+        if mask:
+            mask_lines.append("%d OVERRIDE_MASK_%d $ROOT/%s %s 2000/1/1/0 C xy 1 1 %s" % (
+                next_id, n, mask["file"], mask["variable"], mask["box"]))
+            added.append(next_id)
+            next_id += 1
+
+        matched = 0
+        for i in range(base_begin + 1, base_end):
+            fields = lines[i].split()
+            # ExtNr Name sourceFile sourceVar sourceTime CRE SrcDim SrcUnit Species ScalIDs Cat Hier
+            if len(fields) < 12 or not fields[0].isdigit() or not fnmatch.fnmatchcase(fields[1], override["inventory"]):
+                continue
+            matched += 1
+            if override.get("file"):
+                fields[2] = "$ROOT/" + override["file"]
+                if override.get("variable"):
+                    fields[3] = override["variable"]
+            if added:
+                scale_ids = [] if fields[9] == "-" else fields[9].split("/")
+                fields[9] = "/".join(scale_ids + [str(scale_id) for scale_id in added])
+            lines[i] = " ".join(fields)
+        if matched == 0:
+            sys.exit("Error: no base emissions in HEMCO_Config.rc match %s" % override["inventory"])
+        print("Emissions override %s: %d container(s)" % (override["inventory"], matched))
+
+    # Insert from the end of the file so earlier section positions stay valid
+    for name, added_lines in sorted((("SCALE FACTORS", scale_lines), ("MASKS", mask_lines)),
+                                    key=lambda s: section(lines, s[0])[1], reverse=True):
+        end = section(lines, name)[1]
+        lines[end:end] = added_lines
+
+    with open(config_path, "w") as f:
+        f.write("\n".join(lines))
+
+
+if __name__ == "__main__":
+    main()
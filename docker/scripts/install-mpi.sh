@@ -0,0 +1,82 @@
+#!/bin/bash
+# Install the MPI implementation into /opt/mpi and register it with Spack as an external,
+# so the Spack library stack links it instead of building a second MPI
+# Usage: install-mpi.sh <openmpi|mpich|intelmpi> [version] [x86_64|arm64]
+
+set -euo pipefail
+
+MPI_IMPLEMENTATION="${1:-openmpi}"
+MPI_VERSION="${2:-}"
+ARCHITECTURE="${3:-x86_64}"
+PREFIX=/opt/mpi
+
+# Build a release tarball into PREFIX
+build_from_source() {
+    local url="$1"
+    shift
+    local src=/tmp/mpi-source
+    mkdir -p "$src"
+    curl -fsSL "$url" | tar -xz -C "$src" --strip-components=1
+    (cd "$src" && ./configure --prefix="$PREFIX" "$@" && make -j"$(nproc)" && make install)
+    rm -rf "$src"
+}
+
+case "$MPI_IMPLEMENTATION" in
+    openmpi)
+        MPI_VERSION="${MPI_VERSION:-5.0.1}"
+        SPACK_PACKAGE=openmpi
+        SPACK_PREFIX="$PREFIX"
+        # libfabric from the base image carries MPI traffic over EFA
+        build_from_source "https://download.open-mpi.org/release/open-mpi/v${MPI_VERSION%.*}/openmpi-${MPI_VERSION}.tar.gz" \
+            --with-ofi=/usr --enable-mpi-fortran --disable-static
+        ;;
+    mpich)
+        MPI_VERSION="${MPI_VERSION:-4.1.2}"
+        SPACK_PACKAGE=mpich
+        SPACK_PREFIX="$PREFIX"
+        build_from_source "https://www.mpich.org/static/downloads/${MPI_VERSION}/mpich-${MPI_VERSION}.tar.gz" \
+            --with-device=ch4:ofi --with-libfabric=/usr --enable-fortran --disable-static
+        ;;
+    intelmpi)
+        if [[ "$ARCHITECTURE" != "x86_64" ]]; then
+            echo "Error: Intel MPI is only available on x86_64, not $ARCHITECTURE" >&2
+            exit 1
+        fi
+        MPI_VERSION="${MPI_VERSION:-2021.10.0}"
+        SPACK_PACKAGE=intel-oneapi-mpi
+        SPACK_PREFIX=/opt/intel/oneapi
+        cat > /etc/yum.repos.d/oneAPI.repo << 'EOF'
+[oneAPI]
+name=Intel oneAPI repository
+baseurl=https://yum.repos.intel.com/oneapi
+enabled=1
+gpgcheck=1
+repo_gpgcheck=1
+gpgkey=https://yum.repos.intel.com/intel-gpg-keys/GPG-PUB-KEY-INTEL-SW-PRODUCTS.PUB
+EOF
+        dnf install -y "intel-oneapi-mpi-devel-${MPI_VERSION}" && dnf clean all
+        # The same /opt/mpi/bin and /opt/mpi/lib paths as the MPIs built from source
+        ln -s "/opt/intel/oneapi/mpi/${MPI_VERSION}" "$PREFIX"
+        ;;
+    *)
+        echo "Error: unknown MPI implementation $MPI_IMPLEMENTATION (expected openmpi, mpich or intelmpi)" >&2
+        exit 1
+        ;;
+esac
+
+# Spack reads /etc/spack as its system scope, beneath the site packages.yaml copied in later;
+# requiring the provider keeps the stack on this MPI whatever the site's provider order
+mkdir -p /etc/spack
+cat > /etc/spack/packages.yaml << EOF
+packages:
+  mpi:
+    require: "${SPACK_PACKAGE}"
+  ${SPACK_PACKAGE}:
+    buildable: false
+    externals:
+    - spec: ${SPACK_PACKAGE}@${MPI_VERSION}
+      prefix: ${SPACK_PREFIX}
+EOF
+
+"$PREFIX/bin/mpirun" --version
+echo "Installed $MPI_IMPLEMENTATION $MPI_VERSION in $PREFIX"
@@ -0,0 +1,65 @@
+#!/usr/bin/env python3
+"""Summarize GeosChem restart files, or check a restart file against its summary.
+
+Usage: restart-summary.py OUTPUT_DIR
+       restart-summary.py --verify RESTART_FILE SUMMARY_JSON
+
+The summary of each GEOSChem.Restart.*.nc4 under OUTPUT_DIR is written next to it as
+<file>.json: its size, SHA-256, variable names and time steps. Chunked runs compare a
+boundary restart's summary with the previous boundary's, and the next chunk checks the
+restart it downloaded against the checksum before starting from it.
+"""
+import hashlib
+import json
+import os
+import sys
+
+import netCDF4
+
+
+def sha256(path):
+    digest = hashlib.sha256()
+    with open(path, "rb") as f:
+        for block in iter(lambda: f.read(1 << 20), b""):
+            digest.update(block)
+    return digest.hexdigest()
+
+
+def summarize(path):
+    with netCDF4.Dataset(path) as dataset:
+        time = dataset.dimensions.get("time")
+        return {
+            "size": os.path.getsize(path),
+            "sha256": sha256(path),
+            "variables": sorted(dataset.variables),
+            "time_steps": len(time) if time is not None else 0,
+        }
+
+
+def write_summaries(output_dir):
+    for root, _, files in os.walk(output_dir):
+        for name in sorted(files):
+            if not (name.startswith("GEOSChem.Restart.") and name.endswith(".nc4")):
+                continue
+            path = os.path.join(root, name)
+            with open(path + ".json", "w") as f:
+                json.dump(summarize(path), f)
+            print("Summarized restart file %s" % path)
+
+
+def verify(path, summary_path):
+    with open(summary_path) as f:
+        summary = json.load(f)
+    actual = sha256(path)
+    if actual != summary["sha256"]:
+        sys.exit("Error: restart file %s has SHA-256 %s, but its summary records %s" % (path, actual, summary["sha256"]))
+    print("Restart file checksum matches its summary")
+
+
+if __name__ == "__main__":
+    if len(sys.argv) == 4 and sys.argv[1] == "--verify":
+        verify(sys.argv[2], sys.argv[3])
+    elif len(sys.argv) == 2:
+        write_summaries(sys.argv[1])
+    else:
+        sys.exit(__doc__)
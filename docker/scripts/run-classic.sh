@@ -11,6 +11,11 @@ OUTPUT_DIR="/workspace/output"
 START_DATE=""
 END_DATE=""
 DRY_RUN=""
+RESTART_FILE=""
+CHECKPOINT_FREQUENCY=""
+EMISSIONS_YEAR=""
+HEMCO_OVERRIDES=""
+SOURCE_RUN_DIR=""
 
 # Parse arguments (passed from entrypoint)
 while [[ $# -gt 0 ]]; do
@@ -22,6 +27,11 @@ while [[ $# -gt 0 ]]; do
         --output-dir) OUTPUT_DIR="$2"; shift 2;;
         --start-date) START_DATE="$2"; shift 2;;
         --end-date) END_DATE="$2"; shift 2;;
+        --restart-file) RESTART_FILE="$2"; shift 2;;
+        --checkpoint-frequency) CHECKPOINT_FREQUENCY="$2"; shift 2;;
+        --emissions-year) EMISSIONS_YEAR="$2"; shift 2;;
+        --hemco-overrides) HEMCO_OVERRIDES="$2"; shift 2;;
+        --run-dir) SOURCE_RUN_DIR="$2"; shift 2;;
         --dry-run) DRY_RUN=1; shift;;
         *) echo "Unknown argument: $1"; exit 1;;
     esac
@@ -35,9 +45,16 @@ echo "Resolution: $RESOLUTION"
 RUN_DIR="$OUTPUT_DIR/classic_${SIMULATION}_${RESOLUTION}_$(date +%Y%m%d_%H%M%S)"
 mkdir -p "$RUN_DIR"
 
-# Copy configuration template
+# Copy the given run directory (from generate rundir), or the image's template
 TEMPLATE_DIR="/opt/geoschem/run_templates/${SIMULATION}"
-if [[ -d "$TEMPLATE_DIR" ]]; then
+if [[ -n "$SOURCE_RUN_DIR" ]]; then
+    if [[ ! -f "$SOURCE_RUN_DIR/geoschem_config.yml" ]]; then
+        echo "Error: No geoschem_config.yml in run directory $SOURCE_RUN_DIR"
+        exit 1
+    fi
+    echo "Copying configuration from $SOURCE_RUN_DIR"
+    cp -r "$SOURCE_RUN_DIR"/* "$RUN_DIR/"
+elif [[ -d "$TEMPLATE_DIR" ]]; then
     echo "Copying configuration from $TEMPLATE_DIR"
     cp -r "$TEMPLATE_DIR"/* "$RUN_DIR/"
 else
@@ -54,6 +71,50 @@ fi
 # Update configuration for this resolution/simulation
 cd "$RUN_DIR"
 
+# Write the requested period into the run configuration
+if [[ -f geoschem_config.yml ]]; then
+    if [[ -n "$START_DATE" ]]; then
+        sed -i -E "s/^(\s*start_date:\s*\[)[0-9]+/\1${START_DATE//-/}/" geoschem_config.yml
+    fi
+    if [[ -n "$END_DATE" ]]; then
+        sed -i -E "s/^(\s*end_date:\s*\[)[0-9]+/\1${END_DATE//-/}/" geoschem_config.yml
+    fi
+fi
+
+# Write restart files periodically so a paused run can resume from the latest one
+if [[ -n "$CHECKPOINT_FREQUENCY" && -f HISTORY.rc ]]; then
+    case "$CHECKPOINT_FREQUENCY" in
+        daily) FREQUENCY="00000001 000000";;
+        monthly) FREQUENCY="00000100 000000";;
+        *) echo "Error: Unknown checkpoint frequency: $CHECKPOINT_FREQUENCY"; exit 1;;
+    esac
+    echo "Writing restart files $CHECKPOINT_FREQUENCY"
+    sed -i -E "s/^(\s*Restart\.(frequency|duration):\s*)'[^']*'/\1'${FREQUENCY}'/" HISTORY.rc
+fi
+
+# Hold emissions at one year while the met fields follow the simulation dates
+if [[ -n "$EMISSIONS_YEAR" ]]; then
+    if [[ ! -f HEMCO_Config.rc ]]; then
+        echo "Error: --emissions-year needs HEMCO_Config.rc in the run directory"
+        exit 1
+    fi
+    echo "Reading emissions for $EMISSIONS_YEAR"
+    if grep -qE "^\s*Emission year:" HEMCO_Config.rc; then
+        sed -i -E "s/^(\s*Emission year:\s*).*/\1${EMISSIONS_YEAR}/" HEMCO_Config.rc
+    else
+        sed -i -E "/^#+\s*BEGIN SECTION SETTINGS/a Emission year:               ${EMISSIONS_YEAR}" HEMCO_Config.rc
+    fi
+fi
+
+# Merge scale factors, alternative inventories and masks; their files must be in the staged data
+if [[ -n "$HEMCO_OVERRIDES" ]]; then
+    if [[ ! -f HEMCO_Config.rc ]]; then
+        echo "Error: --hemco-overrides needs HEMCO_Config.rc in the run directory"
+        exit 1
+    fi
+    python3 /usr/local/bin/hemco-overrides.py HEMCO_Config.rc "$DATA_DIR/HEMCO" "$HEMCO_OVERRIDES"
+fi
+
 # Set up data directory links
 if [[ -d "$DATA_DIR" ]]; then
     echo "Linking input data from $DATA_DIR"
@@ -62,8 +123,23 @@ else
     echo "Warning: No input data directory found at $DATA_DIR"
 fi
 
-# Configure for number of OpenMP threads
-export OMP_NUM_THREADS=$(nproc)
+# Use the requested restart file, named as GeosChem Classic expects for the start date
+if [[ -n "$RESTART_FILE" ]]; then
+    if [[ ! -f "$RESTART_FILE" ]]; then
+        echo "Error: Restart file not found: $RESTART_FILE"
+        exit 1
+    fi
+    RESTART_DATE="${START_DATE//-/}"
+    echo "Using restart file $RESTART_FILE"
+    cp "$RESTART_FILE" "GEOSChem.Restart.${RESTART_DATE:-00000000}_0000z.nc4"
+fi
+
+# Configure for number of OpenMP threads (entrypoint sets this from the instance topology).
+# The image's default of 0 means all vCPUs, as it does for the entrypoint.
+if [[ -z "$OMP_NUM_THREADS" || "$OMP_NUM_THREADS" == "0" ]]; then
+    OMP_NUM_THREADS=$(nproc)
+fi
+export OMP_NUM_THREADS
 echo "Using $OMP_NUM_THREADS OpenMP threads"
 
 # Validate that we have the GeosChem executable
@@ -12,6 +12,7 @@ OUTPUT_DIR="/workspace/output"
 START_DATE=""
 END_DATE=""
 DRY_RUN=""
+RESTART_FILE=""
 
 # Parse arguments (passed from entrypoint)
 while [[ $# -gt 0 ]]; do
@@ -24,6 +25,7 @@ while [[ $# -gt 0 ]]; do
         --output-dir) OUTPUT_DIR="$2"; shift 2;;
         --start-date) START_DATE="$2"; shift 2;;
         --end-date) END_DATE="$2"; shift 2;;
+        --restart-file) RESTART_FILE="$2"; shift 2;;
         --dry-run) DRY_RUN=1; shift;;
         *) echo "Unknown argument: $1"; exit 1;;
     esac
@@ -120,6 +122,18 @@ else
     echo "Warning: No input data directory found at $DATA_DIR"
 fi
 
+# Use the requested restart file, named as GCHP expects for the start date and grid
+if [[ -n "$RESTART_FILE" ]]; then
+    if [[ ! -f "$RESTART_FILE" ]]; then
+        echo "Error: Restart file not found: $RESTART_FILE"
+        exit 1
+    fi
+    RESTART_DATE="${START_DATE//-/}"
+    echo "Using restart file $RESTART_FILE"
+    mkdir -p Restarts
+    cp "$RESTART_FILE" "Restarts/GEOSChem.Restart.${RESTART_DATE:-00000000}_0000z.$(echo "$RESOLUTION" | tr '[:upper:]' '[:lower:]').nc4"
+fi
+
 # Configure MPI environment
 export OMPI_ALLOW_RUN_AS_ROOT=1
 export OMPI_ALLOW_RUN_AS_ROOT_CONFIRM=1
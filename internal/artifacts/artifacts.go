@@ -0,0 +1,92 @@
+// Package artifacts removes the temporary files commands create, on the local machine and on
+// build hosts, when they are done with them. -keep-artifacts keeps them for debugging.
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// FlagUsage describes the -keep-artifacts flag the commands share
+const FlagUsage = "Keep temporary files (source checkouts, push logs, kubeconfigs, stale keys) for debugging"
+
+var keep atomic.Bool
+
+// SetKeep keeps temporary files instead of removing them, for the -keep-artifacts flag
+func SetKeep(k bool) {
+	keep.Store(k)
+}
+
+// Kept reports whether temporary files are being kept
+func Kept() bool {
+	return keep.Load()
+}
+
+var (
+	mu    sync.Mutex
+	local = make(map[string]bool)
+)
+
+// Track registers a local temporary file or directory for Cleanup
+func Track(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	local[path] = true
+}
+
+// Remove deletes a tracked temporary file or directory now, once its owner is done with it.
+// When files are kept it stays tracked so Cleanup can list it.
+func Remove(path string) error {
+	if Kept() {
+		return nil
+	}
+	mu.Lock()
+	delete(local, path)
+	mu.Unlock()
+	return os.RemoveAll(path)
+}
+
+// Cleanup deletes every tracked temporary file still around, or lists them when files are
+// kept. Commands register it with shutdown so it also runs when they're interrupted.
+func Cleanup(ctx context.Context) error {
+	mu.Lock()
+	paths := make([]string, 0, len(local))
+	for path := range local {
+		paths = append(paths, path)
+	}
+	local = make(map[string]bool)
+	mu.Unlock()
+	sort.Strings(paths)
+
+	if Kept() {
+		for _, path := range paths {
+			if _, err := os.Stat(path); err == nil {
+				fmt.Printf("📎 Kept %s\n", path)
+			}
+		}
+		return nil
+	}
+
+	var errs []error
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RemoteCommand returns a shell command that deletes temporary paths on a build host, or ""
+// when files are kept. Paths may use ~ and globs.
+func RemoteCommand(paths ...string) string {
+	if Kept() || len(paths) == 0 {
+		return ""
+	}
+	return "rm -rf " + strings.Join(paths, " ")
+}
@@ -0,0 +1,161 @@
+// Package automation holds the Lambda handlers that act on AWS events while nobody is running
+// the CLI: a scheduled janitor, Spot interruption notices, build completion notifications and
+// post-processing of run output written to S3. cmd/lambda starts one of them in the Lambda
+// runtime; they are configured by the function's environment variables.
+package automation
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/notify"
+	"github.com/scttfrdmn/geoschem-aws/internal/ssh"
+)
+
+// Environment variables the handlers read
+const (
+	NotifyTopicsEnv    = "GEOSCHEM_NOTIFY_TOPICS"     // Comma-separated SNS topic ARNs every notification fans out to
+	RepositoryEnv      = "GEOSCHEM_REPOSITORY"        // ECR repository whose pushes are build completions (default: any)
+	JanitorMaxAgeEnv   = "GEOSCHEM_JANITOR_MAX_AGE"   // How long a build instance may run before the janitor terminates it
+	JanitorDryRunEnv   = "GEOSCHEM_JANITOR_DRY_RUN"   // "true" reports leftovers without removing them
+	CatalogTableEnv    = "GEOSCHEM_CATALOG_TABLE"     // Results catalog that new outputs are recorded in
+	AnalysisImageEnv   = "GEOSCHEM_ANALYSIS_IMAGE"    // GCPy analysis image that indexes new outputs
+	FargateQueueEnv    = "GEOSCHEM_FARGATE_JOB_QUEUE" // Fargate job queue the indexing runs on
+	FargateExecRoleEnv = "GEOSCHEM_FARGATE_EXECUTION_ROLE_ARN"
+	FargateJobRoleEnv  = "GEOSCHEM_FARGATE_JOB_ROLE_ARN"
+	FargatePublicIPEnv = "GEOSCHEM_FARGATE_ASSIGN_PUBLIC_IP"
+)
+
+// defaultJanitorMaxAge matches how long per-build key pairs live; no build takes that long
+const defaultJanitorMaxAge = ssh.EphemeralKeyTTL
+
+// Settings configure the handlers
+type Settings struct {
+	Region        string
+	NotifyTopics  []string
+	Repository    string
+	JanitorMaxAge time.Duration
+	JanitorDryRun bool
+	CatalogTable  string
+	AnalysisImage string
+	Fargate       common.FargateConfig
+}
+
+// SettingsFromEnv reads the settings from the function's environment. Lambda sets AWS_REGION.
+func SettingsFromEnv() (Settings, error) {
+	settings := Settings{
+		Region:        os.Getenv("AWS_REGION"),
+		Repository:    os.Getenv(RepositoryEnv),
+		JanitorMaxAge: defaultJanitorMaxAge,
+		CatalogTable:  os.Getenv(CatalogTableEnv),
+		AnalysisImage: os.Getenv(AnalysisImageEnv),
+		Fargate: common.FargateConfig{
+			JobQueue:         os.Getenv(FargateQueueEnv),
+			ExecutionRoleARN: os.Getenv(FargateExecRoleEnv),
+			JobRoleARN:       os.Getenv(FargateJobRoleEnv),
+		},
+	}
+	if settings.Region == "" {
+		return Settings{}, fmt.Errorf("AWS_REGION is not set")
+	}
+	for _, topic := range strings.Split(os.Getenv(NotifyTopicsEnv), ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			settings.NotifyTopics = append(settings.NotifyTopics, topic)
+		}
+	}
+
+	if value := os.Getenv(JanitorMaxAgeEnv); value != "" {
+		maxAge, err := time.ParseDuration(value)
+		if err != nil || maxAge <= 0 {
+			return Settings{}, fmt.Errorf("invalid %s %q: use a duration such as 12h", JanitorMaxAgeEnv, value)
+		}
+		settings.JanitorMaxAge = maxAge
+	}
+	for env, field := range map[string]*bool{
+		JanitorDryRunEnv:   &settings.JanitorDryRun,
+		FargatePublicIPEnv: &settings.Fargate.AssignPublicIP,
+	} {
+		if value := os.Getenv(env); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return Settings{}, fmt.Errorf("invalid %s %q: use true or false", env, value)
+			}
+			*field = parsed
+		}
+	}
+	return settings, nil
+}
+
+// Handlers hold the clients the handlers share, so a warm function reuses them
+type Handlers struct {
+	settings  Settings
+	ec2Client *ec2.Client
+	notifiers []*notify.Notifier
+}
+
+// New creates the handlers with the function's credentials
+func New(ctx context.Context, settings Settings) (*Handlers, error) {
+	cfg, err := common.LoadSDKConfig(ctx, "", settings.Region)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	h := &Handlers{settings: settings, ec2Client: ec2.NewFromConfig(cfg)}
+	for _, topic := range settings.NotifyTopics {
+		notifier, err := notify.New("", topic)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", NotifyTopicsEnv, err)
+		}
+		h.notifiers = append(h.notifiers, notifier)
+	}
+	return h, nil
+}
+
+// notify logs a message and sends it to every configured topic. A topic that can't be
+// reached is logged rather than failing the handler, since Lambda retrying it would send the
+// message to the other topics again.
+func (h *Handlers) notify(ctx context.Context, subject, message string) {
+	fmt.Printf("%s\n%s\n", subject, message)
+	for _, notifier := range h.notifiers {
+		if err := notifier.Send(ctx, subject, message); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+}
+
+// instanceTag returns the value of a tag, or "" when the instance doesn't have it
+func instanceTag(tags []types.Tag, key string) string {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
+
+// What a platform instance is for, from its BuildTag
+const (
+	roleBuild    = "build"
+	roleRun      = "simulation run"
+	roleAnalysis = "analysis session"
+)
+
+func instanceRole(tags []types.Tag) string {
+	buildTag := instanceTag(tags, "BuildTag")
+	switch {
+	case strings.HasPrefix(buildTag, "run-"):
+		return roleRun
+	case buildTag == "analysis":
+		return roleAnalysis
+	default:
+		return roleBuild
+	}
+}
@@ -0,0 +1,27 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// BuildCompleted fans a successful image push out to every notification topic. A build ends
+// by pushing its image to ECR, so the push marks completion however the build ran. Pushes
+// without a tag, such as images a manifest list references by digest, are skipped.
+func (h *Handlers) BuildCompleted(ctx context.Context, event events.ECRImageActionEvent) error {
+	detail := event.Detail
+	if detail.ActionType != "PUSH" || detail.Result != "SUCCESS" || detail.ImageTag == "" {
+		return nil
+	}
+	if h.settings.Repository != "" && detail.RepositoryName != h.settings.Repository {
+		return nil
+	}
+
+	image := fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com/%s:%s", event.Account, event.Region, detail.RepositoryName, detail.ImageTag)
+	message := fmt.Sprintf("Image %s was pushed at %s.\n\nDigest: %s\n\nRun it with:\n  go run ./cmd/run-geoschem -image %s ...\n",
+		image, event.Time.UTC().Format("2006-01-02 15:04 MST"), detail.ImageDigest, image)
+	h.notify(ctx, fmt.Sprintf("✅ GeosChem image built: %s:%s", detail.RepositoryName, detail.ImageTag), message)
+	return nil
+}
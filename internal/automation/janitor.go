@@ -0,0 +1,98 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/ssh"
+)
+
+// JanitorReport lists what a sweep found
+type JanitorReport struct {
+	Instances []string `json:"instances"` // Build instances past the maximum age
+	KeyPairs  []string `json:"key_pairs"` // Expired per-build key pairs
+	DryRun    bool     `json:"dry_run"`
+}
+
+// Janitor terminates build instances older than the maximum age and deletes expired per-build
+// key pairs, for every user in the region. It is the account-wide counterpart of the CLI's
+// cleanup, which only knows what its own ledger recorded. Simulation runs and analysis
+// sessions legitimately last for days and are left alone. The scheduled event carries nothing
+// the sweep needs.
+func (h *Handlers) Janitor(ctx context.Context, _ events.CloudWatchEvent) (*JanitorReport, error) {
+	report := &JanitorReport{DryRun: h.settings.JanitorDryRun}
+	cutoff := time.Now().Add(-h.settings.JanitorMaxAge)
+
+	var lines []string
+	paginator := ec2.NewDescribeInstancesPaginator(h.ec2Client, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("tag:Project"), Values: []string{"geoschem-aws"}},
+			{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing instances: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if instanceRole(instance.Tags) != roleBuild || instance.LaunchTime == nil || instance.LaunchTime.After(cutoff) {
+					continue
+				}
+				instanceID := aws.ToString(instance.InstanceId)
+				report.Instances = append(report.Instances, instanceID)
+				lines = append(lines, fmt.Sprintf("instance %s (%s, owner %s, launched %s)", instanceID,
+					instanceTag(instance.Tags, "Name"), instanceTag(instance.Tags, "Owner"),
+					instance.LaunchTime.UTC().Format("2006-01-02 15:04")))
+			}
+		}
+	}
+
+	keyPairManager := ssh.NewKeyPairManager(h.ec2Client)
+	keyPairManager.SetKeyStore(ssh.SweepKeyStore("", h.settings.Region))
+	expired, err := keyPairManager.AllExpiredEphemeralKeyPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.KeyPairs = expired
+	for _, keyName := range expired {
+		lines = append(lines, "key pair "+keyName+" (expired per-build key)")
+	}
+
+	if len(lines) == 0 {
+		return report, nil
+	}
+	if report.DryRun {
+		h.notify(ctx, fmt.Sprintf("🧹 %d leftover GeosChem resources in %s", len(lines), h.settings.Region),
+			"The janitor is in dry-run mode and removed nothing:\n\n"+strings.Join(lines, "\n"))
+		return report, nil
+	}
+
+	var errs []error
+	if len(report.Instances) > 0 {
+		if _, err := h.ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: report.Instances}); err != nil {
+			errs = append(errs, fmt.Errorf("terminating instances: %w", err))
+		}
+	}
+	for _, keyName := range expired {
+		if err := keyPairManager.DeleteEphemeralKeyPair(ctx, keyName); err != nil {
+			errs = append(errs, fmt.Errorf("deleting key pair %s: %w", keyName, err))
+		}
+	}
+
+	message := fmt.Sprintf("Removed from %s:\n\n%s", h.settings.Region, strings.Join(lines, "\n"))
+	if err := errors.Join(errs...); err != nil {
+		message += "\n\nSome removals failed:\n" + err.Error()
+	}
+	h.notify(ctx, fmt.Sprintf("🧹 Janitor removed %d leftover GeosChem resources", len(lines)), message)
+	return report, errors.Join(errs...)
+}
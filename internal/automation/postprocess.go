@@ -0,0 +1,86 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/catalog"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/provenance"
+	"github.com/scttfrdmn/geoschem-aws/internal/runner"
+)
+
+// OutputWritten post-processes run outputs as they land in S3. A run writes its provenance
+// sidecar last, so the sidecar's creation marks a complete output; other objects are ignored.
+// Each output is recorded in the results catalog and, when the run didn't index it itself,
+// indexed on Fargate, then announced. Steps that aren't configured are skipped.
+func (h *Handlers) OutputWritten(ctx context.Context, event events.S3Event) error {
+	for _, record := range event.Records {
+		key := record.S3.Object.URLDecodedKey
+		if path.Base(key) != provenance.SidecarName || !strings.HasPrefix(record.EventName, "ObjectCreated:") {
+			continue
+		}
+		outputURI := "s3://" + record.S3.Bucket.Name
+		if dir := path.Dir(key); dir != "." {
+			outputURI += "/" + dir
+		}
+		if err := h.postProcess(ctx, outputURI); err != nil {
+			return fmt.Errorf("post-processing %s: %w", outputURI, err)
+		}
+	}
+	return nil
+}
+
+func (h *Handlers) postProcess(ctx context.Context, outputURI string) error {
+	cli := awscli.New("", h.settings.Region)
+	record, err := provenance.ReadSidecar(ctx, cli, outputURI)
+	if err != nil {
+		return err
+	}
+	done := []string{fmt.Sprintf("Run %s wrote %s with %s.", record.ID, outputURI, record.Image)}
+
+	if h.settings.CatalogTable != "" {
+		entry, err := catalog.New("", h.settings.Region, h.settings.CatalogTable).RecordOutput(ctx, catalog.Entry{
+			OutputURI:  outputURI,
+			Simulation: record.Settings["simulation"],
+			Resolution: record.Settings["resolution"],
+		})
+		if err != nil {
+			return err
+		}
+		done = append(done, fmt.Sprintf("Recorded in %s as %s (%d objects).", h.settings.CatalogTable, entry.ID, entry.Objects))
+	}
+
+	if h.settings.AnalysisImage != "" && h.settings.Fargate.Enabled() {
+		// head-object fails when the index doesn't exist yet
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(catalog.IndexURI(outputURI), "s3://"), "/")
+		if err := cli.Run(ctx, nil, "s3api", "head-object", "--bucket", bucket, "--key", key); err == nil {
+			done = append(done, "Already indexed by the run.")
+		} else {
+			fargate, err := runner.NewFargateRunner(&common.BuildConfig{
+				AWS:  common.AWSConfig{Region: h.settings.Region},
+				Runs: common.RunsConfig{Fargate: h.settings.Fargate},
+			})
+			if err != nil {
+				return err
+			}
+			jobID, err := fargate.Submit(ctx, runner.AuxJob{
+				Name:    "index-" + record.ID,
+				Image:   h.settings.AnalysisImage,
+				Command: []string{catalog.IndexCommand, outputURI},
+			})
+			if err != nil {
+				return err
+			}
+			done = append(done, fmt.Sprintf("Indexing on Fargate as job %s; references will be in %s.", jobID, catalog.IndexURI(outputURI)))
+		}
+	}
+
+	h.notify(ctx, fmt.Sprintf("📦 GeosChem output ready: %s", path.Base(outputURI)), strings.Join(done, "\n"))
+	return nil
+}
@@ -0,0 +1,71 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// spotInterruption is the detail of an "EC2 Spot Instance Interruption Warning" event
+type spotInterruption struct {
+	InstanceID     string `json:"instance-id"`
+	InstanceAction string `json:"instance-action"`
+}
+
+// SpotInterruption tells the owner of a platform instance that EC2 is reclaiming it, two
+// minutes ahead, and what that means for the build or run on it. Interruptions of instances
+// the platform didn't launch are ignored.
+func (h *Handlers) SpotInterruption(ctx context.Context, event events.CloudWatchEvent) error {
+	var detail spotInterruption
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		return fmt.Errorf("parsing Spot interruption: %w", err)
+	}
+	if detail.InstanceID == "" {
+		return fmt.Errorf("Spot interruption names no instance")
+	}
+
+	out, err := h.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{detail.InstanceID}})
+	if err != nil {
+		return fmt.Errorf("looking up %s: %w", detail.InstanceID, err)
+	}
+	if len(out.Reservations) == 0 || len(out.Reservations[0].Instances) == 0 {
+		return nil
+	}
+	instance := out.Reservations[0].Instances[0]
+	if instanceTag(instance.Tags, "Project") != "geoschem-aws" {
+		return nil
+	}
+
+	role := instanceRole(instance.Tags)
+	var next string
+	switch role {
+	case roleRun:
+		next = "The simulation stops with the instance. Rerun the same run-geoschem command; " +
+			"chunked runs continue after their last complete chunk."
+	case roleAnalysis:
+		next = "Notebooks not saved to S3 are lost with the instance."
+	default:
+		next = "The build fails when the instance goes. Run it again; without Spot capacity it launches On-Demand."
+	}
+
+	zone := ""
+	if instance.Placement != nil {
+		zone = aws.ToString(instance.Placement.AvailabilityZone)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "EC2 is reclaiming Spot instance %s (%s) in %s, about two minutes from %s.\n\n",
+		detail.InstanceID, instance.InstanceType, zone, event.Time.UTC().Format("15:04 MST"))
+	fmt.Fprintf(&b, "Name:    %s\nOwner:   %s\nPurpose: %s", instanceTag(instance.Tags, "Name"), instanceTag(instance.Tags, "Owner"), role)
+	if buildTag := instanceTag(instance.Tags, "BuildTag"); buildTag != "" {
+		fmt.Fprintf(&b, " (%s)", buildTag)
+	}
+	fmt.Fprintf(&b, "\nAction:  %s\n\n%s\n", detail.InstanceAction, next)
+
+	h.notify(ctx, fmt.Sprintf("⚠️ Spot interruption: %s %s", role, instanceTag(instance.Tags, "Name")), b.String())
+	return nil
+}
@@ -0,0 +1,76 @@
+package awscli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Client runs AWS CLI commands locally for services the platform doesn't link an SDK for
+type Client struct {
+	profile string
+	region  string
+}
+
+// New creates a client for the given AWS profile and region
+func New(profile, region string) *Client {
+	return &Client{profile: profile, region: region}
+}
+
+// Region returns the client's region
+func (c *Client) Region() string {
+	return c.region
+}
+
+// Run executes `aws <args>` and decodes its JSON output into v (if non-nil)
+func (c *Client) Run(ctx context.Context, v interface{}, args ...string) error {
+	return c.RunWithInput(ctx, v, nil, args...)
+}
+
+// RunWithInput is Run with input on stdin, for secrets passed as file:///dev/stdin so they
+// never appear in the process list
+func (c *Client) RunWithInput(ctx context.Context, v interface{}, input []byte, args ...string) error {
+	name := strings.Join(args[:min(2, len(args))], " ")
+
+	args = append(args, "--region", c.region, "--output", "json")
+	if c.profile != "" {
+		args = append(args, "--profile", c.profile)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "aws", args...)
+	cmd.Env = retryEnv()
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("aws %s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+
+	if v == nil || stdout.Len() == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(stdout.Bytes(), v); err != nil {
+		return fmt.Errorf("parsing aws %s output: %w", name, err)
+	}
+	return nil
+}
+
+// retryEnv gives the CLI the same adaptive retries as the SDK clients (see
+// common.LoadSDKConfig) unless the environment already chooses a retry policy
+func retryEnv() []string {
+	env := os.Environ()
+	if os.Getenv("AWS_RETRY_MODE") == "" {
+		env = append(env, "AWS_RETRY_MODE=adaptive")
+	}
+	if os.Getenv("AWS_MAX_ATTEMPTS") == "" {
+		env = append(env, "AWS_MAX_ATTEMPTS=10")
+	}
+	return env
+}
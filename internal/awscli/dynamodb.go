@@ -0,0 +1,40 @@
+package awscli
+
+import (
+	"strconv"
+	"time"
+)
+
+// AttributeValue is the DynamoDB JSON form of the string and number attributes this
+// platform stores, as accepted and printed by `aws dynamodb`
+type AttributeValue struct {
+	S string `json:"S,omitempty"`
+	N string `json:"N,omitempty"`
+}
+
+// StringValue returns a string attribute
+func StringValue(value string) AttributeValue {
+	return AttributeValue{S: value}
+}
+
+// NumberValue returns a number attribute
+func NumberValue(value float64) AttributeValue {
+	return AttributeValue{N: strconv.FormatFloat(value, 'f', -1, 64)}
+}
+
+// TimeValue returns a time as an RFC 3339 string attribute
+func TimeValue(t time.Time) AttributeValue {
+	return AttributeValue{S: t.UTC().Format(time.RFC3339)}
+}
+
+// Number parses a number attribute, returning 0 if it is missing
+func (v AttributeValue) Number() float64 {
+	n, _ := strconv.ParseFloat(v.N, 64)
+	return n
+}
+
+// Time parses an RFC 3339 string attribute, returning the zero time if it is missing
+func (v AttributeValue) Time() time.Time {
+	t, _ := time.Parse(time.RFC3339, v.S)
+	return t
+}
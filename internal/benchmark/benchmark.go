@@ -0,0 +1,590 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+	"github.com/scttfrdmn/geoschem-aws/internal/catalog"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/data"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+	"github.com/scttfrdmn/geoschem-aws/internal/hemco"
+	"github.com/scttfrdmn/geoschem-aws/internal/shutdown"
+	"github.com/scttfrdmn/geoschem-aws/internal/storage"
+)
+
+// Config describes the short simulation both images run
+type Config struct {
+	InstanceType  string
+	Simulation    string           // fullchem, aerosol, TransportTracers, ...
+	Resolution    string           // 4x5, 2x2.5, ...
+	StartDate     string           // YYYY-MM-DD
+	EndDate       string           // YYYY-MM-DD
+	DataSource    string           // Optional S3 URI synced to the instance as ExtData
+	Diagnostics   []string         // Variables compared between runs (global means)
+	ShareDataset  string           // Optional S3 URI prefix; when set, anonymized throughput is uploaded there
+	RestartURI    string           // Optional S3 URI of the initial restart file (resolved from the restart registry)
+	OutputURI     string           // Optional S3 URI the run output is copied to before the instance is terminated
+	EFSID         string           // Optional EFS file system holding the run directory and output, shared across runs
+	FSxID         string           // Optional FSx for Lustre file system serving the inputs from its data repository and holding the run directory
+	MetField      string           // Met product the run reads (MERRA2, GEOSFP, GEOSIT); empty means DefaultMetField
+	SkipPreflight bool             // Skip the generated run directory checks before the simulation starts
+	IndexImage    string           // Optional analysis image that builds kerchunk references over OutputURI after a successful run
+	Checkpoint    string           // How often the run writes restart files: daily or monthly; empty keeps the run directory's setting
+	Spot          bool             // Run on a Spot instance
+	EFA           bool             // Launch with an Elastic Fabric Adapter, for MPI between nodes
+	DisableSMT    bool             // Launch with one thread per physical core on instances with SMT
+	QueueJob      string           // Run queue job holding capacity for the run, tagged on the instance so it can be preempted
+	EmissionsYear int              // Year HEMCO reads emissions for; 0 follows the simulation dates
+	StageMetYears bool             // DataSource holds many years of met fields; stage only the run's years
+	Emissions     []hemco.Override // Scale factors, inventories and masks merged into HEMCO_Config.rc
+}
+
+// DefaultDiagnostics are the species compared when none are configured
+var DefaultDiagnostics = []string{"SpeciesConc_O3", "SpeciesConc_CO"}
+
+// Result holds the measurements for one image
+type Result struct {
+	Image        string
+	InstanceType string
+	WallClock    time.Duration
+	ModelDays    float64
+	Throughput   float64 // Model days per wall-clock day
+	Cost         float64 // USD for the simulation itself
+	Diagnostics  map[string]float64
+	Summary      *OutputSummary // Quick-look check of the output, nil if it could not be computed
+	Indexed      bool           // Kerchunk references were written next to the output
+	Resources    *ResourceUsage // Node metrics sampled during the simulation, nil if none were collected
+	Checkpoint   *Checkpoint    // Where a paused simulation stopped; Err wraps ErrPaused
+	Err          error
+}
+
+// Comparison holds the results for both images
+type Comparison struct {
+	Config Config
+	A      *Result
+	B      *Result
+}
+
+// Runner executes benchmarks on freshly launched instances
+type Runner struct {
+	cfg         aws.Config
+	buildConfig *common.BuildConfig
+	launchMu    sync.Mutex // Serializes key pair setup and launches
+}
+
+// NewRunner creates a benchmark runner. The build config supplies subnet, security group and region.
+func NewRunner(cfg aws.Config, buildConfig *common.BuildConfig) *Runner {
+	return &Runner{
+		cfg:         cfg,
+		buildConfig: buildConfig,
+	}
+}
+
+// Validate checks the benchmark configuration
+func (c *Config) Validate() error {
+	if c.InstanceType == "" {
+		return fmt.Errorf("instance type is required")
+	}
+	if c.Simulation == "" || c.Resolution == "" {
+		return fmt.Errorf("simulation and resolution are required")
+	}
+	if _, err := c.ModelDays(); err != nil {
+		return err
+	}
+	if c.ShareDataset != "" && !strings.HasPrefix(c.ShareDataset, "s3://") {
+		return fmt.Errorf("share dataset must be an s3:// URI, got %s", c.ShareDataset)
+	}
+	if c.OutputURI != "" && !strings.HasPrefix(c.OutputURI, "s3://") {
+		return fmt.Errorf("output location must be an s3:// URI, got %s", c.OutputURI)
+	}
+	if c.Checkpoint != "" && c.Checkpoint != CheckpointDaily && c.Checkpoint != CheckpointMonthly {
+		return fmt.Errorf("checkpoint frequency must be %s or %s, got %s", CheckpointDaily, CheckpointMonthly, c.Checkpoint)
+	}
+	if c.FSxID != "" && c.EFSID != "" {
+		return fmt.Errorf("the run directory goes on FSx or EFS, not both")
+	}
+	if c.FSxID != "" && c.DataSource != "" {
+		return fmt.Errorf("inputs come from the FSx file system's data repository; stage them there instead of syncing a data source")
+	}
+	if c.EmissionsYear < 0 {
+		return fmt.Errorf("emissions year cannot be negative: %d", c.EmissionsYear)
+	}
+	if _, err := c.DataSyncFilters(); err != nil {
+		return err
+	}
+	if err := hemco.Validate(c.Emissions); err != nil {
+		return fmt.Errorf("emissions overrides: %w", err)
+	}
+	return nil
+}
+
+// emissionsArg returns the image options that override emissions, quoted for the shell
+func (c *Config) emissionsArg() (string, error) {
+	arg := ""
+	if c.EmissionsYear != 0 {
+		arg = fmt.Sprintf(" --emissions-year %d", c.EmissionsYear)
+	}
+	if len(c.Emissions) > 0 {
+		overrides, err := hemco.Encode(c.Emissions)
+		if err != nil {
+			return "", err
+		}
+		arg += " --hemco-overrides '" + strings.ReplaceAll(overrides, "'", `'"'"'`) + "'"
+	}
+	return arg, nil
+}
+
+// dataDir is the input data directory on the run instance: the FSx mount, or where
+// DataSource is synced
+func (c *Config) dataDir() string {
+	if c.FSxID != "" {
+		return storage.DefaultFSxMountPath
+	}
+	return "~/bench/data"
+}
+
+// runDir names a run's output directory on shared storage mounted at mountPath
+func (c *Config) runDir(mountPath string) string {
+	return fmt.Sprintf("%s/runs/%s_%s_%s_%s", mountPath,
+		c.Simulation, c.Resolution, c.StartDate, time.Now().UTC().Format("20060102T150405"))
+}
+
+// DataSyncFilters returns the aws s3 sync filters that limit what is staged from DataSource,
+// or none when all of it is staged
+func (c *Config) DataSyncFilters() ([]string, error) {
+	if !c.StageMetYears {
+		return nil, nil
+	}
+	start, err := time.Parse("2006-01-02", c.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing start date: %w", err)
+	}
+	end, err := time.Parse("2006-01-02", c.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing end date: %w", err)
+	}
+	return data.MetSyncFilters(c.metField(), start, end)
+}
+
+// ModelDays returns the simulated period length in days
+func (c *Config) ModelDays() (float64, error) {
+	start, err := time.Parse("2006-01-02", c.StartDate)
+	if err != nil {
+		return 0, fmt.Errorf("parsing start date: %w", err)
+	}
+	end, err := time.Parse("2006-01-02", c.EndDate)
+	if err != nil {
+		return 0, fmt.Errorf("parsing end date: %w", err)
+	}
+	if !end.After(start) {
+		return 0, fmt.Errorf("end date %s must be after start date %s", c.EndDate, c.StartDate)
+	}
+	return end.Sub(start).Hours() / 24, nil
+}
+
+// Compare runs the same simulation with both images on identical instances in parallel
+func (r *Runner) Compare(ctx context.Context, config Config, imageA, imageB string) (*Comparison, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid benchmark configuration: %w", err)
+	}
+	if len(config.Diagnostics) == 0 {
+		config.Diagnostics = DefaultDiagnostics
+	}
+	if !config.SkipPreflight {
+		preflight := config.Preflight()
+		fmt.Print(preflight.Report())
+		if err := preflight.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	comparison := &Comparison{Config: config}
+	results := make([]*Result, 2)
+
+	var wg sync.WaitGroup
+	for i, image := range []string{imageA, imageB} {
+		wg.Add(1)
+		go func(i int, image string) {
+			defer wg.Done()
+			results[i] = r.Run(ctx, config, image)
+		}(i, image)
+	}
+	wg.Wait()
+
+	comparison.A, comparison.B = results[0], results[1]
+	return comparison, nil
+}
+
+// Run launches an instance, runs the simulation with one image, and terminates the instance
+func (r *Runner) Run(ctx context.Context, config Config, image string) *Result {
+	result := &Result{
+		Image:        image,
+		InstanceType: config.InstanceType,
+		Diagnostics:  make(map[string]float64),
+	}
+
+	arch := common.InstanceArchitecture(config.InstanceType)
+
+	// Each run gets its own copy so concurrent launches don't share mutable config
+	buildConfig := *r.buildConfig
+	archConfig := common.ArchConfig{InstanceType: config.InstanceType, Spot: config.Spot, EFA: config.EFA}
+	if instance, err := common.LookupInstance(config.InstanceType); err == nil {
+		archConfig.CPUOptions = common.WorkloadProfile{DisableSMT: config.DisableSMT}.CPUOptions(*instance)
+	}
+	buildConfig.Architectures = map[string]common.ArchConfig{arch: archConfig}
+	if config.QueueJob != "" {
+		tags := map[string]string{QueueJobTag: config.QueueJob}
+		for key, value := range r.buildConfig.Tagging.Tags {
+			tags[key] = value
+		}
+		buildConfig.Tagging.Tags = tags
+	}
+	buildConfig.Tagging.BuildTag = fmt.Sprintf("run-%s-%s", config.Simulation, config.Resolution)
+	// EFS is only reachable from AZs with a mount target
+	buildConfig.Placement.EFSFileSystem = config.EFSID
+	if config.FSxID != "" {
+		buildConfig.Placement.FSxFileSystem = config.FSxID
+	}
+
+	sshBuilder := builder.NewSSHBuilder(r.cfg)
+	r.launchMu.Lock()
+	instanceID, err := sshBuilder.BuildWithSSH(ctx, &buildConfig, arch)
+	r.launchMu.Unlock()
+	// A run paused with PauseStop keeps its instance stopped rather than terminated
+	keepStopped := false
+	if instanceID != "" {
+		cleanup := shutdown.Register(ctx, "benchmark instance "+instanceID, func(ctx context.Context) error {
+			if keepStopped {
+				return sshBuilder.StopInstance(ctx, instanceID)
+			}
+			return sshBuilder.CleanupInstance(ctx, instanceID)
+		})
+		defer func() {
+			if err := cleanup(); err != nil {
+				fmt.Printf("Warning: failed to terminate benchmark instance %s: %v\n", instanceID, err)
+			}
+		}()
+	}
+	if err != nil {
+		result.Err = fmt.Errorf("launching benchmark instance: %w", err)
+		return result
+	}
+
+	if err := sshBuilder.PrepareInstance(ctx, true); err != nil {
+		result.Err = fmt.Errorf("preparing instance: %w", err)
+		return result
+	}
+
+	dockerBuilder := docker.NewDockerBuilder(sshBuilder.Runner())
+	if err := dockerBuilder.PullImage(ctx, image); err != nil {
+		result.Err = err
+		return result
+	}
+
+	if config.DataSource != "" {
+		fmt.Printf("📦 Staging input data from %s...\n", config.DataSource)
+		filters, err := config.DataSyncFilters()
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		filterArgs := ""
+		for _, filter := range filters {
+			filterArgs += fmt.Sprintf(" '%s'", filter)
+		}
+		syncCmd := fmt.Sprintf("mkdir -p ~/bench/data && aws s3 sync --only-show-errors --no-sign-request%s %s ~/bench/data", filterArgs, config.DataSource)
+		if output, err := sshBuilder.ExecuteCommand(ctx, syncCmd); err != nil {
+			result.Err = fmt.Errorf("staging input data: %w, output: %s", err, output)
+			return result
+		}
+	}
+
+	outputDir := "~/bench/output"
+	if config.EFSID != "" {
+		fmt.Printf("🗄️  Mounting EFS %s...\n", config.EFSID)
+		mountCmd := storage.MountCommand(config.EFSID, r.buildConfig.AWS.Region, storage.DefaultEFSMountPath)
+		if output, err := sshBuilder.ExecuteCommand(ctx, mountCmd); err != nil {
+			result.Err = fmt.Errorf("mounting EFS: %w, output: %s", err, output)
+			return result
+		}
+		outputDir = config.runDir(storage.DefaultEFSMountPath)
+		fmt.Printf("📁 Run output will be kept on EFS at %s\n", outputDir)
+	}
+	if config.FSxID != "" {
+		fmt.Printf("🗄️  Mounting FSx for Lustre %s...\n", config.FSxID)
+		fileSystem, err := storage.NewFSxManager(r.buildConfig.AWS.Profile, r.buildConfig.AWS.Region).Describe(ctx, config.FSxID)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		mountCmd := storage.LustreMountCommand(fileSystem, storage.DefaultFSxMountPath)
+		if output, err := sshBuilder.ExecuteCommand(ctx, mountCmd); err != nil {
+			result.Err = fmt.Errorf("mounting FSx: %w, output: %s", err, output)
+			return result
+		}
+		outputDir = config.runDir(storage.DefaultFSxMountPath)
+		fmt.Printf("📁 Inputs load from %s and output is written to %s\n", storage.DefaultFSxMountPath, outputDir)
+	}
+
+	restartArg := ""
+	if config.RestartURI != "" {
+		fmt.Printf("♻️  Fetching restart file %s...\n", config.RestartURI)
+		// The summary, when the restart has one, lets the container check the download's checksum
+		fetchCmd := fmt.Sprintf("mkdir -p ~/bench/restart && aws s3 cp --only-show-errors %[1]s ~/bench/restart/restart.nc4 && "+
+			"{ aws s3 cp --only-show-errors %[1]s.json ~/bench/restart/restart.nc4.json 2>/dev/null || true; }", config.RestartURI)
+		if output, err := sshBuilder.ExecuteCommand(ctx, fetchCmd); err != nil {
+			result.Err = fmt.Errorf("fetching restart file: %w, output: %s", err, output)
+			return result
+		}
+		restartArg = " --restart-file /workspace/restart/restart.nc4"
+	}
+
+	if !config.SkipPreflight {
+		fmt.Println("🔎 Checking the generated run directory...")
+		preflight, err := r.preflightRunDir(ctx, sshBuilder, config, image, outputDir, restartArg)
+		if err != nil {
+			fmt.Printf("Warning: could not run preflight checks: %v\n", err)
+		} else {
+			fmt.Print(preflight.Report())
+			if err := preflight.Err(); err != nil {
+				result.Err = err
+				return result
+			}
+		}
+	}
+
+	checkpointArg := ""
+	if config.Checkpoint != "" {
+		checkpointArg = " --checkpoint-frequency " + config.Checkpoint
+	}
+	emissionsArg, err := config.emissionsArg()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	runCmd := fmt.Sprintf("mkdir -p %[10]s %[1]s ~/bench/restart && podman run --rm --name %[9]s -v %[10]s:/workspace/data -v %[1]s:/workspace/output -v ~/bench/restart:/workspace/restart %[2]s classic --simulation %[3]s --resolution %[4]s --start-date %[5]s --end-date %[6]s%[7]s%[8]s",
+		outputDir, image, config.Simulation, config.Resolution, config.StartDate, config.EndDate, restartArg, checkpointArg+emissionsArg, simulationContainer, config.dataDir())
+
+	runID := fmt.Sprintf("%s-%s", buildConfig.Tagging.BuildTag, time.Now().UTC().Format("20060102T150405"))
+	if err := r.startMetrics(ctx, sshBuilder, runID); err != nil {
+		fmt.Printf("Warning: node metrics will be incomplete: %v\n", err)
+	}
+
+	fmt.Printf("⏱️  Running benchmark with %s on %s...\n", image, config.InstanceType)
+	start := time.Now()
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	pauses := make(chan *Checkpoint, 1)
+	go func() {
+		pauses <- r.watchForPause(watchCtx, sshBuilder, instanceID, outputDir, config)
+	}()
+	output, err := sshBuilder.ExecuteCommand(ctx, runCmd)
+	result.WallClock = time.Since(start)
+	stopWatching()
+	checkpoint := <-pauses
+
+	// Usage is most telling when the run failed, e.g. for lack of memory
+	usage, usageErr := r.collectMetrics(ctx, sshBuilder)
+	if usageErr != nil {
+		// The CloudWatch agent kept publishing even if the local samples are lost
+		usage, usageErr = r.cloudWatchUsage(ctx, runID, start, time.Now())
+	}
+	if usageErr != nil {
+		fmt.Printf("Warning: could not summarize node metrics: %v\n", usageErr)
+	} else {
+		result.Resources = usage
+		fmt.Print(usage.Report())
+	}
+
+	// Keep the output (including logs of a failed run) before the instance goes away
+	if config.OutputURI != "" {
+		fmt.Printf("📤 Copying output to %s...\n", config.OutputURI)
+		syncCmd := fmt.Sprintf("aws s3 sync --only-show-errors %s %s", outputDir, config.OutputURI)
+		if syncOutput, syncErr := sshBuilder.ExecuteCommand(ctx, syncCmd); syncErr != nil {
+			fmt.Printf("Warning: failed to copy output to %s: %v, output: %s\n", config.OutputURI, syncErr, syncOutput)
+		}
+	}
+
+	if err != nil && checkpoint != nil {
+		// One-time Spot instances can't be stopped
+		if config.Spot {
+			checkpoint.Mode = PauseTerminate
+		}
+		result.Checkpoint = checkpoint
+		result.Err = fmt.Errorf("%w for %s", ErrPaused, checkpoint.ModelDate.Format("2006-01-02 15:04"))
+		keepStopped = checkpoint.Mode == PauseStop
+		return result
+	}
+	if err != nil {
+		if r.outOfMemory(ctx, sshBuilder, err, result.Resources) {
+			err = fmt.Errorf("%w on %s (%v)", ErrOutOfMemory, config.InstanceType, err)
+		}
+		result.Err = fmt.Errorf("simulation failed: %w, output tail: %s", err, tail(output, 20))
+		return result
+	}
+
+	// Index in the region, where reading the NetCDF metadata is free; host networking
+	// gives the container the instance profile's credentials
+	if config.IndexImage != "" && config.OutputURI != "" {
+		fmt.Printf("🗂️  Building kerchunk references for %s...\n", config.OutputURI)
+		indexCmd := fmt.Sprintf("podman run --rm --network host %s %s %s", config.IndexImage, catalog.IndexCommand, config.OutputURI)
+		if indexOutput, indexErr := sshBuilder.ExecuteCommand(ctx, indexCmd); indexErr != nil {
+			fmt.Printf("Warning: could not index output: %v, output: %s\n", indexErr, tail(indexOutput, 10))
+		} else {
+			result.Indexed = true
+		}
+	}
+
+	result.ModelDays, _ = config.ModelDays()
+	result.Throughput = result.ModelDays / (result.WallClock.Hours() / 24)
+	if instance, err := common.LookupInstance(config.InstanceType); err == nil {
+		result.Cost = instance.PricePerHour * result.WallClock.Hours()
+	}
+
+	diagnostics, err := r.collectDiagnostics(ctx, sshBuilder, image, outputDir, config.Diagnostics)
+	if err != nil {
+		fmt.Printf("Warning: could not collect diagnostics for %s: %v\n", image, err)
+	}
+	result.Diagnostics = diagnostics
+
+	summary, err := r.summarizeOutput(ctx, sshBuilder, image, outputDir)
+	if err != nil {
+		fmt.Printf("Warning: could not summarize output for %s: %v\n", image, err)
+	} else {
+		result.Summary = summary
+		fmt.Print(summary.Report())
+	}
+
+	// Sharing is opt-in and never fails the benchmark itself
+	if config.ShareDataset != "" {
+		if err := r.shareResult(ctx, sshBuilder, config, result); err != nil {
+			fmt.Printf("Warning: could not share result for %s: %v\n", image, err)
+		}
+	}
+
+	return result
+}
+
+// collectDiagnostics computes global means of the requested variables from the last SpeciesConc file
+func (r *Runner) collectDiagnostics(ctx context.Context, sshBuilder *builder.SSHBuilder, image, outputDir string, variables []string) (map[string]float64, error) {
+	diagnostics := make(map[string]float64)
+
+	script := fmt.Sprintf(`import glob, xarray as xr
+files = sorted(glob.glob("/workspace/output/**/GEOSChem.SpeciesConc*.nc4", recursive=True))
+ds = xr.open_dataset(files[-1])
+for v in %q.split(","):
+    if v in ds:
+        print("DIAG", v, float(ds[v].mean()))`, strings.Join(variables, ","))
+
+	cmd := fmt.Sprintf("podman run --rm -v %s:/workspace/output --entrypoint python3 %s -c '%s'", outputDir, image, strings.ReplaceAll(script, "'", `'"'"'`))
+	output, err := sshBuilder.ExecuteCommand(ctx, cmd)
+	if err != nil {
+		return diagnostics, fmt.Errorf("%w, output: %s", err, tail(output, 10))
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "DIAG" {
+			continue
+		}
+		if value, err := strconv.ParseFloat(fields[2], 64); err == nil {
+			diagnostics[fields[1]] = value
+		}
+	}
+
+	return diagnostics, nil
+}
+
+// Report returns a human-readable comparison report
+func (c *Comparison) Report() string {
+	var report strings.Builder
+
+	report.WriteString(fmt.Sprintf("📊 Benchmark Comparison (%s %s, %s → %s on %s)\n\n",
+		c.Config.Simulation, c.Config.Resolution, c.Config.StartDate, c.Config.EndDate, c.Config.InstanceType))
+
+	for _, named := range []struct {
+		label  string
+		result *Result
+	}{{"A", c.A}, {"B", c.B}} {
+		report.WriteString(fmt.Sprintf("%s: %s\n", named.label, named.result.Image))
+		if named.result.Err != nil {
+			report.WriteString(fmt.Sprintf("   ❌ %v\n\n", named.result.Err))
+			continue
+		}
+		report.WriteString(fmt.Sprintf("   ⏱️  Wall clock: %s\n", named.result.WallClock.Round(time.Second)))
+		report.WriteString(fmt.Sprintf("   🚀 Throughput: %.1f model days/day\n", named.result.Throughput))
+		report.WriteString(fmt.Sprintf("   💰 Cost: $%.2f ($%.2f per model year)\n", named.result.Cost, costPerModelYear(named.result)))
+		if named.result.Summary != nil {
+			for _, warning := range named.result.Summary.Warnings {
+				report.WriteString(fmt.Sprintf("   ⚠️  %s\n", warning))
+			}
+		}
+		report.WriteString("\n")
+	}
+
+	if c.A.Err != nil || c.B.Err != nil {
+		return report.String()
+	}
+
+	speedup := c.B.Throughput / c.A.Throughput
+	report.WriteString(fmt.Sprintf("B vs A: %.2fx throughput, %+.1f%% cost\n\n", speedup, percentChange(c.A.Cost, c.B.Cost)))
+
+	report.WriteString("Diagnostic differences (global mean, B vs A):\n")
+	var names []string
+	for name := range c.A.Diagnostics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		valueB, ok := c.B.Diagnostics[name]
+		if !ok {
+			report.WriteString(fmt.Sprintf("   %s: missing from B\n", name))
+			continue
+		}
+		diff := percentChange(c.A.Diagnostics[name], valueB)
+		marker := "✅"
+		if math.Abs(diff) > 1 {
+			marker = "⚠️ "
+		}
+		report.WriteString(fmt.Sprintf("   %s %s: %.4g vs %.4g (%+.3f%%)\n", marker, name, c.A.Diagnostics[name], valueB, diff))
+	}
+	if len(names) == 0 {
+		report.WriteString("   (no diagnostics collected)\n")
+	}
+
+	return report.String()
+}
+
+// costPerModelYear extrapolates the simulation cost to one model year
+func costPerModelYear(result *Result) float64 {
+	if result.ModelDays == 0 {
+		return 0
+	}
+	return result.Cost / result.ModelDays * 365
+}
+
+// percentChange returns the relative change from a to b in percent
+func percentChange(a, b float64) float64 {
+	if a == 0 {
+		return 0
+	}
+	return (b - a) / a * 100
+}
+
+// tail returns the last n lines of output
+func tail(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,290 @@
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// MetricsNamespace is the CloudWatch namespace run instances publish node metrics to
+const MetricsNamespace = "GeosChem/Runs"
+
+// metricsInterval is how often node metrics are sampled and published, in seconds
+const metricsInterval = 15
+
+// Where the node sampler appends its samples and records its PID on the instance
+const (
+	metricsFile    = "~/bench/node-metrics.txt"
+	metricsPIDFile = "~/bench/node-metrics.pid"
+)
+
+// highMemoryPercent is the peak memory use at which a run counts as memory-bound
+const highMemoryPercent = 90
+
+// agentConfigPath is where the CloudWatch agent config for runs is written
+const agentConfigPath = "/opt/aws/amazon-cloudwatch-agent/etc/geoschem-run.json"
+
+// nodeSampler appends one line every 15 seconds, matching metricsInterval: time, busy, iowait
+// and total CPU jiffies, total and available memory in kB, network bytes received and sent, and Lustre
+// (FSx) bytes read and written
+const nodeSampler = `while :; do
+  cpu=$(awk '/^cpu /{print $2+$3+$4+$7+$8+$9, $6, $2+$3+$4+$5+$6+$7+$8+$9}' /proc/stat)
+  mem=$(awk '/^MemTotal:/{t=$2} /^MemAvailable:/{a=$2} END{print t+0, a+0}' /proc/meminfo)
+  net=$(awk 'NR > 2 && $1 !~ /^lo:/ {sub(/^[^:]*:/, ""); rx += $1; tx += $9} END{print rx+0, tx+0}' /proc/net/dev)
+  lustre=$(sudo -n lctl get_param -n 'llite.*.stats' 2>/dev/null | awk '$1 == "read_bytes" {r += $NF} $1 == "write_bytes" {w += $NF} END{print r+0, w+0}')
+  echo "$(date +%s) $cpu $mem $net ${lustre:-0 0}" >> ` + metricsFile + `
+  sleep 15
+done`
+
+// ResourceUsage summarizes the node metrics sampled while a simulation ran
+type ResourceUsage struct {
+	Samples           int
+	PeakMemoryPercent float64 // Highest share of memory in use
+	MeanCPUPercent    float64 // Average share of CPU time busy over the run
+	PeakCPUPercent    float64
+	MeanIOWaitPercent float64 // Average share of CPU time waiting on I/O
+	PeakNetworkMBps   float64 // Received plus sent, including NFS and EFS traffic
+	PeakLustreMBps    float64 // FSx for Lustre reads plus writes; 0 without a Lustre mount
+}
+
+// cloudWatchAgentConfig returns the CloudWatch agent config publishing memory, CPU, disk and
+// network metrics to MetricsNamespace, dimensioned by instance and run
+func cloudWatchAgentConfig(runID string) (string, error) {
+	dimensions := map[string]string{"Run": runID}
+	config := map[string]any{
+		"agent": map[string]any{"metrics_collection_interval": metricsInterval},
+		"metrics": map[string]any{
+			"namespace": MetricsNamespace,
+			"append_dimensions": map[string]string{
+				"InstanceId":   "${aws:InstanceId}",
+				"InstanceType": "${aws:InstanceType}",
+			},
+			"metrics_collected": map[string]any{
+				"cpu": map[string]any{
+					"measurement":       []string{"usage_active", "usage_iowait"},
+					"totalcpu":          true,
+					"append_dimensions": dimensions,
+				},
+				"mem": map[string]any{
+					"measurement":       []string{"used_percent", "available"},
+					"append_dimensions": dimensions,
+				},
+				"diskio": map[string]any{
+					"measurement":       []string{"read_bytes", "write_bytes"},
+					"append_dimensions": dimensions,
+				},
+				"net": map[string]any{
+					"measurement":       []string{"bytes_recv", "bytes_sent"},
+					"append_dimensions": dimensions,
+				},
+			},
+		},
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("encoding CloudWatch agent config: %w", err)
+	}
+	return string(data), nil
+}
+
+// startMetrics installs and starts the CloudWatch agent so the run can be watched live, and
+// starts a local sampler whose samples are summarized after the run. The agent needs
+// cloudwatch:PutMetricData on the instance profile; failing to start it only costs the live
+// view.
+func (r *Runner) startMetrics(ctx context.Context, sshBuilder *builder.SSHBuilder, runID string) error {
+	samplerCmd := fmt.Sprintf("mkdir -p ~/bench && rm -f %s && (nohup bash -c '%s' >/dev/null 2>&1 & echo $! > %s)",
+		metricsFile, strings.ReplaceAll(nodeSampler, "'", `'"'"'`), metricsPIDFile)
+	if output, err := sshBuilder.ExecuteCommand(ctx, samplerCmd); err != nil {
+		return fmt.Errorf("starting node sampler: %w, output: %s", err, output)
+	}
+
+	agentConfig, err := cloudWatchAgentConfig(runID)
+	if err != nil {
+		return err
+	}
+	agentCmd := fmt.Sprintf("(rpm -q amazon-cloudwatch-agent >/dev/null || sudo dnf install -y -q amazon-cloudwatch-agent) && echo '%s' | sudo tee %s >/dev/null && sudo /opt/aws/amazon-cloudwatch-agent/bin/amazon-cloudwatch-agent-ctl -a fetch-config -m ec2 -s -c file:%s",
+		agentConfig, agentConfigPath, agentConfigPath)
+	if output, err := sshBuilder.ExecuteCommand(ctx, agentCmd); err != nil {
+		return fmt.Errorf("starting CloudWatch agent: %w, output: %s", err, tail(output, 5))
+	}
+	fmt.Printf("📈 Publishing node metrics to CloudWatch namespace %s (Run=%s)\n", MetricsNamespace, runID)
+	return nil
+}
+
+// collectMetrics stops the node sampler and summarizes its samples
+func (r *Runner) collectMetrics(ctx context.Context, sshBuilder *builder.SSHBuilder) (*ResourceUsage, error) {
+	output, err := sshBuilder.ExecuteCommand(ctx, fmt.Sprintf("kill $(cat %s) 2>/dev/null; cat %s", metricsPIDFile, metricsFile))
+	if err != nil {
+		return nil, fmt.Errorf("reading node metrics: %w", err)
+	}
+	return parseNodeMetrics(output)
+}
+
+// cloudWatchUsage summarizes the memory and CPU metrics the CloudWatch agent published for a
+// run, for when the local samples are lost. Network and Lustre peaks aren't published and stay 0.
+func (r *Runner) cloudWatchUsage(ctx context.Context, runID string, start, end time.Time) (*ResourceUsage, error) {
+	search := func(id, metric, stat string) map[string]any {
+		return map[string]any{
+			"Id":         id,
+			"Expression": fmt.Sprintf(`SEARCH('Namespace="%s" MetricName="%s" Run="%s"', '%s', 60)`, MetricsNamespace, metric, runID, stat),
+			"ReturnData": true,
+		}
+	}
+	queries, err := json.Marshal([]map[string]any{
+		search("memory", "mem_used_percent", "Maximum"),
+		search("cpu", "cpu_usage_active", "Average"),
+		search("iowait", "cpu_usage_iowait", "Average"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out struct {
+		MetricDataResults []struct {
+			Id     string
+			Values []float64
+		}
+	}
+	cli := awscli.New(r.buildConfig.AWS.Profile, r.buildConfig.AWS.Region)
+	if err := cli.Run(ctx, &out, "cloudwatch", "get-metric-data", "--metric-data-queries", string(queries),
+		"--start-time", start.UTC().Format(time.RFC3339), "--end-time", end.UTC().Add(time.Minute).Format(time.RFC3339)); err != nil {
+		return nil, fmt.Errorf("reading CloudWatch metrics for %s: %w", runID, err)
+	}
+
+	usage := &ResourceUsage{}
+	var cpu, iowait []float64
+	for _, series := range out.MetricDataResults {
+		switch series.Id {
+		case "memory":
+			usage.Samples += len(series.Values)
+			for _, value := range series.Values {
+				usage.PeakMemoryPercent = max(usage.PeakMemoryPercent, value)
+			}
+		case "cpu":
+			cpu = append(cpu, series.Values...)
+		case "iowait":
+			iowait = append(iowait, series.Values...)
+		}
+	}
+	if usage.Samples < 2 {
+		return nil, fmt.Errorf("only %d CloudWatch memory samples for %s", usage.Samples, runID)
+	}
+	for _, value := range cpu {
+		usage.MeanCPUPercent += value / float64(len(cpu))
+		usage.PeakCPUPercent = max(usage.PeakCPUPercent, value)
+	}
+	for _, value := range iowait {
+		usage.MeanIOWaitPercent += value / float64(len(iowait))
+	}
+	return usage, nil
+}
+
+// parseNodeMetrics summarizes sampler output. Rates come from consecutive samples, so at
+// least two are needed.
+func parseNodeMetrics(output string) (*ResourceUsage, error) {
+	var samples [][]float64
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 10 {
+			continue
+		}
+		values := make([]float64, len(fields))
+		valid := true
+		for i, field := range fields {
+			value, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			values[i] = value
+		}
+		if valid {
+			samples = append(samples, values)
+		}
+	}
+	if len(samples) < 2 {
+		return nil, fmt.Errorf("only %d node metric samples recorded", len(samples))
+	}
+
+	usage := &ResourceUsage{Samples: len(samples)}
+	for _, sample := range samples {
+		if total := sample[4]; total > 0 {
+			usage.PeakMemoryPercent = max(usage.PeakMemoryPercent, 100*(total-sample[5])/total)
+		}
+	}
+
+	first, last := samples[0], samples[len(samples)-1]
+	if total := last[3] - first[3]; total > 0 {
+		usage.MeanCPUPercent = 100 * (last[1] - first[1]) / total
+		usage.MeanIOWaitPercent = 100 * (last[2] - first[2]) / total
+	}
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		if total := cur[3] - prev[3]; total > 0 {
+			usage.PeakCPUPercent = max(usage.PeakCPUPercent, 100*(cur[1]-prev[1])/total)
+		}
+		if seconds := cur[0] - prev[0]; seconds > 0 {
+			usage.PeakNetworkMBps = max(usage.PeakNetworkMBps, (cur[6]-prev[6]+cur[7]-prev[7])/seconds/1e6)
+			usage.PeakLustreMBps = max(usage.PeakLustreMBps, (cur[8]-prev[8]+cur[9]-prev[9])/seconds/1e6)
+		}
+	}
+	return usage, nil
+}
+
+// Report renders the usage with hints for choosing the next instance type
+func (u *ResourceUsage) Report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📈 Node usage (%d samples): memory peak %.0f%%, CPU mean %.0f%% (peak %.0f%%), I/O wait %.0f%%\n",
+		u.Samples, u.PeakMemoryPercent, u.MeanCPUPercent, u.PeakCPUPercent, u.MeanIOWaitPercent)
+	fmt.Fprintf(&b, "   Peak network %.0f MB/s", u.PeakNetworkMBps)
+	if u.PeakLustreMBps > 0 {
+		fmt.Fprintf(&b, ", FSx for Lustre %.0f MB/s", u.PeakLustreMBps)
+	}
+	b.WriteString("\n")
+	for _, hint := range u.Hints() {
+		fmt.Fprintf(&b, "   💡 %s\n", hint)
+	}
+	return b.String()
+}
+
+// SizingSuggestion compares a run's measured usage to its instance and suggests a better
+// fit. It returns nil when usage wasn't measured or the run failed too early to be telling;
+// runs that ran out of memory or paused still count.
+func SizingSuggestion(config Config, result *Result) *common.SizingRecord {
+	if result.Resources == nil {
+		return nil
+	}
+	if result.Err != nil && !errors.Is(result.Err, ErrOutOfMemory) && !errors.Is(result.Err, ErrPaused) {
+		return nil
+	}
+	record, err := common.SuggestSize(config.InstanceType, result.Resources.PeakMemoryPercent, result.Resources.MeanCPUPercent)
+	if err != nil {
+		return nil
+	}
+	record.Simulation = config.Simulation
+	record.Resolution = config.Resolution
+	record.PeakCPUPercent = result.Resources.PeakCPUPercent
+	return &record
+}
+
+// Hints suggests instance changes the measured usage points to
+func (u *ResourceUsage) Hints() []string {
+	var hints []string
+	if u.PeakMemoryPercent >= highMemoryPercent {
+		hints = append(hints, "memory was nearly exhausted; use a memory-optimized (r-family) instance or a coarser grid")
+	}
+	if u.MeanIOWaitPercent >= 20 {
+		hints = append(hints, "the run spent much of its time waiting on I/O; stage inputs locally or use FSx for Lustre")
+	} else if u.MeanCPUPercent > 0 && u.MeanCPUPercent < 50 {
+		hints = append(hints, "CPUs were mostly idle; a smaller instance would likely cost less per model day")
+	}
+	return hints
+}
@@ -0,0 +1,36 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+)
+
+// ErrOutOfMemory marks simulations killed for exhausting the memory available to them.
+// Schedulers wrap it so callers can move the run to an instance with more memory.
+var ErrOutOfMemory = errors.New("out of memory")
+
+// oomKillStatus is the exit status of a container whose process was SIGKILLed, as the OOM
+// killer does
+const oomKillStatus = 137
+
+// oomKernelMessages are kernel log lines the OOM killer writes
+const oomKernelMessages = "out of memory|oom-kill|killed process"
+
+// outOfMemory reports whether a failed simulation was killed for lack of memory: the kernel
+// logged an OOM kill, or the container was SIGKILLed while memory was nearly exhausted
+func (r *Runner) outOfMemory(ctx context.Context, sshBuilder *builder.SSHBuilder, runErr error, usage *ResourceUsage) bool {
+	// Run instances are fresh, so any OOM kill in the kernel log is this run's
+	output, err := sshBuilder.ExecuteCommand(ctx, "sudo dmesg | grep -Eic '"+oomKernelMessages+"'")
+	if err == nil && strings.TrimSpace(output) != "0" {
+		return true
+	}
+
+	var exitErr *gossh.ExitError
+	return errors.As(runErr, &exitErr) && exitErr.ExitStatus() == oomKillStatus &&
+		usage != nil && usage.PeakMemoryPercent >= highMemoryPercent
+}
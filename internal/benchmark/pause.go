@@ -0,0 +1,202 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+)
+
+// Checkpoint frequencies the image's run script understands
+const (
+	CheckpointDaily   = "daily"
+	CheckpointMonthly = "monthly"
+)
+
+// PauseTag is the instance tag that asks the simulation running on it to stop at its next
+// restart checkpoint. Its value is the PauseMode for the instance afterwards.
+const PauseTag = "geoschem:pause"
+
+// What happens to a paused run's instance
+const (
+	PauseStop      = "stop"      // Stopped, keeping its volumes until the run resumes
+	PauseTerminate = "terminate" // Terminated; the checkpoint and output are in S3
+)
+
+// QueueJobTag names the run queue job a run instance holds capacity for
+const QueueJobTag = "geoschem:queue-job"
+
+// simulationContainer names the simulation's container on a run instance, so a pause can stop it
+const simulationContainer = "geoschem-run"
+
+// pausePollInterval is how often a running simulation checks for a pause request, and then
+// for the checkpoint it is waiting on
+const pausePollInterval = time.Minute
+
+// pauseMarker is touched on the instance when a pause is requested; restart files newer
+// than it are checkpoints written after the request
+const pauseMarker = "~/bench/pause-requested"
+
+// ErrPaused reports a simulation that stopped at a restart checkpoint on request
+var ErrPaused = errors.New("paused at a restart checkpoint")
+
+// Checkpoint is the restart file a paused simulation stopped at
+type Checkpoint struct {
+	ModelDate  time.Time // Model time the restart is valid for, where the run resumes
+	S3URI      string    // Restart file in the run's output
+	Mode       string    // PauseStop or PauseTerminate
+	InstanceID string    // The run's instance, stopped rather than terminated under PauseStop
+}
+
+// RequestPause asks the simulation running on a run instance to stop at its next restart
+// checkpoint. The process running it saves the checkpoint and stops or terminates the instance.
+func RequestPause(ctx context.Context, cfg aws.Config, instanceID, mode string) error {
+	if mode != PauseStop && mode != PauseTerminate {
+		return fmt.Errorf("pause mode must be %s or %s, got %s", PauseStop, PauseTerminate, mode)
+	}
+
+	client := ec2.NewFromConfig(cfg)
+	out, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{instanceID}})
+	if err != nil {
+		return fmt.Errorf("looking up %s: %w", instanceID, err)
+	}
+	if len(out.Reservations) == 0 || len(out.Reservations[0].Instances) == 0 {
+		return fmt.Errorf("instance %s not found", instanceID)
+	}
+	instance := out.Reservations[0].Instances[0]
+	if !strings.HasPrefix(instanceTag(instance.Tags, "BuildTag"), "run-") {
+		return fmt.Errorf("%s isn't a simulation run instance", instanceID)
+	}
+	if instance.State == nil || instance.State.Name != types.InstanceStateNameRunning {
+		return fmt.Errorf("%s isn't running", instanceID)
+	}
+
+	_, err = client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: []string{instanceID},
+		Tags:      []types.Tag{{Key: aws.String(PauseTag), Value: aws.String(mode)}},
+	})
+	if err != nil {
+		return fmt.Errorf("tagging %s: %w", instanceID, err)
+	}
+	return nil
+}
+
+// PreemptQueuedRun asks the run holding a queue job's capacity to stop at its next restart
+// checkpoint and terminate its instance
+func PreemptQueuedRun(ctx context.Context, cfg aws.Config, jobID string) error {
+	client := ec2.NewFromConfig(cfg)
+	out, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{Filters: []types.Filter{
+		{Name: aws.String("tag:" + QueueJobTag), Values: []string{jobID}},
+		{Name: aws.String("instance-state-name"), Values: []string{string(types.InstanceStateNameRunning)}},
+	}})
+	if err != nil {
+		return fmt.Errorf("finding the instance of %s: %w", jobID, err)
+	}
+	for _, reservation := range out.Reservations {
+		for _, instance := range reservation.Instances {
+			return RequestPause(ctx, cfg, aws.ToString(instance.InstanceId), PauseTerminate)
+		}
+	}
+	return fmt.Errorf("no running instance holds %s", jobID)
+}
+
+// watchForPause waits for a pause request on the run instance while the simulation runs.
+// Once one arrives it waits for the simulation to write a restart file and stops the
+// container there. It returns nil if the simulation ends first or can't be paused.
+func (r *Runner) watchForPause(ctx context.Context, sshBuilder *builder.SSHBuilder, instanceID, outputDir string, config Config) *Checkpoint {
+	client := ec2.NewFromConfig(r.cfg)
+	var mode string
+	for mode == "" {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(pausePollInterval):
+		}
+
+		out, err := client.DescribeTags(ctx, &ec2.DescribeTagsInput{Filters: []types.Filter{
+			{Name: aws.String("resource-id"), Values: []string{instanceID}},
+			{Name: aws.String("key"), Values: []string{PauseTag}},
+		}})
+		if err != nil || len(out.Tags) == 0 {
+			continue
+		}
+		mode = aws.ToString(out.Tags[0].Value)
+	}
+
+	if config.OutputURI == "" {
+		fmt.Printf("⚠️  Ignoring the pause request: the run has no S3 output to keep its checkpoint in\n")
+		return nil
+	}
+	if config.Checkpoint == "" {
+		fmt.Printf("⚠️  The run doesn't write periodic restart files, so it can only pause at the end\n")
+	}
+	fmt.Printf("⏸️  Pause requested (%s); waiting for the next restart checkpoint...\n", mode)
+	if _, err := sshBuilder.ExecuteCommand(ctx, "touch "+pauseMarker); err != nil {
+		return nil
+	}
+
+	// A restart file untouched for a minute has been written completely
+	findCmd := fmt.Sprintf("cd %s && find . -name 'GEOSChem.Restart.*.nc4' -newer %s -mmin +1 | sort | tail -1", outputDir, pauseMarker)
+	var restart string
+	for restart == "" {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(pausePollInterval):
+		}
+		output, err := sshBuilder.ExecuteCommand(ctx, findCmd)
+		if err != nil {
+			continue
+		}
+		restart = strings.TrimPrefix(strings.TrimSpace(output), "./")
+	}
+
+	modelDate, err := restartModelDate(restart)
+	if err != nil {
+		fmt.Printf("Warning: can't pause at %s: %v\n", restart, err)
+		return nil
+	}
+	checkpoint := &Checkpoint{
+		ModelDate:  modelDate,
+		S3URI:      strings.TrimSuffix(config.OutputURI, "/") + "/" + restart,
+		Mode:       mode,
+		InstanceID: instanceID,
+	}
+
+	fmt.Printf("💾 Checkpoint written for %s; stopping the simulation\n", modelDate.Format("2006-01-02 15:04"))
+	if output, err := sshBuilder.ExecuteCommand(ctx, "podman stop -t 60 "+simulationContainer); err != nil && ctx.Err() == nil {
+		// The simulation ending on its own also cancels ctx; anything else is a failed stop
+		fmt.Printf("Warning: could not stop the simulation: %v, output: %s\n", err, tail(output, 5))
+		return nil
+	}
+	return checkpoint
+}
+
+// restartModelDate parses the model time from a restart file name such as
+// GEOSChem.Restart.20190801_0000z.nc4
+func restartModelDate(file string) (time.Time, error) {
+	name := strings.TrimSuffix(strings.TrimPrefix(path.Base(file), "GEOSChem.Restart."), ".nc4")
+	date, err := time.Parse("20060102_1504z", name)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected restart file name %s", path.Base(file))
+	}
+	return date, nil
+}
+
+// instanceTag returns the value of an instance tag, or ""
+func instanceTag(tags []types.Tag, key string) string {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
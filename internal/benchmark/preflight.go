@@ -0,0 +1,354 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+	"github.com/scttfrdmn/geoschem-aws/internal/data"
+)
+
+// DefaultMetField is the met product runs are checked against when none is configured
+const DefaultMetField = "MERRA2"
+
+// A restart missing more than this fraction of the run's species is from another simulation
+const maxMissingSpeciesFraction = 0.5
+
+// PreflightReport lists the problems found before a run; errors block the run
+type PreflightReport struct {
+	Errors   []string
+	Warnings []string
+}
+
+// OK reports whether the run may proceed
+func (p *PreflightReport) OK() bool {
+	return len(p.Errors) == 0
+}
+
+// Err returns the blocking problems as one readable error, or nil
+func (p *PreflightReport) Err() error {
+	if p.OK() {
+		return nil
+	}
+	return fmt.Errorf("preflight found %d problem(s):\n  - %s", len(p.Errors), strings.Join(p.Errors, "\n  - "))
+}
+
+// Report renders the warnings and errors for the console
+func (p *PreflightReport) Report() string {
+	var report strings.Builder
+	for _, warning := range p.Warnings {
+		report.WriteString(fmt.Sprintf("⚠️  %s\n", warning))
+	}
+	for _, problem := range p.Errors {
+		report.WriteString(fmt.Sprintf("❌ %s\n", problem))
+	}
+	return report.String()
+}
+
+// Preflight checks what can be checked without an instance: the run period against the
+// met field archive and the resolution against the archived grids
+func (c *Config) Preflight() *PreflightReport {
+	report := &PreflightReport{}
+
+	start, startErr := time.Parse("2006-01-02", c.StartDate)
+	end, endErr := time.Parse("2006-01-02", c.EndDate)
+	if startErr != nil || endErr != nil {
+		report.Errors = append(report.Errors, "start and end dates must be YYYY-MM-DD")
+		return report
+	}
+
+	metField := c.metField()
+	warnings, err := data.CheckMetCoverage(metField, start, end)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	report.Warnings = append(report.Warnings, warnings...)
+
+	if _, err := data.MetGridFor(c.Resolution, metField); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	return report
+}
+
+func (c *Config) metField() string {
+	if c.MetField == "" {
+		return DefaultMetField
+	}
+	return c.MetField
+}
+
+// runDirFacts is what the preflight script reads from a generated run directory
+type runDirFacts struct {
+	RunDir          string
+	Config          bool // geoschem_config.yml was generated
+	StartDate       string
+	EndDate         string
+	MetField        string
+	Species         int
+	Restart         string
+	RestartSpecies  int
+	MissingSpecies  []string
+	Collections     []string
+	Frequencies     map[string]string
+	MetFilesPresent map[string]bool // Keyed by YYYYMMDD, only when input data is staged
+}
+
+// preflightRunDir generates the run directory with the container's dry run and checks the
+// files GeosChem will read, so configuration mistakes fail in seconds instead of mid-run
+func (r *Runner) preflightRunDir(ctx context.Context, sshBuilder *builder.SSHBuilder, config Config, image, outputDir, restartArg string) (*PreflightReport, error) {
+	// Emissions overrides are merged (and their files checked) as the run directory is generated
+	emissionsArg, err := config.emissionsArg()
+	if err != nil {
+		return nil, err
+	}
+	dryRunCmd := fmt.Sprintf("podman run --rm -v %[8]s:/workspace/data -v %[1]s:/workspace/output -v ~/bench/restart:/workspace/restart %[2]s classic --simulation %[3]s --resolution %[4]s --start-date %[5]s --end-date %[6]s%[7]s --dry-run",
+		outputDir, image, config.Simulation, config.Resolution, config.StartDate, config.EndDate, restartArg+emissionsArg, config.dataDir())
+	if output, err := sshBuilder.ExecuteCommand(ctx, dryRunCmd); err != nil {
+		return nil, fmt.Errorf("generating run directory: %w, output: %s", err, tail(output, 10))
+	}
+
+	script := fmt.Sprintf(`import glob, os, re
+dirs = sorted(glob.glob("/workspace/output/classic_*"), key=os.path.getmtime)
+if dirs:
+    d = dirs[-1]
+    print("RUNDIR", d)
+    cfg = os.path.join(d, "geoschem_config.yml")
+    species = []
+    if os.path.exists(cfg):
+        print("CONFIG")
+        in_species = False
+        for line in open(cfg):
+            m = re.match(r"\s*(start_date|end_date):\s*\[(\d{8})", line)
+            if m:
+                print(m.group(1).upper(), m.group(2))
+            m = re.match(r"\s*met_field:\s*(\S+)", line)
+            if m:
+                print("MET", m.group(1))
+            if re.match(r"\s*transported_species:", line):
+                in_species = True
+                continue
+            if in_species:
+                m = re.match(r"\s*-\s*(\S+)", line)
+                if m:
+                    species.append(m.group(1))
+                elif line.strip():
+                    in_species = False
+        print("SPECIES", len(species))
+    history = os.path.join(d, "HISTORY.rc")
+    if os.path.exists(history):
+        text = open(history).read()
+        m = re.search(r"^COLLECTIONS:(.*?)^::", text, re.M | re.S)
+        if m:
+            for name in re.findall(r"^\s*'([^']+)'", m.group(1), re.M):
+                print("COLLECTION", name)
+        for name, value in re.findall(r"^\s*(\w+)\.frequency:\s*(.+?)\s*,?\s*$", text, re.M):
+            print("FREQUENCY", name, re.sub(r"\s+", "_", value).strip("'"))
+    restarts = glob.glob(os.path.join(d, "GEOSChem.Restart.*.nc4"))
+    if restarts:
+        import xarray as xr
+        ds = xr.open_dataset(restarts[0])
+        names = set(v.split("_", 1)[1] for v in ds.data_vars if v.startswith(("SpeciesRst_", "SPC_")))
+        print("RESTART", os.path.basename(restarts[0]), len(names))
+        for s in species:
+            if s not in names:
+                print("MISSING", s)
+    if os.path.isdir("/workspace/data/GEOS_%[1]s"):
+        for day in %[2]q.split(","):
+            found = glob.glob("/workspace/data/GEOS_%[1]s/*/%%s/%%s/*.%%s.*" %% (day[:4], day[4:6], day))
+            print("METDAY", day, len(found))`, metGrid(config), strings.Join(metDays(config), ","))
+
+	cmd := fmt.Sprintf("podman run --rm -v %s:/workspace/data -v %s:/workspace/output --entrypoint python3 %s -c '%s'",
+		config.dataDir(), outputDir, image, strings.ReplaceAll(script, "'", `'"'"'`))
+	output, err := sshBuilder.ExecuteCommand(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("reading run directory: %w, output: %s", err, tail(output, 10))
+	}
+
+	facts := parseRunDirFacts(output)
+
+	// The real run generates its own directory
+	if facts.RunDir != "" {
+		cleanupCmd := fmt.Sprintf("podman run --rm -v %s:/workspace/output --entrypoint rm %s -rf %s", outputDir, image, facts.RunDir)
+		if output, err := sshBuilder.ExecuteCommand(ctx, cleanupCmd); err != nil {
+			fmt.Printf("Warning: could not remove preflight run directory: %v, output: %s\n", err, output)
+		}
+	}
+
+	return checkRunDir(config, facts), nil
+}
+
+// metGrid returns the met grid directory the run reads, falling back to the resolution
+func metGrid(config Config) string {
+	grid, err := data.MetGridFor(config.Resolution, config.metField())
+	if err != nil {
+		return config.Resolution
+	}
+	return grid
+}
+
+// metDays returns the first and last model days as YYYYMMDD
+func metDays(config Config) []string {
+	return []string{strings.ReplaceAll(config.StartDate, "-", ""), strings.ReplaceAll(config.EndDate, "-", "")}
+}
+
+// parseRunDirFacts reads the tagged lines printed by the preflight script
+func parseRunDirFacts(output string) *runDirFacts {
+	facts := &runDirFacts{
+		Frequencies:     make(map[string]string),
+		MetFilesPresent: make(map[string]bool),
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case fields[0] == "CONFIG":
+			facts.Config = true
+		case len(fields) < 2:
+			continue
+		case fields[0] == "RUNDIR":
+			facts.RunDir = fields[1]
+		case fields[0] == "START_DATE":
+			facts.StartDate = fields[1]
+		case fields[0] == "END_DATE":
+			facts.EndDate = fields[1]
+		case fields[0] == "MET":
+			facts.MetField = strings.Trim(fields[1], `'"`)
+		case fields[0] == "SPECIES":
+			facts.Species, _ = strconv.Atoi(fields[1])
+		case fields[0] == "COLLECTION":
+			facts.Collections = append(facts.Collections, fields[1])
+		case fields[0] == "MISSING":
+			facts.MissingSpecies = append(facts.MissingSpecies, fields[1])
+		case fields[0] == "RESTART" && len(fields) == 3:
+			facts.Restart = fields[1]
+			facts.RestartSpecies, _ = strconv.Atoi(fields[2])
+		case fields[0] == "FREQUENCY" && len(fields) == 3:
+			facts.Frequencies[fields[1]] = fields[2]
+		case fields[0] == "METDAY" && len(fields) == 3:
+			count, _ := strconv.Atoi(fields[2])
+			facts.MetFilesPresent[fields[1]] = count > 0
+		}
+	}
+	return facts
+}
+
+// checkRunDir compares the generated run directory with the requested run
+func checkRunDir(config Config, facts *runDirFacts) *PreflightReport {
+	report := &PreflightReport{}
+
+	if facts.RunDir == "" {
+		report.Errors = append(report.Errors, "the image's dry run did not create a run directory")
+		return report
+	}
+	if !facts.Config {
+		report.Warnings = append(report.Warnings, "no geoschem_config.yml in the run directory; dates and species could not be checked")
+	} else {
+		wantStart, wantEnd := metDays(config)[0], metDays(config)[1]
+		if facts.StartDate != wantStart || facts.EndDate != wantEnd {
+			report.Errors = append(report.Errors, fmt.Sprintf("geoschem_config.yml runs %s to %s, requested %s to %s",
+				facts.StartDate, facts.EndDate, wantStart, wantEnd))
+		}
+		if facts.MetField != "" && facts.MetField != config.metField() {
+			report.Errors = append(report.Errors, fmt.Sprintf("run directory reads %s met fields, run was planned for %s",
+				facts.MetField, config.metField()))
+		}
+	}
+
+	if facts.Restart != "" {
+		switch {
+		case facts.RestartSpecies == 0:
+			report.Errors = append(report.Errors, fmt.Sprintf("restart file %s contains no species", facts.Restart))
+		case facts.Species > 0 && float64(len(facts.MissingSpecies)) > maxMissingSpeciesFraction*float64(facts.Species):
+			report.Errors = append(report.Errors, fmt.Sprintf("restart file %s is missing %d of %d species; it is probably from a different simulation",
+				facts.Restart, len(facts.MissingSpecies), facts.Species))
+		case len(facts.MissingSpecies) > 0:
+			report.Warnings = append(report.Warnings, fmt.Sprintf("restart file %s is missing %d species, which start from background values: %s",
+				facts.Restart, len(facts.MissingSpecies), abbreviate(facts.MissingSpecies, 8)))
+		}
+	}
+
+	report.Errors = append(report.Errors, checkFrequencies(config, facts)...)
+
+	for _, day := range metDays(config) {
+		if present, checked := facts.MetFilesPresent[day]; checked && !present {
+			report.Errors = append(report.Errors, fmt.Sprintf("no met fields for %s in the staged input data", day))
+		}
+	}
+
+	return report
+}
+
+// checkFrequencies flags active HISTORY collections that never write during the run
+func checkFrequencies(config Config, facts *runDirFacts) []string {
+	modelDays, err := config.ModelDays()
+	if err != nil {
+		return nil
+	}
+
+	var problems []string
+	for _, collection := range facts.Collections {
+		value, ok := facts.Frequencies[collection]
+		if !ok || strings.EqualFold(value, "End") {
+			continue
+		}
+		frequency, err := parseHistoryInterval(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("HISTORY.rc collection %s: %v", collection, err))
+			continue
+		}
+		switch {
+		case frequency <= 0:
+			problems = append(problems, fmt.Sprintf("HISTORY.rc collection %s has a zero output frequency", collection))
+		case frequency.Hours()/24 > modelDays:
+			problems = append(problems, fmt.Sprintf("HISTORY.rc collection %s writes every %s, longer than the %.0f-day run, so it would write nothing",
+				collection, strings.ReplaceAll(value, "_", " "), modelDays))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// parseHistoryInterval reads a HISTORY.rc "YYYYMMDD_hhmmss" interval. Months and years
+// are counted as 30 and 365 days, which is close enough to compare with the run length.
+func parseHistoryInterval(value string) (time.Duration, error) {
+	parts := strings.Split(value, "_")
+	if len(parts) != 2 || len(parts[0]) != 8 || len(parts[1]) != 6 {
+		return 0, fmt.Errorf("unrecognized frequency %q (expected 'YYYYMMDD hhmmss')", strings.ReplaceAll(value, "_", " "))
+	}
+
+	var fields [6]int
+	bounds := [][2]int{{0, 4}, {4, 6}, {6, 8}}
+	for i, b := range bounds {
+		n, err := strconv.Atoi(parts[0][b[0]:b[1]])
+		if err != nil {
+			return 0, fmt.Errorf("unrecognized frequency %q", value)
+		}
+		fields[i] = n
+	}
+	for i := 0; i < 3; i++ {
+		n, err := strconv.Atoi(parts[1][i*2 : i*2+2])
+		if err != nil {
+			return 0, fmt.Errorf("unrecognized frequency %q", value)
+		}
+		fields[3+i] = n
+	}
+
+	days := fields[0]*365 + fields[1]*30 + fields[2]
+	return time.Duration(days)*24*time.Hour + time.Duration(fields[3])*time.Hour +
+		time.Duration(fields[4])*time.Minute + time.Duration(fields[5])*time.Second, nil
+}
+
+// abbreviate joins the first n names and counts the rest
+func abbreviate(names []string, n int) string {
+	if len(names) <= n {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s, and %d more", strings.Join(names[:n], ", "), len(names)-n)
+}
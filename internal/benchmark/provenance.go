@@ -0,0 +1,69 @@
+package benchmark
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/hemco"
+	"github.com/scttfrdmn/geoschem-aws/internal/provenance"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// ResultSettings returns the settings that shape a run's results, apart from its dates, which
+// restarts and results catalog searches match on their own. A run that starts from a restart
+// file rather than the model's initial conditions includes it.
+func (c Config) ResultSettings() map[string]string {
+	settings := map[string]string{
+		"simulation": c.Simulation,
+		"resolution": c.Resolution,
+		"met_field":  c.metField(),
+	}
+	if c.EmissionsYear != 0 {
+		settings["emissions_year"] = strconv.Itoa(c.EmissionsYear)
+	}
+	if overrides, _ := hemco.Encode(c.Emissions); len(c.Emissions) > 0 {
+		settings["emissions_overrides"] = overrides
+	}
+	if c.RestartURI != "" {
+		settings["restart"] = c.RestartURI
+	}
+	return settings
+}
+
+// ConfigHash fingerprints the run's full configuration and the image it runs, for restarts
+// and the results catalog (see state.ConfigHash)
+func (c Config) ConfigHash(image string) string {
+	return state.ConfigHash(image, c.ResultSettings())
+}
+
+// ProvenanceRun describes a finished run for the provenance log: the settings that shape
+// its results and the inputs it read
+func ProvenanceRun(config Config, image, scheduler string) provenance.Run {
+	settings := config.ResultSettings()
+	settings["start_date"] = config.StartDate
+	settings["end_date"] = config.EndDate
+	settings["checkpoint"] = config.Checkpoint
+	if filters, _ := config.DataSyncFilters(); len(filters) > 0 {
+		settings["data_sync_filters"] = strings.Join(filters, " ")
+	}
+
+	inputs := make(map[string]string)
+	if config.DataSource != "" {
+		inputs["data_source"] = config.DataSource
+		for _, file := range hemco.Files(config.Emissions) {
+			inputs["hemco/"+file] = strings.TrimSuffix(config.DataSource, "/") + "/HEMCO/" + file
+		}
+	}
+	if config.RestartURI != "" {
+		inputs["restart"] = config.RestartURI
+	}
+
+	return provenance.Run{
+		Image:        image,
+		InstanceType: config.InstanceType,
+		Scheduler:    scheduler,
+		OutputURI:    config.OutputURI,
+		Settings:     settings,
+		Inputs:       inputs,
+	}
+}
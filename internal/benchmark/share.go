@@ -0,0 +1,68 @@
+package benchmark
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// PerformanceRecord builds the anonymized dataset entry for a successful result
+func PerformanceRecord(config Config, result *Result) common.PerformanceRecord {
+	processor, _ := common.InstanceProcessor(config.InstanceType)
+
+	record := common.PerformanceRecord{
+		SchemaVersion:    common.PerformanceRecordVersion,
+		InstanceType:     config.InstanceType,
+		Architecture:     common.InstanceArchitecture(config.InstanceType),
+		Processor:        processor,
+		ImageConfig:      common.ImageConfigName(result.Image),
+		Simulation:       config.Simulation,
+		Resolution:       config.Resolution,
+		ModelDays:        result.ModelDays,
+		WallClockSeconds: result.WallClock.Seconds(),
+		ModelDaysPerDay:  result.Throughput,
+		RecordedDate:     time.Now().UTC().Format("2006-01-02"),
+	}
+	if result.Resources != nil {
+		record.PeakMemoryPercent = result.Resources.PeakMemoryPercent
+		record.CPUPercent = result.Resources.MeanCPUPercent
+	}
+	return record
+}
+
+// shareResult uploads an anonymized record to the shared dataset from the benchmark instance,
+// using its instance profile credentials
+func (r *Runner) shareResult(ctx context.Context, sshBuilder *builder.SSHBuilder, config Config, result *Result) error {
+	record := PerformanceRecord(config, result)
+	if err := record.Validate(); err != nil {
+		return fmt.Errorf("invalid performance record: %w", err)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encoding performance record: %w", err)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("generating record ID: %w", err)
+	}
+
+	key := fmt.Sprintf("%s/v%d/%s/%s.json", strings.TrimSuffix(config.ShareDataset, "/"),
+		common.PerformanceRecordVersion, record.InstanceType, hex.EncodeToString(id))
+
+	cmd := fmt.Sprintf("echo '%s' | aws s3 cp --only-show-errors - %s", strings.ReplaceAll(string(data), "'", `'"'"'`), key)
+	if output, err := sshBuilder.ExecuteCommand(ctx, cmd); err != nil {
+		return fmt.Errorf("uploading performance record: %w, output: %s", err, output)
+	}
+
+	fmt.Printf("📤 Shared anonymized result to %s\n", key)
+	return nil
+}
@@ -0,0 +1,160 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/builder"
+)
+
+// SummarySpecies are the species reported in the quick-look output summary
+var SummarySpecies = []string{"SpeciesConc_O3", "SpeciesConc_CO", "SpeciesConc_NO2", "SpeciesConc_SO2"}
+
+// Mass drift of a passive tracer beyond this fraction indicates a transport or restart problem
+const maxPassiveMassDrift = 0.01
+
+// SpeciesStats holds global statistics of one species in the final output
+type SpeciesStats struct {
+	Mean float64
+	Min  float64
+	Max  float64
+}
+
+// OutputSummary is a quick-look check of a run's output NetCDF files
+type OutputSummary struct {
+	Files            int
+	Species          map[string]SpeciesStats
+	PassiveMassDrift float64 // Fractional change of passive tracer mass, NaN when not available
+	NonFinite        []string
+	Warnings         []string
+}
+
+// OK reports whether the summary found nothing suspicious
+func (s *OutputSummary) OK() bool {
+	return len(s.Warnings) == 0
+}
+
+// summarizeOutput computes the quick-look summary with the image's own Python stack
+func (r *Runner) summarizeOutput(ctx context.Context, sshBuilder *builder.SSHBuilder, image, outputDir string) (*OutputSummary, error) {
+	script := fmt.Sprintf(`import glob, numpy as np, xarray as xr
+files = sorted(glob.glob("/workspace/output/**/GEOSChem.SpeciesConc*.nc4", recursive=True))
+print("FILES", len(files))
+if files:
+    last = xr.open_dataset(files[-1])
+    for v in %q.split(","):
+        if v in last:
+            a = last[v].values
+            if not np.all(np.isfinite(a)):
+                print("NONFINITE", v)
+            else:
+                print("STAT", v, float(a.mean()), float(a.min()), float(a.max()))
+    met = sorted(glob.glob("/workspace/output/**/GEOSChem.StateMet*.nc4", recursive=True))
+    tracers = [v for v in last.data_vars if v.startswith("SpeciesConc_Passive")]
+    if met and tracers:
+        first = xr.open_dataset(files[0]); m0 = xr.open_dataset(met[0]); m1 = xr.open_dataset(met[-1])
+        v = tracers[0]
+        before = float((first[v].isel(time=0) * m0["Met_AD"].isel(time=0)).sum())
+        after = float((last[v].isel(time=-1) * m1["Met_AD"].isel(time=-1)).sum())
+        if before > 0:
+            print("MASS", v, (after - before) / before)`, strings.Join(SummarySpecies, ","))
+
+	cmd := fmt.Sprintf("podman run --rm -v %s:/workspace/output --entrypoint python3 %s -c '%s'", outputDir, image, strings.ReplaceAll(script, "'", `'"'"'`))
+	output, err := sshBuilder.ExecuteCommand(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%w, output: %s", err, tail(output, 10))
+	}
+
+	return parseSummary(output), nil
+}
+
+// parseSummary reads the tagged lines printed by the summary script and flags problems
+func parseSummary(output string) *OutputSummary {
+	summary := &OutputSummary{
+		Species:          make(map[string]SpeciesStats),
+		PassiveMassDrift: math.NaN(),
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "FILES":
+			summary.Files, _ = strconv.Atoi(fields[1])
+		case "NONFINITE":
+			summary.NonFinite = append(summary.NonFinite, fields[1])
+		case "STAT":
+			if len(fields) != 5 {
+				continue
+			}
+			var values [3]float64
+			for i := range values {
+				values[i], _ = strconv.ParseFloat(fields[i+2], 64)
+			}
+			summary.Species[fields[1]] = SpeciesStats{Mean: values[0], Min: values[1], Max: values[2]}
+		case "MASS":
+			if len(fields) == 3 {
+				summary.PassiveMassDrift, _ = strconv.ParseFloat(fields[2], 64)
+			}
+		}
+	}
+
+	if summary.Files == 0 {
+		summary.Warnings = append(summary.Warnings, "no SpeciesConc output files were written")
+	}
+	for _, name := range summary.NonFinite {
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("%s contains NaN or Inf values", name))
+	}
+	for name, stats := range summary.Species {
+		if stats.Min < 0 {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("%s has negative concentrations (min %.3g)", name, stats.Min))
+		}
+		if stats.Max == 0 {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("%s is zero everywhere", name))
+		}
+	}
+	if math.Abs(summary.PassiveMassDrift) > maxPassiveMassDrift {
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("passive tracer mass changed by %+.2f%%", summary.PassiveMassDrift*100))
+	}
+	sort.Strings(summary.Warnings)
+
+	return summary
+}
+
+// Report renders the summary for the console and completion notifications
+func (s *OutputSummary) Report() string {
+	var report strings.Builder
+
+	report.WriteString(fmt.Sprintf("🔎 Output summary (%d SpeciesConc files)\n", s.Files))
+
+	var names []string
+	for name := range s.Species {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		stats := s.Species[name]
+		report.WriteString(fmt.Sprintf("   %s: mean %.4g, min %.4g, max %.4g mol/mol\n",
+			strings.TrimPrefix(name, "SpeciesConc_"), stats.Mean, stats.Min, stats.Max))
+	}
+
+	if math.IsNaN(s.PassiveMassDrift) {
+		report.WriteString("   Mass conservation: not checked (no passive tracer or StateMet output)\n")
+	} else {
+		report.WriteString(fmt.Sprintf("   Mass conservation: passive tracer %+.4f%%\n", s.PassiveMassDrift*100))
+	}
+
+	if s.OK() {
+		report.WriteString("   ✅ No problems found\n")
+	}
+	for _, warning := range s.Warnings {
+		report.WriteString(fmt.Sprintf("   ⚠️  %s\n", warning))
+	}
+
+	return report.String()
+}
@@ -0,0 +1,529 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/network"
+)
+
+// Resources bootstrap creates carry this tag, and teardown deletes only resources with it
+const (
+	ManagedByTag   = "ManagedBy"
+	ManagedByValue = "geoschem-aws-bootstrap"
+)
+
+const (
+	vpcName           = "geoschem-vpc"
+	vpcCIDR           = "10.42.0.0/16"
+	securityGroupName = "geoschem-instances"
+	roleName          = "geoschem-ec2-builder-role"
+	s3PolicyName      = "geoschem-s3"
+	gitPolicyName     = "geoschem-git-secrets"
+)
+
+// rolePolicies are the managed policies build and run instances need: pushing and pulling
+// images, and SSM for the ssm backend and Session Manager
+var rolePolicies = []string{
+	"arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryPowerUser",
+	"arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore",
+}
+
+// ec2TrustPolicy lets EC2 instances assume the role
+const ec2TrustPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}]}`
+
+// s3Policy gives instances the platform's buckets for input data, ccache and output
+const s3Policy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:ListBucket","s3:GetObject","s3:PutObject","s3:DeleteObject"],"Resource":["arn:aws:s3:::geoschem-*","arn:aws:s3:::geoschem-*/*"]}]}`
+
+// gitPolicy lets instances read private repositories' clone credentials, kept in Secrets
+// Manager under common.GitSecretPrefix
+var gitPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"secretsmanager:GetSecretValue","Resource":"arn:aws:secretsmanager:*:*:secret:` + common.GitSecretPrefix + `*"}]}`
+
+// Options control what bootstrap creates
+type Options struct {
+	VPC        string // Existing VPC and its subnets to use, or "default"; empty creates a dedicated VPC
+	Zones      int    // Availability zones to spread subnets over (default 2)
+	Repository string // ECR repository name (default geoschem)
+	SSHCIDR    string // Source allowed to reach SSH; empty means the caller's public IP
+	SSMOnly    bool   // Allow no SSH at all, for the ssm backend
+}
+
+// Resources are the IDs bootstrap found or created, ready for the config
+type Resources struct {
+	VPCID           string
+	SubnetIDs       []string
+	SecurityGroupID string
+	RepositoryURI   string
+	InstanceProfile string
+	Created         []string // Descriptions of what this run created; everything else already existed
+}
+
+// Bootstrapper creates or discovers the network, registry and IAM resources the platform
+// needs, so a first build needs no hand-made subnet or security group
+type Bootstrapper struct {
+	ec2Client *ec2.Client
+	ecrClient *ecr.Client
+	network   *network.Manager
+	iam       *awscli.Client // The platform links no IAM SDK
+	region    string
+}
+
+// New creates a bootstrapper for a region
+func New(cfg aws.Config, profile, region string) *Bootstrapper {
+	return &Bootstrapper{
+		ec2Client: ec2.NewFromConfig(cfg),
+		ecrClient: ecr.NewFromConfig(cfg),
+		network:   network.NewManager(cfg),
+		iam:       awscli.New(profile, region),
+		region:    region,
+	}
+}
+
+// Run finds or creates every resource. It is safe to run again: resources from an earlier
+// run, complete or not, are reused.
+func (b *Bootstrapper) Run(ctx context.Context, opts Options) (*Resources, error) {
+	if opts.Zones <= 0 {
+		opts.Zones = 2
+	}
+	if opts.Repository == "" {
+		opts.Repository = "geoschem"
+	}
+	resources := &Resources{}
+
+	var err error
+	if opts.VPC != "" {
+		err = b.useExistingVPC(ctx, opts, resources)
+	} else {
+		err = b.ensureVPC(ctx, opts, resources)
+	}
+	if err != nil {
+		return resources, err
+	}
+	if err := b.ensureSecurityGroup(ctx, opts, resources); err != nil {
+		return resources, err
+	}
+	if err := b.ensureRepository(ctx, opts.Repository, resources); err != nil {
+		return resources, err
+	}
+	if err := b.ensureInstanceProfile(ctx, resources); err != nil {
+		return resources, err
+	}
+	return resources, nil
+}
+
+// useExistingVPC picks subnets in distinct zones of an existing VPC, preferring ones that
+// give instances public IPs, which SSH from outside the VPC needs
+func (b *Bootstrapper) useExistingVPC(ctx context.Context, opts Options, resources *Resources) error {
+	filter := types.Filter{Name: aws.String("vpc-id"), Values: []string{opts.VPC}}
+	if opts.VPC == "default" {
+		filter = types.Filter{Name: aws.String("is-default"), Values: []string{"true"}}
+	}
+	vpcs, err := b.ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{Filters: []types.Filter{filter}})
+	if err != nil {
+		return fmt.Errorf("finding VPC %s: %w", opts.VPC, err)
+	}
+	if len(vpcs.Vpcs) == 0 {
+		return fmt.Errorf("VPC %s not found in %s", opts.VPC, b.region)
+	}
+	resources.VPCID = aws.ToString(vpcs.Vpcs[0].VpcId)
+
+	subnets, err := b.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []types.Filter{{Name: aws.String("vpc-id"), Values: []string{resources.VPCID}}},
+	})
+	if err != nil {
+		return fmt.Errorf("listing subnets of %s: %w", resources.VPCID, err)
+	}
+	candidates := subnets.Subnets
+	sort.SliceStable(candidates, func(i, j int) bool {
+		iPublic, jPublic := aws.ToBool(candidates[i].MapPublicIpOnLaunch), aws.ToBool(candidates[j].MapPublicIpOnLaunch)
+		if iPublic != jPublic {
+			return iPublic
+		}
+		return aws.ToString(candidates[i].AvailabilityZone) < aws.ToString(candidates[j].AvailabilityZone)
+	})
+	zones := make(map[string]bool)
+	for _, subnet := range candidates {
+		zone := aws.ToString(subnet.AvailabilityZone)
+		if zones[zone] || len(resources.SubnetIDs) == opts.Zones {
+			continue
+		}
+		if !opts.SSMOnly && !aws.ToBool(subnet.MapPublicIpOnLaunch) {
+			continue
+		}
+		zones[zone] = true
+		resources.SubnetIDs = append(resources.SubnetIDs, aws.ToString(subnet.SubnetId))
+	}
+	if len(resources.SubnetIDs) == 0 {
+		if opts.SSMOnly {
+			return fmt.Errorf("VPC %s has no subnets", resources.VPCID)
+		}
+		return fmt.Errorf("VPC %s has no subnets that assign public IPs; SSH builds need one (or pass --ssm-only)", resources.VPCID)
+	}
+	return nil
+}
+
+// ensureVPC finds or creates the dedicated VPC: public subnets in each zone behind an
+// internet gateway, and an S3 gateway endpoint so data and output transfers skip it
+func (b *Bootstrapper) ensureVPC(ctx context.Context, opts Options, resources *Resources) error {
+	vpcs, err := b.ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{Filters: managedFilters(vpcName)})
+	if err != nil {
+		return fmt.Errorf("finding VPC %s: %w", vpcName, err)
+	}
+	if len(vpcs.Vpcs) > 0 {
+		resources.VPCID = aws.ToString(vpcs.Vpcs[0].VpcId)
+	} else {
+		created, err := b.ec2Client.CreateVpc(ctx, &ec2.CreateVpcInput{
+			CidrBlock:         aws.String(vpcCIDR),
+			TagSpecifications: tagSpecifications(types.ResourceTypeVpc, vpcName),
+		})
+		if err != nil {
+			return fmt.Errorf("creating VPC: %w", err)
+		}
+		resources.VPCID = aws.ToString(created.Vpc.VpcId)
+		resources.Created = append(resources.Created, "VPC "+resources.VPCID)
+		if err := ec2.NewVpcAvailableWaiter(b.ec2Client).Wait(ctx,
+			&ec2.DescribeVpcsInput{VpcIds: []string{resources.VPCID}}, 2*time.Minute); err != nil {
+			return fmt.Errorf("waiting for VPC %s: %w", resources.VPCID, err)
+		}
+		// Instances resolve each other and the EFS mount targets by name
+		if _, err := b.ec2Client.ModifyVpcAttribute(ctx, &ec2.ModifyVpcAttributeInput{
+			VpcId:              aws.String(resources.VPCID),
+			EnableDnsHostnames: &types.AttributeBooleanValue{Value: aws.Bool(true)},
+		}); err != nil {
+			return fmt.Errorf("enabling DNS hostnames in %s: %w", resources.VPCID, err)
+		}
+	}
+
+	gatewayID, err := b.ensureInternetGateway(ctx, resources)
+	if err != nil {
+		return err
+	}
+	routeTableID, err := b.ensureRouteTable(ctx, resources, gatewayID)
+	if err != nil {
+		return err
+	}
+	if err := b.ensureS3Endpoint(ctx, resources, routeTableID); err != nil {
+		return err
+	}
+	return b.ensureSubnets(ctx, opts.Zones, resources, routeTableID)
+}
+
+func (b *Bootstrapper) ensureInternetGateway(ctx context.Context, resources *Resources) (string, error) {
+	gateways, err := b.ec2Client.DescribeInternetGateways(ctx, &ec2.DescribeInternetGatewaysInput{
+		Filters: []types.Filter{{Name: aws.String("attachment.vpc-id"), Values: []string{resources.VPCID}}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("finding internet gateway: %w", err)
+	}
+	if len(gateways.InternetGateways) > 0 {
+		return aws.ToString(gateways.InternetGateways[0].InternetGatewayId), nil
+	}
+
+	created, err := b.ec2Client.CreateInternetGateway(ctx, &ec2.CreateInternetGatewayInput{
+		TagSpecifications: tagSpecifications(types.ResourceTypeInternetGateway, vpcName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("creating internet gateway: %w", err)
+	}
+	gatewayID := aws.ToString(created.InternetGateway.InternetGatewayId)
+	resources.Created = append(resources.Created, "internet gateway "+gatewayID)
+	if _, err := b.ec2Client.AttachInternetGateway(ctx, &ec2.AttachInternetGatewayInput{
+		InternetGatewayId: aws.String(gatewayID),
+		VpcId:             aws.String(resources.VPCID),
+	}); err != nil {
+		return "", fmt.Errorf("attaching internet gateway %s: %w", gatewayID, err)
+	}
+	return gatewayID, nil
+}
+
+func (b *Bootstrapper) ensureRouteTable(ctx context.Context, resources *Resources, gatewayID string) (string, error) {
+	tables, err := b.ec2Client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{
+		Filters: append(managedFilters(vpcName), types.Filter{Name: aws.String("vpc-id"), Values: []string{resources.VPCID}}),
+	})
+	if err != nil {
+		return "", fmt.Errorf("finding route table: %w", err)
+	}
+	var routeTableID string
+	if len(tables.RouteTables) > 0 {
+		routeTableID = aws.ToString(tables.RouteTables[0].RouteTableId)
+		for _, route := range tables.RouteTables[0].Routes {
+			if aws.ToString(route.DestinationCidrBlock) == "0.0.0.0/0" {
+				return routeTableID, nil
+			}
+		}
+	} else {
+		created, err := b.ec2Client.CreateRouteTable(ctx, &ec2.CreateRouteTableInput{
+			VpcId:             aws.String(resources.VPCID),
+			TagSpecifications: tagSpecifications(types.ResourceTypeRouteTable, vpcName),
+		})
+		if err != nil {
+			return "", fmt.Errorf("creating route table: %w", err)
+		}
+		routeTableID = aws.ToString(created.RouteTable.RouteTableId)
+		resources.Created = append(resources.Created, "route table "+routeTableID)
+	}
+
+	if _, err := b.ec2Client.CreateRoute(ctx, &ec2.CreateRouteInput{
+		RouteTableId:         aws.String(routeTableID),
+		DestinationCidrBlock: aws.String("0.0.0.0/0"),
+		GatewayId:            aws.String(gatewayID),
+	}); err != nil {
+		return "", fmt.Errorf("adding default route to %s: %w", routeTableID, err)
+	}
+	return routeTableID, nil
+}
+
+func (b *Bootstrapper) ensureS3Endpoint(ctx context.Context, resources *Resources, routeTableID string) error {
+	service := fmt.Sprintf("com.amazonaws.%s.s3", b.region)
+	endpoints, err := b.ec2Client.DescribeVpcEndpoints(ctx, &ec2.DescribeVpcEndpointsInput{
+		Filters: []types.Filter{
+			{Name: aws.String("vpc-id"), Values: []string{resources.VPCID}},
+			{Name: aws.String("service-name"), Values: []string{service}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("finding S3 endpoint: %w", err)
+	}
+	if len(endpoints.VpcEndpoints) > 0 {
+		return nil
+	}
+
+	created, err := b.ec2Client.CreateVpcEndpoint(ctx, &ec2.CreateVpcEndpointInput{
+		VpcId:             aws.String(resources.VPCID),
+		ServiceName:       aws.String(service),
+		VpcEndpointType:   types.VpcEndpointTypeGateway,
+		RouteTableIds:     []string{routeTableID},
+		TagSpecifications: tagSpecifications(types.ResourceTypeVpcEndpoint, vpcName+"-s3"),
+	})
+	if err != nil {
+		return fmt.Errorf("creating S3 endpoint: %w", err)
+	}
+	resources.Created = append(resources.Created, "S3 gateway endpoint "+aws.ToString(created.VpcEndpoint.VpcEndpointId))
+	return nil
+}
+
+// ensureSubnets finds or creates a public /20 subnet in each of the first zones
+func (b *Bootstrapper) ensureSubnets(ctx context.Context, count int, resources *Resources, routeTableID string) error {
+	zones, err := b.ec2Client.DescribeAvailabilityZones(ctx, &ec2.DescribeAvailabilityZonesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("zone-type"), Values: []string{"availability-zone"}},
+			{Name: aws.String("state"), Values: []string{"available"}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("listing availability zones: %w", err)
+	}
+	var names []string
+	for _, zone := range zones.AvailabilityZones {
+		names = append(names, aws.ToString(zone.ZoneName))
+	}
+	sort.Strings(names)
+	if len(names) > count {
+		names = names[:count]
+	}
+
+	existing, err := b.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []types.Filter{{Name: aws.String("vpc-id"), Values: []string{resources.VPCID}}},
+	})
+	if err != nil {
+		return fmt.Errorf("listing subnets of %s: %w", resources.VPCID, err)
+	}
+	byZone := make(map[string]string)
+	for _, subnet := range existing.Subnets {
+		byZone[aws.ToString(subnet.AvailabilityZone)] = aws.ToString(subnet.SubnetId)
+	}
+
+	for i, zone := range names {
+		if subnetID, ok := byZone[zone]; ok {
+			resources.SubnetIDs = append(resources.SubnetIDs, subnetID)
+			continue
+		}
+		created, err := b.ec2Client.CreateSubnet(ctx, &ec2.CreateSubnetInput{
+			VpcId:             aws.String(resources.VPCID),
+			AvailabilityZone:  aws.String(zone),
+			CidrBlock:         aws.String(fmt.Sprintf("10.42.%d.0/20", i*16)),
+			TagSpecifications: tagSpecifications(types.ResourceTypeSubnet, "geoschem-"+zone),
+		})
+		if err != nil {
+			return fmt.Errorf("creating subnet in %s: %w", zone, err)
+		}
+		subnetID := aws.ToString(created.Subnet.SubnetId)
+		resources.Created = append(resources.Created, fmt.Sprintf("subnet %s (%s)", subnetID, zone))
+		resources.SubnetIDs = append(resources.SubnetIDs, subnetID)
+
+		if _, err := b.ec2Client.ModifySubnetAttribute(ctx, &ec2.ModifySubnetAttributeInput{
+			SubnetId:            aws.String(subnetID),
+			MapPublicIpOnLaunch: &types.AttributeBooleanValue{Value: aws.Bool(true)},
+		}); err != nil {
+			return fmt.Errorf("enabling public IPs in %s: %w", subnetID, err)
+		}
+		if _, err := b.ec2Client.AssociateRouteTable(ctx, &ec2.AssociateRouteTableInput{
+			RouteTableId: aws.String(routeTableID),
+			SubnetId:     aws.String(subnetID),
+		}); err != nil {
+			return fmt.Errorf("routing %s: %w", subnetID, err)
+		}
+	}
+	return nil
+}
+
+// ensureSecurityGroup finds the instances' group in the VPC or creates a least-privilege one
+func (b *Bootstrapper) ensureSecurityGroup(ctx context.Context, opts Options, resources *Resources) error {
+	groups, err := b.ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []types.Filter{
+			{Name: aws.String("vpc-id"), Values: []string{resources.VPCID}},
+			{Name: aws.String("group-name"), Values: []string{securityGroupName}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("finding security group: %w", err)
+	}
+	if len(groups.SecurityGroups) > 0 {
+		resources.SecurityGroupID = aws.ToString(groups.SecurityGroups[0].GroupId)
+		return nil
+	}
+
+	spec := network.GroupSpec{Name: securityGroupName, VPCID: resources.VPCID, SSHCIDR: opts.SSHCIDR}
+	if !opts.SSMOnly && spec.SSHCIDR == "" {
+		if spec.SSHCIDR, err = network.CallerCIDR(ctx); err != nil {
+			return fmt.Errorf("%w; pass --ssh-cidr or --ssm-only", err)
+		}
+	}
+	groupID, err := b.network.CreateSecurityGroup(ctx, spec)
+	if groupID != "" {
+		resources.SecurityGroupID = groupID
+		resources.Created = append(resources.Created, "security group "+groupID)
+		if _, tagErr := b.ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{
+			Resources: []string{groupID},
+			Tags:      []types.Tag{{Key: aws.String(ManagedByTag), Value: aws.String(ManagedByValue)}},
+		}); tagErr != nil && err == nil {
+			err = fmt.Errorf("tagging %s: %w", groupID, tagErr)
+		}
+	}
+	return err
+}
+
+// ensureRepository finds or creates the ECR repository, scanning images on push
+func (b *Bootstrapper) ensureRepository(ctx context.Context, name string, resources *Resources) error {
+	described, err := b.ecrClient.DescribeRepositories(ctx, &ecr.DescribeRepositoriesInput{RepositoryNames: []string{name}})
+	var notFound *ecrtypes.RepositoryNotFoundException
+	switch {
+	case err == nil && len(described.Repositories) > 0:
+		resources.RepositoryURI = aws.ToString(described.Repositories[0].RepositoryUri)
+		return nil
+	case err != nil && !errors.As(err, &notFound):
+		return fmt.Errorf("finding ECR repository %s: %w", name, err)
+	}
+
+	created, err := b.ecrClient.CreateRepository(ctx, &ecr.CreateRepositoryInput{
+		RepositoryName:             aws.String(name),
+		ImageScanningConfiguration: &ecrtypes.ImageScanningConfiguration{ScanOnPush: true},
+		Tags: []ecrtypes.Tag{
+			{Key: aws.String("Project"), Value: aws.String("geoschem-aws")},
+			{Key: aws.String(ManagedByTag), Value: aws.String(ManagedByValue)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("creating ECR repository %s: %w", name, err)
+	}
+	resources.RepositoryURI = aws.ToString(created.Repository.RepositoryUri)
+	resources.Created = append(resources.Created, "ECR repository "+name)
+	return nil
+}
+
+// ensureInstanceProfile finds or creates the role and instance profile build instances
+// launch with
+func (b *Bootstrapper) ensureInstanceProfile(ctx context.Context, resources *Resources) error {
+	tags := []string{"--tags", "Key=Project,Value=geoschem-aws", "Key=" + ManagedByTag + ",Value=" + ManagedByValue}
+
+	err := b.iam.Run(ctx, nil, "iam", "get-role", "--role-name", roleName)
+	if err != nil && !isNoSuchEntity(err) {
+		return fmt.Errorf("finding role %s: %w", roleName, err)
+	}
+	if err != nil {
+		if err := b.iam.Run(ctx, nil, append([]string{"iam", "create-role", "--role-name", roleName,
+			"--description", "GeosChem build and run instances",
+			"--assume-role-policy-document", ec2TrustPolicy}, tags...)...); err != nil {
+			return fmt.Errorf("creating role %s: %w", roleName, err)
+		}
+		resources.Created = append(resources.Created, "IAM role "+roleName)
+	}
+	// Attaching is idempotent, so a role from an interrupted run ends up complete
+	for _, policy := range rolePolicies {
+		if err := b.iam.Run(ctx, nil, "iam", "attach-role-policy", "--role-name", roleName, "--policy-arn", policy); err != nil {
+			return fmt.Errorf("attaching %s to %s: %w", policy, roleName, err)
+		}
+	}
+	if err := b.iam.Run(ctx, nil, "iam", "put-role-policy", "--role-name", roleName,
+		"--policy-name", s3PolicyName, "--policy-document", s3Policy); err != nil {
+		return fmt.Errorf("adding S3 access to %s: %w", roleName, err)
+	}
+	if err := b.iam.Run(ctx, nil, "iam", "put-role-policy", "--role-name", roleName,
+		"--policy-name", gitPolicyName, "--policy-document", gitPolicy); err != nil {
+		return fmt.Errorf("adding clone credential access to %s: %w", roleName, err)
+	}
+
+	var profile struct {
+		InstanceProfile struct {
+			Roles []struct {
+				RoleName string `json:"RoleName"`
+			} `json:"Roles"`
+		} `json:"InstanceProfile"`
+	}
+	err = b.iam.Run(ctx, &profile, "iam", "get-instance-profile", "--instance-profile-name", common.BuilderInstanceProfile)
+	if err != nil && !isNoSuchEntity(err) {
+		return fmt.Errorf("finding instance profile %s: %w", common.BuilderInstanceProfile, err)
+	}
+	if err != nil {
+		if err := b.iam.Run(ctx, nil, append([]string{"iam", "create-instance-profile",
+			"--instance-profile-name", common.BuilderInstanceProfile}, tags...)...); err != nil {
+			return fmt.Errorf("creating instance profile %s: %w", common.BuilderInstanceProfile, err)
+		}
+		resources.Created = append(resources.Created, "instance profile "+common.BuilderInstanceProfile)
+	}
+	if len(profile.InstanceProfile.Roles) == 0 {
+		if err := b.iam.Run(ctx, nil, "iam", "add-role-to-instance-profile",
+			"--instance-profile-name", common.BuilderInstanceProfile, "--role-name", roleName); err != nil {
+			return fmt.Errorf("adding %s to %s: %w", roleName, common.BuilderInstanceProfile, err)
+		}
+	}
+	resources.InstanceProfile = common.BuilderInstanceProfile
+	return nil
+}
+
+// managedFilters match bootstrap's resources with the given Name tag
+func managedFilters(name string) []types.Filter {
+	return []types.Filter{
+		{Name: aws.String("tag:" + ManagedByTag), Values: []string{ManagedByValue}},
+		{Name: aws.String("tag:Name"), Values: []string{name}},
+	}
+}
+
+// tagSpecifications name a new resource and mark it as bootstrap's
+func tagSpecifications(resourceType types.ResourceType, name string) []types.TagSpecification {
+	return []types.TagSpecification{{
+		ResourceType: resourceType,
+		Tags: []types.Tag{
+			{Key: aws.String("Name"), Value: aws.String(name)},
+			{Key: aws.String("Project"), Value: aws.String("geoschem-aws")},
+			{Key: aws.String(ManagedByTag), Value: aws.String(ManagedByValue)},
+		},
+	}}
+}
+
+// isNoSuchEntity reports whether an AWS CLI IAM call failed because the entity is missing
+func isNoSuchEntity(err error) bool {
+	return strings.Contains(err.Error(), "NoSuchEntity")
+}
@@ -0,0 +1,200 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// keyLine splits a "key: value  # comment" line into indent, key, value and comment
+var keyLine = regexp.MustCompile(`^(\s*)([A-Za-z_]+):\s*("[^"]*"|'[^']*'|[^#]*?)(\s+#.*)?$`)
+
+// WriteConfig records the resources in the YAML config at path. Only the lines of the
+// settings it sets change, so the file's comments and commented examples survive. A missing
+// file is created with just these settings.
+func WriteConfig(path, profile, region string, resources *Resources) error {
+	config, err := readConfig(path, true)
+	if err != nil {
+		return err
+	}
+	if profile != "" {
+		config.set("aws", "profile", strconv.Quote(profile))
+	}
+	config.set("aws", "region", strconv.Quote(region))
+	if len(resources.SubnetIDs) > 0 {
+		config.set("aws", "subnet_id", strconv.Quote(resources.SubnetIDs[0]))
+		if len(resources.SubnetIDs) > 1 {
+			var quoted []string
+			for _, id := range resources.SubnetIDs[1:] {
+				quoted = append(quoted, strconv.Quote(id))
+			}
+			config.set("aws", "subnet_ids", "["+strings.Join(quoted, ", ")+"]")
+		} else {
+			config.remove("aws", "subnet_ids")
+		}
+	}
+	if resources.SecurityGroupID != "" {
+		config.set("aws", "security_group", strconv.Quote(resources.SecurityGroupID))
+	}
+	if resources.RepositoryURI != "" {
+		config.set("", "ecr_repository", strconv.Quote(resources.RepositoryURI))
+	}
+	return config.write(path)
+}
+
+// ClearConfig empties the settings that name resources teardown removed, leaving settings
+// that point elsewhere alone
+func ClearConfig(path string, removed *Resources) error {
+	config, err := readConfig(path, false)
+	if err != nil {
+		return err
+	}
+	gone := func(value string) bool {
+		for _, id := range append(removed.SubnetIDs, removed.SecurityGroupID, removed.RepositoryURI) {
+			if id != "" && strings.Contains(value, id) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, key := range []string{"subnet_id", "security_group"} {
+		if value, ok := config.get("aws", key); ok && gone(value) {
+			config.set("aws", key, `""`)
+		}
+	}
+	if value, ok := config.get("aws", "subnet_ids"); ok && gone(value) {
+		config.remove("aws", "subnet_ids")
+	}
+	if value, ok := config.get("", "ecr_repository"); ok && gone(value) {
+		config.set("", "ecr_repository", `""`)
+	}
+	return config.write(path)
+}
+
+// configLines is a YAML config edited line by line
+type configLines []string
+
+func readConfig(path string, create bool) (configLines, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && create {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// write saves the config after checking it still parses
+func (c configLines) write(path string) error {
+	data := []byte(strings.Join(c, "\n") + "\n")
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return fmt.Errorf("updated config would not parse: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// span returns the lines holding a section's settings, or -1 when the file has no such
+// section. The empty section is the top level.
+func (c configLines) span(section string) (start, end int) {
+	if section == "" {
+		return 0, len(c)
+	}
+	start = -1
+	for i, line := range c {
+		if start < 0 {
+			if match := keyLine.FindStringSubmatch(line); match != nil && match[1] == "" && match[2] == section {
+				start = i + 1
+			}
+			continue
+		}
+		if line != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "#") {
+			return start, i
+		}
+	}
+	if start < 0 {
+		return -1, -1
+	}
+	return start, len(c)
+}
+
+// find returns the index of a setting's line and its match, or -1
+func (c configLines) find(section, key string) (int, []string) {
+	start, end := c.span(section)
+	for i := max(start, 0); i < end; i++ {
+		match := keyLine.FindStringSubmatch(c[i])
+		if match == nil || match[2] != key {
+			continue
+		}
+		// Top-level keys aren't indented; a section's are
+		if (section == "") == (match[1] == "") {
+			return i, match
+		}
+	}
+	return -1, nil
+}
+
+// get returns a setting's value as written, quotes included
+func (c configLines) get(section, key string) (string, bool) {
+	i, match := c.find(section, key)
+	if i < 0 {
+		return "", false
+	}
+	return match[3], true
+}
+
+// set replaces a setting's value, keeping its comment, or adds the setting at the end of
+// its section
+func (c *configLines) set(section, key, value string) {
+	if i, match := c.find(section, key); i >= 0 {
+		(*c)[i] = match[1] + key + ": " + value + match[4]
+		return
+	}
+
+	start, end := c.span(section)
+	if start < 0 {
+		*c = append(*c, section+":")
+		start, end = len(*c), len(*c)
+	}
+	indent := ""
+	if section != "" {
+		indent = "  "
+		for i := start; i < end; i++ {
+			if match := keyLine.FindStringSubmatch((*c)[i]); match != nil && match[1] != "" {
+				indent = match[1]
+				break
+			}
+		}
+	}
+	// Insert after the section's last setting, before blank lines and comments that follow
+	at := end
+	for at > start && (strings.TrimSpace((*c)[at-1]) == "" || strings.HasPrefix(strings.TrimSpace((*c)[at-1]), "#")) {
+		at--
+	}
+	*c = append((*c)[:at], append([]string{indent + key + ": " + value}, (*c)[at:]...)...)
+}
+
+// remove deletes a setting along with the indented lines of a block value
+func (c *configLines) remove(section, key string) {
+	i, match := c.find(section, key)
+	if i < 0 {
+		return
+	}
+	end := i + 1
+	for end < len(*c) {
+		line := (*c)[end]
+		if strings.TrimSpace(line) == "" || len(line)-len(strings.TrimLeft(line, " ")) <= len(match[1]) {
+			break
+		}
+		end++
+	}
+	*c = append((*c)[:i], (*c)[end:]...)
+}
@@ -0,0 +1,260 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// TeardownOptions control what teardown deletes
+type TeardownOptions struct {
+	Repository       string // ECR repository name to consider
+	DeleteRepository bool   // Delete the repository and every image in it
+}
+
+// Step is one deletion in a teardown plan
+type Step struct {
+	Description string
+	Run         func(ctx context.Context) error
+}
+
+// Plan lists the deletions that remove what bootstrap created, in dependency order, and
+// the resources they remove. Only resources tagged by bootstrap are included, so a VPC or
+// repository that already existed is never touched.
+func (b *Bootstrapper) Plan(ctx context.Context, opts TeardownOptions) ([]Step, *Resources, error) {
+	var steps []Step
+	removed := &Resources{}
+	managed := []types.Filter{{Name: aws.String("tag:" + ManagedByTag), Values: []string{ManagedByValue}}}
+
+	groups, err := b.ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{Filters: managed})
+	if err != nil {
+		return nil, nil, fmt.Errorf("finding security groups: %w", err)
+	}
+	for _, group := range groups.SecurityGroups {
+		groupID := aws.ToString(group.GroupId)
+		removed.SecurityGroupID = groupID
+		steps = append(steps, Step{"Delete security group " + groupID, func(ctx context.Context) error {
+			_, err := b.ec2Client.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{GroupId: aws.String(groupID)})
+			return err
+		}})
+	}
+
+	vpcs, err := b.ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{Filters: managed})
+	if err != nil {
+		return nil, nil, fmt.Errorf("finding VPCs: %w", err)
+	}
+	for _, vpc := range vpcs.Vpcs {
+		vpcSteps, err := b.planVPC(ctx, aws.ToString(vpc.VpcId), removed)
+		if err != nil {
+			return nil, nil, err
+		}
+		steps = append(steps, vpcSteps...)
+	}
+
+	if opts.DeleteRepository && opts.Repository != "" {
+		step, uri, err := b.planRepository(ctx, opts.Repository)
+		if err != nil {
+			return nil, nil, err
+		}
+		if step != nil {
+			removed.RepositoryURI = uri
+			steps = append(steps, *step)
+		}
+	}
+
+	iamSteps, err := b.planInstanceProfile(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	steps = append(steps, iamSteps...)
+	return steps, removed, nil
+}
+
+// planVPC deletes a bootstrap VPC and everything in it, refusing while instances remain
+func (b *Bootstrapper) planVPC(ctx context.Context, vpcID string, removed *Resources) ([]Step, error) {
+	inVPC := []types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}}
+
+	instances, err := b.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: append(inVPC, types.Filter{Name: aws.String("instance-state-name"),
+			Values: []string{"pending", "running", "stopping", "stopped", "shutting-down"}}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing instances in %s: %w", vpcID, err)
+	}
+	var running []string
+	for _, reservation := range instances.Reservations {
+		for _, instance := range reservation.Instances {
+			running = append(running, aws.ToString(instance.InstanceId))
+		}
+	}
+	if len(running) > 0 {
+		return nil, fmt.Errorf("VPC %s still has instances (%s); terminate them first", vpcID, strings.Join(running, ", "))
+	}
+
+	var steps []Step
+	endpoints, err := b.ec2Client.DescribeVpcEndpoints(ctx, &ec2.DescribeVpcEndpointsInput{Filters: inVPC})
+	if err != nil {
+		return nil, fmt.Errorf("listing endpoints in %s: %w", vpcID, err)
+	}
+	for _, endpoint := range endpoints.VpcEndpoints {
+		endpointID := aws.ToString(endpoint.VpcEndpointId)
+		steps = append(steps, Step{"Delete VPC endpoint " + endpointID, func(ctx context.Context) error {
+			_, err := b.ec2Client.DeleteVpcEndpoints(ctx, &ec2.DeleteVpcEndpointsInput{VpcEndpointIds: []string{endpointID}})
+			return err
+		}})
+	}
+
+	subnets, err := b.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{Filters: inVPC})
+	if err != nil {
+		return nil, fmt.Errorf("listing subnets in %s: %w", vpcID, err)
+	}
+	for _, subnet := range subnets.Subnets {
+		subnetID := aws.ToString(subnet.SubnetId)
+		removed.SubnetIDs = append(removed.SubnetIDs, subnetID)
+		steps = append(steps, Step{"Delete subnet " + subnetID, func(ctx context.Context) error {
+			_, err := b.ec2Client.DeleteSubnet(ctx, &ec2.DeleteSubnetInput{SubnetId: aws.String(subnetID)})
+			return err
+		}})
+	}
+
+	// The main route table goes with the VPC; bootstrap's own table is tagged
+	tables, err := b.ec2Client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{
+		Filters: append(inVPC, types.Filter{Name: aws.String("tag:" + ManagedByTag), Values: []string{ManagedByValue}}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing route tables in %s: %w", vpcID, err)
+	}
+	for _, table := range tables.RouteTables {
+		tableID := aws.ToString(table.RouteTableId)
+		steps = append(steps, Step{"Delete route table " + tableID, func(ctx context.Context) error {
+			_, err := b.ec2Client.DeleteRouteTable(ctx, &ec2.DeleteRouteTableInput{RouteTableId: aws.String(tableID)})
+			return err
+		}})
+	}
+
+	gateways, err := b.ec2Client.DescribeInternetGateways(ctx, &ec2.DescribeInternetGatewaysInput{
+		Filters: []types.Filter{{Name: aws.String("attachment.vpc-id"), Values: []string{vpcID}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing internet gateways of %s: %w", vpcID, err)
+	}
+	for _, gateway := range gateways.InternetGateways {
+		gatewayID := aws.ToString(gateway.InternetGatewayId)
+		steps = append(steps, Step{"Detach and delete internet gateway " + gatewayID, func(ctx context.Context) error {
+			if _, err := b.ec2Client.DetachInternetGateway(ctx, &ec2.DetachInternetGatewayInput{
+				InternetGatewayId: aws.String(gatewayID),
+				VpcId:             aws.String(vpcID),
+			}); err != nil {
+				return err
+			}
+			_, err := b.ec2Client.DeleteInternetGateway(ctx, &ec2.DeleteInternetGatewayInput{InternetGatewayId: aws.String(gatewayID)})
+			return err
+		}})
+	}
+
+	steps = append(steps, Step{"Delete VPC " + vpcID, func(ctx context.Context) error {
+		_, err := b.ec2Client.DeleteVpc(ctx, &ec2.DeleteVpcInput{VpcId: aws.String(vpcID)})
+		return err
+	}})
+	return steps, nil
+}
+
+// planRepository deletes the repository with its images when bootstrap created it
+func (b *Bootstrapper) planRepository(ctx context.Context, name string) (*Step, string, error) {
+	described, err := b.ecrClient.DescribeRepositories(ctx, &ecr.DescribeRepositoriesInput{RepositoryNames: []string{name}})
+	var notFound *ecrtypes.RepositoryNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("finding ECR repository %s: %w", name, err)
+	}
+	repository := described.Repositories[0]
+
+	tags, err := b.ecrClient.ListTagsForResource(ctx, &ecr.ListTagsForResourceInput{ResourceArn: repository.RepositoryArn})
+	if err != nil {
+		return nil, "", fmt.Errorf("reading tags of %s: %w", name, err)
+	}
+	for _, tag := range tags.Tags {
+		if aws.ToString(tag.Key) == ManagedByTag && aws.ToString(tag.Value) == ManagedByValue {
+			return &Step{"Delete ECR repository " + name + " and its images", func(ctx context.Context) error {
+				_, err := b.ecrClient.DeleteRepository(ctx, &ecr.DeleteRepositoryInput{RepositoryName: aws.String(name), Force: true})
+				return err
+			}}, aws.ToString(repository.RepositoryUri), nil
+		}
+	}
+	return nil, "", nil
+}
+
+// planInstanceProfile deletes the role and instance profile when bootstrap created them
+func (b *Bootstrapper) planInstanceProfile(ctx context.Context) ([]Step, error) {
+	type tagged struct {
+		Tags []struct {
+			Key   string `json:"Key"`
+			Value string `json:"Value"`
+		} `json:"Tags"`
+	}
+	isManaged := func(entity tagged) bool {
+		for _, tag := range entity.Tags {
+			if tag.Key == ManagedByTag && tag.Value == ManagedByValue {
+				return true
+			}
+		}
+		return false
+	}
+
+	var steps []Step
+	var profile struct {
+		InstanceProfile tagged `json:"InstanceProfile"`
+	}
+	err := b.iam.Run(ctx, &profile, "iam", "get-instance-profile", "--instance-profile-name", common.BuilderInstanceProfile)
+	if err != nil && !isNoSuchEntity(err) {
+		return nil, fmt.Errorf("finding instance profile: %w", err)
+	}
+	if err == nil && isManaged(profile.InstanceProfile) {
+		steps = append(steps, Step{"Delete instance profile " + common.BuilderInstanceProfile, func(ctx context.Context) error {
+			// The profile can't be deleted while it holds the role; it may not if creation was interrupted
+			err := b.iam.Run(ctx, nil, "iam", "remove-role-from-instance-profile",
+				"--instance-profile-name", common.BuilderInstanceProfile, "--role-name", roleName)
+			if err != nil && !isNoSuchEntity(err) {
+				return err
+			}
+			return b.iam.Run(ctx, nil, "iam", "delete-instance-profile", "--instance-profile-name", common.BuilderInstanceProfile)
+		}})
+	}
+
+	var role struct {
+		Role tagged `json:"Role"`
+	}
+	err = b.iam.Run(ctx, &role, "iam", "get-role", "--role-name", roleName)
+	if err != nil && !isNoSuchEntity(err) {
+		return nil, fmt.Errorf("finding role %s: %w", roleName, err)
+	}
+	if err == nil && isManaged(role.Role) {
+		steps = append(steps, Step{"Delete IAM role " + roleName, func(ctx context.Context) error {
+			for _, policy := range rolePolicies {
+				err := b.iam.Run(ctx, nil, "iam", "detach-role-policy", "--role-name", roleName, "--policy-arn", policy)
+				if err != nil && !isNoSuchEntity(err) {
+					return err
+				}
+			}
+			for _, policy := range []string{s3PolicyName, gitPolicyName} {
+				err := b.iam.Run(ctx, nil, "iam", "delete-role-policy", "--role-name", roleName, "--policy-name", policy)
+				if err != nil && !isNoSuchEntity(err) {
+					return err
+				}
+			}
+			return b.iam.Run(ctx, nil, "iam", "delete-role", "--role-name", roleName)
+		}})
+	}
+	return steps, nil
+}
@@ -0,0 +1,104 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// amiDeprecationNotice is how far ahead a scheduled AMI deprecation is announced
+const amiDeprecationNotice = 30 * 24 * time.Hour
+
+// describeAMIs returns the available EBS-backed HVM AMIs with ENA matching a name pattern,
+// newest first
+func (b *Builder) describeAMIs(ctx context.Context, owners []string, namePattern, arch string) ([]types.Image, error) {
+	result, err := b.ec2Client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+		Owners: owners,
+		Filters: []types.Filter{
+			{Name: aws.String("name"), Values: []string{namePattern}},
+			{Name: aws.String("architecture"), Values: []string{arch}},
+			{Name: aws.String("root-device-type"), Values: []string{"ebs"}},
+			{Name: aws.String("virtualization-type"), Values: []string{"hvm"}},
+			// Nitro instances (c5 onward, including Graviton4) only boot AMIs with ENA
+			{Name: aws.String("ena-support"), Values: []string{"true"}},
+			{Name: aws.String("state"), Values: []string{"available"}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	images := result.Images
+	sort.Slice(images, func(i, j int) bool {
+		return aws.ToString(images[i].CreationDate) > aws.ToString(images[j].CreationDate)
+	})
+	return images, nil
+}
+
+// warnStaleAMI prints warnings when the selected AMI is old, deprecated, or replaced by a
+// newer image the host OS's built-in lookup finds. The build goes ahead either way.
+func (b *Builder) warnStaleAMI(ctx context.Context, hostOS *common.HostOS, arch string, image types.Image) {
+	warnings := amiFreshnessWarnings(image, hostOS.MaxAMIAgeMonths, time.Now())
+	if newer, ok := b.replacementAMI(ctx, hostOS, arch, image); ok {
+		warnings = append(warnings, fmt.Sprintf("the publisher has a newer %s AMI, %s (%s), than host_os.ami_name_pattern/ami_owner select; update or remove the override",
+			hostOS.DisplayName, aws.ToString(newer.ImageId), aws.ToString(newer.Name)))
+	}
+	for _, warning := range warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+}
+
+// amiFreshnessWarnings checks an AMI's age against maxAgeMonths and its deprecation date
+func amiFreshnessWarnings(image types.Image, maxAgeMonths int, now time.Time) []string {
+	var warnings []string
+	name := aws.ToString(image.ImageId)
+
+	created, err := time.Parse(time.RFC3339, aws.ToString(image.CreationDate))
+	if err == nil && maxAgeMonths > 0 && created.AddDate(0, maxAgeMonths, 0).Before(now) {
+		warnings = append(warnings, fmt.Sprintf("AMI %s was published %s, more than %d months ago; if the publisher renamed its images, set host_os.ami_name_pattern to find the current ones",
+			name, created.Format("2006-01-02"), maxAgeMonths))
+	}
+
+	deprecated, err := time.Parse(time.RFC3339, aws.ToString(image.DeprecationTime))
+	switch {
+	case err != nil:
+	case !deprecated.After(now):
+		warnings = append(warnings, fmt.Sprintf("AMI %s was deprecated by its publisher on %s", name, deprecated.Format("2006-01-02")))
+	case deprecated.Sub(now) < amiDeprecationNotice:
+		warnings = append(warnings, fmt.Sprintf("AMI %s will be deprecated by its publisher on %s", name, deprecated.Format("2006-01-02")))
+	}
+	return warnings
+}
+
+// replacementAMI returns the newest AMI the host OS's built-in owner and name pattern find
+// when the configuration overrides them and that image is newer than the one selected
+func (b *Builder) replacementAMI(ctx context.Context, hostOS *common.HostOS, arch string, selected types.Image) (types.Image, bool) {
+	builtin, err := common.GetHostOS(hostOS.Name)
+	if err != nil {
+		return types.Image{}, false
+	}
+	pattern, err := builtin.AMINamePattern(arch)
+	if err != nil {
+		return types.Image{}, false
+	}
+	configured, _ := hostOS.AMINamePattern(arch)
+	if pattern == configured && fmt.Sprint(builtin.AMIOwners) == fmt.Sprint(hostOS.AMIOwners) {
+		return types.Image{}, false
+	}
+
+	images, err := b.describeAMIs(ctx, builtin.AMIOwners, pattern, arch)
+	if err != nil || len(images) == 0 {
+		return types.Image{}, false
+	}
+	if aws.ToString(images[0].CreationDate) <= aws.ToString(selected.CreationDate) {
+		return types.Image{}, false
+	}
+	return images[0], true
+}
@@ -0,0 +1,98 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+	"github.com/scttfrdmn/geoschem-aws/internal/shutdown"
+)
+
+// BuildJob is one cell of the build matrix, ready to hand to a backend
+type BuildJob struct {
+	Name            string
+	Architecture    string
+	Docker          *docker.BuildConfig
+	Dependencies    *docker.BuildConfig // Dependencies image to build before the model; nil when reused or not split
+	ECRRepository   string              // Push target; empty builds without pushing
+	GeosChemVersion string              // Checked against the labels of a published dependencies image
+}
+
+// Backend executes container builds
+type Backend interface {
+	Name() string
+	Run(ctx context.Context, config *common.BuildConfig, job BuildJob) error
+}
+
+// BuildHost is an instance that a host backend launches, prepares, and builds on
+type BuildHost interface {
+	Launch(ctx context.Context, config *common.BuildConfig, arch string) (string, error)
+	Prepare(ctx context.Context, skipUpdate bool) error
+	Runner() docker.CommandRunner
+	Cleanup(ctx context.Context) error
+}
+
+// NewBackend returns the backend selected by the build config
+func NewBackend(cfg aws.Config, config *common.BuildConfig) (Backend, error) {
+	switch config.Execution.BackendName() {
+	case common.BackendSSH:
+		return &hostBackend{name: common.BackendSSH, newHost: func() BuildHost { return NewSSHBuilder(cfg) }}, nil
+	case common.BackendSSM:
+		return &hostBackend{name: common.BackendSSM, newHost: func() BuildHost { return NewSSMHost(cfg) }}, nil
+	case common.BackendBatch:
+		return NewBatchBackend(config)
+	default:
+		return nil, fmt.Errorf("unknown execution backend '%s'", config.Execution.Backend)
+	}
+}
+
+// hostBackend runs each build on a fresh EC2 instance
+type hostBackend struct {
+	name    string
+	newHost func() BuildHost
+}
+
+func (hb *hostBackend) Name() string {
+	return hb.name
+}
+
+func (hb *hostBackend) Run(ctx context.Context, config *common.BuildConfig, job BuildJob) error {
+	host := hb.newHost()
+
+	// Terminate even when the build context was cancelled, or the command interrupted
+	cleanup := shutdown.Register(ctx, "build instance for "+job.Name, host.Cleanup)
+	defer func() {
+		if err := cleanup(); err != nil {
+			fmt.Printf("Warning: failed to clean up build instance: %v\n", err)
+		}
+	}()
+
+	launchConfig := *config
+	launchConfig.Tagging.BuildTag = job.Name
+	if _, err := host.Launch(ctx, &launchConfig, job.Architecture); err != nil {
+		return err
+	}
+
+	if err := host.Prepare(ctx, false); err != nil {
+		return fmt.Errorf("preparing instance: %w", err)
+	}
+
+	dockerBuilder := docker.NewDockerBuilder(host.Runner())
+	if err := BuildDependencies(ctx, dockerBuilder, job); err != nil {
+		return err
+	}
+	if err := dockerBuilder.BuildContainer(ctx, job.Docker); err != nil {
+		return fmt.Errorf("building container: %w", err)
+	}
+
+	if job.ECRRepository != "" {
+		if err := dockerBuilder.PushToECR(ctx, job.Docker, job.ECRRepository); err != nil {
+			return fmt.Errorf("pushing to ECR: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+)
+
+// BatchBackend submits each build as an AWS Batch job. The job definition must run a
+// privileged container with podman, git, and the AWS CLI, and a job role that can push to ECR.
+type BatchBackend struct {
+	cli           *awscli.Client
+	jobQueue      string
+	jobDefinition string
+}
+
+// NewBatchBackend creates a Batch backend from the build config's batch section
+func NewBatchBackend(config *common.BuildConfig) (*BatchBackend, error) {
+	if config.Batch.JobQueue == "" || config.Batch.JobDefinition == "" {
+		return nil, fmt.Errorf("batch backend requires batch.job_queue and batch.job_definition")
+	}
+	return &BatchBackend{
+		cli:           awscli.New(config.AWS.Profile, config.AWS.Region),
+		jobQueue:      config.Batch.JobQueue,
+		jobDefinition: config.Batch.JobDefinition,
+	}, nil
+}
+
+func (bb *BatchBackend) Name() string {
+	return common.BackendBatch
+}
+
+// Run submits the build and waits for the job to finish
+func (bb *BatchBackend) Run(ctx context.Context, config *common.BuildConfig, job BuildJob) error {
+	script, err := docker.BuildScript(job.Docker, job.ECRRepository)
+	if err != nil {
+		return err
+	}
+	if job.Dependencies != nil {
+		depsScript, err := docker.BuildScript(job.Dependencies, job.ECRRepository)
+		if err != nil {
+			return err
+		}
+		script = depsScript + script
+	}
+
+	overrides, err := json.Marshal(map[string]interface{}{
+		"command": []string{"bash", "-c", script},
+	})
+	if err != nil {
+		return fmt.Errorf("encoding container overrides: %w", err)
+	}
+
+	var submitted struct {
+		JobID string `json:"jobId"`
+	}
+	if err := bb.cli.Run(ctx, &submitted, "batch", "submit-job",
+		"--job-name", job.Name,
+		"--job-queue", bb.jobQueue,
+		"--job-definition", bb.jobDefinition,
+		"--container-overrides", string(overrides)); err != nil {
+		return fmt.Errorf("submitting batch job: %w", err)
+	}
+	fmt.Printf("Submitted Batch job %s for %s\n", submitted.JobID, job.Name)
+
+	return bb.waitForJob(ctx, submitted.JobID)
+}
+
+// waitForJob polls the job until it succeeds or fails
+func (bb *BatchBackend) waitForJob(ctx context.Context, jobID string) error {
+	lastStatus := ""
+	for {
+		var out struct {
+			Jobs []struct {
+				Status       string `json:"status"`
+				StatusReason string `json:"statusReason"`
+			} `json:"jobs"`
+		}
+		if err := bb.cli.Run(ctx, &out, "batch", "describe-jobs", "--jobs", jobID); err != nil {
+			return fmt.Errorf("describing batch job: %w", err)
+		}
+		if len(out.Jobs) == 0 {
+			return fmt.Errorf("batch job %s not found", jobID)
+		}
+
+		status := out.Jobs[0].Status
+		if status != lastStatus {
+			fmt.Printf("Batch job %s: %s\n", jobID, status)
+			lastStatus = status
+		}
+
+		switch status {
+		case "SUCCEEDED":
+			return nil
+		case "FAILED":
+			return fmt.Errorf("batch job %s failed: %s", jobID, out.Jobs[0].StatusReason)
+		}
+
+		select {
+		case <-ctx.Done():
+			// Don't leave the build running unattended
+			if err := bb.cli.Run(context.Background(), nil, "batch", "terminate-job",
+				"--job-id", jobID, "--reason", "cancelled by submitter"); err != nil {
+				fmt.Printf("Warning: could not terminate batch job %s: %v\n", jobID, err)
+			}
+			return ctx.Err()
+		case <-time.After(30 * time.Second):
+		}
+	}
+}
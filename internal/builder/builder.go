@@ -3,122 +3,164 @@ package builder
 import (
     "context"
     "fmt"
-    "strings"
-    "time"
+    "sort"
 
     "github.com/aws/aws-sdk-go-v2/aws"
-    "github.com/aws/aws-sdk-go-v2/config"
     "github.com/aws/aws-sdk-go-v2/service/ec2"
     "github.com/aws/aws-sdk-go-v2/service/ecr"
     
     "github.com/scttfrdmn/geoschem-aws/internal/common"
+    "github.com/scttfrdmn/geoschem-aws/internal/docker"
+    "github.com/scttfrdmn/geoschem-aws/internal/geoschem"
 )
 
 type Builder struct {
+    cfg           aws.Config
     ec2Client     *ec2.Client
     ecrClient     *ecr.Client
     quotaChecker  *common.QuotaChecker
     profile       string
     region        string
-}
-
-type BuildRequest struct {
-    Architecture string
-    Compiler     string
-    MPI          string
-    Tag          string
+    waits         common.WaitsConfig // Set for the instance type on launch
+    sources       *SourceChecker     // Checks build inputs before launching, once per matrix
 }
 
 func New(ctx context.Context, profile string, region string) (*Builder, error) {
-    cfg, err := config.LoadDefaultConfig(ctx, 
-        config.WithSharedConfigProfile(profile),
-        config.WithRegion(region),
-    )
+    cfg, err := common.LoadSDKConfig(ctx, profile, region)
     if err != nil {
         return nil, fmt.Errorf("loading AWS config with profile %s and region %s: %w", profile, region, err)
     }
 
-    return NewFromConfig(cfg, region), nil
+    b := NewFromConfig(cfg, region)
+    b.profile = profile
+    return b, nil
 }
 
 // NewFromConfig creates a Builder from an existing AWS config
 func NewFromConfig(cfg aws.Config, region string) *Builder {
     return &Builder{
+        cfg:          cfg,
         ec2Client:    ec2.NewFromConfig(cfg),
         ecrClient:    ecr.NewFromConfig(cfg),
         quotaChecker: common.NewQuotaChecker(cfg, region),
         profile:      "", // Not available from config
         region:       region,
+        waits:        common.WaitsConfig{}.For(""),
+        sources:      NewSourceChecker(),
     }
 }
 
+// BuildMatrix builds every combination for every architecture and prints a result table
 func (b *Builder) BuildMatrix(ctx context.Context, config *common.BuildConfig) error {
     fmt.Printf("Building complete matrix in region %s...\n", b.region)
     
+    arches := make([]string, 0, len(config.Architectures))
     for arch := range config.Architectures {
-        fmt.Printf("Processing architecture: %s\n", arch)
-        if err := b.BuildAllForArch(ctx, config, arch); err != nil {
-            return fmt.Errorf("building arch %s: %w", arch, err)
-        }
+        arches = append(arches, arch)
     }
+    sort.Strings(arches)
     
-    return nil
+    report, err := b.buildCombinations(ctx, config, arches)
+    if err != nil {
+        return err
+    }
+    fmt.Print(report.Table())
+    return report.Err()
 }
 
+// BuildAllForArch builds every combination for one architecture and prints a result table
 func (b *Builder) BuildAllForArch(ctx context.Context, config *common.BuildConfig, arch string) error {
-    archConfig, exists := config.Architectures[arch]
-    if !exists {
+    if _, exists := config.Architectures[arch]; !exists {
         return fmt.Errorf("unknown architecture: %s", arch)
     }
 
     fmt.Printf("Building all combinations for %s in region %s...\n", arch, b.region)
     
-    for compiler, compilerConfig := range archConfig.Compilers {
-        for _, mpi := range compilerConfig.MPIOptions {
-            fmt.Printf("Building: %s-%s-%s\n", arch, compiler, mpi)
-            if err := b.BuildSingle(ctx, config, arch, compiler, mpi); err != nil {
-                return fmt.Errorf("building %s-%s-%s: %w", arch, compiler, mpi, err)
-            }
-        }
+    report, err := b.buildCombinations(ctx, config, []string{arch})
+    if err != nil {
+        return err
     }
-    
-    return nil
+    fmt.Print(report.Table())
+    return report.Err()
 }
 
+// BuildSingle builds one matrix cell on the configured execution backend and pushes it to ECR
 func (b *Builder) BuildSingle(ctx context.Context, config *common.BuildConfig, arch, compiler, mpi string) error {
+    if err := geoschem.ValidateCompilerMPI(compiler, mpi); err != nil {
+        return fmt.Errorf("invalid build combination: %w", err)
+    }
+
+    if _, exists := config.Architectures[arch]; !exists {
+        return fmt.Errorf("unknown architecture: %s", arch)
+    }
+
     tag := fmt.Sprintf("%s-%s", compiler, mpi)
     if arch == "arm64" {
         tag += "-arm64"
     }
     
-    fmt.Printf("Building: %s (using Rocky Linux 9 in %s)\n", tag, b.region)
+    hostOS, err := config.HostOS.Resolve()
+    if err != nil {
+        return err
+    }
     
-    buildReq := BuildRequest{
-        Architecture: arch,
-        Compiler:     compiler,
-        MPI:          mpi,
-        Tag:          tag,
+    buildConfig, err := resolveBuildConfig(config, arch, compiler, mpi)
+    if err != nil {
+        return err
+    }
+    if err := docker.ValidateRepositoryStrategy(config.ECRStrategy); err != nil {
+        return err
     }
     
-    // Launch EC2 instance
-    instanceID, err := b.launchBuildInstance(ctx, config, arch)
+    backend, err := NewBackend(b.cfg, config)
     if err != nil {
-        return fmt.Errorf("launching instance: %w", err)
+        return err
     }
     
-    defer func() {
-        if err := b.terminateInstance(ctx, instanceID); err != nil {
-            fmt.Printf("Warning: failed to terminate instance %s: %v\n", instanceID, err)
-        }
-    }()
+    fmt.Printf("Building: %s (using %s in %s via %s)\n", tag, hostOS.DisplayName, b.region, backend.Name())
     
-    // Wait for instance to be ready
-    if err := b.waitForInstance(ctx, instanceID); err != nil {
-        return fmt.Errorf("waiting for instance: %w", err)
+    removeSource, err := PackLocalSource(ctx, config)
+    if err != nil {
+        return err
+    }
+    defer removeSource()
+    if err := ReadPatches(config); err != nil {
+        return err
     }
+    if err := ReadDockerfiles(config); err != nil {
+        return err
+    }
+    dockerfile := config.DockerfileFor(arch, compiler)
+    if err := CheckDockerfile(config.Source, dockerfile, buildConfig); err != nil {
+        return err
+    }
+    if err := b.sources.Check(ctx, config.Source, dockerfile, buildConfig); err != nil {
+        return err
+    }
+    source := config.Source.WithDefaults()
+    job := BuildJob{
+        Name:            "geoschem-" + tag,
+        Architecture:    arch,
+        Docker:          buildConfig.ToDockerBuildConfig(source.Repo, source.Branch, source.ImageTag),
+        ECRRepository:   config.ECRRepository,
+        GeosChemVersion: buildConfig.GeosChemVersion(),
+    }
+    job.Docker.SourceSubmodules = source.Submodules
+    job.Docker.SourceTokenSecret = source.TokenSecret
+    job.Docker.SourceDeployKeySecret = source.DeployKeySecret
+    UsePatches(source, job.Docker)
+    UseLocalDockerfile(dockerfile, job.Docker)
+    job.Docker.CcacheURI = config.Cache.CcacheS3
+    job.Docker.PullThrough = config.Cache.PullThrough
+    job.Docker.Prepull = config.Cache.Prepull
+    job.Docker.RepositoryStrategy = config.ECRStrategy
+    job.Docker.Push = docker.PushOptions(config.Push)
+    if err := PlanDependencies(ctx, b.cfg, config.Dependencies, buildConfig, &job); err != nil {
+        return err
+    }
+    UseLocalSource(source, job.Docker, job.Dependencies)
     
-    // Execute build
-    if err := b.executeBuild(ctx, instanceID, buildReq, config); err != nil {
+    if err := backend.Run(ctx, config, job); err != nil {
         return fmt.Errorf("executing build: %w", err)
     }
     
@@ -126,21 +168,22 @@ func (b *Builder) BuildSingle(ctx context.Context, config *common.BuildConfig, a
     return nil
 }
 
-func (b *Builder) executeBuild(ctx context.Context, instanceID string, buildReq BuildRequest, config *common.BuildConfig) error {
-    // This is a placeholder for the actual build execution
-    // In a real implementation, you would:
-    // 1. Wait for the user data script to complete
-    // 2. SSH into the instance or use SSM
-    // 3. Build the Docker container
-    // 4. Push to ECR
-    
-    fmt.Printf("Executing build on instance %s for %s...\n", instanceID, buildReq.Tag)
-    
-    // Simulate build time
-    time.Sleep(30 * time.Second)
-    
-    fmt.Printf("Build execution completed for %s\n", buildReq.Tag)
-    return nil
+// resolveBuildConfig returns the validated build configuration of one matrix cell
+func resolveBuildConfig(config *common.BuildConfig, arch, compiler, mpi string) (*geoschem.BuildConfiguration, error) {
+    buildConfig, err := geoschem.FindBuildConfig(arch, compiler)
+    if err != nil {
+        return nil, err
+    }
+    buildConfig.MPI = mpi
+    if optimization := config.Architectures[arch].Optimization; optimization != "" {
+        buildConfig.Optimization = optimization
+    }
+    buildConfig.DependencyStack = config.Dependencies.Stack
+    UseDockerfile(config.DockerfileFor(arch, compiler), buildConfig)
+    if err := buildConfig.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid build configuration: %w", err)
+    }
+    return buildConfig, nil
 }
 
 // CheckQuotas checks AWS service quotas relevant to the platform
@@ -153,15 +196,45 @@ func (b *Builder) CheckQuotas(ctx context.Context) error {
     report.PrintReport()
 
     // Check if any critical quotas need attention
+    supportAPI := -1 // Checked once, only when a non-adjustable quota needs raising
     for _, quota := range report.Quotas {
         if quota.Status == "CRITICAL" {
             fmt.Printf("\n🚨 CRITICAL: %s quota is at %.1f%% usage\n", quota.QuotaName, quota.Usage)
             if quota.CanIncrease {
-                fmt.Printf("💡 Consider requesting a quota increase for %s\n", quota.ServiceName)
-                fmt.Printf("   Use: aws support create-case --service-code=\"%s\" ...\n", strings.ToLower(quota.ServiceName))
+                fmt.Printf("💡 Request an increase through Service Quotas:\n")
+                fmt.Printf("   geoschem-aws quota -request-increase -service %s -quota-code %s -desired-value %.0f\n", quota.ServiceCode, quota.QuotaCode, quota.Limit*2)
+                continue
+            }
+            if supportAPI < 0 {
+                supportAPI = 0
+                if available, err := b.quotaChecker.SupportAPIAvailable(ctx); err == nil && available {
+                    supportAPI = 1
+                }
+            }
+            if supportAPI == 1 {
+                fmt.Printf("💡 %s is not adjustable through Service Quotas; this opens a support case:\n", quota.QuotaName)
+                fmt.Printf("   geoschem-aws quota -request-increase -service %s -quota-code %s -desired-value %.0f\n", quota.ServiceCode, quota.QuotaCode, quota.Limit*2)
+            } else {
+                fmt.Printf("💡 %s is not adjustable through Service Quotas, and this account's support plan can't open cases through the API.\n", quota.QuotaName)
+                fmt.Printf("   Open a service limit increase case instead: %s\n", common.SupportCaseURL)
             }
         }
     }
 
+    return nil
+}
+
+// RequestQuotaIncrease asks AWS to raise a quota, through Service Quotas or a support case
+func (b *Builder) RequestQuotaIncrease(ctx context.Context, serviceCode, quotaCode string, desired float64) error {
+    justification := "Running GEOS-Chem atmospheric chemistry builds and simulations needs more concurrent capacity than the current limit allows."
+    request, err := b.quotaChecker.RequestIncrease(ctx, serviceCode, quotaCode, desired, justification)
+    if err != nil {
+        return err
+    }
+
+    fmt.Printf("✅ Quota increase requested via %s: %s (%s)\n", request.Via, request.ID, request.Status)
+    if request.CaseID != "" && request.CaseID != request.ID {
+        fmt.Printf("   Support case: %s\n", request.CaseID)
+    }
     return nil
 }
\ No newline at end of file
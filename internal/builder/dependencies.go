@@ -0,0 +1,119 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+	"github.com/scttfrdmn/geoschem-aws/internal/geoschem"
+)
+
+// PlanDependencies points the job's model build at a dependencies image. A pinned image is
+// used as is; otherwise the image matching the configuration is reused from ECR when it is
+// already there, and built before the model when it isn't.
+func PlanDependencies(ctx context.Context, cfg aws.Config, deps common.DependenciesImageConfig, config *geoschem.BuildConfiguration, job *BuildJob) error {
+	if !deps.IsSet() {
+		return nil
+	}
+
+	if deps.Image != "" {
+		job.Docker.BuildArgs[docker.DependenciesImageArg] = deps.Image
+		fmt.Printf("📦 Building FROM dependencies image %s\n", deps.Image)
+		return nil
+	}
+
+	depsConfig := config.ToDependenciesBuildConfig(job.Docker.SourceRepo, job.Docker.SourceBranch)
+	depsConfig.SourceSubmodules = job.Docker.SourceSubmodules
+	depsConfig.SourceTokenSecret = job.Docker.SourceTokenSecret
+	depsConfig.SourceDeployKeySecret = job.Docker.SourceDeployKeySecret
+	depsConfig.SourcePatches = job.Docker.SourcePatches
+	depsConfig.CcacheURI = job.Docker.CcacheURI
+	depsConfig.PullThrough = job.Docker.PullThrough
+	depsConfig.Prepull = job.Docker.Prepull
+	depsConfig.RepositoryStrategy = job.Docker.RepositoryStrategy
+	depsConfig.Push = job.Docker.Push
+
+	// Without a registry the image only lives on the build host, so every build makes it
+	if job.ECRRepository == "" {
+		job.Dependencies = depsConfig
+		job.Docker.BuildArgs[docker.DependenciesImageArg] = depsConfig.LocalImage()
+		fmt.Printf("📦 Building dependencies image %s first (no ECR repository to reuse it from)\n", depsConfig.LocalImage())
+		return nil
+	}
+
+	image := docker.ECRImages(depsConfig, job.ECRRepository)[0]
+	job.Docker.BuildArgs[docker.DependenciesImageArg] = image
+	if !deps.Rebuild {
+		exists, err := ecrImageExists(ctx, ecr.NewFromConfig(cfg), image)
+		if err != nil {
+			return fmt.Errorf("looking up dependencies image: %w", err)
+		}
+		if exists {
+			fmt.Printf("♻️  Reusing dependencies image %s\n", image)
+			return nil
+		}
+	}
+
+	job.Dependencies = depsConfig
+	fmt.Printf("📦 Building dependencies image %s first\n", image)
+	return nil
+}
+
+// BuildDependencies builds and pushes the job's dependencies image, or pulls the published
+// one its model builds FROM and checks it supports the job's GEOS-Chem version
+func BuildDependencies(ctx context.Context, dockerBuilder *docker.DockerBuilder, job BuildJob) error {
+	if job.Dependencies == nil {
+		image := job.Docker.BuildArgs[docker.DependenciesImageArg]
+		if image == "" {
+			return nil
+		}
+		if err := dockerBuilder.PullImage(ctx, image); err != nil {
+			return err
+		}
+		labels, err := dockerBuilder.ImageLabels(ctx, image)
+		if err != nil {
+			return err
+		}
+		return geoschem.CheckDependenciesImage(labels, job.GeosChemVersion)
+	}
+
+	if err := dockerBuilder.BuildContainer(ctx, job.Dependencies); err != nil {
+		return fmt.Errorf("building dependencies image: %w", err)
+	}
+	if job.ECRRepository != "" {
+		if err := dockerBuilder.PushToECR(ctx, job.Dependencies, job.ECRRepository); err != nil {
+			return fmt.Errorf("pushing dependencies image: %w", err)
+		}
+	}
+	return nil
+}
+
+// ecrImageExists reports whether an ECR image reference (<registry>/<repository>:<tag>) exists
+func ecrImageExists(ctx context.Context, client *ecr.Client, image string) (bool, error) {
+	slash := strings.Index(image, "/")
+	colon := strings.LastIndex(image, ":")
+	if slash < 0 || colon < slash {
+		return false, fmt.Errorf("invalid ECR image reference: %s", image)
+	}
+
+	_, err := client.DescribeImages(ctx, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(image[slash+1 : colon]),
+		ImageIds:       []ecrtypes.ImageIdentifier{{ImageTag: aws.String(image[colon+1:])}},
+	})
+	var imageNotFound *ecrtypes.ImageNotFoundException
+	var repositoryNotFound *ecrtypes.RepositoryNotFoundException
+	if errors.As(err, &imageNotFound) || errors.As(err, &repositoryNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
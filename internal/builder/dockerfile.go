@@ -0,0 +1,220 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+	"github.com/scttfrdmn/geoschem-aws/internal/geoschem"
+)
+
+// ReadDockerfiles reads the local Dockerfiles source.dockerfile and the compilers name,
+// hashing each so that editing one changes the build settings' fingerprint. Like
+// ReadPatches, it reads them once, before anything launches.
+func ReadDockerfiles(config *common.BuildConfig) error {
+	if err := readDockerfile(&config.Source.Dockerfile); err != nil {
+		return err
+	}
+	for arch, archConfig := range config.Architectures {
+		for compiler, compilerConfig := range archConfig.Compilers {
+			if compilerConfig.Dockerfile.Local == "" || compilerConfig.Dockerfile.Content != "" {
+				continue
+			}
+			if err := readDockerfile(&compilerConfig.Dockerfile); err != nil {
+				return fmt.Errorf("%s on %s: %w", compiler, arch, err)
+			}
+			archConfig.Compilers[compiler] = compilerConfig
+		}
+	}
+	return nil
+}
+
+func readDockerfile(dockerfile *common.DockerfileConfig) error {
+	if dockerfile.Local == "" || dockerfile.Content != "" {
+		return nil
+	}
+	path, err := common.ExpandHome(dockerfile.Local)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading Dockerfile: %w", err)
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("Dockerfile %s is empty", dockerfile.Local)
+	}
+	if len(data) > docker.MaxDockerfileBytes {
+		return fmt.Errorf("Dockerfile %s is %d KiB, more than the %d KiB a build command can carry; commit it to the source instead",
+			dockerfile.Local, len(data)>>10, docker.MaxDockerfileBytes>>10)
+	}
+	sum := sha256.Sum256(data)
+	dockerfile.Content = string(data)
+	dockerfile.SHA256 = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// UseDockerfile points a build configuration at the configured Dockerfile and build context
+func UseDockerfile(dockerfile common.DockerfileConfig, buildConfig *geoschem.BuildConfiguration) {
+	if dockerfile.Path != "" {
+		buildConfig.Dockerfile = dockerfile.Path
+	}
+	if dockerfile.Context != "" {
+		buildConfig.BuildContext = dockerfile.Context
+	}
+}
+
+// UseLocalDockerfile gives docker build configs the local Dockerfile read by ReadDockerfiles,
+// if there is one
+func UseLocalDockerfile(dockerfile common.DockerfileConfig, configs ...*docker.BuildConfig) {
+	if dockerfile.Content == "" {
+		return
+	}
+	for _, config := range configs {
+		if config != nil {
+			config.DockerfileContent = dockerfile.Content
+			config.DockerfileLabel = dockerfile.Label()
+		}
+	}
+}
+
+// CheckDockerfile fails when the packed source.local lacks the build context or Dockerfile
+// the build configuration names, or a file the Dockerfile or the dependencies Dockerfile
+// copies into the image, before an instance launches to find out. Clones are checked on the
+// build host.
+func CheckDockerfile(source common.SourceConfig, dockerfile common.DockerfileConfig, buildConfig *geoschem.BuildConfiguration) error {
+	if source.Archive == "" {
+		return nil
+	}
+	context := buildConfig.BuildContextDir()
+	if info, err := os.Stat(filepath.Join(source.Local, filepath.FromSlash(context))); err != nil || !info.IsDir() {
+		return fmt.Errorf("build context %s isn't a directory in %s", context, source.Local)
+	}
+
+	path, content := buildConfig.GetDockerfilePath(), dockerfile.Content
+	if content == "" {
+		data, err := os.ReadFile(filepath.Join(source.Local, filepath.FromSlash(path)))
+		if err != nil {
+			return fmt.Errorf("Dockerfile %s isn't in %s", path, source.Local)
+		}
+		content = string(data)
+	} else {
+		path = dockerfile.Local
+	}
+	model := buildConfig.ToDockerBuildConfig("", "", "")
+	if err := checkCopySources(source.Local, context, path, content, model.BuildArgs); err != nil {
+		return err
+	}
+
+	// Model images build FROM the dependencies image, built from the same checkout when it
+	// isn't published yet
+	deps := buildConfig.ToDependenciesBuildConfig("", "")
+	depsPath := deps.DockerfileDir + "/" + deps.Dockerfile
+	data, err := os.ReadFile(filepath.Join(source.Local, filepath.FromSlash(depsPath)))
+	if err != nil {
+		return nil
+	}
+	return checkCopySources(source.Local, deps.DockerfileDir, depsPath, string(data), deps.BuildArgs)
+}
+
+// checkCopySources fails when a COPY or ADD in the Dockerfile names a file that isn't in
+// the build context
+func checkCopySources(local, context, path, content string, buildArgs map[string]string) error {
+	contextDir := filepath.Join(local, filepath.FromSlash(context))
+	for _, source := range copySources(content, buildArgs) {
+		matches, err := filepath.Glob(filepath.Join(contextDir, filepath.FromSlash(source)))
+		if err != nil || len(matches) == 0 {
+			return fmt.Errorf("%s copies %s, which isn't in build context %s", path, source, context)
+		}
+	}
+	return nil
+}
+
+// copySources returns the build context files a Dockerfile's COPY and ADD instructions
+// read, with build args, and ARG defaults for those not given, substituted. Copies from
+// other stages or images, URLs and heredocs are skipped.
+func copySources(content string, buildArgs map[string]string) []string {
+	args := make(map[string]string, len(buildArgs))
+	for name, value := range buildArgs {
+		args[name] = value
+	}
+	expand := func(s string) string {
+		return os.Expand(s, func(name string) string {
+			name, fallback, hasFallback := strings.Cut(name, ":-")
+			if value, ok := args[name]; ok && (value != "" || !hasFallback) {
+				return value
+			}
+			return fallback
+		})
+	}
+
+	var sources []string
+	for _, line := range dockerfileInstructions(content) {
+		fields := strings.Fields(line)
+		instruction := strings.ToUpper(fields[0])
+		switch {
+		case instruction == "ARG":
+			for _, arg := range fields[1:] {
+				name, value, _ := strings.Cut(arg, "=")
+				if _, given := args[name]; !given {
+					args[name] = expand(strings.Trim(value, `"'`))
+				}
+			}
+		case instruction == "COPY" || instruction == "ADD":
+			operands := fields[1:]
+			for len(operands) > 0 && strings.HasPrefix(operands[0], "--") {
+				if strings.HasPrefix(operands[0], "--from") {
+					operands = nil
+				} else {
+					operands = operands[1:]
+				}
+			}
+			if len(operands) > 0 && strings.HasPrefix(operands[0], "[") {
+				var exec []string
+				if err := json.Unmarshal([]byte(strings.Join(operands, " ")), &exec); err != nil {
+					continue
+				}
+				operands = exec
+			}
+			if len(operands) < 2 {
+				continue
+			}
+			for _, source := range operands[:len(operands)-1] {
+				if strings.HasPrefix(source, "<<") || strings.Contains(source, "://") {
+					continue
+				}
+				sources = append(sources, expand(source))
+			}
+		}
+	}
+	return sources
+}
+
+// dockerfileInstructions returns a Dockerfile's instructions, one per element, with line
+// continuations joined and comments dropped
+func dockerfileInstructions(content string) []string {
+	var instructions []string
+	var current strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if continued, ok := strings.CutSuffix(trimmed, "\\"); ok {
+			current.WriteString(continued + " ")
+			continue
+		}
+		current.WriteString(trimmed)
+		if instruction := strings.TrimSpace(current.String()); instruction != "" {
+			instructions = append(instructions, instruction)
+		}
+		current.Reset()
+	}
+	return instructions
+}
@@ -3,9 +3,11 @@ package builder
 import (
     "context"
     "fmt"
-    "time"
     "encoding/base64"
     "sort"
+    "strconv"
+    "strings"
+    "sync/atomic"
 
     "github.com/aws/aws-sdk-go-v2/service/ec2"
     "github.com/aws/aws-sdk-go-v2/service/ec2/types"
@@ -16,122 +18,272 @@ import (
 
 func (b *Builder) launchBuildInstance(ctx context.Context, config *common.BuildConfig, arch string) (string, error) {
     archConfig := config.Architectures[arch]
+    b.waits = config.Waits.For(archConfig.InstanceType)
     
-    // Find latest CIQ Rocky Linux 9 AMI based on architecture
-    amiID, err := b.findLatestRockyLinuxAMI(ctx, arch, config.AWS.Region)
+    hostOS, err := config.HostOS.Resolve()
     if err != nil {
-        return "", fmt.Errorf("finding Rocky Linux AMI: %w", err)
+        return "", err
     }
     
-    userData := b.generateUserData(config)
+    // Find latest AMI for the configured host OS and architecture
+    ami, err := b.findLatestAMI(ctx, hostOS, arch, config.AWS.Region)
+    if err != nil {
+        return "", fmt.Errorf("finding %s AMI: %w", hostOS.DisplayName, err)
+    }
+    
+    userData := b.generateUserData(config, hostOS)
     
     input := &ec2.RunInstancesInput{
-        ImageId:      aws.String(amiID),
+        ImageId:      ami.ImageId,
         InstanceType: types.InstanceType(archConfig.InstanceType),
         MinCount:     aws.Int32(1),
         MaxCount:     aws.Int32(1),
-        KeyName:      aws.String(config.AWS.KeyPair),
         SecurityGroupIds: []string{config.AWS.SecurityGroup},
-        SubnetId:     aws.String(config.AWS.SubnetID),
         UserData:     aws.String(base64.StdEncoding.EncodeToString([]byte(userData))),
         IamInstanceProfile: &types.IamInstanceProfileSpecification{
-            Name: aws.String("geoschem-ec2-builder-profile"), // IAM instance profile for ECR access
+            Name: aws.String(common.BuilderInstanceProfile), // IAM instance profile for ECR access
         },
-        TagSpecifications: []types.TagSpecification{
-            {
-                ResourceType: types.ResourceTypeInstance,
-                Tags: []types.Tag{
-                    {Key: aws.String("Name"), Value: aws.String("geoschem-builder")},
-                    {Key: aws.String("Project"), Value: aws.String("geoschem-aws")},
-                },
+    }
+    
+    // Size the root volume for the build; gp3 volumes are deleted with the instance
+    input.BlockDeviceMappings = []types.BlockDeviceMapping{
+        {
+            DeviceName: ami.RootDeviceName,
+            Ebs: &types.EbsBlockDevice{
+                VolumeSize:          aws.Int32(int32(archConfig.RootVolumeSize())),
+                VolumeType:          types.VolumeTypeGp3,
+                DeleteOnTermination: aws.Bool(true),
             },
         },
     }
     
-    result, err := b.ec2Client.RunInstances(ctx, input)
+    tags := launchTags(config, arch)
+    input.TagSpecifications = []types.TagSpecification{
+        {ResourceType: types.ResourceTypeInstance, Tags: tags},
+        {ResourceType: types.ResourceTypeVolume, Tags: tags},
+    }
+    
+    if archConfig.Spot {
+        spotOptions := &types.SpotMarketOptions{
+            SpotInstanceType:             types.SpotInstanceTypeOneTime,
+            InstanceInterruptionBehavior: types.InstanceInterruptionBehaviorTerminate,
+        }
+        if archConfig.SpotMaxPrice > 0 {
+            spotOptions.MaxPrice = aws.String(strconv.FormatFloat(archConfig.SpotMaxPrice, 'f', -1, 64))
+        }
+        input.InstanceMarketOptions = &types.InstanceMarketOptionsRequest{
+            MarketType:  types.MarketTypeSpot,
+            SpotOptions: spotOptions,
+        }
+    }
+    
+    // Key pairs are only needed for SSH; SSM-driven instances launch without one
+    if config.AWS.KeyPair != "" {
+        input.KeyName = aws.String(config.AWS.KeyPair)
+    }
+    
+    // Override core count / SMT when configured (GeosChem often runs better without hyperthreading)
+    if archConfig.CPUOptions.IsSet() {
+        input.CpuOptions = &types.CpuOptionsRequest{}
+        if archConfig.CPUOptions.CoreCount > 0 {
+            input.CpuOptions.CoreCount = aws.Int32(int32(archConfig.CPUOptions.CoreCount))
+        }
+        if archConfig.CPUOptions.ThreadsPerCore > 0 {
+            input.CpuOptions.ThreadsPerCore = aws.Int32(int32(archConfig.CPUOptions.ThreadsPerCore))
+        }
+    }
+    
+    // MPI across nodes: EFA for the interconnect, and a cluster placement group to keep the
+    // nodes on the same network spine
+    if archConfig.EFA {
+        if err := b.checkEFA(ctx, config, archConfig.InstanceType); err != nil {
+            return "", err
+        }
+        input.NetworkInterfaces = []types.InstanceNetworkInterfaceSpecification{efaInterface(config.AWS.SecurityGroup)}
+        input.SecurityGroupIds = nil
+    }
+    if group := config.Placement.ClusterGroup; group != "" {
+        if err := b.ensureClusterGroup(ctx, group); err != nil {
+            return "", err
+        }
+        input.Placement = &types.Placement{GroupName: aws.String(group)}
+    }
+    
+    // Rotate through the configured subnets so concurrent launches spread across AZs,
+    // moving on when one AZ lacks capacity
+    subnets := config.AWS.Subnets()
+    if len(subnets) == 0 {
+        return "", fmt.Errorf("no subnet configured")
+    }
+    subnets, err = b.placeSubnets(ctx, config, subnets)
+    if err != nil {
+        return "", err
+    }
+    start := int(nextSubnet.Add(1)-1) % len(subnets)
+    
+    instanceID, subnet, err := b.runInSubnets(ctx, input, subnets, start)
+    if err != nil && input.InstanceMarketOptions != nil && archConfig.SpotFallback && isSpotCapacityError(err) {
+        // Builds only cost more On-Demand; they shouldn't wait for Spot capacity
+        fmt.Printf("No Spot capacity for %s (%v); launching On-Demand instead...\n", archConfig.InstanceType, err)
+        input.InstanceMarketOptions = nil
+        instanceID, subnet, err = b.runInSubnets(ctx, input, subnets, start)
+    }
     if err != nil {
         return "", fmt.Errorf("launching instance: %w", err)
     }
     
-    instanceID := *result.Instances[0].InstanceId
-    fmt.Printf("Launched instance: %s (Rocky Linux 9)\n", instanceID)
+    market := "On-Demand"
+    if input.InstanceMarketOptions != nil {
+        market = "Spot"
+    }
+    if archConfig.EFA {
+        market += " with EFA"
+    }
+    fmt.Printf("Launched instance: %s (%s %s in %s)\n", instanceID, market, hostOS.DisplayName, subnet)
     return instanceID, nil
 }
 
-// findLatestRockyLinuxAMI finds the latest CIQ Rocky Linux 9 AMI for the specified architecture and region
-func (b *Builder) findLatestRockyLinuxAMI(ctx context.Context, arch string, region string) (string, error) {
-    var namePattern string
-    var architecture string
-    
-    switch arch {
-    case "x86_64":
-        namePattern = "Rocky-9-EC2-Base-9.*x86_64*"
-        architecture = "x86_64"
-    case "arm64":
-        namePattern = "Rocky-9-EC2-Base-9.*aarch64*"
-        architecture = "arm64"
-    default:
-        return "", fmt.Errorf("unsupported architecture: %s", arch)
-    }
-    
-    // CIQ is the official publisher of Rocky Linux AMIs (account ID: 679593333241)
-    input := &ec2.DescribeImagesInput{
-        Owners: []string{"679593333241"},
-        Filters: []types.Filter{
-            {
-                Name:   aws.String("name"),
-                Values: []string{namePattern},
-            },
-            {
-                Name:   aws.String("architecture"),
-                Values: []string{architecture},
-            },
-            {
-                Name:   aws.String("root-device-type"),
-                Values: []string{"ebs"},
-            },
-            {
-                Name:   aws.String("virtualization-type"),
-                Values: []string{"hvm"},
-            },
-            {
-                Name:   aws.String("state"),
-                Values: []string{"available"},
-            },
-        },
+// withSpotFallback lets a build instance launch On-Demand when Spot has no capacity: a build
+// only costs more that way, while a run asked for Spot depends on being on it. It copies the
+// architectures so the shared matrix config is left alone.
+func withSpotFallback(config *common.BuildConfig, arch string) {
+    architectures := make(map[string]common.ArchConfig, len(config.Architectures))
+    for name, archConfig := range config.Architectures {
+        architectures[name] = archConfig
+    }
+    archConfig := architectures[arch]
+    archConfig.SpotFallback = true
+    architectures[arch] = archConfig
+    config.Architectures = architectures
+}
+
+// runInSubnets launches the instance in the first subnet with capacity, starting at start,
+// and returns the instance and its subnet
+func (b *Builder) runInSubnets(ctx context.Context, input *ec2.RunInstancesInput, subnets []string, start int) (string, string, error) {
+    spot := input.InstanceMarketOptions != nil
+    
+    var lastErr error
+    for i := range subnets {
+        subnet := subnets[(start+i)%len(subnets)]
+        // An EFA launch names its subnet on the interface instead
+        if len(input.NetworkInterfaces) > 0 {
+            input.NetworkInterfaces[0].SubnetId = aws.String(subnet)
+        } else {
+            input.SubnetId = aws.String(subnet)
+        }
+        
+        result, err := b.ec2Client.RunInstances(ctx, input)
+        if err != nil {
+            lastErr = err
+            if (isCapacityError(err) || spot && isSpotCapacityError(err)) && i < len(subnets)-1 {
+                fmt.Printf("No capacity in subnet %s, trying the next subnet...\n", subnet)
+                continue
+            }
+            break
+        }
+        return *result.Instances[0].InstanceId, subnet, nil
+    }
+    return "", "", lastErr
+}
+
+// nextSubnet rotates launches across subnets
+var nextSubnet atomic.Uint64
+
+// isCapacityError reports whether a launch failed for lack of capacity in the subnet's AZ
+func isCapacityError(err error) bool {
+    for _, code := range []string{"InsufficientInstanceCapacity", "InsufficientFreeAddressesInSubnet", "Unsupported"} {
+        if strings.Contains(err.Error(), code) {
+            return true
+        }
+    }
+    return false
+}
+
+// isSpotCapacityError reports whether a Spot launch failed for lack of Spot capacity or
+// because the Spot price is above the configured maximum
+func isSpotCapacityError(err error) bool {
+    for _, code := range []string{"InsufficientInstanceCapacity", "InsufficientCapacity", "SpotMaxPriceTooLow", "MaxSpotInstanceCountExceeded"} {
+        if strings.Contains(err.Error(), code) {
+            return true
+        }
+    }
+    return false
+}
+
+// launchTags returns the platform tags plus any configured extra tags, sorted by key
+func launchTags(config *common.BuildConfig, arch string) []types.Tag {
+    user := common.CurrentUser()
+    values := map[string]string{
+        "Name":    config.Tagging.InstanceNameFor(arch, user),
+        "Project": "geoschem-aws",
+        "Owner":   user,
+    }
+    if config.Tagging.BuildTag != "" {
+        values["BuildTag"] = config.Tagging.BuildTag
+    }
+    for key, value := range config.Tagging.Tags {
+        values[key] = value
+    }
+    
+    keys := make([]string, 0, len(values))
+    for key := range values {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    
+    tags := make([]types.Tag, 0, len(keys))
+    for _, key := range keys {
+        tags = append(tags, types.Tag{Key: aws.String(key), Value: aws.String(values[key])})
+    }
+    return tags
+}
+
+// findLatestAMI finds the newest AMI of the host OS for the specified architecture and region
+func (b *Builder) findLatestAMI(ctx context.Context, hostOS *common.HostOS, arch string, region string) (types.Image, error) {
+    if arch != "x86_64" && arch != "arm64" {
+        return types.Image{}, fmt.Errorf("unsupported architecture: %s", arch)
     }
     
-    result, err := b.ec2Client.DescribeImages(ctx, input)
+    namePattern, err := hostOS.AMINamePattern(arch)
     if err != nil {
-        return "", fmt.Errorf("describing Rocky Linux AMIs: %w", err)
+        return types.Image{}, err
     }
     
-    if len(result.Images) == 0 {
-        return "", fmt.Errorf("no Rocky Linux 9 AMIs found for architecture %s in region %s", arch, region)
+    images, err := b.describeAMIs(ctx, hostOS.AMIOwners, namePattern, arch)
+    if err != nil {
+        return types.Image{}, fmt.Errorf("describing %s AMIs: %w", hostOS.DisplayName, err)
     }
     
-    // Sort by creation date to get the latest
-    sort.Slice(result.Images, func(i, j int) bool {
-        return *result.Images[i].CreationDate > *result.Images[j].CreationDate
-    })
+    if len(images) == 0 {
+        return types.Image{}, fmt.Errorf("no %s AMIs matching %q (owners %v) found for architecture %s in region %s; set host_os.ami_name_pattern or host_os.ami_owner if the publisher changed its naming",
+            hostOS.DisplayName, namePattern, hostOS.AMIOwners, arch, region)
+    }
     
-    latestAMI := result.Images[0]
-    fmt.Printf("Selected Rocky Linux 9 AMI: %s (%s)\n", *latestAMI.ImageId, *latestAMI.Name)
+    latestAMI := images[0]
+    fmt.Printf("Selected %s AMI: %s (%s)\n", hostOS.DisplayName, *latestAMI.ImageId, *latestAMI.Name)
+    b.warnStaleAMI(ctx, hostOS, arch, latestAMI)
     
-    return *latestAMI.ImageId, nil
+    return latestAMI, nil
 }
 
-func (b *Builder) generateUserData(config *common.BuildConfig) string {
+func (b *Builder) generateUserData(config *common.BuildConfig, hostOS *common.HostOS) string {
+    // tmux keeps long build commands running when the builder's SSH connection drops
+    install := "dnf update -y\n# Install Docker\ndnf install -y docker git unzip tmux"
+    if hostOS.PackageManager == "apt" {
+        install = "apt-get update -y\n# Install Docker\nDEBIAN_FRONTEND=noninteractive apt-get install -y docker.io git unzip tmux"
+    }
+    
     return `#!/bin/bash
-# Rocky Linux 9 setup script
-dnf update -y
-# Install Docker
-dnf install -y docker git unzip
+# ` + hostOS.DisplayName + ` setup script
+# Failed steps are recorded for the builder instead of aborting setup
+mkdir -p ` + setupStateDir + `
+trap 'echo "$BASH_COMMAND" >> ` + setupWarningsFile + `' ERR
+` + install + `
 # Start and enable Docker
 systemctl start docker
 systemctl enable docker
-usermod -a -G docker rocky
-# Install AWS CLI v2 for x86_64
+usermod -a -G docker ` + hostOS.SSHUser + `
+# Install AWS CLI v2 for the instance architecture
 if [ "$(uname -m)" = "x86_64" ]; then
     curl "https://awscli.amazonaws.com/awscli-exe-linux-x86_64.zip" -o "awscliv2.zip"
 else
@@ -139,9 +291,11 @@ else
 fi
 unzip awscliv2.zip
 sudo ./aws/install
+rm -rf aws awscliv2.zip
 # Configure ECR login
 aws ecr get-login-password --region ` + config.AWS.Region + ` | docker login --username AWS --password-stdin ` + config.ECRRepository + `
-echo "Rocky Linux 9 instance setup complete" > /tmp/setup-complete
+# Readiness signal checked by the builder before preparing the instance
+echo "` + hostOS.DisplayName + ` instance setup complete" > ` + setupCompleteMarker + `
 `
 }
 
@@ -151,14 +305,59 @@ func (b *Builder) waitForInstance(ctx context.Context, instanceID string) error
     waiter := ec2.NewInstanceRunningWaiter(b.ec2Client)
     return waiter.Wait(ctx, &ec2.DescribeInstancesInput{
         InstanceIds: []string{instanceID},
-    }, 5*time.Minute)
+    }, b.waits.InstanceRunning)
 }
 
+// stopInstance stops the instance and waits until EC2 reports it stopped, when it no longer
+// uses vCPU quota or bills for compute
+func (b *Builder) stopInstance(ctx context.Context, instanceID string) error {
+    fmt.Printf("Stopping instance: %s\n", instanceID)
+
+    _, err := b.ec2Client.StopInstances(ctx, &ec2.StopInstancesInput{
+        InstanceIds: []string{instanceID},
+    })
+    if err != nil {
+        return fmt.Errorf("stopping instance: %w", err)
+    }
+
+    waiter := ec2.NewInstanceStoppedWaiter(b.ec2Client)
+    err = waiter.Wait(ctx, &ec2.DescribeInstancesInput{
+        InstanceIds: []string{instanceID},
+    }, b.waits.Termination)
+    if err != nil {
+        return fmt.Errorf("waiting for instance %s to stop: %w", instanceID, err)
+    }
+
+    fmt.Printf("Instance %s stopped\n", instanceID)
+    return nil
+}
+
+// TerminateInstance terminates an instance the platform launched earlier, such as one left
+// stopped by a paused run
+func (b *Builder) TerminateInstance(ctx context.Context, instanceID string) error {
+    return b.terminateInstance(ctx, instanceID)
+}
+
+// terminateInstance terminates the instance and waits until EC2 reports it terminated, so
+// callers only consider it gone once it no longer runs or bills
 func (b *Builder) terminateInstance(ctx context.Context, instanceID string) error {
     fmt.Printf("Terminating instance: %s\n", instanceID)
     
     _, err := b.ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
         InstanceIds: []string{instanceID},
     })
-    return err
+    if err != nil {
+        return fmt.Errorf("terminating instance: %w", err)
+    }
+
+    waiter := ec2.NewInstanceTerminatedWaiter(b.ec2Client)
+    err = waiter.Wait(ctx, &ec2.DescribeInstancesInput{
+        InstanceIds: []string{instanceID},
+    }, b.waits.Termination)
+    if err != nil {
+        return fmt.Errorf("waiting for instance %s to terminate: %w", instanceID, err)
+    }
+
+    fmt.Printf("Instance %s terminated\n", instanceID)
+    return nil
 }
\ No newline at end of file
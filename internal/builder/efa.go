@@ -0,0 +1,107 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/network"
+)
+
+// checkEFA fails before launch when the instance type has no EFA or the security group
+// would drop EFA traffic between the nodes, which otherwise only shows as MPI hanging
+func (b *Builder) checkEFA(ctx context.Context, config *common.BuildConfig, instanceType string) error {
+	out, err := b.ec2Client.DescribeInstanceTypes(ctx, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []types.InstanceType{types.InstanceType(instanceType)},
+	})
+	if err != nil {
+		return fmt.Errorf("looking up %s: %w", instanceType, err)
+	}
+	if len(out.InstanceTypes) == 0 {
+		return fmt.Errorf("instance type %s not found in %s", instanceType, b.region)
+	}
+	if info := out.InstanceTypes[0].NetworkInfo; info == nil || !aws.ToBool(info.EfaSupported) {
+		return fmt.Errorf("%s doesn't support EFA; use an EFA instance type such as hpc6a.48xlarge, hpc7a.96xlarge or hpc7g.16xlarge", instanceType)
+	}
+
+	missing, err := network.NewManager(b.cfg).MissingEFARules(ctx, config.AWS.SecurityGroup)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("security group %s lacks the rules EFA needs (%s); add them with: network efa-rules -security-group %s",
+			config.AWS.SecurityGroup, strings.Join(missing, ", "), config.AWS.SecurityGroup)
+	}
+	return nil
+}
+
+// efaInterface is the primary network interface of an EFA launch. Its subnet is set per
+// attempt by runInSubnets.
+func efaInterface(securityGroup string) types.InstanceNetworkInterfaceSpecification {
+	return types.InstanceNetworkInterfaceSpecification{
+		DeviceIndex:         aws.Int32(0),
+		NetworkCardIndex:    aws.Int32(0),
+		InterfaceType:       aws.String(string(types.NetworkInterfaceTypeEfa)),
+		Groups:              []string{securityGroup},
+		DeleteOnTermination: aws.Bool(true),
+	}
+}
+
+// ensureClusterGroup creates the cluster placement group if it doesn't exist. Groups cost
+// nothing, so they're kept for later launches rather than rolled back.
+func (b *Builder) ensureClusterGroup(ctx context.Context, name string) error {
+	out, err := b.ec2Client.DescribePlacementGroups(ctx, &ec2.DescribePlacementGroupsInput{
+		Filters: []types.Filter{{Name: aws.String("group-name"), Values: []string{name}}},
+	})
+	if err != nil {
+		return fmt.Errorf("looking up placement group %s: %w", name, err)
+	}
+	if len(out.PlacementGroups) > 0 {
+		group := out.PlacementGroups[0]
+		if group.Strategy != types.PlacementStrategyCluster {
+			return fmt.Errorf("placement group %s uses the %s strategy; MPI between nodes needs a cluster group", name, group.Strategy)
+		}
+		return nil
+	}
+
+	_, err = b.ec2Client.CreatePlacementGroup(ctx, &ec2.CreatePlacementGroupInput{
+		GroupName: aws.String(name),
+		Strategy:  types.PlacementStrategyCluster,
+		TagSpecifications: []types.TagSpecification{{
+			ResourceType: types.ResourceTypePlacementGroup,
+			Tags:         []types.Tag{{Key: aws.String("Project"), Value: aws.String("geoschem-aws")}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("creating cluster placement group %s: %w", name, err)
+	}
+	fmt.Printf("Created cluster placement group %s\n", name)
+	return nil
+}
+
+// clusterGroupZone returns the AZ of the instances already in the placement group, which
+// later nodes must join; empty when it has none
+func (b *Builder) clusterGroupZone(ctx context.Context, name string) (string, error) {
+	out, err := b.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("placement-group-name"), Values: []string{name}},
+			{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("looking up instances in placement group %s: %w", name, err)
+	}
+	for _, reservation := range out.Reservations {
+		for _, instance := range reservation.Instances {
+			if instance.Placement != nil && instance.Placement.AvailabilityZone != nil {
+				return *instance.Placement.AvailabilityZone, nil
+			}
+		}
+	}
+	return "", nil
+}
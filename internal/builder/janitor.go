@@ -0,0 +1,131 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/ssh"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// Janitor removes the current user's leftover resources recorded in the ledger for this
+// builder's region, so a ledger in a shared GEOSCHEM_AWS_HOME is only swept by its owners.
+// With dryRun it only lists them.
+func (b *Builder) Janitor(ctx context.Context, dryRun bool) error {
+	store, err := state.OpenDefault()
+	if err != nil {
+		return err
+	}
+	ledger := state.NewResourceLedger(store)
+
+	records, err := ledger.Records()
+	if err != nil {
+		return err
+	}
+
+	user := common.CurrentUser()
+	found := 0
+	failed := 0
+	for _, record := range records {
+		if record.Region != b.region || !record.OwnedBy(user) {
+			continue
+		}
+		found++
+
+		fmt.Printf("🧹 %s %s (recorded %s: %s)\n", record.Kind, record.ID,
+			record.RecordedAt.Format("2006-01-02 15:04"), record.Reason)
+		if dryRun {
+			continue
+		}
+
+		if err := b.removeResource(ctx, record); err != nil {
+			fmt.Printf("   ❌ %v\n", err)
+			failed++
+			continue
+		}
+		if err := ledger.Remove(record.Kind, record.ID); err != nil {
+			return err
+		}
+		fmt.Println("   ✅ Removed")
+	}
+
+	// Per-build key pairs whose builds never cleaned up expire after ssh.EphemeralKeyTTL
+	keyPairManager := b.sweepingKeyPairManager()
+	expired, err := keyPairManager.ExpiredEphemeralKeyPairs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, keyName := range expired {
+		found++
+		fmt.Printf("🧹 %s %s (expired per-build key)\n", state.ResourceKeyPair, keyName)
+		if dryRun {
+			continue
+		}
+		if err := keyPairManager.DeleteEphemeralKeyPair(ctx, keyName); err != nil {
+			fmt.Printf("   ❌ %v\n", err)
+			failed++
+			continue
+		}
+		if err := ledger.Remove(state.ResourceKeyPair, keyName); err != nil {
+			return err
+		}
+		fmt.Println("   ✅ Removed")
+	}
+
+	// Instance Connect keys only exist locally, so nothing in AWS expires them
+	staleKeys, err := ssh.StaleLocalKeys(connectKeyPrefix)
+	if err != nil {
+		return err
+	}
+	for _, keyName := range staleKeys {
+		found++
+		fmt.Printf("🧹 local key %s (stale Instance Connect key)\n", keyName)
+		if dryRun {
+			continue
+		}
+		if err := ssh.RemoveLocalKey(keyName); err != nil {
+			fmt.Printf("   ❌ %v\n", err)
+			failed++
+			continue
+		}
+		fmt.Println("   ✅ Removed")
+	}
+
+	if found == 0 {
+		fmt.Printf("No leftover resources found in %s\n", b.region)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d leftover resources could not be removed", failed, found)
+	}
+	return nil
+}
+
+// removeResource deletes one leftover resource, treating already-deleted resources as removed
+func (b *Builder) removeResource(ctx context.Context, record state.ResourceRecord) error {
+	switch record.Kind {
+	case state.ResourceInstance:
+		_, err := b.ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
+			InstanceIds: []string{record.ID},
+		})
+		if err != nil && !strings.Contains(err.Error(), "InvalidInstanceID.NotFound") {
+			return fmt.Errorf("terminating instance: %w", err)
+		}
+		return nil
+	case state.ResourceKeyPair:
+		return b.sweepingKeyPairManager().DeleteEphemeralKeyPair(ctx, record.ID)
+	default:
+		return fmt.Errorf("unknown resource kind '%s'", record.Kind)
+	}
+}
+
+// sweepingKeyPairManager removes per-build keys along with their private keys wherever
+// key_storage kept them, since the janitor doesn't know which storage a build used
+func (b *Builder) sweepingKeyPairManager() *ssh.KeyPairManager {
+	keyPairManager := ssh.NewKeyPairManager(b.ec2Client)
+	keyPairManager.SetKeyStore(ssh.SweepKeyStore(b.profile, b.region))
+	return keyPairManager
+}
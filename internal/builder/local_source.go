@@ -0,0 +1,210 @@
+package builder
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+)
+
+// PackLocalSource packs the source.local working copy into a .tar.gz that builds upload
+// instead of cloning source.repo, and returns a function that removes it. The archive holds
+// tracked files as they are on disk, uncommitted changes included, and untracked files that
+// aren't ignored; .git is left out. Without source.local, or when it's packed, it does nothing.
+func PackLocalSource(ctx context.Context, config *common.BuildConfig) (func(), error) {
+	if config.Source.Local == "" || config.Source.Archive != "" {
+		return func() {}, nil
+	}
+	// SSM would upload the tree in 48 KB commands, and a Batch job can't be sent files at all
+	if backend := config.Execution.BackendName(); backend != common.BackendSSH {
+		return nil, fmt.Errorf("source.local needs the ssh backend, not %s", backend)
+	}
+	// The working copy's submodules are uploaded as checked out
+	if len(config.Source.Submodules) > 0 {
+		return nil, fmt.Errorf("source.submodules only applies to clones; check the refs out in %s instead", config.Source.Local)
+	}
+
+	dir, err := common.ExpandHome(config.Source.Local)
+	if err != nil {
+		return nil, err
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, fmt.Errorf("resolving source.local: %w", err)
+	}
+	files, err := sourceFiles(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if changes, err := git(ctx, dir, "status", "--porcelain", "--ignore-submodules=none"); err != nil {
+		return nil, err
+	} else if changes != "" {
+		revision += "-dirty"
+	}
+	branch, err := git(ctx, dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	archive, size, err := writeSourceArchive(dir, files)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("📦 Packed %s at %s (%d files, %.1f MiB) to upload to build hosts\n",
+		dir, revision, len(files), float64(size)/(1<<20))
+
+	config.Source.Local = dir
+	config.Source.Archive = archive
+	config.Source.Revision = revision
+	if branch != "HEAD" { // Detached checkouts keep the configured branch
+		config.Source.Branch = branch
+	}
+	return func() { os.Remove(archive) }, nil
+}
+
+// UseLocalSource points docker build configs at the packed source.local, if there is one
+func UseLocalSource(source common.SourceConfig, configs ...*docker.BuildConfig) {
+	if source.Archive == "" {
+		return
+	}
+	for _, config := range configs {
+		if config == nil {
+			continue
+		}
+		config.SourceRepo = source.Local
+		config.SourceBranch = source.Branch
+		config.SourceArchive = source.Archive
+		config.SourceRevision = source.Revision
+	}
+}
+
+// sourceFiles lists the working copy's files relative to dir: tracked files, including those
+// of initialized submodules, and untracked files that .gitignore doesn't exclude
+func sourceFiles(ctx context.Context, dir string) ([]string, error) {
+	tracked, err := git(ctx, dir, "ls-files", "-z", "--cached", "--recurse-submodules")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(ctx, dir, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, file := range strings.Split(tracked+"\x00"+untracked, "\x00") {
+		if file != "" && !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files to upload in %s", dir)
+	}
+	return files, nil
+}
+
+// writeSourceArchive writes the files to a temporary .tar.gz and returns its path and size.
+// Tracked files deleted from the working copy are skipped, as are uninitialized submodules.
+func writeSourceArchive(dir string, files []string) (string, int64, error) {
+	out, err := os.CreateTemp("", "geoschem-source-*.tar.gz")
+	if err != nil {
+		return "", 0, fmt.Errorf("creating source archive: %w", err)
+	}
+	fail := func(err error) (string, int64, error) {
+		out.Close()
+		os.Remove(out.Name())
+		return "", 0, err
+	}
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		if err := addSourceFile(tw, dir, file); err != nil {
+			return fail(fmt.Errorf("packing %s: %w", file, err))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fail(fmt.Errorf("writing source archive: %w", err))
+	}
+	if err := gz.Close(); err != nil {
+		return fail(fmt.Errorf("writing source archive: %w", err))
+	}
+	info, err := out.Stat()
+	if err != nil {
+		return fail(fmt.Errorf("writing source archive: %w", err))
+	}
+	if err := out.Close(); err != nil {
+		return fail(fmt.Errorf("writing source archive: %w", err))
+	}
+	return out.Name(), info.Size(), nil
+}
+
+// addSourceFile writes one regular file or symlink to the archive
+func addSourceFile(tw *tar.Writer, dir, file string) error {
+	path := filepath.Join(dir, filepath.FromSlash(file))
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var link string
+	switch {
+	case info.Mode().IsRegular():
+	case info.Mode()&fs.ModeSymlink != 0:
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = file
+	header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if link != "" {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// git runs git in dir and returns its trimmed output
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s in %s: %w: %s", args[0], dir, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,343 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// MatrixResult is the outcome of building one matrix combination
+type MatrixResult struct {
+	Architecture string
+	Compiler     string
+	MPI          string
+	Critical     bool
+	Duration     time.Duration
+	Err          error
+	Resumed      bool // Built by an earlier matrix build and skipped with resume
+}
+
+// Name identifies the combination as arch/compiler/mpi
+func (r MatrixResult) Name() string {
+	return fmt.Sprintf("%s/%s/%s", r.Architecture, r.Compiler, r.MPI)
+}
+
+// MatrixReport collects the results of a matrix build
+type MatrixReport struct {
+	Results []MatrixResult
+}
+
+// Failed returns the combinations that failed
+func (r *MatrixReport) Failed() []MatrixResult {
+	var failed []MatrixResult
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Err returns an error naming the failed critical combinations, or nil if all of them built
+func (r *MatrixReport) Err() error {
+	var names []string
+	for _, result := range r.Failed() {
+		if result.Critical {
+			names = append(names, result.Name())
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d critical combination(s) failed: %s", len(names), strings.Join(names, ", "))
+}
+
+// Table renders a pass/fail table of every attempted combination
+func (r *MatrixReport) Table() string {
+	var table strings.Builder
+
+	table.WriteString("\n📦 Matrix build results\n")
+	table.WriteString(fmt.Sprintf("%-32s %-8s %-9s %s\n", "Combination", "Result", "Duration", "Error"))
+	table.WriteString(strings.Repeat("-", 80) + "\n")
+	for _, result := range r.Results {
+		status := "✅ pass"
+		errText := ""
+		if result.Resumed {
+			errText = "(built earlier; skipped with --resume)"
+		}
+		if result.Err != nil {
+			status = "❌ fail"
+			errText = result.Err.Error()
+		}
+		name := result.Name()
+		if result.Critical {
+			name += " *"
+		}
+		table.WriteString(fmt.Sprintf("%-32s %-8s %-9s %s\n", name, status,
+			result.Duration.Round(time.Second), errText))
+	}
+
+	failed := len(r.Failed())
+	table.WriteString(fmt.Sprintf("\n%d passed, %d failed (* = critical)\n", len(r.Results)-failed, failed))
+	return table.String()
+}
+
+// matrixCell is one arch/compiler/mpi combination to build
+type matrixCell struct {
+	arch, compiler, mpi string
+}
+
+// matrixCells lists each architecture's combinations in a stable order
+func matrixCells(config *common.BuildConfig, arches []string) []matrixCell {
+	var cells []matrixCell
+	for _, arch := range arches {
+		archConfig := config.Architectures[arch]
+
+		compilers := make([]string, 0, len(archConfig.Compilers))
+		for compiler := range archConfig.Compilers {
+			compilers = append(compilers, compiler)
+		}
+		sort.Strings(compilers)
+
+		for _, compiler := range compilers {
+			for _, mpi := range archConfig.Compilers[compiler].MPIOptions {
+				cells = append(cells, matrixCell{arch: arch, compiler: compiler, mpi: mpi})
+			}
+		}
+	}
+	return cells
+}
+
+// buildCombinations builds the combinations, running up to the configured concurrency at
+// once within the vCPU quota. Without keep-going it starts no new builds after the first
+// failure, as matrix builds always have; builds already running finish. Each outcome is
+// recorded as it finishes, and with resume the combinations the last build finished are skipped.
+func (b *Builder) buildCombinations(ctx context.Context, config *common.BuildConfig, arches []string) (*MatrixReport, error) {
+	report := &MatrixReport{}
+	cells := matrixCells(config, arches)
+
+	// Pack a local source tree and read patches and local Dockerfiles once for every
+	// combination, before the settings are fingerprinted
+	removeSource, err := PackLocalSource(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	defer removeSource()
+	if err := ReadPatches(config); err != nil {
+		return nil, err
+	}
+	if err := ReadDockerfiles(config); err != nil {
+		return nil, err
+	}
+
+	progress, err := b.startMatrixProgress(config, arches)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*MatrixResult, len(cells))
+	var pending []int // Indexes of the cells to build
+	var pendingCells []matrixCell
+	for i, cell := range cells {
+		result := &MatrixResult{
+			Architecture: cell.arch,
+			Compiler:     cell.compiler,
+			MPI:          cell.mpi,
+			Critical:     config.Execution.IsCritical(cell.arch, cell.compiler, cell.mpi),
+		}
+		if built, ok := progress.built[result.Name()]; ok {
+			result.Duration = built.Duration
+			result.Resumed = true
+			results[i] = result
+			continue
+		}
+		pending = append(pending, i)
+		pendingCells = append(pendingCells, cell)
+	}
+
+	// Check each combination's configuration, source and Dockerfile before launching any;
+	// the builds reuse the lookups. A combination that fails its check is recorded as failed
+	// without launching, and without keep-going no combination launches.
+	stopped := false
+	checked := pending[:0]
+	pendingCells = pendingCells[:0]
+	for _, i := range pending {
+		cell := cells[i]
+		err := b.checkCombination(ctx, config, cell)
+		if err == nil {
+			checked = append(checked, i)
+			pendingCells = append(pendingCells, cell)
+			continue
+		}
+		result := &MatrixResult{
+			Architecture: cell.arch,
+			Compiler:     cell.compiler,
+			MPI:          cell.mpi,
+			Critical:     config.Execution.IsCritical(cell.arch, cell.compiler, cell.mpi),
+			Err:          err,
+		}
+		fmt.Printf("❌ %s failed its pre-launch check: %v\n", result.Name(), err)
+		progress.record(*result)
+		results[i] = result
+		if !config.Execution.KeepGoing {
+			stopped = true
+		}
+	}
+	pending = checked
+	if stopped {
+		pending, pendingCells = nil, nil
+	}
+
+	concurrency := 1
+	if len(pendingCells) > 0 {
+		if concurrency, err = b.safeConcurrency(ctx, config, pendingCells); err != nil {
+			return nil, err
+		}
+	}
+
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for _, i := range pending {
+		cell := cells[i]
+		slots <- struct{}{}
+		mu.Lock()
+		stop := stopped || ctx.Err() != nil
+		mu.Unlock()
+		if stop {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, cell matrixCell) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			fmt.Printf("Building: %s-%s-%s\n", cell.arch, cell.compiler, cell.mpi)
+			started := time.Now()
+			// The label attributes the build's goroutines (SSH streams, waiters) in pprof profiles
+			var err error
+			labels := pprof.Labels("combination", cell.arch+"/"+cell.compiler+"/"+cell.mpi)
+			pprof.Do(ctx, labels, func(ctx context.Context) {
+				err = b.BuildSingle(ctx, config, cell.arch, cell.compiler, cell.mpi)
+			})
+			result := &MatrixResult{
+				Architecture: cell.arch,
+				Compiler:     cell.compiler,
+				MPI:          cell.mpi,
+				Critical:     config.Execution.IsCritical(cell.arch, cell.compiler, cell.mpi),
+				Duration:     time.Since(started),
+				Err:          err,
+			}
+
+			progress.record(*result)
+			mu.Lock()
+			defer mu.Unlock()
+			results[i] = result
+			if err != nil {
+				fmt.Printf("❌ %s failed: %v\n", result.Name(), err)
+				if !config.Execution.KeepGoing {
+					stopped = true
+				}
+			}
+		}(i, cell)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result != nil {
+			report.Results = append(report.Results, *result)
+		}
+	}
+	return report, nil
+}
+
+// checkCombination resolves a combination's build configuration and checks its Dockerfile
+// and source from here
+func (b *Builder) checkCombination(ctx context.Context, config *common.BuildConfig, cell matrixCell) error {
+	buildConfig, err := resolveBuildConfig(config, cell.arch, cell.compiler, cell.mpi)
+	if err != nil {
+		return err
+	}
+	dockerfile := config.DockerfileFor(cell.arch, cell.compiler)
+	if err := CheckDockerfile(config.Source, dockerfile, buildConfig); err != nil {
+		return err
+	}
+	return b.sources.Check(ctx, config.Source, dockerfile, buildConfig)
+}
+
+// safeConcurrency caps the configured concurrency so the build instances running at once
+// fit in the free On-Demand vCPU quota, sizing every slot for the largest build instance.
+// It fails before the first launch when not even one build instance fits.
+func (b *Builder) safeConcurrency(ctx context.Context, config *common.BuildConfig, cells []matrixCell) (int, error) {
+	requested := min(config.Execution.MaxConcurrency(), max(len(cells), 1))
+
+	// Batch builds run in its compute environment, which enforces its own vCPU limit
+	if config.Execution.BackendName() == common.BackendBatch {
+		return requested, nil
+	}
+
+	largest := 0
+	for _, cell := range cells {
+		instanceType := config.Architectures[cell.arch].InstanceType
+		if !common.IsStandardInstance(instanceType) {
+			continue
+		}
+		vcpus, err := b.instanceVCPUs(ctx, instanceType)
+		if err != nil {
+			fmt.Printf("⚠️  Skipping the vCPU quota check: %v\n", err)
+			return requested, nil
+		}
+		largest = max(largest, vcpus)
+	}
+	if largest == 0 {
+		return requested, nil
+	}
+
+	limit, used, err := b.quotaChecker.StandardVCPUUsage(ctx)
+	if err != nil {
+		fmt.Printf("⚠️  Skipping the vCPU quota check: %v\n", err)
+		return requested, nil
+	}
+	free := limit - used
+	fits := free / largest
+	if fits < 1 {
+		return 0, fmt.Errorf("a %d-vCPU build instance doesn't fit in the On-Demand vCPU quota: %d of %d in use; "+
+			"wait for running instances to finish or request a quota increase", largest, used, limit)
+	}
+
+	if fits < requested {
+		fmt.Printf("⚠️  %d builds at once would need %d vCPUs, but only %d of %d are free; running %d at a time\n",
+			requested, requested*largest, free, limit, fits)
+		return fits, nil
+	}
+	return requested, nil
+}
+
+// instanceVCPUs returns the default vCPU count of an instance type
+func (b *Builder) instanceVCPUs(ctx context.Context, instanceType string) (int, error) {
+	if instance, err := common.LookupInstance(instanceType); err == nil {
+		return instance.VCPUs, nil
+	}
+
+	out, err := b.ec2Client.DescribeInstanceTypes(ctx, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []types.InstanceType{types.InstanceType(instanceType)},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("looking up %s: %w", instanceType, err)
+	}
+	if len(out.InstanceTypes) == 0 || out.InstanceTypes[0].VCpuInfo == nil || out.InstanceTypes[0].VCpuInfo.DefaultVCpus == nil {
+		return 0, fmt.Errorf("no vCPU count for %s", instanceType)
+	}
+	return int(*out.InstanceTypes[0].VCpuInfo.DefaultVCpus), nil
+}
@@ -0,0 +1,62 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+)
+
+// ReadPatches reads the source.patches files, hashing each so that editing a patch changes
+// the build settings' fingerprint. It reads them once, before the settings are fingerprinted.
+func ReadPatches(config *common.BuildConfig) error {
+	total := 0
+	for i := range config.Source.Patches {
+		patch := &config.Source.Patches[i]
+		if patch.File == "" || patch.Diff != "" {
+			total += len(patch.Diff)
+			continue
+		}
+		path, err := common.ExpandHome(patch.File)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading patch: %w", err)
+		}
+		if len(data) == 0 {
+			return fmt.Errorf("patch %s is empty", patch.File)
+		}
+		sum := sha256.Sum256(data)
+		patch.Diff = string(data)
+		patch.SHA256 = hex.EncodeToString(sum[:])
+		total += len(data)
+	}
+	if total > docker.MaxPatchBytes {
+		return fmt.Errorf("patch files total %d KiB, more than the %d KiB a build command can carry; push the change to a branch instead",
+			total>>10, docker.MaxPatchBytes>>10)
+	}
+	return nil
+}
+
+// UsePatches gives docker build configs the source's patches, read by ReadPatches
+func UsePatches(source common.SourceConfig, configs ...*docker.BuildConfig) {
+	repo := source.WithDefaults().Repo
+	patches := make([]docker.Patch, len(source.Patches))
+	for i, patch := range source.Patches {
+		patches[i] = docker.Patch{Dir: patch.Path, Diff: patch.Diff, Label: patch.Label(repo)}
+		if patch.PullRequest != "" {
+			name, number, _ := patch.PullRequestRef(repo)
+			patches[i].PullRequest = fmt.Sprintf("%s#%d", name, number)
+		}
+	}
+	for _, config := range configs {
+		if config != nil {
+			config.SourcePatches = patches
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// crossAZPerGB is the EC2 data transfer charge between AZs, billed in each direction (USD)
+const crossAZPerGB = 0.01
+
+// zoneConstraint is an AZ-scoped resource an instance uses
+type zoneConstraint struct {
+	resource string
+	zones    []string
+	required bool // The resource can't be used from another AZ at all
+}
+
+func (c zoneConstraint) allows(zone string) bool {
+	for _, z := range c.zones {
+		if z == zone {
+			return true
+		}
+	}
+	return false
+}
+
+// placeSubnets narrows the configured subnets to those in the AZs the configured storage
+// lives in. Storage reachable across AZs only warns about transfer charges when no
+// subnet is next to it; storage that can't be reached across AZs is an error.
+func (b *Builder) placeSubnets(ctx context.Context, config *common.BuildConfig, subnets []string) ([]string, error) {
+	if !config.Placement.IsSet() {
+		return subnets, nil
+	}
+
+	constraints, err := b.zoneConstraints(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	zones, err := b.subnetZones(ctx, subnets)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := subnets
+	for _, constraint := range constraints {
+		if !constraint.required {
+			continue
+		}
+		var next []string
+		for _, subnet := range allowed {
+			if constraint.allows(zones[subnet]) {
+				next = append(next, subnet)
+			}
+		}
+		if len(next) == 0 {
+			return nil, fmt.Errorf("no configured subnet is in %s, where %s is; add a subnet in that AZ to subnet_ids",
+				strings.Join(constraint.zones, " or "), constraint.resource)
+		}
+		allowed = next
+	}
+
+	preferred := allowed
+	for _, constraint := range constraints {
+		if constraint.required {
+			continue
+		}
+		var next []string
+		for _, subnet := range preferred {
+			if constraint.allows(zones[subnet]) {
+				next = append(next, subnet)
+			}
+		}
+		if len(next) == 0 {
+			fmt.Printf("⚠️  No usable subnet is in %s with %s; its traffic will cross AZs at $%.2f/GB each way\n",
+				strings.Join(constraint.zones, " or "), constraint.resource, crossAZPerGB)
+			continue
+		}
+		preferred = next
+	}
+
+	var placed []string
+	for _, subnet := range preferred {
+		placed = append(placed, zones[subnet])
+	}
+	var resources []string
+	for _, constraint := range constraints {
+		resources = append(resources, constraint.resource)
+	}
+	fmt.Printf("📍 Launching in %s to stay next to %s\n", strings.Join(uniqueSorted(placed), ", "), strings.Join(resources, ", "))
+	return preferred, nil
+}
+
+// zoneConstraints looks up the AZs of every configured AZ-scoped resource
+func (b *Builder) zoneConstraints(ctx context.Context, config *common.BuildConfig) ([]zoneConstraint, error) {
+	placement := config.Placement
+	cli := awscli.New(config.AWS.Profile, config.AWS.Region)
+	var constraints []zoneConstraint
+
+	if placement.AvailabilityZone != "" {
+		constraints = append(constraints, zoneConstraint{
+			resource: "placement.availability_zone",
+			zones:    []string{placement.AvailabilityZone},
+			required: true,
+		})
+	}
+
+	// A cluster placement group spans one AZ, fixed by the first instance launched into it
+	if placement.ClusterGroup != "" {
+		zone, err := b.clusterGroupZone(ctx, placement.ClusterGroup)
+		if err != nil {
+			return nil, err
+		}
+		if zone != "" {
+			constraints = append(constraints, zoneConstraint{
+				resource: "placement group " + placement.ClusterGroup,
+				zones:    []string{zone},
+				required: true,
+			})
+		}
+	}
+
+	// EBS volumes attach only to instances in their own AZ
+	if placement.CacheVolume != "" {
+		out, err := b.ec2Client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{VolumeIds: []string{placement.CacheVolume}})
+		if err != nil {
+			return nil, fmt.Errorf("looking up cache volume %s: %w", placement.CacheVolume, err)
+		}
+		if len(out.Volumes) == 0 {
+			return nil, fmt.Errorf("cache volume %s not found", placement.CacheVolume)
+		}
+		constraints = append(constraints, zoneConstraint{
+			resource: "EBS volume " + placement.CacheVolume,
+			zones:    []string{*out.Volumes[0].AvailabilityZone},
+			required: true,
+		})
+	}
+
+	// FSx for Lustre lives in one subnet and can be mounted across AZs, at a transfer charge
+	if placement.FSxFileSystem != "" {
+		var out struct {
+			FileSystems []struct {
+				SubnetIDs []string `json:"SubnetIds"`
+			} `json:"FileSystems"`
+		}
+		if err := cli.Run(ctx, &out, "fsx", "describe-file-systems", "--file-system-ids", placement.FSxFileSystem); err != nil {
+			return nil, fmt.Errorf("looking up FSx file system %s: %w", placement.FSxFileSystem, err)
+		}
+		if len(out.FileSystems) == 0 {
+			return nil, fmt.Errorf("FSx file system %s not found", placement.FSxFileSystem)
+		}
+		subnetZones, err := b.subnetZones(ctx, out.FileSystems[0].SubnetIDs)
+		if err != nil {
+			return nil, err
+		}
+		var zones []string
+		for _, zone := range subnetZones {
+			zones = append(zones, zone)
+		}
+		constraints = append(constraints, zoneConstraint{
+			resource: "FSx file system " + placement.FSxFileSystem,
+			zones:    uniqueSorted(zones),
+		})
+	}
+
+	// The EFS DNS name only resolves in AZs with a mount target
+	if placement.EFSFileSystem != "" {
+		var out struct {
+			MountTargets []struct {
+				AvailabilityZoneName string `json:"AvailabilityZoneName"`
+			} `json:"MountTargets"`
+		}
+		if err := cli.Run(ctx, &out, "efs", "describe-mount-targets", "--file-system-id", placement.EFSFileSystem); err != nil {
+			return nil, fmt.Errorf("looking up EFS mount targets of %s: %w", placement.EFSFileSystem, err)
+		}
+		var zones []string
+		for _, target := range out.MountTargets {
+			zones = append(zones, target.AvailabilityZoneName)
+		}
+		if len(zones) == 0 {
+			return nil, fmt.Errorf("EFS file system %s has no mount targets", placement.EFSFileSystem)
+		}
+		constraints = append(constraints, zoneConstraint{
+			resource: "EFS file system " + placement.EFSFileSystem,
+			zones:    uniqueSorted(zones),
+			required: true,
+		})
+	}
+
+	return constraints, nil
+}
+
+// subnetZones maps each subnet to its AZ
+func (b *Builder) subnetZones(ctx context.Context, subnets []string) (map[string]string, error) {
+	zones := make(map[string]string)
+	if len(subnets) == 0 {
+		return zones, nil
+	}
+	out, err := b.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{SubnetIds: subnets})
+	if err != nil {
+		return nil, fmt.Errorf("looking up subnets: %w", err)
+	}
+	for _, subnet := range out.Subnets {
+		zones[*subnet.SubnetId] = *subnet.AvailabilityZone
+	}
+	return zones, nil
+}
+
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,93 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// matrixProgress records each combination's outcome in the state store as it finishes, so
+// a matrix build stopped by an interrupt or an AWS error can resume with `build -resume`
+type matrixProgress struct {
+	builds *state.MatrixBuilds // nil when the state store can't be opened
+	scope  string
+	built  map[string]state.MatrixCombination // Combinations the resumed build finished
+}
+
+// startMatrixProgress begins recording a matrix build of the architectures. With resume, it
+// picks up the scope's last build when it used the same settings; otherwise it starts over.
+func (b *Builder) startMatrixProgress(config *common.BuildConfig, arches []string) (*matrixProgress, error) {
+	progress := &matrixProgress{
+		scope: fmt.Sprintf("%s/%s/%s", common.CurrentUser(), b.region, strings.Join(arches, ",")),
+		built: make(map[string]state.MatrixCombination),
+	}
+	store, err := state.OpenDefault()
+	if err != nil {
+		if config.Execution.Resume {
+			return nil, fmt.Errorf("reading matrix build progress: %w", err)
+		}
+		fmt.Printf("⚠️  Not recording build progress, so this build can't be resumed: %v\n", err)
+		return progress, nil
+	}
+	progress.builds = state.NewMatrixBuilds(store)
+
+	hash, err := matrixConfigHash(config)
+	if err != nil {
+		return nil, err
+	}
+	record := state.MatrixBuild{Scope: progress.scope, ConfigHash: hash}
+	if config.Execution.Resume {
+		last, err := progress.builds.Get(progress.scope)
+		switch {
+		case err != nil:
+			return nil, err
+		case last == nil:
+			fmt.Println("No earlier matrix build to resume; building every combination")
+		case last.ConfigHash != hash:
+			fmt.Println("⚠️  The build settings changed since the last matrix build; building every combination")
+		default:
+			record = *last
+			progress.built = last.Built()
+			fmt.Printf("⏭️  Resuming the matrix build from %s: %d combination(s) already built\n",
+				last.StartedAt.Local().Format("2006-01-02 15:04"), len(progress.built))
+		}
+	}
+	if err := progress.builds.Start(record); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// record stores a finished combination. Losing one only means rebuilding it on resume.
+func (p *matrixProgress) record(result MatrixResult) {
+	if p.builds == nil {
+		return
+	}
+	combination := state.MatrixCombination{Status: state.CombinationBuilt, Duration: result.Duration}
+	if result.Err != nil {
+		combination.Status = state.CombinationFailed
+		combination.Error = result.Err.Error()
+	}
+	if err := p.builds.Record(p.scope, result.Name(), combination); err != nil {
+		fmt.Printf("⚠️  Could not record %s: %v\n", result.Name(), err)
+	}
+}
+
+// matrixConfigHash fingerprints what a matrix build produces. How it runs (backend,
+// concurrency, keep-going) and the profile don't change the images, so they don't count.
+func matrixConfigHash(config *common.BuildConfig) (string, error) {
+	settings := *config
+	settings.Execution = common.ExecutionConfig{}
+	settings.AWS.Profile = ""
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return "", fmt.Errorf("fingerprinting build settings: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
@@ -0,0 +1,264 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// InstancePlatform identifies the operating system and CPU architecture of a running instance
+type InstancePlatform struct {
+	Distro       string // ID from /etc/os-release (rocky, almalinux, ubuntu, amzn)
+	Version      string // VERSION_ID from /etc/os-release
+	Architecture string // uname -m (x86_64, aarch64)
+}
+
+// ProvisionStep is a single command run while preparing an instance
+type ProvisionStep struct {
+	Description string
+	Command     string
+	Optional    bool // Failures are reported as warnings instead of aborting preparation
+}
+
+// ProvisioningProfile describes how to prepare an instance of a given platform for building
+type ProvisioningProfile struct {
+	Name          string
+	UpdateCommand string          // Full system update
+	RebootCheck   string          // Prints "1" when a reboot is required after updating
+	Steps         []ProvisionStep // Runtime, tooling, and build dependency installation
+	DockerAlias   string          // Makes the docker command available alongside podman
+}
+
+// commandHost is what provisioning needs from a build host, whatever the transport
+type commandHost interface {
+	ExecuteCommand(ctx context.Context, command string) (string, error)
+	ExecuteCommandStream(ctx context.Context, command string) error
+}
+
+// DetectPlatform reads the distro and architecture from the instance
+func (sb *SSHBuilder) DetectPlatform(ctx context.Context) (*InstancePlatform, error) {
+	return detectPlatform(ctx, sb)
+}
+
+// detectPlatform reads the distro and architecture from a host
+func detectPlatform(ctx context.Context, host commandHost) (*InstancePlatform, error) {
+	output, err := host.ExecuteCommand(ctx, `. /etc/os-release && echo "$ID $VERSION_ID $(uname -m)"`)
+	if err != nil {
+		return nil, fmt.Errorf("detecting instance platform: %w", err)
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("unexpected platform output: %q", output)
+	}
+
+	return &InstancePlatform{
+		Distro:       fields[0],
+		Version:      fields[1],
+		Architecture: fields[2],
+	}, nil
+}
+
+// String returns a short description of the platform
+func (p InstancePlatform) String() string {
+	return fmt.Sprintf("%s %s (%s)", p.Distro, p.Version, p.Architecture)
+}
+
+// awsCLIArch returns the architecture suffix used by AWS CLI v2 download URLs
+func (p InstancePlatform) awsCLIArch() string {
+	if p.Architecture == "aarch64" || p.Architecture == "arm64" {
+		return "aarch64"
+	}
+	return "x86_64"
+}
+
+// GetProvisioningProfile selects the provisioning profile for a platform
+func GetProvisioningProfile(platform InstancePlatform) (*ProvisioningProfile, error) {
+	switch platform.Distro {
+	case "rocky", "almalinux", "rhel":
+		return dnfProfile(platform), nil
+	case "amzn":
+		return amazonLinuxProfile(platform), nil
+	case "ubuntu", "debian":
+		return aptProfile(platform), nil
+	default:
+		return nil, fmt.Errorf("no provisioning profile for %s", platform)
+	}
+}
+
+// installAWSCLIStep installs AWS CLI v2 for the instance architecture (the distro package is too old)
+func installAWSCLIStep(platform InstancePlatform) ProvisionStep {
+	return ProvisionStep{
+		Description: "Installing AWS CLI 2.x",
+		Command: fmt.Sprintf("command -v aws >/dev/null && aws --version | grep -q aws-cli/2 || "+
+			"(curl -sS \"https://awscli.amazonaws.com/awscli-exe-linux-%s.zip\" -o \"awscliv2.zip\" && unzip -q awscliv2.zip && sudo ./aws/install && rm -rf aws awscliv2.zip) && aws --version",
+			platform.awsCLIArch()),
+	}
+}
+
+// dnfProfile prepares RHEL-family instances (Rocky Linux, AlmaLinux)
+func dnfProfile(platform InstancePlatform) *ProvisioningProfile {
+	return &ProvisioningProfile{
+		Name:          fmt.Sprintf("dnf-%s", platform.Architecture),
+		UpdateCommand: "sudo dnf clean all && sudo dnf update -y --allowerasing",
+		RebootCheck:   "sudo dnf needs-restarting -r >/dev/null 2>&1; echo $?",
+		Steps: []ProvisionStep{
+			{
+				// Rocky Linux 9 uses Podman with Docker compatibility
+				Description: "Installing container runtime",
+				Command:     "sudo dnf install -y podman git unzip tar && sudo systemctl enable --now podman.socket && sudo usermod -aG wheel $(whoami)",
+			},
+			installAWSCLIStep(platform),
+			{
+				Description: "Installing build tools",
+				Command:     "sudo dnf install -y make gcc gcc-gfortran",
+			},
+		},
+		DockerAlias: "sudo dnf install -y podman-docker",
+	}
+}
+
+// amazonLinuxProfile prepares Amazon Linux 2023, which ships Docker rather than Podman.
+// A podman shim keeps the rest of the pipeline runtime-agnostic.
+func amazonLinuxProfile(platform InstancePlatform) *ProvisioningProfile {
+	return &ProvisioningProfile{
+		Name:          fmt.Sprintf("al2023-%s", platform.Architecture),
+		UpdateCommand: "sudo dnf clean all && sudo dnf update -y",
+		RebootCheck:   "sudo dnf needs-restarting -r >/dev/null 2>&1; echo $?",
+		Steps: []ProvisionStep{
+			{
+				Description: "Installing container runtime",
+				Command: "sudo dnf install -y docker git unzip tar && sudo systemctl enable --now docker && sudo usermod -aG docker $(whoami) && " +
+					"printf '#!/bin/sh\\nexec sudo docker \"$@\"\\n' | sudo tee /usr/local/bin/podman >/dev/null && sudo chmod +x /usr/local/bin/podman",
+			},
+			installAWSCLIStep(platform),
+			{
+				Description: "Installing build tools",
+				Command:     "sudo dnf install -y make gcc gcc-gfortran",
+			},
+		},
+		DockerAlias: "true",
+	}
+}
+
+// aptProfile prepares Ubuntu/Debian instances
+func aptProfile(platform InstancePlatform) *ProvisioningProfile {
+	return &ProvisioningProfile{
+		Name:          fmt.Sprintf("apt-%s", platform.Architecture),
+		UpdateCommand: "sudo apt-get update -y && sudo DEBIAN_FRONTEND=noninteractive apt-get upgrade -y",
+		RebootCheck:   "test -f /var/run/reboot-required && echo 1 || echo 0",
+		Steps: []ProvisionStep{
+			{
+				Description: "Installing container runtime",
+				Command:     "sudo apt-get update -y && sudo DEBIAN_FRONTEND=noninteractive apt-get install -y podman git unzip tar curl",
+			},
+			installAWSCLIStep(platform),
+			{
+				Description: "Installing build tools",
+				Command:     "sudo DEBIAN_FRONTEND=noninteractive apt-get install -y make gcc gfortran",
+			},
+		},
+		DockerAlias: "sudo DEBIAN_FRONTEND=noninteractive apt-get install -y podman-docker",
+	}
+}
+
+// provisionHost prepares a host with the provisioning profile for its platform. After a kernel
+// update reboot it pauses for rebootDelay, then calls reconnect to wait for the host to come back.
+func provisionHost(ctx context.Context, host commandHost, skipUpdate bool, rebootDelay time.Duration, reconnect func(context.Context) error) (*InstancePlatform, error) {
+	fmt.Println("Preparing build instance...")
+
+	platform, err := detectPlatform(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := GetProvisioningProfile(*platform)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Detected %s, using provisioning profile %s\n", platform, profile.Name)
+
+	if !skipUpdate {
+		fmt.Println("Cleaning package cache and updating system packages...")
+		err := host.ExecuteCommandStream(ctx, profile.UpdateCommand)
+		if err != nil {
+			return nil, fmt.Errorf("updating packages: %w", err)
+		}
+
+		// Check if kernel was updated and reboot if necessary
+		fmt.Println("Checking if reboot is needed...")
+		needsReboot, err := host.ExecuteCommand(ctx, profile.RebootCheck)
+		if err != nil {
+			fmt.Printf("Warning: Could not check reboot status: %v\n", err)
+		} else if strings.TrimSpace(needsReboot) == "1" {
+			fmt.Println("Kernel update detected, rebooting instance...")
+			// Initiate reboot
+			_, err := host.ExecuteCommand(ctx, "sudo reboot")
+			if err != nil {
+				fmt.Printf("Warning: Reboot command failed: %v\n", err)
+			}
+
+			// Wait for reboot and reconnect
+			fmt.Println("Waiting for instance to reboot...")
+			select { // Wait for reboot to begin
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(rebootDelay):
+			}
+
+			if err := reconnect(ctx); err != nil {
+				return nil, err
+			}
+
+			fmt.Println("Successfully reconnected after reboot!")
+		}
+	} else {
+		fmt.Println("Skipping system package update for faster testing...")
+	}
+
+	for _, step := range profile.Steps {
+		fmt.Printf("%s...\n", step.Description)
+		if err := host.ExecuteCommandStream(ctx, step.Command); err != nil {
+			if step.Optional {
+				fmt.Printf("Warning: %s failed: %v\n", strings.ToLower(step.Description), err)
+				continue
+			}
+			return nil, fmt.Errorf("%s: %w", strings.ToLower(step.Description), err)
+		}
+	}
+
+	fmt.Println("Instance preparation completed!")
+	return platform, nil
+}
+
+// testContainerRuntime checks podman works on a prepared host and installs the docker alias
+// for its platform
+func testContainerRuntime(ctx context.Context, host commandHost, platform *InstancePlatform) error {
+	fmt.Println("Testing container runtime...")
+
+	if _, err := host.ExecuteCommand(ctx, "podman --version"); err != nil {
+		return fmt.Errorf("testing container runtime: %w", err)
+	}
+
+	// Enable Docker compatibility alias if not already set
+	fmt.Println("Setting up Docker compatibility alias...")
+	aliasCmd := "sudo dnf install -y podman-docker"
+	if platform != nil {
+		if profile, err := GetProvisioningProfile(*platform); err == nil {
+			aliasCmd = profile.DockerAlias
+		}
+	}
+	if err := host.ExecuteCommandStream(ctx, aliasCmd); err != nil {
+		fmt.Printf("Warning: Could not install docker alias: %v\n", err)
+	}
+
+	// Pull and run a small test image using podman
+	fmt.Println("Testing container pull and run...")
+	if err := host.ExecuteCommandStream(ctx, "podman run --rm hello-world"); err != nil {
+		return fmt.Errorf("testing container functionality: %w", err)
+	}
+
+	fmt.Println("Container runtime verified!")
+	return nil
+}
@@ -0,0 +1,109 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// PullThroughUpstream is a registry Dockerfiles pull from that ECR can cache
+type PullThroughUpstream struct {
+	Registry       string // Registry as written in image references (docker.io, ghcr.io)
+	URL            string // Upstream URL for the cache rule
+	Prefix         string // ECR repository prefix for the rule
+	Kind           ecrtypes.UpstreamRegistry
+	NeedsSecret    bool // ECR requires credentials in Secrets Manager for this upstream
+	SecretTemplate string
+}
+
+// PullThroughUpstreams are the upstreams setup-cache knows, keyed by registry
+var PullThroughUpstreams = map[string]PullThroughUpstream{
+	"docker.io": {
+		Registry: "docker.io", URL: "registry-1.docker.io", Prefix: "docker-hub",
+		Kind: ecrtypes.UpstreamRegistryDockerHub, NeedsSecret: true,
+		SecretTemplate: `{"username":"<docker hub user>","accessToken":"<access token>"}`,
+	},
+	"ghcr.io": {
+		Registry: "ghcr.io", URL: "ghcr.io", Prefix: "ghcr",
+		Kind: ecrtypes.UpstreamRegistryGitHubContainerRegistry, NeedsSecret: true,
+		SecretTemplate: `{"username":"<github user>","accessToken":"<token with read:packages>"}`,
+	},
+	"quay.io": {
+		Registry: "quay.io", URL: "quay.io", Prefix: "quay",
+		Kind: ecrtypes.UpstreamRegistryQuay,
+	},
+	"public.ecr.aws": {
+		Registry: "public.ecr.aws", URL: "public.ecr.aws", Prefix: "ecr-public",
+		Kind: ecrtypes.UpstreamRegistryEcrPublic,
+	},
+}
+
+// SetupPullThroughCache creates an ECR pull-through cache rule for each registry, reusing
+// rules that already exist, and returns the cache prefix for each registry as used by
+// cache.pull_through. secrets maps registries to Secrets Manager ARNs for upstreams that
+// need credentials; the secret names must start with ecr-pullthroughcache/.
+func (b *Builder) SetupPullThroughCache(ctx context.Context, registries []string, secrets map[string]string) (map[string]string, error) {
+	existing := make(map[string]ecrtypes.PullThroughCacheRule)
+	paginator := ecr.NewDescribePullThroughCacheRulesPaginator(b.ecrClient, &ecr.DescribePullThroughCacheRulesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing pull-through cache rules: %w", err)
+		}
+		for _, rule := range page.PullThroughCacheRules {
+			existing[aws.ToString(rule.EcrRepositoryPrefix)] = rule
+		}
+	}
+
+	caches := make(map[string]string)
+	for _, registry := range registries {
+		upstream, ok := PullThroughUpstreams[registry]
+		if !ok {
+			return nil, fmt.Errorf("no pull-through cache support for registry %s", registry)
+		}
+
+		if rule, ok := existing[upstream.Prefix]; ok {
+			if aws.ToString(rule.UpstreamRegistryUrl) != upstream.URL {
+				return nil, fmt.Errorf("ECR prefix %s already caches %s, not %s", upstream.Prefix, aws.ToString(rule.UpstreamRegistryUrl), upstream.URL)
+			}
+			fmt.Printf("♻️  Reusing pull-through cache rule %s -> %s\n", upstream.Prefix, upstream.URL)
+			caches[registry] = b.cachePrefix(aws.ToString(rule.RegistryId), upstream.Prefix)
+			continue
+		}
+
+		input := &ecr.CreatePullThroughCacheRuleInput{
+			EcrRepositoryPrefix: aws.String(upstream.Prefix),
+			UpstreamRegistryUrl: aws.String(upstream.URL),
+			UpstreamRegistry:    upstream.Kind,
+		}
+		if upstream.NeedsSecret {
+			secret := secrets[registry]
+			if secret == "" {
+				return nil, fmt.Errorf("%s needs credentials: store %s in a Secrets Manager secret named ecr-pullthroughcache/%s and pass its ARN",
+					registry, upstream.SecretTemplate, upstream.Prefix)
+			}
+			input.CredentialArn = aws.String(secret)
+		}
+
+		rule, err := b.ecrClient.CreatePullThroughCacheRule(ctx, input)
+		var exists *ecrtypes.PullThroughCacheRuleAlreadyExistsException
+		if errors.As(err, &exists) {
+			return nil, fmt.Errorf("a pull-through cache rule for %s was created concurrently; run setup-cache again", upstream.Prefix)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("creating pull-through cache rule for %s: %w", registry, err)
+		}
+		fmt.Printf("✅ Created pull-through cache rule %s -> %s\n", upstream.Prefix, upstream.URL)
+		caches[registry] = b.cachePrefix(aws.ToString(rule.RegistryId), upstream.Prefix)
+	}
+	return caches, nil
+}
+
+// cachePrefix returns the image reference prefix for a pull-through cache rule
+func (b *Builder) cachePrefix(registryID, prefix string) string {
+	return fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com/%s", registryID, b.region, prefix)
+}
@@ -0,0 +1,73 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Files written by the user-data script; they survive the reboot PrepareInstance may trigger
+const (
+	setupStateDir       = "/var/lib/geoschem-aws"
+	setupCompleteMarker = setupStateDir + "/setup-complete"
+	setupWarningsFile   = setupStateDir + "/setup-warnings"
+)
+
+// readinessCommand waits for cloud-init where available, then reports the user-data marker.
+// cloud-init exits non-zero for recoverable errors, so the marker is the source of truth.
+var readinessCommand = fmt.Sprintf(
+	"if command -v cloud-init >/dev/null 2>&1; then timeout 60 sudo cloud-init status --wait >/dev/null 2>&1; fi; "+
+		"if [ -f %s ]; then echo READY; cat %s 2>/dev/null | sed 's/^/WARNING /'; else echo PENDING; fi",
+	setupCompleteMarker, setupWarningsFile)
+
+// BootTimings records how long each stage of bringing up an instance took
+type BootTimings struct {
+	Running      time.Duration // Launch until EC2 reports running with a public IP
+	SSHReachable time.Duration // Running until SSH accepts connections
+	SetupDone    time.Duration // SSH reachable until user data finished
+}
+
+// Total returns the time from launch until the instance was ready for work
+func (t BootTimings) Total() time.Duration {
+	return t.Running + t.SSHReachable + t.SetupDone
+}
+
+// String summarizes the timings for logs
+func (t BootTimings) String() string {
+	return fmt.Sprintf("%s (running %s, SSH %s, user data %s)",
+		t.Total().Round(time.Second), t.Running.Round(time.Second),
+		t.SSHReachable.Round(time.Second), t.SetupDone.Round(time.Second))
+}
+
+// waitForSetup blocks until the user-data script has finished, so preparation doesn't
+// race package installs that are still running. timeout bounds how long user data may take.
+func waitForSetup(ctx context.Context, host commandHost, timeout time.Duration) error {
+	fmt.Println("Waiting for instance setup (user data) to finish...")
+
+	deadline := time.Now().Add(timeout)
+	for {
+		output, err := host.ExecuteCommand(ctx, readinessCommand)
+		if err == nil && strings.HasPrefix(strings.TrimSpace(output), "READY") {
+			for _, line := range strings.Split(output, "\n") {
+				if warning := strings.TrimPrefix(line, "WARNING "); warning != line {
+					fmt.Printf("Warning: user data step failed: %s\n", warning)
+				}
+			}
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("instance setup did not finish within %v: %w", timeout, err)
+			}
+			return fmt.Errorf("instance setup did not finish within %v", timeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
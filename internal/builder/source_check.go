@@ -0,0 +1,277 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+	"github.com/scttfrdmn/geoschem-aws/internal/geoschem"
+)
+
+// githubAPI is the GitHub REST API the Dockerfile lookup uses
+const githubAPI = "https://api.github.com"
+
+// SourceChecker checks a build's inputs from here, in seconds, rather than on a build host
+// after it's been provisioned: that the branch exists, that the Dockerfile is on it, that
+// the build args set every ARG the Dockerfile leaves without a default, and that the files
+// it and the dependencies Dockerfile copy are in the build context. It remembers its
+// lookups, so the combinations of a matrix make each once.
+type SourceChecker struct {
+	mu          sync.Mutex
+	branches    map[string]error  // ls-remote results, by repo@branch
+	dockerfiles map[string]string // Dockerfiles fetched from GitHub, by repo@branch:path; empty when unchecked
+	files       map[string]bool   // Whether files are on GitHub, by repo@branch:path
+}
+
+// NewSourceChecker creates a SourceChecker
+func NewSourceChecker() *SourceChecker {
+	return &SourceChecker{branches: make(map[string]error), dockerfiles: make(map[string]string), files: make(map[string]bool)}
+}
+
+// Check checks the source and Dockerfile one build uses. Lookups that can't be made from
+// here (git missing, a private repo without local credentials, GitHub rate limits) print a
+// warning and leave the check to the build host.
+func (c *SourceChecker) Check(ctx context.Context, source common.SourceConfig, dockerfile common.DockerfileConfig, buildConfig *geoschem.BuildConfiguration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	source = source.WithDefaults()
+	path := buildConfig.GetDockerfilePath()
+	var content string
+	if source.Archive != "" {
+		// CheckDockerfile found the uploaded tree's Dockerfile
+		if dockerfile.Content == "" {
+			data, err := os.ReadFile(filepath.Join(source.Local, filepath.FromSlash(path)))
+			if err != nil {
+				return fmt.Errorf("reading Dockerfile: %w", err)
+			}
+			content = string(data)
+		}
+	} else {
+		reachable, err := c.checkBranch(ctx, source)
+		if err != nil || !reachable {
+			return err
+		}
+		if dockerfile.Content == "" {
+			if content, err = c.fetchDockerfile(ctx, source, path); err != nil {
+				return err
+			}
+		}
+	}
+	if dockerfile.Content != "" {
+		content, path = dockerfile.Content, dockerfile.Local
+	}
+	if content == "" {
+		return nil
+	}
+
+	args := buildConfig.ToDockerBuildConfig(source.Repo, source.Branch, source.ImageTag).BuildArgs
+	if missing := docker.MissingBuildArgs(content, args); len(missing) > 0 {
+		return fmt.Errorf("%s declares ARG %s without a default, and %s doesn't set it; add it to build_args or give it a default",
+			path, strings.Join(missing, ", "), buildConfig.Name)
+	}
+	if source.Archive != "" {
+		// CheckDockerfile checked the uploaded tree's copies
+		return nil
+	}
+	if err := c.checkCopies(ctx, source, buildConfig.BuildContextDir(), path, content, args); err != nil {
+		return err
+	}
+	deps := buildConfig.ToDependenciesBuildConfig(source.Repo, source.Branch)
+	depsPath := deps.DockerfileDir + "/" + deps.Dockerfile
+	if depsContent, err := c.fetchDockerfile(ctx, source, depsPath); err == nil && depsContent != "" {
+		return c.checkCopies(ctx, source, deps.DockerfileDir, depsPath, depsContent, deps.BuildArgs)
+	}
+	return nil
+}
+
+// checkCopies looks up each file a Dockerfile on GitHub copies from its build context.
+// Wildcards, and files that can't be looked up from here, are left to the build host.
+func (c *SourceChecker) checkCopies(ctx context.Context, source common.SourceConfig, buildContext, path, content string, args map[string]string) error {
+	name, ok := common.GitHubRepo(source.Repo)
+	if !ok {
+		return nil
+	}
+	if os.Getenv("GITHUB_TOKEN") == "" && (source.TokenSecret != "" || source.DeployKeySecret != "") {
+		// GitHub answers 404 for private repos too
+		return nil
+	}
+	for _, file := range copySources(content, args) {
+		if strings.ContainsAny(file, "*?[") {
+			continue
+		}
+		file = strings.TrimPrefix(buildContext+"/"+strings.TrimPrefix(file, "./"), "./")
+		key := source.Repo + "@" + source.Branch + ":" + file
+		found, checked := c.files[key]
+		if !checked {
+			found, checked = lookUpFile(ctx, name, source.Branch, file)
+			if !checked {
+				continue
+			}
+			c.files[key] = found
+		}
+		if !found {
+			return fmt.Errorf("%s copies %s, which isn't on %s of %s", path, file, source.Branch, name)
+		}
+	}
+	return nil
+}
+
+// lookUpFile reports whether a file is on a branch of a GitHub repo, and whether GitHub
+// could say
+func lookUpFile(ctx context.Context, name, branch, path string) (found, checked bool) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	endpoint := fmt.Sprintf("%s/repos/%s/contents/%s?ref=%s", githubAPI, name, path, url.QueryEscape(branch))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, false
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, false
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, true
+	case http.StatusNotFound:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// checkBranch looks the branch up with git ls-remote, which clones take as a branch or tag.
+// It reports whether the repo could be reached from here.
+func (c *SourceChecker) checkBranch(ctx context.Context, source common.SourceConfig) (bool, error) {
+	key := source.Repo + "@" + source.Branch
+	if err, checked := c.branches[key]; checked {
+		if err == errUnreachable {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", "--exit-code", source.Repo,
+		"refs/heads/"+source.Branch, "refs/tags/"+source.Branch)
+	// Fail rather than prompt for credentials
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if os.Getenv("GIT_SSH_COMMAND") == "" {
+		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 2:
+		err = fmt.Errorf("%s has no branch or tag named %s", source.Repo, source.Branch)
+	case errors.Is(err, exec.ErrNotFound):
+		fmt.Printf("⚠️  git isn't installed here; the build host will find out whether %s has %s\n", source.Repo, source.Branch)
+		c.branches[key] = errUnreachable
+		return false, nil
+	case source.TokenSecret != "" || source.DeployKeySecret != "":
+		fmt.Printf("⚠️  Couldn't reach %s from here (%s); the build host clones it with its secret\n",
+			source.Repo, firstLine(stderr.String(), err))
+		c.branches[key] = errUnreachable
+		return false, nil
+	default:
+		err = fmt.Errorf("can't reach %s: %s", source.Repo, firstLine(stderr.String(), err))
+	}
+	c.branches[key] = err
+	return err == nil, err
+}
+
+// errUnreachable records a repo that couldn't be checked from here
+var errUnreachable = errors.New("unreachable from here")
+
+// fetchDockerfile gets the Dockerfile from the branch through the GitHub API, with
+// GITHUB_TOKEN when it's set. It returns "" when the repo isn't on GitHub or the lookup
+// can't be made.
+func (c *SourceChecker) fetchDockerfile(ctx context.Context, source common.SourceConfig, path string) (string, error) {
+	name, ok := common.GitHubRepo(source.Repo)
+	if !ok {
+		return "", nil
+	}
+	key := source.Repo + "@" + source.Branch + ":" + path
+	if content, fetched := c.dockerfiles[key]; fetched {
+		return content, nil
+	}
+	c.dockerfiles[key] = ""
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	endpoint := fmt.Sprintf("%s/repos/%s/contents/%s?ref=%s", githubAPI, name, path, url.QueryEscape(source.Branch))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		delete(c.dockerfiles, key)
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.raw")
+	token := os.Getenv("GITHUB_TOKEN")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Printf("⚠️  Couldn't look up %s on GitHub: %v\n", path, err)
+		return "", nil
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		fmt.Printf("⚠️  Couldn't read %s from GitHub: %v\n", path, err)
+		return "", nil
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK && bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")):
+		delete(c.dockerfiles, key)
+		return "", fmt.Errorf("Dockerfile %s is a directory on %s of %s", path, source.Branch, name)
+	case resp.StatusCode == http.StatusOK:
+		c.dockerfiles[key] = string(data)
+		return string(data), nil
+	case resp.StatusCode == http.StatusNotFound && token == "" && (source.TokenSecret != "" || source.DeployKeySecret != ""):
+		// GitHub answers 404 for private repos too
+		fmt.Printf("⚠️  Couldn't look up %s in private %s; set GITHUB_TOKEN to check it before launching\n", path, name)
+		return "", nil
+	case resp.StatusCode == http.StatusNotFound:
+		delete(c.dockerfiles, key)
+		return "", fmt.Errorf("Dockerfile %s isn't on %s of %s; set dockerfile.path to where it is", path, source.Branch, name)
+	default:
+		hint := ""
+		if token == "" && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) {
+			hint = "; set GITHUB_TOKEN for a higher rate limit"
+		}
+		fmt.Printf("⚠️  Couldn't look up %s on GitHub (%s)%s\n", path, resp.Status, hint)
+		return "", nil
+	}
+}
+
+// firstLine returns the first line of a command's stderr, or its error when it printed none
+func firstLine(stderr string, err error) string {
+	if line, _, _ := strings.Cut(strings.TrimSpace(stderr), "\n"); line != "" {
+		return line
+	}
+	return err.Error()
+}
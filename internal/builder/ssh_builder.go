@@ -3,23 +3,35 @@ package builder
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 
 	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
 	"github.com/scttfrdmn/geoschem-aws/internal/ssh"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
 )
 
+// connectKeyPrefix starts the names of local keys pushed with EC2 Instance Connect
+const connectKeyPrefix = "geoschem-connect-"
+
 type SSHBuilder struct {
 	*Builder
 	keyPairManager *ssh.KeyPairManager
+	keyStore       *ssh.KeyStore // Where generated private keys are kept (aws.key_storage)
 	sshClient      *ssh.Client
 	instanceID     string
+	publicIP       string
+	keyPath        string // Private key file, or the parameter or secret holding a stored key
+	ephemeralKey   string // Per-build key pair deleted on cleanup; empty when using a persistent key
+	connectKey     string // Local key name pushed with EC2 Instance Connect; empty when using key pairs
+	connectPublic  string
+	platform       *InstancePlatform
+	bootTimings    BootTimings
 }
 
 // NewSSHBuilder creates a new SSH-enabled builder
@@ -31,26 +43,50 @@ func NewSSHBuilder(cfg aws.Config) *SSHBuilder {
 	}
 }
 
-// BuildWithSSH launches an instance and establishes SSH connection for building
-func (sb *SSHBuilder) BuildWithSSH(ctx context.Context, config *common.BuildConfig, arch string) (string, error) {
-	// Setup key pair for SSH access
-	keyPairName := fmt.Sprintf("geoschem-builder-%s", arch)
-	privateKeyPath := filepath.Join(os.TempDir(), fmt.Sprintf("%s.pem", keyPairName))
+// BuildWithSSH launches an instance and establishes SSH connection for building.
+// If any step fails, resources it created are rolled back and no instance ID is returned.
+func (sb *SSHBuilder) BuildWithSSH(ctx context.Context, config *common.BuildConfig, arch string) (instanceID string, err error) {
+	tracker := NewResourceTracker(sb.region)
+	defer func() {
+		if err != nil {
+			if rollbackErr := tracker.Rollback(err.Error()); rollbackErr != nil {
+				fmt.Printf("Warning: rollback incomplete: %v\n", rollbackErr)
+			}
+			sb.instanceID = ""
+			sb.ephemeralKey = ""
+			sb.removeConnectKey()
+			instanceID = ""
+		}
+	}()
 
-	// Ensure key pair exists
-	err := sb.keyPairManager.GetOrCreateKeyPair(ctx, keyPairName, privateKeyPath)
+	hostOS, err := config.HostOS.Resolve()
 	if err != nil {
-		return "", fmt.Errorf("setting up key pair: %w", err)
+		return "", err
+	}
+
+	sb.keyStore, err = ssh.NewKeyStore(config.AWS)
+	if err != nil {
+		return "", err
+	}
+	sb.keyPairManager.SetKeyStore(sb.keyStore)
+	if err := sb.keyPairManager.SetKeyType(config.AWS.KeyTypeName()); err != nil {
+		return "", err
 	}
 
-	// Update config to use our key pair
-	config.AWS.KeyPair = keyPairName
+	privateKeyPath, err := sb.setupKeyPair(ctx, config, arch, tracker)
+	if err != nil {
+		return "", err
+	}
 
 	// Launch the build instance
-	instanceID, err := sb.launchBuildInstance(ctx, config, arch)
+	launchedAt := time.Now()
+	instanceID, err = sb.launchBuildInstance(ctx, config, arch)
 	if err != nil {
 		return "", fmt.Errorf("launching build instance: %w", err)
 	}
+	tracker.Track(state.ResourceInstance, instanceID, func(ctx context.Context) error {
+		return sb.terminateInstance(ctx, instanceID)
+	})
 
 	sb.instanceID = instanceID // Store for later use
 	fmt.Printf("Launched build instance: %s\n", instanceID)
@@ -61,21 +97,35 @@ func (sb *SSHBuilder) BuildWithSSH(ctx context.Context, config *common.BuildConf
 		return instanceID, fmt.Errorf("waiting for instance: %w", err)
 	}
 
+	sb.bootTimings.Running = time.Since(launchedAt)
+	sb.publicIP = publicIP
 	fmt.Printf("Instance ready with public IP: %s\n", publicIP)
 
 	// Setup SSH client
-	sb.sshClient, err = ssh.NewClient(publicIP, "rocky", privateKeyPath)
+	sb.sshClient, err = sb.keyStore.NewClient(ctx, publicIP, hostOS.SSHUser, privateKeyPath)
 	if err != nil {
 		return instanceID, fmt.Errorf("creating SSH client: %w", err)
 	}
+	if sb.connectKey != "" {
+		// Pushed keys expire after 60 seconds, so push before every attempt (including after reboots)
+		instanceConnect := ssh.NewInstanceConnect(config.AWS.Profile, sb.region)
+		publicKey := sb.connectPublic
+		sb.sshClient.SetBeforeConnect(func(ctx context.Context) error {
+			return instanceConnect.SendPublicKey(ctx, instanceID, hostOS.SSHUser, publicKey)
+		})
+	}
+
+	sb.sshClient.SetRetry(sb.waits.SSHAttempts, sb.waits.SSHInterval)
+	sb.sshClient.SetTransferLimits(config.Transfer)
 
 	// Wait for SSH to be available (instance needs to boot)
 	fmt.Println("Waiting for SSH connection...")
-	err = sb.sshClient.WaitForConnection(ctx, publicIP, 30) // 30 retries = ~5 minutes
+	err = sb.sshClient.WaitForConnection(ctx, publicIP)
 	if err != nil {
 		return instanceID, fmt.Errorf("establishing SSH connection: %w", err)
 	}
 
+	sb.bootTimings.SSHReachable = time.Since(launchedAt) - sb.bootTimings.Running
 	fmt.Println("SSH connection established!")
 
 	// Test SSH connection
@@ -85,17 +135,133 @@ func (sb *SSHBuilder) BuildWithSSH(ctx context.Context, config *common.BuildConf
 	}
 
 	fmt.Println("SSH connection verified!")
+
+	// SSH comes up before user data finishes; don't let preparation race it
+	if err := waitForSetup(ctx, sb, sb.waits.UserData); err != nil {
+		return instanceID, err
+	}
+	sb.bootTimings.SetupDone = time.Since(launchedAt) - sb.bootTimings.Running - sb.bootTimings.SSHReachable
+
+	fmt.Printf("⏱️  Time to ready: %s\n", sb.bootTimings)
+	tracker.Commit()
 	return instanceID, nil
 }
 
+// Launch implements BuildHost; the config is copied so the matrix config keeps its key pair
+func (sb *SSHBuilder) Launch(ctx context.Context, config *common.BuildConfig, arch string) (string, error) {
+	launchConfig := *config
+	withSpotFallback(&launchConfig, arch)
+	return sb.BuildWithSSH(ctx, &launchConfig, arch)
+}
+
+// Prepare implements BuildHost
+func (sb *SSHBuilder) Prepare(ctx context.Context, skipUpdate bool) error {
+	return sb.PrepareInstance(ctx, skipUpdate)
+}
+
+// Runner returns a runner that keeps container builds running across dropped connections
+func (sb *SSHBuilder) Runner() docker.CommandRunner {
+	return detachedRunner{sb.sshClient}
+}
+
+// detachedRunner runs streamed commands detached from the SSH connection
+type detachedRunner struct {
+	*ssh.Client
+}
+
+func (r detachedRunner) ExecuteCommandStream(ctx context.Context, command string, stdout, stderr io.Writer) error {
+	return r.ExecuteDetached(ctx, command, stdout)
+}
+
+// Cleanup terminates the launched instance, if any
+func (sb *SSHBuilder) Cleanup(ctx context.Context) error {
+	if sb.instanceID == "" {
+		return nil
+	}
+	return sb.CleanupInstance(ctx, sb.instanceID)
+}
+
+// setupKeyPair selects the configured persistent key, a local key for EC2 Instance Connect,
+// or creates a per-build key pair that is rolled back on failure and deleted on cleanup.
+// It sets config.AWS.KeyPair for launch.
+func (sb *SSHBuilder) setupKeyPair(ctx context.Context, config *common.BuildConfig, arch string, tracker *ResourceTracker) (string, error) {
+	if config.AWS.InstanceConnect {
+		hostOS, err := config.HostOS.Resolve()
+		if err != nil {
+			return "", err
+		}
+		if !hostOS.InstanceConnect {
+			return "", fmt.Errorf("EC2 Instance Connect needs a host OS with ec2-instance-connect installed (al2023, ubuntu22, ubuntu24), not %s", hostOS.Name)
+		}
+
+		keyName, err := ssh.UniqueKeyName(connectKeyPrefix + arch)
+		if err != nil {
+			return "", err
+		}
+		keyPair, keyPath, err := sb.keyStore.CreateLocalKey(ctx, keyName, config.AWS.KeyTypeName())
+		if err != nil {
+			return "", fmt.Errorf("setting up Instance Connect key: %w", err)
+		}
+		fmt.Println("Using EC2 Instance Connect (no key pair imported)")
+
+		config.AWS.KeyPair = ""
+		sb.connectKey = keyName
+		sb.connectPublic = keyPair.PublicKey
+		sb.keyPath = keyPath
+		return keyPath, nil
+	}
+
+	if config.AWS.KeyPair != "" && config.AWS.KeyFile != "" {
+		keyPath, err := common.ExpandHome(config.AWS.KeyFile)
+		if err != nil {
+			return "", fmt.Errorf("expanding key file path: %w", err)
+		}
+		if err := common.CheckPrivateKeyPermissions(keyPath); err != nil {
+			return "", fmt.Errorf("key file for key pair %s: %w", config.AWS.KeyPair, err)
+		}
+		fmt.Printf("Using persistent key pair: %s\n", config.AWS.KeyPair)
+		sb.keyPath = keyPath
+		return keyPath, nil
+	}
+
+	keyName, keyPath, err := sb.keyPairManager.CreateEphemeralKeyPair(ctx, fmt.Sprintf("geoschem-build-%s", arch))
+	if err != nil {
+		return "", fmt.Errorf("setting up key pair: %w", err)
+	}
+	tracker.Track(state.ResourceKeyPair, keyName, func(ctx context.Context) error {
+		return sb.keyPairManager.DeleteEphemeralKeyPair(ctx, keyName)
+	})
+	fmt.Printf("Created per-build key pair: %s\n", keyName)
+
+	config.AWS.KeyPair = keyName
+	sb.ephemeralKey = keyName
+	sb.keyPath = keyPath
+	return keyPath, nil
+}
+
+// KeyPath returns the private key used to reach the instance: a file, or for keys kept in
+// SSM or Secrets Manager a reference to fetch with ssh.FetchKeyCommand
+func (sb *SSHBuilder) KeyPath() string {
+	return sb.keyPath
+}
+
+// PublicIP returns the launched instance's public IP address
+func (sb *SSHBuilder) PublicIP() string {
+	return sb.publicIP
+}
+
+// BootTimings returns how long each stage of bringing up the instance took
+func (sb *SSHBuilder) BootTimings() BootTimings {
+	return sb.bootTimings
+}
+
 // waitForInstanceReady waits for instance to be running and returns public IP
 func (sb *SSHBuilder) waitForInstanceReady(ctx context.Context, instanceID string) (string, error) {
 	waiter := ec2.NewInstanceRunningWaiter(sb.ec2Client)
 	
-	// Wait for instance to be running (max 5 minutes)
 	err := waiter.Wait(ctx, &ec2.DescribeInstancesInput{
 		InstanceIds: []string{instanceID},
-	}, 5*time.Minute)
+	}, sb.waits.InstanceRunning)
 	if err != nil {
 		return "", fmt.Errorf("waiting for instance to be running: %w", err)
 	}
@@ -129,13 +295,14 @@ func (sb *SSHBuilder) ExecuteCommand(ctx context.Context, command string) (strin
 	return sb.sshClient.ExecuteCommand(ctx, command)
 }
 
-// ExecuteCommandStream runs a command and streams output in real-time
+// ExecuteCommandStream runs a command and streams output in real-time. The command runs
+// detached from the connection, so a dropped connection doesn't stop it.
 func (sb *SSHBuilder) ExecuteCommandStream(ctx context.Context, command string) error {
 	if sb.sshClient == nil {
 		return fmt.Errorf("SSH client not initialized")
 	}
 
-	return sb.sshClient.ExecuteCommandStream(ctx, command, os.Stdout, os.Stderr)
+	return sb.sshClient.ExecuteDetached(ctx, command, os.Stdout)
 }
 
 // UploadFile uploads a file to the build instance
@@ -147,105 +314,48 @@ func (sb *SSHBuilder) UploadFile(ctx context.Context, localPath, remotePath stri
 	return sb.sshClient.UploadFile(ctx, localPath, remotePath)
 }
 
-// PrepareInstance sets up the instance for building (install Docker, etc.)
-func (sb *SSHBuilder) PrepareInstance(ctx context.Context, skipUpdate bool) error {
-	fmt.Println("Preparing build instance...")
-
-	if !skipUpdate {
-		// Clean package cache and update system packages with conflict resolution
-		fmt.Println("Cleaning package cache and updating system packages...")
-		err := sb.ExecuteCommandStream(ctx, "sudo dnf clean all && sudo dnf update -y --allowerasing")
-		if err != nil {
-			return fmt.Errorf("updating packages: %w", err)
-		}
-
-		// Check if kernel was updated and reboot if necessary
-		fmt.Println("Checking if reboot is needed...")
-		needsReboot, err := sb.ExecuteCommand(ctx, "dnf needs-restarting -r; echo $?")
-		if err != nil {
-			fmt.Printf("Warning: Could not check reboot status: %v\n", err)
-		} else if strings.Contains(needsReboot, "1") {
-			fmt.Println("Kernel update detected, rebooting instance...")
-			// Initiate reboot
-			_, err := sb.ExecuteCommand(ctx, "sudo reboot")
-			if err != nil {
-				fmt.Printf("Warning: Reboot command failed: %v\n", err)
-			}
-			
-			// Wait for reboot and reconnect
-			fmt.Println("Waiting for instance to reboot...")
-			time.Sleep(30 * time.Second) // Wait for reboot to begin
-			
-			// Re-establish SSH connection
-			publicIP, err := sb.waitForInstanceReady(ctx, sb.instanceID)
-			if err != nil {
-				return fmt.Errorf("waiting for instance after reboot: %w", err)
-			}
-			
-			err = sb.sshClient.WaitForConnection(ctx, publicIP, 30)
-			if err != nil {
-				return fmt.Errorf("reconnecting SSH after reboot: %w", err)
-			}
-			
-			fmt.Println("Successfully reconnected after reboot!")
-		}
-	} else {
-		fmt.Println("Skipping system package update for faster testing...")
-	}
-
-	// Install Docker/Podman (Rocky Linux 9 uses Podman with Docker compatibility)
-	fmt.Println("Installing container runtime...")
-	containerInstall := "sudo dnf install -y podman git unzip && sudo systemctl enable --now podman.socket && sudo usermod -aG wheel rocky"
-	err := sb.ExecuteCommandStream(ctx, containerInstall)
-	if err != nil {
-		return fmt.Errorf("installing container runtime: %w", err)
+// UploadFiles uploads files to the build instance concurrently, within the transfer limits
+func (sb *SSHBuilder) UploadFiles(ctx context.Context, uploads []ssh.Upload) error {
+	if sb.sshClient == nil {
+		return fmt.Errorf("SSH client not initialized")
 	}
 
-	// Install AWS CLI 2.x (as requested by user - dnf version is old)
-	fmt.Println("Installing AWS CLI 2.x...")
-	awsInstall := "curl \"https://awscli.amazonaws.com/awscli-exe-linux-x86_64.zip\" -o \"awscliv2.zip\" && unzip awscliv2.zip && sudo ./aws/install && rm -rf aws awscliv2.zip && aws --version"
-	err = sb.ExecuteCommandStream(ctx, awsInstall)
-	if err != nil {
-		return fmt.Errorf("installing AWS CLI: %w", err)
-	}
+	return sb.sshClient.UploadFiles(ctx, uploads)
+}
 
-	// Install additional build tools
-	fmt.Println("Installing build tools...")
-	err = sb.ExecuteCommandStream(ctx, "sudo dnf install -y make gcc gcc-gfortran")
+// PrepareInstance sets up the instance for building using the provisioning profile for its platform
+func (sb *SSHBuilder) PrepareInstance(ctx context.Context, skipUpdate bool) error {
+	platform, err := provisionHost(ctx, sb, skipUpdate, sb.waits.RebootDelay, sb.reconnectAfterReboot)
 	if err != nil {
-		return fmt.Errorf("installing build tools: %w", err)
+		return err
 	}
-
-	fmt.Println("Instance preparation completed!")
+	sb.platform = platform
 	return nil
 }
 
-// TestDockerConnection verifies container runtime is working
-func (sb *SSHBuilder) TestDockerConnection(ctx context.Context) error {
-	fmt.Println("Testing container runtime...")
-	
-	// Test basic container command (Rocky Linux 9 uses Podman)
-	_, err := sb.ExecuteCommand(ctx, "podman --version")
+// reconnectAfterReboot waits for the instance to come back and re-establishes SSH
+func (sb *SSHBuilder) reconnectAfterReboot(ctx context.Context) error {
+	// Re-establish SSH connection
+	publicIP, err := sb.waitForInstanceReady(ctx, sb.instanceID)
 	if err != nil {
-		return fmt.Errorf("testing container runtime: %w", err)
+		return fmt.Errorf("waiting for instance after reboot: %w", err)
 	}
 
-	// Enable Docker compatibility alias if not already set
-	fmt.Println("Setting up Docker compatibility alias...")
-	err = sb.ExecuteCommandStream(ctx, "sudo dnf install -y podman-docker")
+	err = sb.sshClient.WaitForConnection(ctx, publicIP)
 	if err != nil {
-		fmt.Printf("Warning: Could not install docker alias: %v\n", err)
+		return fmt.Errorf("reconnecting SSH after reboot: %w", err)
 	}
+	return nil
+}
 
-	// Pull and run a small test image using podman
-	fmt.Println("Testing container pull and run...")
-	err = sb.ExecuteCommandStream(ctx, "podman run --rm hello-world")
-	if err != nil {
-		return fmt.Errorf("testing container functionality: %w", err)
-	}
+// Platform returns the platform detected during PrepareInstance, or nil before preparation
+func (sb *SSHBuilder) Platform() *InstancePlatform {
+	return sb.platform
+}
 
-	fmt.Println("Container runtime verified!")
-	return nil
+// TestDockerConnection verifies container runtime is working
+func (sb *SSHBuilder) TestDockerConnection(ctx context.Context) error {
+	return testContainerRuntime(ctx, sb, sb.platform)
 }
 
 // GetSSHClient returns the SSH client for direct use
@@ -253,32 +363,63 @@ func (sb *SSHBuilder) GetSSHClient() *ssh.Client {
 	return sb.sshClient
 }
 
-// CleanupInstance terminates the build instance
+// CleanupInstance terminates the build instance and deletes its per-build key pair
 func (sb *SSHBuilder) CleanupInstance(ctx context.Context, instanceID string) error {
 	if sb.sshClient != nil {
 		sb.sshClient.Close()
 	}
-
-	fmt.Printf("Terminating instance: %s\n", instanceID)
-	
-	input := &ec2.TerminateInstancesInput{
-		InstanceIds: []string{instanceID},
+	defer sb.deleteEphemeralKey(ctx)
+	defer sb.removeConnectKey()
+
+	if err := sb.terminateInstance(ctx, instanceID); err != nil {
+		recordLeftovers(state.ResourceRecord{
+			Kind:       state.ResourceInstance,
+			ID:         instanceID,
+			Region:     sb.region,
+			Reason:     err.Error(),
+			RecordedAt: time.Now(),
+		})
+		return err
 	}
+	return nil
+}
 
-	_, err := sb.ec2Client.TerminateInstances(ctx, input)
-	if err != nil {
-		return fmt.Errorf("terminating instance: %w", err)
+// StopInstance stops the instance instead of terminating it, keeping its volumes, and
+// deletes its per-build key pair; a later run on the instance would need a new key
+func (sb *SSHBuilder) StopInstance(ctx context.Context, instanceID string) error {
+	if sb.sshClient != nil {
+		sb.sshClient.Close()
 	}
+	defer sb.deleteEphemeralKey(ctx)
+	defer sb.removeConnectKey()
 
-	// Wait for termination to complete
-	waiter := ec2.NewInstanceTerminatedWaiter(sb.ec2Client)
-	err = waiter.Wait(ctx, &ec2.DescribeInstancesInput{
-		InstanceIds: []string{instanceID},
-	}, 5*time.Minute)
-	if err != nil {
-		return fmt.Errorf("waiting for instance termination: %w", err)
+	return sb.stopInstance(ctx, instanceID)
+}
+
+// deleteEphemeralKey removes the per-build key pair, recording it for the janitor on failure
+func (sb *SSHBuilder) deleteEphemeralKey(ctx context.Context) {
+	if sb.ephemeralKey == "" {
+		return
 	}
+	if err := sb.keyPairManager.DeleteEphemeralKeyPair(ctx, sb.ephemeralKey); err != nil {
+		recordLeftovers(state.ResourceRecord{
+			Kind:       state.ResourceKeyPair,
+			ID:         sb.ephemeralKey,
+			Region:     sb.region,
+			Reason:     err.Error(),
+			RecordedAt: time.Now(),
+		})
+	}
+	sb.ephemeralKey = ""
+}
 
-	fmt.Printf("Instance %s terminated successfully\n", instanceID)
-	return nil
-}
\ No newline at end of file
+// removeConnectKey deletes the local Instance Connect key; nothing was created in AWS
+func (sb *SSHBuilder) removeConnectKey() {
+	if sb.connectKey == "" {
+		return
+	}
+	if err := sb.keyStore.Remove(context.Background(), sb.connectKey); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	sb.connectKey = ""
+}
@@ -0,0 +1,145 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+	"github.com/scttfrdmn/geoschem-aws/internal/ssh"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// SSMHost drives a build instance with SSM Run Command, so no key pair or inbound SSH is
+// needed. The instance profile must include AmazonSSMManagedInstanceCore.
+type SSMHost struct {
+	*Builder
+	cfg        aws.Config
+	client     *ssh.SSMClient
+	instanceID string
+	platform   *InstancePlatform
+}
+
+// NewSSMHost creates an SSM-driven build host
+func NewSSMHost(cfg aws.Config) *SSMHost {
+	return &SSMHost{
+		Builder: NewFromConfig(cfg, cfg.Region),
+		cfg:     cfg,
+	}
+}
+
+// Launch starts an instance and waits until SSM can reach it and user data has finished.
+// A failed launch terminates the instance it started.
+func (h *SSMHost) Launch(ctx context.Context, config *common.BuildConfig, arch string) (instanceID string, err error) {
+	tracker := NewResourceTracker(h.region)
+	defer func() {
+		if err != nil {
+			if rollbackErr := tracker.Rollback(err.Error()); rollbackErr != nil {
+				fmt.Printf("Warning: rollback incomplete: %v\n", rollbackErr)
+			}
+			h.instanceID = ""
+			h.client = nil
+			instanceID = ""
+		}
+	}()
+
+	hostOS, err := config.HostOS.Resolve()
+	if err != nil {
+		return "", err
+	}
+
+	// SSM needs no key pair
+	launchConfig := *config
+	launchConfig.AWS.KeyPair = ""
+	withSpotFallback(&launchConfig, arch)
+
+	instanceID, err = h.launchBuildInstance(ctx, &launchConfig, arch)
+	if err != nil {
+		return "", fmt.Errorf("launching build instance: %w", err)
+	}
+	h.instanceID = instanceID
+	h.client = ssh.NewSSMClient(h.cfg, instanceID, hostOS.SSHUser)
+	tracker.Track(state.ResourceInstance, instanceID, func(ctx context.Context) error {
+		return h.terminateInstance(ctx, instanceID)
+	})
+
+	if err := h.waitForInstance(ctx, instanceID); err != nil {
+		return instanceID, fmt.Errorf("waiting for instance: %w", err)
+	}
+
+	if err := h.client.WaitForAgent(ctx, h.waits.SSMAgent); err != nil {
+		return instanceID, err
+	}
+
+	if err := waitForSetup(ctx, h, h.waits.UserData); err != nil {
+		return instanceID, err
+	}
+	tracker.Commit()
+	return instanceID, nil
+}
+
+// Prepare provisions the instance for building
+func (h *SSMHost) Prepare(ctx context.Context, skipUpdate bool) error {
+	platform, err := provisionHost(ctx, h, skipUpdate, h.waits.RebootDelay, func(ctx context.Context) error {
+		return h.client.WaitForAgent(ctx, h.waits.SSMAgent)
+	})
+	if err != nil {
+		return err
+	}
+	h.platform = platform
+	return nil
+}
+
+// Runner returns the command runner used for container builds
+func (h *SSMHost) Runner() docker.CommandRunner {
+	return h.client
+}
+
+// InstanceID returns the launched instance, or empty before launch
+func (h *SSMHost) InstanceID() string {
+	return h.instanceID
+}
+
+// TestDockerConnection verifies the container runtime on the prepared instance
+func (h *SSMHost) TestDockerConnection(ctx context.Context) error {
+	return testContainerRuntime(ctx, h, h.platform)
+}
+
+// Cleanup terminates the instance
+func (h *SSMHost) Cleanup(ctx context.Context) error {
+	if h.instanceID == "" {
+		return nil
+	}
+	if err := h.terminateInstance(ctx, h.instanceID); err != nil {
+		recordLeftovers(state.ResourceRecord{
+			Kind:       state.ResourceInstance,
+			ID:         h.instanceID,
+			Region:     h.region,
+			Reason:     err.Error(),
+			RecordedAt: time.Now(),
+		})
+		return err
+	}
+	return nil
+}
+
+// ExecuteCommand runs a command as the OS user and returns its output
+func (h *SSMHost) ExecuteCommand(ctx context.Context, command string) (string, error) {
+	if h.client == nil {
+		return "", fmt.Errorf("SSM host not launched")
+	}
+	return h.client.ExecuteCommand(ctx, command)
+}
+
+// ExecuteCommandStream runs a command and prints its output when it finishes;
+// SSM Run Command has no live output stream
+func (h *SSMHost) ExecuteCommandStream(ctx context.Context, command string) error {
+	if h.client == nil {
+		return fmt.Errorf("SSM host not launched")
+	}
+	return h.client.ExecuteCommandStream(ctx, command, os.Stdout, os.Stdout)
+}
@@ -0,0 +1,90 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// trackedResource is a created resource and how to undo it
+type trackedResource struct {
+	kind string
+	id   string
+	undo func(ctx context.Context) error
+}
+
+// ResourceTracker records resources as they are created so a failed operation can undo
+// them in reverse order. Anything that cannot be undone is written to the resource ledger
+// for the janitor.
+type ResourceTracker struct {
+	region    string
+	resources []trackedResource
+}
+
+// NewResourceTracker creates an empty tracker for resources in the region
+func NewResourceTracker(region string) *ResourceTracker {
+	return &ResourceTracker{region: region}
+}
+
+// Track registers a created resource with the function that removes it
+func (t *ResourceTracker) Track(kind, id string, undo func(ctx context.Context) error) {
+	t.resources = append(t.resources, trackedResource{kind: kind, id: id, undo: undo})
+}
+
+// Commit keeps every tracked resource; the caller now owns their cleanup
+func (t *ResourceTracker) Commit() {
+	t.resources = nil
+}
+
+// Rollback undoes tracked resources newest first. It runs on a fresh context so a
+// cancelled operation still cleans up, and records failures in the ledger.
+func (t *ResourceTracker) Rollback(reason string) error {
+	if len(t.resources) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	var errs []error
+	var leftovers []state.ResourceRecord
+	for i := len(t.resources) - 1; i >= 0; i-- {
+		resource := t.resources[i]
+		fmt.Printf("Rolling back %s %s\n", resource.kind, resource.id)
+		if err := resource.undo(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", resource.kind, resource.id, err))
+			leftovers = append(leftovers, state.ResourceRecord{
+				Kind:       resource.kind,
+				ID:         resource.id,
+				Region:     t.region,
+				Reason:     reason,
+				RecordedAt: time.Now(),
+			})
+		}
+	}
+	t.resources = nil
+
+	if len(leftovers) > 0 {
+		recordLeftovers(leftovers...)
+	}
+	return errors.Join(errs...)
+}
+
+// recordLeftovers writes resources that could not be cleaned up to the ledger. A ledger
+// failure is only reported, since the caller is already handling a cleanup failure.
+func recordLeftovers(records ...state.ResourceRecord) {
+	store, err := state.OpenDefault()
+	if err == nil {
+		err = state.NewResourceLedger(store).Record(records...)
+	}
+	if err != nil {
+		for _, record := range records {
+			fmt.Printf("⚠️  Could not record leftover %s %s (%v); remove it manually\n", record.Kind, record.ID, err)
+		}
+		return
+	}
+	fmt.Printf("⚠️  Recorded %d leftover resource(s); run 'geoschem-aws cleanup' to remove them\n", len(records))
+}
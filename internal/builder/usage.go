@@ -0,0 +1,239 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+	"github.com/scttfrdmn/geoschem-aws/internal/geoschem"
+)
+
+// ImageUsage is how much one matrix combination's image has been pulled and run
+type ImageUsage struct {
+	Architecture string
+	Compiler     string
+	MPI          string
+	Critical     bool
+	Image        string    // Main ECR reference
+	Pushed       bool      // The image is in ECR
+	PushedAt     time.Time // Latest push
+	LastPulled   time.Time // ECR's last recorded pull, updated about once a day; zero if never
+	SizeBytes    int64
+	Runs         int       // Recorded runs of the image since the window started
+	LastRun      time.Time // Date of the latest recorded run
+}
+
+// Name identifies the combination as arch/compiler/mpi
+func (u ImageUsage) Name() string {
+	return fmt.Sprintf("%s/%s/%s", u.Architecture, u.Compiler, u.MPI)
+}
+
+// Unused reports whether the image was neither pulled nor run since the window started.
+// Images pushed within the window haven't had the chance yet and don't count.
+func (u ImageUsage) Unused(since time.Time) bool {
+	if u.Pushed && u.PushedAt.After(since) {
+		return false
+	}
+	return u.LastPulled.Before(since) && u.Runs == 0
+}
+
+// ImageUsage reports the ECR pulls and recorded runs of every matrix combination's image
+// since the given time. Runs come from performance records, which every run appends.
+func (b *Builder) ImageUsage(ctx context.Context, config *common.BuildConfig, records []common.PerformanceRecord, since time.Time) ([]ImageUsage, error) {
+	arches := make([]string, 0, len(config.Architectures))
+	for arch := range config.Architectures {
+		arches = append(arches, arch)
+	}
+	sort.Strings(arches)
+
+	var usages []ImageUsage
+	for _, cell := range matrixCells(config, arches) {
+		images, err := matrixImages(config, cell)
+		if err != nil {
+			return nil, err
+		}
+		usage := ImageUsage{
+			Architecture: cell.arch,
+			Compiler:     cell.compiler,
+			MPI:          cell.mpi,
+			Critical:     config.Execution.IsCritical(cell.arch, cell.compiler, cell.mpi),
+			Image:        images[0],
+		}
+
+		detail, err := b.describeImage(ctx, images[0])
+		if err != nil {
+			return nil, err
+		}
+		if detail != nil {
+			usage.Pushed = true
+			usage.PushedAt = aws.ToTime(detail.ImagePushedAt)
+			usage.LastPulled = aws.ToTime(detail.LastRecordedPullTime)
+			usage.SizeBytes = aws.ToInt64(detail.ImageSizeInBytes)
+		}
+
+		tags := make(map[string]bool, len(images))
+		for _, image := range images {
+			tags[common.ImageConfigName(image)] = true
+		}
+		for _, record := range records {
+			recorded, err := time.Parse("2006-01-02", record.RecordedDate)
+			if err != nil || !tags[record.ImageConfig] || recorded.Before(since.Truncate(24*time.Hour)) {
+				continue
+			}
+			usage.Runs++
+			if recorded.After(usage.LastRun) {
+				usage.LastRun = recorded
+			}
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+// matrixImages returns the ECR references a combination is pushed as, main reference first
+func matrixImages(config *common.BuildConfig, cell matrixCell) ([]string, error) {
+	buildConfig, err := geoschem.FindBuildConfig(cell.arch, cell.compiler)
+	if err != nil {
+		return nil, err
+	}
+	buildConfig.MPI = cell.mpi
+
+	source := config.Source.WithDefaults()
+	dockerConfig := buildConfig.ToDockerBuildConfig(source.Repo, source.Branch, source.ImageTag)
+	dockerConfig.RepositoryStrategy = config.ECRStrategy
+	return docker.ECRImages(dockerConfig, config.ECRRepository), nil
+}
+
+// MatrixImage returns the ECR reference a matrix combination is pushed as
+func MatrixImage(config *common.BuildConfig, arch, compiler, mpi string) (string, error) {
+	images, err := matrixImages(config, matrixCell{arch: arch, compiler: compiler, mpi: mpi})
+	if err != nil {
+		return "", err
+	}
+	return images[0], nil
+}
+
+// MatrixManifest returns the multi-arch manifest list a compiler and MPI combination is
+// pushed as, and the images of each configured architecture that has the compiler
+func MatrixManifest(config *common.BuildConfig, compiler, mpi string) (string, []string, error) {
+	var archs []string
+	for arch := range config.Architectures {
+		archs = append(archs, arch)
+	}
+	sort.Strings(archs)
+
+	source := config.Source.WithDefaults()
+	var target string
+	var images []string
+	for _, arch := range archs {
+		buildConfig, err := geoschem.FindBuildConfig(arch, compiler)
+		if err != nil {
+			continue
+		}
+		buildConfig.MPI = mpi
+		dockerConfig := buildConfig.ToDockerBuildConfig(source.Repo, source.Branch, source.ImageTag)
+		dockerConfig.RepositoryStrategy = config.ECRStrategy
+
+		manifest := docker.ManifestImage(dockerConfig, config.ECRRepository)
+		if target != "" && manifest != target {
+			return "", nil, fmt.Errorf("%s images are named differently per architecture (%s, %s); give the target and images explicitly",
+				compiler, target, manifest)
+		}
+		target = manifest
+		images = append(images, docker.ECRImages(dockerConfig, config.ECRRepository)[0])
+	}
+	if len(images) == 0 {
+		return "", nil, fmt.Errorf("no architecture in the config builds %s", compiler)
+	}
+	return target, images, nil
+}
+
+// describeImage looks up an image reference in ECR, returning nil if it was never pushed
+func (b *Builder) describeImage(ctx context.Context, image string) (*ecrtypes.ImageDetail, error) {
+	slash := strings.Index(image, "/")
+	colon := strings.LastIndex(image, ":")
+	if slash < 0 || colon < slash {
+		return nil, fmt.Errorf("invalid ECR image reference: %s", image)
+	}
+
+	out, err := b.ecrClient.DescribeImages(ctx, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(image[slash+1 : colon]),
+		ImageIds:       []ecrtypes.ImageIdentifier{{ImageTag: aws.String(image[colon+1:])}},
+	})
+	var imageNotFound *ecrtypes.ImageNotFoundException
+	var repositoryNotFound *ecrtypes.RepositoryNotFoundException
+	if errors.As(err, &imageNotFound) || errors.As(err, &repositoryNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("describing %s: %w", image, err)
+	}
+	if len(out.ImageDetails) == 0 {
+		return nil, nil
+	}
+	return &out.ImageDetails[0], nil
+}
+
+// FormatImageUsage renders the usage of each combination and lists the ones neither pulled
+// nor run since the window started as candidates to drop from the default matrix
+func FormatImageUsage(usages []ImageUsage, since time.Time) string {
+	var b strings.Builder
+	days := int(time.Since(since).Hours() / 24)
+	fmt.Fprintf(&b, "\n📊 Image usage over the last %d days\n", days)
+	fmt.Fprintf(&b, "%-32s %-11s %-11s %5s %9s\n", "Combination", "Pushed", "Last pull", "Runs", "Size")
+	b.WriteString(strings.Repeat("-", 72) + "\n")
+
+	var unused []ImageUsage
+	for _, usage := range usages {
+		pushed, pulled := "never", "never"
+		if usage.Pushed {
+			pushed = usage.PushedAt.Local().Format("2006-01-02")
+		}
+		if !usage.LastPulled.IsZero() {
+			pulled = usage.LastPulled.Local().Format("2006-01-02")
+		}
+		name := usage.Name()
+		if usage.Critical {
+			name += " *"
+		}
+		fmt.Fprintf(&b, "%-32s %-11s %-11s %5d %9s\n", name, pushed, pulled, usage.Runs, formatImageSize(usage.SizeBytes))
+		if usage.Unused(since) {
+			unused = append(unused, usage)
+		}
+	}
+
+	if len(unused) == 0 {
+		b.WriteString("\n✅ Every combination was pulled or run\n")
+		return b.String()
+	}
+	var size int64
+	fmt.Fprintf(&b, "\n🗑️  Candidates to drop from the default matrix (no pulls or runs in %d days):\n", days)
+	for _, usage := range unused {
+		size += usage.SizeBytes
+		note := ""
+		if usage.Critical {
+			note = " (critical; remove it from execution.critical too)"
+		}
+		fmt.Fprintf(&b, "   %s%s\n", usage.Name(), note)
+	}
+	fmt.Fprintf(&b, "   Dropping them saves %d build(s) per matrix run and %s of ECR storage\n", len(unused), formatImageSize(size))
+	b.WriteString("\n* = critical\n")
+	return b.String()
+}
+
+// formatImageSize renders a compressed image size
+func formatImageSize(bytes int64) string {
+	if bytes <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f GB", float64(bytes)/1e9)
+}
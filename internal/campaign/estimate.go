@@ -0,0 +1,108 @@
+package campaign
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// Plan is a run with the instance type it will use and the predicted wall clock and cost
+type Plan struct {
+	Run        Run
+	Prediction common.Prediction
+}
+
+// Estimate predicts each run on the manifest's instance type, or on the instance type with
+// the lowest predicted cost when the manifest doesn't name one
+func Estimate(runs []Run, predictor *common.Predictor, instanceType string) ([]Plan, error) {
+	candidates := common.InstanceCatalog()
+	if instanceType != "" {
+		instance, err := common.LookupInstance(instanceType)
+		if err != nil {
+			return nil, err
+		}
+		candidates = []common.InstanceRecommendation{*instance}
+	}
+
+	plans := make([]Plan, 0, len(runs))
+	for _, run := range runs {
+		workload := common.WorkloadProfile{GridResolution: run.Config.Resolution, Architecture: "any"}
+		if workload.IsGCHP() {
+			return nil, fmt.Errorf("run %s: campaigns run GeosChem Classic; GCHP resolutions are not supported yet", run.ID)
+		}
+		modelDays, err := run.Config.ModelDays()
+		if err != nil {
+			return nil, fmt.Errorf("run %s: %w", run.ID, err)
+		}
+
+		predictions := predictor.Rank(common.PredictionRequest{
+			Simulation: run.Config.Simulation,
+			Workload:   workload,
+			ModelDays:  modelDays,
+		}, candidates)
+		if len(predictions) == 0 {
+			return nil, fmt.Errorf("run %s: no instance type can run %s %s", run.ID, run.Config.Simulation, workload.Description())
+		}
+
+		plan := Plan{Run: run, Prediction: predictions[0]}
+		plan.Run.Config.InstanceType = plan.Prediction.InstanceType
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// Totals returns the summed predicted cost and instance hours of the plans
+func Totals(plans []Plan) (cost float64, instanceHours float64) {
+	for _, plan := range plans {
+		cost += plan.Prediction.Cost
+		instanceHours += plan.Prediction.WallClock.Hours()
+	}
+	return cost, instanceHours
+}
+
+// FormatPlans renders the expanded runs with their estimates and the campaign totals
+func FormatPlans(plans []Plan, concurrency int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-40s %-14s %-12s %10s %9s\n", "RUN", "INSTANCE", "WALL CLOCK", "COST", "SAMPLES")
+	unbenchmarked := 0
+	for _, plan := range plans {
+		fmt.Fprintf(&b, "%-40s %-14s %-12s %10s %9d\n", plan.Run.ID, plan.Prediction.InstanceType,
+			common.FormatWallClock(plan.Prediction.WallClock), fmt.Sprintf("$%.2f", plan.Prediction.Cost), plan.Prediction.Samples)
+		if plan.Prediction.Samples == 0 {
+			unbenchmarked++
+		}
+	}
+
+	cost, instanceHours := Totals(plans)
+	fmt.Fprintf(&b, "\n📋 %d runs, %.0f instance hours\n", len(plans), instanceHours)
+	fmt.Fprintf(&b, "💰 Estimated cost: $%.2f\n", cost)
+	if concurrency > 0 {
+		fmt.Fprintf(&b, "⏱️  Estimated duration at %d runs at once: %s\n", concurrency, common.FormatWallClock(makespan(plans, concurrency)))
+	}
+	if unbenchmarked > 0 {
+		fmt.Fprintf(&b, "⚠️  %d runs have no benchmark data for their configuration; run 'benchmark compare' to improve the estimate\n", unbenchmarked)
+	}
+	return b.String()
+}
+
+// makespan estimates how long the plans take when up to concurrency run at once, started in order
+func makespan(plans []Plan, concurrency int) time.Duration {
+	slots := make([]time.Duration, concurrency)
+	for _, plan := range plans {
+		earliest := 0
+		for i := range slots {
+			if slots[i] < slots[earliest] {
+				earliest = i
+			}
+		}
+		slots[earliest] += plan.Prediction.WallClock
+	}
+
+	var longest time.Duration
+	for _, slot := range slots {
+		longest = max(longest, slot)
+	}
+	return longest
+}
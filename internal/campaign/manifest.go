@@ -0,0 +1,314 @@
+// Package campaign runs simulation campaigns: a manifest describes a sweep of GeosChem runs
+// over years, met years, emissions scenarios, resolutions and simulations, which is expanded into
+// individual runs, cost-estimated, run within a vCPU budget and tracked as a unit
+package campaign
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/benchmark"
+	"github.com/scttfrdmn/geoschem-aws/internal/data"
+	"github.com/scttfrdmn/geoschem-aws/internal/hemco"
+	"github.com/scttfrdmn/geoschem-aws/internal/runner"
+)
+
+// DefaultMaxConcurrent is how many runs a campaign runs at once when the manifest doesn't say
+const DefaultMaxConcurrent = 4
+
+// Manifest describes a campaign
+type Manifest struct {
+	Name          string      `yaml:"name"`           // Identifies the campaign; letters, digits and '-'
+	Image         string      `yaml:"image"`          // Container image every run uses
+	Output        string      `yaml:"output"`         // S3 prefix; each run writes to <output>/<run id>
+	InstanceType  string      `yaml:"instance_type"`  // Optional; default is the lowest predicted cost per run
+	MaxConcurrent int         `yaml:"max_concurrent"` // Runs at once (default 4)
+	MaxVCPUs      int         `yaml:"max_vcpus"`      // vCPUs the runs may use at once; 0 uses the free On-Demand quota
+	Chunk         string      `yaml:"chunk"`          // Split each run into year or month chunks chained by restart files
+	RetryOnOOM    bool        `yaml:"retry_on_oom"`   // Rerun a run on the next larger memory instance when it runs out of memory
+	Base          RunSettings `yaml:"base"`           // Settings every run starts from
+	Sweep         Sweep       `yaml:"sweep"`          // Values swept over; each run is one combination
+}
+
+// RunSettings are the settings of a single run
+type RunSettings struct {
+	Simulation string           `yaml:"simulation"`
+	Resolution string           `yaml:"resolution"`
+	StartDate  string           `yaml:"start_date"`  // YYYY-MM-DD
+	EndDate    string           `yaml:"end_date"`    // YYYY-MM-DD
+	MetField   string           `yaml:"met"`         // MERRA2, GEOSFP, GEOSIT
+	DataSource string           `yaml:"data_source"` // Optional S3 URI of input data
+	Emissions  []hemco.Override `yaml:"emissions"`   // Scale factors, inventories and masks merged into HEMCO_Config.rc
+}
+
+// Sweep lists the values a campaign varies. An empty list keeps the base setting.
+type Sweep struct {
+	Years       []int      `yaml:"years"`     // Shift the base period to start in each year
+	MetYears    []int      `yaml:"met_years"` // Like years, but emissions stay on the base year: a met-year ensemble
+	Simulations []string   `yaml:"simulations"`
+	Resolutions []string   `yaml:"resolutions"`
+	Scenarios   []Scenario `yaml:"scenarios"`
+}
+
+// Scenario is a named set of inputs, such as an emissions scenario
+type Scenario struct {
+	Name       string           `yaml:"name"`
+	DataSource string           `yaml:"data_source"` // Input data for the scenario; default is the base data source
+	MetField   string           `yaml:"met"`         // Optional met field override
+	Emissions  []hemco.Override `yaml:"emissions"`   // Applied after the base emissions overrides
+}
+
+// Run is one simulation of an expanded campaign
+type Run struct {
+	ID       string
+	Scenario string
+	Config   benchmark.Config
+}
+
+var campaignName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// LoadManifest reads and validates a campaign manifest
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading campaign manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing campaign manifest: %w", err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid campaign manifest %s: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+// Validate checks the manifest before anything is expanded
+func (m *Manifest) Validate() error {
+	if !campaignName.MatchString(m.Name) {
+		return fmt.Errorf("name %q must be lowercase letters, digits and '-'", m.Name)
+	}
+	if m.Image == "" {
+		return fmt.Errorf("image is required")
+	}
+	if !strings.HasPrefix(m.Output, "s3://") {
+		return fmt.Errorf("output must be an s3:// prefix, got %q", m.Output)
+	}
+	if m.MaxConcurrent < 0 || m.MaxVCPUs < 0 {
+		return fmt.Errorf("max_concurrent and max_vcpus cannot be negative")
+	}
+	if err := runner.ValidateChunk(m.Chunk); err != nil {
+		return err
+	}
+	if m.Base.StartDate == "" || m.Base.EndDate == "" {
+		return fmt.Errorf("base start_date and end_date are required")
+	}
+	if len(m.Sweep.Years) > 0 && len(m.Sweep.MetYears) > 0 {
+		return fmt.Errorf("sweep years and met_years cannot be combined")
+	}
+	if err := hemco.Validate(m.Base.Emissions); err != nil {
+		return fmt.Errorf("base emissions: %w", err)
+	}
+
+	scenarios := make(map[string]bool)
+	for _, scenario := range m.Sweep.Scenarios {
+		if !campaignName.MatchString(scenario.Name) {
+			return fmt.Errorf("scenario name %q must be lowercase letters, digits and '-'", scenario.Name)
+		}
+		if scenarios[scenario.Name] {
+			return fmt.Errorf("scenario %s is listed twice", scenario.Name)
+		}
+		scenarios[scenario.Name] = true
+		if scenario.DataSource != "" && !strings.HasPrefix(scenario.DataSource, "s3://") {
+			return fmt.Errorf("scenario %s data_source must be an s3:// URI", scenario.Name)
+		}
+		if err := hemco.Validate(scenario.Emissions); err != nil {
+			return fmt.Errorf("scenario %s emissions: %w", scenario.Name, err)
+		}
+	}
+	return nil
+}
+
+// CheckEmissions checks the files each scenario's emissions overrides read are in the
+// scenario's data source, before any run starts
+func (m *Manifest) CheckEmissions(ctx context.Context, cli *awscli.Client) error {
+	scenarios := m.Sweep.Scenarios
+	if len(scenarios) == 0 {
+		scenarios = []Scenario{{}}
+	}
+	for _, scenario := range scenarios {
+		overrides := scenarioEmissions(m.Base, scenario)
+		dataSource := firstNonEmpty(scenario.DataSource, m.Base.DataSource)
+		if len(overrides) == 0 || dataSource == "" {
+			continue
+		}
+		if err := hemco.CheckStaged(ctx, cli, dataSource, overrides); err != nil {
+			if scenario.Name != "" {
+				return fmt.Errorf("scenario %s: %w", scenario.Name, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// scenarioEmissions returns the base emissions overrides followed by the scenario's
+func scenarioEmissions(base RunSettings, scenario Scenario) []hemco.Override {
+	return append(slices.Clip(base.Emissions), scenario.Emissions...)
+}
+
+// Concurrency returns the number of runs to run at once
+func (m *Manifest) Concurrency() int {
+	if m.MaxConcurrent == 0 {
+		return DefaultMaxConcurrent
+	}
+	return m.MaxConcurrent
+}
+
+// Expand returns every combination of the swept values as a run, in a stable order
+func (m *Manifest) Expand() ([]Run, error) {
+	start, err := time.Parse("2006-01-02", m.Base.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing base start_date: %w", err)
+	}
+	end, err := time.Parse("2006-01-02", m.Base.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing base end_date: %w", err)
+	}
+
+	years := m.Sweep.Years
+	ensemble := len(m.Sweep.MetYears) > 0
+	if ensemble {
+		years = m.Sweep.MetYears
+	}
+	if len(years) == 0 {
+		years = []int{start.Year()}
+	}
+	simulations := orDefault(m.Sweep.Simulations, m.Base.Simulation, "fullchem")
+	resolutions := orDefault(m.Sweep.Resolutions, m.Base.Resolution, "4x5")
+	scenarios := m.Sweep.Scenarios
+	if len(scenarios) == 0 {
+		scenarios = []Scenario{{}}
+	}
+
+	var runs []Run
+	seen := make(map[string]bool)
+	for _, simulation := range simulations {
+		for _, resolution := range resolutions {
+			for _, scenario := range scenarios {
+				for _, year := range years {
+					shift := year - start.Year()
+					config := benchmark.Config{
+						Simulation:  simulation,
+						Resolution:  resolution,
+						StartDate:   start.AddDate(shift, 0, 0).Format("2006-01-02"),
+						EndDate:     end.AddDate(shift, 0, 0).Format("2006-01-02"),
+						DataSource:  firstNonEmpty(scenario.DataSource, m.Base.DataSource),
+						MetField:    firstNonEmpty(scenario.MetField, m.Base.MetField, benchmark.DefaultMetField),
+						Diagnostics: benchmark.DefaultDiagnostics,
+						Emissions:   scenarioEmissions(m.Base, scenario),
+					}
+					if _, err := config.ModelDays(); err != nil {
+						return nil, err
+					}
+					if ensemble {
+						if err := ensembleMember(&config, start.Year()); err != nil {
+							return nil, err
+						}
+					}
+
+					id := runID(config, scenario.Name)
+					if seen[id] {
+						return nil, fmt.Errorf("sweep produces run %s twice", id)
+					}
+					seen[id] = true
+					config.OutputURI = strings.TrimSuffix(m.Output, "/") + "/" + id
+					runs = append(runs, Run{ID: id, Scenario: scenario.Name, Config: config})
+				}
+			}
+		}
+	}
+	return runs, nil
+}
+
+// ensembleMember turns a run shifted to another met year into a member of a met-year
+// ensemble: emissions stay on the base year, and only the member's met fields are staged,
+// so members can share a data source holding every year
+func ensembleMember(config *benchmark.Config, emissionsYear int) error {
+	start, _ := time.Parse("2006-01-02", config.StartDate)
+	end, _ := time.Parse("2006-01-02", config.EndDate)
+	if _, err := data.CheckMetCoverage(config.MetField, start, end); err != nil {
+		return fmt.Errorf("met year %d: %w", start.Year(), err)
+	}
+	config.EmissionsYear = emissionsYear
+	config.StageMetYears = true
+	return nil
+}
+
+// runID names a run after what varies, e.g. fullchem-4x5-ssp245-20190101. Met-year
+// ensemble members are marked as such, e.g. fullchem-4x5-met20150101.
+func runID(config benchmark.Config, scenario string) string {
+	parts := []string{config.Simulation, config.Resolution}
+	if scenario != "" {
+		parts = append(parts, scenario)
+	}
+	date := strings.ReplaceAll(config.StartDate, "-", "")
+	if config.EmissionsYear != 0 {
+		date = "met" + date
+	}
+	parts = append(parts, date)
+	id := strings.ToLower(strings.Join(parts, "-"))
+	return strings.ReplaceAll(id, ".", "p")
+}
+
+// ParseYears parses a list of years such as "2010-2019" or "2010,2012,2015-2017"
+func ParseYears(spec string) ([]int, error) {
+	var years []int
+	for _, part := range strings.Split(spec, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid year %q in %q", first, spec)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil {
+				return nil, fmt.Errorf("invalid year %q in %q", last, spec)
+			}
+		}
+		if to < from {
+			return nil, fmt.Errorf("year range %s runs backwards", part)
+		}
+		for year := from; year <= to; year++ {
+			years = append(years, year)
+		}
+	}
+	return years, nil
+}
+
+// orDefault returns the swept values, or the base value, or the fallback
+func orDefault(values []string, base, fallback string) []string {
+	if len(values) > 0 {
+		return values
+	}
+	return []string{firstNonEmpty(base, fallback)}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package campaign
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// statusOrder is the order statuses are summarized in
+var statusOrder = []string{state.CampaignDone, state.CampaignRunning, state.CampaignFailed, state.CampaignPending}
+
+// Summary is one line of aggregate status, e.g. "12 runs: 8 done, 1 running, 3 pending"
+func Summary(record *state.CampaignRecord) string {
+	counts := record.Counts()
+	var parts []string
+	for _, status := range statusOrder {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	return fmt.Sprintf("%d runs: %s", len(record.Runs), strings.Join(parts, ", "))
+}
+
+// Status renders every run of the campaign with its progress
+func Status(record *state.CampaignRecord) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📋 Campaign %s (%s)\n   %s\n\n", record.Name, record.Image, Summary(record))
+	fmt.Fprintf(&b, "%-40s %-8s %-14s %-12s %10s\n", "RUN", "STATUS", "INSTANCE", "WALL CLOCK", "COST")
+	for _, run := range record.Runs {
+		wallClock, cost := "-", "-"
+		switch run.Status {
+		case state.CampaignDone, state.CampaignFailed:
+			wallClock = common.FormatWallClock(hours(run.Hours))
+			cost = fmt.Sprintf("$%.2f", run.Cost)
+		case state.CampaignRunning:
+			wallClock = common.FormatWallClock(time.Since(run.StartedAt)) + "+"
+		}
+		fmt.Fprintf(&b, "%-40s %-8s %-14s %-12s %10s\n", run.ID, run.Status, run.InstanceType, wallClock, cost)
+	}
+	return b.String()
+}
+
+// Report is the campaign roll-up: progress, actual against estimated cost and instance
+// hours overall and per scenario, failures, and where the output is
+func Report(record *state.CampaignRecord) string {
+	type totals struct {
+		runs, done                   int
+		cost, estimatedCost          float64
+		instanceHours, estimateHours float64
+	}
+	var all totals
+	byScenario := make(map[string]*totals)
+	var failures []state.CampaignRun
+
+	for _, run := range record.Runs {
+		scenario := run.Scenario
+		if scenario == "" {
+			scenario = "(base)"
+		}
+		if byScenario[scenario] == nil {
+			byScenario[scenario] = &totals{}
+		}
+		for _, t := range []*totals{&all, byScenario[scenario]} {
+			t.runs++
+			t.estimatedCost += run.EstimatedCost
+			t.estimateHours += run.EstimatedHours
+			t.cost += run.Cost
+			t.instanceHours += run.Hours
+			if run.Status == state.CampaignDone {
+				t.done++
+			}
+		}
+		if run.Status == state.CampaignFailed {
+			failures = append(failures, run)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 Campaign %s\n", record.Name)
+	fmt.Fprintf(&b, "   Image: %s\n", record.Image)
+	if record.Scheduler != "" {
+		fmt.Fprintf(&b, "   Scheduler: %s\n", record.Scheduler)
+	}
+	fmt.Fprintf(&b, "   Started: %s, last update: %s\n", record.CreatedAt.Local().Format("2006-01-02 15:04"),
+		record.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "   %s\n", Summary(record))
+	if all.done == all.runs {
+		b.WriteString("   ✅ Complete\n")
+	}
+
+	fmt.Fprintf(&b, "\n💰 Cost: $%.2f of $%.2f estimated\n", all.cost, all.estimatedCost)
+	fmt.Fprintf(&b, "⏱️  Instance hours: %.1f of %.1f estimated\n", all.instanceHours, all.estimateHours)
+
+	if len(byScenario) > 1 {
+		scenarios := make([]string, 0, len(byScenario))
+		for scenario := range byScenario {
+			scenarios = append(scenarios, scenario)
+		}
+		sort.Strings(scenarios)
+
+		fmt.Fprintf(&b, "\n%-24s %8s %10s %12s %10s\n", "SCENARIO", "DONE", "COST", "ESTIMATED", "HOURS")
+		for _, scenario := range scenarios {
+			t := byScenario[scenario]
+			fmt.Fprintf(&b, "%-24s %8s %10s %12s %10.1f\n", scenario, fmt.Sprintf("%d/%d", t.done, t.runs),
+				fmt.Sprintf("$%.2f", t.cost), fmt.Sprintf("$%.2f", t.estimatedCost), t.instanceHours)
+		}
+	}
+
+	if len(failures) > 0 {
+		fmt.Fprintf(&b, "\n❌ Failed runs (run the campaign again to retry them):\n")
+		for _, run := range failures {
+			fmt.Fprintf(&b, "   %s: %s\n", run.ID, run.Error)
+		}
+	}
+
+	if all.done > 0 {
+		fmt.Fprintf(&b, "\n📦 Output:\n")
+		for _, run := range record.Runs {
+			if run.Status == state.CampaignDone {
+				fmt.Fprintf(&b, "   %s\n", run.OutputURI)
+			}
+		}
+	}
+	return b.String()
+}
+
+// hours converts fractional hours to a duration
+func hours(h float64) time.Duration {
+	return time.Duration(h * float64(time.Hour))
+}
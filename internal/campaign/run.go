@@ -0,0 +1,219 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/benchmark"
+	"github.com/scttfrdmn/geoschem-aws/internal/catalog"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/runflow"
+	"github.com/scttfrdmn/geoschem-aws/internal/runner"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// minTimeLimit is the shortest wall-clock limit a run gets, however short its prediction
+const minTimeLimit = 2 * time.Hour
+
+// FitConcurrency caps the runs at once so they fit in a vCPU budget, sizing every slot for
+// the largest run's instance. A budget of 0 leaves the concurrency unchanged. It fails when
+// not even one run fits.
+func FitConcurrency(plans []Plan, concurrency, vcpuBudget int) (int, error) {
+	concurrency = min(concurrency, max(len(plans), 1))
+	if vcpuBudget <= 0 {
+		return concurrency, nil
+	}
+
+	largest := 0
+	for _, plan := range plans {
+		instance, err := common.LookupInstance(plan.Prediction.InstanceType)
+		if err != nil {
+			return 0, err
+		}
+		largest = max(largest, instance.VCPUs)
+	}
+	if largest == 0 {
+		return concurrency, nil
+	}
+
+	fits := vcpuBudget / largest
+	if fits < 1 {
+		return 0, fmt.Errorf("a %d-vCPU run doesn't fit in the %d vCPUs available to the campaign", largest, vcpuBudget)
+	}
+	if fits < concurrency {
+		fmt.Printf("⚠️  %d runs at once would need %d vCPUs, but only %d are available; running %d at a time\n",
+			concurrency, concurrency*largest, vcpuBudget, fits)
+		return fits, nil
+	}
+	return concurrency, nil
+}
+
+// Track records the campaign's runs in the state store. Runs of an earlier attempt keep
+// their progress, so only runs that aren't done yet run again; runs no longer in the
+// manifest are dropped.
+func Track(campaigns *state.Campaigns, manifestPath string, manifest *Manifest, plans []Plan, scheduler string) (*state.CampaignRecord, error) {
+	previous, err := campaigns.Get(manifest.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	record := state.CampaignRecord{
+		Name:      manifest.Name,
+		Manifest:  manifestPath,
+		Image:     manifest.Image,
+		Scheduler: scheduler,
+		Owner:     common.CurrentUser(),
+	}
+	if previous != nil {
+		if previous.Image != manifest.Image {
+			fmt.Printf("⚠️  Campaign %s previously ran %s; runs already done keep that image's output\n", manifest.Name, previous.Image)
+		}
+		record.CreatedAt = previous.CreatedAt
+	}
+
+	for _, plan := range plans {
+		if previous != nil {
+			if run := previous.Run(plan.Run.ID); run != nil && run.Status == state.CampaignDone {
+				record.Runs = append(record.Runs, *run)
+				continue
+			}
+		}
+		record.Runs = append(record.Runs, state.CampaignRun{
+			ID:             plan.Run.ID,
+			Simulation:     plan.Run.Config.Simulation,
+			Resolution:     plan.Run.Config.Resolution,
+			Scenario:       plan.Run.Scenario,
+			StartDate:      plan.Run.Config.StartDate,
+			EndDate:        plan.Run.Config.EndDate,
+			InstanceType:   plan.Prediction.InstanceType,
+			OutputURI:      plan.Run.Config.OutputURI,
+			Status:         state.CampaignPending,
+			EstimatedCost:  plan.Prediction.Cost,
+			EstimatedHours: plan.Prediction.WallClock.Hours(),
+		})
+	}
+
+	if err := campaigns.Save(record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Execute runs the campaign's unfinished runs, up to concurrency at once, through runflow:
+// each run is chunked and retried on OOM as the manifest says, and a finished run's
+// provenance, final restart, performance and, when results is given, catalog entry are
+// recorded. An interrupt starts no new runs and returns interrupted runs to pending, so
+// running the campaign again picks up where it stopped. It returns an error when any run failed.
+func Execute(ctx context.Context, store *state.Store, scheduler runner.Scheduler, record *state.CampaignRecord, plans []Plan, manifest *Manifest, concurrency int, results *catalog.Catalog, aws common.AWSConfig) error {
+	campaigns := state.NewCampaigns(store)
+
+	var pending []Plan
+	for _, plan := range plans {
+		if run := record.Run(plan.Run.ID); run != nil && run.Status != state.CampaignDone {
+			pending = append(pending, plan)
+		}
+	}
+	if len(pending) == 0 {
+		fmt.Printf("✅ All %d runs of %s are done\n", len(record.Runs), record.Name)
+		return nil
+	}
+	fmt.Printf("🚀 Running %d of %d runs of %s via %s, %d at a time\n",
+		len(pending), len(record.Runs), record.Name, scheduler.Name(), concurrency)
+
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+
+	for _, plan := range pending {
+		slots <- struct{}{}
+		if ctx.Err() != nil {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(plan Plan) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			run := *record.Run(plan.Run.ID)
+			run.Status = state.CampaignRunning
+			run.InstanceType = plan.Prediction.InstanceType
+			run.Error = ""
+			run.StartedAt = time.Now().UTC()
+			run.FinishedAt = time.Time{}
+			if err := campaigns.UpdateRun(record.Name, run); err != nil {
+				fmt.Printf("Warning: could not record %s starting: %v\n", run.ID, err)
+			}
+
+			fmt.Printf("▶️  %s on %s\n", run.ID, run.InstanceType)
+			flow := &runflow.Run{
+				Job: runner.Job{
+					Name:      jobName(record.Name, run.ID),
+					Image:     manifest.Image,
+					Config:    plan.Run.Config,
+					TimeLimit: max(2*plan.Prediction.WallClock, minTimeLimit),
+				},
+				Chunk:       manifest.Chunk,
+				RetryOnOOM:  manifest.RetryOnOOM,
+				ConfigFiles: map[string]string{"campaign_manifest": record.Manifest},
+				Profile:     aws.Profile,
+				Region:      aws.Region,
+			}
+			result := runflow.Execute(ctx, store, scheduler, flow)
+
+			// An OOM retry moves the run to a larger instance
+			run.InstanceType = flow.Job.Config.InstanceType
+			run.Cost = result.Cost
+			run.Hours = result.WallClock.Hours()
+			run.FinishedAt = time.Now().UTC()
+			switch {
+			case result.Err != nil && ctx.Err() != nil:
+				run.Status = state.CampaignPending
+				run.Error = "interrupted"
+				fmt.Printf("⏸️  %s interrupted\n", run.ID)
+			case errors.Is(result.Err, benchmark.ErrPaused):
+				run.Status = state.CampaignPending
+				run.Error = "paused; the campaign reruns it from the start"
+				fmt.Printf("⏸️  %s paused\n", run.ID)
+			case result.Err != nil:
+				run.Status = state.CampaignFailed
+				run.Error = result.Err.Error()
+				fmt.Printf("❌ %s failed: %v\n", run.ID, result.Err)
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			default:
+				run.Status = state.CampaignDone
+				fmt.Printf("✅ %s done in %s ($%.2f)\n", run.ID, common.FormatWallClock(result.WallClock), result.Cost)
+				runflow.Record(ctx, store, flow, scheduler.Name(), result, results)
+			}
+			if err := campaigns.UpdateRun(record.Name, run); err != nil {
+				fmt.Printf("Warning: could not record %s finishing: %v\n", run.ID, err)
+			}
+		}(plan)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("campaign %s interrupted: %w", record.Name, ctx.Err())
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d runs failed", failed, len(pending))
+	}
+	return nil
+}
+
+// jobName names a campaign run for schedulers, which allow letters, digits, '-' and '_'
+// and at most 128 characters
+func jobName(campaign, runID string) string {
+	name := "geoschem-" + campaign + "-" + runID
+	if len(name) > 128 {
+		name = name[:128]
+	}
+	return name
+}
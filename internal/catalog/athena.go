@@ -0,0 +1,217 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/artifacts"
+)
+
+// DefaultDatabase is the Glue database holding the run tables
+const DefaultDatabase = "geoschem"
+
+// TabulateCommand is the analysis image's command that reduces output to diagnostic rows
+const TabulateCommand = "geoschem-tabulate-output"
+
+// Lake publishes catalog entries and their tabular diagnostics as JSON lines under an S3
+// location and registers Glue tables over them, so Athena can query every run at once
+type Lake struct {
+	catalog  *Catalog
+	database string
+	location string // s3:// prefix holding runs/ and diagnostics/
+}
+
+// NewLake creates a lake under location, writing through the catalog's AWS profile and region
+func NewLake(c *Catalog, database, location string) (*Lake, error) {
+	if _, _, err := splitS3URI(location); err != nil {
+		return nil, fmt.Errorf("invalid lake location: %w", err)
+	}
+	if database == "" {
+		database = DefaultDatabase
+	}
+	return &Lake{catalog: c, database: database, location: strings.TrimSuffix(location, "/")}, nil
+}
+
+// Database returns the Glue database name
+func (l *Lake) Database() string {
+	return l.database
+}
+
+// glueColumn is a column in a Glue table definition
+type glueColumn struct {
+	Name string `json:"Name"`
+	Type string `json:"Type"`
+}
+
+// runColumns match the keys of runRow
+var runColumns = []glueColumn{
+	{"id", "string"}, {"config_hash", "string"}, {"image", "string"}, {"image_digest", "string"},
+	{"simulation", "string"}, {"resolution", "string"}, {"met_field", "string"},
+	{"start_date", "date"}, {"end_date", "date"}, {"output_uri", "string"},
+	{"size_bytes", "bigint"}, {"objects", "int"}, {"cost", "double"},
+	{"instance_type", "string"}, {"scheduler", "string"}, {"user", "string"},
+	{"created_at", "string"}, {"indexed", "boolean"},
+	{"provenance_id", "string"}, {"run_config_hash", "string"},
+}
+
+// diagnosticColumns match the rows written by the analysis image's TabulateCommand
+var diagnosticColumns = []glueColumn{
+	{"run_id", "string"}, {"kind", "string"}, {"collection", "string"}, {"variable", "string"},
+	{"time", "string"}, {"value", "double"}, {"units", "string"},
+}
+
+// runRow is one line of the runs table
+type runRow struct {
+	ID            string  `json:"id"`
+	ConfigHash    string  `json:"config_hash"`
+	Image         string  `json:"image"`
+	ImageDigest   string  `json:"image_digest"`
+	Simulation    string  `json:"simulation"`
+	Resolution    string  `json:"resolution"`
+	MetField      string  `json:"met_field"`
+	StartDate     string  `json:"start_date"`
+	EndDate       string  `json:"end_date"`
+	OutputURI     string  `json:"output_uri"`
+	SizeBytes     int64   `json:"size_bytes"`
+	Objects       int     `json:"objects"`
+	Cost          float64 `json:"cost"`
+	InstanceType  string  `json:"instance_type"`
+	Scheduler     string  `json:"scheduler"`
+	User          string  `json:"user"`
+	CreatedAt     string  `json:"created_at"`
+	Indexed       bool    `json:"indexed"`
+	ProvenanceID  string  `json:"provenance_id"`
+	RunConfigHash string  `json:"run_config_hash"`
+}
+
+// Setup creates the database and the runs and diagnostics tables. Existing ones are kept.
+func (l *Lake) Setup(ctx context.Context) error {
+	err := l.catalog.cli.Run(ctx, nil, "glue", "create-database",
+		"--database-input", fmt.Sprintf(`{"Name": %q, "Description": "GeosChem runs and diagnostics"}`, l.database))
+	if err != nil && !strings.Contains(err.Error(), "AlreadyExistsException") {
+		return fmt.Errorf("creating Glue database %s: %w", l.database, err)
+	}
+
+	tables := []struct {
+		name        string
+		description string
+		columns     []glueColumn
+	}{
+		{"runs", "One row per recorded simulation output", runColumns},
+		{"diagnostics", "Timeseries and budget rows reduced from simulation output", diagnosticColumns},
+	}
+	for _, table := range tables {
+		if err := l.createTable(ctx, table.name, table.description, table.columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTable registers a JSON lines table over location/<name>/
+func (l *Lake) createTable(ctx context.Context, name, description string, columns []glueColumn) error {
+	input, err := json.Marshal(map[string]interface{}{
+		"Name":        name,
+		"Description": description,
+		"TableType":   "EXTERNAL_TABLE",
+		"Parameters":  map[string]string{"classification": "json"},
+		"StorageDescriptor": map[string]interface{}{
+			"Columns":      columns,
+			"Location":     l.location + "/" + name + "/",
+			"InputFormat":  "org.apache.hadoop.mapred.TextInputFormat",
+			"OutputFormat": "org.apache.hadoop.hive.ql.io.HiveIgnoreKeyTextOutputFormat",
+			"SerdeInfo": map[string]interface{}{
+				"SerializationLibrary": "org.openx.data.jsonserde.JsonSerDe",
+				"Parameters":           map[string]string{"ignore.malformed.json": "true"},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = l.catalog.cli.Run(ctx, nil, "glue", "create-table",
+		"--database-name", l.database, "--table-input", string(input))
+	if err != nil && !strings.Contains(err.Error(), "AlreadyExistsException") {
+		return fmt.Errorf("creating Glue table %s.%s: %w", l.database, name, err)
+	}
+	return nil
+}
+
+// PublishRun writes the entry's row to the runs table, replacing an earlier copy
+func (l *Lake) PublishRun(ctx context.Context, entry Entry) error {
+	row, err := json.Marshal(runRow{
+		ID:            entry.ID,
+		ConfigHash:    entry.ConfigHash,
+		Image:         entry.Image,
+		ImageDigest:   entry.ImageDigest,
+		Simulation:    entry.Simulation,
+		Resolution:    entry.Resolution,
+		MetField:      entry.MetField,
+		StartDate:     entry.StartDate,
+		EndDate:       entry.EndDate,
+		OutputURI:     entry.OutputURI,
+		SizeBytes:     entry.SizeBytes,
+		Objects:       entry.Objects,
+		Cost:          entry.Cost,
+		InstanceType:  entry.InstanceType,
+		Scheduler:     entry.Scheduler,
+		User:          entry.User,
+		CreatedAt:     entry.CreatedAt.UTC().Format("2006-01-02 15:04:05"),
+		Indexed:       entry.Indexed,
+		ProvenanceID:  entry.ProvenanceID,
+		RunConfigHash: entry.RunConfigHash,
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp("", "geoschem-run-*.json")
+	if err != nil {
+		return err
+	}
+	artifacts.Track(file.Name())
+	defer artifacts.Remove(file.Name())
+	if _, err := file.Write(append(row, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	bucket, key, _ := splitS3URI(l.RunURI(entry.ID))
+	if err := l.catalog.cli.Run(ctx, nil, "s3api", "put-object",
+		"--bucket", bucket, "--key", key, "--body", file.Name(),
+		"--content-type", "application/json"); err != nil {
+		return fmt.Errorf("publishing run %s: %w", entry.ID, err)
+	}
+	return nil
+}
+
+// Tabulate reduces the entry's output to diagnostic rows with the analysis image, run by
+// the runner. The selected species are read in full, so run it inside AWS when the output
+// is large.
+func (l *Lake) Tabulate(ctx context.Context, runner AnalysisRunner, analysisImage string, entry Entry, species []string) error {
+	command := []string{TabulateCommand, entry.OutputURI, l.DiagnosticsURI(entry.ID), "--run-id", entry.ID}
+	if len(species) > 0 {
+		command = append(command, "--species", strings.Join(species, ","))
+	}
+	if err := runner.RunAnalysis(ctx, analysisImage, command...); err != nil {
+		return fmt.Errorf("tabulating %s: %w", entry.ID, err)
+	}
+	return nil
+}
+
+// RunURI is where an entry's runs row is stored
+func (l *Lake) RunURI(id string) string {
+	return fmt.Sprintf("%s/runs/%s.json", l.location, id)
+}
+
+// DiagnosticsURI is where an entry's diagnostic rows are stored
+func (l *Lake) DiagnosticsURI(id string) string {
+	return fmt.Sprintf("%s/diagnostics/%s.json", l.location, id)
+}
@@ -0,0 +1,357 @@
+package catalog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/provenance"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// DefaultTable is the DynamoDB table shared by everyone recording results in the account
+const DefaultTable = "geoschem-results"
+
+// Entry describes the output of one completed simulation
+type Entry struct {
+	ID            string
+	ConfigHash    string // Fingerprint of the image and the run configuration (see state.ConfigHash)
+	Image         string
+	ImageDigest   string // sha256 digest of the image when it came from ECR
+	Simulation    string
+	Resolution    string
+	MetField      string
+	StartDate     string // YYYY-MM-DD
+	EndDate       string // YYYY-MM-DD
+	OutputURI     string
+	SizeBytes     int64
+	Objects       int
+	Indexed       bool    // Kerchunk references exist under OutputURI (see IndexURI)
+	ProvenanceID  string  // Run that wrote the output's provenance sidecar (see provenance.SidecarURI)
+	RunConfigHash string  // Fingerprint of the run settings and config files from the sidecar
+	Cost          float64 // Compute cost of the run in USD
+	InstanceType  string
+	Scheduler     string
+	User          string
+	CreatedAt     time.Time
+}
+
+// Query selects catalog entries. Empty fields match everything.
+type Query struct {
+	Simulation    string
+	Resolution    string
+	MetField      string
+	ConfigHash    string
+	RunConfigHash string // Matches runs configured the same way (see provenance.RunConfigHash)
+	Image         string // Matches the image reference or its digest
+	User          string
+	Start         string // Only runs covering Start through End (YYYY-MM-DD)
+	End           string
+}
+
+// Catalog is a DynamoDB table of simulation outputs, keyed by output location so
+// recording the same output again updates its entry
+type Catalog struct {
+	cli   *awscli.Client
+	table string
+}
+
+// New opens the catalog in the named table
+func New(profile, region, table string) *Catalog {
+	if table == "" {
+		table = DefaultTable
+	}
+	return &Catalog{cli: awscli.New(profile, region), table: table}
+}
+
+// Table returns the DynamoDB table name
+func (c *Catalog) Table() string {
+	return c.table
+}
+
+// CreateTable creates the on-demand catalog table
+func (c *Catalog) CreateTable(ctx context.Context) error {
+	err := c.cli.Run(ctx, nil, "dynamodb", "create-table",
+		"--table-name", c.table,
+		"--attribute-definitions", "AttributeName=id,AttributeType=S",
+		"--key-schema", "AttributeName=id,KeyType=HASH",
+		"--billing-mode", "PAY_PER_REQUEST",
+		"--tags", "Key=Project,Value=geoschem-aws")
+	if err != nil && !strings.Contains(err.Error(), "ResourceInUseException") {
+		return fmt.Errorf("creating results table: %w", err)
+	}
+	if err := c.cli.Run(ctx, nil, "dynamodb", "wait", "table-exists", "--table-name", c.table); err != nil {
+		return fmt.Errorf("waiting for results table: %w", err)
+	}
+	return nil
+}
+
+// Record stores an entry and returns it with its ID and creation time
+func (c *Catalog) Record(ctx context.Context, entry Entry) (*Entry, error) {
+	if !strings.HasPrefix(entry.OutputURI, "s3://") {
+		return nil, fmt.Errorf("output location must be an s3:// URI, got %s", entry.OutputURI)
+	}
+	if entry.Simulation == "" || entry.Resolution == "" {
+		return nil, fmt.Errorf("simulation and resolution are required")
+	}
+	for _, date := range []string{entry.StartDate, entry.EndDate} {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("invalid run date %q: %w", date, err)
+		}
+	}
+
+	entry.ID = EntryID(entry.OutputURI)
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+
+	itemJSON, err := json.Marshal(marshalEntry(entry))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.cli.Run(ctx, nil, "dynamodb", "put-item", "--table-name", c.table, "--item", string(itemJSON)); err != nil {
+		return nil, fmt.Errorf("recording result: %w", err)
+	}
+	return &entry, nil
+}
+
+// RecordOutput measures the output, notes whether it is indexed, indexes its provenance
+// sidecar, resolves the image digest, and records the entry
+func (c *Catalog) RecordOutput(ctx context.Context, entry Entry) (*Entry, error) {
+	objects, err := c.ListOutput(ctx, entry.OutputURI)
+	if err != nil {
+		return nil, err
+	}
+	_, sidecarKey, _ := splitS3URI(provenance.SidecarURI(entry.OutputURI))
+	entry.SizeBytes, entry.Objects = 0, len(objects)
+	hasSidecar := false
+	for _, object := range objects {
+		entry.SizeBytes += object.Size
+		if strings.HasSuffix(object.Key, "/"+IndexDir+"/index.json") {
+			entry.Indexed = true
+		}
+		if object.Key == sidecarKey {
+			hasSidecar = true
+		}
+	}
+	if hasSidecar {
+		// A sidecar that cannot be read leaves the entry as the caller described it
+		if record, err := provenance.ReadSidecar(ctx, c.cli, entry.OutputURI); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		} else {
+			entry.applyProvenance(record)
+		}
+	}
+	if entry.Image != "" && entry.ImageDigest == "" {
+		// A missing digest only makes the entry harder to match by digest
+		if entry.ImageDigest, err = c.ImageDigest(ctx, entry.Image); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	if entry.ConfigHash == "" {
+		// Runs pass their full configuration's hash; an entry described by hand hashes what it has
+		settings := map[string]string{"simulation": entry.Simulation, "resolution": entry.Resolution}
+		if entry.MetField != "" {
+			settings["met_field"] = entry.MetField
+		}
+		entry.ConfigHash = state.ConfigHash(entry.Image, settings)
+	}
+	return c.Record(ctx, entry)
+}
+
+// applyProvenance indexes the run's provenance and fills what the caller left empty. The
+// sidecar's digest was resolved when the run started, so it wins over the tag's current one.
+func (e *Entry) applyProvenance(record *provenance.Record) {
+	e.ProvenanceID = record.ID
+	e.RunConfigHash = record.ConfigHash
+	if record.ImageDigest != "" && (e.Image == "" || e.Image == record.Image) {
+		e.ImageDigest = record.ImageDigest
+	}
+	fill := []struct {
+		field *string
+		value string
+	}{
+		{&e.Image, record.Image},
+		{&e.InstanceType, record.InstanceType},
+		{&e.Scheduler, record.Scheduler},
+		{&e.MetField, record.Settings["met_field"]},
+		{&e.StartDate, record.Settings["start_date"]},
+		{&e.EndDate, record.Settings["end_date"]},
+	}
+	for _, f := range fill {
+		if *f.field == "" {
+			*f.field = f.value
+		}
+	}
+}
+
+// Get returns an entry by ID
+func (c *Catalog) Get(ctx context.Context, id string) (*Entry, error) {
+	key, err := json.Marshal(map[string]awscli.AttributeValue{"id": awscli.StringValue(id)})
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Item map[string]awscli.AttributeValue `json:"Item"`
+	}
+	if err := c.cli.Run(ctx, &out, "dynamodb", "get-item", "--table-name", c.table, "--key", string(key)); err != nil {
+		return nil, fmt.Errorf("reading result %s: %w", id, err)
+	}
+	if out.Item == nil {
+		return nil, fmt.Errorf("result %s not found", id)
+	}
+	entry := unmarshalEntry(out.Item)
+	return &entry, nil
+}
+
+// Delete removes an entry (the output in S3 is left in place)
+func (c *Catalog) Delete(ctx context.Context, id string) error {
+	key, err := json.Marshal(map[string]awscli.AttributeValue{"id": awscli.StringValue(id)})
+	if err != nil {
+		return err
+	}
+	if err := c.cli.Run(ctx, nil, "dynamodb", "delete-item", "--table-name", c.table, "--key", string(key),
+		"--condition-expression", "attribute_exists(id)"); err != nil {
+		if strings.Contains(err.Error(), "ConditionalCheckFailed") {
+			return fmt.Errorf("result %s not found", id)
+		}
+		return fmt.Errorf("deleting result %s: %w", id, err)
+	}
+	return nil
+}
+
+// Search returns the entries matching the query, newest first. Catalogs of a lab's runs
+// fit in a scan, so filtering happens here rather than in DynamoDB.
+func (c *Catalog) Search(ctx context.Context, query Query) ([]Entry, error) {
+	var out struct {
+		Items []map[string]awscli.AttributeValue `json:"Items"`
+	}
+	if err := c.cli.Run(ctx, &out, "dynamodb", "scan", "--table-name", c.table); err != nil {
+		return nil, fmt.Errorf("reading results: %w", err)
+	}
+
+	var matches []Entry
+	for _, item := range out.Items {
+		entry := unmarshalEntry(item)
+		if query.Matches(entry) {
+			matches = append(matches, entry)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].CreatedAt.After(matches[j].CreatedAt)
+	})
+	return matches, nil
+}
+
+// Matches reports whether the entry satisfies every field set in the query
+func (q Query) Matches(entry Entry) bool {
+	switch {
+	case q.Simulation != "" && entry.Simulation != q.Simulation,
+		q.Resolution != "" && entry.Resolution != q.Resolution,
+		q.MetField != "" && !strings.EqualFold(entry.MetField, q.MetField),
+		q.ConfigHash != "" && entry.ConfigHash != q.ConfigHash,
+		q.RunConfigHash != "" && entry.RunConfigHash != q.RunConfigHash,
+		q.User != "" && entry.User != q.User,
+		q.Image != "" && entry.Image != q.Image && entry.ImageDigest != q.Image:
+		return false
+	}
+	// Dates are YYYY-MM-DD, so string order is date order
+	if q.Start != "" && entry.StartDate > q.Start {
+		return false
+	}
+	if q.End != "" && entry.EndDate < q.End {
+		return false
+	}
+	return true
+}
+
+// EntryID derives a stable ID from the output location
+func EntryID(outputURI string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSuffix(outputURI, "/")))
+	return "res-" + hex.EncodeToString(sum[:6])
+}
+
+// ImageDigest resolves an ECR image reference to its digest. Images from other
+// registries return an empty digest.
+func (c *Catalog) ImageDigest(ctx context.Context, image string) (string, error) {
+	return provenance.ImageDigest(ctx, c.cli, image)
+}
+
+// splitS3URI splits s3://bucket/prefix into its bucket and prefix
+func splitS3URI(uri string) (string, string, error) {
+	if !strings.HasPrefix(uri, "s3://") {
+		return "", "", fmt.Errorf("expected an s3:// URI, got %s", uri)
+	}
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(uri, "s3://"), "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("missing bucket in %s", uri)
+	}
+	return bucket, prefix, nil
+}
+
+func marshalEntry(entry Entry) map[string]awscli.AttributeValue {
+	item := map[string]awscli.AttributeValue{
+		"id":         awscli.StringValue(entry.ID),
+		"simulation": awscli.StringValue(entry.Simulation),
+		"resolution": awscli.StringValue(entry.Resolution),
+		"start_date": awscli.StringValue(entry.StartDate),
+		"end_date":   awscli.StringValue(entry.EndDate),
+		"output_uri": awscli.StringValue(entry.OutputURI),
+		"size_bytes": awscli.NumberValue(float64(entry.SizeBytes)),
+		"objects":    awscli.NumberValue(float64(entry.Objects)),
+		"cost":       awscli.NumberValue(entry.Cost),
+		"created_at": awscli.TimeValue(entry.CreatedAt),
+	}
+	if entry.Indexed {
+		item["indexed"] = awscli.StringValue("true")
+	}
+	// An empty string would encode as an attribute without a type, so optional fields are omitted
+	optional := map[string]string{
+		"config_hash":     entry.ConfigHash,
+		"image":           entry.Image,
+		"image_digest":    entry.ImageDigest,
+		"met_field":       entry.MetField,
+		"instance_type":   entry.InstanceType,
+		"scheduler":       entry.Scheduler,
+		"user":            entry.User,
+		"provenance_id":   entry.ProvenanceID,
+		"run_config_hash": entry.RunConfigHash,
+	}
+	for name, value := range optional {
+		if value != "" {
+			item[name] = awscli.StringValue(value)
+		}
+	}
+	return item
+}
+
+func unmarshalEntry(item map[string]awscli.AttributeValue) Entry {
+	return Entry{
+		ID:            item["id"].S,
+		ConfigHash:    item["config_hash"].S,
+		Image:         item["image"].S,
+		ImageDigest:   item["image_digest"].S,
+		Simulation:    item["simulation"].S,
+		Resolution:    item["resolution"].S,
+		MetField:      item["met_field"].S,
+		StartDate:     item["start_date"].S,
+		EndDate:       item["end_date"].S,
+		OutputURI:     item["output_uri"].S,
+		SizeBytes:     int64(item["size_bytes"].Number()),
+		Objects:       int(item["objects"].Number()),
+		Cost:          item["cost"].Number(),
+		InstanceType:  item["instance_type"].S,
+		Scheduler:     item["scheduler"].S,
+		User:          item["user"].S,
+		CreatedAt:     item["created_at"].Time(),
+		Indexed:       item["indexed"].S == "true",
+		ProvenanceID:  item["provenance_id"].S,
+		RunConfigHash: item["run_config_hash"].S,
+	}
+}
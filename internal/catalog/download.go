@@ -0,0 +1,258 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// InternetEgressPerGB is S3 data transfer out to the internet (USD, first 10 TB/month tier)
+const InternetEgressPerGB = 0.09
+
+// downloadChunk is the byte range fetched per request. An interrupted download loses
+// at most one chunk of progress.
+const downloadChunk = int64(256) << 20
+
+// partSuffix marks a file still being downloaded
+const partSuffix = ".part"
+
+// Object is one file of a run's output
+type Object struct {
+	Key  string
+	Size int64
+	ETag string
+}
+
+// Selection narrows a download to part of a run's output. An empty selection keeps everything.
+type Selection struct {
+	Collections []string // GeosChem diagnostic collections, e.g. SpeciesConc, AerosolMass
+	Patterns    []string // Shell patterns matched against file names, e.g. "*.log"
+}
+
+// DownloadPlan lists what a download will fetch before any data moves
+type DownloadPlan struct {
+	OutputURI     string
+	Objects       []Object
+	TotalBytes    int64 // Selected output
+	PresentBytes  int64 // Already downloaded, including partial files that will resume
+	CompleteFiles int   // Files already fully downloaded
+}
+
+// ListOutput returns every object under an S3 output prefix
+func (c *Catalog) ListOutput(ctx context.Context, outputURI string) ([]Object, error) {
+	bucket, prefix, err := splitS3URI(outputURI)
+	if err != nil {
+		return nil, err
+	}
+	// Keep s3://bucket/run1 from also listing s3://bucket/run10
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	// The CLI follows pagination and applies the query to every page
+	var objects []Object
+	if err := c.cli.Run(ctx, &objects, "s3api", "list-objects-v2",
+		"--bucket", bucket, "--prefix", prefix,
+		"--query", "Contents[].{Key: Key, Size: Size, ETag: ETag}"); err != nil {
+		return nil, fmt.Errorf("listing %s: %w", outputURI, err)
+	}
+	return objects, nil
+}
+
+// Collection returns the GeosChem diagnostic collection an output file belongs to, such as
+// SpeciesConc for GEOSChem.SpeciesConc.20190701_0000z.nc4, or "" for other files
+func Collection(key string) string {
+	name := path.Base(key)
+	switch {
+	case strings.HasPrefix(name, "GEOSChem.") || strings.HasPrefix(name, "GCHP."):
+		parts := strings.Split(name, ".")
+		if len(parts) >= 3 {
+			return parts[1]
+		}
+	case strings.HasPrefix(name, "HEMCO_diagnostics"):
+		return "HEMCO"
+	}
+	return ""
+}
+
+// Matches reports whether an output file is selected
+func (s Selection) Matches(key string) bool {
+	if len(s.Collections) == 0 && len(s.Patterns) == 0 {
+		return true
+	}
+	collection := Collection(key)
+	for _, want := range s.Collections {
+		if collection != "" && strings.EqualFold(collection, want) {
+			return true
+		}
+	}
+	for _, pattern := range s.Patterns {
+		if ok, _ := path.Match(pattern, path.Base(key)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanDownload lists the selected output and what is already in dest from an earlier attempt
+func (c *Catalog) PlanDownload(ctx context.Context, outputURI, dest string, selection Selection) (*DownloadPlan, error) {
+	objects, err := c.ListOutput(ctx, outputURI)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &DownloadPlan{OutputURI: outputURI}
+	_, prefix, _ := splitS3URI(outputURI)
+	for _, object := range objects {
+		if strings.HasSuffix(object.Key, "/") || !selection.Matches(object.Key) {
+			continue
+		}
+		plan.Objects = append(plan.Objects, object)
+		plan.TotalBytes += object.Size
+
+		local := localPath(dest, prefix, object.Key)
+		if info, err := os.Stat(local); err == nil && info.Size() == object.Size {
+			plan.PresentBytes += object.Size
+			plan.CompleteFiles++
+		} else if info, err := os.Stat(local + partSuffix); err == nil && info.Size() <= object.Size {
+			plan.PresentBytes += info.Size()
+		}
+	}
+	sort.Slice(plan.Objects, func(i, j int) bool {
+		return plan.Objects[i].Key < plan.Objects[j].Key
+	})
+	return plan, nil
+}
+
+// TransferBytes is what remains to be downloaded
+func (p *DownloadPlan) TransferBytes() int64 {
+	return p.TotalBytes - p.PresentBytes
+}
+
+// EgressCost estimates the S3 data transfer charge for the remaining bytes to a
+// machine outside AWS. The account's monthly free allowance is not subtracted.
+func (p *DownloadPlan) EgressCost() float64 {
+	return float64(p.TransferBytes()) / float64(int64(1)<<30) * InternetEgressPerGB
+}
+
+// BytesByCollection totals the selected output per collection; other files are under "other"
+func (p *DownloadPlan) BytesByCollection() map[string]int64 {
+	totals := make(map[string]int64)
+	for _, object := range p.Objects {
+		collection := Collection(object.Key)
+		if collection == "" {
+			collection = "other"
+		}
+		totals[collection] += object.Size
+	}
+	return totals
+}
+
+// Download fetches the planned objects into dest, keeping the output's directory layout.
+// Files are fetched in byte ranges into a .part file, so an interrupted download resumes
+// where it stopped. progress is called after each file.
+func (c *Catalog) Download(ctx context.Context, plan *DownloadPlan, dest string, progress func(object Object, done int)) error {
+	bucket, prefix, err := splitS3URI(plan.OutputURI)
+	if err != nil {
+		return err
+	}
+
+	for i, object := range plan.Objects {
+		local := localPath(dest, prefix, object.Key)
+		if err := c.downloadObject(ctx, bucket, object, local); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(object, i+1)
+		}
+	}
+	return nil
+}
+
+// downloadObject resumes or starts one file and renames it into place when complete
+func (c *Catalog) downloadObject(ctx context.Context, bucket string, object Object, local string) error {
+	if info, err := os.Stat(local); err == nil && info.Size() == object.Size {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return err
+	}
+
+	// The ETag of the attempt that started the part file; a changed object starts over
+	part := local + partSuffix
+	etagFile := part + ".etag"
+	if previous, err := os.ReadFile(etagFile); err != nil || string(previous) != object.ETag {
+		os.Remove(part)
+		if err := os.WriteFile(etagFile, []byte(object.ETag), 0644); err != nil {
+			return err
+		}
+	}
+
+	out, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	info, err := out.Stat()
+	if err != nil {
+		return err
+	}
+
+	offset := info.Size()
+	if offset > object.Size {
+		return fmt.Errorf("%s is larger than s3://%s/%s; delete it and retry", part, bucket, object.Key)
+	}
+	for offset < object.Size {
+		end := min(offset+downloadChunk, object.Size) - 1
+		n, err := c.fetchRange(ctx, bucket, object, offset, end, out)
+		if err != nil {
+			return err
+		}
+		offset += n
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	os.Remove(etagFile)
+	return os.Rename(part, local)
+}
+
+// fetchRange appends bytes offset..end (inclusive) of the object to out. The chunk is
+// only appended once its request completes, so an interrupted request leaves no partial range.
+func (c *Catalog) fetchRange(ctx context.Context, bucket string, object Object, offset, end int64, out *os.File) (int64, error) {
+	chunk := out.Name() + ".chunk"
+	defer os.Remove(chunk)
+
+	if err := c.cli.Run(ctx, nil, "s3api", "get-object",
+		"--bucket", bucket,
+		"--key", object.Key,
+		"--range", fmt.Sprintf("bytes=%d-%d", offset, end),
+		"--if-match", object.ETag,
+		chunk); err != nil {
+		return 0, fmt.Errorf("downloading s3://%s/%s: %w", bucket, object.Key, err)
+	}
+
+	in, err := os.Open(chunk)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	n, err := io.Copy(out, in)
+	if err != nil {
+		return 0, fmt.Errorf("appending to %s: %w", out.Name(), err)
+	}
+	if n != end-offset+1 {
+		return 0, fmt.Errorf("short read of s3://%s/%s: got %d bytes, expected %d", bucket, object.Key, n, end-offset+1)
+	}
+	return n, nil
+}
+
+// localPath maps an object key under prefix to its path below dest
+func localPath(dest, prefix, key string) string {
+	relative := strings.TrimPrefix(strings.TrimPrefix(key, prefix), "/")
+	return filepath.Join(dest, filepath.FromSlash(relative))
+}
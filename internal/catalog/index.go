@@ -0,0 +1,76 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// IndexCommand is the analysis image's command that builds kerchunk references for an output
+const IndexCommand = "geoschem-index-output"
+
+// IndexDir holds the reference files under an output prefix
+const IndexDir = "kerchunk"
+
+// IndexURI returns where the list of an output's reference files is written
+func IndexURI(outputURI string) string {
+	return strings.TrimSuffix(outputURI, "/") + "/" + IndexDir + "/index.json"
+}
+
+// AnalysisRunner runs a command in the analysis image, on this machine or in AWS
+type AnalysisRunner interface {
+	RunAnalysis(ctx context.Context, analysisImage string, command ...string) error
+}
+
+// Index runs the analysis image's indexer over an output with the runner. It reads only
+// NetCDF metadata, so running it outside AWS transfers little data.
+func Index(ctx context.Context, runner AnalysisRunner, analysisImage, outputURI string) error {
+	if err := runner.RunAnalysis(ctx, analysisImage, IndexCommand, outputURI); err != nil {
+		return fmt.Errorf("indexing %s: %w", outputURI, err)
+	}
+	return nil
+}
+
+// LocalAnalysis runs the analysis image with docker and the local AWS credentials
+type LocalAnalysis struct {
+	Profile string
+	Region  string
+}
+
+func (l LocalAnalysis) RunAnalysis(ctx context.Context, analysisImage string, command ...string) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("locating AWS credentials: %w", err)
+	}
+
+	// The micromamba base image runs as mambauser
+	args := []string{"run", "--rm",
+		"-v", filepath.Join(home, ".aws") + ":/home/mambauser/.aws:ro",
+		"-e", "AWS_REGION=" + l.Region,
+	}
+	if l.Profile != "" {
+		args = append(args, "-e", "AWS_PROFILE="+l.Profile)
+	}
+	args = append(args, analysisImage)
+	args = append(args, command...)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w: %s", command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// OpenSnippet shows how to open one collection's references lazily with xarray
+func OpenSnippet(outputURI, collection string) string {
+	return fmt.Sprintf(`xr.open_dataset("reference://", engine="zarr", backend_kwargs={"consolidated": False, `+
+		`"storage_options": {"fo": "%s/%s/%s.json", "remote_protocol": "s3"}})`,
+		strings.TrimSuffix(outputURI, "/"), IndexDir, collection)
+}
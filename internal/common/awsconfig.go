@@ -0,0 +1,62 @@
+package common
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// Retry policy for AWS API calls. Parallel matrix workers call EC2 and ECR in bursts that get
+// throttled, so calls retry more often than the SDK's default of 3 attempts, with jittered
+// exponential backoff up to awsMaxBackoff.
+const (
+	awsMaxAttempts = 10
+	awsMaxBackoff  = 30 * time.Second
+	// awsRetryBudget is the retry tokens available at once. The SDK's default of 500 is spent
+	// after about 100 throttled calls, after which calls fail without retrying.
+	awsRetryBudget = 5000
+)
+
+// LoadSDKConfig loads the AWS SDK config for a profile and region with the platform's retry
+// policy. All clients built from the config share one adaptive retryer, so once any call is
+// throttled every worker in the process slows its request rate, rather than each retrying
+// on its own. AWS_MAX_ATTEMPTS overrides the attempt count. The config's caller identity
+// becomes CurrentUser, which namespaces resources in shared accounts.
+func LoadSDKConfig(ctx context.Context, profile, region string, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+	retryer := newAWSRetryer()
+	opts := []func(*config.LoadOptions) error{
+		config.WithSharedConfigProfile(profile),
+		config.WithRegion(region),
+		config.WithRetryer(func() aws.Retryer { return retryer }),
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, append(opts, optFns...)...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	IdentifyUser(ctx, cfg)
+	return cfg, nil
+}
+
+// newAWSRetryer returns an adaptive retryer: standard retries with jittered backoff, plus
+// client-side rate limiting that backs off when AWS throttles
+func newAWSRetryer() aws.Retryer {
+	maxAttempts := awsMaxAttempts
+	if n, err := strconv.Atoi(os.Getenv("AWS_MAX_ATTEMPTS")); err == nil && n > 0 {
+		maxAttempts = n
+	}
+
+	return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+		o.StandardOptions = append(o.StandardOptions, func(so *retry.StandardOptions) {
+			so.MaxAttempts = maxAttempts
+			so.MaxBackoff = awsMaxBackoff
+			so.Backoff = retry.NewExponentialJitterBackoff(awsMaxBackoff)
+			so.RateLimiter = ratelimit.NewTokenRateLimit(awsRetryBudget)
+		})
+	})
+}
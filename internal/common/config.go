@@ -2,17 +2,145 @@ package common
 
 import (
     "fmt"
+    "net/url"
     "os"
+    "slices"
+    "strings"
     "gopkg.in/yaml.v3"
 )
 
 // AWSConfig holds AWS-specific configuration
 type AWSConfig struct {
-    Profile       string `yaml:"profile"`
-    Region        string `yaml:"region"`
-    KeyPair       string `yaml:"key_pair"`
-    SecurityGroup string `yaml:"security_group"`
-    SubnetID      string `yaml:"subnet_id"`
+    Profile         string   `yaml:"profile"`
+    Region          string   `yaml:"region"`
+    KeyPair         string   `yaml:"key_pair"`
+    KeyFile         string   `yaml:"key_file"`         // Private key for key_pair; when set, SSH builds use this persistent key instead of a per-build key
+    InstanceConnect bool     `yaml:"instance_connect"` // Push a one-time key with EC2 Instance Connect instead of importing a key pair
+    KeyStorage      string   `yaml:"key_storage"`      // Where generated private keys are kept: file (default), passphrase, ssm, secretsmanager
+    KeyKMSKeyID     string   `yaml:"key_kms_key_id"`   // KMS key for ssm and secretsmanager key storage (default: the service's AWS managed key)
+    KeyType         string   `yaml:"key_type"`         // Generated SSH keys: ed25519 (default) or rsa4096
+    SecurityGroup   string   `yaml:"security_group"`
+    SubnetID        string   `yaml:"subnet_id"`
+    SubnetIDs       []string `yaml:"subnet_ids"`       // Additional subnets (ideally in different AZs) tried in rotation
+}
+
+// BuilderInstanceProfile is the IAM instance profile build and run instances launch with
+// (see 'geoschem-aws bootstrap')
+const BuilderInstanceProfile = "geoschem-ec2-builder-profile"
+
+// Key storage for generated SSH private keys
+const (
+    KeyStorageFile           = "file"           // Plaintext PEM files in the private key directory
+    KeyStoragePassphrase     = "passphrase"     // PEM files encrypted with GEOSCHEM_KEY_PASSPHRASE
+    KeyStorageSSM            = "ssm"            // SSM Parameter Store SecureString, fetched when connecting
+    KeyStorageSecretsManager = "secretsmanager" // Secrets Manager secret, fetched when connecting
+)
+
+// KeyStorageName returns the configured key storage, defaulting to files
+func (a AWSConfig) KeyStorageName() string {
+    if a.KeyStorage == "" {
+        return KeyStorageFile
+    }
+    return a.KeyStorage
+}
+
+// ValidateKeyStorage checks the key storage name
+func (a AWSConfig) ValidateKeyStorage() error {
+    switch a.KeyStorageName() {
+    case KeyStorageFile, KeyStoragePassphrase, KeyStorageSSM, KeyStorageSecretsManager:
+    default:
+        return fmt.Errorf("unknown key_storage '%s' (expected file, passphrase, ssm, or secretsmanager)", a.KeyStorage)
+    }
+    if a.KeyKMSKeyID != "" && a.KeyStorageName() != KeyStorageSSM && a.KeyStorageName() != KeyStorageSecretsManager {
+        return fmt.Errorf("key_kms_key_id only applies to ssm and secretsmanager key storage")
+    }
+    return nil
+}
+
+// Generated SSH key types. EC2 imports both and Instance Connect pushes both; RSA is for
+// older sshd builds and Windows instances, which don't accept ed25519.
+const (
+    KeyTypeEd25519 = "ed25519"
+    KeyTypeRSA4096 = "rsa4096"
+)
+
+// KeyTypeName returns the configured key type, defaulting to ed25519
+func (a AWSConfig) KeyTypeName() string {
+    if a.KeyType == "" {
+        return KeyTypeEd25519
+    }
+    return a.KeyType
+}
+
+// ValidateKeyType checks the key type name
+func (a AWSConfig) ValidateKeyType() error {
+    switch a.KeyTypeName() {
+    case KeyTypeEd25519, KeyTypeRSA4096:
+        return nil
+    default:
+        return fmt.Errorf("unknown key_type '%s' (expected ed25519 or rsa4096)", a.KeyType)
+    }
+}
+
+// Subnets returns subnet_id followed by subnet_ids, without duplicates
+func (a AWSConfig) Subnets() []string {
+    var subnets []string
+    seen := make(map[string]bool)
+    for _, subnet := range append([]string{a.SubnetID}, a.SubnetIDs...) {
+        if subnet != "" && !seen[subnet] {
+            seen[subnet] = true
+            subnets = append(subnets, subnet)
+        }
+    }
+    return subnets
+}
+
+// PlacementConfig names AZ-scoped resources instances use, so launches stay in their AZ
+type PlacementConfig struct {
+    AvailabilityZone string `yaml:"availability_zone"` // Pin launches to this AZ (e.g. us-west-2b)
+    FSxFileSystem    string `yaml:"fsx_file_system"`   // FSx for Lustre file system the instances mount
+    CacheVolume      string `yaml:"cache_volume"`      // Existing EBS volume (e.g. an input data cache) attached to instances
+    ClusterGroup     string `yaml:"cluster_group"`     // Cluster placement group instances launch into, created if missing, for low-latency MPI between GCHP nodes
+    EFSFileSystem    string `yaml:"-"`                 // Set per run by the caller when the run mounts EFS
+}
+
+// IsSet reports whether any placement constraint is configured
+func (p PlacementConfig) IsSet() bool {
+    return p.AvailabilityZone != "" || p.FSxFileSystem != "" || p.CacheVolume != "" || p.ClusterGroup != "" || p.EFSFileSystem != ""
+}
+
+// TaggingConfig controls how launched instances are named and tagged
+type TaggingConfig struct {
+    InstanceName string            `yaml:"instance_name"` // Name tag template; {arch}, {tag}, and {user} are expanded (default: geoschem-builder-{user}-{arch})
+    Tags         map[string]string `yaml:"tags"`          // Extra tags for instances and their volumes
+    BuildTag     string            `yaml:"-"`             // Set per launch by the caller, expands {tag}
+}
+
+// Tags the platform always sets itself
+var reservedTags = map[string]bool{"Name": true, "Project": true, "Owner": true, "BuildTag": true}
+
+// InstanceNameFor expands the instance name template. The default includes the user so
+// people sharing an account can tell their instances apart.
+func (t TaggingConfig) InstanceNameFor(arch, user string) string {
+    name := t.InstanceName
+    if name == "" {
+        name = "geoschem-builder-{user}-{arch}"
+    }
+    tag := t.BuildTag
+    if tag == "" {
+        tag = "adhoc"
+    }
+    return strings.NewReplacer("{arch}", arch, "{tag}", tag, "{user}", user).Replace(name)
+}
+
+// Validate rejects tags that would override the platform's own
+func (t TaggingConfig) Validate() error {
+    for key := range t.Tags {
+        if reservedTags[key] || strings.HasPrefix(key, "aws:") {
+            return fmt.Errorf("tag '%s' is reserved", key)
+        }
+    }
+    return nil
 }
 
 // BatchConfig holds AWS Batch configuration
@@ -22,25 +150,441 @@ type BatchConfig struct {
     JobDefinition     string `yaml:"job_definition"`
 }
 
+// Execution backends for matrix builds
+const (
+    BackendSSH   = "ssh"   // Launch an instance per build and drive it over SSH
+    BackendSSM   = "ssm"   // Launch an instance per build and drive it with SSM Run Command
+    BackendBatch = "batch" // Submit each build as an AWS Batch job
+)
+
+// ExecutionConfig selects how matrix builds are executed
+type ExecutionConfig struct {
+    Backend     string   `yaml:"backend"`     // ssh (default), ssm, batch
+    KeepGoing   bool     `yaml:"keep_going"`  // Build every combination even after failures
+    Critical    []string `yaml:"critical"`    // arch/compiler/mpi patterns ("*" matches any part) whose failure fails the matrix; empty = all
+    Concurrency int      `yaml:"concurrency"` // Builds run at once (default 1); instance backends cap it by the free vCPU quota
+    Resume      bool     `yaml:"-"`           // Skip combinations the last matrix build with the same settings built (build --resume)
+}
+
+// MaxConcurrency returns the configured number of builds to run at once, at least 1
+func (e ExecutionConfig) MaxConcurrency() int {
+    if e.Concurrency < 1 {
+        return 1
+    }
+    return e.Concurrency
+}
+
+// BackendName returns the configured backend, defaulting to SSH
+func (e ExecutionConfig) BackendName() string {
+    if e.Backend == "" {
+        return BackendSSH
+    }
+    return e.Backend
+}
+
+// Validate checks the backend name and critical patterns
+func (e ExecutionConfig) Validate() error {
+    switch e.BackendName() {
+    case BackendSSH, BackendSSM, BackendBatch:
+    default:
+        return fmt.Errorf("unknown execution backend '%s' (expected ssh, ssm, or batch)", e.Backend)
+    }
+    if e.Concurrency < 0 {
+        return fmt.Errorf("concurrency cannot be negative: %d", e.Concurrency)
+    }
+    for _, pattern := range e.Critical {
+        if len(strings.Split(pattern, "/")) != 3 {
+            return fmt.Errorf("critical entry '%s' must be arch/compiler/mpi", pattern)
+        }
+    }
+    return nil
+}
+
+// IsCritical reports whether a failure of the combination should fail the matrix build
+func (e ExecutionConfig) IsCritical(arch, compiler, mpi string) bool {
+    if len(e.Critical) == 0 {
+        return true
+    }
+    parts := []string{arch, compiler, mpi}
+    for _, pattern := range e.Critical {
+        fields := strings.Split(pattern, "/")
+        if len(fields) != len(parts) {
+            continue
+        }
+        matched := true
+        for i, field := range fields {
+            if field != "*" && field != parts[i] {
+                matched = false
+                break
+            }
+        }
+        if matched {
+            return true
+        }
+    }
+    return false
+}
+
+// Schedulers that execute simulation runs
+const (
+    SchedulerEC2   = "ec2"   // Launch an instance per run and drive it over SSH
+    SchedulerBatch = "batch" // Submit each run as an AWS Batch job
+    SchedulerSlurm = "slurm" // Submit each run with sbatch on a ParallelCluster (or other Slurm) head node
+    SchedulerEKS   = "eks"   // Submit each run as a Kubernetes Job
+)
+
+// RunsConfig selects where simulations run
+type RunsConfig struct {
+    Scheduler string         `yaml:"scheduler"` // ec2 (default), batch, slurm, eks
+    Batch     BatchRunConfig `yaml:"batch"`
+    Slurm     SlurmConfig    `yaml:"slurm"`
+    EKS       EKSConfig      `yaml:"eks"`
+    Fargate   FargateConfig  `yaml:"fargate"` // Queue for auxiliary jobs, whichever scheduler runs the simulations
+    FSx       FSxConfig      `yaml:"fsx"`     // FSx for Lustre for inputs and the run directory (ec2 and batch)
+}
+
+// BatchRunConfig describes the Batch queue runs are submitted to
+type BatchRunConfig struct {
+    JobQueue   string `yaml:"job_queue"`
+    JobRoleARN string `yaml:"job_role_arn"` // Role the run container uses for S3 input, restart, and output
+    WarmPool   WarmPoolConfig `yaml:"warm_pool"` // Minimum vCPUs kept up during working hours
+}
+
+// SlurmConfig describes how to reach the cluster's head node
+type SlurmConfig struct {
+    HeadNode  string `yaml:"head_node"` // Host name or IP of the head node
+    User      string `yaml:"user"`      // Default ec2-user
+    KeyFile   string `yaml:"key_file"`  // SSH private key for the head node
+    Partition string `yaml:"partition"` // Default: the cluster's default partition
+    Runtime   string `yaml:"runtime"`   // apptainer (default) or podman on the compute nodes
+    WorkDir   string `yaml:"work_dir"`  // Shared directory for job scripts and output (default /shared/geoschem)
+}
+
+// EKSConfig describes the cluster and namespace runs are submitted to as Kubernetes Jobs
+type EKSConfig struct {
+    Cluster        string            `yaml:"cluster"`         // EKS cluster name
+    Namespace      string            `yaml:"namespace"`       // Default "default"
+    ServiceAccount string            `yaml:"service_account"` // Service account with S3 access (IRSA or Pod Identity)
+    NodeSelector   map[string]string `yaml:"node_selector"`   // Extra node labels, e.g. karpenter.sh/capacity-type: on-demand
+    PinInstance    bool              `yaml:"pin_instance"`    // Also select the predicted instance type instead of letting Karpenter choose by requests
+}
+
+// SchedulerName returns the configured scheduler, defaulting to EC2
+func (r RunsConfig) SchedulerName() string {
+    if r.Scheduler == "" {
+        return SchedulerEC2
+    }
+    return r.Scheduler
+}
+
+// Validate checks the scheduler name and its required settings
+func (r RunsConfig) Validate() error {
+    if err := r.Fargate.Validate(); err != nil {
+        return fmt.Errorf("runs.%w", err)
+    }
+    if err := r.FSx.Validate(); err != nil {
+        return fmt.Errorf("runs.%w", err)
+    }
+    if r.FSx.Enabled() {
+        switch r.SchedulerName() {
+        case SchedulerEC2:
+        case SchedulerBatch:
+            // Batch hosts mount the file system when they boot, before any run could create one
+            if r.FSx.Provisioned() {
+                return fmt.Errorf("runs.fsx on batch needs file_system_id, mounted at boot by the compute environment's hosts")
+            }
+        default:
+            return fmt.Errorf("runs.fsx is supported on the ec2 and batch schedulers, not %s", r.SchedulerName())
+        }
+    }
+    switch r.SchedulerName() {
+    case SchedulerEC2:
+    case SchedulerBatch:
+        if r.Batch.JobQueue == "" {
+            return fmt.Errorf("batch scheduler requires runs.batch.job_queue")
+        }
+        if err := r.Batch.WarmPool.Validate(); err != nil {
+            return fmt.Errorf("runs.batch.%w", err)
+        }
+    case SchedulerSlurm:
+        if r.Slurm.HeadNode == "" || r.Slurm.KeyFile == "" {
+            return fmt.Errorf("slurm scheduler requires runs.slurm.head_node and runs.slurm.key_file")
+        }
+        switch r.Slurm.Runtime {
+        case "", "apptainer", "podman":
+        default:
+            return fmt.Errorf("unknown slurm runtime '%s' (expected apptainer or podman)", r.Slurm.Runtime)
+        }
+    case SchedulerEKS:
+        if r.EKS.Cluster == "" {
+            return fmt.Errorf("eks scheduler requires runs.eks.cluster")
+        }
+    default:
+        return fmt.Errorf("unknown scheduler '%s' (expected ec2, batch, slurm, or eks)", r.Scheduler)
+    }
+    return nil
+}
+
+// SourceConfig identifies the GeosChem source and image tag used by matrix builds
+type SourceConfig struct {
+    Repo            string            `yaml:"repo"`
+    Branch          string            `yaml:"branch"`
+    ImageTag        string            `yaml:"image_tag"`
+    Local           string            `yaml:"local"`             // Working copy to upload to build hosts instead of cloning repo, uncommitted changes included (ssh backend)
+    Archive         string            `yaml:"-" json:"-"`        // Local packed for upload, set while building
+    Revision        string            `yaml:"-"`                 // Local's commit, suffixed -dirty when it has uncommitted changes
+    Submodules      map[string]string `yaml:"submodules"`        // Branch, tag or commit to check out per submodule path (e.g. src/HEMCO); others stay at the commit repo pins
+    TokenSecret     string            `yaml:"token_secret"`      // Secrets Manager secret holding a GitHub token, for a private https:// repo
+    DeployKeySecret string            `yaml:"deploy_key_secret"` // Secrets Manager secret holding an SSH deploy key, for a private git@ repo
+    Patches         []SourcePatch     `yaml:"patches"`           // Applied in order to the clone before building, and recorded in image labels
+    Dockerfile      DockerfileConfig  `yaml:"dockerfile"`        // Dockerfile and build context every model build uses, unless its compiler sets one
+}
+
+// GitSecretPrefix is the Secrets Manager name prefix bootstrap lets build instances read
+// clone credentials under
+const GitSecretPrefix = "geoschem-aws/git/"
+
+// WithDefaults fills in the upstream repository, main branch, and latest tag
+func (s SourceConfig) WithDefaults() SourceConfig {
+    if s.Repo == "" {
+        s.Repo = "https://github.com/geoschem/GeosChem.git"
+    }
+    if s.Branch == "" {
+        s.Branch = "main"
+    }
+    if s.ImageTag == "" {
+        s.ImageTag = "latest"
+    }
+    return s
+}
+
+// Validate checks the submodule overrides, patches, Dockerfile and clone credentials
+func (s SourceConfig) Validate() error {
+    if err := ValidateSubmoduleRefs(s.Submodules); err != nil {
+        return err
+    }
+    if err := s.Dockerfile.Validate(); err != nil {
+        return fmt.Errorf("invalid source.dockerfile: %w", err)
+    }
+    if err := ValidatePatches(s.Patches, s.WithDefaults().Repo); err != nil {
+        return err
+    }
+    return ValidateCloneCredentials(s.WithDefaults().Repo, s.TokenSecret, s.DeployKeySecret)
+}
+
+// ValidateCloneCredentials checks that a private repository's credential suits its URL and
+// isn't written into the URL, where it would end up in image labels and logs
+func ValidateCloneCredentials(repo, tokenSecret, deployKeySecret string) error {
+    if u, err := url.Parse(repo); err == nil && u.User != nil && u.Scheme != "ssh" {
+        return fmt.Errorf("repo must not contain credentials; store the token in Secrets Manager and name it in token_secret")
+    }
+    https := strings.HasPrefix(repo, "https://")
+    switch {
+    case tokenSecret != "" && deployKeySecret != "":
+        return fmt.Errorf("token_secret and deploy_key_secret can't both be set")
+    case tokenSecret != "" && !https:
+        return fmt.Errorf("token_secret needs an https:// repo, got '%s'", repo)
+    case deployKeySecret != "" && https:
+        return fmt.Errorf("deploy_key_secret needs an SSH repo (git@github.com:owner/repo.git), got '%s'", repo)
+    }
+    return nil
+}
+
+// ParseSubmoduleRefs reads submodule overrides written path=ref,path=ref
+func ParseSubmoduleRefs(spec string) (map[string]string, error) {
+    refs := make(map[string]string)
+    for _, entry := range strings.Split(spec, ",") {
+        path, ref, ok := strings.Cut(strings.TrimSpace(entry), "=")
+        if !ok {
+            return nil, fmt.Errorf("submodule override '%s' must be path=ref", entry)
+        }
+        refs[path] = ref
+    }
+    return refs, ValidateSubmoduleRefs(refs)
+}
+
+// ValidateSubmoduleRefs checks submodule overrides: paths relative to the checkout, and a ref for each
+func ValidateSubmoduleRefs(refs map[string]string) error {
+    for path, ref := range refs {
+        if path == "" || strings.HasPrefix(path, "/") || slices.Contains(strings.Split(path, "/"), "..") {
+            return fmt.Errorf("submodule path '%s' must be relative to the checkout", path)
+        }
+        if ref == "" || strings.HasPrefix(ref, "-") {
+            return fmt.Errorf("submodule %s needs a branch, tag or commit, got '%s'", path, ref)
+        }
+    }
+    return nil
+}
+
+// DependenciesImageConfig splits builds into a dependencies image (compilers, MPI, and the
+// Spack library stack) and a model image built FROM it, so model changes rebuild in minutes
+type DependenciesImageConfig struct {
+    Enabled bool   `yaml:"enabled"` // Build models FROM the dependencies image matching their configuration
+    Image   string `yaml:"image"`   // Build FROM this published image instead
+    Rebuild bool   `yaml:"rebuild"` // Rebuild the dependencies image even when ECR already has it
+    Stack   string `yaml:"stack"`   // Dependency stack version; empty picks one compatible with the GEOS-Chem version
+}
+
+// IsSet reports whether model builds use a dependencies image
+func (d DependenciesImageConfig) IsSet() bool {
+    return d.Enabled || d.Image != ""
+}
+
+// CacheConfig holds build caches that persist between build instances
+type CacheConfig struct {
+    CcacheS3    string            `yaml:"ccache_s3"`    // s3:// prefix for ccache archives; empty disables ccache
+    PullThrough map[string]string `yaml:"pull_through"` // Upstream registry (docker.io, quay.io) to ECR pull-through cache prefix
+    Prepull     []string          `yaml:"prepull"`      // Images pulled onto build hosts before builds, besides the base image
+}
+
+// Validate checks the cache locations
+func (c CacheConfig) Validate() error {
+    if c.CcacheS3 != "" && !strings.HasPrefix(c.CcacheS3, "s3://") {
+        return fmt.Errorf("ccache_s3 must be an s3:// URI, got '%s'", c.CcacheS3)
+    }
+    for registry, prefix := range c.PullThrough {
+        if !strings.Contains(prefix, ".dkr.ecr.") {
+            return fmt.Errorf("pull_through cache for %s must be an ECR repository prefix (<account>.dkr.ecr.<region>.amazonaws.com/<prefix>), got '%s'", registry, prefix)
+        }
+    }
+    return nil
+}
+
+// Compression formats podman can push layers in
+var pushCompressionFormats = []string{"gzip", "zstd", "zstd:chunked"}
+
+// PushConfig tunes how built images are uploaded to ECR
+type PushConfig struct {
+    ParallelUploads  int    `yaml:"parallel_uploads"`  // Layers uploaded at once; 0 lets podman decide
+    Compression      string `yaml:"compression"`       // gzip (default), zstd or zstd:chunked
+    CompressionLevel int    `yaml:"compression_level"` // 0 uses the format's default
+    Manifest         bool   `yaml:"manifest"`          // Also push a multi-arch manifest list combining the architectures' images
+}
+
+// Validate checks the push settings
+func (p PushConfig) Validate() error {
+    if p.ParallelUploads < 0 {
+        return fmt.Errorf("parallel_uploads must not be negative, got %d", p.ParallelUploads)
+    }
+    if p.Compression != "" && !slices.Contains(pushCompressionFormats, p.Compression) {
+        return fmt.Errorf("unknown compression '%s' (expected %s)", p.Compression, strings.Join(pushCompressionFormats, ", "))
+    }
+    if p.CompressionLevel < 0 {
+        return fmt.Errorf("compression_level must not be negative, got %d", p.CompressionLevel)
+    }
+    return nil
+}
+
+// TransferConfig limits file uploads to instances over SSH, so large uploads don't saturate
+// a shared uplink. Each upload is its own stream, multiplexed over the instance's connection.
+type TransferConfig struct {
+    BandwidthLimitMbps float64 `yaml:"bandwidth_limit_mbps"` // Cap on all uploads to an instance together, in megabits per second; 0 is uncapped
+    MaxStreams         int     `yaml:"max_streams"`          // Uploads to an instance at once (default 4)
+}
+
+// DefaultTransferStreams is how many uploads run at once when max_streams isn't set
+const DefaultTransferStreams = 4
+
+// Streams returns the configured upload streams, defaulting to DefaultTransferStreams
+func (t TransferConfig) Streams() int {
+    if t.MaxStreams == 0 {
+        return DefaultTransferStreams
+    }
+    return t.MaxStreams
+}
+
+// BytesPerSecond returns the bandwidth cap in bytes per second, or 0 when uncapped
+func (t TransferConfig) BytesPerSecond() float64 {
+    return t.BandwidthLimitMbps * 1e6 / 8
+}
+
+// Validate checks the transfer limits
+func (t TransferConfig) Validate() error {
+    if t.BandwidthLimitMbps < 0 {
+        return fmt.Errorf("bandwidth_limit_mbps must not be negative, got %g", t.BandwidthLimitMbps)
+    }
+    if t.MaxStreams < 0 {
+        return fmt.Errorf("max_streams must not be negative, got %d", t.MaxStreams)
+    }
+    return nil
+}
+
 // CompilerConfig holds compiler-specific configuration
 type CompilerConfig struct {
-    Version    string   `yaml:"version"`
-    MPIOptions []string `yaml:"mpi_options"`
+    Version    string           `yaml:"version"`
+    MPIOptions []string         `yaml:"mpi_options"`
+    Dockerfile DockerfileConfig `yaml:"dockerfile"` // Overrides source.dockerfile for this compiler's builds
+}
+
+// CPUOptions controls the physical core count and SMT of launched instances
+type CPUOptions struct {
+    CoreCount      int `yaml:"core_count"`       // 0 = instance default
+    ThreadsPerCore int `yaml:"threads_per_core"` // 1 disables hyperthreading, 0 = instance default
+}
+
+// IsSet reports whether any CPU option overrides the instance defaults
+func (co *CPUOptions) IsSet() bool {
+    return co != nil && (co.CoreCount > 0 || co.ThreadsPerCore > 0)
 }
 
+// Validate checks the CPU options for obvious mistakes
+func (co *CPUOptions) Validate() error {
+    if co == nil {
+        return nil
+    }
+    if co.CoreCount < 0 {
+        return fmt.Errorf("core count cannot be negative: %d", co.CoreCount)
+    }
+    if co.ThreadsPerCore < 0 || co.ThreadsPerCore > 2 {
+        return fmt.Errorf("threads per core must be 1 or 2, got: %d", co.ThreadsPerCore)
+    }
+    return nil
+}
+
+// DefaultRootVolumeGB sizes build instance root volumes; Spack builds and the podman layers
+// of a full stack need far more than the AMIs' default
+const DefaultRootVolumeGB = 100
+
 // ArchConfig holds architecture-specific configuration
 type ArchConfig struct {
     InstanceType string                    `yaml:"instance_type"`
+    Optimization string                    `yaml:"optimization"`   // Compiler tuning preset (e.g. portable, icelake, graviton3)
+    CPUOptions   *CPUOptions               `yaml:"cpu_options"`
+    RootVolumeGB int                       `yaml:"root_volume_gb"` // Root volume size of build instances (default: DefaultRootVolumeGB)
+    Spot         bool                      `yaml:"spot"`           // Launch one-time Spot instances, terminated if EC2 reclaims them
+    SpotFallback bool                      `yaml:"-"`              // Launch On-Demand when Spot has no capacity; set for build instances, while runs stay on Spot
+    SpotMaxPrice float64                   `yaml:"spot_max_price"` // Highest Spot price in USD per hour (default: the On-Demand price)
+    EFA          bool                      `yaml:"efa"`            // Launch with an Elastic Fabric Adapter for MPI between nodes (hpc6a, hpc7a, hpc7g, ...)
     Compilers    map[string]CompilerConfig `yaml:"compilers"`
 }
 
+// RootVolumeSize returns the configured root volume size in GB
+func (a ArchConfig) RootVolumeSize() int {
+    if a.RootVolumeGB > 0 {
+        return a.RootVolumeGB
+    }
+    return DefaultRootVolumeGB
+}
+
 // BuildConfig holds the complete build matrix configuration
 type BuildConfig struct {
-    AWS           AWSConfig             `yaml:"aws"`
-    Batch         BatchConfig           `yaml:"batch"`
-    Architectures map[string]ArchConfig `yaml:"architectures"`
-    MPIVersions   map[string]string     `yaml:"mpi_versions"`
-    ECRRepository string                `yaml:"ecr_repository"`
+    AWS           AWSConfig               `yaml:"aws"`
+    Batch         BatchConfig             `yaml:"batch"`
+    Architectures map[string]ArchConfig   `yaml:"architectures"`
+    MPIVersions   map[string]string       `yaml:"mpi_versions"`
+    ECRRepository string                  `yaml:"ecr_repository"`
+    ECRStrategy   string                  `yaml:"ecr_strategy"` // single, per-arch, or per-image
+    HostOS        HostOSConfig            `yaml:"host_os"`
+    Execution     ExecutionConfig         `yaml:"execution"`
+    Source        SourceConfig            `yaml:"source"`
+    Cache         CacheConfig             `yaml:"cache"`
+    Push          PushConfig              `yaml:"push"`
+    Transfer      TransferConfig          `yaml:"transfer"`
+    Dependencies  DependenciesImageConfig `yaml:"dependencies_image"`
+    Tagging       TaggingConfig           `yaml:"tagging"`
+    Runs          RunsConfig              `yaml:"runs"`
+    Placement     PlacementConfig         `yaml:"placement"`
+    Waits         WaitsConfig             `yaml:"waits"`
 }
 
 // LoadBuildConfig loads configuration from YAML file
@@ -63,6 +607,64 @@ func LoadBuildConfig(configFile string) (*BuildConfig, error) {
         return nil, fmt.Errorf("AWS region is required")
     }
     
+    if err := config.AWS.ValidateKeyStorage(); err != nil {
+        return nil, fmt.Errorf("invalid aws: %w", err)
+    }
+    if err := config.AWS.ValidateKeyType(); err != nil {
+        return nil, fmt.Errorf("invalid aws: %w", err)
+    }
+    
+    if _, err := config.HostOS.Resolve(); err != nil {
+        return nil, fmt.Errorf("invalid host_os: %w", err)
+    }
+    
+    if err := config.Execution.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid execution: %w", err)
+    }
+    
+    if err := config.Cache.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid cache: %w", err)
+    }
+    
+    if err := config.Push.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid push: %w", err)
+    }
+    if err := config.Transfer.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid transfer: %w", err)
+    }
+    if err := config.Source.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid source: %w", err)
+    }
+    
+    if err := config.Tagging.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid tagging: %w", err)
+    }
+    
+    if err := config.Runs.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid runs: %w", err)
+    }
+    
+    if err := config.Waits.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid waits: %w", err)
+    }
+    
+    for arch, archConfig := range config.Architectures {
+        if err := archConfig.CPUOptions.Validate(); err != nil {
+            return nil, fmt.Errorf("invalid cpu_options for %s: %w", arch, err)
+        }
+        if archConfig.RootVolumeGB < 0 {
+            return nil, fmt.Errorf("invalid root_volume_gb for %s: %d", arch, archConfig.RootVolumeGB)
+        }
+        if archConfig.SpotMaxPrice < 0 || (archConfig.SpotMaxPrice > 0 && !archConfig.Spot) {
+            return nil, fmt.Errorf("invalid spot_max_price for %s: %g (needs spot: true)", arch, archConfig.SpotMaxPrice)
+        }
+        for compiler, compilerConfig := range archConfig.Compilers {
+            if err := compilerConfig.Dockerfile.Validate(); err != nil {
+                return nil, fmt.Errorf("invalid dockerfile for %s on %s: %w", compiler, arch, err)
+            }
+        }
+    }
+    
     return &config, nil
 }
 
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// DockerfileConfig picks the Dockerfile a model image builds from and the directory it builds
+// in, for source trees that keep theirs outside docker/ (a monorepo's service directory, say)
+// or to try one from local disk without committing it
+type DockerfileConfig struct {
+	Path    string `yaml:"path"`            // In the source checkout (default: per build configuration, docker/Dockerfile)
+	Context string `yaml:"context"`         // Build context directory in the checkout (default: Path's directory)
+	Local   string `yaml:"local"`           // On local disk, uploaded and built in Context instead of Path
+	Content string `yaml:"-" json:"-"`      // Local's contents, read before launching
+	SHA256  string `yaml:"-" json:"sha256"` // Hash of Local's contents, fingerprinted and recorded in image labels
+}
+
+// IsSet reports whether anything overrides the build configuration's Dockerfile
+func (d DockerfileConfig) IsSet() bool {
+	return d.Path != "" || d.Context != "" || d.Local != ""
+}
+
+// Or fills the fields d leaves empty from fallback, so a compiler's Dockerfile can override
+// only the context, say, of the source's
+func (d DockerfileConfig) Or(fallback DockerfileConfig) DockerfileConfig {
+	if d.Path == "" && d.Local == "" {
+		d.Path, d.Local, d.Content, d.SHA256 = fallback.Path, fallback.Local, fallback.Content, fallback.SHA256
+	}
+	if d.Context == "" {
+		d.Context = fallback.Context
+	}
+	return d
+}
+
+// Label describes the Dockerfile in image labels: docker/Dockerfile in the checkout, or
+// local:Dockerfile.gchp@sha256:0123456789ab
+func (d DockerfileConfig) Label() string {
+	if d.Local == "" {
+		return d.Path
+	}
+	return "local:" + path.Base(d.Local) + "@sha256:" + d.SHA256[:min(12, len(d.SHA256))]
+}
+
+// Validate checks the paths stay inside the checkout and that only one Dockerfile is given
+func (d DockerfileConfig) Validate() error {
+	if d.Path != "" && d.Local != "" {
+		return fmt.Errorf("dockerfile path %s and local %s are both set; pick one", d.Path, d.Local)
+	}
+	return ValidateCheckoutPaths(d.Path, d.Context)
+}
+
+// ValidateCheckoutPaths checks that each non-empty path is relative to the checkout and
+// doesn't climb out of it
+func ValidateCheckoutPaths(paths ...string) error {
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if path.IsAbs(p) || strings.HasPrefix(p, "~") || slices.Contains(strings.Split(path.Clean(p), "/"), "..") {
+			return fmt.Errorf("'%s' must be relative to the source checkout", p)
+		}
+		if strings.ContainsAny(p, " \t\n'\"$`\\") {
+			return fmt.Errorf("'%s' can't contain spaces, quotes or shell characters", p)
+		}
+	}
+	return nil
+}
+
+// DockerfileFor returns the Dockerfile a compiler's builds on arch use: the compiler's,
+// falling back to the source's
+func (c *BuildConfig) DockerfileFor(arch, compiler string) DockerfileConfig {
+	return c.Architectures[arch].Compilers[compiler].Dockerfile.Or(c.Source.Dockerfile)
+}
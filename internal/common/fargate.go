@@ -0,0 +1,84 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// FargateConfig describes the Batch job queue that small auxiliary jobs (indexing,
+// tabulation, staging, checks) run on. A queue backed by a Fargate compute environment
+// needs no instances and doesn't count against the EC2 vCPU quota.
+type FargateConfig struct {
+	JobQueue         string  `yaml:"job_queue"`          // Queue whose compute environment is FARGATE or FARGATE_SPOT
+	ExecutionRoleARN string  `yaml:"execution_role_arn"` // Role Fargate uses to pull the image from ECR and write logs
+	JobRoleARN       string  `yaml:"job_role_arn"`       // Role the container uses for S3 (default runs.batch.job_role_arn)
+	VCPUs            float64 `yaml:"vcpus"`              // 0.25, 0.5, 1, 2, 4, 8 or 16 (default 1)
+	MemoryMiB        int     `yaml:"memory_mib"`         // Must suit the vCPUs, see the Fargate task sizes (default 4096)
+	Architecture     string  `yaml:"architecture"`       // x86_64 (default) or arm64, matching the image
+	AssignPublicIP   bool    `yaml:"assign_public_ip"`   // Needed to pull images in public subnets without a NAT gateway
+}
+
+// Enabled reports whether a Fargate queue is configured
+func (f FargateConfig) Enabled() bool {
+	return f.JobQueue != ""
+}
+
+// WithDefaults fills in a 1 vCPU, 4 GiB task on x86_64
+func (f FargateConfig) WithDefaults() FargateConfig {
+	if f.VCPUs == 0 {
+		f.VCPUs = 1
+	}
+	if f.MemoryMiB == 0 {
+		f.MemoryMiB = 4096
+	}
+	if f.Architecture == "" {
+		f.Architecture = "x86_64"
+	}
+	return f
+}
+
+// Validate checks the task size is one Fargate offers and the roles are set
+func (f FargateConfig) Validate() error {
+	if !f.Enabled() {
+		return nil
+	}
+	f = f.WithDefaults()
+	if f.ExecutionRoleARN == "" {
+		return fmt.Errorf("fargate requires execution_role_arn")
+	}
+	switch f.Architecture {
+	case "x86_64", "arm64":
+	default:
+		return fmt.Errorf("fargate.architecture must be x86_64 or arm64, got '%s'", f.Architecture)
+	}
+	if !fargateMemoryValid(f.VCPUs, f.MemoryMiB) {
+		return fmt.Errorf("fargate.memory_mib %d doesn't suit %s vCPUs; see the Fargate task sizes",
+			f.MemoryMiB, strconv.FormatFloat(f.VCPUs, 'f', -1, 64))
+	}
+	return nil
+}
+
+// fargateMemoryValid reports whether Fargate offers a task with the vCPUs and memory
+func fargateMemoryValid(vcpus float64, memoryMiB int) bool {
+	// Each size allows memory from low to high in steps
+	var low, high, step int
+	switch vcpus {
+	case 0.25:
+		return memoryMiB == 512 || memoryMiB == 1024 || memoryMiB == 2048
+	case 0.5:
+		low, high, step = 1024, 4096, 1024
+	case 1:
+		low, high, step = 2048, 8192, 1024
+	case 2:
+		low, high, step = 4096, 16384, 1024
+	case 4:
+		low, high, step = 8192, 30720, 1024
+	case 8:
+		low, high, step = 16384, 61440, 4096
+	case 16:
+		low, high, step = 32768, 122880, 8192
+	default:
+		return false
+	}
+	return memoryMiB >= low && memoryMiB <= high && memoryMiB%step == 0
+}
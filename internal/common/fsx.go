@@ -0,0 +1,119 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// FSx for Lustre deployment types runs can create
+const (
+	FSxScratch    = "SCRATCH_2"    // Cheapest; no replication, suits a run's working set that lives in S3
+	FSxPersistent = "PERSISTENT_2" // Replicated within its AZ, for file systems kept between runs
+)
+
+// What happens to a file system after the run
+const (
+	FSxDelete    = "delete"    // Delete it; output only survives where -output copies it
+	FSxHibernate = "hibernate" // Export new and changed files to the data repository, then delete it
+	FSxKeep      = "keep"      // Leave it running, and billed, for the next run
+)
+
+// fsxPersistentThroughputs are the PERSISTENT_2 throughput tiers in MB/s per TiB
+var fsxPersistentThroughputs = []int{125, 250, 500, 1000}
+
+// FSxConfig gives runs an FSx for Lustre file system for parallel I/O. It is linked to an
+// S3 data repository, whose files it loads as runs first read them, and holds the run
+// directory. FSx for Lustre can't be stopped, so a file system created for a run is
+// deleted or hibernated (exported to its repository and deleted) afterwards unless kept.
+type FSxConfig struct {
+	FileSystemID     string `yaml:"file_system_id"`     // Mount this file system instead of creating one per run (required for batch)
+	DataRepository   string `yaml:"data_repository"`    // s3:// prefix the created file system is linked to, e.g. inputs staged with 'data stage'
+	DeploymentType   string `yaml:"deployment_type"`    // SCRATCH_2 (default) or PERSISTENT_2
+	StorageGiB       int    `yaml:"storage_gib"`        // 1200 or a multiple of 2400 (default 1200)
+	ThroughputPerTiB int    `yaml:"throughput_per_tib"` // PERSISTENT_2 MB/s per TiB: 125 (default), 250, 500 or 1000
+	AfterRun         string `yaml:"after_run"`          // delete, hibernate or keep (default delete, or keep for file_system_id)
+}
+
+// Enabled reports whether runs mount FSx for Lustre
+func (f FSxConfig) Enabled() bool {
+	return f.FileSystemID != "" || f.DataRepository != ""
+}
+
+// Provisioned reports whether each run creates its own file system
+func (f FSxConfig) Provisioned() bool {
+	return f.FileSystemID == "" && f.DataRepository != ""
+}
+
+// WithDefaults fills in a 1.2 TiB scratch file system, deleted after runs that created it
+func (f FSxConfig) WithDefaults() FSxConfig {
+	if f.DeploymentType == "" {
+		f.DeploymentType = FSxScratch
+	}
+	if f.StorageGiB == 0 {
+		f.StorageGiB = 1200
+	}
+	if f.DeploymentType == FSxPersistent && f.ThroughputPerTiB == 0 {
+		f.ThroughputPerTiB = fsxPersistentThroughputs[0]
+	}
+	if f.AfterRun == "" {
+		f.AfterRun = FSxDelete
+		if !f.Provisioned() {
+			f.AfterRun = FSxKeep
+		}
+	}
+	return f
+}
+
+// KeepsOutput reports whether the run directory outlives the run, in the file system or its repository
+func (f FSxConfig) KeepsOutput() bool {
+	return f.Enabled() && f.WithDefaults().AfterRun != FSxDelete
+}
+
+// Validate checks the file system settings
+func (f FSxConfig) Validate() error {
+	if !f.Enabled() {
+		return nil
+	}
+	f = f.WithDefaults()
+	if f.DataRepository != "" && !strings.HasPrefix(f.DataRepository, "s3://") {
+		return fmt.Errorf("fsx.data_repository must be an s3:// URI, got '%s'", f.DataRepository)
+	}
+	switch f.DeploymentType {
+	case FSxScratch:
+		if f.ThroughputPerTiB != 0 {
+			return fmt.Errorf("fsx.throughput_per_tib only applies to %s", FSxPersistent)
+		}
+	case FSxPersistent:
+		if !slices.Contains(fsxPersistentThroughputs, f.ThroughputPerTiB) {
+			return fmt.Errorf("fsx.throughput_per_tib must be 125, 250, 500 or 1000, got %d", f.ThroughputPerTiB)
+		}
+	default:
+		return fmt.Errorf("unknown fsx.deployment_type '%s' (expected %s or %s)", f.DeploymentType, FSxScratch, FSxPersistent)
+	}
+	if f.StorageGiB != 1200 && (f.StorageGiB <= 0 || f.StorageGiB%2400 != 0) {
+		return fmt.Errorf("fsx.storage_gib must be 1200 or a multiple of 2400, got %d", f.StorageGiB)
+	}
+	switch f.AfterRun {
+	case FSxDelete, FSxHibernate, FSxKeep:
+	default:
+		return fmt.Errorf("unknown fsx.after_run '%s' (expected %s, %s or %s)", f.AfterRun, FSxDelete, FSxHibernate, FSxKeep)
+	}
+	return nil
+}
+
+// hoursPerMonth is the month AWS prices storage by
+const hoursPerMonth = 730
+
+// fsxCostPerGBMonth is the storage price of each deployment type (us-west-2); PERSISTENT_2 at 125 MB/s/TiB
+var fsxCostPerGBMonth = map[string]float64{
+	FSxScratch:    0.14,
+	FSxPersistent: 0.145,
+}
+
+// HourlyCost estimates what the file system costs per hour it exists. Higher PERSISTENT_2
+// throughput tiers cost more; they're priced at the 125 MB/s/TiB tier.
+func (f FSxConfig) HourlyCost() float64 {
+	f = f.WithDefaults()
+	return float64(f.StorageGiB) * fsxCostPerGBMonth[f.DeploymentType] / hoursPerMonth
+}
@@ -0,0 +1,110 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NestedDomain is a regional window run at high resolution with boundary conditions from a global run
+type NestedDomain struct {
+	Name   string
+	LonMin float64
+	LonMax float64
+	LatMin float64
+	LatMax float64
+}
+
+// Standard GeosChem nested-grid domains
+var nestedDomains = map[string]NestedDomain{
+	"AS": {Name: "AS", LonMin: 60, LonMax: 150, LatMin: -11, LatMax: 55},
+	"EU": {Name: "EU", LonMin: -30, LonMax: 50, LatMin: 30, LatMax: 70},
+	"NA": {Name: "NA", LonMin: -140, LonMax: -40, LatMin: 10, LatMax: 70},
+}
+
+// globalCells4x5 is the horizontal cell count of the 4x5 grid, the reference for scaling
+const globalCells4x5 = 46 * 72
+
+// GetNestedDomain returns a standard nested domain by name
+func GetNestedDomain(name string) (*NestedDomain, error) {
+	domain, ok := nestedDomains[strings.ToUpper(name)]
+	if !ok {
+		var names []string
+		for n := range nestedDomains {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("nested domain '%s' not found (available: %s)", name, strings.Join(names, ", "))
+	}
+	return &domain, nil
+}
+
+// IsCubedSphere reports whether a resolution is a GCHP cubed-sphere grid (C24, C48, ...)
+func IsCubedSphere(resolution string) bool {
+	_, err := CubedSphereSize(resolution)
+	return err == nil
+}
+
+// CubedSphereSize returns N for a CN cubed-sphere resolution
+func CubedSphereSize(resolution string) (int, error) {
+	if !strings.HasPrefix(resolution, "C") {
+		return 0, fmt.Errorf("%s is not a cubed-sphere resolution", resolution)
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(resolution, "C"))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid cubed-sphere resolution: %s", resolution)
+	}
+	return n, nil
+}
+
+// latLonSpacing parses a lat-lon resolution such as 2x2.5 into degrees
+func latLonSpacing(resolution string) (float64, float64, error) {
+	parts := strings.Split(resolution, "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid lat-lon resolution: %s", resolution)
+	}
+	dlat, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || dlat <= 0 {
+		return 0, 0, fmt.Errorf("invalid lat-lon resolution: %s", resolution)
+	}
+	dlon, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || dlon <= 0 {
+		return 0, 0, fmt.Errorf("invalid lat-lon resolution: %s", resolution)
+	}
+	return dlat, dlon, nil
+}
+
+// GridCells returns the number of horizontal grid cells for a resolution, optionally
+// restricted to a nested domain
+func GridCells(resolution string, nested *NestedDomain) (int, error) {
+	if n, err := CubedSphereSize(resolution); err == nil {
+		if nested != nil {
+			return 0, fmt.Errorf("nested domains are not supported on cubed-sphere grids")
+		}
+		return 6 * n * n, nil
+	}
+
+	dlat, dlon, err := latLonSpacing(resolution)
+	if err != nil {
+		return 0, err
+	}
+
+	if nested != nil {
+		lats := int((nested.LatMax-nested.LatMin)/dlat) + 1
+		lons := int((nested.LonMax-nested.LonMin)/dlon) + 1
+		return lats * lons, nil
+	}
+
+	// Global lat-lon grids have half-size polar boxes, hence the extra latitude row
+	return (int(180/dlat) + 1) * int(360/dlon), nil
+}
+
+// CellsRelativeTo4x5 returns the horizontal size of a grid relative to global 4x5
+func CellsRelativeTo4x5(resolution string, nested *NestedDomain) (float64, error) {
+	cells, err := GridCells(resolution, nested)
+	if err != nil {
+		return 0, err
+	}
+	return float64(cells) / globalCells4x5, nil
+}
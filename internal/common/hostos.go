@@ -0,0 +1,195 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HostOS describes an operating system that build and run instances can boot from
+type HostOS struct {
+	Name            string
+	DisplayName     string
+	AMIOwners       []string
+	AMINamePatterns map[string]string // Keyed by architecture (x86_64, arm64)
+	SSHUser         string
+	PackageManager  string // dnf or apt
+	InstanceConnect bool   // The AMI ships ec2-instance-connect, so EC2 Instance Connect can push keys
+	MaxAMIAgeMonths int    // Warn when the newest AMI found is older than this
+}
+
+// DefaultMaxAMIAgeMonths is the AMI age past which launches warn. Publishers refresh their
+// images at least with every minor release, so an older newest image usually means their
+// naming changed and the name pattern no longer finds the current ones.
+const DefaultMaxAMIAgeMonths = 6
+
+// DefaultHostOS is used when a configuration does not select a host OS
+const DefaultHostOS = "rocky9"
+
+// HostOSConfig selects the instance operating system and optionally overrides the AMI lookup,
+// which protects against publishers renaming or re-owning their images
+type HostOSConfig struct {
+	Name            string `yaml:"name"`               // rocky9 (default), rocky8, rocky10, alma9, al2023, ubuntu22, ubuntu24
+	AMIOwner        string `yaml:"ami_owner"`          // Overrides the AMI publisher account
+	AMINamePattern  string `yaml:"ami_name_pattern"`   // Overrides the AMI name filter; {arch} is replaced with the AMI architecture name
+	SSHUser         string `yaml:"ssh_user"`           // Overrides the default login user
+	MaxAMIAgeMonths int    `yaml:"max_ami_age_months"` // Warn when the selected AMI is older (default: 6)
+}
+
+// Rocky Linux AMIs are published through CIQ's marketplace account
+var rockyOwners = []string{"679593333241"}
+
+// GetHostOSes returns the supported host operating systems
+func GetHostOSes() []HostOS {
+	return []HostOS{
+		{
+			Name:        "rocky8",
+			DisplayName: "Rocky Linux 8",
+			AMIOwners:   rockyOwners,
+			AMINamePatterns: map[string]string{
+				"x86_64": "Rocky-8-EC2-Base-8.*x86_64*",
+				"arm64":  "Rocky-8-EC2-Base-8.*aarch64*",
+			},
+			SSHUser:        "rocky",
+			PackageManager: "dnf",
+		},
+		{
+			Name:        "rocky9",
+			DisplayName: "Rocky Linux 9",
+			AMIOwners:   rockyOwners,
+			AMINamePatterns: map[string]string{
+				"x86_64": "Rocky-9-EC2-Base-9.*x86_64*",
+				"arm64":  "Rocky-9-EC2-Base-9.*aarch64*",
+			},
+			SSHUser:        "rocky",
+			PackageManager: "dnf",
+		},
+		{
+			Name:        "rocky10",
+			DisplayName: "Rocky Linux 10",
+			AMIOwners:   rockyOwners,
+			AMINamePatterns: map[string]string{
+				"x86_64": "Rocky-10-EC2-Base-10.*x86_64*",
+				"arm64":  "Rocky-10-EC2-Base-10.*aarch64*",
+			},
+			SSHUser:        "rocky",
+			PackageManager: "dnf",
+		},
+		{
+			Name:        "alma9",
+			DisplayName: "AlmaLinux 9",
+			AMIOwners:   []string{"764336703387"},
+			AMINamePatterns: map[string]string{
+				"x86_64": "AlmaLinux OS 9.*x86_64*",
+				"arm64":  "AlmaLinux OS 9.*aarch64*",
+			},
+			SSHUser:        "ec2-user",
+			PackageManager: "dnf",
+		},
+		{
+			Name:        "al2023",
+			DisplayName: "Amazon Linux 2023",
+			AMIOwners:   []string{"amazon"},
+			AMINamePatterns: map[string]string{
+				"x86_64": "al2023-ami-2023.*-kernel-*-x86_64",
+				"arm64":  "al2023-ami-2023.*-kernel-*-arm64",
+			},
+			SSHUser:         "ec2-user",
+			PackageManager:  "dnf",
+			InstanceConnect: true,
+		},
+		{
+			Name:        "ubuntu22",
+			DisplayName: "Ubuntu 22.04 LTS",
+			AMIOwners:   []string{"099720109477"},
+			AMINamePatterns: map[string]string{
+				"x86_64": "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-*",
+				"arm64":  "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-arm64-server-*",
+			},
+			SSHUser:         "ubuntu",
+			PackageManager:  "apt",
+			InstanceConnect: true,
+		},
+		{
+			Name:        "ubuntu24",
+			DisplayName: "Ubuntu 24.04 LTS",
+			AMIOwners:   []string{"099720109477"},
+			AMINamePatterns: map[string]string{
+				"x86_64": "ubuntu/images/hvm-ssd-gp3/ubuntu-noble-24.04-amd64-server-*",
+				"arm64":  "ubuntu/images/hvm-ssd-gp3/ubuntu-noble-24.04-arm64-server-*",
+			},
+			SSHUser:         "ubuntu",
+			PackageManager:  "apt",
+			InstanceConnect: true,
+		},
+	}
+}
+
+// GetHostOS returns a host OS by name
+func GetHostOS(name string) (*HostOS, error) {
+	for _, hostOS := range GetHostOSes() {
+		if hostOS.Name == name {
+			return &hostOS, nil
+		}
+	}
+
+	var names []string
+	for _, hostOS := range GetHostOSes() {
+		names = append(names, hostOS.Name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("host OS '%s' not found (available: %s)", name, strings.Join(names, ", "))
+}
+
+// Resolve returns the selected host OS with any configured overrides applied
+func (c HostOSConfig) Resolve() (*HostOS, error) {
+	name := c.Name
+	if name == "" {
+		name = DefaultHostOS
+	}
+
+	hostOS, err := GetHostOS(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.AMIOwner != "" {
+		hostOS.AMIOwners = []string{c.AMIOwner}
+	}
+	if c.AMINamePattern != "" {
+		hostOS.AMINamePatterns = map[string]string{
+			"x86_64": strings.ReplaceAll(c.AMINamePattern, "{arch}", hostOS.amiArchName("x86_64")),
+			"arm64":  strings.ReplaceAll(c.AMINamePattern, "{arch}", hostOS.amiArchName("arm64")),
+		}
+	}
+	if c.SSHUser != "" {
+		hostOS.SSHUser = c.SSHUser
+	}
+	hostOS.MaxAMIAgeMonths = DefaultMaxAMIAgeMonths
+	if c.MaxAMIAgeMonths > 0 {
+		hostOS.MaxAMIAgeMonths = c.MaxAMIAgeMonths
+	}
+
+	return hostOS, nil
+}
+
+// AMINamePattern returns the AMI name filter for an architecture
+func (h *HostOS) AMINamePattern(arch string) (string, error) {
+	pattern, ok := h.AMINamePatterns[arch]
+	if !ok {
+		return "", fmt.Errorf("%s has no AMI for architecture %s", h.DisplayName, arch)
+	}
+	return pattern, nil
+}
+
+// amiArchName returns how the publisher spells the architecture in its AMI names
+func (h *HostOS) amiArchName(arch string) string {
+	pattern := h.AMINamePatterns[arch]
+	switch {
+	case arch == "x86_64" && strings.Contains(pattern, "amd64"):
+		return "amd64"
+	case arch == "arm64" && strings.Contains(pattern, "aarch64"):
+		return "aarch64"
+	}
+	return arch
+}
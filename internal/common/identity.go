@@ -0,0 +1,120 @@
+package common
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// UserEnv overrides the user name resources are namespaced by
+const UserEnv = "GEOSCHEM_USER"
+
+// identifyTimeout bounds the caller identity lookup, which only refines the user name
+const identifyTimeout = 10 * time.Second
+
+var (
+	identityMu     sync.Mutex
+	identifiedUser string
+)
+
+// CurrentUser names the person running the tools. Key pairs, instance names, Owner tags, the
+// local state ledger, queues and catalogs are namespaced by it, so people sharing an account
+// don't act on each other's resources. It is $GEOSCHEM_USER when set, the AWS caller
+// identity once IdentifyUser has resolved it, and otherwise the OS user.
+func CurrentUser() string {
+	if name := SanitizeUser(os.Getenv(UserEnv)); name != "" {
+		return name
+	}
+	identityMu.Lock()
+	name := identifiedUser
+	identityMu.Unlock()
+	if name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		if name := SanitizeUser(u.Username); name != "" {
+			return name
+		}
+	}
+	if name := SanitizeUser(os.Getenv("USER")); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// IdentifyUser looks up the AWS caller identity and makes CurrentUser return the name it
+// carries. People in a shared account often share an OS account name (ec2-user, a lab
+// workstation login) but not an IAM identity. When the lookup fails the OS user is kept.
+func IdentifyUser(ctx context.Context, cfg aws.Config) string {
+	identityMu.Lock()
+	resolved := identifiedUser != ""
+	identityMu.Unlock()
+	if resolved {
+		return CurrentUser()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, identifyTimeout)
+	defer cancel()
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err == nil {
+		if name := UserFromARN(aws.ToString(identity.Arn)); name != "" {
+			identityMu.Lock()
+			identifiedUser = name
+			identityMu.Unlock()
+		}
+	}
+	return CurrentUser()
+}
+
+// UserFromARN derives a user name from a caller identity ARN: the IAM user name, or the
+// session name of an assumed role, which SSO and most federation set to the person's login
+// or email address
+func UserFromARN(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 6 {
+		return ""
+	}
+	resource := strings.Split(parts[5], "/")
+	switch resource[0] {
+	case "user", "federated-user":
+		return SanitizeUser(resource[len(resource)-1])
+	case "assumed-role":
+		if len(resource) < 3 {
+			return ""
+		}
+		session := resource[2]
+		// Instance profiles name the session after the instance, not a person
+		if strings.HasPrefix(session, "i-") {
+			return ""
+		}
+		return SanitizeUser(session)
+	case "root":
+		return "root"
+	}
+	return ""
+}
+
+var unsafeUserChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// maxUserLength keeps names built from the user within EC2 and ECR limits
+const maxUserLength = 32
+
+// SanitizeUser reduces a login or email address to lower-case letters, digits and dashes,
+// safe in key pair names, Name tags and image tags
+func SanitizeUser(name string) string {
+	name, _, _ = strings.Cut(strings.ToLower(name), "@")
+	// Windows logins carry the domain: DOMAIN\user
+	name = name[strings.LastIndex(name, `\`)+1:]
+	name = strings.Trim(unsafeUserChars.ReplaceAllString(name, "-"), "-")
+	if len(name) > maxUserLength {
+		name = strings.TrimRight(name[:maxUserLength], "-")
+	}
+	return name
+}
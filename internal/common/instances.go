@@ -3,7 +3,9 @@ package common
 import (
     "context"
     "fmt"
+    "math"
     "sort"
+    "strings"
 
     "github.com/aws/aws-sdk-go-v2/service/ec2"
     "github.com/aws/aws-sdk-go-v2/aws"
@@ -18,34 +20,267 @@ type InstanceRecommendation struct {
     Architecture   string  // x86_64 or arm64
     UseCase        string  // Description of optimal use case
     CostEfficiency float64 // Lower is better (price per vCPU)
+    Processor      string  // CPU generation (e.g. graviton2, graviton3, icelake)
+    ImageVariant   string  // Optimization preset of the image that best matches the CPU
+    EFA            bool    // Elastic Fabric Adapter, for MPI traffic between nodes
+}
+
+// processorFamilies maps instance families to CPU generation and matching image tuning preset
+var processorFamilies = map[string][2]string{
+    "t3":    {"skylake", "portable"},
+    "c5":    {"skylake", "skylake"},
+    "m5":    {"skylake", "skylake"},
+    "r5":    {"skylake", "skylake"},
+    "c6i":   {"icelake", "icelake"},
+    "m6i":   {"icelake", "icelake"},
+    "r6i":   {"icelake", "icelake"},
+    "c7i":   {"sapphirerapids", "sapphirerapids"},
+    "c6a":   {"zen3", "zen3"},
+    "hpc6a": {"zen3", "zen3"},
+    "c7a":   {"zen4", "zen4"},
+    "hpc7a": {"zen4", "zen4"},
+    "t4g":   {"graviton2", "portable"},
+    "c6g":   {"graviton2", "graviton2"},
+    "m6g":   {"graviton2", "graviton2"},
+    "r6g":   {"graviton2", "graviton2"},
+    "c7g":   {"graviton3", "graviton3-sve"},
+    "m7g":   {"graviton3", "graviton3-sve"},
+    "r7g":   {"graviton3", "graviton3-sve"},
+    "hpc7g": {"graviton3", "graviton3-sve"},
+    "c8g":   {"graviton4", "graviton4-sve"},
+    "m8g":   {"graviton4", "graviton4-sve"},
+    "r8g":   {"graviton4", "graviton4-sve"},
+}
+
+// generationBonus scores newer Graviton generations, which have wider SIMD (SVE, SVE2)
+// and more memory bandwidth per core
+var generationBonus = map[string]float64{
+    "graviton3": 5,
+    "graviton4": 8,
+}
+
+// InstanceProcessor returns the CPU generation and matching image tuning preset for an instance type
+func InstanceProcessor(instanceType string) (string, string) {
+    family := strings.SplitN(instanceType, ".", 2)[0]
+    if info, ok := processorFamilies[family]; ok {
+        return info[0], info[1]
+    }
+    return "unknown", "portable"
 }
 
 // WorkloadProfile defines the characteristics of a GeosChem workload
 type WorkloadProfile struct {
-    GridResolution string  // "4x5", "2x2.5", "0.5x0.625"
-    SpeciesCount   int     // Number of chemical species
-    Duration       int     // Expected runtime in hours
-    BudgetPerHour  float64 // Maximum cost per hour
-    Priority       string  // "cost", "performance", "balanced"
-    Architecture   string  // "x86_64", "arm64", "any"
+    GridResolution string        // "4x5", "2x2.5", "0.5x0.625", or "C48"-"C360" for GCHP
+    SpeciesCount   int           // Number of chemical species
+    Duration       int           // Expected runtime in hours
+    BudgetPerHour  float64       // Maximum cost per hour (whole cluster for multi-node GCHP)
+    Priority       string        // "cost", "performance", "balanced"
+    Architecture   string        // "x86_64", "arm64", "any"
+    DisableSMT     bool          // Run with one thread per physical core
+    Mode           string        // "classic" (default) or "gchp"
+    Cores          int           // GCHP MPI processes (0 = minimum for the resolution)
+    MaxNodes       int           // GCHP nodes allowed (0 or 1 = single instance)
+    Nested         *NestedDomain // Classic nested-grid domain (nil = global)
+}
+
+// IsGCHP reports whether the workload runs GCHP on a cubed-sphere grid
+func (p WorkloadProfile) IsGCHP() bool {
+    return p.Mode == "gchp" || IsCubedSphere(p.GridResolution)
+}
+
+// Validate checks that the resolution, mode, and domain are consistent
+func (p WorkloadProfile) Validate() error {
+    if p.Mode != "" && p.Mode != "classic" && p.Mode != "gchp" {
+        return fmt.Errorf("unknown mode '%s' (expected classic or gchp)", p.Mode)
+    }
+    if p.IsGCHP() {
+        if !IsCubedSphere(p.GridResolution) {
+            return fmt.Errorf("GCHP requires a cubed-sphere resolution (C48-C360), got %s", p.GridResolution)
+        }
+        if p.Nested != nil {
+            return fmt.Errorf("nested domains are only supported for GeosChem Classic")
+        }
+        if p.Cores < 0 || p.MaxNodes < 0 {
+            return fmt.Errorf("cores and max nodes cannot be negative")
+        }
+        return nil
+    }
+    
+    if _, err := GridCells(p.GridResolution, p.Nested); err != nil {
+        return err
+    }
+    if p.Cores > 0 || p.MaxNodes > 1 {
+        return fmt.Errorf("core counts and multiple nodes apply to GCHP only; Classic runs on one instance")
+    }
+    return nil
+}
+
+// MinimumCores returns the cores a workload needs in total
+func (p WorkloadProfile) MinimumCores() int {
+    if p.IsGCHP() {
+        n, _ := CubedSphereSize(p.GridResolution)
+        // Practical minimum ranks per cube size, limited by memory per core
+        minCores := 6
+        switch {
+        case n > 180:
+            minCores = 360
+        case n > 90:
+            minCores = 96
+        case n > 48:
+            minCores = 24
+        case n > 24:
+            minCores = 12
+        }
+        if p.Cores > minCores {
+            return p.Cores
+        }
+        return minCores
+    }
+    
+    switch p.GridResolution {
+    case "4x5":
+        return 2 // Can run on 2 cores but 4 is better
+    case "2x2.5":
+        return 4 // Needs at least 4 cores
+    case "0.5x0.625", "0.25x0.3125":
+        if p.Nested != nil {
+            return 4 // Regional windows are much smaller than the global grid
+        }
+        return 8 // High-res needs more cores
+    default:
+        return 2 // Conservative default
+    }
+}
+
+// MinimumMemory returns the memory (GB) a workload needs in total
+func (p WorkloadProfile) MinimumMemory() float64 {
+    // Memory scales with number of species (roughly linear)
+    speciesMemory := float64(p.SpeciesCount) * 0.02 // 20 MB per species roughly
+    
+    if p.IsGCHP() || p.Nested != nil {
+        // Scale the 4x5 footprint by horizontal cell count; GCHP adds per-rank overhead
+        scale, err := CellsRelativeTo4x5(p.GridResolution, p.Nested)
+        if err != nil {
+            scale = 1
+        }
+        memory := (2.0 + speciesMemory) * scale
+        if memory < 2.0+speciesMemory {
+            memory = 2.0 + speciesMemory
+        }
+        if p.IsGCHP() {
+            memory += float64(p.MinimumCores()) * 1.0
+        }
+        return memory
+    }
+    
+    baseMemory := 2.0 // GB base requirement
+    
+    // Memory scales with grid resolution
+    switch p.GridResolution {
+    case "4x5":
+        baseMemory = 2.0
+    case "2x2.5": 
+        baseMemory = 4.0
+    case "0.5x0.625":
+        baseMemory = 8.0
+    case "0.25x0.3125":
+        baseMemory = 16.0
+    }
+    
+    return baseMemory + speciesMemory
+}
+
+// maxNodes returns the number of instances the workload may span
+func (p WorkloadProfile) maxNodes() int {
+    if !p.IsGCHP() || p.MaxNodes < 1 {
+        return 1
+    }
+    return p.MaxNodes
+}
+
+// NodesRequired returns how many instances of a type the workload needs
+func (p WorkloadProfile) NodesRequired(instance InstanceRecommendation) int {
+    cores := p.PhysicalCores(instance)
+    if cores == 0 || instance.Memory == 0 {
+        return 0
+    }
+    
+    nodes := (p.MinimumCores() + cores - 1) / cores
+    if byMemory := int(math.Ceil(p.MinimumMemory() / instance.Memory)); byMemory > nodes {
+        nodes = byMemory
+    }
+    if nodes < 1 {
+        nodes = 1
+    }
+    return nodes
+}
+
+// ClusterPricePerHour returns the hourly price of all instances the workload needs
+func (p WorkloadProfile) ClusterPricePerHour(instance InstanceRecommendation) float64 {
+    return instance.PricePerHour * float64(p.NodesRequired(instance))
+}
+
+// Description returns a short label for the workload
+func (p WorkloadProfile) Description() string {
+    switch {
+    case p.IsGCHP():
+        return fmt.Sprintf("GCHP %s (%d cores)", p.GridResolution, p.MinimumCores())
+    case p.Nested != nil:
+        return fmt.Sprintf("%s nested %s", p.GridResolution, p.Nested.Name)
+    default:
+        return p.GridResolution
+    }
+}
+
+// PhysicalCores returns the number of cores available to the workload on an instance.
+// Graviton and HPC instances have no SMT, so every vCPU is a physical core.
+func (p WorkloadProfile) PhysicalCores(instance InstanceRecommendation) int {
+    if p.DisableSMT && hasSMT(instance) {
+        return instance.VCPUs / 2
+    }
+    return instance.VCPUs
+}
+
+// hasSMT reports whether an instance runs two threads per core
+func hasSMT(instance InstanceRecommendation) bool {
+    return instance.Architecture == "x86_64" && !strings.HasPrefix(instance.InstanceType, "hpc")
+}
+
+// CPUOptions returns the launch CPU options for running this workload on an instance
+func (p WorkloadProfile) CPUOptions(instance InstanceRecommendation) *CPUOptions {
+    if !p.DisableSMT || !hasSMT(instance) {
+        return nil
+    }
+    return &CPUOptions{
+        CoreCount:      instance.VCPUs / 2,
+        ThreadsPerCore: 1,
+    }
 }
 
 // InstanceSelector handles intelligent instance type selection
 type InstanceSelector struct {
     ec2Client *ec2.Client
+    profile   string // For the Pricing API, which the platform calls through the AWS CLI
     region    string
+
+    StaticPricing bool // Use the built-in catalog instead of live prices and instance types
 }
 
 // NewInstanceSelector creates a new instance selector
-func NewInstanceSelector(cfg aws.Config, region string) *InstanceSelector {
+func NewInstanceSelector(cfg aws.Config, profile, region string) *InstanceSelector {
     return &InstanceSelector{
         ec2Client: ec2.NewFromConfig(cfg),
+        profile:   profile,
         region:    region,
     }
 }
 
 // GetRecommendations returns recommended instance types for a workload
 func (is *InstanceSelector) GetRecommendations(ctx context.Context, profile WorkloadProfile) ([]InstanceRecommendation, error) {
+    if err := profile.Validate(); err != nil {
+        return nil, fmt.Errorf("invalid workload: %w", err)
+    }
+
     // Get current pricing and availability
     instances, err := is.getAvailableInstances(ctx)
     if err != nil {
@@ -69,10 +304,73 @@ func (is *InstanceSelector) GetRecommendations(ctx context.Context, profile Work
     return recommendations[:maxResults], nil
 }
 
-// getAvailableInstances retrieves available instance types with current pricing
+// getAvailableInstances retrieves the region's instance types with current On-Demand
+// prices, falling back to the built-in catalog when the lookup fails
 func (is *InstanceSelector) getAvailableInstances(ctx context.Context) ([]InstanceRecommendation, error) {
-    // For now, return static data based on research
-    // In production, this would query EC2 pricing API
+    if is.StaticPricing {
+        return staticInstanceCatalog(), nil
+    }
+    instances, err := is.liveInstanceCatalog(ctx)
+    if err != nil {
+        fmt.Printf("Warning: live pricing unavailable, using built-in prices: %v\n", err)
+        return staticInstanceCatalog(), nil
+    }
+    return instances, nil
+}
+
+// InstanceCatalog returns every instance type the platform knows about
+func InstanceCatalog() []InstanceRecommendation {
+    return staticInstanceCatalog()
+}
+
+// LookupInstance returns catalog data (vCPUs, memory, price) for an instance type
+func LookupInstance(instanceType string) (*InstanceRecommendation, error) {
+    for _, instance := range staticInstanceCatalog() {
+        if instance.InstanceType == instanceType {
+            return &instance, nil
+        }
+    }
+    return nil, fmt.Errorf("instance type %s not in catalog", instanceType)
+}
+
+// NextLargerMemory returns the cheapest catalog instance with more memory than both the
+// given instance and minMemory (GB), on the same architecture and with at least as many
+// vCPUs, for rerunning a workload that ran out of memory
+func NextLargerMemory(instanceType string, minMemory float64) (*InstanceRecommendation, error) {
+    current, err := LookupInstance(instanceType)
+    if err != nil {
+        return nil, err
+    }
+    needed := math.Max(current.Memory, minMemory)
+
+    var next *InstanceRecommendation
+    for _, instance := range staticInstanceCatalog() {
+        if instance.Architecture != current.Architecture || instance.VCPUs < current.VCPUs || instance.Memory <= needed {
+            continue
+        }
+        if next == nil || instance.PricePerHour < next.PricePerHour ||
+            (instance.PricePerHour == next.PricePerHour && instance.Memory < next.Memory) {
+            candidate := instance
+            next = &candidate
+        }
+    }
+    if next == nil {
+        return nil, fmt.Errorf("no %s instance in the catalog has more than %.0f GB of memory", current.Architecture, needed)
+    }
+    return next, nil
+}
+
+// InstanceArchitecture returns the CPU architecture (x86_64 or arm64) of an instance type
+func InstanceArchitecture(instanceType string) string {
+    processor, _ := InstanceProcessor(instanceType)
+    if strings.HasPrefix(processor, "graviton") {
+        return "arm64"
+    }
+    return "x86_64"
+}
+
+// staticInstanceCatalog returns the built-in instance catalog
+func staticInstanceCatalog() []InstanceRecommendation {
     instances := []InstanceRecommendation{
         // Development tier
         {
@@ -125,6 +423,64 @@ func (is *InstanceSelector) getAvailableInstances(ctx context.Context) ([]Instan
             CostEfficiency: 0.034,
         },
         
+        // Standard tier - ARM64 (Graviton3, SVE)
+        {
+            InstanceType:    "c7g.xlarge",
+            VCPUs:          4,
+            Memory:         8.0,
+            PricePerHour:   0.145,
+            Architecture:   "arm64",
+            UseCase:        "Standard simulations on Graviton3 (use SVE-tuned images)",
+            CostEfficiency: 0.0363,
+        },
+        {
+            InstanceType:    "c7g.2xlarge",
+            VCPUs:          8,
+            Memory:         16.0,
+            PricePerHour:   0.29,
+            Architecture:   "arm64",
+            UseCase:        "High-resolution simulations on Graviton3 (use SVE-tuned images)",
+            CostEfficiency: 0.0363,
+        },
+        
+        // Standard tier - ARM64 (Graviton4, Neoverse-V2 with SVE2)
+        {
+            InstanceType:    "c8g.xlarge",
+            VCPUs:          4,
+            Memory:         8.0,
+            PricePerHour:   0.1595,
+            Architecture:   "arm64",
+            UseCase:        "Standard simulations on Graviton4 (use graviton4 images)",
+            CostEfficiency: 0.0399,
+        },
+        {
+            InstanceType:    "c8g.2xlarge",
+            VCPUs:          8,
+            Memory:         16.0,
+            PricePerHour:   0.319,
+            Architecture:   "arm64",
+            UseCase:        "High-resolution simulations on Graviton4 (use graviton4 images)",
+            CostEfficiency: 0.0399,
+        },
+        {
+            InstanceType:    "c8g.4xlarge",
+            VCPUs:          16,
+            Memory:         32.0,
+            PricePerHour:   0.6381,
+            Architecture:   "arm64",
+            UseCase:        "Large-scale parallel simulations on Graviton4",
+            CostEfficiency: 0.0399,
+        },
+        {
+            InstanceType:    "m8g.2xlarge",
+            VCPUs:          8,
+            Memory:         32.0,
+            PricePerHour:   0.359,
+            Architecture:   "arm64",
+            UseCase:        "High-resolution simulations with many species on Graviton4",
+            CostEfficiency: 0.0449,
+        },
+        
         // Memory-optimized tier - x86_64
         {
             InstanceType:    "r5.2xlarge",
@@ -146,6 +502,26 @@ func (is *InstanceSelector) getAvailableInstances(ctx context.Context) ([]Instan
             UseCase:        "Memory-intensive simulations - 20% cost savings",
             CostEfficiency: 0.050,
         },
+        {
+            InstanceType:    "r8g.2xlarge",
+            VCPUs:          8,
+            Memory:         64.0,
+            PricePerHour:   0.4713,
+            Architecture:   "arm64",
+            UseCase:        "Memory-intensive simulations on Graviton4",
+            CostEfficiency: 0.0589,
+        },
+        
+        // Standard tier - AMD EPYC (Genoa), best with AOCC/AOCL images
+        {
+            InstanceType:    "c7a.2xlarge",
+            VCPUs:          8,
+            Memory:         16.0,
+            PricePerHour:   0.4106,
+            Architecture:   "x86_64",
+            UseCase:        "High-resolution simulations on AMD EPYC (use aocc-aocl images)",
+            CostEfficiency: 0.0513,
+        },
         
         // High-performance tier
         {
@@ -157,9 +533,45 @@ func (is *InstanceSelector) getAvailableInstances(ctx context.Context) ([]Instan
             UseCase:        "Large-scale parallel simulations",
             CostEfficiency: 0.0425,
         },
+        {
+            InstanceType:    "hpc7a.12xlarge",
+            VCPUs:          24,
+            Memory:         768.0,
+            PricePerHour:   7.20,
+            Architecture:   "x86_64",
+            UseCase:        "HPC-optimized AMD EPYC for large runs (use aocc-aocl images)",
+            CostEfficiency: 0.30,
+            EFA:            true,
+        },
+        
+        // Multi-node tier - EFA between nodes for GCHP
+        {
+            InstanceType:    "hpc6a.48xlarge",
+            VCPUs:          96,
+            Memory:         384.0,
+            PricePerHour:   2.88,
+            Architecture:   "x86_64",
+            UseCase:        "Multi-node GCHP on AMD EPYC Milan with EFA",
+            CostEfficiency: 0.03,
+            EFA:            true,
+        },
+        {
+            InstanceType:    "hpc7g.16xlarge",
+            VCPUs:          64,
+            Memory:         128.0,
+            PricePerHour:   1.6832,
+            Architecture:   "arm64",
+            UseCase:        "Multi-node GCHP on Graviton3 with EFA (use SVE-tuned images)",
+            CostEfficiency: 0.0263,
+            EFA:            true,
+        },
     }
 
-    return instances, nil
+    for i := range instances {
+        instances[i].Processor, instances[i].ImageVariant = InstanceProcessor(instances[i].InstanceType)
+    }
+
+    return instances
 }
 
 // scoreInstances filters and scores instances based on workload profile
@@ -173,7 +585,7 @@ func (is *InstanceSelector) scoreInstances(instances []InstanceRecommendation, p
         }
         
         // Filter by budget
-        if profile.BudgetPerHour > 0 && instance.PricePerHour > profile.BudgetPerHour {
+        if profile.BudgetPerHour > 0 && profile.ClusterPricePerHour(instance) > profile.BudgetPerHour {
             continue
         }
         
@@ -190,55 +602,17 @@ func (is *InstanceSelector) scoreInstances(instances []InstanceRecommendation, p
 
 // meetsMinimumRequirements checks if instance meets minimum workload requirements
 func (is *InstanceSelector) meetsMinimumRequirements(instance InstanceRecommendation, profile WorkloadProfile) bool {
-    // Minimum vCPU requirements based on grid resolution
-    minVCPUs := is.getMinimumVCPUs(profile.GridResolution)
-    if instance.VCPUs < minVCPUs {
-        return false
-    }
-    
-    // Minimum memory requirements based on species count
-    minMemory := is.getMinimumMemory(profile.SpeciesCount, profile.GridResolution)
-    if instance.Memory < minMemory {
+    // HPC instances are built for MPI across nodes; Classic's OpenMP can't use them
+    if !profile.IsGCHP() && strings.HasPrefix(instance.InstanceType, "hpc") {
         return false
     }
     
-    return true
-}
-
-// getMinimumVCPUs returns minimum vCPUs for a given grid resolution
-func (is *InstanceSelector) getMinimumVCPUs(gridResolution string) int {
-    switch gridResolution {
-    case "4x5":
-        return 2 // Can run on 2 cores but 4 is better
-    case "2x2.5":
-        return 4 // Needs at least 4 cores
-    case "0.5x0.625", "0.25x0.3125":
-        return 8 // High-res needs more cores
-    default:
-        return 2 // Conservative default
-    }
-}
-
-// getMinimumMemory returns minimum memory (GB) for workload characteristics
-func (is *InstanceSelector) getMinimumMemory(speciesCount int, gridResolution string) float64 {
-    baseMemory := 2.0 // GB base requirement
-    
-    // Memory scales with grid resolution
-    switch gridResolution {
-    case "4x5":
-        baseMemory = 2.0
-    case "2x2.5": 
-        baseMemory = 4.0
-    case "0.5x0.625":
-        baseMemory = 8.0
-    case "0.25x0.3125":
-        baseMemory = 16.0
+    // Multi-node GCHP can spread cores and memory across instances; Classic must fit on one
+    nodes := profile.NodesRequired(instance)
+    if nodes > 1 && !instance.EFA {
+        return false // MPI halo exchanges over TCP leave the extra nodes waiting
     }
-    
-    // Memory scales with number of species (roughly linear)
-    speciesMemory := float64(speciesCount) * 0.02 // 20 MB per species roughly
-    
-    return baseMemory + speciesMemory
+    return nodes >= 1 && nodes <= profile.maxNodes()
 }
 
 // calculateScore calculates a suitability score for an instance
@@ -249,7 +623,7 @@ func (is *InstanceSelector) calculateScore(instance InstanceRecommendation, prof
     switch profile.Priority {
     case "cost":
         // Lower cost is better
-        score -= instance.PricePerHour * 100
+        score -= profile.ClusterPricePerHour(instance) * 100
         // Bonus for ARM64 cost savings
         if instance.Architecture == "arm64" {
             score += 20
@@ -258,6 +632,7 @@ func (is *InstanceSelector) calculateScore(instance InstanceRecommendation, prof
     case "performance":
         // More vCPUs is better
         score += float64(instance.VCPUs) * 5
+        score += generationBonus[instance.Processor]
         // Penalize memory-optimized if not needed
         memoryRatio := instance.Memory / float64(instance.VCPUs)
         if memoryRatio > 4 && profile.SpeciesCount < 200 {
@@ -267,24 +642,30 @@ func (is *InstanceSelector) calculateScore(instance InstanceRecommendation, prof
     case "balanced":
     default:
         // Balance cost and performance
-        score -= instance.PricePerHour * 50
+        score -= profile.ClusterPricePerHour(instance) * 50
         score += float64(instance.VCPUs) * 2
         if instance.Architecture == "arm64" {
             score += 10 // Moderate bonus for ARM64
         }
+        // A newer generation finishes the same run sooner for a similar hourly price
+        score += generationBonus[instance.Processor]
     }
     
     // Penalize over-provisioning
-    minVCPUs := is.getMinimumVCPUs(profile.GridResolution)
-    if instance.VCPUs > minVCPUs*3 {
+    nodes := float64(profile.NodesRequired(instance))
+    if float64(instance.VCPUs)*nodes > float64(profile.MinimumCores()*3) {
         score -= 15 // Likely over-provisioned
     }
     
-    minMemory := is.getMinimumMemory(profile.SpeciesCount, profile.GridResolution)
-    if instance.Memory > minMemory*2 {
+    if instance.Memory*nodes > profile.MinimumMemory()*2 {
         score -= 10 // Memory over-provisioned
     }
     
+    // Fewer nodes means less MPI traffic over the network
+    if nodes > 1 {
+        score -= (nodes - 1) * 5
+    }
+    
     return score
 }
 
@@ -294,7 +675,7 @@ func FormatRecommendations(recommendations []InstanceRecommendation, profile Wor
         return "No suitable instances found for your requirements."
     }
     
-    result := fmt.Sprintf("💡 Instance Recommendations for %s simulation:\n\n", profile.GridResolution)
+    result := fmt.Sprintf("💡 Instance Recommendations for %s simulation:\n\n", profile.Description())
     
     for i, rec := range recommendations {
         rank := ""
@@ -316,7 +697,11 @@ func FormatRecommendations(recommendations []InstanceRecommendation, profile Wor
             rec.VCPUs, rec.Memory, rec.Architecture)
         result += fmt.Sprintf("   💰 $%.3f/hour ($%.2f/day)\n", 
             rec.PricePerHour, costPerDay)
+        if nodes := profile.NodesRequired(rec); nodes > 1 {
+            result += fmt.Sprintf("   🖧  %d nodes over EFA: $%.3f/hour for the cluster\n", nodes, profile.ClusterPricePerHour(rec))
+        }
         result += fmt.Sprintf("   📋 %s\n", rec.UseCase)
+        result += fmt.Sprintf("   🔧 %s CPU, use %s-tuned image\n", rec.Processor, rec.ImageVariant)
         result += "\n"
     }
     
@@ -0,0 +1,102 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SourcePatch is a change applied to the source after it's cloned and before the build, to
+// try a fix without forking: a local patch file or a GitHub pull request
+type SourcePatch struct {
+	File        string `yaml:"file"`            // Patch from git diff or git format-patch
+	PullRequest string `yaml:"pr"`              // 123 in repo, or owner/name#123
+	Path        string `yaml:"path"`            // Checkout directory it applies in, e.g. src/GEOS-Chem (default: the top)
+	Diff        string `yaml:"-" json:"-"`      // File's contents, read before building
+	SHA256      string `yaml:"-" json:"sha256"` // Hash of File's contents, fingerprinted and recorded in image labels
+}
+
+// pullRequestPattern matches owner/name#123, #123 and 123
+var pullRequestPattern = regexp.MustCompile(`^(?:([\w.-]+/[\w.-]+))?#?(\d+)$`)
+
+// githubRepoPattern matches GitHub clone URLs over HTTPS and SSH
+var githubRepoPattern = regexp.MustCompile(`^(?:https://github\.com/|git@github\.com:|ssh://git@github\.com/)([\w.-]+/[\w.-]+?)(?:\.git)?/?$`)
+
+// GitHubRepo returns the owner/name of a GitHub clone URL
+func GitHubRepo(url string) (string, bool) {
+	match := githubRepoPattern.FindStringSubmatch(url)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// PullRequestRef resolves the patch's pull request to its owner/name and number; a bare
+// number refers to repo
+func (p SourcePatch) PullRequestRef(repo string) (string, int, error) {
+	match := pullRequestPattern.FindStringSubmatch(strings.TrimSpace(p.PullRequest))
+	if match == nil {
+		return "", 0, fmt.Errorf("pull request '%s' must be 123 or owner/name#123", p.PullRequest)
+	}
+	number, _ := strconv.Atoi(match[2])
+	if match[1] != "" {
+		return match[1], number, nil
+	}
+	name, ok := GitHubRepo(repo)
+	if !ok {
+		return "", 0, fmt.Errorf("pull request %s needs owner/name#%d, since %s isn't on GitHub", p.PullRequest, number, repo)
+	}
+	return name, number, nil
+}
+
+// Label describes the patch in image labels: pr:geoschem/geos-chem#2345, whose diff's hash
+// the build host appends, or file:wetdep.patch@sha256:0123456789ab
+func (p SourcePatch) Label(repo string) string {
+	if p.PullRequest != "" {
+		name, number, _ := p.PullRequestRef(repo)
+		return fmt.Sprintf("pr:%s#%d", name, number)
+	}
+	return "file:" + path.Base(p.File) + "@sha256:" + p.SHA256[:min(12, len(p.SHA256))]
+}
+
+// ParsePatches reads patches written [path=]patch,...: a patch file, or a pull request as
+// 123 or owner/name#123, applied in the checkout directory path (default: the top)
+func ParsePatches(spec, repo string) ([]SourcePatch, error) {
+	var patches []SourcePatch
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		path, patch, ok := strings.Cut(entry, "=")
+		if !ok {
+			path, patch = "", entry
+		}
+		if pullRequestPattern.MatchString(patch) {
+			patches = append(patches, SourcePatch{PullRequest: patch, Path: path})
+		} else {
+			patches = append(patches, SourcePatch{File: patch, Path: path})
+		}
+	}
+	return patches, ValidatePatches(patches, repo)
+}
+
+// ValidatePatches checks that each patch is a file or a pull request, in a directory
+// relative to the checkout
+func ValidatePatches(patches []SourcePatch, repo string) error {
+	for _, patch := range patches {
+		if (patch.File == "") == (patch.PullRequest == "") {
+			return fmt.Errorf("each patch needs exactly one of file or pr")
+		}
+		if strings.HasPrefix(patch.Path, "/") || strings.HasPrefix(patch.Path, "-") ||
+			slices.Contains(strings.Split(patch.Path, "/"), "..") {
+			return fmt.Errorf("patch path '%s' must be relative to the checkout", patch.Path)
+		}
+		if patch.PullRequest != "" {
+			if _, _, err := patch.PullRequestRef(repo); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
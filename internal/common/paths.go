@@ -0,0 +1,82 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// HomeEnv overrides the directory holding local keys and state
+const HomeEnv = "GEOSCHEM_AWS_HOME"
+
+// legacyDirName is the directory in the home directory used before per-OS locations
+const legacyDirName = ".geoschem-aws"
+
+// DataDir returns the per-user directory for generated keys and local state. It is
+// $GEOSCHEM_AWS_HOME when set, ~/.geoschem-aws when an earlier install created it, and
+// otherwise geoschem-aws in the OS config directory: ~/.config on Linux,
+// ~/Library/Application Support on macOS, and %AppData% on Windows.
+func DataDir() (string, error) {
+	if dir := os.Getenv(HomeEnv); dir != "" {
+		return dir, nil
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		legacy := filepath.Join(home, legacyDirName)
+		if info, err := os.Stat(legacy); err == nil && info.IsDir() {
+			return legacy, nil
+		}
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("locating user config directory (set %s to choose one): %w", HomeEnv, err)
+	}
+	return filepath.Join(configDir, "geoschem-aws"), nil
+}
+
+// PrivateDir returns a DataDir subdirectory, created readable only by the user
+func PrivateDir(name string) (string, error) {
+	base, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("creating %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// ExpandHome expands a leading ~ in a path. On Windows "~\.ssh\key.pem" works as well as
+// "~/.ssh/key.pem".
+func ExpandHome(path string) (string, error) {
+	prefixed := strings.HasPrefix(path, "~/") || (runtime.GOOS == "windows" && strings.HasPrefix(path, `~\`))
+	if path != "~" && !prefixed {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("expanding %s: %w", path, err)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// CheckPrivateKeyPermissions rejects a private key other users can read, which OpenSSH
+// refuses to use. Windows has no mode bits: keys there are protected by the ACLs of the
+// user's profile directory, so the check is skipped.
+func CheckPrivateKeyPermissions(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	if mode := info.Mode().Perm(); mode&0077 != 0 {
+		return fmt.Errorf("private key %s is accessible by other users (mode %04o); run: chmod 600 %s", path, mode, path)
+	}
+	return nil
+}
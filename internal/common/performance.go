@@ -0,0 +1,81 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PerformanceRecordVersion is the schema version of shared benchmark records
+const PerformanceRecordVersion = 1
+
+// PerformanceRecord is one anonymized benchmark measurement in the shared performance dataset.
+// It deliberately carries no account, registry, region, or user information.
+type PerformanceRecord struct {
+	SchemaVersion    int     `json:"schema_version"`
+	InstanceType     string  `json:"instance_type"`
+	Architecture     string  `json:"architecture"`
+	Processor        string  `json:"processor,omitempty"`
+	ImageConfig      string  `json:"image_config"` // Image tag without registry or repository, e.g. gcc13-x86_64-openmpi
+	Simulation       string  `json:"simulation"`
+	Resolution       string  `json:"resolution"`
+	ModelDays        float64 `json:"model_days"`
+	WallClockSeconds float64 `json:"wall_clock_seconds"`
+	ModelDaysPerDay  float64 `json:"model_days_per_day"`
+	RecordedDate     string  `json:"recorded_date"` // YYYY-MM-DD only
+	// Node usage sampled during the run; zero when not measured
+	PeakMemoryPercent float64 `json:"peak_memory_percent,omitempty"`
+	CPUPercent        float64 `json:"cpu_percent,omitempty"` // Mean share of CPU time busy
+}
+
+// Validate checks that a record is complete enough to be used for scoring
+func (r *PerformanceRecord) Validate() error {
+	if r.InstanceType == "" || r.ImageConfig == "" {
+		return fmt.Errorf("instance type and image config are required")
+	}
+	if r.Simulation == "" || r.Resolution == "" {
+		return fmt.Errorf("simulation and resolution are required")
+	}
+	if r.ModelDaysPerDay <= 0 {
+		return fmt.Errorf("model days per day must be positive")
+	}
+	return nil
+}
+
+// ImageConfigName strips the registry and repository from an image reference,
+// leaving only the tag that identifies the build configuration
+func ImageConfigName(image string) string {
+	name := image
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// LoadPerformanceRecords reads newline-delimited JSON records, skipping invalid entries
+func LoadPerformanceRecords(r io.Reader) ([]PerformanceRecord, error) {
+	var records []PerformanceRecord
+
+	decoder := json.NewDecoder(r)
+	for {
+		var record PerformanceRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return records, fmt.Errorf("decoding performance record: %w", err)
+		}
+		if record.SchemaVersion != PerformanceRecordVersion || record.Validate() != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,262 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fallback throughput when no benchmark data applies: model days per wall-clock day
+// for one core running fullchem at 4x5
+const baselineDaysPerDayPerCore = 12.0
+
+// memoryPressurePercent is the peak memory use at which measured runs count as memory-bound
+const memoryPressurePercent = 90.0
+
+// parallelEfficiencyExponent models the sublinear speedup from adding cores
+const parallelEfficiencyExponent = 0.85
+
+// simulationCost is the relative compute cost of each simulation type, fullchem = 1
+var simulationCost = map[string]float64{
+	"fullchem":         1.0,
+	"aerosol":          0.5,
+	"Hg":               0.3,
+	"tagO3":            0.2,
+	"tagCO":            0.15,
+	"CH4":              0.1,
+	"CO2":              0.1,
+	"TransportTracers": 0.1,
+}
+
+// PredictionRequest describes a simulation to estimate
+type PredictionRequest struct {
+	Simulation string
+	Workload   WorkloadProfile // Resolution, mode, nested domain and SMT setting
+	ModelDays  float64
+}
+
+// Prediction is the estimated wall-clock time and cost of a simulation on one instance type
+type Prediction struct {
+	InstanceType     string
+	Nodes            int
+	ModelDaysPerDay  float64
+	WallClock        time.Duration
+	Cost             float64 // USD for the whole simulation
+	CostPerModelYear float64
+	Basis            string // How the throughput was derived
+	Samples          int    // Benchmark records behind the estimate
+}
+
+// Predictor estimates simulation throughput from collected benchmark records
+type Predictor struct {
+	records []PerformanceRecord
+}
+
+// NewPredictor creates a predictor over the given benchmark records
+func NewPredictor(records []PerformanceRecord) *Predictor {
+	return &Predictor{records: records}
+}
+
+// Predict estimates wall-clock time and cost of a request on an instance type.
+// Measurements for the same instance, simulation and resolution are used directly;
+// otherwise the closest measurements are scaled by grid size, simulation cost and
+// core count, falling back to a built-in model when no records apply.
+func (p *Predictor) Predict(request PredictionRequest, instance InstanceRecommendation) (*Prediction, error) {
+	if request.ModelDays <= 0 {
+		return nil, fmt.Errorf("model days must be positive")
+	}
+	if err := request.Workload.Validate(); err != nil {
+		return nil, err
+	}
+
+	nodes := request.Workload.NodesRequired(instance)
+	if nodes < 1 || nodes > request.Workload.maxNodes() {
+		return nil, fmt.Errorf("%s cannot run %s", instance.InstanceType, request.Workload.Description())
+	}
+	cores := request.Workload.PhysicalCores(instance) * nodes
+
+	throughput, basis, samples := p.throughput(request, instance, cores)
+	if throughput <= 0 {
+		return nil, fmt.Errorf("no throughput estimate for %s", instance.InstanceType)
+	}
+
+	if p.memoryBound(instance.InstanceType, request.Simulation, request.Workload.GridResolution) {
+		basis += ", memory-bound: consider more memory"
+	}
+
+	wallClockHours := request.ModelDays / throughput * 24
+	pricePerHour := request.Workload.ClusterPricePerHour(instance)
+
+	return &Prediction{
+		InstanceType:     instance.InstanceType,
+		Nodes:            nodes,
+		ModelDaysPerDay:  throughput,
+		WallClock:        time.Duration(wallClockHours * float64(time.Hour)),
+		Cost:             pricePerHour * wallClockHours,
+		CostPerModelYear: pricePerHour * 24 / throughput * 365,
+		Basis:            basis,
+		Samples:          samples,
+	}, nil
+}
+
+// Rank predicts every instance and orders them by cost per model year, cheapest first.
+// Instances that cannot run the workload are skipped.
+func (p *Predictor) Rank(request PredictionRequest, instances []InstanceRecommendation) []Prediction {
+	var predictions []Prediction
+	for _, instance := range instances {
+		prediction, err := p.Predict(request, instance)
+		if err != nil {
+			continue
+		}
+		predictions = append(predictions, *prediction)
+	}
+
+	sort.Slice(predictions, func(i, j int) bool {
+		return predictions[i].CostPerModelYear < predictions[j].CostPerModelYear
+	})
+	return predictions
+}
+
+// throughput returns model days per day, a description of how it was derived, and the sample count
+func (p *Predictor) throughput(request PredictionRequest, instance InstanceRecommendation, cores int) (float64, string, int) {
+	resolution := request.Workload.GridResolution
+	work := relativeWork(request.Simulation, request.Workload)
+
+	// Exact measurements carry everything the model can't (memory bandwidth, I/O, compiler)
+	if values := p.matching(instance.InstanceType, request.Simulation, resolution); len(values) > 0 && request.Workload.Nested == nil {
+		return median(values), "measured", len(values)
+	}
+
+	// Same instance type, different simulation or grid: scale by relative work
+	var scaled []float64
+	for _, record := range p.records {
+		if record.InstanceType != instance.InstanceType {
+			continue
+		}
+		if recordWork := relativeWork(record.Simulation, WorkloadProfile{GridResolution: record.Resolution}); recordWork > 0 {
+			scaled = append(scaled, record.ModelDaysPerDay*recordWork/work)
+		}
+	}
+	if len(scaled) > 0 {
+		return median(scaled), "scaled from same instance", len(scaled)
+	}
+
+	// Same processor family: scale by core count as well
+	for _, record := range p.records {
+		if record.Processor == "" || record.Processor != instance.Processor {
+			continue
+		}
+		other, err := LookupInstance(record.InstanceType)
+		if err != nil || other.VCPUs == 0 {
+			continue
+		}
+		recordWork := relativeWork(record.Simulation, WorkloadProfile{GridResolution: record.Resolution})
+		if recordWork <= 0 {
+			continue
+		}
+		coreScale := math.Pow(float64(cores)/float64(other.VCPUs), parallelEfficiencyExponent)
+		scaled = append(scaled, record.ModelDaysPerDay*recordWork/work*coreScale)
+	}
+	if len(scaled) > 0 {
+		return median(scaled), fmt.Sprintf("scaled from %s instances", instance.Processor), len(scaled)
+	}
+
+	return baselineDaysPerDayPerCore * math.Pow(float64(cores), parallelEfficiencyExponent) / work, "estimated (no benchmark data)", 0
+}
+
+// matching returns throughput of records for exactly this instance, simulation and resolution
+func (p *Predictor) matching(instanceType, simulation, resolution string) []float64 {
+	var values []float64
+	for _, record := range p.records {
+		if record.InstanceType == instanceType && record.Simulation == simulation && record.Resolution == resolution {
+			values = append(values, record.ModelDaysPerDay)
+		}
+	}
+	return values
+}
+
+// memoryBound reports whether a measured run of this instance, simulation and resolution
+// nearly exhausted memory, so its throughput may include swapping or a risk of OOM
+func (p *Predictor) memoryBound(instanceType, simulation, resolution string) bool {
+	for _, record := range p.records {
+		if record.InstanceType == instanceType && record.Simulation == simulation && record.Resolution == resolution &&
+			record.PeakMemoryPercent >= memoryPressurePercent {
+			return true
+		}
+	}
+	return false
+}
+
+// relativeWork returns the compute per model day relative to fullchem at global 4x5.
+// Finer grids need more cells and proportionally shorter time steps.
+func relativeWork(simulation string, workload WorkloadProfile) float64 {
+	cells, err := CellsRelativeTo4x5(workload.GridResolution, workload.Nested)
+	if err != nil {
+		return 0
+	}
+
+	cost, ok := simulationCost[simulation]
+	if !ok {
+		cost = 1.0
+	}
+
+	return cells * timeStepFactor(workload.GridResolution) * cost
+}
+
+// timeStepFactor returns how many more time steps per model day a grid needs than 4x5
+func timeStepFactor(resolution string) float64 {
+	if n, err := CubedSphereSize(resolution); err == nil {
+		// C24 is comparable to 4x5; time steps shorten with cell size
+		return math.Max(1, float64(n)/24)
+	}
+	dlat, _, err := latLonSpacing(resolution)
+	if err != nil {
+		return 1
+	}
+	return math.Max(1, 4/dlat)
+}
+
+// median returns the median of values
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// FormatPredictions renders predictions as a table ranked by cost per model year
+func FormatPredictions(predictions []Prediction, request PredictionRequest) string {
+	if len(predictions) == 0 {
+		return "No instance types can run this workload\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📈 Predicted cost for %s %s, %.0f model days (ranked by cost per model year):\n\n",
+		request.Simulation, request.Workload.Description(), request.ModelDays)
+	fmt.Fprintf(&b, "   %-16s %5s %12s %12s %10s %14s  %s\n", "INSTANCE", "NODES", "DAYS/DAY", "WALL CLOCK", "COST", "$/MODEL YEAR", "BASIS")
+	for _, prediction := range predictions {
+		fmt.Fprintf(&b, "   %-16s %5d %12.1f %12s %10s %14s  %s\n",
+			prediction.InstanceType,
+			prediction.Nodes,
+			prediction.ModelDaysPerDay,
+			FormatWallClock(prediction.WallClock),
+			fmt.Sprintf("$%.2f", prediction.Cost),
+			fmt.Sprintf("$%.2f", prediction.CostPerModelYear),
+			prediction.Basis)
+	}
+	return b.String()
+}
+
+// FormatWallClock renders a duration, in days and hours for long runs
+func FormatWallClock(d time.Duration) string {
+	if d >= 48*time.Hour {
+		days := int(d.Hours()) / 24
+		return fmt.Sprintf("%dd%dh", days, int(d.Hours())-days*24)
+	}
+	return d.Round(time.Minute).String()
+}
@@ -0,0 +1,181 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+)
+
+// pricingRegion hosts the Pricing API endpoint; it returns prices for every region
+const pricingRegion = "us-east-1"
+
+// liveInstanceCatalog returns the instance types of the known families that the region
+// offers, with their current On-Demand Linux prices
+func (is *InstanceSelector) liveInstanceCatalog(ctx context.Context) ([]InstanceRecommendation, error) {
+	offered, err := is.describeInstanceTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := onDemandPrices(ctx, is.profile, is.region)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the hand-written use cases for the types the built-in catalog covers
+	useCases := make(map[string]string)
+	for _, instance := range staticInstanceCatalog() {
+		useCases[instance.InstanceType] = instance.UseCase
+	}
+
+	var instances []InstanceRecommendation
+	for _, instance := range offered {
+		price, ok := prices[instance.InstanceType]
+		if !ok || price == 0 {
+			continue // Not sold On-Demand in the region
+		}
+		instance.PricePerHour = price
+		instance.CostEfficiency = price / float64(instance.VCPUs)
+		instance.Processor, instance.ImageVariant = InstanceProcessor(instance.InstanceType)
+		instance.UseCase = useCases[instance.InstanceType]
+		if instance.UseCase == "" {
+			instance.UseCase = familyUseCase(instance.InstanceType, instance.Processor)
+		}
+		instances = append(instances, instance)
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("no priced instance types found in %s", is.region)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].InstanceType < instances[j].InstanceType })
+	return instances, nil
+}
+
+// describeInstanceTypes lists the region's current-generation, virtualized instance types
+// in the families the platform has tuned images for
+func (is *InstanceSelector) describeInstanceTypes(ctx context.Context) ([]InstanceRecommendation, error) {
+	var patterns []string
+	for family := range processorFamilies {
+		patterns = append(patterns, family+".*")
+	}
+	sort.Strings(patterns)
+
+	paginator := ec2.NewDescribeInstanceTypesPaginator(is.ec2Client, &ec2.DescribeInstanceTypesInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("instance-type"), Values: patterns},
+			{Name: aws.String("bare-metal"), Values: []string{"false"}},
+		},
+	})
+	var instances []InstanceRecommendation
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("describing instance types: %w", err)
+		}
+		for _, info := range page.InstanceTypes {
+			if info.VCpuInfo == nil || info.MemoryInfo == nil || info.ProcessorInfo == nil {
+				continue
+			}
+			architecture := ""
+			for _, supported := range info.ProcessorInfo.SupportedArchitectures {
+				if supported == ec2types.ArchitectureTypeArm64 || supported == ec2types.ArchitectureTypeX8664 {
+					architecture = string(supported)
+				}
+			}
+			if architecture == "" {
+				continue
+			}
+			instances = append(instances, InstanceRecommendation{
+				InstanceType: string(info.InstanceType),
+				VCPUs:        int(aws.ToInt32(info.VCpuInfo.DefaultVCpus)),
+				Memory:       float64(aws.ToInt64(info.MemoryInfo.SizeInMiB)) / 1024,
+				Architecture: architecture,
+				EFA:          info.NetworkInfo != nil && aws.ToBool(info.NetworkInfo.EfaSupported),
+			})
+		}
+	}
+	return instances, nil
+}
+
+// onDemandPrices returns the hourly On-Demand price of every Linux instance type in a
+// region, from the Pricing API
+func onDemandPrices(ctx context.Context, profile, region string) (map[string]float64, error) {
+	var out struct {
+		PriceList []string `json:"PriceList"` // Each product is a JSON document in a string
+	}
+	filter := func(field, value string) string {
+		return fmt.Sprintf("Type=TERM_MATCH,Field=%s,Value=%s", field, value)
+	}
+	if err := awscli.New(profile, pricingRegion).Run(ctx, &out, "pricing", "get-products",
+		"--service-code", "AmazonEC2",
+		"--filters",
+		filter("regionCode", region),
+		filter("operatingSystem", "Linux"),
+		filter("tenancy", "Shared"),
+		filter("preInstalledSw", "NA"),
+		filter("licenseModel", "No License required"),
+		filter("capacitystatus", "Used")); err != nil {
+		return nil, fmt.Errorf("reading EC2 prices: %w", err)
+	}
+
+	prices := make(map[string]float64)
+	for _, document := range out.PriceList {
+		var product struct {
+			Product struct {
+				Attributes struct {
+					InstanceType string `json:"instanceType"`
+				} `json:"attributes"`
+			} `json:"product"`
+			Terms struct {
+				OnDemand map[string]struct {
+					PriceDimensions map[string]struct {
+						Unit         string            `json:"unit"`
+						PricePerUnit map[string]string `json:"pricePerUnit"`
+					} `json:"priceDimensions"`
+				} `json:"OnDemand"`
+			} `json:"terms"`
+		}
+		if err := json.Unmarshal([]byte(document), &product); err != nil {
+			return nil, fmt.Errorf("parsing EC2 price: %w", err)
+		}
+		for _, term := range product.Terms.OnDemand {
+			for _, dimension := range term.PriceDimensions {
+				if dimension.Unit != "Hrs" {
+					continue
+				}
+				if price, err := strconv.ParseFloat(dimension.PricePerUnit["USD"], 64); err == nil {
+					prices[product.Product.Attributes.InstanceType] = price
+				}
+			}
+		}
+	}
+	return prices, nil
+}
+
+// familyUseCase describes an instance type the built-in catalog doesn't cover by its family
+func familyUseCase(instanceType, processor string) string {
+	family := strings.SplitN(instanceType, ".", 2)[0]
+	cpu := processor
+	if strings.HasPrefix(processor, "graviton") {
+		cpu = "Graviton" + strings.TrimPrefix(processor, "graviton")
+	}
+	switch {
+	case strings.HasPrefix(family, "hpc"):
+		return fmt.Sprintf("HPC-optimized %s with EFA for large runs", cpu)
+	case strings.HasPrefix(family, "t"):
+		return "Development and testing"
+	case strings.HasPrefix(family, "r"):
+		return fmt.Sprintf("Memory-intensive simulations on %s", cpu)
+	case strings.HasPrefix(family, "m"):
+		return fmt.Sprintf("Simulations with many species on %s", cpu)
+	default:
+		return fmt.Sprintf("Compute-bound simulations on %s", cpu)
+	}
+}
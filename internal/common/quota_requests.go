@@ -0,0 +1,105 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/aws/aws-sdk-go-v2/service/support"
+	"github.com/aws/smithy-go"
+)
+
+// supportRegion is where the AWS Support API is served for the commercial partition
+const supportRegion = "us-east-1"
+
+// SupportCaseURL opens a new service limit increase case in the Support Center
+const SupportCaseURL = "https://support.console.aws.amazon.com/support/home#/case/create?issueType=service-limit-increase"
+
+// ErrSupportPlanRequired means the account's support plan doesn't include the Support API:
+// Basic and Developer plans can only open cases in the console
+var ErrSupportPlanRequired = errors.New("the AWS Support API needs a Business, Enterprise On-Ramp, or Enterprise support plan")
+
+// IncreaseRequest is a submitted quota increase
+type IncreaseRequest struct {
+	Via    string // "Service Quotas" or "AWS Support"
+	ID     string // Service Quotas request ID or support case ID
+	Status string
+	CaseID string // Support case opened for the request, if any
+}
+
+// SupportAPIAvailable reports whether the account's support plan allows creating cases
+// through the API. Basic and Developer plans don't, so non-adjustable limits must be raised
+// in the Support Center.
+func (qc *QuotaChecker) SupportAPIAvailable(ctx context.Context) (bool, error) {
+	_, err := qc.supportClient.DescribeSeverityLevels(ctx, &support.DescribeSeverityLevelsInput{})
+	if isSubscriptionRequired(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("checking support plan: %w", err)
+	}
+	return true, nil
+}
+
+// RequestIncrease asks for a quota to be raised to desired. Adjustable quotas go through
+// Service Quotas; others need a support case, which the Support API can only open on
+// Business and Enterprise support plans. On other plans the error is ErrSupportPlanRequired
+// with a link to open the case in the console.
+func (qc *QuotaChecker) RequestIncrease(ctx context.Context, serviceCode, quotaCode string, desired float64, justification string) (*IncreaseRequest, error) {
+	quota, err := qc.getQuota(ctx, serviceCode, quotaCode)
+	if err != nil {
+		return nil, err
+	}
+	if quota.Value != nil && desired <= *quota.Value {
+		return nil, fmt.Errorf("%s is already %.0f; request more than that", aws.ToString(quota.QuotaName), *quota.Value)
+	}
+
+	if quota.Adjustable {
+		result, err := qc.quotasClient.RequestServiceQuotaIncrease(ctx, &servicequotas.RequestServiceQuotaIncreaseInput{
+			ServiceCode:  aws.String(serviceCode),
+			QuotaCode:    aws.String(quotaCode),
+			DesiredValue: aws.Float64(desired),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("requesting increase of %s: %w", aws.ToString(quota.QuotaName), err)
+		}
+		change := result.RequestedQuota
+		return &IncreaseRequest{
+			Via:    "Service Quotas",
+			ID:     aws.ToString(change.Id),
+			Status: string(change.Status),
+			CaseID: aws.ToString(change.CaseId),
+		}, nil
+	}
+
+	subject := fmt.Sprintf("Limit increase: %s (%s) in %s", aws.ToString(quota.QuotaName), quotaCode, qc.region)
+	body := fmt.Sprintf("Please raise %s (%s, quota code %s) in %s to %.0f.\n\n%s",
+		aws.ToString(quota.QuotaName), aws.ToString(quota.ServiceName), quotaCode, qc.region, desired, justification)
+	result, err := qc.supportClient.CreateCase(ctx, &support.CreateCaseInput{
+		Subject:           aws.String(subject),
+		CommunicationBody: aws.String(body),
+		ServiceCode:       aws.String("service-limit-increase"),
+		IssueType:         aws.String("customer-service"),
+		SeverityCode:      aws.String("low"),
+	})
+	if isSubscriptionRequired(err) {
+		return nil, fmt.Errorf("%s is not adjustable through Service Quotas and %w; open the case at %s", aws.ToString(quota.QuotaName), ErrSupportPlanRequired, SupportCaseURL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening support case for %s: %w", aws.ToString(quota.QuotaName), err)
+	}
+	return &IncreaseRequest{
+		Via:    "AWS Support",
+		ID:     aws.ToString(result.CaseId),
+		Status: "opened",
+		CaseID: aws.ToString(result.CaseId),
+	}, nil
+}
+
+// isSubscriptionRequired reports whether a Support API call failed for lack of a support plan
+func isSubscriptionRequired(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "SubscriptionRequiredException"
+}
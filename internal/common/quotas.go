@@ -25,6 +25,8 @@ type QuotaChecker struct {
 type QuotaStatus struct {
     ServiceName   string
     QuotaName     string
+    ServiceCode   string // Service Quotas codes, for requesting increases
+    QuotaCode     string
     Current       float64
     Limit         float64
     Usage         float64
@@ -45,7 +47,9 @@ func NewQuotaChecker(cfg aws.Config, region string) *QuotaChecker {
     return &QuotaChecker{
         quotasClient:  servicequotas.NewFromConfig(cfg),
         ec2Client:     ec2.NewFromConfig(cfg),
-        supportClient: support.NewFromConfig(cfg),
+        supportClient: support.NewFromConfig(cfg, func(o *support.Options) {
+            o.Region = supportRegion // The Support API is only served from one region
+        }),
         region:        region,
     }
 }
@@ -88,43 +92,27 @@ func (qc *QuotaChecker) CheckGeoChemQuotas(ctx context.Context) (*QuotaReport, e
 func (qc *QuotaChecker) checkEC2Quotas(ctx context.Context) ([]QuotaStatus, error) {
     quotas := make([]QuotaStatus, 0)
 
-    // Check Running On-Demand instances
-    onDemandQuota, err := qc.getQuota(ctx, "ec2", "L-1216C47A") // Running On-Demand instances
+    // Check Running On-Demand Standard instances, a vCPU quota
+    limit, used, err := qc.StandardVCPUUsage(ctx)
     if err != nil {
-        return nil, fmt.Errorf("getting on-demand quota: %w", err)
+        return nil, err
     }
-
-    // Get current usage
-    instances, err := qc.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
-        Filters: []ec2types.Filter{
-            {
-                Name:   aws.String("instance-state-name"),
-                Values: []string{"running", "pending"},
-            },
-        },
-    })
+    onDemandQuota, err := qc.getQuota(ctx, "ec2", standardVCPUQuota)
     if err != nil {
-        return nil, fmt.Errorf("describing instances: %w", err)
-    }
-
-    runningInstances := 0
-    for _, reservation := range instances.Reservations {
-        runningInstances += len(reservation.Instances)
+        return nil, fmt.Errorf("getting on-demand quota: %w", err)
     }
 
-    quotaValue := float64(0)
-    if onDemandQuota.Value != nil {
-        quotaValue = *onDemandQuota.Value
-    }
-    status := qc.evaluateQuotaStatus(float64(runningInstances), quotaValue)
+    status := qc.evaluateQuotaStatus(float64(used), float64(limit))
     quotas = append(quotas, QuotaStatus{
         ServiceName: "EC2",
-        QuotaName:   "Running On-Demand Instances",
-        Current:     float64(runningInstances),
-        Limit:       quotaValue,
-        Usage:       (float64(runningInstances) / quotaValue) * 100,
+        QuotaName:   "Running On-Demand Standard vCPUs",
+        ServiceCode: "ec2",
+        QuotaCode:   standardVCPUQuota,
+        Current:     float64(used),
+        Limit:       float64(limit),
+        Usage:       (float64(used) / float64(limit)) * 100,
         Status:      status,
-        Message:     qc.getQuotaMessage("EC2 instances", status, float64(runningInstances), quotaValue),
+        Message:     qc.getQuotaMessage("EC2 standard vCPUs", status, float64(used), float64(limit)),
         CanIncrease: onDemandQuota.Adjustable,
     })
 
@@ -142,6 +130,8 @@ func (qc *QuotaChecker) checkEC2Quotas(ctx context.Context) ([]QuotaStatus, erro
             quotas = append(quotas, QuotaStatus{
                 ServiceName: "EC2",
                 QuotaName:   "Key Pairs",
+                ServiceCode: "ec2",
+                QuotaCode:   "L-7C0D3F92",
                 Current:     float64(keyPairCount),
                 Limit:       keyPairQuotaValue,
                 Usage:       (float64(keyPairCount) / keyPairQuotaValue) * 100,
@@ -155,6 +145,67 @@ func (qc *QuotaChecker) checkEC2Quotas(ctx context.Context) ([]QuotaStatus, erro
     return quotas, nil
 }
 
+// standardVCPUQuota is Running On-Demand Standard (A, C, D, H, I, M, R, T, Z) instances,
+// counted in vCPUs
+const standardVCPUQuota = "L-1216C47A"
+
+// StandardVCPUUsage returns the Running On-Demand Standard vCPU quota and the vCPUs running
+// and pending instances use against it
+func (qc *QuotaChecker) StandardVCPUUsage(ctx context.Context) (limit, used int, err error) {
+    quota, err := qc.getQuota(ctx, "ec2", standardVCPUQuota)
+    if err != nil {
+        return 0, 0, fmt.Errorf("getting on-demand quota: %w", err)
+    }
+    if quota.Value != nil {
+        limit = int(*quota.Value)
+    }
+
+    paginator := ec2.NewDescribeInstancesPaginator(qc.ec2Client, &ec2.DescribeInstancesInput{
+        Filters: []ec2types.Filter{
+            {
+                Name:   aws.String("instance-state-name"),
+                Values: []string{"running", "pending"},
+            },
+        },
+    })
+    for paginator.HasMorePages() {
+        page, err := paginator.NextPage(ctx)
+        if err != nil {
+            return 0, 0, fmt.Errorf("describing instances: %w", err)
+        }
+        for _, reservation := range page.Reservations {
+            for _, instance := range reservation.Instances {
+                // Spot instances count against their own quota
+                if instance.InstanceLifecycle == ec2types.InstanceLifecycleTypeSpot || !IsStandardInstance(string(instance.InstanceType)) {
+                    continue
+                }
+                if instance.CpuOptions != nil && instance.CpuOptions.CoreCount != nil && instance.CpuOptions.ThreadsPerCore != nil {
+                    used += int(*instance.CpuOptions.CoreCount * *instance.CpuOptions.ThreadsPerCore)
+                }
+            }
+        }
+    }
+    return limit, used, nil
+}
+
+// nonStandardPrefixes are families whose first letter is a standard one but that have
+// their own vCPU quotas
+var nonStandardPrefixes = []string{"hpc", "inf", "dl", "trn", "mac"}
+
+// IsStandardInstance reports whether an instance type counts against the standard
+// (A, C, D, H, I, M, R, T, Z) On-Demand vCPU quota
+func IsStandardInstance(instanceType string) bool {
+    if instanceType == "" {
+        return false
+    }
+    for _, prefix := range nonStandardPrefixes {
+        if strings.HasPrefix(instanceType, prefix) {
+            return false
+        }
+    }
+    return strings.ContainsRune("acdhimrtz", rune(instanceType[0]))
+}
+
 // checkECRQuotas checks ECR-related quotas
 func (qc *QuotaChecker) checkECRQuotas(ctx context.Context) ([]QuotaStatus, error) {
     quotas := make([]QuotaStatus, 0)
@@ -174,6 +225,8 @@ func (qc *QuotaChecker) checkECRQuotas(ctx context.Context) ([]QuotaStatus, erro
     quotas = append(quotas, QuotaStatus{
         ServiceName: "ECR",
         QuotaName:   "Repositories per Region",
+        ServiceCode: "ecr",
+        QuotaCode:   "L-CFEB8E8D",
         Current:     1, // We need at least 1 for geoschem
         Limit:       repoQuotaValue,
         Usage:       (1.0 / repoQuotaValue) * 100,
@@ -202,6 +255,8 @@ func (qc *QuotaChecker) checkBatchQuotas(ctx context.Context) ([]QuotaStatus, er
     quotas = append(quotas, QuotaStatus{
         ServiceName: "Batch",
         QuotaName:   "Compute Environments per Region",
+        ServiceCode: "batch",
+        QuotaCode:   "L-D8F0C5EA",
         Current:     1, // We need at least 1
         Limit:       computeEnvQuotaValue,
         Usage:       (1.0 / computeEnvQuotaValue) * 100,
@@ -306,7 +361,7 @@ func (qr *QuotaReport) PrintReport() {
         fmt.Printf("   Usage: %.0f/%.0f (%.1f%%)\n", quota.Current, quota.Limit, quota.Usage)
         fmt.Printf("   %s\n", quota.Message)
         if quota.CanIncrease && (quota.Status == "WARNING" || quota.Status == "CRITICAL") {
-            fmt.Printf("   💡 This quota can be increased via AWS Support case\n")
+            fmt.Printf("   💡 Request an increase with: -request-quota-increase -service %s -quota-code %s -desired-value N\n", quota.ServiceCode, quota.QuotaCode)
         }
         fmt.Println()
     }
@@ -0,0 +1,168 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Right-sizing actions
+const (
+	SizingKeep     = "keep"
+	SizingUpsize   = "upsize"
+	SizingDownsize = "downsize"
+)
+
+// sizingMemoryHeadroom is the slack kept above a workload's measured peak memory
+const sizingMemoryHeadroom = 1.15
+
+// sizingTargetCPUPercent is the mean CPU use a right-sized instance should reach
+const sizingTargetCPUPercent = 70.0
+
+// idleCPUPercent is the mean CPU use below which an instance counts as oversized
+const idleCPUPercent = 50.0
+
+// SizingRecord is one run's measured use of its instance and the right-sizing suggestion
+// it led to, kept so later instance choices for the workload improve
+type SizingRecord struct {
+	Simulation        string    `json:"simulation"`
+	Resolution        string    `json:"resolution"`
+	NestedDomain      string    `json:"nested_domain,omitempty"`
+	InstanceType      string    `json:"instance_type"`
+	PeakMemoryPercent float64   `json:"peak_memory_percent"`
+	MeanCPUPercent    float64   `json:"mean_cpu_percent"`
+	PeakCPUPercent    float64   `json:"peak_cpu_percent"`
+	Action            string    `json:"action"`                   // SizingKeep, SizingUpsize or SizingDownsize
+	SuggestedType     string    `json:"suggested_type,omitempty"` // Instance to use instead, if any
+	Reason            string    `json:"reason"`
+	RecordedAt        time.Time `json:"recorded_at"`
+}
+
+// PeakMemoryGB is the memory the run used at its peak
+func (r SizingRecord) PeakMemoryGB() float64 {
+	instance, err := LookupInstance(r.InstanceType)
+	if err != nil {
+		return 0
+	}
+	return instance.Memory * r.PeakMemoryPercent / 100
+}
+
+// SuggestSize compares a run's measured peak memory and mean CPU use to its instance and
+// suggests the cheapest catalog instance on the same architecture that fits the workload
+// with headroom: larger when memory ran short, smaller when the instance sat mostly idle.
+func SuggestSize(instanceType string, peakMemoryPercent, meanCPUPercent float64) (SizingRecord, error) {
+	current, err := LookupInstance(instanceType)
+	if err != nil {
+		return SizingRecord{}, err
+	}
+	record := SizingRecord{
+		InstanceType:      instanceType,
+		PeakMemoryPercent: peakMemoryPercent,
+		MeanCPUPercent:    meanCPUPercent,
+		Action:            SizingKeep,
+	}
+	neededMemory := current.Memory * peakMemoryPercent / 100 * sizingMemoryHeadroom
+
+	switch {
+	case peakMemoryPercent >= memoryPressurePercent:
+		// Keep the cores; the run was short of memory, not compute
+		record.Action = SizingUpsize
+		record.Reason = fmt.Sprintf("memory peaked at %.0f%% of %.0f GB", peakMemoryPercent, current.Memory)
+		if next, err := NextLargerMemory(instanceType, neededMemory); err == nil {
+			record.SuggestedType = next.InstanceType
+		}
+	case meanCPUPercent > 0 && meanCPUPercent < idleCPUPercent:
+		neededVCPUs := max(1, int(math.Ceil(float64(current.VCPUs)*meanCPUPercent/sizingTargetCPUPercent)))
+		smaller := cheapestFit(current.Architecture, neededVCPUs, neededMemory)
+		if smaller != nil && smaller.PricePerHour < current.PricePerHour {
+			record.Action = SizingDownsize
+			record.SuggestedType = smaller.InstanceType
+			record.Reason = fmt.Sprintf("CPU averaged %.0f%% of %d vCPUs and memory peaked at %.0f GB",
+				meanCPUPercent, current.VCPUs, current.Memory*peakMemoryPercent/100)
+		} else {
+			record.Reason = fmt.Sprintf("CPU averaged %.0f%%, but no cheaper instance fits the memory", meanCPUPercent)
+		}
+	default:
+		record.Reason = fmt.Sprintf("CPU averaged %.0f%% and memory peaked at %.0f%%", meanCPUPercent, peakMemoryPercent)
+	}
+	return record, nil
+}
+
+// cheapestFit returns the cheapest catalog instance of the architecture with at least the
+// given vCPUs and memory (GB), or nil
+func cheapestFit(architecture string, vcpus int, memory float64) *InstanceRecommendation {
+	var best *InstanceRecommendation
+	for _, instance := range staticInstanceCatalog() {
+		if instance.Architecture != architecture || instance.VCPUs < vcpus || instance.Memory < memory {
+			continue
+		}
+		if best == nil || instance.PricePerHour < best.PricePerHour {
+			candidate := instance
+			best = &candidate
+		}
+	}
+	return best
+}
+
+// SizingMemoryFloor is the memory (GB) a workload needs with headroom, from the highest peak
+// its past runs reached; 0 without measurements
+func SizingMemoryFloor(records []SizingRecord) float64 {
+	peak := 0.0
+	for _, record := range records {
+		peak = math.Max(peak, record.PeakMemoryGB())
+	}
+	return peak * sizingMemoryHeadroom
+}
+
+// FormatSizingSuggestions renders what past runs of a workload measured on each instance
+// type and the suggestion each type's latest run led to. Recommendations with less memory
+// than the workload has needed are called out.
+func FormatSizingSuggestions(records []SizingRecord, recommendations []InstanceRecommendation) string {
+	if len(records) == 0 {
+		return ""
+	}
+
+	byType := make(map[string][]SizingRecord)
+	for _, record := range records {
+		byType[record.InstanceType] = append(byType[record.InstanceType], record)
+	}
+	types := make([]string, 0, len(byType))
+	for instanceType := range byType {
+		types = append(types, instanceType)
+	}
+	sort.Strings(types)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📐 Right-sizing from %d measured runs of this workload:\n\n", len(records))
+	fmt.Fprintf(&b, "   %-16s %4s %12s %9s  %s\n", "INSTANCE", "RUNS", "PEAK MEMORY", "MEAN CPU", "SUGGESTION")
+	for _, instanceType := range types {
+		runs := byType[instanceType]
+		peakMemory, meanCPU := 0.0, 0.0
+		for _, run := range runs {
+			peakMemory = math.Max(peakMemory, run.PeakMemoryGB())
+			meanCPU += run.MeanCPUPercent / float64(len(runs))
+		}
+		latest := runs[len(runs)-1]
+		suggestion := latest.Action
+		if latest.SuggestedType != "" {
+			suggestion += " to " + latest.SuggestedType
+		}
+		fmt.Fprintf(&b, "   %-16s %4d %12s %8.0f%%  %s (%s)\n", instanceType, len(runs),
+			fmt.Sprintf("%.1f GB", peakMemory), meanCPU, suggestion, latest.Reason)
+	}
+
+	if floor := SizingMemoryFloor(records); floor > 0 {
+		var tooSmall []string
+		for _, recommendation := range recommendations {
+			if recommendation.Memory < floor {
+				tooSmall = append(tooSmall, recommendation.InstanceType)
+			}
+		}
+		if len(tooSmall) > 0 {
+			fmt.Fprintf(&b, "\n   ⚠️  Runs needed %.0f GB with headroom; too little memory: %s\n", floor, strings.Join(tooSmall, ", "))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// StorageOption describes a place to keep run directories and output
+type StorageOption struct {
+	Name           string
+	Shared         bool    // Mountable by several instances at once
+	ThroughputMBps float64 // Typical sustained throughput per client
+	CostPerGBMonth float64 // Storage price (USD)
+	MinimumGB      float64 // Smallest billable size
+	Tradeoff       string
+}
+
+// storageOptions are the supported storage choices for run directories and output (us-west-2 pricing)
+var storageOptions = []StorageOption{
+	{
+		Name:           "ebs",
+		Shared:         false,
+		ThroughputMBps: 125,
+		CostPerGBMonth: 0.08,
+		Tradeoff:       "Cheapest and lowest latency, but tied to one instance; output must be copied to S3 before termination",
+	},
+	{
+		Name:           "efs",
+		Shared:         true,
+		ThroughputMBps: 500,
+		CostPerGBMonth: 0.30,
+		Tradeoff:       "Shared across instances and runs with no capacity planning; slower for many small files and billed per GB transferred in elastic mode",
+	},
+	{
+		Name:           "fsx-lustre",
+		Shared:         true,
+		ThroughputMBps: 200 * 1.2,
+		CostPerGBMonth: 0.14,
+		MinimumGB:      1200,
+		Tradeoff:       "Highest parallel throughput for multi-node GCHP, but 1.2 TB minimum and must be created and deleted around campaigns",
+	},
+}
+
+// efsTransferCostPerGB is the elastic-throughput charge for data written to EFS
+const efsTransferCostPerGB = 0.06
+
+// StorageOptions returns the supported storage options
+func StorageOptions() []StorageOption {
+	return storageOptions
+}
+
+// MonthlyCost estimates the monthly cost of keeping outputGB of run data on this option
+func (o StorageOption) MonthlyCost(outputGB float64) float64 {
+	billed := math.Max(outputGB, o.MinimumGB)
+	cost := billed * o.CostPerGBMonth
+	if o.Name == "efs" {
+		// Writing the output once is billed on top of storage
+		cost += outputGB * efsTransferCostPerGB
+	}
+	return cost
+}
+
+// FormatStorageTradeoffs renders the storage options for a workload producing outputGB of data
+func FormatStorageTradeoffs(profile WorkloadProfile, outputGB float64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🗄️  Storage for run directories and output (%.0f GB):\n\n", outputGB)
+
+	for _, option := range storageOptions {
+		shared := "single instance"
+		if option.Shared {
+			shared = "shared"
+		}
+		fmt.Fprintf(&b, "   %-11s ~%.0f MB/s, %s, ~$%.2f/month\n", option.Name, option.ThroughputMBps, shared, option.MonthlyCost(outputGB))
+		fmt.Fprintf(&b, "               %s\n", option.Tradeoff)
+	}
+
+	switch {
+	case profile.maxNodes() > 1:
+		b.WriteString("\n   💡 Multi-node GCHP needs shared storage; use fsx-lustre unless output is small\n")
+	case outputGB > 0:
+		b.WriteString("\n   💡 EFS suits groups sharing run directories without Lustre throughput needs\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WaitsConfig tunes how long builds wait on EC2 and on the instance. Durations are Go
+// durations ("90s", "10m"). Unset values use defaults that scale with the instance size,
+// since metal and very large instances take several times longer to boot.
+type WaitsConfig struct {
+	InstanceRunning time.Duration `yaml:"instance_running"` // Launch until EC2 reports running (default 5m)
+	SSHAttempts     int           `yaml:"ssh_attempts"`     // SSH connection attempts (default 30)
+	SSHInterval     time.Duration `yaml:"ssh_interval"`     // Pause between SSH attempts (default 10s)
+	UserData        time.Duration `yaml:"user_data"`        // SSH reachable until user data finishes (default 15m)
+	SSMAgent        time.Duration `yaml:"ssm_agent"`        // Launch until the SSM agent is online (default 10m)
+	RebootDelay     time.Duration `yaml:"reboot_delay"`     // Reboot until reconnecting starts (default 30s)
+	Termination     time.Duration `yaml:"termination"`      // Terminate until EC2 reports terminated (default 5m)
+}
+
+// defaultWaits suit the 2xlarge-class instances builds usually run on
+var defaultWaits = WaitsConfig{
+	InstanceRunning: 5 * time.Minute,
+	SSHAttempts:     30,
+	SSHInterval:     10 * time.Second,
+	UserData:        15 * time.Minute,
+	SSMAgent:        10 * time.Minute,
+	RebootDelay:     30 * time.Second,
+	Termination:     5 * time.Minute,
+}
+
+// Validate rejects negative waits
+func (w WaitsConfig) Validate() error {
+	durations := map[string]time.Duration{
+		"instance_running": w.InstanceRunning,
+		"ssh_interval":     w.SSHInterval,
+		"user_data":        w.UserData,
+		"ssm_agent":        w.SSMAgent,
+		"reboot_delay":     w.RebootDelay,
+		"termination":      w.Termination,
+	}
+	for name, d := range durations {
+		if d < 0 {
+			return fmt.Errorf("%s cannot be negative: %s", name, d)
+		}
+	}
+	if w.SSHAttempts < 0 {
+		return fmt.Errorf("ssh_attempts cannot be negative: %d", w.SSHAttempts)
+	}
+	return nil
+}
+
+// For returns the waits for an instance type: configured values as set, and defaults
+// scaled by how slowly instances of its size boot
+func (w WaitsConfig) For(instanceType string) WaitsConfig {
+	scale := bootScale(instanceType)
+	duration := func(configured, fallback time.Duration) time.Duration {
+		if configured > 0 {
+			return configured
+		}
+		return fallback * time.Duration(scale)
+	}
+
+	resolved := WaitsConfig{
+		InstanceRunning: duration(w.InstanceRunning, defaultWaits.InstanceRunning),
+		SSHAttempts:     w.SSHAttempts,
+		SSHInterval:     w.SSHInterval,
+		UserData:        duration(w.UserData, defaultWaits.UserData),
+		SSMAgent:        duration(w.SSMAgent, defaultWaits.SSMAgent),
+		RebootDelay:     duration(w.RebootDelay, defaultWaits.RebootDelay),
+		Termination:     duration(w.Termination, defaultWaits.Termination),
+	}
+	// Slow boots take more attempts, not longer pauses between them
+	if resolved.SSHAttempts == 0 {
+		resolved.SSHAttempts = defaultWaits.SSHAttempts * scale
+	}
+	if resolved.SSHInterval == 0 {
+		resolved.SSHInterval = defaultWaits.SSHInterval
+	}
+	return resolved
+}
+
+// bootScale is how many times longer than a 2xlarge an instance size takes to boot: metal
+// instances run firmware checks, and the largest sizes initialize far more memory
+func bootScale(instanceType string) int {
+	_, size, _ := strings.Cut(instanceType, ".")
+	if strings.HasPrefix(size, "metal") {
+		return 3
+	}
+	if n, err := strconv.Atoi(strings.TrimSuffix(size, "xlarge")); err == nil && n >= 16 {
+		return 2
+	}
+	return 1
+}
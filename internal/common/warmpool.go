@@ -0,0 +1,153 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WarmPoolConfig keeps a Batch compute environment's minimum vCPUs up during working hours,
+// so test runs start on instances that are already running instead of scaling from zero.
+// Outside the window the minimum drops back to zero and idle instances are released.
+type WarmPoolConfig struct {
+	ComputeEnvironment string `yaml:"compute_environment"` // Managed EC2 compute environment behind runs.batch.job_queue
+	MinVCPUs           int    `yaml:"min_vcpus"`           // Minimum vCPUs during working hours
+	Days               string `yaml:"days"`                // Working days, e.g. MON-FRI or MON,WED,FRI (default MON-FRI)
+	Start              string `yaml:"start"`               // Start of working hours, HH:MM (default 08:00)
+	End                string `yaml:"end"`                 // End of working hours, HH:MM (default 18:00)
+	Timezone           string `yaml:"timezone"`            // IANA time zone of the schedule (default UTC)
+	SchedulerRoleARN   string `yaml:"scheduler_role_arn"`  // Role EventBridge Scheduler assumes to update the compute environment
+}
+
+var weekdays = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+
+// Enabled reports whether a warm pool is configured
+func (w WarmPoolConfig) Enabled() bool {
+	return w.ComputeEnvironment != ""
+}
+
+// WithDefaults fills in weekday working hours in UTC
+func (w WarmPoolConfig) WithDefaults() WarmPoolConfig {
+	if w.Days == "" {
+		w.Days = "MON-FRI"
+	}
+	if w.Start == "" {
+		w.Start = "08:00"
+	}
+	if w.End == "" {
+		w.End = "18:00"
+	}
+	if w.Timezone == "" {
+		w.Timezone = "UTC"
+	}
+	return w
+}
+
+// Validate checks the schedule and that the scheduler can act on it
+func (w WarmPoolConfig) Validate() error {
+	if !w.Enabled() {
+		return nil
+	}
+	w = w.WithDefaults()
+	if w.MinVCPUs <= 0 {
+		return fmt.Errorf("warm_pool.min_vcpus must be positive, got %d", w.MinVCPUs)
+	}
+	if w.SchedulerRoleARN == "" {
+		return fmt.Errorf("warm_pool requires scheduler_role_arn")
+	}
+	if _, err := w.days(); err != nil {
+		return err
+	}
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return fmt.Errorf("warm_pool.start: %w", err)
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return fmt.Errorf("warm_pool.end: %w", err)
+	}
+	if end <= start {
+		return fmt.Errorf("warm_pool.end %s must be after start %s", w.End, w.Start)
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return fmt.Errorf("warm_pool.timezone: %w", err)
+	}
+	return nil
+}
+
+// StartCron is the EventBridge Scheduler expression that raises the minimum
+func (w WarmPoolConfig) StartCron() string {
+	return w.cron(w.WithDefaults().Start)
+}
+
+// StopCron is the EventBridge Scheduler expression that drops the minimum to zero
+func (w WarmPoolConfig) StopCron() string {
+	return w.cron(w.WithDefaults().End)
+}
+
+func (w WarmPoolConfig) cron(clock string) string {
+	minutes, _ := parseClock(clock)
+	return fmt.Sprintf("cron(%d %d ? * %s *)", minutes%60, minutes/60, strings.ToUpper(w.WithDefaults().Days))
+}
+
+// Active reports whether t falls within working hours, when the minimum should be up
+func (w WarmPoolConfig) Active(t time.Time) bool {
+	w = w.WithDefaults()
+	location, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return false
+	}
+	days, err := w.days()
+	if err != nil {
+		return false
+	}
+	start, _ := parseClock(w.Start)
+	end, _ := parseClock(w.End)
+
+	local := t.In(location)
+	minutes := local.Hour()*60 + local.Minute()
+	return days[local.Weekday()] && minutes >= start && minutes < end
+}
+
+// days parses the working days, a comma-separated list of names or ranges like MON-FRI
+func (w WarmPoolConfig) days() (map[time.Weekday]bool, error) {
+	index := func(name string) (int, error) {
+		for i, day := range weekdays {
+			if strings.EqualFold(name, day) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("warm_pool.days: unknown day '%s' (expected SUN through SAT)", name)
+	}
+
+	days := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(w.Days, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, err := index(from)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if isRange {
+			if last, err = index(to); err != nil {
+				return nil, err
+			}
+			if last < first {
+				return nil, fmt.Errorf("warm_pool.days: range %s runs backwards", part)
+			}
+		}
+		for day := first; day <= last; day++ {
+			days[time.Weekday(day)] = true
+		}
+	}
+	return days, nil
+}
+
+// parseClock returns minutes after midnight for HH:MM
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s' (expected HH:MM)", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,234 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HEMCOInput is a data file a HEMCO_Config.rc reads, as a key pattern under HEMCO/ in
+// SourceBucket. Time tokens ($YYYY, $MM, $DD, $HH) are left in the pattern.
+type HEMCOInput struct {
+	Pattern   string
+	FirstYear int // Years the file covers; HEMCO uses the nearest for years outside them
+	LastYear  int // 0 when the config doesn't bound them
+}
+
+// hemcoRootVar is the HEMCO_Config.rc setting holding the HEMCO data directory
+const hemcoRootVar = "$ROOT/"
+
+// hemcoSwitch matches an extension switch line, e.g. "111  GFED  : on  NO/CO/..."
+var hemcoSwitch = regexp.MustCompile(`^\d+\s+(\S+)\s*:\s*(on|off)\b`)
+
+// ReadHEMCOConfig returns the HEMCO data files a HEMCO_Config.rc reads. Sections between
+// (((NAME and )))NAME are skipped when NAME is an extension or --> option the config turns
+// off; names it doesn't set are kept, so nothing a run may read is left out.
+func ReadHEMCOConfig(r io.Reader) ([]HEMCOInput, error) {
+	settings := make(map[string]string)
+	enabled := make(map[string]bool)
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+
+		switch fields := strings.Fields(line); {
+		case fields[0] == "-->" && len(fields) >= 4 && fields[2] == ":":
+			enabled[strings.ToLower(fields[1])] = strings.EqualFold(fields[3], "true")
+		case hemcoSwitch.MatchString(line):
+			match := hemcoSwitch.FindStringSubmatch(line)
+			enabled[strings.ToLower(match[1])] = match[2] == "on"
+		case len(fields) == 2 && strings.HasSuffix(fields[0], ":"):
+			settings[strings.TrimSuffix(fields[0], ":")] = fields[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading HEMCO config: %w", err)
+	}
+
+	var inputs []HEMCOInput
+	seen := make(map[string]bool)
+	var sections []bool // Whether each enclosing section is on
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "((("):
+			sections = append(sections, sectionEnabled(strings.TrimPrefix(line, "((("), enabled))
+			continue
+		case strings.HasPrefix(line, ")))"):
+			if len(sections) > 0 {
+				sections = sections[:len(sections)-1]
+			}
+			continue
+		}
+		if !allEnabled(sections) {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 5 || !strings.HasPrefix(fields[2], hemcoRootVar) {
+			continue
+		}
+		pattern := expandSettings(strings.TrimPrefix(fields[2], hemcoRootVar), settings)
+		if seen[pattern] {
+			continue
+		}
+		seen[pattern] = true
+		input := HEMCOInput{Pattern: pattern}
+		input.FirstYear, input.LastYear = timestampYears(fields[4])
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+// sectionEnabled evaluates a section name such as GFED, .not.CEDS or CEDS.or.EDGAR
+func sectionEnabled(name string, enabled map[string]bool) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, alternative := range strings.Split(name, ".or.") {
+		negate := strings.HasPrefix(alternative, ".not.")
+		on, known := enabled[strings.TrimPrefix(alternative, ".not.")]
+		if !known || on != negate {
+			return true
+		}
+	}
+	return false
+}
+
+func allEnabled(sections []bool) bool {
+	for _, on := range sections {
+		if !on {
+			return false
+		}
+	}
+	return true
+}
+
+// hemcoTimeTokens are expanded per time step by HEMCO, not from settings
+var hemcoTimeTokens = []string{"$YYYY", "$MM", "$DD", "$HH", "$MN"}
+
+// expandSettings replaces $NAME with the config's settings, leaving time tokens. Longer
+// names go first, so $METDIR isn't taken for $MET followed by DIR.
+func expandSettings(path string, settings map[string]string) string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for _, name := range names {
+		if !isTimeToken("$" + name) {
+			path = strings.ReplaceAll(path, "$"+name, settings[name])
+		}
+	}
+	return path
+}
+
+func isTimeToken(token string) bool {
+	for _, t := range hemcoTimeTokens {
+		if token == t {
+			return true
+		}
+	}
+	return false
+}
+
+// timestampYears reads the year range of a HEMCO SrcTime field such as 1980-2019/1-12/1/0
+func timestampYears(srcTime string) (int, int) {
+	years, _, _ := strings.Cut(srcTime, "/")
+	first, last, isRange := strings.Cut(years, "-")
+	if !isRange {
+		last = first
+	}
+	firstYear, err1 := strconv.Atoi(first)
+	lastYear, err2 := strconv.Atoi(last)
+	if err1 != nil || err2 != nil || firstYear > lastYear {
+		return 0, 0
+	}
+	return firstYear, lastYear
+}
+
+// hemcoMatcher matches archive keys against an input's pattern and the run's dates
+type hemcoMatcher struct {
+	prefix string         // Key prefix before the first token, to list
+	re     *regexp.Regexp // Whole key, capturing each time token
+	tokens []string       // Time token of each capture group
+}
+
+var patternToken = regexp.MustCompile(`\$[A-Za-z_]+`)
+
+func newHEMCOMatcher(input HEMCOInput) (*hemcoMatcher, error) {
+	key := "HEMCO/" + input.Pattern
+	matcher := &hemcoMatcher{prefix: key}
+	if loc := patternToken.FindStringIndex(key); loc != nil {
+		matcher.prefix = key[:loc[0]]
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	last := 0
+	for _, loc := range patternToken.FindAllStringIndex(key, -1) {
+		expr.WriteString(regexp.QuoteMeta(key[last:loc[0]]))
+		token := key[loc[0]:loc[1]]
+		switch token {
+		case "$YYYY":
+			expr.WriteString(`(\d{4})`)
+			matcher.tokens = append(matcher.tokens, token)
+		case "$MM", "$DD", "$HH", "$MN":
+			expr.WriteString(`(\d{2})`)
+			matcher.tokens = append(matcher.tokens, token)
+		default: // A setting the config doesn't define
+			expr.WriteString(`[^/]*`)
+		}
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(key[last:]) + "$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("HEMCO file %s: %w", input.Pattern, err)
+	}
+	matcher.re = re
+	return matcher, nil
+}
+
+// matches reports whether key is one of the input's files a run over days reads
+func (m *hemcoMatcher) matches(key string, input HEMCOInput, days []time.Time) bool {
+	captures := m.re.FindStringSubmatch(key)
+	if captures == nil {
+		return false
+	}
+	if len(m.tokens) == 0 {
+		return true
+	}
+
+	for _, day := range days {
+		year := day.Year()
+		if input.LastYear != 0 {
+			year = max(input.FirstYear, min(year, input.LastYear))
+		}
+		match := true
+		for i, token := range m.tokens {
+			value, _ := strconv.Atoi(captures[i+1])
+			switch token {
+			case "$YYYY":
+				match = match && value == year
+			case "$MM":
+				match = match && value == int(day.Month())
+			case "$DD":
+				match = match && value == day.Day()
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
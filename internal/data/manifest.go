@@ -0,0 +1,228 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+)
+
+// Object is a file in SourceBucket
+type Object struct {
+	Key  string `json:"Key"`
+	Size int64  `json:"Size"`
+}
+
+// Lister lists the objects in SourceBucket under a key prefix
+type Lister interface {
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+// bucketLister lists SourceBucket with the AWS CLI. It is public, so requests are unsigned.
+type bucketLister struct {
+	cli   *awscli.Client
+	cache map[string][]Object
+}
+
+// NewBucketLister lists SourceBucket, remembering each prefix's listing
+func NewBucketLister() Lister {
+	return &bucketLister{cli: awscli.New("", SourceRegion), cache: make(map[string][]Object)}
+}
+
+func (l *bucketLister) List(ctx context.Context, prefix string) ([]Object, error) {
+	if objects, ok := l.cache[prefix]; ok {
+		return objects, nil
+	}
+	var objects []Object
+	if err := l.cli.Run(ctx, &objects, "s3api", "list-objects-v2",
+		"--bucket", strings.TrimPrefix(SourceBucket, "s3://"),
+		"--prefix", prefix,
+		"--query", "Contents[].{Key: Key, Size: Size}",
+		"--no-sign-request"); err != nil {
+		return nil, fmt.Errorf("listing %s/%s: %w", SourceBucket, prefix, err)
+	}
+	l.cache[prefix] = objects
+	return objects, nil
+}
+
+// ManifestFile is one object a run reads
+type ManifestFile struct {
+	Key      string `json:"key"` // Relative to the source (and the staging destination)
+	Category string `json:"category"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// Manifest lists exactly the objects in SourceBucket a run reads, found by listing the
+// bucket for each entry of its Plan
+type Manifest struct {
+	Simulation string         `json:"simulation"`
+	Resolution string         `json:"resolution"`
+	MetField   string         `json:"met_field"`
+	Version    string         `json:"version,omitempty"` // GEOS-Chem version whose restart files are preferred
+	StartDate  string         `json:"start_date"`
+	EndDate    string         `json:"end_date"`
+	Source     string         `json:"source"`
+	Created    time.Time      `json:"created"`
+	Files      []ManifestFile `json:"files"`
+	TotalBytes int64          `json:"total_bytes"`
+	Missing    []string       `json:"missing,omitempty"` // Inputs the plan needs that the archive doesn't have
+	Notes      []string       `json:"notes,omitempty"`
+}
+
+// ManifestOptions refine what a manifest resolves
+type ManifestOptions struct {
+	Version string       // Prefer restart files of this GEOS-Chem version, e.g. 14.4.3
+	HEMCO   []HEMCOInput // Emissions the run's HEMCO_Config.rc reads; nil leaves emissions out
+}
+
+// ResolveManifest turns a plan's estimates into the archive's actual objects and sizes.
+// Emissions come from the HEMCO inputs; without them HEMCO/ is left out, since staging the
+// whole archive would be many terabytes.
+func ResolveManifest(ctx context.Context, lister Lister, plan *Plan, opts ManifestOptions) (*Manifest, error) {
+	manifest := &Manifest{
+		Simulation: plan.Spec.Simulation,
+		Resolution: plan.Spec.Resolution,
+		MetField:   plan.Spec.MetField,
+		Version:    opts.Version,
+		StartDate:  plan.Spec.StartDate.Format("2006-01-02"),
+		EndDate:    plan.Spec.EndDate.Format("2006-01-02"),
+		Source:     SourceBucket,
+		Created:    time.Now().UTC(),
+	}
+	seen := make(map[string]bool)
+	add := func(object Object, category string) {
+		if !seen[object.Key] {
+			seen[object.Key] = true
+			manifest.Files = append(manifest.Files, ManifestFile{Key: object.Key, Category: category, Bytes: object.Size})
+			manifest.TotalBytes += object.Size
+		}
+	}
+
+	for _, file := range plan.Files {
+		switch {
+		case file.Category == "emissions" && strings.HasPrefix(file.Path, "HEMCO/") && file.Path != "HEMCO/MASKS/":
+			// Resolved from the HEMCO inputs below
+		case file.Category == "restart":
+			object, found, err := findRestart(ctx, lister, file.Path, opts.Version)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				manifest.Missing = append(manifest.Missing, file.Path)
+				continue
+			}
+			add(object, file.Category)
+		case file.Directory:
+			objects, err := lister.List(ctx, file.Path)
+			if err != nil {
+				return nil, err
+			}
+			if len(objects) == 0 {
+				manifest.Missing = append(manifest.Missing, file.Path)
+			}
+			for _, object := range objects {
+				add(object, file.Category)
+			}
+		default:
+			// Listing the file's directory finds a whole month of met fields at once
+			objects, err := lister.List(ctx, path.Dir(file.Path)+"/")
+			if err != nil {
+				return nil, err
+			}
+			object, ok := findObject(objects, file.Path)
+			if !ok {
+				manifest.Missing = append(manifest.Missing, file.Path)
+				continue
+			}
+			add(object, file.Category)
+		}
+	}
+
+	if opts.HEMCO == nil {
+		manifest.Notes = append(manifest.Notes, "Emissions are left out; give the run directory's HEMCO_Config.rc to include exactly the files it reads")
+	}
+	var days []time.Time
+	for day := plan.Spec.StartDate; !day.After(plan.Spec.EndDate); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	for _, input := range opts.HEMCO {
+		matcher, err := newHEMCOMatcher(input)
+		if err != nil {
+			return nil, err
+		}
+		objects, err := lister.List(ctx, matcher.prefix)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, object := range objects {
+			if matcher.matches(object.Key, input, days) {
+				add(object, "emissions")
+				found = true
+			}
+		}
+		if !found {
+			manifest.Missing = append(manifest.Missing, "HEMCO/"+input.Pattern)
+		}
+	}
+
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Key < manifest.Files[j].Key })
+	return manifest, nil
+}
+
+// findObject finds the object with key in a listing
+func findObject(objects []Object, key string) (Object, bool) {
+	for _, object := range objects {
+		if object.Key == key {
+			return object, true
+		}
+	}
+	return Object{}, false
+}
+
+// findRestart finds a restart file by name anywhere under GEOSCHEM_RESTARTS/, where the
+// archive keeps them per GEOS-Chem version. The requested version's copy is preferred,
+// then the newest version's.
+func findRestart(ctx context.Context, lister Lister, planned, version string) (Object, bool, error) {
+	objects, err := lister.List(ctx, path.Dir(planned)+"/")
+	if err != nil {
+		return Object{}, false, err
+	}
+	var candidates []Object
+	for _, object := range objects {
+		if path.Base(object.Key) == path.Base(planned) {
+			candidates = append(candidates, object)
+		}
+	}
+	if len(candidates) == 0 {
+		return Object{}, false, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Key > candidates[j].Key })
+	if version != "" {
+		for _, object := range candidates {
+			if strings.Contains(object.Key, "/GC_"+version+"/") {
+				return object, true, nil
+			}
+		}
+	}
+	return candidates[0], true, nil
+}
+
+// BytesByCategory sums the manifest per input category
+func (m *Manifest) BytesByCategory() map[string]int64 {
+	totals := make(map[string]int64)
+	for _, file := range m.Files {
+		totals[file.Category] += file.Bytes
+	}
+	return totals
+}
+
+// Name identifies the run the manifest is for, e.g. fullchem-4x5-MERRA2-20190701-20190801
+func (m *Manifest) Name() string {
+	return fmt.Sprintf("%s-%s-%s-%s-%s", m.Simulation, m.Resolution, m.MetField,
+		strings.ReplaceAll(m.StartDate, "-", ""), strings.ReplaceAll(m.EndDate, "-", ""))
+}
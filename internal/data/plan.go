@@ -0,0 +1,388 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SourceBucket is the AWS Open Data archive holding GeosChem input data
+const SourceBucket = "s3://gcgrid"
+
+// SourceRegion is where SourceBucket lives; staging elsewhere incurs inter-region transfer
+const SourceRegion = "us-east-1"
+
+// RunSpec identifies the inputs a simulation needs
+type RunSpec struct {
+	Simulation string // fullchem, aerosol, TransportTracers, CH4, CO2, Hg
+	Resolution string // 4x5, 2x2.5, 0.5x0.625, 0.25x0.3125, or a GCHP cubed-sphere grid (C48, C90, ...)
+	MetField   string // MERRA2, GEOSFP, GEOSIT
+	StartDate  time.Time
+	EndDate    time.Time
+}
+
+// InputFile is one required input with its size estimate
+type InputFile struct {
+	Path      string // Relative to SourceBucket
+	Category  string // met, emissions, chemistry, restart
+	Bytes     int64
+	Directory bool   // Path is a prefix whose exact contents depend on the HEMCO configuration
+	Note      string // Explains what an estimated directory entry covers
+}
+
+// Plan lists every input a run requires before anything is downloaded
+type Plan struct {
+	Spec       RunSpec
+	MetGrid    string // Lat-lon met grid the run reads
+	Files      []InputFile
+	TotalBytes int64
+}
+
+// TransferOptions describes where and how fast data will be staged
+type TransferOptions struct {
+	DestinationRegion string
+	ThroughputMBps    float64 // Sustained aws s3 sync throughput
+}
+
+// Estimate is the projected staging time and cost for a plan
+type Estimate struct {
+	Duration       time.Duration
+	TransferCost   float64 // Inter-region data transfer, USD
+	RequestCost    float64 // S3 GET requests, USD
+	StorageCostEBS float64 // gp3 volume holding the data for one month, USD
+	StorageCostS3  float64 // S3 Standard copy for one month, USD
+	CrossRegion    bool
+}
+
+// Pricing used for estimates (USD, us-east-1 list prices)
+const (
+	interRegionPerGB = 0.02
+	getPer1000       = 0.0004
+	ebsGP3PerGBMonth = 0.08
+	s3PerGBMonth     = 0.023
+)
+
+// DefaultThroughputMBps is a conservative aws s3 sync rate for a mid-size instance
+const DefaultThroughputMBps = 250
+
+const (
+	megabyte = int64(1) << 20
+	gigabyte = int64(1) << 30
+)
+
+// metField describes how a met product is laid out in the archive
+type metField struct {
+	dir         string // Directory under GEOS_<grid>/
+	prefix      string // File name prefix
+	extension   string
+	collections []string
+	grids       []string  // Lat-lon grids available in the archive
+	firstDate   time.Time // First day in the archive; products are extended as new months are processed
+}
+
+var metFields = map[string]metField{
+	"MERRA2": {
+		dir:         "MERRA2",
+		prefix:      "MERRA2",
+		extension:   "nc4",
+		collections: []string{"A1", "A3cld", "A3dyn", "A3mstC", "A3mstE", "I3"},
+		grids:       []string{"4x5", "2x2.5", "0.5x0.625"},
+		firstDate:   time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC),
+	},
+	"GEOSFP": {
+		dir:         "GEOS_FP",
+		prefix:      "GEOSFP",
+		extension:   "nc",
+		collections: []string{"A1", "A3cld", "A3dyn", "A3mstC", "A3mstE", "I3"},
+		grids:       []string{"4x5", "2x2.5", "0.25x0.3125"},
+		firstDate:   time.Date(2011, 1, 1, 0, 0, 0, 0, time.UTC),
+	},
+	"GEOSIT": {
+		dir:         "GEOS_IT",
+		prefix:      "GEOSIT",
+		extension:   "nc",
+		collections: []string{"A1", "A3cld", "A3dyn", "A3mstC", "A3mstE", "I3"},
+		grids:       []string{"4x5", "2x2.5", "0.5x0.625"},
+		firstDate:   time.Date(1998, 1, 1, 0, 0, 0, 0, time.UTC),
+	},
+}
+
+// Approximate daily size of each met collection at 4x5; finer grids scale with cell count
+var metCollectionMB4x5 = map[string]float64{
+	"A1":     18,
+	"A3cld":  22,
+	"A3dyn":  20,
+	"A3mstC": 10,
+	"A3mstE": 12,
+	"I3":     8,
+	"CN":     2,
+}
+
+// gridScale is the number of grid cells relative to 4x5
+var gridScale = map[string]float64{
+	"4x5":         1,
+	"2x2.5":       4,
+	"0.5x0.625":   64,
+	"0.25x0.3125": 256,
+}
+
+// gridFileTag is how each grid is spelled in met file names
+var gridFileTag = map[string]string{
+	"4x5":         "4x5",
+	"2x2.5":       "2x25",
+	"0.5x0.625":   "05x0625",
+	"0.25x0.3125": "025x03125",
+}
+
+// emissionsGBPerYear approximates the HEMCO inventory volume each simulation reads per model year
+var emissionsGBPerYear = map[string]float64{
+	"fullchem":         45,
+	"aerosol":          30,
+	"TransportTracers": 2,
+	"CH4":              20,
+	"CO2":              15,
+	"Hg":               10,
+}
+
+// staticInputs are read regardless of run length
+var staticInputs = []InputFile{
+	{Path: "CHEM_INPUTS/", Category: "chemistry", Bytes: 3 * gigabyte, Directory: true, Note: "photolysis, mechanism, and climatology tables"},
+	{Path: "HEMCO/MASKS/", Category: "emissions", Bytes: 1 * gigabyte, Directory: true, Note: "regional masks"},
+}
+
+// MetGridFor returns the lat-lon met grid a resolution reads. GCHP regrids on the fly
+// from the finest archived grid appropriate to the cube size.
+func MetGridFor(resolution, metFieldName string) (string, error) {
+	field, ok := metFields[metFieldName]
+	if !ok {
+		return "", fmt.Errorf("unknown met field %s (available: %s)", metFieldName, strings.Join(MetFieldNames(), ", "))
+	}
+
+	grid := resolution
+	if strings.HasPrefix(resolution, "C") {
+		grid = "2x2.5"
+		if size := strings.TrimPrefix(resolution, "C"); size != "24" && size != "48" {
+			grid = "0.5x0.625"
+			if metFieldName == "GEOSFP" {
+				grid = "0.25x0.3125"
+			}
+		}
+	}
+
+	for _, g := range field.grids {
+		if g == grid {
+			return grid, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not archived at %s (available: %s)", metFieldName, grid, strings.Join(field.grids, ", "))
+}
+
+// MetFiles is where a met product's files for one grid are in SourceBucket, e.g.
+// GEOS_4x5/MERRA2/2019/07/MERRA2.20190701.A1.4x5.nc4
+type MetFiles struct {
+	Dir       string // Directory holding the YYYY/MM tree, relative to SourceBucket
+	Prefix    string // File name prefix
+	Tag       string // Grid as spelled in file names
+	Extension string
+	FirstYear int // First year in the archive
+}
+
+// ConstantYear is the year whose January holds each product's constant (CN) fields
+const ConstantYear = 2015
+
+// MetFilesFor returns the layout of the met files a resolution reads
+func MetFilesFor(resolution, metFieldName string) (MetFiles, error) {
+	grid, err := MetGridFor(resolution, metFieldName)
+	if err != nil {
+		return MetFiles{}, err
+	}
+	field := metFields[metFieldName]
+	return MetFiles{
+		Dir:       fmt.Sprintf("GEOS_%s/%s", grid, field.dir),
+		Prefix:    field.prefix,
+		Tag:       gridFileTag[grid],
+		Extension: field.extension,
+		FirstYear: field.firstDate.Year(),
+	}, nil
+}
+
+// metProcessingLag is how far behind the present the archive typically runs
+const metProcessingLag = 60 * 24 * time.Hour
+
+// CheckMetCoverage returns an error when the archive has no met fields for part of the run,
+// and warnings when the run reaches months that may not have been processed yet
+func CheckMetCoverage(metFieldName string, start, end time.Time) ([]string, error) {
+	field, ok := metFields[metFieldName]
+	if !ok {
+		return nil, fmt.Errorf("unknown met field %s (available: %s)", metFieldName, strings.Join(MetFieldNames(), ", "))
+	}
+
+	if start.Before(field.firstDate) {
+		return nil, fmt.Errorf("%s met fields start %s, run starts %s",
+			metFieldName, field.firstDate.Format("2006-01-02"), start.Format("2006-01-02"))
+	}
+	now := time.Now().UTC()
+	if end.After(now) {
+		return nil, fmt.Errorf("run ends %s, after the latest possible %s met fields (today)", end.Format("2006-01-02"), metFieldName)
+	}
+
+	var warnings []string
+	if end.After(now.Add(-metProcessingLag)) {
+		warnings = append(warnings, fmt.Sprintf("%s met fields for the last two months may not be in %s yet; check the archive before running",
+			metFieldName, SourceBucket))
+	}
+	return warnings, nil
+}
+
+// MetSyncFilters returns aws s3 sync filters that stage only the met fields a run reads
+// from a data source laid out like SourceBucket: the run's years of its met product and the
+// constant fields. Other inputs are staged in full.
+func MetSyncFilters(metFieldName string, start, end time.Time) ([]string, error) {
+	field, ok := metFields[metFieldName]
+	if !ok {
+		return nil, fmt.Errorf("unknown met field %s (available: %s)", metFieldName, strings.Join(MetFieldNames(), ", "))
+	}
+
+	// Later filters take precedence. The end date's fields are read for the last step, which
+	// for a run ending on January 1 is the only day of that year it needs.
+	filters := []string{"--exclude", "GEOS_*"}
+	for year := start.Year(); year <= end.AddDate(0, 0, -1).Year(); year++ {
+		filters = append(filters, "--include", fmt.Sprintf("GEOS_*/%s/%d/*", field.dir, year))
+	}
+	return append(filters,
+		"--include", fmt.Sprintf("GEOS_*/%s/%s/*.%s.*", field.dir, end.Format("2006/01"), end.Format("20060102")),
+		"--include", fmt.Sprintf("GEOS_*/%s/2015/01/*.CN.*", field.dir)), nil
+}
+
+// MetFieldNames returns the supported met field products
+func MetFieldNames() []string {
+	var names []string
+	for name := range metFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuildPlan resolves every input file a run requires
+func BuildPlan(spec RunSpec) (*Plan, error) {
+	if !spec.EndDate.After(spec.StartDate) {
+		return nil, fmt.Errorf("end date must be after start date")
+	}
+	perYear, ok := emissionsGBPerYear[spec.Simulation]
+	if !ok {
+		return nil, fmt.Errorf("unknown simulation %s", spec.Simulation)
+	}
+
+	grid, err := MetGridFor(spec.Resolution, spec.MetField)
+	if err != nil {
+		return nil, err
+	}
+	field := metFields[spec.MetField]
+	scale := gridScale[grid]
+	tag := gridFileTag[grid]
+
+	plan := &Plan{Spec: spec, MetGrid: grid}
+
+	// Constant fields are stored once under 2015/01
+	plan.add(InputFile{
+		Path:     fmt.Sprintf("GEOS_%s/%s/%d/01/%s.%d0101.CN.%s.%s", grid, field.dir, ConstantYear, field.prefix, ConstantYear, tag, field.extension),
+		Category: "met",
+		Bytes:    int64(metCollectionMB4x5["CN"] * scale * float64(megabyte)),
+	})
+
+	// One file per collection per day; the final day is needed for the last interpolation step
+	for day := spec.StartDate; !day.After(spec.EndDate); day = day.AddDate(0, 0, 1) {
+		for _, collection := range field.collections {
+			plan.add(InputFile{
+				Path: fmt.Sprintf("GEOS_%s/%s/%s/%s.%s.%s.%s.%s", grid, field.dir, day.Format("2006/01"),
+					field.prefix, day.Format("20060102"), collection, tag, field.extension),
+				Category: "met",
+				Bytes:    int64(metCollectionMB4x5[collection] * scale * float64(megabyte)),
+			})
+		}
+	}
+
+	// Emissions are on native inventory grids, so they scale with months covered rather than resolution
+	firstMonth := time.Date(spec.StartDate.Year(), spec.StartDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for month := firstMonth; month.Before(spec.EndDate); month = month.AddDate(0, 1, 0) {
+		plan.add(InputFile{
+			Path:      "HEMCO/",
+			Category:  "emissions",
+			Bytes:     int64(perYear / 12 * float64(gigabyte)),
+			Directory: true,
+			Note:      fmt.Sprintf("%s inventories for %s (exact files set by HEMCO_Config.rc)", spec.Simulation, month.Format("2006-01")),
+		})
+	}
+
+	for _, file := range staticInputs {
+		plan.add(file)
+	}
+
+	plan.add(InputFile{
+		Path:     fmt.Sprintf("GEOSCHEM_RESTARTS/GEOSChem.Restart.%s.%s_0000z.nc4", spec.Simulation, spec.StartDate.Format("20060102")),
+		Category: "restart",
+		Bytes:    int64(restartMB4x5(spec.Simulation) * scale * float64(megabyte)),
+	})
+
+	return plan, nil
+}
+
+// restartMB4x5 approximates a restart file size at 4x5, driven by species count
+func restartMB4x5(simulation string) float64 {
+	if simulation == "fullchem" || simulation == "aerosol" {
+		return 90
+	}
+	return 10
+}
+
+func (p *Plan) add(file InputFile) {
+	p.Files = append(p.Files, file)
+	p.TotalBytes += file.Bytes
+}
+
+// BytesByCategory sums the plan per input category
+func (p *Plan) BytesByCategory() map[string]int64 {
+	totals := make(map[string]int64)
+	for _, file := range p.Files {
+		totals[file.Category] += file.Bytes
+	}
+	return totals
+}
+
+// Estimate projects staging time and cost for the plan
+func (p *Plan) Estimate(opts TransferOptions) Estimate {
+	throughput := opts.ThroughputMBps
+	if throughput <= 0 {
+		throughput = DefaultThroughputMBps
+	}
+
+	gb := float64(p.TotalBytes) / float64(gigabyte)
+	estimate := Estimate{
+		Duration:       time.Duration(float64(p.TotalBytes) / (throughput * float64(megabyte)) * float64(time.Second)),
+		RequestCost:    float64(len(p.Files)) / 1000 * getPer1000,
+		StorageCostEBS: gb * ebsGP3PerGBMonth,
+		StorageCostS3:  gb * s3PerGBMonth,
+		CrossRegion:    opts.DestinationRegion != "" && opts.DestinationRegion != SourceRegion,
+	}
+	if estimate.CrossRegion {
+		estimate.TransferCost = gb * interRegionPerGB
+	}
+
+	return estimate
+}
+
+// FormatBytes renders a byte count in human-readable binary units
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+)
+
+// syncIncludesPerCommand bounds the --include filters per aws s3 sync, keeping the command
+// line well under the OS limit
+const syncIncludesPerCommand = 200
+
+// ManifestDir is where Stage writes the manifest, relative to the destination
+const ManifestDir = "manifests"
+
+// StageOptions say where staged data goes
+type StageOptions struct {
+	Destination string // s3://bucket/prefix, or a directory such as an FSx for Lustre mount
+	Profile     string // For an S3 destination
+	Region      string // The destination bucket's region
+}
+
+// Stage copies the manifest's files from SourceBucket to the destination, keeping their
+// keys, and writes the manifest beside them. It runs aws s3 sync per directory, so files
+// already staged are skipped and an interrupted stage resumes where it stopped. Bucket to
+// bucket copies happen within S3; a directory destination downloads to this host.
+func Stage(ctx context.Context, manifest *Manifest, opts StageOptions) error {
+	toS3 := strings.HasPrefix(opts.Destination, "s3://")
+	destination := strings.TrimSuffix(opts.Destination, "/")
+
+	// Reading the public archive into a bucket needs signed requests; downloading doesn't
+	cli := awscli.New("", SourceRegion)
+	extra := []string{"--no-sign-request"}
+	if toS3 {
+		cli = awscli.New(opts.Profile, opts.Region)
+		extra = []string{"--source-region", SourceRegion}
+	} else if err := os.MkdirAll(destination, 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", destination, err)
+	}
+
+	byDir := make(map[string][]ManifestFile)
+	for _, file := range manifest.Files {
+		dir := path.Dir(file.Key)
+		byDir[dir] = append(byDir[dir], file)
+	}
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for i, dir := range dirs {
+		files := byDir[dir]
+		var bytes int64
+		for _, file := range files {
+			bytes += file.Bytes
+		}
+		fmt.Printf("📥 [%d/%d] %s (%d files, %s)\n", i+1, len(dirs), dir, len(files), FormatBytes(bytes))
+
+		for start := 0; start < len(files); start += syncIncludesPerCommand {
+			args := []string{"s3", "sync", "--only-show-errors",
+				SourceBucket + "/" + dir, destination + "/" + dir,
+				"--exclude", "*"}
+			for _, file := range files[start:min(start+syncIncludesPerCommand, len(files))] {
+				args = append(args, "--include", path.Base(file.Key))
+			}
+			if err := cli.Run(ctx, nil, append(args, extra...)...); err != nil {
+				return fmt.Errorf("staging %s: %w", dir, err)
+			}
+		}
+	}
+
+	return writeManifest(ctx, cli, manifest, destination, toS3)
+}
+
+// ManifestPath returns where Stage puts a manifest in a destination
+func ManifestPath(destination string, manifest *Manifest) string {
+	return strings.TrimSuffix(destination, "/") + "/" + ManifestDir + "/" + manifest.Name() + ".json"
+}
+
+func writeManifest(ctx context.Context, cli *awscli.Client, manifest *Manifest, destination string, toS3 bool) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+	target := ManifestPath(destination, manifest)
+	if toS3 {
+		if err := cli.RunWithInput(ctx, nil, data, "s3", "cp", "-", target); err != nil {
+			return fmt.Errorf("writing manifest: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	return nil
+}
@@ -1,45 +1,89 @@
 package docker
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 
-	"github.com/scttfrdmn/geoschem-aws/internal/ssh"
+	"github.com/scttfrdmn/geoschem-aws/internal/artifacts"
+	"github.com/scttfrdmn/geoschem-aws/internal/redact"
 )
 
+// CommandRunner executes shell commands on a build host (SSH, SSM, ...)
+type CommandRunner interface {
+	ExecuteCommand(ctx context.Context, command string) (string, error)
+	ExecuteCommandStream(ctx context.Context, command string, stdout, stderr io.Writer) error
+}
+
+// FileUploader is implemented by runners that can also copy local files to the build host,
+// which a local source tree needs
+type FileUploader interface {
+	UploadFile(ctx context.Context, localPath, remotePath string) error
+}
+
 type DockerBuilder struct {
-	sshClient *ssh.Client
+	runner CommandRunner
 }
 
 type BuildConfig struct {
 	SourceRepo    string // Git repository URL
 	SourceBranch  string // Git branch/tag
+	SourceArchive string // Local .tar.gz of a working copy uploaded instead of cloning SourceRepo
+	SourceRevision string // Commit of SourceArchive's working copy; empty reads it from the checkout
+	SourceSubmodules map[string]string // Ref to check out per submodule path instead of the commit SourceBranch pins
+	SourceTokenSecret string // Secrets Manager secret holding a token for cloning a private HTTPS SourceRepo
+	SourceDeployKeySecret string // Secrets Manager secret holding an SSH deploy key for cloning a private SourceRepo
+	SourcePatches []Patch // Applied in order to the cloned or uploaded source
 	DockerfileDir string // Directory containing Dockerfile
+	Dockerfile    string // Dockerfile name inside DockerfileDir; empty means Dockerfile
+	DockerfileContent string // Local Dockerfile uploaded and built in DockerfileDir instead of Dockerfile
+	DockerfileLabel string // How LabelDockerfile records DockerfileContent
 	ImageName     string // Final image name
 	ImageTag      string // Image tag
 	Architecture  string // x86_64 or arm64
 	BuildArgs     map[string]string // Docker build arguments
+	CcacheURI     string // s3:// prefix holding the persistent compiler cache; empty disables ccache
+	PullThrough   map[string]string // Upstream registry (docker.io, quay.io) to ECR pull-through cache prefix
+	Prepull       []string // Images pulled before the build besides the base image
+	RepositoryStrategy string // ECR layout (RepoSingle, RepoPerArch, RepoPerImage); empty means RepoSingle
+	Push          PushOptions // ECR upload tuning
 }
 
-// NewDockerBuilder creates a new Docker builder
-func NewDockerBuilder(sshClient *ssh.Client) *DockerBuilder {
+// NewDockerBuilder creates a new Docker builder that runs its commands through runner
+func NewDockerBuilder(runner CommandRunner) *DockerBuilder {
 	return &DockerBuilder{
-		sshClient: sshClient,
+		runner: runner,
 	}
 }
 
 // BuildContainer builds a Docker container on the remote instance
 func (db *DockerBuilder) BuildContainer(ctx context.Context, config *BuildConfig) error {
 	fmt.Printf("🐳 Starting Docker build for %s:%s (%s)\n", config.ImageName, config.ImageTag, config.Architecture)
+	redact.AddSecrets(config.SecretBuildArgs()...)
 
-	// Step 1: Clone the source repository
-	fmt.Println("📥 Cloning source repository...")
-	err := db.cloneRepository(ctx, config)
-	if err != nil {
-		return fmt.Errorf("cloning repository: %w", err)
+	// Fail now rather than when Spack runs out of space an hour in
+	if err := db.checkDiskSpace(ctx, buildFreeGB); err != nil {
+		return err
+	}
+
+	// Step 1: Clone the source repository, or upload the local working copy
+	var err error
+	if config.SourceArchive != "" {
+		fmt.Printf("📤 Uploading local source tree %s...\n", config.SourceRepo)
+		if err := db.uploadSource(ctx, config); err != nil {
+			return fmt.Errorf("uploading source: %w", err)
+		}
+	} else {
+		fmt.Println("📥 Cloning source repository...")
+		err = db.cloneRepository(ctx, config)
+		if err != nil {
+			return fmt.Errorf("cloning repository: %w", err)
+		}
 	}
 
 	// Step 2: Prepare build context
@@ -49,14 +93,41 @@ func (db *DockerBuilder) BuildContainer(ctx context.Context, config *BuildConfig
 		return fmt.Errorf("preparing build context: %w", err)
 	}
 
-	// Step 3: Build the Docker image
+	// Step 3: Pull the images the build starts FROM, ideally from a pull-through cache
+	db.PrePull(ctx, config)
+
+	// Step 4: Restore the compiler cache from earlier builds
+	if config.CcacheURI != "" {
+		fmt.Println("♻️  Restoring compiler cache...")
+		output, err := db.runner.ExecuteCommand(ctx, ccacheRestoreCommand(config))
+		if err != nil {
+			return fmt.Errorf("restoring compiler cache: %w, output: %s", err, output)
+		}
+		fmt.Print(output)
+	}
+
+	// Step 5: Build the Docker image
 	fmt.Println("🔨 Building Docker image...")
 	err = db.buildDockerImage(ctx, config, buildDir)
 	if err != nil {
 		return fmt.Errorf("building Docker image: %w", err)
 	}
 
-	// Step 4: Tag the image
+	// Step 6: Record how the image was produced; the image is usable without the labels
+	fmt.Println("🧾 Recording lineage labels...")
+	if output, err := db.runner.ExecuteCommand(ctx, lineageCommand(config)); err != nil {
+		fmt.Printf("Warning: Failed to record lineage labels: %v, output: %s\n", err, output)
+	}
+
+	// Step 7: Save the compiler cache; a failed upload only costs the next build its speedup
+	if config.CcacheURI != "" {
+		fmt.Println("♻️  Saving compiler cache...")
+		if output, err := db.runner.ExecuteCommand(ctx, ccacheSaveCommand(config)); err != nil {
+			fmt.Printf("Warning: Failed to save compiler cache: %v, output: %s\n", err, output)
+		}
+	}
+
+	// Step 8: Tag the image
 	fmt.Println("🏷️  Tagging Docker image...")
 	err = db.tagImage(ctx, config)
 	if err != nil {
@@ -70,16 +141,13 @@ func (db *DockerBuilder) BuildContainer(ctx context.Context, config *BuildConfig
 // cloneRepository clones the source repository
 func (db *DockerBuilder) cloneRepository(ctx context.Context, config *BuildConfig) error {
 	// Clean up any existing source directory
-	_, err := db.sshClient.ExecuteCommand(ctx, "rm -rf ~/source")
+	_, err := db.runner.ExecuteCommand(ctx, "rm -rf ~/source")
 	if err != nil {
 		// Ignore error if directory doesn't exist
 	}
 
 	// Clone the repository
-	cloneCmd := fmt.Sprintf("git clone --depth 1 --branch %s %s ~/source", 
-		config.SourceBranch, config.SourceRepo)
-	
-	output, err := db.sshClient.ExecuteCommand(ctx, cloneCmd)
+	output, err := db.runner.ExecuteCommand(ctx, cloneCommand(config))
 	if err != nil {
 		return fmt.Errorf("git clone failed: %w, output: %s", err, output)
 	}
@@ -88,20 +156,52 @@ func (db *DockerBuilder) cloneRepository(ctx context.Context, config *BuildConfi
 	return nil
 }
 
+// uploadSource copies the packed working copy to the host and unpacks it as ~/source
+func (db *DockerBuilder) uploadSource(ctx context.Context, config *BuildConfig) error {
+	uploader, ok := db.runner.(FileUploader)
+	if !ok {
+		return fmt.Errorf("this build host can't receive files; a local source tree needs SSH")
+	}
+	if err := uploader.UploadFile(ctx, config.SourceArchive, sourceArchiveFile); err != nil {
+		return err
+	}
+	output, err := db.runner.ExecuteCommand(ctx, unpackSourceCommand())
+	if err != nil {
+		return fmt.Errorf("unpacking source: %w, output: %s", err, output)
+	}
+	if patch := patchCommand(config); patch != "" {
+		if output, err := db.runner.ExecuteCommand(ctx, withSourceCredentials(config, patch)); err != nil {
+			return fmt.Errorf("applying patches: %w, output: %s", err, output)
+		}
+	}
+
+	fmt.Printf("Source uploaded successfully\n")
+	return nil
+}
+
 // prepareBuildContext prepares the build context directory
 func (db *DockerBuilder) prepareBuildContext(ctx context.Context, config *BuildConfig) (string, error) {
-	buildDir := filepath.Join("~/source", config.DockerfileDir)
+	buildDir := buildContextDir(config)
 	
-	// Verify Dockerfile exists
-	checkCmd := fmt.Sprintf("test -f %s/Dockerfile", buildDir)
-	_, err := db.sshClient.ExecuteCommand(ctx, checkCmd)
+	// Verify the build context and Dockerfile exist, uploading a local Dockerfile first
+	_, err := db.runner.ExecuteCommand(ctx, checkDockerfileCommand(config, buildDir))
 	if err != nil {
-		return "", fmt.Errorf("Dockerfile not found in %s", buildDir)
+		if config.DockerfileContent != "" {
+			return "", fmt.Errorf("build context %s not found for %s", buildDir, config.DockerfileLabel)
+		}
+		return "", fmt.Errorf("%s not found in %s", config.DockerfileName(), buildDir)
+	}
+
+	// Pull base images through the configured pull-through caches
+	if rewrite := rewriteFromCommand(config, buildDir); rewrite != "" {
+		if output, err := db.runner.ExecuteCommand(ctx, rewrite); err != nil {
+			return "", fmt.Errorf("rewriting FROM lines for pull-through caches: %w, output: %s", err, output)
+		}
 	}
 
 	// Show build context info
-	infoCmd := fmt.Sprintf("cd %s && ls -la && echo '=== Dockerfile ===' && head -20 Dockerfile", buildDir)
-	output, err := db.sshClient.ExecuteCommand(ctx, infoCmd)
+	infoCmd := fmt.Sprintf("cd %[1]s && ls -la && echo '=== %[2]s ===' && head -20 %[2]s", buildDir, dockerfilePath(config, buildDir))
+	output, err := db.runner.ExecuteCommand(ctx, infoCmd)
 	if err != nil {
 		fmt.Printf("Warning: Could not show build context info: %v\n", err)
 	} else {
@@ -113,32 +213,19 @@ func (db *DockerBuilder) prepareBuildContext(ctx context.Context, config *BuildC
 
 // buildDockerImage builds the Docker image
 func (db *DockerBuilder) buildDockerImage(ctx context.Context, config *BuildConfig, buildDir string) error {
-	// Construct build command (Rocky Linux 9 uses Podman)
-	buildCmd := strings.Builder{}
-	buildCmd.WriteString(fmt.Sprintf("cd %s && podman build", buildDir))
-	
-	// Add build arguments (properly escape values with shell-sensitive characters)
-	for key, value := range config.BuildArgs {
-		buildCmd.WriteString(fmt.Sprintf(" --build-arg %s='%s'", key, strings.ReplaceAll(value, "'", `'"'"'`)))
-	}
+	buildCmd := buildCommand(config, buildDir)
 	
-	// Add platform specification for multi-arch builds (Podman may not need this)
-	// platformArch := config.Architecture
-	// if platformArch == "arm64" {
-	// 	platformArch = "arm64"
-	// } else {
-	// 	platformArch = "amd64"
-	// }
-	// buildCmd.WriteString(fmt.Sprintf(" --platform linux/%s", platformArch))
-	
-	// Add image tag and build context
-	buildCmd.WriteString(fmt.Sprintf(" -t %s:%s .", config.ImageName, config.ImageTag))
-	
-	fmt.Printf("Executing build command: %s\n", buildCmd.String())
+	fmt.Printf("Executing build command: %s\n", buildCmd)
 	
 	// Execute build with streaming output
-	err := db.sshClient.ExecuteCommandStream(ctx, buildCmd.String(), os.Stdout, os.Stderr)
+	err := db.runner.ExecuteCommandStream(ctx, buildCmd, os.Stdout, os.Stderr)
 	if err != nil {
+		// Name the cause when the build died of a full disk, whatever the tool reported
+		if ctx.Err() == nil {
+			if free, freeErr := db.freeSpace(ctx); freeErr == nil && free < buildFreeGB/4 {
+				return fmt.Errorf("docker build failed: %w (%s)", err, diskSpaceMessage(strconv.Itoa(free), buildFreeGB))
+			}
+		}
 		return fmt.Errorf("docker build failed: %w", err)
 	}
 
@@ -148,17 +235,14 @@ func (db *DockerBuilder) buildDockerImage(ctx context.Context, config *BuildConf
 // tagImage tags the built image with additional tags
 func (db *DockerBuilder) tagImage(ctx context.Context, config *BuildConfig) error {
 	// Create architecture-specific tag
-	archTag := fmt.Sprintf("%s:%s-%s", config.ImageName, config.ImageTag, config.Architecture)
-	tagCmd := fmt.Sprintf("podman tag %s:%s %s", config.ImageName, config.ImageTag, archTag)
-	
-	output, err := db.sshClient.ExecuteCommand(ctx, tagCmd)
+	output, err := db.runner.ExecuteCommand(ctx, archTagCommand(config))
 	if err != nil {
 		return fmt.Errorf("tagging failed: %w, output: %s", err, output)
 	}
 
 	// List final images
 	listCmd := fmt.Sprintf("podman images | grep %s", config.ImageName)
-	output, err = db.sshClient.ExecuteCommand(ctx, listCmd)
+	output, err = db.runner.ExecuteCommand(ctx, listCmd)
 	if err != nil {
 		fmt.Printf("Warning: Could not list images: %v\n", err)
 	} else {
@@ -179,65 +263,66 @@ func (db *DockerBuilder) PushToECR(ctx context.Context, config *BuildConfig, ecr
 		return fmt.Errorf("ECR login failed: %w", err)
 	}
 
-	// Step 2: Tag image for ECR
-	fmt.Println("🏷️  Tagging image for ECR...")
-	ecrImageName := fmt.Sprintf("%s:%s", ecrRepository, config.ImageTag)
-	archECRImageName := fmt.Sprintf("%s:%s-%s", ecrRepository, config.ImageTag, config.Architecture)
-	
-	// Tag main image
-	tagCmd := fmt.Sprintf("podman tag %s:%s %s", config.ImageName, config.ImageTag, ecrImageName)
-	output, err := db.sshClient.ExecuteCommand(ctx, tagCmd)
+	// Step 2: Push the image once; further tags reuse the uploaded manifest
+	push, retags, err := ecrPushPlan(config, ecrRepository)
 	if err != nil {
-		return fmt.Errorf("tagging for ECR failed: %w, output: %s", err, output)
+		return err
 	}
+	fmt.Println("⬆️  Pushing image to ECR...")
+	for attempt := 1; ; attempt++ {
+		// Keep the output to tell an expired login from other failures
+		var output bytes.Buffer
+		err = db.runner.ExecuteCommandStream(ctx, push, io.MultiWriter(os.Stdout, &output), io.MultiWriter(os.Stderr, &output))
+		if err == nil {
+			break
+		}
+		if attempt == maxPushAttempts || !isECRAuthFailure(output.String()) {
+			return fmt.Errorf("pushing %s failed: %w", config.LocalImage(), err)
+		}
 
-	// Tag architecture-specific image
-	tagArchCmd := fmt.Sprintf("podman tag %s:%s-%s %s", config.ImageName, config.ImageTag, config.Architecture, archECRImageName)
-	output, err = db.sshClient.ExecuteCommand(ctx, tagArchCmd)
-	if err != nil {
-		return fmt.Errorf("tagging arch-specific image for ECR failed: %w, output: %s", err, output)
+		// Long builds outlive the 12-hour ECR token; a fresh login lets the push finish
+		fmt.Println("🔐 ECR login expired during the push; logging in again and retrying...")
+		if err := db.loginToECR(ctx, ecrRepository); err != nil {
+			return fmt.Errorf("ECR login failed: %w", err)
+		}
 	}
 
-	// Step 3: Push images
-	fmt.Println("⬆️  Pushing images to ECR...")
-	
-	// Push main tag
-	pushCmd := fmt.Sprintf("podman push %s", ecrImageName)
-	err = db.sshClient.ExecuteCommandStream(ctx, pushCmd, os.Stdout, os.Stderr)
-	if err != nil {
-		return fmt.Errorf("pushing main image failed: %w", err)
+	// Step 3: Add the remaining tags
+	if len(retags) > 0 {
+		fmt.Println("🏷️  Tagging pushed image in ECR...")
 	}
-
-	// Push architecture-specific tag
-	pushArchCmd := fmt.Sprintf("podman push %s", archECRImageName)
-	err = db.sshClient.ExecuteCommandStream(ctx, pushArchCmd, os.Stdout, os.Stderr)
-	if err != nil {
-		return fmt.Errorf("pushing arch-specific image failed: %w", err)
+	for _, retag := range retags {
+		output, err := db.runner.ExecuteCommand(ctx, retag)
+		if err != nil {
+			return fmt.Errorf("tagging pushed image failed: %w, output: %s", err, output)
+		}
 	}
 
 	fmt.Printf("✅ Successfully pushed to ECR:\n")
-	fmt.Printf("   - %s\n", ecrImageName)
-	fmt.Printf("   - %s\n", archECRImageName)
-	
+	for _, ecrImage := range ECRImages(config, ecrRepository) {
+		fmt.Printf("   - %s\n", ecrImage)
+	}
+
+	// Step 4: Combine with the other architecture's image under one tag
+	if config.Push.Manifest {
+		images := ManifestCandidates(config, ecrRepository)
+		if err := db.PushManifest(ctx, ManifestImage(config, ecrRepository), images); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // loginToECR authenticates with Amazon ECR
 func (db *DockerBuilder) loginToECR(ctx context.Context, ecrRepository string) error {
-	// Extract region from ECR repository URL
-	// Format: <account>.dkr.ecr.<region>.amazonaws.com/<repo>
-	parts := strings.Split(ecrRepository, ".")
-	if len(parts) < 4 {
-		return fmt.Errorf("invalid ECR repository format: %s", ecrRepository)
-	}
-	region := parts[3]
-
 	// Get ECR login password and login to Podman
-	loginCmd := fmt.Sprintf(
-		"aws ecr get-login-password --region %s | podman login --username AWS --password-stdin %s",
-		region, strings.Split(ecrRepository, "/")[0])
+	loginCmd, err := ecrLoginCommand(ecrRepository)
+	if err != nil {
+		return err
+	}
 	
-	output, err := db.sshClient.ExecuteCommand(ctx, loginCmd)
+	output, err := db.runner.ExecuteCommand(ctx, loginCmd)
 	if err != nil {
 		return fmt.Errorf("ECR login command failed: %w, output: %s", err, output)
 	}
@@ -250,26 +335,58 @@ func (db *DockerBuilder) loginToECR(ctx context.Context, ecrRepository string) e
 	return nil
 }
 
+// PullImage pulls an image onto the remote instance, logging in to ECR first when needed
+func (db *DockerBuilder) PullImage(ctx context.Context, image string) error {
+	if strings.Contains(image, ".dkr.ecr.") {
+		if err := db.loginToECR(ctx, image); err != nil {
+			return fmt.Errorf("ECR login failed: %w", err)
+		}
+	}
+
+	fmt.Printf("📥 Pulling image: %s\n", image)
+	err := db.runner.ExecuteCommandStream(ctx, fmt.Sprintf("podman pull %s", image), os.Stdout, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("pulling image %s: %w", image, err)
+	}
+
+	return nil
+}
+
+// ImageLabels returns the labels of an image on the remote instance
+func (db *DockerBuilder) ImageLabels(ctx context.Context, image string) (map[string]string, error) {
+	output, err := db.runner.ExecuteCommand(ctx, fmt.Sprintf("podman image inspect --format '{{json .Labels}}' %s", image))
+	if err != nil {
+		return nil, fmt.Errorf("inspecting image %s: %w, output: %s", image, err, output)
+	}
+
+	labels := make(map[string]string)
+	output = strings.TrimSpace(output)
+	if output == "" || output == "null" {
+		return labels, nil
+	}
+	if err := json.Unmarshal([]byte(output), &labels); err != nil {
+		return nil, fmt.Errorf("parsing labels of %s: %w", image, err)
+	}
+	return labels, nil
+}
+
 // CleanupImages removes built images to save space
 func (db *DockerBuilder) CleanupImages(ctx context.Context, config *BuildConfig) error {
 	fmt.Println("🧹 Cleaning up Docker images...")
 	
 	// Remove built images
-	images := []string{
-		fmt.Sprintf("%s:%s", config.ImageName, config.ImageTag),
-		fmt.Sprintf("%s:%s-%s", config.ImageName, config.ImageTag, config.Architecture),
-	}
+	images := []string{config.LocalImage(), config.LocalArchImage()}
 	
 	for _, image := range images {
 		cleanupCmd := fmt.Sprintf("podman rmi %s || true", image)
-		_, err := db.sshClient.ExecuteCommand(ctx, cleanupCmd)
+		_, err := db.runner.ExecuteCommand(ctx, cleanupCmd)
 		if err != nil {
 			fmt.Printf("Warning: Failed to remove image %s: %v\n", image, err)
 		}
 	}
 
 	// Clean up build cache
-	_, err := db.sshClient.ExecuteCommand(ctx, "podman system prune -f || true")
+	_, err := db.runner.ExecuteCommand(ctx, "podman system prune -f || true")
 	if err != nil {
 		fmt.Printf("Warning: Failed to prune build cache: %v\n", err)
 	}
@@ -278,12 +395,25 @@ func (db *DockerBuilder) CleanupImages(ctx context.Context, config *BuildConfig)
 	return nil
 }
 
+// CleanupArtifacts removes the source checkout and push files a build left on the host,
+// unless artifacts are kept for debugging
+func (db *DockerBuilder) CleanupArtifacts(ctx context.Context, config *BuildConfig) error {
+	remove := artifacts.RemoteCommand(hostArtifacts(config)...)
+	if remove == "" {
+		return nil
+	}
+	if output, err := db.runner.ExecuteCommand(ctx, remove); err != nil {
+		return fmt.Errorf("removing build files: %w, output: %s", err, output)
+	}
+	return nil
+}
+
 // GetImageInfo returns information about built images
 func (db *DockerBuilder) GetImageInfo(ctx context.Context, config *BuildConfig) (string, error) {
 	// Get image information
 	infoCmd := fmt.Sprintf("podman images --format 'table {{.Repository}}\t{{.Tag}}\t{{.Size}}\t{{.CreatedSince}}' | grep %s || echo 'No images found'", config.ImageName)
 	
-	output, err := db.sshClient.ExecuteCommand(ctx, infoCmd)
+	output, err := db.runner.ExecuteCommand(ctx, infoCmd)
 	if err != nil {
 		return "", fmt.Errorf("getting image info: %w", err)
 	}
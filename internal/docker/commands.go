@@ -0,0 +1,285 @@
+package docker
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/artifacts"
+	"github.com/scttfrdmn/geoschem-aws/internal/redact"
+)
+
+// cloneCommand clones the source repository into ~/source with its submodules (GCClassic
+// keeps GEOS-Chem, HEMCO and Cloud-J in them), each at depth 1. Submodules with an override
+// then fetch and check out that ref instead of the commit the branch pins, and the patches
+// are applied last.
+func cloneCommand(config *BuildConfig) string {
+	git := "git"
+	if config.SourceTokenSecret != "" {
+		// The helper answers every HTTPS prompt, submodules' included, from the variable;
+		// the empty helper first drops any configured one that would store the token
+		git += " -c credential.helper= -c " +
+			shellQuote(`credential.helper=!f() { echo username=x-access-token; echo "password=$GIT_TOKEN"; }; f`)
+	}
+	commands := []string{fmt.Sprintf("%s clone --depth 1 --recurse-submodules --shallow-submodules --branch %s %s ~/source",
+		git, config.SourceBranch, config.SourceRepo)}
+
+	paths := make([]string, 0, len(config.SourceSubmodules))
+	for path := range config.SourceSubmodules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		dir := "~/source/" + shellQuote(path)
+		commands = append(commands,
+			fmt.Sprintf("%s -C %s fetch --depth 1 origin %s", git, dir, shellQuote(config.SourceSubmodules[path])),
+			fmt.Sprintf("git -C %s checkout --quiet --detach FETCH_HEAD", dir))
+	}
+	if patch := patchCommand(config); patch != "" {
+		commands = append(commands, patch)
+	}
+	return withSourceCredentials(config, strings.Join(commands, " && "))
+}
+
+// withSourceCredentials runs command with the private repository's credential, fetched from
+// Secrets Manager inside a subshell and gone when it exits, so neither the checkout's git
+// config nor the image holds it
+func withSourceCredentials(config *BuildConfig, command string) string {
+	switch {
+	case config.SourceTokenSecret != "":
+		return fmt.Sprintf("(%s; GIT_TOKEN=$(%s) && export GIT_TOKEN && %s)",
+			imdsFunction, secretValueCommand(config.SourceTokenSecret), command)
+	case config.SourceDeployKeySecret != "":
+		sshCommand := fmt.Sprintf("ssh -i ~/%s -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new", sourceKeyFile)
+		return fmt.Sprintf("(%s; trap %s EXIT; (umask 077 && %s > ~/%s) && export GIT_SSH_COMMAND=%s %s && %s)",
+			imdsFunction, shellQuote("rm -f ~/"+sourceKeyFile), secretValueCommand(config.SourceDeployKeySecret),
+			sourceKeyFile, shellQuote(sshCommand), githubSSHOverHTTPSPort, command)
+	}
+	return command
+}
+
+// githubSSHOverHTTPSPort sends SSH clones from GitHub to its SSH endpoint on port 443, since
+// the instances' security group lets HTTPS out but not port 22. It rewrites both forms of
+// GitHub URL through git's environment config, leaving other SSH hosts on port 22.
+const githubSSHOverHTTPSPort = "GIT_CONFIG_COUNT=2" +
+	" GIT_CONFIG_KEY_0=url.ssh://git@ssh.github.com:443/.insteadOf GIT_CONFIG_VALUE_0=git@github.com:" +
+	" GIT_CONFIG_KEY_1=url.ssh://git@ssh.github.com:443/.insteadOf GIT_CONFIG_VALUE_1=ssh://git@github.com/"
+
+// sourceKeyFile holds a deploy key for the length of a clone, relative to the home directory
+const sourceKeyFile = ".geoschem-deploy-key"
+
+// secretValueCommand prints a Secrets Manager secret on the build host, read in the secret
+// ARN's region or else the host's. It needs imdsFunction defined.
+func secretValueCommand(secret string) string {
+	region := `"$(imds placement/region)"`
+	if parts := strings.Split(secret, ":"); len(parts) > 3 && strings.HasPrefix(secret, "arn:") {
+		region = shellQuote(parts[3])
+	}
+	return fmt.Sprintf("aws secretsmanager get-secret-value --region %s --secret-id %s --query SecretString --output text",
+		region, shellQuote(secret))
+}
+
+// sourceArchiveFile is where an uploaded source tree lands, relative to the home directory
+// (the SSH session's working directory)
+const sourceArchiveFile = "source.tar.gz"
+
+// unpackSourceCommand replaces ~/source with the uploaded source tree
+func unpackSourceCommand() string {
+	return fmt.Sprintf("rm -rf ~/source && mkdir ~/source && tar -xzf ~/%[1]s -C ~/source && rm -f ~/%[1]s", sourceArchiveFile)
+}
+
+// hostArtifacts lists the temporary files a build of config leaves on its host
+func hostArtifacts(config *BuildConfig) []string {
+	return []string{"~/source", "~/" + sourceArchiveFile, "~/" + sourceKeyFile, "~/" + patchesFile, "~/" + pullRequestDiffFile, "~/" + localDockerfileFile, digestFile(config), pushConfFile, pushLogFile}
+}
+
+// DependenciesImageArg is the build argument naming the dependencies image a model
+// Dockerfile builds FROM
+const DependenciesImageArg = "DEPS_IMAGE"
+
+// DockerfileName returns the Dockerfile to build inside the build context
+func (c *BuildConfig) DockerfileName() string {
+	if c.Dockerfile == "" {
+		return "Dockerfile"
+	}
+	return c.Dockerfile
+}
+
+// buildContextDir returns the directory holding the Dockerfile inside the checkout
+func buildContextDir(config *BuildConfig) string {
+	return filepath.Join("~/source", config.DockerfileDir)
+}
+
+// ccacheHostDir holds the compiler cache on the build host; it is mounted into RUN steps
+const ccacheHostDir = "$HOME/.cache/geoschem-ccache"
+
+// ccacheMaxSize bounds the cache so the S3 round trip stays fast
+const ccacheMaxSize = "5G"
+
+// ccacheObject returns the S3 object for the image's cache. Objects are only reusable with the
+// same compiler and architecture, which the image name encodes.
+func ccacheObject(config *BuildConfig) string {
+	return fmt.Sprintf("%s/ccache-%s.tar.gz", strings.TrimSuffix(config.CcacheURI, "/"), config.ImageName)
+}
+
+// ccacheRestoreCommand downloads the cache; a missing object starts a cold cache
+func ccacheRestoreCommand(config *BuildConfig) string {
+	return fmt.Sprintf("mkdir -p %[1]s && "+
+		"if aws s3 cp %[2]s - 2>/dev/null | tar -xzf - -C %[1]s; then echo \"Restored compiler cache ($(du -sh %[1]s | cut -f1))\"; "+
+		"else echo \"No compiler cache at %[2]s, starting cold\"; fi",
+		ccacheHostDir, shellQuote(ccacheObject(config)))
+}
+
+// ccacheSaveCommand uploads the cache for later builds
+func ccacheSaveCommand(config *BuildConfig) string {
+	return fmt.Sprintf("tar -czf - -C %s . | aws s3 cp - %s", ccacheHostDir, shellQuote(ccacheObject(config)))
+}
+
+// SecretBuildArgs returns the values of build args whose names look like credentials
+func (c *BuildConfig) SecretBuildArgs() []string {
+	var values []string
+	for key, value := range c.BuildArgs {
+		if redact.IsSecretName(key) {
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// buildCommand returns the podman build command (Rocky Linux 9 uses Podman)
+func buildCommand(config *BuildConfig, buildDir string) string {
+	var cmd strings.Builder
+	cmd.WriteString("podman build")
+
+	// Mount the compiler cache into RUN steps; the Dockerfile enables ccache when CCACHE_DIR is set
+	if config.CcacheURI != "" {
+		cmd.WriteString(fmt.Sprintf(" -v %s:/ccache:Z --build-arg CCACHE_DIR=/ccache --build-arg CCACHE_MAXSIZE=%s",
+			ccacheHostDir, ccacheMaxSize))
+	}
+
+	// Add build arguments in a stable order (properly escape values with shell-sensitive characters)
+	keys := make([]string, 0, len(config.BuildArgs))
+	for key := range config.BuildArgs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := config.BuildArgs[key]
+		if key == BaseImageArg {
+			value = cachedImage(value, config.PullThrough)
+		}
+		cmd.WriteString(fmt.Sprintf(" --build-arg %s=%s", key, shellQuote(value)))
+	}
+
+	// Add Dockerfile, image tag, and build context
+	if config.DockerfileContent != "" {
+		cmd.WriteString(" -f ~/" + localDockerfileFile)
+	} else if config.Dockerfile != "" {
+		cmd.WriteString(" -f " + shellQuote(config.Dockerfile))
+	}
+	cmd.WriteString(fmt.Sprintf(" -t %s:%s .", config.ImageName, config.ImageTag))
+	return fmt.Sprintf("cd %s && %s", buildDir, watchDiskSpace(cmd.String()))
+}
+
+// archTagCommand tags the image with its architecture-specific tag
+func archTagCommand(config *BuildConfig) string {
+	return fmt.Sprintf("podman tag %s %s", config.LocalImage(), config.LocalArchImage())
+}
+
+// ecrLoginCommand logs podman in to the registry of an ECR repository
+func ecrLoginCommand(ecrRepository string) (string, error) {
+	// Extract region from ECR repository URL
+	// Format: <account>.dkr.ecr.<region>.amazonaws.com/<repo>
+	parts := strings.Split(ecrRepository, ".")
+	if len(parts) < 4 {
+		return "", fmt.Errorf("invalid ECR repository format: %s", ecrRepository)
+	}
+	region := parts[3]
+
+	return fmt.Sprintf(
+		"aws ecr get-login-password --region %s | podman login --username AWS --password-stdin %s",
+		region, strings.Split(ecrRepository, "/")[0]), nil
+}
+
+// BuildScript returns a self-contained shell script that clones, builds, tags and (when
+// ecrRepository is set) pushes the image, for backends that run a build as one job
+func BuildScript(config *BuildConfig, ecrRepository string) (string, error) {
+	if config.SourceArchive != "" {
+		return "", fmt.Errorf("a local source tree can't be sent with a build script; build on an instance over SSH")
+	}
+	buildDir := buildContextDir(config)
+
+	lines := []string{
+		"set -euo pipefail",
+		// Mask credentials before the output reaches the job's logs (CloudWatch for Batch)
+		fmt.Sprintf("exec > >(sed -u -E %s) 2>&1", shellQuote(redact.SedProgram(config.SecretBuildArgs()...))),
+	}
+	// Batch reuses its hosts between jobs, so the checkout goes whether or not the build succeeds
+	if remove := artifacts.RemoteCommand(hostArtifacts(config)...); remove != "" {
+		lines = append(lines, fmt.Sprintf("trap %s EXIT", shellQuote(remove)))
+	}
+	lines = append(lines,
+		"rm -rf ~/source",
+		cloneCommand(config),
+		checkDockerfileCommand(config, buildDir),
+	)
+	if rewrite := rewriteFromCommand(config, buildDir); rewrite != "" {
+		lines = append(lines, rewrite)
+	}
+	lines = append(lines, preflightCommand())
+	if images := config.PrePullImages(); len(images) > 0 {
+		logins, err := pullThroughLogins(config)
+		if err != nil {
+			return "", err
+		}
+		for _, login := range logins {
+			lines = append(lines, login+" || echo 'Warning: pull-through cache login failed'")
+		}
+		for _, image := range images {
+			lines = append(lines, prepullCommand(image, config.PullThrough)+" || echo "+shellQuote("Warning: failed to pre-pull "+image))
+		}
+	}
+	if config.CcacheURI != "" {
+		lines = append(lines, ccacheRestoreCommand(config))
+	}
+	// podman build pulls the dependencies image itself, so log in to its registry first
+	if image := config.BuildArgs[DependenciesImageArg]; strings.Contains(image, ".dkr.ecr.") {
+		loginCmd, err := ecrLoginCommand(image)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, loginCmd)
+	}
+	lines = append(lines, buildCommand(config, buildDir))
+	lines = append(lines, lineageCommand(config)+" || echo 'Warning: failed to record lineage labels'")
+	if config.CcacheURI != "" {
+		lines = append(lines, ccacheSaveCommand(config)+" || echo 'Warning: failed to save compiler cache'")
+	}
+	lines = append(lines, archTagCommand(config))
+
+	if ecrRepository != "" {
+		loginCmd, err := ecrLoginCommand(ecrRepository)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, loginCmd)
+		push, retags, err := ecrPushPlan(config, ecrRepository)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, retryingPushCommand(push, loginCmd))
+		lines = append(lines, retags...)
+		if config.Push.Manifest {
+			lines = append(lines, manifestCommand(ManifestImage(config, ecrRepository), ManifestCandidates(config, ecrRepository)))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// shellQuote wraps a value in single quotes for the shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Disk space thresholds on the build host, in GB
+const (
+	buildFreeGB = 40 // Needed before a build starts: Spack stages, ccache, and image layers
+	abortFreeGB = 2  // A running build is stopped below this, before tools fail with ENOSPC
+)
+
+// enospcStatus is the exit status of a build stopped for lack of disk space (ENOSPC)
+const enospcStatus = 28
+
+// freeSpaceCommand prints the free GB of the fullest filesystem holding the home directory
+// (the checkout, compiler cache, and rootless podman storage) or the rootful container
+// storage under /var/lib
+const freeSpaceCommand = `df -Pk "$HOME" /var/lib 2>/dev/null | awk 'NR > 1 && (min == "" || $4 < min) { min = $4 } END { print int(min / 1048576) }'`
+
+// diskSpaceMessage tells the user how to give builds more room. free is the free GB, or a
+// shell expansion of it in scripts.
+func diskSpaceMessage(free string, neededGB int) string {
+	return fmt.Sprintf("build host has %s GB free, builds need at least %d GB; increase root_volume_gb for the architecture (or the Batch compute environment's volume size)",
+		free, neededGB)
+}
+
+// preflightCommand fails a build script early when the host lacks the space a build needs
+func preflightCommand() string {
+	return fmt.Sprintf(`free=$(%s); if [ "${free:-%[2]d}" -lt %[2]d ]; then echo "ERROR: %[3]s" >&2; exit %[4]d; fi`,
+		freeSpaceCommand, buildFreeGB, diskSpaceMessage("${free}", buildFreeGB), enospcStatus)
+}
+
+// freeSpace returns the free space on the build host in GB
+func (db *DockerBuilder) freeSpace(ctx context.Context) (int, error) {
+	output, err := db.runner.ExecuteCommand(ctx, freeSpaceCommand)
+	if err != nil {
+		return 0, fmt.Errorf("checking free disk space: %w, output: %s", err, output)
+	}
+	free, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected free disk space output: %q", output)
+	}
+	return free, nil
+}
+
+// checkDiskSpace fails when the build host has less than neededGB free
+func (db *DockerBuilder) checkDiskSpace(ctx context.Context, neededGB int) error {
+	free, err := db.freeSpace(ctx)
+	if err != nil {
+		return err
+	}
+	if free < neededGB {
+		return errors.New(diskSpaceMessage(strconv.Itoa(free), neededGB))
+	}
+	return nil
+}
+
+// watchDiskSpace wraps a command so it is stopped, with a clear message and the ENOSPC exit
+// status, once free space drops below abortFreeGB
+func watchDiskSpace(command string) string {
+	return fmt.Sprintf("{ %s & pid=$!; "+
+		"while kill -0 $pid 2>/dev/null; do "+
+		"free=$(%s); "+
+		"if [ \"${free:-%[3]d}\" -lt %[3]d ]; then "+
+		"echo \"ERROR: build host is out of disk space (${free} GB free); stopping the build. Increase root_volume_gb for the architecture.\" >&2; "+
+		"kill $pid; wait $pid || true; exit %[4]d; fi; "+
+		"sleep 15; done; wait $pid; }",
+		command, freeSpaceCommand, abortFreeGB, enospcStatus)
+}
@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// LabelDockerfile records the Dockerfile an image was built from: its path in the checkout,
+// or the local file uploaded in its place
+const LabelDockerfile = "geoschem.build.dockerfile"
+
+// MaxDockerfileBytes bounds a local Dockerfile, which travels inside the build command like
+// the patches do
+const MaxDockerfileBytes = 16 << 10
+
+// localDockerfileFile holds an uploaded local Dockerfile, relative to the home directory
+const localDockerfileFile = ".geoschem-Dockerfile"
+
+// dockerfilePath returns the Dockerfile the build uses on the host
+func dockerfilePath(config *BuildConfig, buildDir string) string {
+	if config.DockerfileContent != "" {
+		return "~/" + localDockerfileFile
+	}
+	return buildDir + "/" + config.DockerfileName()
+}
+
+// dockerfileLabel returns what LabelDockerfile records for the build
+func dockerfileLabel(config *BuildConfig) string {
+	if config.DockerfileContent != "" {
+		return config.DockerfileLabel
+	}
+	return path.Join(config.DockerfileDir, config.DockerfileName())
+}
+
+// checkDockerfileCommand writes an uploaded Dockerfile to the host, then fails unless the
+// build context and Dockerfile are there
+func checkDockerfileCommand(config *BuildConfig, buildDir string) string {
+	check := fmt.Sprintf("test -d %s && test -f %s", buildDir, dockerfilePath(config, buildDir))
+	if config.DockerfileContent == "" {
+		return check
+	}
+	return fmt.Sprintf("echo %s | base64 -d > ~/%s && %s",
+		base64.StdEncoding.EncodeToString([]byte(config.DockerfileContent)), localDockerfileFile, check)
+}
+
+// optionalBuildArgs may be declared without defaults since only some builds set them: the
+// compiler cache, the dependency image and the platform and proxy ARGs podman predefines
+var optionalBuildArgs = map[string]bool{
+	"CCACHE_DIR": true, "CCACHE_MAXSIZE": true, DependenciesImageArg: true,
+	"TARGETPLATFORM": true, "TARGETOS": true, "TARGETARCH": true, "TARGETVARIANT": true,
+	"BUILDPLATFORM": true, "BUILDOS": true, "BUILDARCH": true, "BUILDVARIANT": true,
+	"HTTP_PROXY": true, "HTTPS_PROXY": true, "FTP_PROXY": true, "NO_PROXY": true, "ALL_PROXY": true,
+}
+
+// MissingBuildArgs returns the ARGs the Dockerfile declares without a default that the build
+// args don't set, which would otherwise build with empty values. An ARG redeclared in a stage
+// takes the default of its global declaration, so a default anywhere counts.
+func MissingBuildArgs(dockerfile string, buildArgs map[string]string) []string {
+	defaulted := make(map[string]bool)
+	var declared []string
+	for _, line := range strings.Split(strings.ReplaceAll(dockerfile, "\\\n", " "), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "ARG") {
+			continue
+		}
+		for _, field := range fields[1:] {
+			name, _, hasDefault := strings.Cut(field, "=")
+			if hasDefault {
+				defaulted[name] = true
+			} else {
+				declared = append(declared, name)
+			}
+		}
+	}
+
+	var missing []string
+	for _, name := range declared {
+		if _, set := buildArgs[name]; set || defaulted[name] || optionalBuildArgs[strings.ToUpper(name)] {
+			continue
+		}
+		if !slices.Contains(missing, name) {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
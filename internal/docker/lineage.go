@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lineage labels recorded on every built image, so a running container can be traced back
+// to how it was produced. The org.opencontainers.image keys are the OCI standard annotations.
+const (
+	LabelCreated      = "org.opencontainers.image.created"  // Build time, RFC 3339 UTC
+	LabelSource       = "org.opencontainers.image.source"   // Source repository URL
+	LabelVersion      = "org.opencontainers.image.version"  // Source branch or tag
+	LabelRevision     = "org.opencontainers.image.revision" // Source commit
+	LabelInstanceType = "geoschem.build.instance_type"
+	LabelAMI          = "geoschem.build.ami"
+	LabelRegion       = "geoschem.build.region"
+	LabelCompilers    = "geoschem.build.compilers"         // --version of each compiler in the image
+	LabelSpackLock    = "geoschem.build.spack_lock_sha256" // Hash of the concretized Spack stack
+)
+
+// lineageProbe runs inside the built image and prints the compilers it ships and a hash of
+// its Spack lockfiles: environment spack.lock files, and the stack recorded by Dockerfile.deps
+const lineageProbe = `c=""; for cc in gcc g++ gfortran icx ifx nvc nvfortran armclang armflang; do ` +
+	`v=$(command -v $cc >/dev/null 2>&1 && $cc --version 2>/dev/null | head -1); [ -n "$v" ] && c="$c${c:+; }$v"; done; ` +
+	`echo "compilers=$c"; ` +
+	`locks=$(ls /opt/spack/var/spack/environments/*/spack.lock /opt/spack/geoschem-spack.lock 2>/dev/null); ` +
+	`if [ -n "$locks" ]; then echo "spack_lock=$(cat $locks | sha256sum | cut -d" " -f1)"; fi`
+
+// imdsFunction defines imds, which prints an instance metadata value via IMDSv2, or
+// "unknown" off EC2
+const imdsFunction = `token=$(curl -sf -m 2 -X PUT -H 'X-aws-ec2-metadata-token-ttl-seconds: 60' http://169.254.169.254/latest/api/token || true); ` +
+	`imds() { curl -sf -m 2 -H "X-aws-ec2-metadata-token: $token" "http://169.254.169.254/latest/meta-data/$1" || echo unknown; }`
+
+// lineageCommand relabels the built image with its lineage. Labels are added by building
+// FROM the image with --label, which adds no layers, so any Dockerfile can be labelled. It
+// runs in a subshell, so failing doesn't end a build script.
+func lineageCommand(config *BuildConfig) string {
+	image := config.LocalImage()
+	revision := `"$(git -C ~/source rev-parse HEAD 2>/dev/null || echo unknown)"`
+	if config.SourceRevision != "" {
+		revision = shellQuote(config.SourceRevision)
+	}
+	labels := []string{
+		fmt.Sprintf(`--label "%s=$(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ)"`, LabelCreated),
+		fmt.Sprintf("--label %s", shellQuote(LabelSource+"="+config.SourceRepo)),
+		fmt.Sprintf("--label %s", shellQuote(LabelVersion+"="+config.SourceBranch)),
+		fmt.Sprintf("--label %s=%s", LabelRevision, revision),
+		fmt.Sprintf("--label %s", shellQuote(LabelDockerfile+"="+dockerfileLabel(config))),
+		fmt.Sprintf(`--label "%s=$(imds instance-type)"`, LabelInstanceType),
+		fmt.Sprintf(`--label "%s=$(imds ami-id)"`, LabelAMI),
+		fmt.Sprintf(`--label "%s=$(imds placement/region)"`, LabelRegion),
+		fmt.Sprintf(`--label "%s=$compilers"`, LabelCompilers),
+		fmt.Sprintf(`--label "%s=$spack_lock"`, LabelSpackLock),
+	}
+	if len(config.SourcePatches) > 0 {
+		labels = append(labels, fmt.Sprintf(`--label "%s=$(paste -sd, ~/%s)"`, LabelPatches, patchesFile))
+	}
+
+	return "(\n" + strings.Join([]string{
+		fmt.Sprintf("probe=$(podman run --rm --entrypoint /bin/sh %s -c %s 2>/dev/null || true)", image, shellQuote(lineageProbe)),
+		`compilers=$(echo "$probe" | sed -n 's/^compilers=//p')`,
+		`spack_lock=$(echo "$probe" | sed -n 's/^spack_lock=//p')`,
+		imdsFunction,
+		`context=$(mktemp -d)`,
+		fmt.Sprintf(`echo "FROM %s" > "$context/Containerfile"`, image),
+		fmt.Sprintf(`podman build --quiet %s -t %s "$context"; status=$?`, strings.Join(labels, " "), image),
+		`rm -rf "$context"`,
+		`exit $status`,
+	}, "\n") + "\n)"
+}
@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// manifestArchitectures are the architectures a multi-arch manifest list combines
+var manifestArchitectures = []string{"x86_64", "arm64"}
+
+// withoutArch drops the architecture field from a '-' separated image name
+func withoutArch(name, arch string) string {
+	var kept []string
+	for _, field := range strings.Split(name, "-") {
+		if field != arch {
+			kept = append(kept, field)
+		}
+	}
+	return strings.Join(kept, "-")
+}
+
+// ManifestImage returns the ECR reference of the multi-arch manifest list an image belongs
+// to: its main reference without the architecture. Under the per-arch strategy the list goes
+// to the base repository, which must exist.
+func ManifestImage(config *BuildConfig, ecrRepository string) string {
+	repository := strings.TrimSuffix(ecrRepository, "/")
+	name := withoutArch(config.ImageName, config.Architecture)
+	if config.RepositoryStrategy == RepoPerImage {
+		return fmt.Sprintf("%s/%s:%s", repository, name, CanonicalTag(config.ImageTag))
+	}
+	return fmt.Sprintf("%s:%s", repository, CanonicalTag(name, config.ImageTag))
+}
+
+// ManifestCandidates returns the main ECR reference of the image for every architecture,
+// whether or not each was built; a manifest list includes the ones ECR has
+func ManifestCandidates(config *BuildConfig, ecrRepository string) []string {
+	var images []string
+	for _, arch := range manifestArchitectures {
+		variant := *config
+		variant.Architecture = arch
+		variant.ImageName = strings.ReplaceAll("-"+config.ImageName+"-", "-"+config.Architecture+"-", "-"+arch+"-")
+		variant.ImageName = strings.Trim(variant.ImageName, "-")
+		images = append(images, ECRImages(&variant, ecrRepository)[0])
+	}
+	return images
+}
+
+// manifestCommand creates a manifest list from the images ECR has and pushes it with the
+// images it references. Images that were never pushed are skipped, and the command fails
+// when none were.
+func manifestCommand(target string, images []string) string {
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "podman manifest rm %s >/dev/null 2>&1; podman manifest create %s && added=0", target, target)
+	for _, image := range images {
+		fmt.Fprintf(&cmd, " && { if podman manifest add %s docker://%s; then added=$((added+1)); else echo %s; fi; }",
+			target, image, shellQuote("Skipping "+image+" (not in ECR)"))
+	}
+	fmt.Fprintf(&cmd, " && test $added -gt 0 && podman manifest push --all %s docker://%s", target, target)
+	return cmd.String()
+}
+
+// PushManifest assembles a manifest list from already-pushed images and pushes it, so one
+// tag pulls the right architecture
+func (db *DockerBuilder) PushManifest(ctx context.Context, target string, images []string) error {
+	fmt.Printf("🧩 Pushing multi-arch manifest list: %s\n", target)
+	if err := db.loginToECR(ctx, target); err != nil {
+		return fmt.Errorf("ECR login failed: %w", err)
+	}
+	if err := db.runner.ExecuteCommandStream(ctx, manifestCommand(target, images), os.Stdout, os.Stderr); err != nil {
+		return fmt.Errorf("pushing manifest list %s failed: %w", target, err)
+	}
+	fmt.Printf("✅ Pushed manifest list %s\n", target)
+	return nil
+}
+
+// LocalRunner runs commands with the local shell, for podman work that needs no build host
+type LocalRunner struct{}
+
+func (LocalRunner) ExecuteCommand(ctx context.Context, command string) (string, error) {
+	output, err := exec.CommandContext(ctx, "bash", "-c", command).CombinedOutput()
+	return string(output), err
+}
+
+func (LocalRunner) ExecuteCommandStream(ctx context.Context, command string, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
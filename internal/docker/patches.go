@@ -0,0 +1,57 @@
+package docker
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// LabelPatches lists the patches applied to the source, comma-separated
+const LabelPatches = "geoschem.source.patches"
+
+// MaxPatchBytes bounds the patch files a build carries. They travel inside the build
+// command, which SSM and Batch cap at tens of KiB; larger changes belong in a branch.
+const MaxPatchBytes = 16 << 10
+
+// patchesFile lists the applied patches for the labels, relative to the home directory
+const patchesFile = ".geoschem-patches"
+
+// pullRequestDiffFile holds a downloaded pull request while it's applied
+const pullRequestDiffFile = ".geoschem-pr.diff"
+
+// Patch is a change applied to the source after it's cloned or uploaded
+type Patch struct {
+	Dir         string // Checkout directory it applies in, relative to the top; empty is the top
+	Diff        string // Patch file contents
+	PullRequest string // owner/name#123 downloaded from GitHub instead of Diff
+	Label       string // How LabelPatches records it
+}
+
+// patchCommand applies the patches to ~/source in order and lists them in patchesFile.
+// Pull requests download with the clone token when there is one, for private forks.
+func patchCommand(config *BuildConfig) string {
+	if len(config.SourcePatches) == 0 {
+		return ""
+	}
+	commands := []string{"rm -f ~/" + patchesFile}
+	for _, patch := range config.SourcePatches {
+		apply := "git -C ~/source apply"
+		if patch.Dir != "" {
+			apply += " --directory=" + shellQuote(patch.Dir)
+		}
+		if patch.PullRequest == "" {
+			commands = append(commands,
+				fmt.Sprintf("echo %s | base64 -d | %s", base64.StdEncoding.EncodeToString([]byte(patch.Diff)), apply),
+				fmt.Sprintf("echo %s >> ~/%s", shellQuote(patch.Label), patchesFile))
+			continue
+		}
+		name, number, _ := strings.Cut(patch.PullRequest, "#")
+		commands = append(commands,
+			fmt.Sprintf(`curl -sfL -H 'Accept: application/vnd.github.diff' ${GIT_TOKEN:+-H "Authorization: Bearer $GIT_TOKEN"} -o ~/%s https://api.github.com/repos/%s/pulls/%s`,
+				pullRequestDiffFile, name, number),
+			fmt.Sprintf("%s ~/%s", apply, pullRequestDiffFile),
+			fmt.Sprintf(`echo "%s@sha256:$(sha256sum ~/%s | cut -c1-12)" >> ~/%s`, patch.Label, pullRequestDiffFile, patchesFile),
+			"rm -f ~/"+pullRequestDiffFile)
+	}
+	return strings.Join(commands, " && ")
+}
@@ -0,0 +1,157 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// dockerHub is the registry unqualified image names resolve to
+const dockerHub = "docker.io"
+
+// BaseImageArg is the build argument naming the image a Dockerfile builds FROM when it
+// doesn't build FROM a dependencies image
+const BaseImageArg = "BASE_IMAGE"
+
+// qualifyImage splits an image reference into its registry and its repository with tag,
+// following Docker's rules: a first component with a dot, a port, or "localhost" names the
+// registry, and single-component Docker Hub names live under library/
+func qualifyImage(image string) (registry, repository string) {
+	first, rest, found := strings.Cut(image, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first, rest
+	}
+	if !found {
+		return dockerHub, "library/" + image
+	}
+	return dockerHub, image
+}
+
+// cachedImage returns the reference to pull an image through the pull-through cache for its
+// registry, or the image itself when its registry isn't cached
+func cachedImage(image string, pullThrough map[string]string) string {
+	registry, repository := qualifyImage(image)
+	if prefix := pullThrough[registry]; prefix != "" {
+		return strings.TrimSuffix(prefix, "/") + "/" + repository
+	}
+	return image
+}
+
+// rewriteFromProgram is an awk program that points FROM lines naming images on cached
+// registries at their pull-through caches, given as -v caches="registry=prefix,...". Build
+// arguments and earlier stages are left alone; registries are resolved as qualifyImage does.
+const rewriteFromProgram = `BEGIN { n = split(caches, pairs, ","); for (i = 1; i <= n; i++) { split(pairs[i], kv, "="); cache[kv[1]] = kv[2] } }
+toupper($1) == "FROM" {
+  f = 2; while ($f ~ /^--/) f++
+  image = $f
+  if (image !~ /^\$/ && image != "scratch" && !(image in stage)) {
+    registry = "docker.io"; repository = image; slash = index(image, "/")
+    if (slash == 0) repository = "library/" image
+    else { first = substr(image, 1, slash - 1); if (first ~ /[.:]/ || first == "localhost") { registry = first; repository = substr(image, slash + 1) } }
+    if (registry in cache) $f = cache[registry] "/" repository
+  }
+  for (i = f + 1; i < NF; i++) if (tolower($i) == "as") stage[$(i + 1)] = 1
+}
+{ print }`
+
+// rewriteFromCommand rewrites the FROM lines of the checked-out Dockerfile to pull through
+// the configured caches; empty without caches
+func rewriteFromCommand(config *BuildConfig, buildDir string) string {
+	if len(config.PullThrough) == 0 {
+		return ""
+	}
+	registries := make([]string, 0, len(config.PullThrough))
+	for registry := range config.PullThrough {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	pairs := make([]string, len(registries))
+	for i, registry := range registries {
+		pairs[i] = registry + "=" + strings.TrimSuffix(config.PullThrough[registry], "/")
+	}
+
+	dockerfile := dockerfilePath(config, buildDir)
+	return fmt.Sprintf("awk -v caches=%s %s %s > %s.cached && mv %s.cached %s",
+		shellQuote(strings.Join(pairs, ",")), shellQuote(rewriteFromProgram), dockerfile, dockerfile, dockerfile, dockerfile)
+}
+
+// PrePullImages returns the images a build starts FROM: the base image, unless the build
+// starts from a dependencies image pulled separately, and the configured extra images
+func (c *BuildConfig) PrePullImages() []string {
+	var images []string
+	if base := c.BuildArgs[BaseImageArg]; base != "" && c.BuildArgs[DependenciesImageArg] == "" {
+		images = append(images, base)
+	}
+	for _, image := range c.Prepull {
+		if image != "" && !slices.Contains(images, image) {
+			images = append(images, image)
+		}
+	}
+	return images
+}
+
+// prepullCommand pulls an image, through the pull-through cache for its registry when one is
+// configured, and tags it with its upstream name so FROM lines resolve to the local copy.
+// Pulls are retried, since Docker Hub rate limits fail them intermittently.
+func prepullCommand(image string, pullThrough map[string]string) string {
+	registry, repository := qualifyImage(image)
+	upstream := registry + "/" + repository
+	source := upstream
+	if cached := cachedImage(image, pullThrough); cached != image {
+		source = cached
+	}
+
+	pull := "podman pull --quiet " + shellQuote(source)
+	if source != upstream {
+		pull += " && podman tag " + shellQuote(source) + " " + shellQuote(upstream)
+	}
+	return fmt.Sprintf("(for attempt in 1 2 3; do (%s) && break; [ $attempt = 3 ] && exit 1; sleep $((attempt * 20)); done)", pull)
+}
+
+// pullThroughLogins returns the ECR logins the configured pull-through caches need
+func pullThroughLogins(config *BuildConfig) ([]string, error) {
+	var logins []string
+	registries := make([]string, 0, len(config.PullThrough))
+	for registry := range config.PullThrough {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	for _, registry := range registries {
+		login, err := ecrLoginCommand(config.PullThrough[registry])
+		if err != nil {
+			return nil, fmt.Errorf("pull-through cache for %s: %w", registry, err)
+		}
+		if !slices.Contains(logins, login) {
+			logins = append(logins, login)
+		}
+	}
+	return logins, nil
+}
+
+// PrePull pulls the images a build starts FROM before it runs, from pull-through caches
+// where configured. A failed pull only costs the build the time to pull it itself.
+func (db *DockerBuilder) PrePull(ctx context.Context, config *BuildConfig) {
+	images := config.PrePullImages()
+	if len(images) == 0 {
+		return
+	}
+
+	logins, err := pullThroughLogins(config)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	for _, login := range logins {
+		if output, err := db.runner.ExecuteCommand(ctx, login); err != nil {
+			fmt.Printf("Warning: pull-through cache login failed: %v, output: %s\n", err, output)
+		}
+	}
+
+	for _, image := range images {
+		fmt.Printf("📥 Pre-pulling %s\n", image)
+		if output, err := db.runner.ExecuteCommand(ctx, prepullCommand(image, config.PullThrough)); err != nil {
+			fmt.Printf("Warning: Failed to pre-pull %s: %v, output: %s\n", image, err, output)
+		}
+	}
+}
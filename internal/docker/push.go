@@ -0,0 +1,120 @@
+package docker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PushOptions tunes how images are uploaded to ECR
+type PushOptions struct {
+	ParallelUploads  int    // Layers uploaded at once; 0 lets podman decide
+	Compression      string // gzip, zstd or zstd:chunked; empty keeps podman's default (gzip)
+	CompressionLevel int    // 0 uses the format's default
+	Manifest         bool   // Also push a multi-arch manifest list of the architectures' images (see ManifestImage)
+}
+
+// ecrAuthFailurePattern matches registry errors a fresh ECR login fixes, chiefly the
+// 12-hour authorization token expiring during a long build. It's used with grep -E too.
+const ecrAuthFailurePattern = "authorization token has expired|authentication required|unauthorized|no basic auth credentials"
+
+var ecrAuthFailure = regexp.MustCompile("(?i)" + ecrAuthFailurePattern)
+
+// maxPushAttempts bounds pushes retried after renewing the ECR login
+const maxPushAttempts = 2
+
+// isECRAuthFailure reports whether push output shows the ECR login needs renewing
+func isECRAuthFailure(output string) bool {
+	return ecrAuthFailure.MatchString(output)
+}
+
+// pushLogFile keeps the output of a scripted push to look for auth failures in
+const pushLogFile = "/tmp/geoschem-push.log"
+
+// retryingPushCommand runs push, and when it fails because the ECR login expired, logs in
+// again with loginCmd and pushes once more, for build scripts that can't be driven step by step
+func retryingPushCommand(push, loginCmd string) string {
+	return fmt.Sprintf("if ! { %s; } 2>&1 | tee %s; then "+
+		"grep -qiE '%s' %s || exit 1; "+
+		"echo 'ECR login expired during the push; logging in again and retrying'; %s && %s; fi",
+		push, pushLogFile, ecrAuthFailurePattern, pushLogFile, loginCmd, push)
+}
+
+// pushConfFile is the containers.conf override setting layer upload concurrency for a push
+const pushConfFile = "/tmp/geoschem-push.conf"
+
+// pushCommand pushes an image once, recording its manifest digest in digestFile so further
+// tags can be added without uploading it again
+func pushCommand(options PushOptions, image, digestFile string) string {
+	args := []string{"podman", "push", "--digestfile", digestFile}
+	if options.Compression != "" {
+		args = append(args, "--compression-format", options.Compression, "--force-compression")
+	}
+	if options.CompressionLevel > 0 {
+		args = append(args, "--compression-level", fmt.Sprint(options.CompressionLevel))
+	}
+	command := strings.Join(append(args, image), " ")
+
+	if options.ParallelUploads > 0 {
+		// image_parallel_copies has no push flag; CONTAINERS_CONF_OVERRIDE applies it to this
+		// push only
+		return fmt.Sprintf("printf '[engine]\\nimage_parallel_copies = %d\\n' > %s && CONTAINERS_CONF_OVERRIDE=%s %s",
+			options.ParallelUploads, pushConfFile, pushConfFile, command)
+	}
+	return command
+}
+
+// ecrImageRef splits an ECR image reference into region, repository name and tag
+func ecrImageRef(image string) (region, repository, tag string, err error) {
+	registry, path, ok := strings.Cut(image, "/")
+	parts := strings.Split(registry, ".")
+	colon := strings.LastIndex(path, ":")
+	if !ok || len(parts) < 4 || parts[1] != "dkr" || colon < 0 {
+		return "", "", "", fmt.Errorf("invalid ECR image reference: %s", image)
+	}
+	return parts[3], path[:colon], path[colon+1:], nil
+}
+
+// retagCommand adds image's tag to the manifest pushed as digestFile's digest with ECR
+// PutImage, so no layers are uploaded twice. PutImage rejects a tag that already points at
+// the manifest, which counts as success.
+func retagCommand(image, digestFile string) (string, error) {
+	region, repository, tag, err := ecrImageRef(image)
+	if err != nil {
+		return "", err
+	}
+	// Command substitution drops the newline text output adds, keeping the manifest's bytes
+	// and so its digest, which --image-digest checks
+	return fmt.Sprintf("manifest=$(aws ecr batch-get-image --region %s --repository-name %s --image-ids imageDigest=$(cat %s) --query 'images[0].imageManifest' --output text) && "+
+		"{ out=$(aws ecr put-image --region %s --repository-name %s --image-tag %s --image-digest $(cat %s) --image-manifest \"$manifest\" 2>&1) || "+
+		"echo \"$out\" | grep -q ImageAlreadyExistsException || { echo \"$out\" >&2; false; }; }",
+		region, repository, digestFile, region, repository, tag, digestFile), nil
+}
+
+// digestFile is where a push records the manifest digest later tags point at
+func digestFile(config *BuildConfig) string {
+	return fmt.Sprintf("/tmp/%s.digest", CanonicalTag(config.ImageName, config.ImageTag))
+}
+
+// ecrPushPlan returns the commands that tag and push the image once under its main reference,
+// and the commands that add the remaining references. References in another repository
+// than the main one are pushed, since PutImage can't reach across repositories.
+func ecrPushPlan(config *BuildConfig, ecrRepository string) (push string, retags []string, err error) {
+	images := ECRImages(config, ecrRepository)
+	digestFile := digestFile(config)
+	push = fmt.Sprintf("podman tag %s %s && %s", config.LocalImage(), images[0], pushCommand(config.Push, images[0], digestFile))
+
+	mainRepository, _, _ := strings.Cut(images[0], ":")
+	for _, image := range images[1:] {
+		if repository, _, _ := strings.Cut(image, ":"); repository != mainRepository {
+			retags = append(retags, fmt.Sprintf("podman tag %s %s && %s", config.LocalImage(), image, pushCommand(config.Push, image, digestFile)))
+			continue
+		}
+		retag, err := retagCommand(image, digestFile)
+		if err != nil {
+			return "", nil, err
+		}
+		retags = append(retags, retag)
+	}
+	return push, retags, nil
+}
@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Repository strategies decide how images are laid out in ECR
+const (
+	RepoSingle   = "single"    // Every image in one repository; tags carry the image name
+	RepoPerArch  = "per-arch"  // One repository per architecture (<repository>-<arch>)
+	RepoPerImage = "per-image" // One repository per image (<repository>/<image name>)
+)
+
+// maxTagLength is the longest tag registries accept
+const maxTagLength = 128
+
+var invalidTagChars = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// ValidateRepositoryStrategy checks a strategy name; empty selects RepoSingle
+func ValidateRepositoryStrategy(strategy string) error {
+	switch strategy {
+	case "", RepoSingle, RepoPerArch, RepoPerImage:
+		return nil
+	default:
+		return fmt.Errorf("unknown ECR repository strategy '%s' (expected %s, %s, or %s)",
+			strategy, RepoSingle, RepoPerArch, RepoPerImage)
+	}
+}
+
+// CanonicalTag joins parts into a valid image tag: lower case, '-' separated, without
+// empty parts or characters registries reject, and at most 128 characters
+func CanonicalTag(parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		part = strings.Trim(invalidTagChars.ReplaceAllString(strings.ToLower(part), "-"), "-.")
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+
+	tag := strings.Join(kept, "-")
+	if len(tag) > maxTagLength {
+		tag = strings.TrimRight(tag[:maxTagLength], "-.")
+	}
+	if tag == "" {
+		return "latest"
+	}
+	return tag
+}
+
+// ArchTag returns the architecture-specific form of a tag. Tags that already name the
+// architecture (e.g. because the image name does) are returned unchanged.
+func ArchTag(tag, arch string) string {
+	for _, field := range strings.Split(tag, "-") {
+		if field == arch {
+			return tag
+		}
+	}
+	return CanonicalTag(tag, arch)
+}
+
+// LocalImage returns the reference the image is built as on the build host
+func (c *BuildConfig) LocalImage() string {
+	return fmt.Sprintf("%s:%s", c.ImageName, c.ImageTag)
+}
+
+// LocalArchImage returns the architecture-specific local reference
+func (c *BuildConfig) LocalArchImage() string {
+	return fmt.Sprintf("%s:%s", c.ImageName, ArchTag(c.ImageTag, c.Architecture))
+}
+
+// ECRImages returns the references the image is pushed as under the config's repository
+// strategy, main reference first. Duplicates are dropped, so an image whose tag already
+// names its architecture is pushed once.
+func ECRImages(config *BuildConfig, ecrRepository string) []string {
+	repository := strings.TrimSuffix(ecrRepository, "/")
+
+	var tag string
+	switch config.RepositoryStrategy {
+	case RepoPerArch:
+		repository = fmt.Sprintf("%s-%s", repository, config.Architecture)
+		tag = CanonicalTag(config.ImageName, config.ImageTag)
+	case RepoPerImage:
+		repository = fmt.Sprintf("%s/%s", repository, config.ImageName)
+		tag = CanonicalTag(config.ImageTag)
+	default:
+		tag = CanonicalTag(config.ImageName, config.ImageTag)
+	}
+
+	images := []string{fmt.Sprintf("%s:%s", repository, tag)}
+	if archTag := ArchTag(tag, config.Architecture); archTag != tag {
+		images = append(images, fmt.Sprintf("%s:%s", repository, archTag))
+	}
+	return images
+}
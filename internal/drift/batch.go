@@ -0,0 +1,129 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+type batchResource struct {
+	State        string `json:"state"`
+	Status       string `json:"status"`
+	StatusReason string `json:"statusReason"`
+}
+
+type computeEnvironment struct {
+	batchResource
+	Name string `json:"computeEnvironmentName"`
+	ARN  string `json:"computeEnvironmentArn"`
+}
+
+type jobQueue struct {
+	batchResource
+	ComputeEnvironmentOrder []struct {
+		ComputeEnvironment string `json:"computeEnvironment"`
+	} `json:"computeEnvironmentOrder"`
+}
+
+type jobDefinition struct {
+	Revision            int `json:"revision"`
+	ContainerProperties struct {
+		Privileged bool `json:"privileged"`
+	} `json:"containerProperties"`
+}
+
+// Batch checks the Batch resources the configuration names against what builds and runs
+// need: an enabled, valid compute environment and queues, the build queue backed by the
+// configured compute environment, and an active, privileged build job definition
+func Batch(ctx context.Context, cli *awscli.Client, config *common.BuildConfig) ([]Finding, error) {
+	var findings []Finding
+	add := func(resource, format string, args ...interface{}) {
+		findings = append(findings, Finding{Source: "batch", Resource: resource, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var environment *computeEnvironment
+	if name := config.Batch.ComputeEnvironment; name != "" {
+		var out struct {
+			ComputeEnvironments []computeEnvironment `json:"computeEnvironments"`
+		}
+		if err := cli.Run(ctx, &out, "batch", "describe-compute-environments", "--compute-environments", name); err != nil {
+			return nil, err
+		}
+		if len(out.ComputeEnvironments) == 0 {
+			add("compute environment "+name, "doesn't exist")
+		} else {
+			environment = &out.ComputeEnvironments[0]
+			checkUsable(add, "compute environment "+name, environment.batchResource)
+		}
+	}
+
+	queues := map[string]bool{}
+	for _, name := range []string{config.Batch.JobQueue, config.Runs.Batch.JobQueue} {
+		if name == "" || queues[name] {
+			continue
+		}
+		queues[name] = true
+
+		var out struct {
+			JobQueues []jobQueue `json:"jobQueues"`
+		}
+		if err := cli.Run(ctx, &out, "batch", "describe-job-queues", "--job-queues", name); err != nil {
+			return nil, err
+		}
+		if len(out.JobQueues) == 0 {
+			add("job queue "+name, "doesn't exist")
+			continue
+		}
+		queue := out.JobQueues[0]
+		checkUsable(add, "job queue "+name, queue.batchResource)
+		if name == config.Batch.JobQueue && environment != nil && !queueUses(queue, environment) {
+			add("job queue "+name, "no longer sends jobs to compute environment %s", environment.Name)
+		}
+	}
+
+	if name := config.Batch.JobDefinition; name != "" {
+		var out struct {
+			JobDefinitions []jobDefinition `json:"jobDefinitions"`
+		}
+		if err := cli.Run(ctx, &out, "batch", "describe-job-definitions", "--job-definition-name", name, "--status", "ACTIVE"); err != nil {
+			return nil, err
+		}
+		if len(out.JobDefinitions) == 0 {
+			add("job definition "+name, "has no active revision")
+		} else {
+			latest := out.JobDefinitions[0]
+			for _, definition := range out.JobDefinitions[1:] {
+				if definition.Revision > latest.Revision {
+					latest = definition
+				}
+			}
+			if !latest.ContainerProperties.Privileged {
+				add(fmt.Sprintf("job definition %s:%d", name, latest.Revision), "isn't privileged; builds run podman inside the container")
+			}
+		}
+	}
+	return findings, nil
+}
+
+// checkUsable flags a compute environment or queue that's disabled or invalid
+func checkUsable(add func(string, string, ...interface{}), resource string, r batchResource) {
+	if r.State != "ENABLED" {
+		add(resource, "state is %s, expected ENABLED", r.State)
+	}
+	if r.Status != "VALID" && r.Status != "UPDATING" {
+		add(resource, "status is %s: %s", r.Status, r.StatusReason)
+	}
+}
+
+// queueUses reports whether a queue sends jobs to the compute environment, named by ARN or name
+func queueUses(queue jobQueue, environment *computeEnvironment) bool {
+	for _, order := range queue.ComputeEnvironmentOrder {
+		if order.ComputeEnvironment == environment.ARN || strings.HasSuffix(order.ComputeEnvironment, "/"+environment.Name) {
+			return true
+		}
+	}
+	return false
+}
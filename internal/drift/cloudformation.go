@@ -0,0 +1,102 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+)
+
+// stackDriftPollInterval is how often a running stack drift detection is checked
+const stackDriftPollInterval = 5 * time.Second
+
+type propertyDifference struct {
+	PropertyPath   string `json:"PropertyPath"`
+	ExpectedValue  string `json:"ExpectedValue"`
+	ActualValue    string `json:"ActualValue"`
+	DifferenceType string `json:"DifferenceType"` // ADD, REMOVE, NOT_EQUAL
+}
+
+type resourceDrift struct {
+	LogicalResourceID        string               `json:"LogicalResourceId"`
+	PhysicalResourceID       string               `json:"PhysicalResourceId"`
+	ResourceType             string               `json:"ResourceType"`
+	StackResourceDriftStatus string               `json:"StackResourceDriftStatus"`
+	PropertyDifferences      []propertyDifference `json:"PropertyDifferences"`
+}
+
+// CloudFormation runs CloudFormation drift detection on a stack and reports its modified
+// and deleted resources, with the properties that differ from the template
+func CloudFormation(ctx context.Context, cli *awscli.Client, stack string) ([]Finding, error) {
+	var detection struct {
+		StackDriftDetectionID string `json:"StackDriftDetectionId"`
+	}
+	if err := cli.Run(ctx, &detection, "cloudformation", "detect-stack-drift", "--stack-name", stack); err != nil {
+		return nil, err
+	}
+
+	for {
+		var status struct {
+			DetectionStatus       string `json:"DetectionStatus"`
+			DetectionStatusReason string `json:"DetectionStatusReason"`
+		}
+		if err := cli.Run(ctx, &status, "cloudformation", "describe-stack-drift-detection-status",
+			"--stack-drift-detection-id", detection.StackDriftDetectionID); err != nil {
+			return nil, err
+		}
+		if status.DetectionStatus == "DETECTION_FAILED" {
+			// Detection fails for resource types it doesn't support, but still checks the rest
+			fmt.Printf("Warning: drift detection for %s incomplete: %s\n", stack, status.DetectionStatusReason)
+		}
+		if status.DetectionStatus != "DETECTION_IN_PROGRESS" {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(stackDriftPollInterval):
+		}
+	}
+
+	var drifts struct {
+		StackResourceDrifts []resourceDrift `json:"StackResourceDrifts"`
+	}
+	if err := cli.Run(ctx, &drifts, "cloudformation", "describe-stack-resource-drifts", "--stack-name", stack,
+		"--stack-resource-drift-status-filters", "MODIFIED", "DELETED"); err != nil {
+		return nil, err
+	}
+
+	findings := make([]Finding, 0, len(drifts.StackResourceDrifts))
+	for _, drift := range drifts.StackResourceDrifts {
+		findings = append(findings, Finding{
+			Source:   "cloudformation",
+			Resource: fmt.Sprintf("%s/%s (%s %s)", stack, drift.LogicalResourceID, drift.ResourceType, drift.PhysicalResourceID),
+			Message:  describeResourceDrift(drift),
+		})
+	}
+	return findings, nil
+}
+
+// describeResourceDrift summarizes how a resource differs from its template
+func describeResourceDrift(drift resourceDrift) string {
+	if drift.StackResourceDriftStatus == "DELETED" {
+		return "deleted outside CloudFormation"
+	}
+	differences := make([]string, 0, len(drift.PropertyDifferences))
+	for _, difference := range drift.PropertyDifferences {
+		switch difference.DifferenceType {
+		case "ADD":
+			differences = append(differences, fmt.Sprintf("%s added: %s", difference.PropertyPath, difference.ActualValue))
+		case "REMOVE":
+			differences = append(differences, fmt.Sprintf("%s removed (was %s)", difference.PropertyPath, difference.ExpectedValue))
+		default:
+			differences = append(differences, fmt.Sprintf("%s is %s, expected %s", difference.PropertyPath, difference.ActualValue, difference.ExpectedValue))
+		}
+	}
+	if len(differences) == 0 {
+		return "modified outside CloudFormation"
+	}
+	return "modified outside CloudFormation: " + strings.Join(differences, "; ")
+}
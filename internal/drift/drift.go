@@ -0,0 +1,30 @@
+// Package drift compares deployed infrastructure with its definitions: Terraform state and
+// CloudFormation templates for teams that deployed the platform's infrastructure as code, and
+// the Batch resources a build configuration expects, so differences surface before a build
+// fails on them
+package drift
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Finding is one resource whose live state differs from its definition
+type Finding struct {
+	Source   string // terraform, cloudformation or batch
+	Resource string // Terraform address, stack logical ID, or Batch resource name
+	Message  string
+}
+
+// Format renders findings grouped by source
+func Format(findings []Finding) string {
+	if len(findings) == 0 {
+		return "✅ No drift found\n"
+	}
+	var b strings.Builder
+	for _, finding := range findings {
+		fmt.Fprintf(&b, "🔀 %s %s\n   %s\n", finding.Source, finding.Resource, finding.Message)
+	}
+	fmt.Fprintf(&b, "\n%d drifted resources\n", len(findings))
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package drift
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// planMessage is the part of a `terraform plan -json` log line drift detection reads
+type planMessage struct {
+	Type   string `json:"type"`
+	Change struct {
+		Resource struct {
+			Addr string `json:"addr"`
+		} `json:"resource"`
+		Action string `json:"action"`
+	} `json:"change"`
+}
+
+// terraformChangesPresent is plan's -detailed-exitcode status when the plan isn't empty
+const terraformChangesPresent = 2
+
+// Terraform reports resources in an initialized Terraform working directory that changed
+// outside Terraform. A refresh-only plan compares live resources with the state without
+// proposing changes, so nothing is modified.
+func Terraform(ctx context.Context, dir string) ([]Finding, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "terraform", "-chdir="+dir, "plan", "-refresh-only", "-detailed-exitcode", "-input=false", "-lock=false", "-json")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == terraformChangesPresent {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("terraform plan in %s: %w: %s", dir, err, strings.TrimSpace(stderr.String()+planErrors(stdout.Bytes())))
+	}
+	return parsePlan(stdout.Bytes())
+}
+
+// parsePlan collects the resource_drift messages of a JSON plan log
+func parsePlan(log []byte) ([]Finding, error) {
+	var findings []Finding
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var message planMessage
+		if json.Unmarshal(scanner.Bytes(), &message) != nil || message.Type != "resource_drift" {
+			continue
+		}
+		action := "changed"
+		if message.Change.Action == "delete" {
+			action = "deleted"
+		}
+		findings = append(findings, Finding{
+			Source:   "terraform",
+			Resource: message.Change.Resource.Addr,
+			Message:  fmt.Sprintf("%s outside Terraform; 'terraform apply' restores it, or 'terraform apply -refresh-only' accepts the change", action),
+		})
+	}
+	return findings, scanner.Err()
+}
+
+// planErrors returns the error diagnostics of a JSON plan log, which -json writes to stdout
+func planErrors(log []byte) string {
+	var errs []string
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	for scanner.Scan() {
+		var message struct {
+			Level   string `json:"@level"`
+			Message string `json:"@message"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &message) == nil && message.Level == "error" {
+			errs = append(errs, message.Message)
+		}
+	}
+	return strings.Join(errs, "; ")
+}
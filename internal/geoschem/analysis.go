@@ -0,0 +1,108 @@
+package geoschem
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+)
+
+// AnalysisConfiguration describes a Python post-processing image (GCPy, xarray, cartopy)
+// built alongside the model images
+type AnalysisConfiguration struct {
+	Name          string   `yaml:"name"`
+	Architecture  string   `yaml:"architecture"`
+	BaseImage     string   `yaml:"base_image"`
+	PythonVersion string   `yaml:"python_version"`
+	GCPyVersion   string   `yaml:"gcpy_version"`
+	Packages      []string `yaml:"packages"` // Additional conda-forge packages
+	Description   string   `yaml:"description"`
+}
+
+// defaultAnalysisPackages are installed in every analysis image next to GCPy. kerchunk,
+// h5py, h5netcdf, s3fs, and zarr back the image's geoschem-index-output and
+// geoschem-tabulate-output commands.
+var defaultAnalysisPackages = []string{"xarray", "cartopy", "netcdf4", "dask", "jupyterlab", "matplotlib", "kerchunk", "h5py", "h5netcdf", "s3fs", "zarr"}
+
+// GetAnalysisConfigs returns the standard analysis image configurations
+func GetAnalysisConfigs() []AnalysisConfiguration {
+	var configs []AnalysisConfiguration
+	for _, arch := range []string{"x86_64", "arm64"} {
+		configs = append(configs, AnalysisConfiguration{
+			Name:          fmt.Sprintf("geoschem-gcpy-%s", arch),
+			Architecture:  arch,
+			BaseImage:     "mambaorg/micromamba:1.5-jammy",
+			PythonVersion: "3.11",
+			GCPyVersion:   "1.4.2",
+			Packages:      defaultAnalysisPackages,
+			Description:   fmt.Sprintf("GCPy analysis environment (xarray, cartopy, Jupyter) on %s", arch),
+		})
+	}
+	return configs
+}
+
+// GetAnalysisConfigByName returns an analysis configuration by name
+func GetAnalysisConfigByName(name string) (*AnalysisConfiguration, error) {
+	for _, config := range GetAnalysisConfigs() {
+		if config.Name == name {
+			return &config, nil
+		}
+	}
+
+	return nil, fmt.Errorf("analysis configuration '%s' not found", name)
+}
+
+// AnalysisConfigForArch returns the analysis configuration matching an architecture
+func AnalysisConfigForArch(arch string) (*AnalysisConfiguration, error) {
+	return GetAnalysisConfigByName(fmt.Sprintf("geoschem-gcpy-%s", arch))
+}
+
+// Validate checks the analysis configuration
+func (ac *AnalysisConfiguration) Validate() error {
+	if ac.Name == "" {
+		return fmt.Errorf("configuration name is required")
+	}
+	if ac.Architecture != "x86_64" && ac.Architecture != "arm64" {
+		return fmt.Errorf("architecture must be x86_64 or arm64, got: %s", ac.Architecture)
+	}
+	if ac.BaseImage == "" || ac.PythonVersion == "" || ac.GCPyVersion == "" {
+		return fmt.Errorf("base image, Python version, and GCPy version are required")
+	}
+	return nil
+}
+
+// ToDockerBuildConfig converts the analysis configuration to a Docker build config
+func (ac *AnalysisConfiguration) ToDockerBuildConfig(sourceRepo, sourceBranch, imageTag string) *docker.BuildConfig {
+	return &docker.BuildConfig{
+		SourceRepo:    sourceRepo,
+		SourceBranch:  sourceBranch,
+		DockerfileDir: "docker/analysis",
+		ImageName:     ac.Name,
+		ImageTag:      fmt.Sprintf("%s-gcpy", imageTag), // Keeps analysis tags distinct from model tags in a shared repository
+		Architecture:  ac.Architecture,
+		BuildArgs: map[string]string{
+			"BASE_IMAGE":     ac.BaseImage,
+			"PYTHON_VERSION": ac.PythonVersion,
+			"GCPY_VERSION":   ac.GCPyVersion,
+			"EXTRA_PACKAGES": strings.Join(ac.Packages, " "),
+		},
+	}
+}
+
+// ListAnalysisConfigs returns a formatted list of analysis image configurations
+func ListAnalysisConfigs() string {
+	var result strings.Builder
+
+	result.WriteString("Available Analysis Image Configurations:\n\n")
+
+	for _, config := range GetAnalysisConfigs() {
+		result.WriteString(fmt.Sprintf("• %s\n", config.Name))
+		result.WriteString(fmt.Sprintf("  Architecture: %s\n", config.Architecture))
+		result.WriteString(fmt.Sprintf("  Python: %s, GCPy: %s\n", config.PythonVersion, config.GCPyVersion))
+		result.WriteString(fmt.Sprintf("  Packages: %s\n", strings.Join(config.Packages, ", ")))
+		result.WriteString(fmt.Sprintf("  Description: %s\n", config.Description))
+		result.WriteString("\n")
+	}
+
+	return result.String()
+}
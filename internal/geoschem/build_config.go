@@ -2,6 +2,7 @@ package geoschem
 
 import (
 	"fmt"
+	"path"
 	"strings"
 
 	"github.com/scttfrdmn/geoschem-aws/internal/docker"
@@ -13,14 +14,40 @@ type BuildMatrix struct {
 }
 
 type BuildConfiguration struct {
-	Name         string            `yaml:"name"`
-	Architecture string            `yaml:"architecture"`
-	Compiler     string            `yaml:"compiler"`
-	BaseImage    string            `yaml:"base_image"`
-	BuildArgs    map[string]string `yaml:"build_args"`
-	Description  string            `yaml:"description"`
+	Name            string             `yaml:"name"`
+	Architecture    string             `yaml:"architecture"`
+	Compiler        string             `yaml:"compiler"`
+	MPI             string             `yaml:"mpi"` // MPI implementation, defaults to "openmpi"
+	BaseImage       string             `yaml:"base_image"` // dnf-based container base (rockylinux:8/9, almalinux:9)
+	BuildArgs       map[string]string  `yaml:"build_args"`
+	Optimization    string             `yaml:"optimization"` // Optimization preset name, defaults to "portable"
+	OpenMP          OpenMPConfig       `yaml:"openmp"`
+	Dependencies    DependencyVersions `yaml:"dependencies"`
+	DependencyStack string             `yaml:"dependency_stack"` // Stack version (see GetDependencyStacks); empty resolves from the GEOS-Chem version
+	MathLibrary     string             `yaml:"math_library"` // Math library stack, defaults to "default"
+	Model           string             `yaml:"model"` // GeosChem model built: classic (default) or gchp
+	MAPL            string             `yaml:"mapl"` // MAPL version GCHP builds against, defaults to DefaultMAPLVersion
+	Dockerfile      string             `yaml:"dockerfile"` // Path in the source checkout, defaults to DefaultDockerfile
+	BuildContext    string             `yaml:"build_context"` // Directory in the checkout the image builds in, defaults to the Dockerfile's
+	Description     string             `yaml:"description"`
 }
 
+// Models a configuration can build
+const (
+	ModelClassic = "classic" // GEOS-Chem Classic: lat-lon grids, OpenMP on one instance
+	ModelGCHP    = "gchp"    // GCHP: cubed-sphere grids, MPI across nodes with ESMF and MAPL
+)
+
+// DefaultMAPLVersion is the MAPL release GCHP 14.x is developed against
+const DefaultMAPLVersion = "2.26.0"
+
+// DefaultDockerfile is the model Dockerfile in the source checkout
+const DefaultDockerfile = "docker/Dockerfile"
+
+// ProductionDockerfile builds the model GEOSCHEM_MODEL names FROM the dependencies image;
+// GCHP and math library configurations use it to link the MAPL and MATH_LDFLAGS it installs
+const ProductionDockerfile = "docker/Dockerfile.production"
+
 // GetStandardBuildConfigs returns standard GeosChem build configurations
 func GetStandardBuildConfigs() []BuildConfiguration {
 	return []BuildConfiguration{
@@ -35,7 +62,8 @@ func GetStandardBuildConfigs() []BuildConfiguration {
 				"ARCHITECTURE": "x86_64",
 				"SPACK_SPEC":   "geos-chem@14.4.3 %gcc@13.2.0",
 			},
-			Description: "GeosChem with GCC 13 on x86_64",
+			Dependencies: DefaultDependencyVersions(),
+			Description:  "GeosChem with GCC 13 on x86_64",
 		},
 		{
 			Name:         "geoschem-intel-x86_64",
@@ -48,7 +76,8 @@ func GetStandardBuildConfigs() []BuildConfiguration {
 				"ARCHITECTURE": "x86_64",
 				"SPACK_SPEC":   "geos-chem@14.4.3 %intel@2024.0.0",
 			},
-			Description: "GeosChem with Intel Compiler 2024 on x86_64",
+			Dependencies: DefaultDependencyVersions(),
+			Description:  "GeosChem with Intel Compiler 2024 on x86_64",
 		},
 		{
 			Name:         "geoschem-gcc-arm64",
@@ -61,7 +90,8 @@ func GetStandardBuildConfigs() []BuildConfiguration {
 				"ARCHITECTURE": "arm64",
 				"SPACK_SPEC":   "geos-chem@14.4.3 %gcc@13.2.0",
 			},
-			Description: "GeosChem with GCC 13 on ARM64/Graviton",
+			Dependencies: DefaultDependencyVersions(),
+			Description:  "GeosChem with GCC 13 on ARM64/Graviton",
 		},
 		{
 			Name:         "geoschem-aocc-x86_64",
@@ -74,7 +104,125 @@ func GetStandardBuildConfigs() []BuildConfiguration {
 				"ARCHITECTURE": "x86_64", 
 				"SPACK_SPEC":   "geos-chem@14.4.3 %aocc@4.0.0",
 			},
-			Description: "GeosChem with AMD AOCC 4 on x86_64",
+			Dependencies: DefaultDependencyVersions(),
+			Description:  "GeosChem with AMD AOCC 4 on x86_64",
+		},
+		{
+			Name:         "geoschem-aocc-aocl-x86_64",
+			Architecture: "x86_64",
+			Compiler:     "aocc4",
+			BaseImage:    "rockylinux:9",
+			BuildArgs: map[string]string{
+				"COMPILER":         "aocc",
+				"COMPILER_VERSION": "4.0.0",
+				"ARCHITECTURE":     "x86_64",
+				"SPACK_SPEC":       "geos-chem@14.4.3 %aocc@4.0.0",
+			},
+			Optimization: "zen4",
+			MathLibrary:  "aocl",
+			Dockerfile:   ProductionDockerfile,
+			Dependencies: DefaultDependencyVersions(),
+			Description:  "GeosChem with AMD AOCC 4 and AOCL math libraries, tuned for c7a/hpc7a",
+		},
+		{
+			Name:         "geoschem-gcc-graviton3-arm64",
+			Architecture: "arm64",
+			Compiler:     "gcc13",
+			BaseImage:    "rockylinux:9",
+			BuildArgs: map[string]string{
+				"COMPILER":         "gcc",
+				"COMPILER_VERSION": "13",
+				"ARCHITECTURE":     "arm64",
+				"SPACK_SPEC":       "geos-chem@14.4.3 %gcc@13.2.0",
+			},
+			Optimization: "graviton3-sve",
+			Dependencies: DefaultDependencyVersions(),
+			Description:  "GeosChem with GCC 13 and 256-bit SVE, tuned for Graviton3 (c7g, hpc7g)",
+		},
+		{
+			Name:         "geoschem-acfl-graviton3-arm64",
+			Architecture: "arm64",
+			Compiler:     "acfl24",
+			BaseImage:    "rockylinux:9",
+			BuildArgs: map[string]string{
+				"COMPILER":         "arm",
+				"COMPILER_VERSION": "24.04",
+				"ARCHITECTURE":     "arm64",
+				"SPACK_SPEC":       "geos-chem@14.4.3 %arm@24.04",
+			},
+			Optimization: "graviton3-sve",
+			MathLibrary:  "armpl",
+			Dockerfile:   ProductionDockerfile,
+			Dependencies: DefaultDependencyVersions(),
+			Description:  "GeosChem with Arm Compiler for Linux 24 and ArmPL, tuned for Graviton3 (c7g, hpc7g)",
+		},
+		{
+			Name:         "geoschem-gcc-graviton4-arm64",
+			Architecture: "arm64",
+			Compiler:     "gcc13",
+			BaseImage:    "rockylinux:9",
+			BuildArgs: map[string]string{
+				"COMPILER":         "gcc",
+				"COMPILER_VERSION": "13",
+				"ARCHITECTURE":     "arm64",
+				"SPACK_SPEC":       "geos-chem@14.4.3 %gcc@13.2.0",
+			},
+			Optimization: "graviton4-sve",
+			Dependencies: DefaultDependencyVersions(),
+			Description:  "GeosChem with GCC 13 and SVE2, tuned for Graviton4 (c8g, m8g, r8g)",
+		},
+		{
+			Name:         "gchp-gcc-x86_64",
+			Architecture: "x86_64",
+			Compiler:     "gcc13",
+			MPI:          "openmpi",
+			Model:        ModelGCHP,
+			BaseImage:    "rockylinux:9",
+			Dockerfile:   ProductionDockerfile,
+			BuildArgs: map[string]string{
+				"COMPILER":         "gcc",
+				"COMPILER_VERSION": "13",
+				"ARCHITECTURE":     "x86_64",
+				"SPACK_SPEC":       "gchp@14.4.3 %gcc@13.2.0",
+			},
+			Optimization: "zen3",
+			Dependencies: DefaultDependencyVersions(),
+			Description:  "GCHP with GCC 13 and Open MPI, tuned for multi-node runs on hpc6a with EFA",
+		},
+		{
+			Name:         "gchp-intel-x86_64",
+			Architecture: "x86_64",
+			Compiler:     "intel2024",
+			MPI:          "intelmpi",
+			Model:        ModelGCHP,
+			BaseImage:    "rockylinux:9",
+			Dockerfile:   ProductionDockerfile,
+			BuildArgs: map[string]string{
+				"COMPILER":         "intel",
+				"COMPILER_VERSION": "2024.0",
+				"ARCHITECTURE":     "x86_64",
+				"SPACK_SPEC":       "gchp@14.4.3 %intel@2024.0.0",
+			},
+			Dependencies: DefaultDependencyVersions(),
+			Description:  "GCHP with Intel Compiler 2024 and Intel MPI on x86_64",
+		},
+		{
+			Name:         "gchp-gcc-graviton3-arm64",
+			Architecture: "arm64",
+			Compiler:     "gcc13",
+			MPI:          "openmpi",
+			Model:        ModelGCHP,
+			BaseImage:    "rockylinux:9",
+			Dockerfile:   ProductionDockerfile,
+			BuildArgs: map[string]string{
+				"COMPILER":         "gcc",
+				"COMPILER_VERSION": "13",
+				"ARCHITECTURE":     "arm64",
+				"SPACK_SPEC":       "gchp@14.4.3 %gcc@13.2.0",
+			},
+			Optimization: "graviton3-sve",
+			Dependencies: DefaultDependencyVersions(),
+			Description:  "GCHP with GCC 13 and Open MPI, tuned for multi-node runs on hpc7g with EFA",
 		},
 	}
 }
@@ -84,12 +232,138 @@ func (bc *BuildConfiguration) ToDockerBuildConfig(sourceRepo, sourceBranch, imag
 	return &docker.BuildConfig{
 		SourceRepo:    sourceRepo,
 		SourceBranch:  sourceBranch,
-		DockerfileDir: "docker", // Assume Dockerfile is in docker/ subdirectory
+		DockerfileDir: bc.BuildContextDir(),
+		Dockerfile:    bc.dockerfileInContext(),
 		ImageName:     bc.Name,
-		ImageTag:      imageTag,
+		ImageTag:      fmt.Sprintf("%s-%s", imageTag, bc.MPIName()),
 		Architecture:  bc.Architecture,
-		BuildArgs:     bc.BuildArgs,
+		BuildArgs:     bc.dockerBuildArgs(),
+	}
+}
+
+// dockerBuildArgs returns a copy of the build args with optimization flags applied
+func (bc *BuildConfiguration) dockerBuildArgs() map[string]string {
+	args := make(map[string]string, len(bc.BuildArgs)+16)
+	for key, value := range bc.BuildArgs {
+		args[key] = value
+	}
+
+	if preset, err := GetOptimizationPreset(bc.OptimizationName()); err == nil {
+		args["OPTIMIZATION"] = preset.Name
+		args["CFLAGS"] = preset.CFlags
+		args["FFLAGS"] = preset.FFlags
+	}
+
+	for key, value := range bc.OpenMP.BuildArgs() {
+		args[key] = value
+	}
+
+	for key, value := range bc.Dependencies.BuildArgs() {
+		args[key] = value
+	}
+	if stack, err := GetDependencyStack(bc.DependencyStack); err == nil {
+		args["DEPS_STACK"] = stack.Version
+		args["DEPS_GEOSCHEM_VERSIONS"] = strings.Join(stack.GeosChem, ",")
+	}
+	if lib, err := GetMathLibrary(bc.MathLibraryName()); err == nil {
+		for key, value := range lib.BuildArgs() {
+			args[key] = value
+		}
+	}
+	if bc.BaseImage != "" {
+		args["BASE_IMAGE"] = bc.BaseImage
+	}
+	args["MPI"] = bc.MPIName()
+	args["MPI_IMPLEMENTATION"] = bc.MPIName()
+	args["MPI_VERSION"] = bc.MPIVersion()
+	args["GEOSCHEM_MODEL"] = bc.ModelName()
+	if bc.ModelName() == ModelGCHP {
+		args["MAPL_VERSION"] = bc.MAPLVersion()
+	}
+	if spec := bc.SpackSpec(); spec != "" {
+		args["SPACK_SPEC"] = spec
+	}
+
+	return args
+}
+
+// SpackSpec returns the full Spack spec including pinned dependency versions
+func (bc *BuildConfiguration) SpackSpec() string {
+	spec := bc.BuildArgs["SPACK_SPEC"]
+	if spec == "" {
+		return ""
+	}
+
+	if impl, err := GetMPIImplementation(bc.MPIName()); err == nil {
+		spec += fmt.Sprintf(" ^%s@%s", impl.SpackPackage, bc.MPIVersion())
+	}
+
+	if constraints := bc.Dependencies.SpackConstraints(); constraints != "" {
+		spec += " " + constraints
+	}
+
+	if lib, err := GetMathLibrary(bc.MathLibraryName()); err == nil && len(lib.SpackSpecs) > 0 {
+		spec += " " + strings.Join(lib.SpackSpecs, " ")
+	}
+
+	// GCHP's ESMF is pinned with the other libraries; MAPL is pinned here
+	if bc.ModelName() == ModelGCHP {
+		spec += fmt.Sprintf(" ^mapl@%s", bc.MAPLVersion())
+	}
+	return spec
+}
+
+// ModelName returns the GeosChem model the configuration builds
+func (bc *BuildConfiguration) ModelName() string {
+	if bc.Model == "" {
+		return ModelClassic
+	}
+	return bc.Model
+}
+
+// MAPLVersion returns the MAPL version GCHP builds against
+func (bc *BuildConfiguration) MAPLVersion() string {
+	if bc.MAPL == "" {
+		return DefaultMAPLVersion
+	}
+	return bc.MAPL
+}
+
+// MathLibraryName returns the selected math library stack name
+func (bc *BuildConfiguration) MathLibraryName() string {
+	if bc.MathLibrary == "" {
+		return DefaultMathLibrary
+	}
+	return bc.MathLibrary
+}
+
+// MPIName returns the selected MPI implementation name
+func (bc *BuildConfiguration) MPIName() string {
+	if bc.MPI == "" {
+		return DefaultMPI
+	}
+	return bc.MPI
+}
+
+// MPIVersion returns the MPI version to build against, honoring an Open MPI pin
+func (bc *BuildConfiguration) MPIVersion() string {
+	if bc.MPIName() == "openmpi" && bc.Dependencies.OpenMPI != "" {
+		return bc.Dependencies.OpenMPI
+	}
+
+	impl, err := GetMPIImplementation(bc.MPIName())
+	if err != nil {
+		return ""
+	}
+	return impl.DefaultVersion
+}
+
+// OptimizationName returns the selected optimization preset name
+func (bc *BuildConfiguration) OptimizationName() string {
+	if bc.Optimization == "" {
+		return DefaultOptimizationPreset
 	}
+	return bc.Optimization
 }
 
 // GetBuildConfigByName returns a build configuration by name
@@ -105,6 +379,26 @@ func GetBuildConfigByName(name string) (*BuildConfiguration, error) {
 	return nil, fmt.Errorf("build configuration '%s' not found", name)
 }
 
+// FindBuildConfig returns the standard GeosChem Classic configuration for an architecture
+// and compiler, preferring the default math library when several match
+func FindBuildConfig(arch, compiler string) (*BuildConfiguration, error) {
+	var match *BuildConfiguration
+	for _, config := range GetStandardBuildConfigs() {
+		if config.Architecture != arch || config.Compiler != compiler || config.ModelName() != ModelClassic {
+			continue
+		}
+		if match == nil || match.MathLibraryName() != DefaultMathLibrary {
+			config := config
+			match = &config
+		}
+	}
+	
+	if match == nil {
+		return nil, fmt.Errorf("no build configuration for %s on %s", compiler, arch)
+	}
+	return match, nil
+}
+
 // ListAvailableConfigs returns a formatted list of available configurations
 func ListAvailableConfigs() string {
 	configs := GetStandardBuildConfigs()
@@ -116,6 +410,15 @@ func ListAvailableConfigs() string {
 		result.WriteString(fmt.Sprintf("• %s\n", config.Name))
 		result.WriteString(fmt.Sprintf("  Architecture: %s\n", config.Architecture))
 		result.WriteString(fmt.Sprintf("  Compiler: %s\n", config.Compiler))
+		if config.ModelName() == ModelGCHP {
+			result.WriteString(fmt.Sprintf("  Model: GCHP (MAPL %s)\n", config.MAPLVersion()))
+			result.WriteString(fmt.Sprintf("  MPI: %s\n", config.MPIName()))
+		} else {
+			result.WriteString(fmt.Sprintf("  MPI: %s\n", strings.Join(SupportedMPI(config.Compiler), ", ")))
+		}
+		if err := config.ResolveDependencyStack(); err == nil && config.DependencyStack != "" {
+			result.WriteString(fmt.Sprintf("  Dependency Stack: %s (GEOS-Chem %s)\n", config.DependencyStack, config.GeosChemVersion()))
+		}
 		result.WriteString(fmt.Sprintf("  Description: %s\n", config.Description))
 		result.WriteString("\n")
 	}
@@ -141,6 +444,46 @@ func (bc *BuildConfiguration) Validate() error {
 		bc.BuildArgs = make(map[string]string)
 	}
 	
+	if err := ValidateCompilerMPI(bc.Compiler, bc.MPIName()); err != nil {
+		return err
+	}
+	
+	preset, err := GetOptimizationPreset(bc.OptimizationName())
+	if err != nil {
+		return err
+	}
+	if !preset.SupportsArchitecture(bc.Architecture) {
+		return fmt.Errorf("optimization preset '%s' targets %s, not %s", preset.Name, preset.Architecture, bc.Architecture)
+	}
+	
+	lib, err := GetMathLibrary(bc.MathLibraryName())
+	if err != nil {
+		return err
+	}
+	if err := lib.Supports(bc.Architecture, bc.Compiler); err != nil {
+		return err
+	}
+	
+	if err := bc.ResolveDependencyStack(); err != nil {
+		return err
+	}
+	
+	if err := bc.Dependencies.Validate(); err != nil {
+		return fmt.Errorf("invalid dependency versions: %w", err)
+	}
+	
+	if err := bc.validateModel(); err != nil {
+		return err
+	}
+	
+	if err := bc.validateDockerfile(); err != nil {
+		return fmt.Errorf("invalid Dockerfile: %w", err)
+	}
+	
+	if err := bc.OpenMP.Validate(); err != nil {
+		return fmt.Errorf("invalid OpenMP configuration: %w", err)
+	}
+	
 	// Ensure required build args are present
 	requiredArgs := []string{"COMPILER", "ARCHITECTURE"}
 	for _, arg := range requiredArgs {
@@ -152,7 +495,79 @@ func (bc *BuildConfiguration) Validate() error {
 	return nil
 }
 
-// GetDockerfilePath returns the expected Dockerfile path for this configuration
+// validateModel checks the configuration can build its model. GCHP runs as MPI ranks
+// across nodes, so it needs an MPI chosen for it and the ESMF and MAPL it couples through.
+func (bc *BuildConfiguration) validateModel() error {
+	spackPackage := strings.SplitN(bc.BuildArgs["SPACK_SPEC"], "@", 2)[0]
+	switch bc.ModelName() {
+	case ModelClassic:
+		if spackPackage == "gchp" {
+			return fmt.Errorf("SPACK_SPEC builds gchp; set model: gchp")
+		}
+	case ModelGCHP:
+		if bc.MPI == "" {
+			return fmt.Errorf("GCHP requires an MPI implementation (supported with %s: %s)",
+				bc.Compiler, strings.Join(SupportedMPI(bc.Compiler), ", "))
+		}
+		if bc.Dependencies.ESMF == "" {
+			return fmt.Errorf("GCHP requires ESMF; pin dependencies.esmf or choose a dependency stack")
+		}
+		if strings.ContainsAny(bc.MAPLVersion(), " ^%@'\"") {
+			return fmt.Errorf("invalid MAPL version: %q", bc.MAPLVersion())
+		}
+		if spackPackage != "" && spackPackage != "gchp" {
+			return fmt.Errorf("GCHP configurations must build the gchp Spack package, not %s", spackPackage)
+		}
+	default:
+		return fmt.Errorf("unknown model '%s' (expected %s or %s)", bc.Model, ModelClassic, ModelGCHP)
+	}
+	return nil
+}
+
+// GetDockerfilePath returns the Dockerfile's path in the source checkout
 func (bc *BuildConfiguration) GetDockerfilePath() string {
-	return fmt.Sprintf("docker/Dockerfile.%s", bc.Compiler)
+	if bc.Dockerfile == "" {
+		return DefaultDockerfile
+	}
+	return path.Clean(bc.Dockerfile)
+}
+
+// BuildContextDir returns the build context directory in the source checkout
+func (bc *BuildConfiguration) BuildContextDir() string {
+	if bc.BuildContext == "" {
+		return path.Dir(bc.GetDockerfilePath())
+	}
+	return path.Clean(bc.BuildContext)
+}
+
+// dockerfileInContext returns the Dockerfile relative to the build context, which podman
+// build -f resolves it against; a monorepo's Dockerfile can sit outside its context
+func (bc *BuildConfiguration) dockerfileInContext() string {
+	dockerfile, context := bc.GetDockerfilePath(), bc.BuildContextDir()
+	if context == "." {
+		return dockerfile
+	}
+	if rel, ok := strings.CutPrefix(dockerfile, context+"/"); ok {
+		return rel
+	}
+	return strings.Repeat("../", len(strings.Split(context, "/"))) + dockerfile
+}
+
+// validateDockerfile checks the Dockerfile and build context are inside the checkout
+func (bc *BuildConfiguration) validateDockerfile() error {
+	for _, p := range []string{bc.Dockerfile, bc.BuildContext} {
+		if p == "" {
+			continue
+		}
+		if path.IsAbs(p) || strings.HasPrefix(p, "~") || strings.Contains("/"+path.Clean(p)+"/", "/../") {
+			return fmt.Errorf("'%s' must be relative to the source checkout", p)
+		}
+		if strings.ContainsAny(p, " \t\n'\"$`\\") {
+			return fmt.Errorf("'%s' can't contain spaces, quotes or shell characters", p)
+		}
+	}
+	if path.Base(bc.GetDockerfilePath()) == "." {
+		return fmt.Errorf("dockerfile must name a file, not the checkout")
+	}
+	return nil
 }
\ No newline at end of file
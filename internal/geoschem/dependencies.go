@@ -0,0 +1,72 @@
+package geoschem
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DependencyVersions pins the library stack Spack builds GeosChem against.
+// Empty fields are left for Spack to resolve.
+type DependencyVersions struct {
+	NetCDFC       string `yaml:"netcdf_c"`
+	NetCDFFortran string `yaml:"netcdf_fortran"`
+	HDF5          string `yaml:"hdf5"`
+	ESMF          string `yaml:"esmf"`
+	OpenMPI       string `yaml:"openmpi"`
+}
+
+// DefaultDependencyVersions returns the library stack used for published images
+func DefaultDependencyVersions() DependencyVersions {
+	return DependencyVersions{
+		NetCDFC:       "4.9.2",
+		NetCDFFortran: "4.6.1",
+		HDF5:          "1.14.3",
+		ESMF:          "8.6.0",
+		OpenMPI:       "5.0.1",
+	}
+}
+
+// packages returns the Spack package names paired with their pinned versions
+func (dv DependencyVersions) packages() [][2]string {
+	return [][2]string{
+		{"netcdf-c", dv.NetCDFC},
+		{"netcdf-fortran", dv.NetCDFFortran},
+		{"hdf5", dv.HDF5},
+		{"esmf", dv.ESMF},
+		{"openmpi", dv.OpenMPI},
+	}
+}
+
+// SpackConstraints returns the "^pkg@version" dependency constraints for pinned packages.
+// The Open MPI pin is applied through the configuration's MPI selection instead.
+func (dv DependencyVersions) SpackConstraints() string {
+	var constraints []string
+	for _, pkg := range dv.packages() {
+		if pkg[1] != "" && pkg[0] != "openmpi" {
+			constraints = append(constraints, fmt.Sprintf("^%s@%s", pkg[0], pkg[1]))
+		}
+	}
+	return strings.Join(constraints, " ")
+}
+
+// BuildArgs returns the Docker build arguments for pinned versions
+func (dv DependencyVersions) BuildArgs() map[string]string {
+	args := make(map[string]string)
+	for _, pkg := range dv.packages() {
+		if pkg[1] != "" {
+			key := strings.ToUpper(strings.ReplaceAll(pkg[0], "-", "_")) + "_VERSION"
+			args[key] = pkg[1]
+		}
+	}
+	return args
+}
+
+// Validate checks that pinned versions are usable in a Spack spec
+func (dv DependencyVersions) Validate() error {
+	for _, pkg := range dv.packages() {
+		if strings.ContainsAny(pkg[1], " ^%@'\"") {
+			return fmt.Errorf("invalid %s version: %q", pkg[0], pkg[1])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package geoschem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/docker"
+)
+
+// DependenciesDockerfile builds the compilers, MPI, and Spack library stack that model
+// images build FROM
+const DependenciesDockerfile = "Dockerfile.deps"
+
+// modelOnlyArgs only reach the GeosChem stages, so changing them keeps the dependencies image
+var modelOnlyArgs = map[string]bool{
+	"SPACK_SPEC":                true,
+	"OMP_NUM_THREADS":           true,
+	"OMP_STACKSIZE":             true,
+	"OMP_PROC_BIND":             true,
+	"OMP_PLACES":                true,
+	docker.DependenciesImageArg: true,
+}
+
+// DependenciesName returns the dependencies image name for the configuration's compiler
+// and architecture
+func (bc *BuildConfiguration) DependenciesName() string {
+	return fmt.Sprintf("geoschem-deps-%s-%s", bc.Compiler, bc.Architecture)
+}
+
+// dependenciesBuildArgs returns the build args that reach the dependency stages
+func (bc *BuildConfiguration) dependenciesBuildArgs() map[string]string {
+	args := bc.dockerBuildArgs()
+	for key := range args {
+		if modelOnlyArgs[key] {
+			delete(args, key)
+		}
+	}
+	return args
+}
+
+// DependenciesTag identifies the dependency stack: the MPI implementation, the stack
+// version, and a digest of every build arg the dependency stages see. Configurations
+// that only differ in model settings share a tag, and so share one published image.
+func (bc *BuildConfiguration) DependenciesTag() string {
+	args := bc.dependenciesBuildArgs()
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s=%s\n", key, args[key])
+	}
+	return docker.CanonicalTag(bc.MPIName(), bc.DependencyStack, hex.EncodeToString(hash.Sum(nil))[:12])
+}
+
+// ToDependenciesBuildConfig returns the Docker build config for the configuration's
+// dependencies image
+func (bc *BuildConfiguration) ToDependenciesBuildConfig(sourceRepo, sourceBranch string) *docker.BuildConfig {
+	return &docker.BuildConfig{
+		SourceRepo:    sourceRepo,
+		SourceBranch:  sourceBranch,
+		DockerfileDir: "docker",
+		Dockerfile:    DependenciesDockerfile,
+		ImageName:     bc.DependenciesName(),
+		ImageTag:      bc.DependenciesTag(),
+		Architecture:  bc.Architecture,
+		BuildArgs:     bc.dependenciesBuildArgs(),
+	}
+}
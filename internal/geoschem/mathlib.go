@@ -0,0 +1,84 @@
+package geoschem
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MathLibrary describes a BLAS/LAPACK/FFT stack GeosChem can link against
+type MathLibrary struct {
+	Name         string   `yaml:"name"`
+	Architecture string   `yaml:"architecture"` // x86_64, arm64, or "any"
+	Compilers    []string `yaml:"compilers"`    // Compilers the library is supported with (empty = all)
+	SpackSpecs   []string `yaml:"spack_specs"`  // Dependency constraints appended to the Spack spec
+	LDFlags      string   `yaml:"ldflags"`
+	Description  string   `yaml:"description"`
+}
+
+// DefaultMathLibrary is used when a configuration does not select one
+const DefaultMathLibrary = "default"
+
+// GetMathLibraries returns the supported math library stacks
+func GetMathLibraries() []MathLibrary {
+	return []MathLibrary{
+		{
+			Name:         "default",
+			Architecture: "any",
+			Description:  "Whatever BLAS/LAPACK Spack resolves (usually OpenBLAS)",
+		},
+		{
+			Name:         "aocl",
+			Architecture: "x86_64",
+			Compilers:    []string{"aocc4", "gcc13"},
+			SpackSpecs:   []string{"^amdblis threads=openmp", "^amdlibflame", "^amdfftw"},
+			LDFlags:      "-lblis-mt -lflame -lfftw3",
+			Description:  "AMD Optimizing CPU Libraries (BLIS, libFLAME, FFTW) for EPYC (c7a, hpc7a)",
+		},
+		{
+			Name:         "armpl",
+			Architecture: "arm64",
+			Compilers:    []string{"acfl24", "gcc13"},
+			SpackSpecs:   []string{"^armpl-gcc"},
+			LDFlags:      "-larmpl_mp",
+			Description:  "Arm Performance Libraries with SVE kernels for Graviton3/4",
+		},
+	}
+}
+
+// GetMathLibrary returns a math library stack by name
+func GetMathLibrary(name string) (*MathLibrary, error) {
+	for _, lib := range GetMathLibraries() {
+		if lib.Name == name {
+			return &lib, nil
+		}
+	}
+
+	return nil, fmt.Errorf("math library '%s' not found", name)
+}
+
+// BuildArgs returns the Docker build arguments for the math library
+func (ml *MathLibrary) BuildArgs() map[string]string {
+	return map[string]string{
+		"MATH_LIBRARY": ml.Name,
+		"MATH_LDFLAGS": ml.LDFlags,
+		"MATH_SPECS":   strings.Join(ml.SpackSpecs, " "),
+	}
+}
+
+// Supports checks that the library can be used with an architecture and compiler
+func (ml *MathLibrary) Supports(arch, compiler string) error {
+	if ml.Architecture != "any" && ml.Architecture != arch {
+		return fmt.Errorf("math library '%s' requires %s, not %s", ml.Name, ml.Architecture, arch)
+	}
+
+	if len(ml.Compilers) == 0 {
+		return nil
+	}
+	for _, supported := range ml.Compilers {
+		if supported == compiler {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("math library '%s' is not supported with compiler %s (supported: %s)", ml.Name, compiler, strings.Join(ml.Compilers, ", "))
+}
@@ -0,0 +1,85 @@
+package geoschem
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MPIImplementation describes an MPI library GeosChem can be built against
+type MPIImplementation struct {
+	Name           string `yaml:"name"`
+	SpackPackage   string `yaml:"spack_package"`
+	DefaultVersion string `yaml:"default_version"`
+	Description    string `yaml:"description"`
+}
+
+// DefaultMPI is used when a configuration does not select an MPI implementation
+const DefaultMPI = "openmpi"
+
+// GetMPIImplementations returns the supported MPI implementations
+func GetMPIImplementations() []MPIImplementation {
+	return []MPIImplementation{
+		{
+			Name:           "openmpi",
+			SpackPackage:   "openmpi",
+			DefaultVersion: "5.0.1",
+			Description:    "Open MPI",
+		},
+		{
+			Name:           "mpich",
+			SpackPackage:   "mpich",
+			DefaultVersion: "4.1.2",
+			Description:    "MPICH",
+		},
+		{
+			Name:           "intelmpi",
+			SpackPackage:   "intel-oneapi-mpi",
+			DefaultVersion: "2021.10.0",
+			Description:    "Intel MPI (oneAPI)",
+		},
+	}
+}
+
+// compilerMPISupport lists the MPI implementations known to work with each compiler
+var compilerMPISupport = map[string][]string{
+	"gcc13":     {"openmpi", "mpich"},
+	"intel2024": {"intelmpi", "openmpi"},
+	"aocc4":     {"openmpi"},
+	"acfl24":    {"openmpi", "mpich"},
+}
+
+// GetMPIImplementation returns an MPI implementation by name
+func GetMPIImplementation(name string) (*MPIImplementation, error) {
+	for _, impl := range GetMPIImplementations() {
+		if impl.Name == name {
+			return &impl, nil
+		}
+	}
+
+	return nil, fmt.Errorf("MPI implementation '%s' not found", name)
+}
+
+// SupportedMPI returns the MPI implementations allowed for a compiler
+func SupportedMPI(compiler string) []string {
+	return compilerMPISupport[compiler]
+}
+
+// ValidateCompilerMPI checks that a compiler/MPI pair is supported
+func ValidateCompilerMPI(compiler, mpi string) error {
+	if _, err := GetMPIImplementation(mpi); err != nil {
+		return err
+	}
+
+	supported, exists := compilerMPISupport[compiler]
+	if !exists {
+		return fmt.Errorf("unknown compiler: %s", compiler)
+	}
+
+	for _, name := range supported {
+		if name == mpi {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("MPI '%s' is not supported with compiler %s (supported: %s)", mpi, compiler, strings.Join(supported, ", "))
+}
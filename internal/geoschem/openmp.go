@@ -0,0 +1,97 @@
+package geoschem
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// OpenMPConfig holds OpenMP runtime defaults baked into the image entrypoint
+type OpenMPConfig struct {
+	NumThreads int    `yaml:"num_threads"` // 0 = use all vCPUs visible to the container
+	StackSize  string `yaml:"stack_size"`  // OMP_STACKSIZE, GeosChem needs a large per-thread stack
+	ProcBind   string `yaml:"proc_bind"`   // OMP_PROC_BIND: true, false, close, spread
+	Places     string `yaml:"places"`      // OMP_PLACES: cores, threads, sockets
+}
+
+// DefaultOpenMPConfig returns the recommended OpenMP settings for GeosChem Classic
+func DefaultOpenMPConfig() OpenMPConfig {
+	return OpenMPConfig{
+		NumThreads: 0,
+		StackSize:  "500m",
+		ProcBind:   "true",
+		Places:     "cores",
+	}
+}
+
+// withDefaults fills unset fields from DefaultOpenMPConfig
+func (oc OpenMPConfig) withDefaults() OpenMPConfig {
+	defaults := DefaultOpenMPConfig()
+	if oc.StackSize == "" {
+		oc.StackSize = defaults.StackSize
+	}
+	if oc.ProcBind == "" {
+		oc.ProcBind = defaults.ProcBind
+	}
+	if oc.Places == "" {
+		oc.Places = defaults.Places
+	}
+	return oc
+}
+
+// BuildArgs returns the Docker build arguments that set the entrypoint defaults
+func (oc OpenMPConfig) BuildArgs() map[string]string {
+	oc = oc.withDefaults()
+	return map[string]string{
+		"OMP_NUM_THREADS": strconv.Itoa(oc.NumThreads),
+		"OMP_STACKSIZE":   oc.StackSize,
+		"OMP_PROC_BIND":   oc.ProcBind,
+		"OMP_PLACES":      oc.Places,
+	}
+}
+
+// Validate checks the OpenMP settings for obvious mistakes
+func (oc OpenMPConfig) Validate() error {
+	if oc.NumThreads < 0 {
+		return fmt.Errorf("OpenMP thread count cannot be negative: %d", oc.NumThreads)
+	}
+
+	switch oc.ProcBind {
+	case "", "true", "false", "close", "spread", "master", "primary":
+	default:
+		return fmt.Errorf("invalid OMP_PROC_BIND value: %s", oc.ProcBind)
+	}
+
+	switch oc.Places {
+	case "", "cores", "threads", "sockets", "ll_caches", "numa_domains":
+	default:
+		return fmt.Errorf("invalid OMP_PLACES value: %s", oc.Places)
+	}
+
+	return nil
+}
+
+// CheckThreadTopology returns warnings when the requested threads and MPI processes
+// do not match the vCPUs of the instance a run is launched on
+func CheckThreadTopology(ompThreads, mpiProcesses, vcpus int) []string {
+	var warnings []string
+
+	if vcpus <= 0 {
+		return warnings
+	}
+	if mpiProcesses <= 0 {
+		mpiProcesses = 1
+	}
+	if ompThreads <= 0 {
+		ompThreads = vcpus / mpiProcesses
+	}
+
+	total := ompThreads * mpiProcesses
+	switch {
+	case total > vcpus:
+		warnings = append(warnings, fmt.Sprintf("⚠️  %d MPI x %d OpenMP threads = %d exceeds %d vCPUs - expect oversubscription", mpiProcesses, ompThreads, total, vcpus))
+	case total < vcpus/2:
+		warnings = append(warnings, fmt.Sprintf("⚠️  %d MPI x %d OpenMP threads = %d uses less than half of %d vCPUs - consider a smaller instance", mpiProcesses, ompThreads, total, vcpus))
+	}
+
+	return warnings
+}
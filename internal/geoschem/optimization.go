@@ -0,0 +1,147 @@
+package geoschem
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OptimizationPreset describes compiler flags tuned for a target microarchitecture
+type OptimizationPreset struct {
+	Name         string `yaml:"name"`
+	Architecture string `yaml:"architecture"` // x86_64, arm64, or "any"
+	CFlags       string `yaml:"cflags"`
+	FFlags       string `yaml:"fflags"`
+	Description  string `yaml:"description"`
+}
+
+// DefaultOptimizationPreset is used when a configuration does not select one
+const DefaultOptimizationPreset = "portable"
+
+// GetOptimizationPresets returns the built-in compiler tuning presets
+func GetOptimizationPresets() []OptimizationPreset {
+	return []OptimizationPreset{
+		{
+			Name:         "portable",
+			Architecture: "any",
+			CFlags:       "-O2",
+			FFlags:       "-O2",
+			Description:  "Generic optimization that runs on any instance of the architecture",
+		},
+		{
+			Name:         "skylake",
+			Architecture: "x86_64",
+			CFlags:       "-O3 -march=skylake-avx512",
+			FFlags:       "-O3 -march=skylake-avx512",
+			Description:  "Intel Skylake/Cascade Lake (c5, m5, r5)",
+		},
+		{
+			Name:         "icelake",
+			Architecture: "x86_64",
+			CFlags:       "-O3 -march=icelake-server",
+			FFlags:       "-O3 -march=icelake-server",
+			Description:  "Intel Ice Lake (c6i, m6i, r6i)",
+		},
+		{
+			Name:         "sapphirerapids",
+			Architecture: "x86_64",
+			CFlags:       "-O3 -march=sapphirerapids",
+			FFlags:       "-O3 -march=sapphirerapids",
+			Description:  "Intel Sapphire Rapids (c7i, m7i, r7i)",
+		},
+		{
+			Name:         "zen3",
+			Architecture: "x86_64",
+			CFlags:       "-O3 -march=znver3",
+			FFlags:       "-O3 -march=znver3",
+			Description:  "AMD EPYC Milan (c6a, m6a, hpc6a)",
+		},
+		{
+			Name:         "zen4",
+			Architecture: "x86_64",
+			CFlags:       "-O3 -march=znver4",
+			FFlags:       "-O3 -march=znver4",
+			Description:  "AMD EPYC Genoa (c7a, m7a, hpc7a)",
+		},
+		{
+			Name:         "graviton2",
+			Architecture: "arm64",
+			CFlags:       "-O3 -mcpu=neoverse-n1",
+			FFlags:       "-O3 -mcpu=neoverse-n1",
+			Description:  "AWS Graviton2 (c6g, m6g, r6g)",
+		},
+		{
+			Name:         "graviton3",
+			Architecture: "arm64",
+			CFlags:       "-O3 -mcpu=neoverse-v1",
+			FFlags:       "-O3 -mcpu=neoverse-v1",
+			Description:  "AWS Graviton3 (c7g, m7g, r7g, hpc7g)",
+		},
+		{
+			Name:         "graviton4",
+			Architecture: "arm64",
+			CFlags:       "-O3 -mcpu=neoverse-v2",
+			FFlags:       "-O3 -mcpu=neoverse-v2",
+			Description:  "AWS Graviton4 (c8g, m8g, r8g)",
+		},
+		{
+			Name:         "graviton3-sve",
+			Architecture: "arm64",
+			CFlags:       "-O3 -mcpu=neoverse-v1 -msve-vector-bits=256",
+			FFlags:       "-O3 -mcpu=neoverse-v1 -msve-vector-bits=256",
+			Description:  "AWS Graviton3 with fixed-length 256-bit SVE vectorization (not portable to Graviton4)",
+		},
+		{
+			Name:         "graviton4-sve",
+			Architecture: "arm64",
+			CFlags:       "-O3 -mcpu=neoverse-v2 -msve-vector-bits=128",
+			FFlags:       "-O3 -mcpu=neoverse-v2 -msve-vector-bits=128",
+			Description:  "AWS Graviton4 with fixed-length 128-bit SVE2 vectorization",
+		},
+	}
+}
+
+// GetOptimizationPreset returns an optimization preset by name
+func GetOptimizationPreset(name string) (*OptimizationPreset, error) {
+	for _, preset := range GetOptimizationPresets() {
+		if preset.Name == name {
+			return &preset, nil
+		}
+	}
+
+	return nil, fmt.Errorf("optimization preset '%s' not found (available: %s)", name, strings.Join(optimizationPresetNames(), ", "))
+}
+
+// SupportsArchitecture reports whether the preset can be used for the given architecture
+func (op *OptimizationPreset) SupportsArchitecture(arch string) bool {
+	return op.Architecture == "any" || op.Architecture == arch
+}
+
+// ListOptimizationPresets returns a formatted list of presets for an architecture ("" for all)
+func ListOptimizationPresets(arch string) string {
+	var result strings.Builder
+
+	result.WriteString("Available Optimization Presets:\n\n")
+
+	for _, preset := range GetOptimizationPresets() {
+		if arch != "" && !preset.SupportsArchitecture(arch) {
+			continue
+		}
+		result.WriteString(fmt.Sprintf("• %s (%s)\n", preset.Name, preset.Architecture))
+		result.WriteString(fmt.Sprintf("  Flags: %s\n", preset.FFlags))
+		result.WriteString(fmt.Sprintf("  Description: %s\n", preset.Description))
+		result.WriteString("\n")
+	}
+
+	return result.String()
+}
+
+// optimizationPresetNames returns the sorted names of all presets
+func optimizationPresetNames() []string {
+	var names []string
+	for _, preset := range GetOptimizationPresets() {
+		names = append(names, preset.Name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,181 @@
+package geoschem
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Labels recording a dependencies image's stack and the GEOS-Chem releases it supports
+const (
+	StackLabel      = "geoschem.deps.stack"
+	CompatibleLabel = "geoschem.deps.geoschem_versions"
+)
+
+// DependencyStack is a versioned library stack published as dependencies images, with the
+// GEOS-Chem release series it has been validated against
+type DependencyStack struct {
+	Version     string
+	Libraries   DependencyVersions
+	GeosChem    []string // Release series, e.g. "14.4" covers 14.4.0 through 14.4.x
+	Description string
+}
+
+// DefaultDependencyStack is the stack whose libraries DefaultDependencyVersions pins
+const DefaultDependencyStack = "2024.03"
+
+// GetDependencyStacks returns the known stacks, oldest first
+func GetDependencyStacks() []DependencyStack {
+	return []DependencyStack{
+		{
+			Version: "2023.10",
+			Libraries: DependencyVersions{
+				NetCDFC:       "4.9.2",
+				NetCDFFortran: "4.6.1",
+				HDF5:          "1.14.1",
+				ESMF:          "8.4.2",
+				OpenMPI:       "4.1.6",
+			},
+			GeosChem:    []string{"14.1", "14.2", "14.3"},
+			Description: "ESMF 8.4 and Open MPI 4.1, for GEOS-Chem releases before 14.4",
+		},
+		{
+			Version:     DefaultDependencyStack,
+			Libraries:   DefaultDependencyVersions(),
+			GeosChem:    []string{"14.3", "14.4"},
+			Description: "ESMF 8.6 and Open MPI 5.0 (published images)",
+		},
+		{
+			Version: "2024.09",
+			Libraries: DependencyVersions{
+				NetCDFC:       "4.9.2",
+				NetCDFFortran: "4.6.1",
+				HDF5:          "1.14.5",
+				ESMF:          "8.6.1",
+				OpenMPI:       "5.0.5",
+			},
+			GeosChem:    []string{"14.4", "14.5"},
+			Description: "HDF5 1.14.5 and ESMF 8.6.1, required by GEOS-Chem 14.5",
+		},
+	}
+}
+
+// GetDependencyStack returns a stack by version
+func GetDependencyStack(version string) (*DependencyStack, error) {
+	for _, stack := range GetDependencyStacks() {
+		if stack.Version == version {
+			return &stack, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown dependency stack '%s'", version)
+}
+
+// Supports reports whether the stack has been validated with a GEOS-Chem version
+func (ds *DependencyStack) Supports(geosChemVersion string) bool {
+	return supportsVersion(ds.GeosChem, geosChemVersion)
+}
+
+// supportsVersion matches a version against release series
+func supportsVersion(series []string, version string) bool {
+	for _, s := range series {
+		if version == s || strings.HasPrefix(version, s+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// geosChemSpec matches the GEOS-Chem package in a Spack spec; GCHP shares its version
+var geosChemSpec = regexp.MustCompile(`(?:geos-chem|gchp)@([0-9][0-9.]*)`)
+
+// GeosChemVersion returns the GEOS-Chem version in the configuration's Spack spec
+func (bc *BuildConfiguration) GeosChemVersion() string {
+	match := geosChemSpec.FindStringSubmatch(bc.BuildArgs["SPACK_SPEC"])
+	if match == nil {
+		return ""
+	}
+	return strings.TrimSuffix(match[1], ".")
+}
+
+// ResolveDependencyStack picks the configuration's dependency stack and checks it supports
+// the GEOS-Chem version. A named stack must support it. Otherwise the stack matching the
+// pinned libraries is kept when it does, and the newest stack that does replaces it when
+// it doesn't. Libraries pinned outside any stack are left unchecked.
+func (bc *BuildConfiguration) ResolveDependencyStack() error {
+	version := bc.GeosChemVersion()
+
+	if bc.DependencyStack != "" {
+		stack, err := GetDependencyStack(bc.DependencyStack)
+		if err != nil {
+			return err
+		}
+		if version != "" && !stack.Supports(version) {
+			return fmt.Errorf("dependency stack %s supports GEOS-Chem %s, not %s",
+				stack.Version, strings.Join(stack.GeosChem, ", "), version)
+		}
+		bc.Dependencies = stack.Libraries
+		return nil
+	}
+
+	var current *DependencyStack
+	for _, stack := range GetDependencyStacks() {
+		if stack.Libraries == bc.Dependencies {
+			stack := stack
+			current = &stack
+		}
+	}
+	if current == nil {
+		return nil
+	}
+	if version == "" || current.Supports(version) {
+		bc.DependencyStack = current.Version
+		return nil
+	}
+
+	stacks := GetDependencyStacks()
+	for i := len(stacks) - 1; i >= 0; i-- {
+		if stacks[i].Supports(version) {
+			bc.DependencyStack = stacks[i].Version
+			bc.Dependencies = stacks[i].Libraries
+			return nil
+		}
+	}
+	return fmt.Errorf("no dependency stack supports GEOS-Chem %s", version)
+}
+
+// CheckDependenciesImage checks a dependencies image's labels against a GEOS-Chem version.
+// Images without the labels predate them and pass.
+func CheckDependenciesImage(labels map[string]string, geosChemVersion string) error {
+	compatible, ok := labels[CompatibleLabel]
+	if !ok || geosChemVersion == "" {
+		return nil
+	}
+	if !supportsVersion(strings.Split(compatible, ","), geosChemVersion) {
+		return fmt.Errorf("dependencies image (stack %s) supports GEOS-Chem %s, not %s",
+			labels[StackLabel], compatible, geosChemVersion)
+	}
+	return nil
+}
+
+// ListDependencyStacks returns a formatted compatibility table of the dependency stacks
+func ListDependencyStacks() string {
+	var result strings.Builder
+
+	result.WriteString("Dependency Stacks:\n\n")
+
+	for _, stack := range GetDependencyStacks() {
+		libs := stack.Libraries
+		marker := ""
+		if stack.Version == DefaultDependencyStack {
+			marker = " (default)"
+		}
+		result.WriteString(fmt.Sprintf("• %s%s\n", stack.Version, marker))
+		result.WriteString(fmt.Sprintf("  GEOS-Chem: %s\n", strings.Join(stack.GeosChem, ", ")))
+		result.WriteString(fmt.Sprintf("  Libraries: netcdf-c %s, netcdf-fortran %s, hdf5 %s, esmf %s, openmpi %s\n",
+			libs.NetCDFC, libs.NetCDFFortran, libs.HDF5, libs.ESMF, libs.OpenMPI))
+		result.WriteString(fmt.Sprintf("  Description: %s\n", stack.Description))
+		result.WriteString("\n")
+	}
+
+	return result.String()
+}
@@ -0,0 +1,164 @@
+// Package hemco describes changes to the emissions a run reads: scale factors, alternative
+// inventories and regional masks. The image's run directory generator merges them into
+// HEMCO_Config.rc, so runs differ from the template without a hand-edited copy.
+package hemco
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+)
+
+// Override changes the base emissions containers whose names match Inventory. Scale and
+// Mask add scale factors to the containers; File replaces the data they read.
+type Override struct {
+	Inventory string   `yaml:"inventory" json:"inventory"`                   // Container name or glob from HEMCO_Config.rc, e.g. CEDS_*_ANTH
+	Scale     *float64 `yaml:"scale,omitempty" json:"scale,omitempty"`       // Multiplies the emissions; 0 switches them off
+	File      string   `yaml:"file,omitempty" json:"file,omitempty"`         // Alternative inventory under the HEMCO data directory; HEMCO tokens such as $YYYY allowed
+	Variable  string   `yaml:"variable,omitempty" json:"variable,omitempty"` // Variable in File (default: the container's)
+	Mask      *Mask    `yaml:"mask,omitempty" json:"mask,omitempty"`         // Keeps the emissions only inside a region
+}
+
+// Mask is a regional mask file, 1 inside the region and 0 outside
+type Mask struct {
+	File     string `yaml:"file" json:"file"`         // Under the HEMCO data directory
+	Variable string `yaml:"variable" json:"variable"` // Mask variable in File
+	Box      string `yaml:"box" json:"box"`           // lon1/lat1/lon2/lat2 bounding the region
+}
+
+// LoadOverrides reads and validates a YAML list of overrides
+func LoadOverrides(filename string) ([]Override, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading emissions overrides: %w", err)
+	}
+
+	var overrides []Override
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("parsing emissions overrides: %w", err)
+	}
+	if err := Validate(overrides); err != nil {
+		return nil, fmt.Errorf("invalid emissions overrides %s: %w", filename, err)
+	}
+	return overrides, nil
+}
+
+// Validate checks each override changes something and names its files sensibly
+func Validate(overrides []Override) error {
+	for i, override := range overrides {
+		if override.Inventory == "" {
+			return fmt.Errorf("override %d: inventory is required", i+1)
+		}
+		if _, err := path.Match(override.Inventory, ""); err != nil {
+			return fmt.Errorf("override %s: invalid inventory pattern: %w", override.Inventory, err)
+		}
+		if override.Scale == nil && override.File == "" && override.Mask == nil {
+			return fmt.Errorf("override %s: set scale, file, or mask", override.Inventory)
+		}
+		if override.Scale != nil && *override.Scale < 0 {
+			return fmt.Errorf("override %s: scale cannot be negative: %g", override.Inventory, *override.Scale)
+		}
+		if override.Variable != "" && override.File == "" {
+			return fmt.Errorf("override %s: variable needs file", override.Inventory)
+		}
+		if err := checkPath(override.File); err != nil {
+			return fmt.Errorf("override %s: %w", override.Inventory, err)
+		}
+		if mask := override.Mask; mask != nil {
+			if mask.File == "" || mask.Variable == "" {
+				return fmt.Errorf("override %s: mask needs file and variable", override.Inventory)
+			}
+			if err := checkPath(mask.File); err != nil {
+				return fmt.Errorf("override %s: mask: %w", override.Inventory, err)
+			}
+			if err := checkBox(mask.Box); err != nil {
+				return fmt.Errorf("override %s: mask: %w", override.Inventory, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkPath rejects files outside the HEMCO data directory
+func checkPath(file string) error {
+	if file == "" {
+		return nil
+	}
+	if path.IsAbs(file) || strings.HasPrefix(file, "$ROOT") || strings.Contains(file, "..") {
+		return fmt.Errorf("file %s must be relative to the HEMCO data directory", file)
+	}
+	return nil
+}
+
+// checkBox checks a lon1/lat1/lon2/lat2 region
+func checkBox(box string) error {
+	corners := strings.Split(box, "/")
+	if len(corners) != 4 {
+		return fmt.Errorf("box %q must be lon1/lat1/lon2/lat2", box)
+	}
+	for _, corner := range corners {
+		if _, err := strconv.ParseFloat(corner, 64); err != nil {
+			return fmt.Errorf("box %q must be lon1/lat1/lon2/lat2", box)
+		}
+	}
+	return nil
+}
+
+// Encode returns the overrides as the JSON the image's --hemco-overrides option reads
+func Encode(overrides []Override) (string, error) {
+	encoded, err := json.Marshal(overrides)
+	if err != nil {
+		return "", fmt.Errorf("encoding emissions overrides: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// Files returns the files the overrides read, relative to the HEMCO data directory
+func Files(overrides []Override) []string {
+	var files []string
+	for _, override := range overrides {
+		if override.File != "" {
+			files = append(files, override.File)
+		}
+		if override.Mask != nil {
+			files = append(files, override.Mask.File)
+		}
+	}
+	return files
+}
+
+// CheckStaged checks the files the overrides read are in the data source staged for the
+// run, under its HEMCO directory. A file with HEMCO tokens such as $YYYY passes when
+// anything exists under the part of its path before the first token.
+func CheckStaged(ctx context.Context, cli *awscli.Client, dataSource string, overrides []Override) error {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(dataSource, "s3://"), "/")
+	if !strings.HasPrefix(dataSource, "s3://") || bucket == "" {
+		return fmt.Errorf("data source must be an s3:// URI, got %s", dataSource)
+	}
+	root := path.Join(prefix, "HEMCO")
+
+	var missing []string
+	for _, file := range Files(overrides) {
+		key, _, _ := strings.Cut(path.Join(root, file), "$")
+		var count int
+		if err := cli.Run(ctx, &count, "s3api", "list-objects-v2",
+			"--bucket", bucket, "--prefix", key, "--max-keys", "1", "--query", "KeyCount"); err != nil {
+			return fmt.Errorf("checking %s in %s: %w", file, dataSource, err)
+		}
+		if count == 0 {
+			missing = append(missing, file)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("emissions override files not in %s/HEMCO: %s", strings.TrimSuffix(dataSource, "/"), strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Finding severities, most serious first
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+// broadPrefixBits is the shortest IPv4 prefix still treated as a narrow source; anything
+// broader reaching SSH is flagged
+const broadPrefixBits = 24
+
+// Finding is one over-permissive rule in a security group
+type Finding struct {
+	GroupID  string
+	Severity string
+	Rule     string // e.g. "ingress tcp 22 from 0.0.0.0/0"
+	Message  string
+}
+
+// Audit flags rules in the groups that allow more than build and run instances need.
+// sshNeeded is false when instances are driven through SSM, so any SSH ingress is excess.
+func (m *Manager) Audit(ctx context.Context, groupIDs []string, sshNeeded bool) ([]Finding, error) {
+	out, err := m.ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{GroupIds: groupIDs})
+	if err != nil {
+		return nil, fmt.Errorf("describing security groups: %w", err)
+	}
+
+	var findings []Finding
+	for _, group := range out.SecurityGroups {
+		groupID := aws.ToString(group.GroupId)
+		for _, permission := range group.IpPermissions {
+			for _, source := range sources(permission) {
+				rule := fmt.Sprintf("ingress %s from %s", describePorts(permission), source)
+				add := func(severity, message string) {
+					findings = append(findings, Finding{GroupID: groupID, Severity: severity, Rule: rule, Message: message})
+				}
+
+				world := source == "0.0.0.0/0" || source == "::/0"
+				switch {
+				case world && allPorts(permission):
+					add(SeverityHigh, "every port is open to the internet")
+				case world && includesPort(permission, portSSH):
+					add(SeverityHigh, "SSH is open to the internet; restrict it to your IP (network create-sg) or use the ssm backend")
+				case world:
+					add(SeverityMedium, "open to the internet; build and run instances accept no public traffic besides SSH")
+				case includesPort(permission, portSSH) && broadCIDR(source):
+					add(SeverityMedium, "SSH is open to a broad range; restrict it to your IP")
+				}
+				if !sshNeeded && includesPort(permission, portSSH) && !world {
+					add(SeverityLow, "SSH is allowed but the ssm backend doesn't use it")
+				}
+			}
+		}
+
+		for _, permission := range group.IpPermissionsEgress {
+			for _, source := range sources(permission) {
+				if (source == "0.0.0.0/0" || source == "::/0") && allPorts(permission) {
+					findings = append(findings, Finding{
+						GroupID:  groupID,
+						Severity: SeverityLow,
+						Rule:     fmt.Sprintf("egress %s to %s", describePorts(permission), source),
+						Message:  "unrestricted egress; builds and runs only need HTTPS and HTTP out, plus traffic within the group",
+					})
+				}
+			}
+		}
+	}
+
+	severityRank := map[string]int{SeverityHigh: 0, SeverityMedium: 1, SeverityLow: 2}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank[findings[i].Severity] < severityRank[findings[j].Severity]
+	})
+	return findings, nil
+}
+
+// sources returns the CIDR ranges a rule applies to; rules between security groups and to
+// prefix lists are not flagged
+func sources(permission types.IpPermission) []string {
+	var cidrs []string
+	for _, r := range permission.IpRanges {
+		cidrs = append(cidrs, aws.ToString(r.CidrIp))
+	}
+	for _, r := range permission.Ipv6Ranges {
+		cidrs = append(cidrs, aws.ToString(r.CidrIpv6))
+	}
+	return cidrs
+}
+
+// allPorts reports whether a rule covers every protocol or every port of its protocol
+func allPorts(permission types.IpPermission) bool {
+	if aws.ToString(permission.IpProtocol) == "-1" {
+		return true
+	}
+	return aws.ToInt32(permission.FromPort) <= 0 && aws.ToInt32(permission.ToPort) >= 65535
+}
+
+// includesPort reports whether a TCP rule covers the port
+func includesPort(permission types.IpPermission, port int32) bool {
+	switch aws.ToString(permission.IpProtocol) {
+	case "-1":
+		return true
+	case "tcp", "6":
+		return aws.ToInt32(permission.FromPort) <= port && aws.ToInt32(permission.ToPort) >= port
+	}
+	return false
+}
+
+// broadCIDR reports whether an IPv4 range is wider than broadPrefixBits, or an IPv6 range
+// wider than a /64
+func broadCIDR(cidr string) bool {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	ones, bits := network.Mask.Size()
+	if bits == 128 {
+		return ones < 64
+	}
+	return ones < broadPrefixBits
+}
+
+// describePorts renders a rule's protocol and port range
+func describePorts(permission types.IpPermission) string {
+	protocol := aws.ToString(permission.IpProtocol)
+	if protocol == "-1" {
+		return "all traffic"
+	}
+	from, to := aws.ToInt32(permission.FromPort), aws.ToInt32(permission.ToPort)
+	if from == to {
+		return fmt.Sprintf("%s %d", protocol, from)
+	}
+	return fmt.Sprintf("%s %d-%d", protocol, from, to)
+}
+
+// FormatFindings renders findings grouped by security group
+func FormatFindings(findings []Finding) string {
+	if len(findings) == 0 {
+		return "✅ No over-permissive rules found\n"
+	}
+	icons := map[string]string{SeverityHigh: "🔴", SeverityMedium: "🟠", SeverityLow: "🟡"}
+	var b strings.Builder
+	for _, finding := range findings {
+		fmt.Fprintf(&b, "%s %s %s: %s\n   %s\n", icons[finding.Severity], finding.GroupID, finding.Severity, finding.Rule, finding.Message)
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package network
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// MissingEFARules lists the rules EFA needs that the group lacks. EFA traffic isn't IP, so
+// port rules can't admit it; the group has to allow all traffic in from and out to itself.
+func (m *Manager) MissingEFARules(ctx context.Context, groupID string) ([]string, error) {
+	group, err := m.describeGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	if !allowsSelf(group.IpPermissions, groupID) {
+		missing = append(missing, "ingress all traffic from "+groupID)
+	}
+	if !allowsSelf(group.IpPermissionsEgress, groupID) {
+		missing = append(missing, "egress all traffic to "+groupID)
+	}
+	return missing, nil
+}
+
+// AddEFARules adds the self-referencing all-traffic rules EFA needs to the group, if it
+// lacks them, and returns the rules added
+func (m *Manager) AddEFARules(ctx context.Context, groupID string) ([]string, error) {
+	group, err := m.describeGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	self := []types.IpPermission{{
+		IpProtocol:       aws.String("-1"),
+		UserIdGroupPairs: []types.UserIdGroupPair{{GroupId: aws.String(groupID), Description: aws.String("Members of this group (EFA)")}},
+	}}
+
+	var added []string
+	if !allowsSelf(group.IpPermissions, groupID) {
+		if _, err := m.ec2Client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       aws.String(groupID),
+			IpPermissions: self,
+		}); err != nil {
+			return added, fmt.Errorf("adding EFA ingress rule to %s: %w", groupID, err)
+		}
+		added = append(added, "ingress all traffic from "+groupID)
+	}
+	if !allowsSelf(group.IpPermissionsEgress, groupID) {
+		if _, err := m.ec2Client.AuthorizeSecurityGroupEgress(ctx, &ec2.AuthorizeSecurityGroupEgressInput{
+			GroupId:       aws.String(groupID),
+			IpPermissions: self,
+		}); err != nil {
+			return added, fmt.Errorf("adding EFA egress rule to %s: %w", groupID, err)
+		}
+		added = append(added, "egress all traffic to "+groupID)
+	}
+	return added, nil
+}
+
+func (m *Manager) describeGroup(ctx context.Context, groupID string) (types.SecurityGroup, error) {
+	out, err := m.ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{GroupIds: []string{groupID}})
+	if err != nil {
+		return types.SecurityGroup{}, fmt.Errorf("describing security group %s: %w", groupID, err)
+	}
+	if len(out.SecurityGroups) == 0 {
+		return types.SecurityGroup{}, fmt.Errorf("security group %s not found", groupID)
+	}
+	return out.SecurityGroups[0], nil
+}
+
+// allowsSelf reports whether the rules allow every protocol to and from the group's own
+// members; a TCP rule over all ports doesn't carry EFA
+func allowsSelf(permissions []types.IpPermission, groupID string) bool {
+	for _, permission := range permissions {
+		if aws.ToString(permission.IpProtocol) != "-1" {
+			continue
+		}
+		for _, pair := range permission.UserIdGroupPairs {
+			if aws.ToString(pair.GroupId) == groupID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// checkIPURL returns the caller's public IP address
+const checkIPURL = "https://checkip.amazonaws.com"
+
+// Ports build and run instances are reached on or reach out to
+const (
+	portSSH   = 22
+	portHTTP  = 80
+	portHTTPS = 443
+)
+
+// GroupSpec describes a least-privilege security group for build and run instances
+type GroupSpec struct {
+	Name  string
+	VPCID string
+	// SSHCIDR is the only source allowed to reach SSH, normally the caller's /32. Empty
+	// creates an SSM-only group with no ingress from outside the group.
+	SSHCIDR string
+}
+
+// Manager creates and audits security groups
+type Manager struct {
+	ec2Client *ec2.Client
+}
+
+// NewManager creates a manager from an AWS config
+func NewManager(cfg aws.Config) *Manager {
+	return &Manager{ec2Client: ec2.NewFromConfig(cfg)}
+}
+
+// CallerCIDR returns the caller's current public IP address as a /32
+func CallerCIDR(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkIPURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("looking up public IP: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("looking up public IP: %s returned %s", checkIPURL, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return "", fmt.Errorf("looking up public IP: %w", err)
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil || ip.To4() == nil {
+		return "", fmt.Errorf("unexpected public IP %q from %s", strings.TrimSpace(string(body)), checkIPURL)
+	}
+	return ip.String() + "/32", nil
+}
+
+// CreateSecurityGroup creates a group that allows:
+//   - SSH from spec.SSHCIDR only, or no outside ingress at all for SSM-driven instances
+//   - all traffic between members, for NFS (EFS), Lustre (FSx) and MPI between GCHP nodes
+//   - HTTPS and HTTP out, for AWS APIs, ECR, S3, SSM, source checkouts and package mirrors;
+//     deploy-key clones from GitHub reach its SSH endpoint on 443 (ssh.github.com)
+//
+// The default allow-all egress rule is removed. DNS to the VPC resolver isn't filtered by
+// security groups, so it needs no rule.
+func (m *Manager) CreateSecurityGroup(ctx context.Context, spec GroupSpec) (string, error) {
+	if spec.SSHCIDR != "" {
+		if _, _, err := net.ParseCIDR(spec.SSHCIDR); err != nil {
+			return "", fmt.Errorf("invalid SSH source: %w", err)
+		}
+	}
+
+	description := "GeosChem build and run instances: SSM only"
+	if spec.SSHCIDR != "" {
+		description = "GeosChem build and run instances: SSH from " + spec.SSHCIDR
+	}
+	created, err := m.ec2Client.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
+		GroupName:   aws.String(spec.Name),
+		Description: aws.String(description),
+		VpcId:       aws.String(spec.VPCID),
+		TagSpecifications: []types.TagSpecification{{
+			ResourceType: types.ResourceTypeSecurityGroup,
+			Tags: []types.Tag{
+				{Key: aws.String("Name"), Value: aws.String(spec.Name)},
+				{Key: aws.String("Project"), Value: aws.String("geoschem-aws")},
+			},
+		}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("creating security group %s: %w", spec.Name, err)
+	}
+	groupID := aws.ToString(created.GroupId)
+
+	self := []types.UserIdGroupPair{{GroupId: aws.String(groupID), Description: aws.String("Members of this group")}}
+	ingress := []types.IpPermission{{IpProtocol: aws.String("-1"), UserIdGroupPairs: self}}
+	if spec.SSHCIDR != "" {
+		ingress = append(ingress, tcpPermission(portSSH, types.IpRange{CidrIp: aws.String(spec.SSHCIDR), Description: aws.String("SSH from the operator")}))
+	}
+	if _, err := m.ec2Client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(groupID),
+		IpPermissions: ingress,
+	}); err != nil {
+		return groupID, fmt.Errorf("adding ingress rules to %s: %w", groupID, err)
+	}
+
+	anywhere := types.IpRange{CidrIp: aws.String("0.0.0.0/0")}
+	egress := []types.IpPermission{
+		tcpPermission(portHTTPS, anywhere),
+		tcpPermission(portHTTP, anywhere),
+		{IpProtocol: aws.String("-1"), UserIdGroupPairs: self},
+	}
+	if _, err := m.ec2Client.AuthorizeSecurityGroupEgress(ctx, &ec2.AuthorizeSecurityGroupEgressInput{
+		GroupId:       aws.String(groupID),
+		IpPermissions: egress,
+	}); err != nil {
+		return groupID, fmt.Errorf("adding egress rules to %s: %w", groupID, err)
+	}
+	if _, err := m.ec2Client.RevokeSecurityGroupEgress(ctx, &ec2.RevokeSecurityGroupEgressInput{
+		GroupId:       aws.String(groupID),
+		IpPermissions: []types.IpPermission{{IpProtocol: aws.String("-1"), IpRanges: []types.IpRange{anywhere}}},
+	}); err != nil {
+		return groupID, fmt.Errorf("removing default egress rule from %s: %w", groupID, err)
+	}
+
+	return groupID, nil
+}
+
+// tcpPermission allows one TCP port from or to a CIDR range
+func tcpPermission(port int32, ipRange types.IpRange) types.IpPermission {
+	return types.IpPermission{
+		IpProtocol: aws.String("tcp"),
+		FromPort:   aws.Int32(port),
+		ToPort:     aws.Int32(port),
+		IpRanges:   []types.IpRange{ipRange},
+	}
+}
@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+)
+
+// SNS subjects are limited to 100 characters
+const maxSubjectLength = 100
+
+// Notifier publishes run completion messages to an SNS topic
+type Notifier struct {
+	cli      *awscli.Client
+	topicARN string
+}
+
+// New creates a notifier for the topic. The region is taken from the topic ARN.
+func New(profile, topicARN string) (*Notifier, error) {
+	parts := strings.Split(topicARN, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sns" {
+		return nil, fmt.Errorf("invalid SNS topic ARN: %s", topicARN)
+	}
+	return &Notifier{cli: awscli.New(profile, parts[3]), topicARN: topicARN}, nil
+}
+
+// Send publishes a message to the topic
+func (n *Notifier) Send(ctx context.Context, subject, message string) error {
+	if len(subject) > maxSubjectLength {
+		subject = subject[:maxSubjectLength]
+	}
+	if err := n.cli.Run(ctx, nil, "sns", "publish",
+		"--topic-arn", n.topicARN,
+		"--subject", subject,
+		"--message", message); err != nil {
+		return fmt.Errorf("sending notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package profiling shows where a long-running command such as a matrix build spends its
+// memory, goroutines and CPU: pprof profiles over HTTP, a runtime execution trace, and a
+// periodic summary of goroutine and heap counts.
+package profiling
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/trace"
+	"time"
+)
+
+// statsInterval is how often the goroutine and heap summary is printed while profiling
+const statsInterval = 30 * time.Second
+
+// Options select what to collect. The zero value collects nothing.
+type Options struct {
+	Addr      string // Serve the pprof endpoints here, such as localhost:6060
+	TraceFile string // Write a runtime execution trace to this file
+}
+
+// Enabled reports whether anything is collected
+func (o Options) Enabled() bool {
+	return o.Addr != "" || o.TraceFile != ""
+}
+
+// Start begins collecting and returns a function that stops it and finishes the trace file.
+// A command that exits without calling it leaves a truncated trace.
+func Start(opts Options) (stop func(), err error) {
+	if !opts.Enabled() {
+		return func() {}, nil
+	}
+
+	var stops []func()
+	stopAll := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	if opts.TraceFile != "" {
+		file, err := os.Create(opts.TraceFile)
+		if err != nil {
+			return nil, fmt.Errorf("creating trace file: %w", err)
+		}
+		if err := trace.Start(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("starting execution trace: %w", err)
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			file.Close()
+			fmt.Fprintf(os.Stderr, "🔬 Execution trace written to %s (view with 'go tool trace %s')\n", opts.TraceFile, opts.TraceFile)
+		})
+	}
+
+	if opts.Addr != "" {
+		listener, err := net.Listen("tcp", opts.Addr)
+		if err != nil {
+			stopAll()
+			return nil, fmt.Errorf("listening for pprof on %s: %w", opts.Addr, err)
+		}
+		// A mux of its own keeps the endpoints off http.DefaultServeMux
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go server.Serve(listener)
+		stops = append(stops, func() { server.Close() })
+		fmt.Fprintf(os.Stderr, "🔬 pprof at http://%s/debug/pprof/\n", listener.Addr())
+	}
+
+	done := make(chan struct{})
+	go printStats(done)
+	stops = append(stops, func() { close(done) })
+
+	// Heap and goroutine profiles are always collected; block and mutex profiles need sampling
+	runtime.SetBlockProfileRate(int(time.Millisecond))
+	runtime.SetMutexProfileFraction(100)
+	return stopAll, nil
+}
+
+// printStats prints goroutine, heap and GC counts until done is closed
+func printStats(done <-chan struct{}) {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			var mem runtime.MemStats
+			runtime.ReadMemStats(&mem)
+			fmt.Fprintf(os.Stderr, "🔬 %d goroutines, %.1f MiB heap in use, %.1f MiB from the OS, %d GCs\n",
+				runtime.NumGoroutine(), float64(mem.HeapInuse)/(1<<20), float64(mem.Sys)/(1<<20), mem.NumGC)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package provenance
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Difference is one field two runs recorded differently; a missing field is empty
+type Difference struct {
+	Field string
+	A, B  string
+}
+
+// Fields flattens the provenance to one value per field. The ID, output location and
+// recording time identify the run rather than determine its results, so they are left out;
+// so is the config hash, which only summarizes the settings and config files.
+func (r *Record) Fields() map[string]string {
+	fields := map[string]string{
+		"image":         r.Image,
+		"image_digest":  r.ImageDigest,
+		"instance_type": r.InstanceType,
+		"scheduler":     r.Scheduler,
+		"platform":      r.Platform,
+	}
+	for name, value := range r.Settings {
+		fields["setting."+name] = value
+	}
+	for role, hash := range r.ConfigFiles {
+		fields["config_file."+role] = hash
+	}
+	for role, input := range r.Inputs {
+		fields["input."+role] = input.URI
+		if input.ETag != "" {
+			fields["input."+role+".etag"] = input.ETag
+		}
+	}
+	return fields
+}
+
+// Diff returns every field the runs recorded differently, by field name
+func Diff(a, b *Record) []Difference {
+	fieldsA, fieldsB := a.Fields(), b.Fields()
+	names := make(map[string]bool)
+	for name := range fieldsA {
+		names[name] = true
+	}
+	for name := range fieldsB {
+		names[name] = true
+	}
+
+	var differences []Difference
+	for name := range names {
+		if fieldsA[name] != fieldsB[name] {
+			differences = append(differences, Difference{Field: name, A: fieldsA[name], B: fieldsB[name]})
+		}
+	}
+	sort.Slice(differences, func(i, j int) bool {
+		return differences[i].Field < differences[j].Field
+	})
+	return differences
+}
+
+// FormatDiff renders the differences between two runs for the console
+func FormatDiff(a, b *Record, differences []Difference) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "A: %s  %s  %s\n", a.ID, a.RecordedAt.Local().Format("2006-01-02 15:04"), orNone(a.OutputURI))
+	fmt.Fprintf(&out, "B: %s  %s  %s\n\n", b.ID, b.RecordedAt.Local().Format("2006-01-02 15:04"), orNone(b.OutputURI))
+	if len(differences) == 0 {
+		out.WriteString("✅ No differences in the recorded provenance\n")
+		return out.String()
+	}
+
+	fmt.Fprintf(&out, "🔀 %d difference(s):\n", len(differences))
+	for _, difference := range differences {
+		fmt.Fprintf(&out, "  %s\n    A: %s\n    B: %s\n", difference.Field, orNone(difference.A), orNone(difference.B))
+	}
+	return out.String()
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
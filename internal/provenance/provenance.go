@@ -0,0 +1,241 @@
+// Package provenance records everything that determined a run's results: the image digest,
+// the run settings, the files that configured it, the input data it read and where it ran.
+// Two runs that should agree can then be compared field by field.
+package provenance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+const provenanceCollection = "provenance"
+
+// Record is the provenance of one run
+type Record struct {
+	ID           string            `json:"id"`
+	RecordedAt   time.Time         `json:"recorded_at"`
+	Image        string            `json:"image"`
+	ImageDigest  string            `json:"image_digest,omitempty"` // Empty when the image is not in ECR
+	InstanceType string            `json:"instance_type"`
+	Scheduler    string            `json:"scheduler"`
+	OutputURI    string            `json:"output_uri,omitempty"`
+	Platform     string            `json:"platform"`               // Version of the tools that submitted the run
+	ConfigHash   string            `json:"config_hash"`            // Fingerprint of the settings and config files (see RunConfigHash)
+	Settings     map[string]string `json:"settings"`               // Run configuration, one value per setting
+	ConfigFiles  map[string]string `json:"config_files,omitempty"` // SHA-256 of the local files that configured the run, by role
+	Inputs       map[string]Input  `json:"inputs,omitempty"`       // Input data by role
+}
+
+// Input is an input location and the version the run read
+type Input struct {
+	URI  string `json:"uri"`
+	ETag string `json:"etag,omitempty"` // S3 objects only; prefixes are recorded by location
+}
+
+// Run describes a finished run to record
+type Run struct {
+	Image        string
+	InstanceType string
+	Scheduler    string
+	OutputURI    string
+	Settings     map[string]string
+	ConfigFiles  map[string]string // Role to local path, hashed when recorded
+	Inputs       map[string]string // Role to S3 URI, versioned when recorded
+}
+
+// AddConfigFile records that a local file configured the run; empty paths are skipped
+func (r *Run) AddConfigFile(role, path string) {
+	if path == "" {
+		return
+	}
+	if r.ConfigFiles == nil {
+		r.ConfigFiles = make(map[string]string)
+	}
+	r.ConfigFiles[role] = path
+}
+
+// Recorder resolves a run's versions and keeps its provenance in the state store. It is
+// safe for concurrent use.
+type Recorder struct {
+	store *state.Store
+	cli   *awscli.Client
+	mu    sync.Mutex
+}
+
+// NewRecorder creates a recorder backed by the store, resolving versions with the CLI
+func NewRecorder(store *state.Store, cli *awscli.Client) *Recorder {
+	return &Recorder{store: store, cli: cli}
+}
+
+// Record resolves the image digest, config file hashes and input versions of a run and
+// stores its provenance. Versions that cannot be resolved are left empty.
+func (r *Recorder) Record(ctx context.Context, run Run) (*Record, error) {
+	record := Record{
+		RecordedAt:   time.Now().UTC(),
+		Image:        run.Image,
+		InstanceType: run.InstanceType,
+		Scheduler:    run.Scheduler,
+		OutputURI:    run.OutputURI,
+		Platform:     common.GetVersion(),
+		Settings:     run.Settings,
+		ConfigFiles:  make(map[string]string),
+		Inputs:       make(map[string]Input),
+	}
+	sum := sha256.Sum256([]byte(run.OutputURI + "\x00" + record.RecordedAt.Format(time.RFC3339Nano)))
+	record.ID = "run-" + hex.EncodeToString(sum[:4])
+
+	if digest, err := ImageDigest(ctx, r.cli, run.Image); err == nil {
+		record.ImageDigest = digest
+	}
+	for role, path := range run.ConfigFiles {
+		record.ConfigFiles[role] = fileHash(path)
+	}
+	for role, uri := range run.Inputs {
+		record.Inputs[role] = Input{URI: uri, ETag: r.etag(ctx, uri)}
+	}
+	record.ConfigHash = RunConfigHash(record.Settings, record.ConfigFiles)
+
+	if err := r.save(record); err != nil {
+		return nil, err
+	}
+
+	// The sidecar travels with the output, so whoever reads it later can tell how it was made
+	if strings.HasPrefix(record.OutputURI, "s3://") {
+		if err := WriteSidecar(ctx, r.cli, &record); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	return &record, nil
+}
+
+func (r *Recorder) save(record Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	records, err := load(r.store)
+	if err != nil {
+		return err
+	}
+	records[record.ID] = record
+	return r.store.Save(provenanceCollection, records)
+}
+
+// RunConfigHash fingerprints a run's settings and config file hashes, so runs configured
+// the same way share a hash whatever image or instance they ran on
+func RunConfigHash(settings, configFiles map[string]string) string {
+	var lines []string
+	for name, value := range settings {
+		lines = append(lines, "setting."+name+"="+value)
+	}
+	for role, hash := range configFiles {
+		lines = append(lines, "config_file."+role+"="+hash)
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// fileHash returns a file's SHA-256, or why it could not be read
+func fileHash(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "unreadable: " + err.Error()
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// etag returns an S3 object's ETag, or empty for prefixes, HEMCO-templated paths and
+// objects that cannot be read
+func (r *Recorder) etag(ctx context.Context, uri string) string {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(uri, "s3://"), "/")
+	if !strings.HasPrefix(uri, "s3://") || key == "" || strings.HasSuffix(key, "/") || strings.Contains(key, "$") {
+		return ""
+	}
+	var etag string
+	if err := r.cli.Run(ctx, &etag, "s3api", "head-object", "--bucket", bucket, "--key", key, "--query", "ETag"); err != nil {
+		return ""
+	}
+	return strings.Trim(etag, `"`)
+}
+
+// ImageDigest resolves an ECR image reference to its digest. Images from other
+// registries return an empty digest.
+func ImageDigest(ctx context.Context, cli *awscli.Client, image string) (string, error) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[i+1:], nil
+	}
+	registry, rest, ok := strings.Cut(image, "/")
+	if !ok || !strings.Contains(registry, ".dkr.ecr.") {
+		return "", nil
+	}
+	repository, tag, ok := strings.Cut(rest, ":")
+	if !ok {
+		tag = "latest"
+	}
+
+	var out struct {
+		ImageDetails []struct {
+			ImageDigest string `json:"imageDigest"`
+		} `json:"imageDetails"`
+	}
+	if err := cli.Run(ctx, &out, "ecr", "describe-images",
+		"--registry-id", strings.Split(registry, ".")[0],
+		"--repository-name", repository,
+		"--image-ids", "imageTag="+tag); err != nil {
+		return "", fmt.Errorf("resolving digest of %s: %w", image, err)
+	}
+	if len(out.ImageDetails) == 0 {
+		return "", fmt.Errorf("image %s not found", image)
+	}
+	return out.ImageDetails[0].ImageDigest, nil
+}
+
+// Find returns a run's provenance by ID, or by the output location it wrote to (the
+// latest run there when several did)
+func Find(store *state.Store, ref string) (*Record, error) {
+	records, err := List(store)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.ID == ref || (record.OutputURI != "" && strings.TrimSuffix(record.OutputURI, "/") == strings.TrimSuffix(ref, "/")) {
+			return &record, nil
+		}
+	}
+	return nil, fmt.Errorf("no recorded run %s", ref)
+}
+
+// List returns every recorded run, newest first
+func List(store *state.Store) ([]Record, error) {
+	records, err := load(store)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Record, 0, len(records))
+	for _, record := range records {
+		list = append(list, record)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].RecordedAt.After(list[j].RecordedAt)
+	})
+	return list, nil
+}
+
+func load(store *state.Store) (map[string]Record, error) {
+	records := make(map[string]Record)
+	if err := store.Load(provenanceCollection, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,78 @@
+package provenance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/artifacts"
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+)
+
+// SidecarName is the provenance file written at the top of every S3 output
+const SidecarName = "provenance.json"
+
+// SidecarURI returns where an output's provenance is written
+func SidecarURI(outputURI string) string {
+	return strings.TrimSuffix(outputURI, "/") + "/" + SidecarName
+}
+
+// WriteSidecar writes the record next to the output it describes, replacing an earlier copy
+func WriteSidecar(ctx context.Context, cli *awscli.Client, record *Record) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp("", "geoschem-provenance-*.json")
+	if err != nil {
+		return err
+	}
+	artifacts.Track(file.Name())
+	defer artifacts.Remove(file.Name())
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	uri := SidecarURI(record.OutputURI)
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(uri, "s3://"), "/")
+	if err := cli.Run(ctx, nil, "s3api", "put-object",
+		"--bucket", bucket, "--key", key, "--body", file.Name(),
+		"--content-type", "application/json"); err != nil {
+		return fmt.Errorf("writing provenance to %s: %w", uri, err)
+	}
+	return nil
+}
+
+// ReadSidecar reads the provenance written with an output
+func ReadSidecar(ctx context.Context, cli *awscli.Client, outputURI string) (*Record, error) {
+	file, err := os.CreateTemp("", "geoschem-provenance-*.json")
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	artifacts.Track(file.Name())
+	defer artifacts.Remove(file.Name())
+
+	uri := SidecarURI(outputURI)
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(uri, "s3://"), "/")
+	if err := cli.Run(ctx, nil, "s3api", "get-object", "--bucket", bucket, "--key", key, file.Name()); err != nil {
+		return nil, fmt.Errorf("reading provenance from %s: %w", uri, err)
+	}
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return nil, err
+	}
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("parsing provenance in %s: %w", uri, err)
+	}
+	return &record, nil
+}
@@ -0,0 +1,124 @@
+package queue
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultEstimate is assumed for jobs submitted without a runtime prediction
+const defaultEstimate = time.Hour
+
+// preemptionEstimate is how long a preempted scavenger is assumed to take to reach its
+// next checkpoint and stop
+const preemptionEstimate = 15 * time.Minute
+
+// Slot is a queued job's place in the fair-share order
+type Slot struct {
+	Job            Job
+	Position       int       // 1 starts next
+	EstimatedStart time.Time // Zero when the job can never fit the vCPU limit
+}
+
+// StartsNow reports whether the job may claim its capacity immediately
+func (s Slot) StartsNow(now time.Time) bool {
+	return !s.EstimatedStart.IsZero() && !s.EstimatedStart.After(now)
+}
+
+// running is a job holding capacity in the simulation
+type running struct {
+	user  string
+	vcpus int
+	end   time.Time
+}
+
+// Plan orders the queued jobs by priority and fair share and estimates when each starts. It
+// simulates the queue forward from now: whenever capacity frees up, the next job is the
+// highest priority class's job from the user holding the fewest vCPUs, oldest submission
+// first, subject to the per-user concurrency limit.
+// Running jobs are assumed to finish at their estimated end, or shortly after now if overdue.
+func Plan(jobs []Job, policy Policy, now time.Time) []Slot {
+	var active []running
+	var queued []Job
+	for _, job := range jobs {
+		switch job.Status {
+		case StatusRunning:
+			end := job.StartedAt.Add(job.estimate())
+			if !job.PreemptRequestedAt.IsZero() && job.PreemptRequestedAt.Add(preemptionEstimate).Before(end) {
+				end = job.PreemptRequestedAt.Add(preemptionEstimate)
+			}
+			if end.Before(now) {
+				end = now.Add(5 * time.Minute)
+			}
+			active = append(active, running{user: job.User, vcpus: job.VCPUs, end: end})
+		case StatusQueued:
+			queued = append(queued, job)
+		}
+	}
+	sort.SliceStable(queued, func(i, j int) bool {
+		return queued[i].SubmittedAt.Before(queued[j].SubmittedAt)
+	})
+
+	var slots []Slot
+	clock := now
+	for len(queued) > 0 {
+		next := pickNext(queued, active, policy)
+		if next < 0 {
+			if len(active) == 0 {
+				// Nothing running and nothing fits: the remaining jobs exceed the limit
+				for _, job := range queued {
+					slots = append(slots, Slot{Job: job, Position: len(slots) + 1})
+				}
+				break
+			}
+			// Advance to the next job finishing
+			sort.Slice(active, func(i, j int) bool { return active[i].end.Before(active[j].end) })
+			if active[0].end.After(clock) {
+				clock = active[0].end
+			}
+			active = active[1:]
+			continue
+		}
+
+		job := queued[next]
+		queued = append(queued[:next], queued[next+1:]...)
+		slots = append(slots, Slot{Job: job, Position: len(slots) + 1, EstimatedStart: clock})
+		active = append(active, running{user: job.User, vcpus: job.VCPUs, end: clock.Add(job.estimate())})
+	}
+	return slots
+}
+
+// pickNext returns the index of the queued job to start next, or -1 if none fits now
+func pickNext(queued []Job, active []running, policy Policy) int {
+	usedTotal := 0
+	usedByUser := make(map[string]int)
+	jobsByUser := make(map[string]int)
+	for _, job := range active {
+		usedTotal += job.vcpus
+		usedByUser[job.user] += job.vcpus
+		jobsByUser[job.user]++
+	}
+
+	best := -1
+	for i, job := range queued {
+		if policy.PerUserLimit > 0 && jobsByUser[job.User] >= policy.PerUserLimit {
+			continue
+		}
+		if policy.VCPULimit > 0 && usedTotal+job.VCPUs > policy.VCPULimit {
+			continue
+		}
+		// Higher classes first; queued is in submission order, so within a class the first job
+		// of the least-served user wins ties
+		if best < 0 || job.rank() > queued[best].rank() ||
+			job.rank() == queued[best].rank() && usedByUser[job.User] < usedByUser[queued[best].User] {
+			best = i
+		}
+	}
+	return best
+}
+
+func (j Job) estimate() time.Duration {
+	if j.EstimatedHours <= 0 {
+		return defaultEstimate
+	}
+	return time.Duration(j.EstimatedHours * float64(time.Hour))
+}
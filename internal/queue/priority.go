@@ -0,0 +1,88 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Priority classes. Higher classes start first; fair share orders jobs within a class.
+const (
+	PriorityUrgent    = "urgent"
+	PriorityNormal    = "normal"
+	PriorityScavenger = "scavenger" // Runs on Spot and gives up its capacity to higher classes
+)
+
+// Preempter checkpoints and stops a running scavenger job so its capacity can be reused
+type Preempter func(ctx context.Context, job Job) error
+
+// ValidatePriority checks a priority class name; empty means normal
+func ValidatePriority(priority string) error {
+	switch priority {
+	case "", PriorityUrgent, PriorityNormal, PriorityScavenger:
+		return nil
+	default:
+		return fmt.Errorf("priority must be %s, %s or %s, got %s", PriorityUrgent, PriorityNormal, PriorityScavenger, priority)
+	}
+}
+
+// PriorityClass returns the job's priority class
+func (j Job) PriorityClass() string {
+	if j.Priority == "" {
+		return PriorityNormal
+	}
+	return j.Priority
+}
+
+// rank orders priority classes; higher starts first
+func (j Job) rank() int {
+	switch j.PriorityClass() {
+	case PriorityUrgent:
+		return 2
+	case PriorityScavenger:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// Preemptible returns the running scavenger jobs to preempt so the job fits in the vCPU
+// limit, most recently started first so older scavengers keep their progress. It returns
+// nil when the job is a scavenger itself, already fits, or wouldn't fit even with every
+// scavenger stopped. Scavengers already asked to stop count as leaving.
+func Preemptible(jobs []Job, policy Policy, job Job) []Job {
+	if policy.VCPULimit <= 0 || job.PriorityClass() == PriorityScavenger {
+		return nil
+	}
+
+	used := 0
+	var scavengers []Job
+	for _, running := range jobs {
+		if running.Status != StatusRunning {
+			continue
+		}
+		if running.PriorityClass() == PriorityScavenger && !running.PreemptRequestedAt.IsZero() {
+			continue
+		}
+		used += running.VCPUs
+		if running.PriorityClass() == PriorityScavenger {
+			scavengers = append(scavengers, running)
+		}
+	}
+	if used+job.VCPUs <= policy.VCPULimit {
+		return nil
+	}
+
+	sort.Slice(scavengers, func(i, j int) bool {
+		return scavengers[i].StartedAt.After(scavengers[j].StartedAt)
+	})
+	var victims []Job
+	for _, scavenger := range scavengers {
+		victims = append(victims, scavenger)
+		used -= scavenger.VCPUs
+		if used+job.VCPUs <= policy.VCPULimit {
+			return victims
+		}
+	}
+	return nil
+}
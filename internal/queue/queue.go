@@ -0,0 +1,427 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+)
+
+// DefaultTable is the DynamoDB table shared by everyone submitting runs in the account
+const DefaultTable = "geoschem-run-queue"
+
+// DefaultPerUserLimit is how many runs one user may have running at once
+const DefaultPerUserLimit = 2
+
+// policyID is the item holding the queue's Policy, and the vCPUs its running jobs hold
+const policyID = "#policy"
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusDone      = "done"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	StatusPaused    = "paused" // Stopped at a restart checkpoint, such as a preempted scavenger
+)
+
+// Job is one run waiting for or holding a share of the account's vCPUs
+type Job struct {
+	ID             string
+	User           string
+	Description    string
+	InstanceType   string
+	VCPUs          int
+	EstimatedHours float64
+	Priority       string // urgent, normal or scavenger; empty is normal
+	Status         string
+	SubmittedAt    time.Time
+	StartedAt      time.Time
+	FinishedAt     time.Time
+	// ClaimedVCPUs is what Claim added to the queue's running total, returned when the job
+	// stops running; 0 for jobs claimed before the queue kept one
+	ClaimedVCPUs int
+	// PreemptRequestedAt is when a higher priority job asked this scavenger to give up its capacity
+	PreemptRequestedAt time.Time
+}
+
+// Policy limits what the queue lets run at once
+type Policy struct {
+	VCPULimit    int // Account-wide vCPUs shared by all queued runs; 0 is unlimited
+	PerUserLimit int // Concurrent runs per user; 0 is unlimited
+}
+
+// Queue is a DynamoDB-backed run queue. Every submitting process schedules itself against
+// the same table, so no separate scheduler service is needed.
+type Queue struct {
+	cli   *awscli.Client
+	table string
+}
+
+// New opens the queue in the named table
+func New(profile, region, table string) *Queue {
+	if table == "" {
+		table = DefaultTable
+	}
+	return &Queue{cli: awscli.New(profile, region), table: table}
+}
+
+// Table returns the DynamoDB table name
+func (q *Queue) Table() string {
+	return q.table
+}
+
+// CreateTable creates the on-demand table and stores the initial policy
+func (q *Queue) CreateTable(ctx context.Context, policy Policy) error {
+	err := q.cli.Run(ctx, nil, "dynamodb", "create-table",
+		"--table-name", q.table,
+		"--attribute-definitions", "AttributeName=id,AttributeType=S",
+		"--key-schema", "AttributeName=id,KeyType=HASH",
+		"--billing-mode", "PAY_PER_REQUEST",
+		"--tags", "Key=Project,Value=geoschem-aws")
+	if err != nil && !strings.Contains(err.Error(), "ResourceInUseException") {
+		return fmt.Errorf("creating queue table: %w", err)
+	}
+	if err := q.cli.Run(ctx, nil, "dynamodb", "wait", "table-exists", "--table-name", q.table); err != nil {
+		return fmt.Errorf("waiting for queue table: %w", err)
+	}
+	return q.SetPolicy(ctx, policy)
+}
+
+// SetPolicy replaces the queue's limits, keeping the vCPUs its running jobs hold
+func (q *Queue) SetPolicy(ctx context.Context, policy Policy) error {
+	err := q.write(ctx, update{
+		TableName:        q.table,
+		Key:              itemKey(policyID),
+		UpdateExpression: "SET vcpu_limit = :vcpu_limit, per_user_limit = :per_user_limit",
+		ExpressionAttributeValues: map[string]awscli.AttributeValue{
+			":vcpu_limit":     awscli.NumberValue(float64(policy.VCPULimit)),
+			":per_user_limit": awscli.NumberValue(float64(policy.PerUserLimit)),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("saving queue policy: %w", err)
+	}
+	return nil
+}
+
+// Submit adds a job to the queue and returns it with its ID and submission time
+func (q *Queue) Submit(ctx context.Context, job Job) (*Job, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	job.ID = "run-" + hex.EncodeToString(id)
+	job.Status = StatusQueued
+	job.SubmittedAt = time.Now().UTC()
+
+	if err := q.putItem(ctx, marshalJob(job), "attribute_not_exists(id)"); err != nil {
+		return nil, fmt.Errorf("submitting to queue: %w", err)
+	}
+	return &job, nil
+}
+
+// State returns the policy and every job in the table. Lab-sized queues fit in a scan.
+func (q *Queue) State(ctx context.Context) (Policy, []Job, error) {
+	var out struct {
+		Items []map[string]awscli.AttributeValue `json:"Items"`
+	}
+	if err := q.cli.Run(ctx, &out, "dynamodb", "scan", "--table-name", q.table, "--consistent-read"); err != nil {
+		return Policy{}, nil, fmt.Errorf("reading queue: %w", err)
+	}
+
+	policy := Policy{PerUserLimit: DefaultPerUserLimit}
+	var jobs []Job
+	for _, item := range out.Items {
+		if item["id"].S == policyID {
+			policy.VCPULimit = int(item["vcpu_limit"].Number())
+			policy.PerUserLimit = int(item["per_user_limit"].Number())
+			continue
+		}
+		jobs = append(jobs, unmarshalJob(item))
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].SubmittedAt.Before(jobs[j].SubmittedAt)
+	})
+	return policy, jobs, nil
+}
+
+// Claim marks a queued job running and adds its vCPUs to the queue's running total, in one
+// transaction. The total is held on the policy item, so the claim fails, rather than exceed
+// the vCPU limit, when another claim took the capacity since the caller planned with policy.
+// It also fails if someone else changed the job or the policy first.
+func (q *Queue) Claim(ctx context.Context, job Job, policy Policy) error {
+	vcpus := awscli.NumberValue(float64(job.VCPUs))
+	claim := q.transitionUpdate(job.ID, StatusRunning, "started_at", StatusQueued)
+	claim.UpdateExpression += ", claimed_vcpus = :vcpus"
+	claim.ExpressionAttributeValues[":vcpus"] = vcpus
+
+	reserve := update{
+		TableName:           q.table,
+		Key:                 itemKey(policyID),
+		UpdateExpression:    "ADD running_vcpus :vcpus",
+		ConditionExpression: "(attribute_not_exists(vcpu_limit) OR vcpu_limit = :limit)",
+		ExpressionAttributeValues: map[string]awscli.AttributeValue{
+			":vcpus": vcpus,
+			":limit": awscli.NumberValue(float64(policy.VCPULimit)),
+		},
+	}
+	if policy.VCPULimit > 0 {
+		// Condition expressions can't add, so the total must leave room for the job
+		reserve.ConditionExpression += " AND (attribute_not_exists(running_vcpus) OR running_vcpus <= :room)"
+		reserve.ExpressionAttributeValues[":room"] = awscli.NumberValue(float64(policy.VCPULimit - job.VCPUs))
+	}
+	if err := q.write(ctx, claim, reserve); err != nil {
+		return fmt.Errorf("claiming %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// Finish records how a running job ended, releasing its capacity
+func (q *Queue) Finish(ctx context.Context, id, status string) error {
+	return q.stop(ctx, id, status, StatusRunning)
+}
+
+// Cancel removes a job from scheduling. Cancelling a running job releases its capacity
+// in the queue but does not stop the run itself.
+func (q *Queue) Cancel(ctx context.Context, id string) error {
+	return q.stop(ctx, id, StatusCancelled, StatusQueued, StatusRunning)
+}
+
+// RequestPreemption records that a running scavenger job was asked to give up its capacity,
+// so other waiting jobs don't ask again
+func (q *Queue) RequestPreemption(ctx context.Context, id string) error {
+	return q.transition(ctx, id, StatusRunning, "preempt_requested_at", StatusRunning)
+}
+
+// stop moves a job whose status is one of from to status. A running job returns the vCPUs it
+// claimed to the queue's running total in the same transaction.
+func (q *Queue) stop(ctx context.Context, id, status string, from ...string) error {
+	job, err := q.job(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Status != StatusRunning || job.ClaimedVCPUs == 0 {
+		return q.transition(ctx, id, status, "finished_at", from...)
+	}
+
+	release := update{
+		TableName:        q.table,
+		Key:              itemKey(policyID),
+		UpdateExpression: "ADD running_vcpus :vcpus",
+		ExpressionAttributeValues: map[string]awscli.AttributeValue{
+			":vcpus": awscli.NumberValue(float64(-job.ClaimedVCPUs)),
+		},
+	}
+	if err := q.write(ctx, q.transitionUpdate(id, status, "finished_at", StatusRunning), release); err != nil {
+		return fmt.Errorf("marking %s %s: %w", id, status, err)
+	}
+	return nil
+}
+
+// job reads one job
+func (q *Queue) job(ctx context.Context, id string) (Job, error) {
+	key, err := json.Marshal(itemKey(id))
+	if err != nil {
+		return Job{}, err
+	}
+	var out struct {
+		Item map[string]awscli.AttributeValue `json:"Item"`
+	}
+	if err := q.cli.Run(ctx, &out, "dynamodb", "get-item", "--table-name", q.table, "--key", string(key), "--consistent-read"); err != nil {
+		return Job{}, fmt.Errorf("reading job %s: %w", id, err)
+	}
+	if out.Item == nil {
+		return Job{}, fmt.Errorf("job %s not found", id)
+	}
+	return unmarshalJob(out.Item), nil
+}
+
+// WaitForTurn polls the queue until the job may start under the fair-share plan, then claims it.
+// Position changes are printed so the submitter can see progress. When the job is next in
+// line but running scavengers hold the capacity it needs, preempt asks them to stop; nil
+// waits for them to finish instead.
+func (q *Queue) WaitForTurn(ctx context.Context, id string, pollInterval time.Duration, preempt Preempter) error {
+	lastPosition := 0
+	for {
+		policy, jobs, err := q.State(ctx)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		slot, found := findSlot(Plan(jobs, policy, now), id)
+		if !found {
+			return fmt.Errorf("job %s is no longer queued", id)
+		}
+		if slot.StartsNow(now) {
+			if err := q.Claim(ctx, slot.Job, policy); err == nil {
+				return nil
+			} else if !strings.Contains(err.Error(), "ConditionalCheckFailed") {
+				return err
+			}
+			// Cancelled, or another claim took the capacity, while we were deciding; the
+			// next poll reports it
+		} else if slot.Position == 1 && preempt != nil {
+			for _, victim := range Preemptible(jobs, policy, slot.Job) {
+				fmt.Printf("🪂 Preempting scavenger %s (%d vCPUs, %s)\n", victim.ID, victim.VCPUs, victim.User)
+				if err := preempt(ctx, victim); err != nil {
+					fmt.Printf("Warning: could not preempt %s: %v\n", victim.ID, err)
+					continue
+				}
+				if err := q.RequestPreemption(ctx, victim.ID); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				}
+			}
+		}
+		if !slot.StartsNow(now) && slot.Position != lastPosition {
+			lastPosition = slot.Position
+			if slot.EstimatedStart.IsZero() {
+				fmt.Printf("⏳ Queued at position %d; the job needs more vCPUs than the queue allows (%d)\n", slot.Position, policy.VCPULimit)
+			} else {
+				fmt.Printf("⏳ Queued at position %d, estimated start %s\n", slot.Position, slot.EstimatedStart.Local().Format("Jan 2 15:04"))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func findSlot(slots []Slot, id string) (Slot, bool) {
+	for _, slot := range slots {
+		if slot.Job.ID == id {
+			return slot, true
+		}
+	}
+	return Slot{}, false
+}
+
+// transition moves a job to status, stamping timeField, if its current status is one of from
+func (q *Queue) transition(ctx context.Context, id, status, timeField string, from ...string) error {
+	if err := q.write(ctx, q.transitionUpdate(id, status, timeField, from...)); err != nil {
+		return fmt.Errorf("marking %s %s: %w", id, status, err)
+	}
+	return nil
+}
+
+// transitionUpdate is the update transition makes
+func (q *Queue) transitionUpdate(id, status, timeField string, from ...string) update {
+	values := map[string]awscli.AttributeValue{
+		":status": {S: status},
+		":now":    awscli.TimeValue(time.Now()),
+	}
+	var conditions []string
+	for i, s := range from {
+		name := fmt.Sprintf(":from%d", i)
+		values[name] = awscli.StringValue(s)
+		conditions = append(conditions, "#status = "+name)
+	}
+	return update{
+		TableName:                 q.table,
+		Key:                       itemKey(id),
+		UpdateExpression:          fmt.Sprintf("SET #status = :status, %s = :now", timeField),
+		ConditionExpression:       strings.Join(conditions, " OR "),
+		ExpressionAttributeNames:  map[string]string{"#status": "status"},
+		ExpressionAttributeValues: values,
+	}
+}
+
+// update is an UpdateItem request, in the JSON form update-item and transact-write-items take
+type update struct {
+	TableName                 string
+	Key                       map[string]awscli.AttributeValue
+	UpdateExpression          string
+	ConditionExpression       string                           `json:",omitempty"`
+	ExpressionAttributeNames  map[string]string                `json:",omitempty"`
+	ExpressionAttributeValues map[string]awscli.AttributeValue `json:",omitempty"`
+}
+
+// write applies updates, together in one transaction when there are several, so that any
+// failed condition leaves every item as it was
+func (q *Queue) write(ctx context.Context, updates ...update) error {
+	if len(updates) == 1 {
+		input, err := json.Marshal(updates[0])
+		if err != nil {
+			return err
+		}
+		return q.cli.Run(ctx, nil, "dynamodb", "update-item", "--cli-input-json", string(input))
+	}
+	items := make([]map[string]update, len(updates))
+	for i, u := range updates {
+		items[i] = map[string]update{"Update": u}
+	}
+	input, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return q.cli.Run(ctx, nil, "dynamodb", "transact-write-items", "--transact-items", string(input))
+}
+
+// itemKey is the key of the item with id
+func itemKey(id string) map[string]awscli.AttributeValue {
+	return map[string]awscli.AttributeValue{"id": {S: id}}
+}
+
+func (q *Queue) putItem(ctx context.Context, item map[string]awscli.AttributeValue, condition string) error {
+	itemJSON, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	args := []string{"dynamodb", "put-item", "--table-name", q.table, "--item", string(itemJSON)}
+	if condition != "" {
+		args = append(args, "--condition-expression", condition)
+	}
+	return q.cli.Run(ctx, nil, args...)
+}
+
+func marshalJob(job Job) map[string]awscli.AttributeValue {
+	item := map[string]awscli.AttributeValue{
+		"id":              {S: job.ID},
+		"user":            {S: job.User},
+		"status":          {S: job.Status},
+		"vcpus":           awscli.NumberValue(float64(job.VCPUs)),
+		"estimated_hours": awscli.NumberValue(job.EstimatedHours),
+		"submitted_at":    awscli.TimeValue(job.SubmittedAt),
+	}
+	// An empty string would encode as an attribute without a type, so optional fields are omitted
+	if job.Description != "" {
+		item["description"] = awscli.StringValue(job.Description)
+	}
+	if job.InstanceType != "" {
+		item["instance_type"] = awscli.StringValue(job.InstanceType)
+	}
+	if job.Priority != "" {
+		item["priority"] = awscli.StringValue(job.Priority)
+	}
+	return item
+}
+
+func unmarshalJob(item map[string]awscli.AttributeValue) Job {
+	return Job{
+		ID:             item["id"].S,
+		User:           item["user"].S,
+		Description:    item["description"].S,
+		InstanceType:   item["instance_type"].S,
+		VCPUs:          int(item["vcpus"].Number()),
+		EstimatedHours: item["estimated_hours"].Number(),
+		Status:         item["status"].S,
+		SubmittedAt:    item["submitted_at"].Time(),
+		StartedAt:      item["started_at"].Time(),
+		FinishedAt:     item["finished_at"].Time(),
+		Priority:       item["priority"].S,
+		ClaimedVCPUs:   int(item["claimed_vcpus"].Number()),
+
+		PreemptRequestedAt: item["preempt_requested_at"].Time(),
+	}
+}
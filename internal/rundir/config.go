@@ -0,0 +1,89 @@
+package rundir
+
+import (
+	"fmt"
+	"strings"
+)
+
+// geoschemConfig renders geoschem_config.yml
+func geoschemConfig(spec Spec, species []string) string {
+	sim := simulations[spec.Simulation]
+	g := grids[spec.Resolution]
+	onOff := func(on bool) string {
+		if on {
+			return "true"
+		}
+		return "false"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\n")
+	fmt.Fprintf(&b, "# GEOS-Chem configuration for a %s %s run, generated by geoschem-aws\n\n", spec.Simulation, spec.Resolution)
+
+	fmt.Fprintf(&b, "simulation:\n")
+	fmt.Fprintf(&b, "  name: %s\n", spec.Simulation)
+	fmt.Fprintf(&b, "  start_date: [%s, %s]\n", spec.StartDate.Format("20060102"), spec.StartDate.Format("150405"))
+	fmt.Fprintf(&b, "  end_date: [%s, %s]\n", spec.EndDate.Format("20060102"), spec.EndDate.Format("150405"))
+	fmt.Fprintf(&b, "  root_data_dir: %s\n", spec.DataDir)
+	fmt.Fprintf(&b, "  met_field: %s\n", spec.MetField)
+	fmt.Fprintf(&b, "  species_database_file: ./species_database.yml\n")
+	fmt.Fprintf(&b, "  species_metadata_output_file: OutputDir/geoschem_species_metadata.yml\n")
+	fmt.Fprintf(&b, "  verbose:\n")
+	fmt.Fprintf(&b, "    activate: false\n")
+	fmt.Fprintf(&b, "    on_cores: root\n")
+	fmt.Fprintf(&b, "  use_gcclassic_timers: false\n\n")
+
+	fmt.Fprintf(&b, "grid:\n")
+	fmt.Fprintf(&b, "  resolution: %s\n", g.name)
+	fmt.Fprintf(&b, "  number_of_levels: %d\n", spec.Levels)
+	fmt.Fprintf(&b, "  longitude:\n")
+	fmt.Fprintf(&b, "    range: [-180.0, 180.0]\n")
+	fmt.Fprintf(&b, "    center_at_180: true\n")
+	fmt.Fprintf(&b, "  latitude:\n")
+	fmt.Fprintf(&b, "    range: [-90.0, 90.0]\n")
+	fmt.Fprintf(&b, "    half_size_polar_boxes: %s\n", onOff(g.polarBoxes))
+	fmt.Fprintf(&b, "  nested_grid_simulation:\n")
+	fmt.Fprintf(&b, "    activate: false\n")
+	fmt.Fprintf(&b, "    buffer_zone_NSEW: [0, 0, 0, 0]\n\n")
+
+	fmt.Fprintf(&b, "timesteps:\n")
+	fmt.Fprintf(&b, "  transport_timestep_in_s: %d\n", g.transport)
+	fmt.Fprintf(&b, "  chemistry_timestep_in_s: %d\n", g.chemistry)
+	fmt.Fprintf(&b, "  radiation_timestep_in_s: 10800\n\n")
+
+	fmt.Fprintf(&b, "operations:\n")
+	fmt.Fprintf(&b, "  chemistry:\n")
+	fmt.Fprintf(&b, "    activate: %s\n", onOff(sim.chemistry))
+	if sim.linearChem {
+		fmt.Fprintf(&b, "    linear_chemistry_aloft:\n")
+		fmt.Fprintf(&b, "      activate: true\n")
+		fmt.Fprintf(&b, "      use_linoz_for_O3: false\n")
+	}
+	fmt.Fprintf(&b, "  convection:\n")
+	fmt.Fprintf(&b, "    activate: true\n")
+	fmt.Fprintf(&b, "  dry_deposition:\n")
+	fmt.Fprintf(&b, "    activate: %s\n", onOff(sim.dryDep))
+	fmt.Fprintf(&b, "    CO2_effect:\n")
+	fmt.Fprintf(&b, "      activate: false\n")
+	fmt.Fprintf(&b, "  pbl_mixing:\n")
+	fmt.Fprintf(&b, "    activate: true\n")
+	fmt.Fprintf(&b, "    use_non_local_pbl: true\n")
+	fmt.Fprintf(&b, "  transport:\n")
+	fmt.Fprintf(&b, "    gcclassic_tpcore:\n")
+	fmt.Fprintf(&b, "      activate: true\n")
+	fmt.Fprintf(&b, "      fill_negative_values: true\n")
+	fmt.Fprintf(&b, "      iord_jord_kord: [3, 3, 7]\n")
+	fmt.Fprintf(&b, "    transported_species:\n")
+	for _, name := range species {
+		fmt.Fprintf(&b, "      - %s\n", name)
+	}
+	fmt.Fprintf(&b, "  wet_deposition:\n")
+	fmt.Fprintf(&b, "    activate: %s\n\n", onOff(sim.wetDep))
+
+	fmt.Fprintf(&b, "extra_diagnostics:\n")
+	fmt.Fprintf(&b, "  obspack:\n")
+	fmt.Fprintf(&b, "    activate: false\n")
+	fmt.Fprintf(&b, "  planeflight:\n")
+	fmt.Fprintf(&b, "    activate: false\n")
+	return b.String()
+}
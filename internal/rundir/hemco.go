@@ -0,0 +1,227 @@
+package rundir
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/data"
+)
+
+// Emission is a base emissions container HEMCO reads
+type Emission struct {
+	Name      string `yaml:"name"`      // Container name, e.g. RN_EMIS
+	File      string `yaml:"file"`      // Under the HEMCO data directory; HEMCO tokens such as $YYYY allowed
+	Variable  string `yaml:"variable"`  // Variable in File
+	Time      string `yaml:"time"`      // HEMCO source time, e.g. 2000-2020/1-12/1/0 (default: constant)
+	Cycle     string `yaml:"cycle"`     // C, R, E, EF, ... (default: C)
+	Unit      string `yaml:"unit"`      // Default: kg/m2/s
+	Species   string `yaml:"species"`   // Species it emits
+	Category  int    `yaml:"category"`  // Default: 1
+	Hierarchy int    `yaml:"hierarchy"` // Default: 1
+}
+
+// LoadEmissions reads a YAML list of base emissions
+func LoadEmissions(filename string) ([]Emission, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading emissions: %w", err)
+	}
+
+	var emissions []Emission
+	if err := yaml.Unmarshal(content, &emissions); err != nil {
+		return nil, fmt.Errorf("parsing emissions: %w", err)
+	}
+	for i, emission := range emissions {
+		if err := emission.validate(); err != nil {
+			return nil, fmt.Errorf("invalid emissions %s: emission %d: %w", filename, i+1, err)
+		}
+	}
+	return emissions, nil
+}
+
+func (e Emission) validate() error {
+	for field, value := range map[string]string{"name": e.Name, "file": e.File, "variable": e.Variable, "species": e.Species} {
+		if value == "" {
+			return fmt.Errorf("%s is required", field)
+		}
+	}
+	for _, value := range []string{e.Name, e.File, e.Variable, e.Time, e.Cycle, e.Unit, e.Species} {
+		if strings.ContainsAny(value, " \t\n") {
+			return fmt.Errorf("'%s' can't contain spaces", value)
+		}
+	}
+	if path.IsAbs(e.File) || strings.HasPrefix(path.Clean(e.File), "..") {
+		return fmt.Errorf("file %s must be relative to the HEMCO data directory", e.File)
+	}
+	return nil
+}
+
+// line renders the container as a BASE EMISSIONS line:
+// ExtNr Name sourceFile sourceVar sourceTime CRE SrcDim SrcUnit Species ScalIDs Cat Hier
+func (e Emission) line() string {
+	value := func(v, fallback string) string {
+		if v == "" {
+			return fallback
+		}
+		return v
+	}
+	category, hierarchy := max(e.Category, 1), max(e.Hierarchy, 1)
+	return fmt.Sprintf("0 %s $ROOT/%s %s %s %s xy %s %s - %d %d", e.Name, e.File, e.Variable,
+		value(e.Time, "*/1/1/0"), value(e.Cycle, "C"), value(e.Unit, "kg/m2/s"), e.Species, category, hierarchy)
+}
+
+// metField is a meteorology field HEMCO reads for GeosChem
+type metField struct {
+	name     string // Container name GeosChem looks for
+	variable string // Variable in the file
+	offset   string // Added to the file time, for fields at the end of the step
+	dim      string
+}
+
+// metCollections lists the fields GeosChem Classic reads from each met collection
+var metCollections = []struct {
+	name   string
+	offset string // Averaged collections are stamped at the middle of the interval
+	fields []metField
+}{
+	{name: "A1", offset: "+30minute", fields: fields2D("ALBEDO", "CLDTOT", "EFLUX", "EVAP", "FRSEAICE", "FRSNO",
+		"GRN", "GWETROOT", "GWETTOP", "HFLUX", "LAI", "LWI", "PARDF", "PARDR", "PBLH", "PRECANV", "PRECCON",
+		"PRECLSC", "PRECSNO", "PRECTOT", "QV2M", "SLP", "SNODP", "SNOMAS", "SWGDN", "TO3", "TROPPT", "TS",
+		"T2M", "U10M", "USTAR", "V10M", "Z0M")},
+	{name: "A3cld", offset: "+90minute", fields: fields3D("CLOUD", "OPTDEPTH", "QI", "QL", "TAUCLI", "TAUCLW")},
+	{name: "A3dyn", offset: "+90minute", fields: fields3D("DTRAIN", "OMEGA", "RH", "U", "V")},
+	{name: "A3mstC", offset: "+90minute", fields: fields3D("DQRCU", "DQRLSAN", "REEVAPCN", "REEVAPLS")},
+	{name: "A3mstE", offset: "+90minute", fields: fields3D("CMFMC", "PFICU", "PFILSAN", "PFLCU", "PFLLSAN")},
+	{name: "I3", fields: []metField{
+		{name: "PS1_GMAO", variable: "PS", dim: "xy"},
+		{name: "PS2_GMAO", variable: "PS", offset: "+3hour", dim: "xy"},
+		{name: "SPHU1", variable: "QV", dim: "xyz"},
+		{name: "SPHU2", variable: "QV", offset: "+3hour", dim: "xyz"},
+		{name: "TMPU1", variable: "T", dim: "xyz"},
+		{name: "TMPU2", variable: "T", offset: "+3hour", dim: "xyz"},
+	}},
+}
+
+// constantMetFields are read once from the CN collection
+var constantMetFields = []string{"FRLAKE", "FRLAND", "FRLANDIC", "FROCEAN", "PHIS"}
+
+func fields2D(names ...string) []metField {
+	fields := make([]metField, len(names))
+	for i, name := range names {
+		fields[i] = metField{name: name, variable: name, dim: "xy"}
+	}
+	return fields
+}
+
+func fields3D(names ...string) []metField {
+	fields := make([]metField, len(names))
+	for i, name := range names {
+		fields[i] = metField{name: name, variable: name, dim: "xyz"}
+	}
+	return fields
+}
+
+// hemcoConfig renders HEMCO_Config.rc. Met fields name their files outright, in the layout
+// data stage copies, and exist from the met product's first year to the run's last.
+func hemcoConfig(spec Spec) (string, error) {
+	met, err := data.MetFilesFor(spec.Resolution, spec.MetField)
+	if err != nil {
+		return "", err
+	}
+	banner := func(b *strings.Builder, title string) {
+		fmt.Fprintf(b, "%s\n### BEGIN SECTION %s\n%s\n", strings.Repeat("#", 79), title, strings.Repeat("#", 79))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HEMCO_Config.rc for a %s %s run, generated by geoschem-aws\n", spec.Simulation, spec.Resolution)
+	banner(&b, "SETTINGS")
+	settings := [][2]string{
+		{"ROOT", spec.DataDir + "/HEMCO"},
+		{"METDIR", spec.DataDir + "/" + met.Dir},
+		{"Logfile", "*"},
+		{"DiagnFile", "HEMCO_Diagn.rc"},
+		{"DiagnPrefix", "./OutputDir/HEMCO_diagnostics"},
+		{"DiagnFreq", "End"},
+		{"Wildcard", "*"},
+		{"Separator", "/"},
+		{"Unit tolerance", "1"},
+		{"Negative values", "0"},
+		{"Only unitless scale factors", "false"},
+		{"Verbose", "false"},
+		{"VerboseOnCores", "root"},
+	}
+	for _, setting := range settings {
+		fmt.Fprintf(&b, "%-29s%s\n", setting[0]+":", setting[1])
+	}
+	fmt.Fprintf(&b, "\n### END SECTION SETTINGS ###\n\n")
+
+	banner(&b, "EXTENSION SWITCHES")
+	fmt.Fprintf(&b, "# ExtNr ExtName                on/off  Species\n")
+	fmt.Fprintf(&b, "0       Base                   : on    *\n")
+	fmt.Fprintf(&b, "    --> EMISSIONS              :       %t\n", len(spec.Emissions) > 0)
+	fmt.Fprintf(&b, "    --> METEOROLOGY            :       true\n")
+	fmt.Fprintf(&b, "    --> GC_RESTART             :       true\n")
+	fmt.Fprintf(&b, "\n### END SECTION EXTENSION SWITCHES ###\n\n")
+
+	banner(&b, "BASE EMISSIONS")
+	fmt.Fprintf(&b, "# ExtNr Name sourceFile sourceVar sourceTime C/R/E SrcDim SrcUnit Species ScalIDs Cat Hier\n")
+	fmt.Fprintf(&b, "(((EMISSIONS\n")
+	for _, emission := range spec.Emissions {
+		fmt.Fprintf(&b, "%s\n", emission.line())
+	}
+	fmt.Fprintf(&b, ")))EMISSIONS\n\n")
+
+	fmt.Fprintf(&b, "(((METEOROLOGY\n")
+	for _, name := range constantMetFields {
+		fmt.Fprintf(&b, "* %s $METDIR/%d/01/%s.%d0101.CN.%s.%s %s */1/1/0 C xy 1 * - 1 1\n",
+			name, data.ConstantYear, met.Prefix, data.ConstantYear, met.Tag, met.Extension, name)
+	}
+	years := fmt.Sprintf("%d-%d", met.FirstYear, spec.EndDate.Year())
+	for _, collection := range metCollections {
+		for _, field := range collection.fields {
+			offset := field.offset
+			if offset == "" {
+				offset = collection.offset
+			}
+			if offset != "" {
+				offset = "/" + offset
+			}
+			fmt.Fprintf(&b, "* %s $METDIR/$YYYY/$MM/%s.$YYYY$MM$DD.%s.%s.%s %s %s/1-12/1-31/0-23%s EFY %s 1 * - 1 1\n",
+				field.name, met.Prefix, collection.name, met.Tag, met.Extension, field.variable, years, offset, field.dim)
+		}
+	}
+	fmt.Fprintf(&b, ")))METEOROLOGY\n\n")
+
+	// Initial conditions, from the restart file the runners copy into the run directory;
+	// species missing from it start from background values
+	fmt.Fprintf(&b, "(((GC_RESTART\n")
+	for _, field := range restartFields {
+		name := strings.TrimPrefix(field, "Met_")
+		if field == "SpeciesRst_?ALL?" {
+			name, field = "SPC_", "SpeciesRst_?SPC?"
+		}
+		fmt.Fprintf(&b, "* %s ./GEOSChem.Restart.$YYYY$MM$DD_$HH$MNz.nc4 %s $YYYY/$MM/$DD/$HH EFYO xyz 1 * - 1 1\n", name, field)
+	}
+	fmt.Fprintf(&b, ")))GC_RESTART\n")
+	fmt.Fprintf(&b, "\n### END SECTION BASE EMISSIONS ###\n\n")
+
+	banner(&b, "SCALE FACTORS")
+	fmt.Fprintf(&b, "# ScalID Name sourceFile sourceVar sourceTime C/R/E SrcDim SrcUnit Oper\n")
+	fmt.Fprintf(&b, "\n### END SECTION SCALE FACTORS ###\n\n")
+
+	banner(&b, "MASKS")
+	fmt.Fprintf(&b, "# ScalID Name sourceFile sourceVar sourceTime C/R/E SrcDim SrcUnit Oper Lon1/Lat1/Lon2/Lat2\n")
+	fmt.Fprintf(&b, "\n### END SECTION MASKS ###\n\n")
+	fmt.Fprintf(&b, "### END OF HEMCO INPUT FILE ###\n")
+	return b.String(), nil
+}
+
+// hemcoDiagnostics is HEMCO_Diagn.rc. It lists no diagnostics; GeosChem's emissions
+// diagnostics are HISTORY.rc collections.
+const hemcoDiagnostics = `# HEMCO_Diagn.rc, generated by geoschem-aws
+# Name        Spec  ExtNr  Cat  Hier  Dim  OutUnit  LongName
+`
@@ -0,0 +1,141 @@
+package rundir
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Diagnostic is a HISTORY.rc collection written at a frequency
+type Diagnostic struct {
+	Collection string // One of the preset collections, e.g. SpeciesConc
+	Frequency  string // hourly, daily, monthly, or end
+}
+
+// Output frequencies, as HISTORY.rc spells them
+var frequencies = map[string]string{
+	"hourly":  "00000000 010000",
+	"daily":   "00000001 000000",
+	"monthly": "00000100 000000",
+	"end":     "End",
+}
+
+// collection is a preset HISTORY.rc collection
+type collection struct {
+	mode   string // time-averaged or instantaneous
+	fields []string
+}
+
+// collections are the diagnostics a run can ask for, with the fields upstream's templates give them
+var collections = map[string]collection{
+	"SpeciesConc": {mode: "time-averaged", fields: []string{"SpeciesConcVV_?ALL?"}},
+	"StateMet": {mode: "time-averaged", fields: []string{
+		"Met_AD", "Met_AIRDEN", "Met_AIRVOL", "Met_BXHEIGHT", "Met_CLDFRC", "Met_DELP", "Met_PBLH",
+		"Met_PMID", "Met_PRECTOT", "Met_PS1WET", "Met_SPHU", "Met_T", "Met_TropP", "Met_U", "Met_V"}},
+	"DryDep":      {mode: "time-averaged", fields: []string{"DryDep_?DRY?", "DryDepVel_?DRY?"}},
+	"WetLossConv": {mode: "time-averaged", fields: []string{"WetLossConv_?WET?", "WetLossConvFrac_?WET?"}},
+	"WetLossLS":   {mode: "time-averaged", fields: []string{"WetLossLS_?WET?"}},
+	"RadioNuclide": {mode: "time-averaged", fields: []string{
+		"PbFromRnDecay", "RadDecay_?ALL?", "EmisRn222", "EmisPb210", "EmisBe7", "EmisBe10"}},
+}
+
+// CollectionNames returns the preset diagnostics collections
+func CollectionNames() []string {
+	names := make([]string, 0, len(collections))
+	for name := range collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseDiagnostics reads diagnostics written Collection:frequency,...
+func ParseDiagnostics(spec string) ([]Diagnostic, error) {
+	var diagnostics []Diagnostic
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, frequency, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("diagnostic '%s' must be Collection:frequency", entry)
+		}
+		diagnostic := Diagnostic{Collection: name, Frequency: frequency}
+		if err := diagnostic.validate(); err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics, nil
+}
+
+func (d Diagnostic) validate() error {
+	if _, ok := collections[d.Collection]; !ok {
+		return fmt.Errorf("unknown diagnostics collection %s (available: %s)", d.Collection, strings.Join(CollectionNames(), ", "))
+	}
+	if _, ok := frequencies[d.Frequency]; !ok {
+		return fmt.Errorf("unknown frequency %s for %s (use hourly, daily, monthly or end)", d.Frequency, d.Collection)
+	}
+	return nil
+}
+
+// restartFields are written with the species in restart files, so a run resumes with the
+// same dry air and boundary layer as it stopped with
+var restartFields = []string{"SpeciesRst_?ALL?", "Met_DELPDRY", "Met_PS1WET", "Met_PS1DRY", "Met_SPHU1", "Met_TMPU1"}
+
+// history renders HISTORY.rc. Restart files are written to the run directory, where the
+// runners and pause/resume look for them.
+func history(spec Spec) string {
+	checkpoint := frequencies["end"]
+	if spec.Checkpoint != CheckpointEnd {
+		checkpoint = frequencies[spec.Checkpoint]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HISTORY.rc for a %s %s run, generated by geoschem-aws\n", spec.Simulation, spec.Resolution)
+	fmt.Fprintf(&b, "EXPID:  ./OutputDir/GEOSChem\n")
+	fmt.Fprintf(&b, "EXPDSC: GEOS-Chem_devel\n")
+	fmt.Fprintf(&b, "CoresPerNode: 6\n")
+	fmt.Fprintf(&b, "VERSION: 1\n\n")
+
+	fmt.Fprintf(&b, "COLLECTIONS: 'Restart',\n")
+	for _, diagnostic := range spec.Diagnostics {
+		fmt.Fprintf(&b, "             '%s',\n", diagnostic.Collection)
+	}
+	fmt.Fprintf(&b, "::\n")
+
+	fmt.Fprintf(&b, "  Restart.filename:           './GEOSChem.Restart.%%y4%%m2%%d2_%%h2%%n2z.nc4',\n")
+	fmt.Fprintf(&b, "  Restart.format:             'CFIO',\n")
+	fmt.Fprintf(&b, "  Restart.frequency:          '%s',\n", checkpoint)
+	fmt.Fprintf(&b, "  Restart.duration:           '%s',\n", checkpoint)
+	fmt.Fprintf(&b, "  Restart.mode:               'instantaneous'\n")
+	writeFields(&b, "Restart", restartFields)
+	fmt.Fprintf(&b, "::\n")
+
+	for _, diagnostic := range spec.Diagnostics {
+		c := collections[diagnostic.Collection]
+		frequency := frequencies[diagnostic.Frequency]
+		fmt.Fprintf(&b, "  %s.template:       '%%y4%%m2%%d2_%%h2%%n2z.nc4',\n", diagnostic.Collection)
+		fmt.Fprintf(&b, "  %s.format:         'CFIO',\n", diagnostic.Collection)
+		fmt.Fprintf(&b, "  %s.frequency:      '%s',\n", diagnostic.Collection, frequency)
+		fmt.Fprintf(&b, "  %s.duration:       '%s',\n", diagnostic.Collection, frequency)
+		fmt.Fprintf(&b, "  %s.mode:           '%s'\n", diagnostic.Collection, c.mode)
+		writeFields(&b, diagnostic.Collection, c.fields)
+		fmt.Fprintf(&b, "::\n")
+	}
+	return b.String()
+}
+
+// writeFields writes a collection's fields, each from the GeosChem state
+func writeFields(b *strings.Builder, name string, fields []string) {
+	label := fmt.Sprintf("  %s.fields:", name)
+	indent := strings.Repeat(" ", len(label))
+	for i, field := range fields {
+		prefix := indent
+		if i == 0 {
+			prefix = label
+		}
+		fmt.Fprintf(b, "%s '%-30s', 'GIGCchem',\n", prefix, field)
+	}
+}
@@ -0,0 +1,189 @@
+// Package rundir generates GeosChem Classic run directories from a description of the run
+// instead of the interactive createRunDir.sh in the image. It writes the GEOS-Chem 14.2+
+// layout: geoschem_config.yml, HEMCO_Config.rc, HEMCO_Diagn.rc, HISTORY.rc and
+// species_database.yml, read from the input data directory the run mounts.
+package rundir
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/data"
+)
+
+// DefaultDataDir is where the image's runners mount the input data
+const DefaultDataDir = "/workspace/data"
+
+// DefaultLevels is the number of vertical levels GeosChem Classic runs on
+const DefaultLevels = 72
+
+// Checkpoint frequencies, matching the runners' --checkpoint-frequency
+const (
+	CheckpointEnd     = ""
+	CheckpointDaily   = "daily"
+	CheckpointMonthly = "monthly"
+)
+
+// Spec describes the run directory to generate
+type Spec struct {
+	Simulation      string // fullchem, aerosol, TransportTracers, CH4, CO2, Hg
+	Resolution      string // 4x5, 2x2.5, 0.5x0.625, 0.25x0.3125
+	MetField        string // MERRA2, GEOSFP, GEOSIT
+	StartDate       time.Time
+	EndDate         time.Time
+	Levels          int          // Vertical levels (default: DefaultLevels)
+	Diagnostics     []Diagnostic // HISTORY.rc collections besides the restart
+	Checkpoint      string       // How often restart files are written: daily, monthly, or only at the end
+	DataDir         string       // Input data directory on the run host (default: DefaultDataDir)
+	Species         []string     // Species to carry (default: the simulation's)
+	SpeciesDatabase string       // Upstream species_database.yml to take the species from, for simulations without built-in species
+	Emissions       []Emission   // Base emissions HEMCO reads
+}
+
+// RunDir is a generated run directory, its files by name
+type RunDir struct {
+	Files map[string]string
+}
+
+// WithDefaults fills in the defaults for unset fields
+func (s Spec) WithDefaults() Spec {
+	if s.Levels == 0 {
+		s.Levels = DefaultLevels
+	}
+	if s.DataDir == "" {
+		s.DataDir = DefaultDataDir
+	}
+	s.DataDir = strings.TrimSuffix(s.DataDir, "/")
+	return s
+}
+
+// Validate checks the spec describes a run GeosChem Classic can make
+func (s Spec) Validate() error {
+	sim, ok := simulations[s.Simulation]
+	if !ok {
+		return fmt.Errorf("unknown simulation %s (available: %s)", s.Simulation, strings.Join(SimulationNames(), ", "))
+	}
+	if _, ok := grids[s.Resolution]; !ok {
+		return fmt.Errorf("unknown resolution %s (available: %s)", s.Resolution, strings.Join(resolutionNames(), ", "))
+	}
+	if _, err := data.MetFilesFor(s.Resolution, s.MetField); err != nil {
+		return err
+	}
+	if s.StartDate.IsZero() || s.EndDate.IsZero() {
+		return fmt.Errorf("start and end dates are required")
+	}
+	if !s.EndDate.After(s.StartDate) {
+		return fmt.Errorf("end date %s must be after start date %s", s.EndDate.Format("2006-01-02"), s.StartDate.Format("2006-01-02"))
+	}
+	if s.Levels != 0 && s.Levels != 47 && s.Levels != 72 {
+		return fmt.Errorf("levels must be 72 or 47, got %d", s.Levels)
+	}
+	switch s.Checkpoint {
+	case CheckpointEnd, CheckpointDaily, CheckpointMonthly:
+	default:
+		return fmt.Errorf("unknown checkpoint frequency %s (use daily or monthly)", s.Checkpoint)
+	}
+	seen := make(map[string]bool)
+	for _, diagnostic := range s.Diagnostics {
+		if err := diagnostic.validate(); err != nil {
+			return err
+		}
+		if seen[diagnostic.Collection] {
+			return fmt.Errorf("diagnostics collection %s is listed twice", diagnostic.Collection)
+		}
+		seen[diagnostic.Collection] = true
+	}
+	if len(sim.species) == 0 && (len(s.Species) == 0 || s.SpeciesDatabase == "") {
+		return fmt.Errorf("the %s simulation needs its species and the upstream species_database.yml to take them from", s.Simulation)
+	}
+	if len(s.Species) > 0 && len(sim.species) > 0 && s.SpeciesDatabase == "" {
+		for _, name := range s.Species {
+			if _, ok := sim.species[name]; !ok {
+				return fmt.Errorf("species %s isn't in the %s simulation; pass the species database to add it", name, s.Simulation)
+			}
+		}
+	}
+	for i, emission := range s.Emissions {
+		if err := emission.validate(); err != nil {
+			return fmt.Errorf("emission %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Generate renders the run directory's configuration files
+func Generate(spec Spec) (*RunDir, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	spec = spec.WithDefaults()
+
+	species, err := spec.species()
+	if err != nil {
+		return nil, err
+	}
+	database, err := speciesDatabase(spec, species)
+	if err != nil {
+		return nil, err
+	}
+	hemcoConfig, err := hemcoConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RunDir{Files: map[string]string{
+		"geoschem_config.yml":  geoschemConfig(spec, species),
+		"HISTORY.rc":           history(spec),
+		"HEMCO_Config.rc":      hemcoConfig,
+		"HEMCO_Diagn.rc":       hemcoDiagnostics,
+		"species_database.yml": database,
+	}}, nil
+}
+
+// Write writes the files into dir, creating it and the OutputDir GeosChem writes
+// diagnostics to
+func (r *RunDir) Write(dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, "OutputDir"), 0755); err != nil {
+		return fmt.Errorf("creating run directory: %w", err)
+	}
+	for _, name := range r.Names() {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(r.Files[name]), 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Names returns the file names in order
+func (r *RunDir) Names() []string {
+	names := make([]string, 0, len(r.Files))
+	for name := range r.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// species returns the species the run carries, in the order given
+func (s Spec) species() ([]string, error) {
+	if len(s.Species) == 0 {
+		return simulations[s.Simulation].speciesNames(), nil
+	}
+	var species []string
+	for _, name := range s.Species {
+		name = strings.TrimSpace(name)
+		if name == "" || slices.Contains(species, name) {
+			continue
+		}
+		species = append(species, name)
+	}
+	if len(species) == 0 {
+		return nil, fmt.Errorf("no species given")
+	}
+	return species, nil
+}
@@ -0,0 +1,113 @@
+package rundir
+
+import (
+	"sort"
+)
+
+// simulation describes what a simulation type turns on and the species it carries
+type simulation struct {
+	chemistry    bool
+	dryDep       bool
+	wetDep       bool
+	linearChem   bool // Chemistry is a prescribed loss rather than a KPP mechanism
+	species      map[string]speciesInfo
+	speciesOrder []string // Order species are listed in, as upstream lists them
+}
+
+// speciesInfo holds the species database properties written for built-in species. A species
+// database given in the spec takes precedence.
+type speciesInfo struct {
+	fullName string
+	formula  string
+	mwG      float64
+	aerosol  bool // Radionuclide aerosols deposit wet and dry
+	tracer   bool // Passive tracers with a prescribed lifetime
+}
+
+// simulations lists the simulation types. fullchem, aerosol and Hg carry hundreds of
+// species with mechanism-specific properties, so they take theirs from a species database.
+var simulations = map[string]simulation{
+	"TransportTracers": {
+		chemistry: true,
+		dryDep:    true,
+		wetDep:    true,
+		species: map[string]speciesInfo{
+			"Rn222":         {fullName: "Radon-222 isotope", formula: "Rn", mwG: 222.0},
+			"Pb210":         {fullName: "Lead-210 isotope", formula: "Pb", mwG: 210.0, aerosol: true},
+			"Pb210s":        {fullName: "Lead-210 isotope produced in the stratosphere", formula: "Pb", mwG: 210.0, aerosol: true},
+			"Be7":           {fullName: "Beryllium-7 isotope", formula: "Be", mwG: 7.0, aerosol: true},
+			"Be7s":          {fullName: "Beryllium-7 isotope produced in the stratosphere", formula: "Be", mwG: 7.0, aerosol: true},
+			"Be10":          {fullName: "Beryllium-10 isotope", formula: "Be", mwG: 10.0, aerosol: true},
+			"Be10s":         {fullName: "Beryllium-10 isotope produced in the stratosphere", formula: "Be", mwG: 10.0, aerosol: true},
+			"aoa":           {fullName: "Age of air uniform source tracer", mwG: 1.0, tracer: true},
+			"aoa_bl":        {fullName: "Age of air boundary layer source tracer", mwG: 1.0, tracer: true},
+			"aoa_nh":        {fullName: "Age of air northern hemisphere source tracer", mwG: 1.0, tracer: true},
+			"CH3I":          {fullName: "Methyl iodide", formula: "CH3I", mwG: 141.94, tracer: true},
+			"CO_25":         {fullName: "Anthropogenic CO-like tracer with a 25 day lifetime", formula: "CO", mwG: 28.01, tracer: true},
+			"CO_50":         {fullName: "Anthropogenic CO-like tracer with a 50 day lifetime", formula: "CO", mwG: 28.01, tracer: true},
+			"e90":           {fullName: "Constant burden tracer with a 90 day lifetime", mwG: 28.97, tracer: true},
+			"e90_n":         {fullName: "Constant burden tracer with a 90 day lifetime, northern source", mwG: 28.97, tracer: true},
+			"e90_s":         {fullName: "Constant burden tracer with a 90 day lifetime, southern source", mwG: 28.97, tracer: true},
+			"nh_5":          {fullName: "Northern hemisphere tracer with a 5 day lifetime", mwG: 28.97, tracer: true},
+			"nh_50":         {fullName: "Northern hemisphere tracer with a 50 day lifetime", mwG: 28.97, tracer: true},
+			"PassiveTracer": {fullName: "Passive tracer", mwG: 1.0, tracer: true},
+			"SF6":           {fullName: "Sulfur hexafluoride", formula: "SF6", mwG: 146.06, tracer: true},
+			"st80_25":       {fullName: "Stratospheric source tracer with a 25 day lifetime", mwG: 28.97, tracer: true},
+			"stOX":          {fullName: "Stratospheric ozone tracer", formula: "O3", mwG: 48.0, tracer: true},
+		},
+		speciesOrder: []string{"Rn222", "Pb210", "Pb210s", "Be7", "Be7s", "Be10", "Be10s", "aoa", "aoa_bl", "aoa_nh",
+			"CH3I", "CO_25", "CO_50", "e90", "e90_n", "e90_s", "nh_5", "nh_50", "PassiveTracer", "SF6", "st80_25", "stOX"},
+	},
+	"CH4": {
+		chemistry:  true,
+		linearChem: true,
+		species: map[string]speciesInfo{
+			"CH4": {fullName: "Methane", formula: "CH4", mwG: 16.04},
+		},
+		speciesOrder: []string{"CH4"},
+	},
+	"CO2": {
+		chemistry:  true,
+		linearChem: true,
+		species: map[string]speciesInfo{
+			"CO2": {fullName: "Carbon dioxide", formula: "CO2", mwG: 44.01},
+		},
+		speciesOrder: []string{"CO2"},
+	},
+	"fullchem": {chemistry: true, dryDep: true, wetDep: true},
+	"aerosol":  {chemistry: true, dryDep: true, wetDep: true},
+	"Hg":       {chemistry: true, dryDep: true, wetDep: true},
+}
+
+// SimulationNames returns the simulation types that can be generated
+func SimulationNames() []string {
+	names := make([]string, 0, len(simulations))
+	for name := range simulations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s simulation) speciesNames() []string {
+	return append([]string(nil), s.speciesOrder...)
+}
+
+// grid describes a GeosChem Classic global grid
+type grid struct {
+	name       string // As geoschem_config.yml spells it
+	transport  int    // Transport and convection time step, seconds
+	chemistry  int    // Chemistry and emissions time step, seconds
+	polarBoxes bool   // Half-size boxes at the poles
+}
+
+var grids = map[string]grid{
+	"4x5":         {name: "4.0x5.0", transport: 600, chemistry: 1200, polarBoxes: true},
+	"2x2.5":       {name: "2.0x2.5", transport: 600, chemistry: 1200, polarBoxes: true},
+	"0.5x0.625":   {name: "0.5x0.625", transport: 300, chemistry: 600},
+	"0.25x0.3125": {name: "0.25x0.3125", transport: 300, chemistry: 600},
+}
+
+func resolutionNames() []string {
+	return []string{"4x5", "2x2.5", "0.5x0.625", "0.25x0.3125"}
+}
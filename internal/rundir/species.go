@@ -0,0 +1,82 @@
+package rundir
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// speciesDatabase renders species_database.yml for the run's species. With a database in
+// the spec, their entries are copied from it with anchors and merges resolved, so the file
+// stands alone; otherwise they come from the simulation's built-in properties, which cover
+// transport and deposition but not the tracers' sources and lifetimes.
+func speciesDatabase(spec Spec, species []string) (string, error) {
+	entries := make(map[string]interface{})
+	if spec.SpeciesDatabase != "" {
+		content, err := os.ReadFile(spec.SpeciesDatabase)
+		if err != nil {
+			return "", fmt.Errorf("reading species database: %w", err)
+		}
+		if err := yaml.Unmarshal(content, &entries); err != nil {
+			return "", fmt.Errorf("parsing species database %s: %w", spec.SpeciesDatabase, err)
+		}
+	} else {
+		for name, info := range simulations[spec.Simulation].species {
+			entries[name] = info.properties()
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Species database for a %s run, generated by geoschem-aws\n", spec.Simulation)
+	for _, name := range species {
+		properties, ok := entries[name]
+		if !ok {
+			return "", fmt.Errorf("species %s isn't in the species database %s", name, spec.SpeciesDatabase)
+		}
+		// Names are written bare, as upstream does; yaml would quote NO and similar
+		var entry strings.Builder
+		encoder := yaml.NewEncoder(&entry)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(properties); err != nil {
+			return "", fmt.Errorf("encoding species %s: %w", name, err)
+		}
+		encoder.Close()
+		fmt.Fprintf(&b, "%s:\n", name)
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(entry.String(), "\n"), "\n") {
+			fmt.Fprintf(&b, "  %s", line)
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// properties returns the species' entry in species_database.yml
+func (s speciesInfo) properties() map[string]interface{} {
+	properties := map[string]interface{}{
+		"FullName":    s.fullName,
+		"Is_Advected": true,
+		"MW_g":        s.mwG,
+	}
+	if s.formula != "" {
+		properties["Formula"] = s.formula
+	}
+	if !s.aerosol {
+		properties["Is_Gas"] = true
+	}
+	if s.tracer {
+		properties["Is_Tracer"] = true
+	}
+	if s.aerosol {
+		properties["Is_Aerosol"] = true
+		properties["Is_DryDep"] = true
+		properties["Is_WetDep"] = true
+		properties["DD_DvzAerSnow"] = 0.03
+		properties["DD_DvzMinVal"] = []float64{0.01, 0.01}
+		properties["WD_AerScavEff"] = 1.0
+		properties["WD_KcScaleFac"] = []float64{1.0, 0.5, 1.0}
+		properties["WD_RainoutEff"] = []float64{1.0, 0.0, 1.0}
+	}
+	return properties
+}
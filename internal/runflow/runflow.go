@@ -0,0 +1,241 @@
+// Package runflow runs a planned GeosChem simulation on a scheduler and records what it
+// taught: right-sizing and memory limits for the next instance choice, performance for the
+// next prediction, provenance, and the output in the results catalog. run-geoschem and
+// 'geoschem-aws run' both run simulations through it.
+package runflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/benchmark"
+	"github.com/scttfrdmn/geoschem-aws/internal/catalog"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/data"
+	"github.com/scttfrdmn/geoschem-aws/internal/provenance"
+	"github.com/scttfrdmn/geoschem-aws/internal/runner"
+	"github.com/scttfrdmn/geoschem-aws/internal/state"
+)
+
+// MaxOOMRetries is how many larger instances a run retrying on OOM tries after the first run
+const MaxOOMRetries = 2
+
+// recording serializes what runs record in the state store, which rewrites each collection
+// whole, since a campaign finishes several runs at once
+var recording sync.Mutex
+
+// Run is a simulation ready for its scheduler
+type Run struct {
+	Job          runner.Job
+	NestedDomain string            // Nested-grid domain, which workload profiles and right-sizing are kept by
+	Chunk        string            // Chunk length (see runner.SplitChunks); empty runs in one piece
+	RetryOnOOM   bool              // Rerun on the next larger memory instance when the simulation runs out of memory
+	ConfigFiles  map[string]string // Files recorded in the provenance, by role
+	Profile      string
+	Region       string
+}
+
+// ExcludeOutOfMemory drops the candidates with no more memory than the workload has already
+// run out of, so instance selection doesn't repeat a choice that failed
+func ExcludeOutOfMemory(candidates []common.InstanceRecommendation, learned *state.WorkloadRecord) []common.InstanceRecommendation {
+	if learned == nil {
+		return candidates
+	}
+	fmt.Printf("🧠 Considering only instances with more than %.0f GB: this workload ran out of memory on %s\n",
+		learned.InsufficientMemoryGB, learned.OOMInstanceType)
+	return slices.DeleteFunc(candidates, func(instance common.InstanceRecommendation) bool {
+		return instance.Memory <= learned.InsufficientMemoryGB
+	})
+}
+
+// Cataloged reports whether the results catalog already holds the run's configuration for
+// its dates, listing the entries a run can reuse instead of computing them again
+func Cataloged(ctx context.Context, results *catalog.Catalog, config benchmark.Config, image string) (bool, error) {
+	prior, err := results.Search(ctx, catalog.Query{
+		ConfigHash: config.ConfigHash(image),
+		MetField:   config.MetField,
+		Start:      config.StartDate,
+		End:        config.EndDate,
+	})
+	if err != nil {
+		return false, fmt.Errorf("searching results catalog: %w", err)
+	}
+	if len(prior) == 0 {
+		return false, nil
+	}
+	fmt.Printf("\n♻️  %s already holds this configuration for these dates:\n", results.Table())
+	for _, entry := range prior {
+		fmt.Printf("   %s  %s to %s  %s\n", entry.ID, entry.StartDate, entry.EndDate, entry.OutputURI)
+	}
+	fmt.Println("Reuse that output, or pass --rerun to compute it again")
+	return true, nil
+}
+
+// Execute runs the job in its chunks and, when RetryOnOOM is set, reruns it on larger memory
+// instances after it runs out of memory. Each out-of-memory instance is kept in the workload
+// profile, and the final attempt's right-sizing is recorded. run.Job holds the configuration
+// of the final attempt when it returns.
+func Execute(ctx context.Context, store *state.Store, scheduler runner.Scheduler, run *Run) *benchmark.Result {
+	// Chunks are split again for each attempt, since an OOM retry changes the instance type
+	chunkRunner := runner.NewChunkRunner(run.Profile, run.Region)
+	attempt := func() *benchmark.Result {
+		chunks, err := runner.SplitChunks(run.Job.Config, run.Chunk)
+		if err != nil {
+			return &benchmark.Result{Image: run.Job.Image, InstanceType: run.Job.Config.InstanceType, Err: err}
+		}
+		return chunkRunner.Run(ctx, scheduler, run.Job, chunks)
+	}
+
+	workloads := state.NewWorkloadProfiles(store)
+	result := attempt()
+	for retry := 1; errors.Is(result.Err, benchmark.ErrOutOfMemory); retry++ {
+		next := handleOOM(workloads, run.Job.Config, run.NestedDomain)
+		if next == nil || !run.RetryOnOOM || retry > MaxOOMRetries {
+			break
+		}
+		fmt.Printf("\n🔁 Rerunning on %s (%.0f GB)...\n", next.InstanceType, next.Memory)
+		run.Job.Config.InstanceType = next.InstanceType
+		if run.Job.Resources != nil {
+			// Batch places the job by its resources rather than its instance type
+			resources := *run.Job.Resources
+			resources.MemoryMiB = max(resources.MemoryMiB, int(next.Memory*1024)*9/10)
+			run.Job.Resources = &resources
+		}
+		result = attempt()
+	}
+	RecordSizing(store, run.Job.Config, run.NestedDomain, result)
+	return result
+}
+
+// handleOOM records that the run's instance had too little memory for the workload and
+// recommends the next larger memory instance; nil when the catalog has none
+func handleOOM(workloads *state.WorkloadProfiles, config benchmark.Config, nestedDomain string) *common.InstanceRecommendation {
+	instance, err := common.LookupInstance(config.InstanceType)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return nil
+	}
+	fmt.Printf("\n💥 The simulation ran out of memory on %s (%.0f GB)\n", instance.InstanceType, instance.Memory)
+	recording.Lock()
+	err = workloads.RecordOOM(config.Simulation, config.Resolution, nestedDomain, instance.InstanceType, instance.Memory)
+	recording.Unlock()
+	if err != nil {
+		fmt.Printf("Warning: could not update workload profile: %v\n", err)
+	}
+
+	next, err := common.NextLargerMemory(instance.InstanceType, instance.Memory)
+	if err != nil {
+		fmt.Printf("⚠️  %v; try a coarser grid or fewer diagnostics\n", err)
+		return nil
+	}
+	fmt.Printf("💡 Next larger memory instance: %s (%d vCPUs, %.0f GB, $%.3f/hour); pass --instance-type %s or --retry-on-oom\n",
+		next.InstanceType, next.VCPUs, next.Memory, next.PricePerHour, next.InstanceType)
+	return next
+}
+
+// RecordSizing compares the run's measured usage to its instance and keeps the suggestion,
+// which 'geoschem-aws recommend' shows for the workload
+func RecordSizing(store *state.Store, config benchmark.Config, nestedDomain string, result *benchmark.Result) {
+	suggestion := benchmark.SizingSuggestion(config, result)
+	if suggestion == nil {
+		return
+	}
+	suggestion.NestedDomain = nestedDomain
+	if suggestion.Action != common.SizingKeep && suggestion.SuggestedType != "" {
+		fmt.Printf("📐 Right-sizing: %s to %s next time (%s)\n", suggestion.Action, suggestion.SuggestedType, suggestion.Reason)
+	}
+	recording.Lock()
+	defer recording.Unlock()
+	if err := state.NewSizingLog(store).Append(*suggestion); err != nil {
+		fmt.Printf("Warning: could not record right-sizing: %v\n", err)
+	}
+}
+
+// Record keeps what a successful run produced: its provenance, the restart file it ended
+// with, its output in the results catalog when one is given, and its performance for the
+// next prediction. The provenance sidecar goes next to the output before the catalog indexes it.
+func Record(ctx context.Context, store *state.Store, run *Run, schedulerName string, result *benchmark.Result, results *catalog.Catalog) {
+	recording.Lock()
+	defer recording.Unlock()
+	config := run.Job.Config
+
+	provenanceRun := benchmark.ProvenanceRun(config, run.Job.Image, schedulerName)
+	for role, path := range run.ConfigFiles {
+		provenanceRun.AddConfigFile(role, path)
+	}
+	recorder := provenance.NewRecorder(store, awscli.New(run.Profile, run.Region))
+	if record, err := recorder.Record(ctx, provenanceRun); err != nil {
+		fmt.Printf("Warning: could not record run provenance: %v\n", err)
+	} else {
+		fmt.Printf("   Provenance: %s (compare runs with 'geoschem-aws run diff')\n", record.ID)
+	}
+	if strings.HasPrefix(config.OutputURI, "s3://") {
+		registerRestart(ctx, store, run)
+	}
+
+	if results != nil && config.OutputURI != "" {
+		entry, err := results.RecordOutput(ctx, catalog.Entry{
+			ConfigHash:   config.ConfigHash(run.Job.Image),
+			Image:        run.Job.Image,
+			Simulation:   config.Simulation,
+			Resolution:   config.Resolution,
+			MetField:     config.MetField,
+			StartDate:    config.StartDate,
+			EndDate:      config.EndDate,
+			OutputURI:    config.OutputURI,
+			Cost:         result.Cost,
+			InstanceType: config.InstanceType,
+			Scheduler:    schedulerName,
+			User:         common.CurrentUser(),
+		})
+		if err != nil {
+			fmt.Printf("Warning: could not record output in the results catalog: %v\n", err)
+		} else {
+			fmt.Printf("   Catalog: %s (%s)\n", entry.ID, data.FormatBytes(entry.SizeBytes))
+		}
+	}
+
+	// Every run improves the next prediction
+	if err := state.NewPerformanceLog(store).Append(benchmark.PerformanceRecord(config, result)); err != nil {
+		fmt.Printf("Warning: could not record run performance: %v\n", err)
+	}
+}
+
+// registerRestart registers the restart file the run ended with, so later runs can continue
+// from it with -restart. Runs that write no restart for their end date register nothing.
+func registerRestart(ctx context.Context, store *state.Store, run *Run) {
+	config := run.Job.Config
+	uri, err := runner.NewChunkRunner(run.Profile, run.Region).FinalRestart(ctx, config)
+	if err != nil {
+		fmt.Printf("Warning: could not find the run's final restart: %v\n", err)
+		return
+	}
+	if uri == "" {
+		return
+	}
+	end, err := time.Parse("2006-01-02", config.EndDate)
+	if err != nil {
+		fmt.Printf("Warning: could not register restart %s: %v\n", uri, err)
+		return
+	}
+	restart, err := state.NewRestartRegistry(store).Register(state.RestartRecord{
+		S3URI:      uri,
+		ModelDate:  end,
+		Simulation: config.Simulation,
+		Resolution: config.Resolution,
+		ConfigHash: config.ConfigHash(run.Job.Image),
+		Image:      run.Job.Image,
+		RunID:      run.Job.Name,
+	})
+	if err != nil {
+		fmt.Printf("Warning: could not register restart %s: %v\n", uri, err)
+		return
+	}
+	fmt.Printf("   Restart: %s (continue from %s with --restart %s)\n", uri, config.EndDate, restart.ID)
+}
@@ -0,0 +1,216 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/benchmark"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/storage"
+)
+
+// runJobDefinition is the job definition family that run revisions are registered under
+const runJobDefinition = "geoschem-run"
+
+// BatchScheduler submits each run as an AWS Batch job. A job definition revision is
+// registered per run so the image and resources always match the request.
+type BatchScheduler struct {
+	cli        *awscli.Client
+	jobQueue   string
+	jobRoleARN string
+}
+
+// NewBatchScheduler creates a Batch scheduler from the config's runs.batch section
+func NewBatchScheduler(config *common.BuildConfig) *BatchScheduler {
+	return &BatchScheduler{
+		cli:        awscli.New(config.AWS.Profile, config.AWS.Region),
+		jobQueue:   config.Runs.Batch.JobQueue,
+		jobRoleARN: config.Runs.Batch.JobRoleARN,
+	}
+}
+
+func (s *BatchScheduler) Name() string {
+	return common.SchedulerBatch
+}
+
+// Run registers a job definition for the image, submits the run, and waits for it
+func (s *BatchScheduler) Run(ctx context.Context, job Job) *benchmark.Result {
+	result := newResult(job)
+
+	jobDefinition, err := s.registerJobDefinition(ctx, job)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	var environment []map[string]string
+	env := containerEnvironment(job.Config)
+	for _, name := range environmentNames(env) {
+		environment = append(environment, map[string]string{"name": name, "value": env[name]})
+	}
+
+	overrides, err := json.Marshal(map[string]interface{}{
+		"command":     containerArgs(job.Config),
+		"environment": environment,
+	})
+	if err != nil {
+		result.Err = fmt.Errorf("encoding container overrides: %w", err)
+		return result
+	}
+
+	args := []string{"batch", "submit-job",
+		"--job-name", job.Name,
+		"--job-queue", s.jobQueue,
+		"--job-definition", jobDefinition,
+		"--container-overrides", string(overrides)}
+	if job.TimeLimit > 0 {
+		args = append(args, "--timeout", fmt.Sprintf("attemptDurationSeconds=%d", int(job.TimeLimit.Seconds())))
+	}
+
+	var submitted struct {
+		JobID string `json:"jobId"`
+	}
+	if err := s.cli.Run(ctx, &submitted, args...); err != nil {
+		result.Err = fmt.Errorf("submitting batch job: %w", err)
+		return result
+	}
+	fmt.Printf("📨 Submitted Batch job %s for %s\n", submitted.JobID, job.Name)
+
+	result.WallClock, result.Err = waitForBatchJob(ctx, s.cli, submitted.JobID)
+	if result.Err == nil {
+		complete(result, job.Config)
+	}
+	return result
+}
+
+// JobResources are what a Batch job definition reserves for a run
+type JobResources struct {
+	VCPUs     int
+	MemoryMiB int
+	TimeLimit time.Duration // 0 leaves the run unlimited
+}
+
+// WorkloadResources generates a job's resources from a workload profile rather than an
+// instance type, so the compute environment can place it on any instance large enough
+func WorkloadResources(workload common.WorkloadProfile) JobResources {
+	// The profile's memory is a floor; half again covers output buffers and peaks during chemistry
+	return JobResources{
+		VCPUs:     workload.MinimumCores(),
+		MemoryMiB: int(workload.MinimumMemory() * 1.5 * 1024),
+		TimeLimit: time.Duration(workload.Duration) * time.Hour,
+	}
+}
+
+// instanceResources reserves most of an instance type, leaving headroom for the ECS agent and OS
+func instanceResources(instanceType string) (JobResources, error) {
+	instance, err := common.LookupInstance(instanceType)
+	if err != nil {
+		return JobResources{}, err
+	}
+	return JobResources{VCPUs: instance.VCPUs, MemoryMiB: int(instance.Memory*1024) * 9 / 10}, nil
+}
+
+// registerJobDefinition registers a revision running the image with the job's resources,
+// or the instance type's when the job has none
+func (s *BatchScheduler) registerJobDefinition(ctx context.Context, job Job) (string, error) {
+	resources := job.Resources
+	if resources == nil {
+		sized, err := instanceResources(job.Config.InstanceType)
+		if err != nil {
+			return "", err
+		}
+		resources = &sized
+	}
+
+	properties := map[string]interface{}{
+		"image": job.Image,
+		"resourceRequirements": []map[string]string{
+			{"type": "VCPU", "value": strconv.Itoa(resources.VCPUs)},
+			{"type": "MEMORY", "value": strconv.Itoa(resources.MemoryMiB)},
+		},
+		"linuxParameters": map[string]interface{}{"sharedMemorySize": resources.MemoryMiB / 4},
+		"ulimits":         []map[string]interface{}{{"name": "stack", "softLimit": -1, "hardLimit": -1}},
+	}
+	if s.jobRoleARN != "" {
+		properties["jobRoleArn"] = s.jobRoleARN
+	}
+	// The compute environment's hosts mount the file system at boot; the run reads its inputs there
+	if job.Config.FSxID != "" {
+		properties["volumes"] = []map[string]interface{}{{"name": "fsx", "host": map[string]string{"sourcePath": storage.DefaultFSxMountPath}}}
+		properties["mountPoints"] = []map[string]interface{}{{"sourceVolume": "fsx", "containerPath": "/workspace/data"}}
+	}
+	propertiesJSON, err := json.Marshal(properties)
+	if err != nil {
+		return "", fmt.Errorf("encoding container properties: %w", err)
+	}
+
+	var registered struct {
+		JobDefinitionArn string `json:"jobDefinitionArn"`
+	}
+	if err := s.cli.Run(ctx, &registered, "batch", "register-job-definition",
+		"--job-definition-name", runJobDefinition,
+		"--type", "container",
+		"--container-properties", string(propertiesJSON),
+		"--tags", "Project=geoschem-aws"); err != nil {
+		return "", fmt.Errorf("registering job definition: %w", err)
+	}
+	return registered.JobDefinitionArn, nil
+}
+
+// waitForBatchJob polls the job until it finishes and returns its running time
+func waitForBatchJob(ctx context.Context, cli *awscli.Client, jobID string) (time.Duration, error) {
+	lastStatus := ""
+	for {
+		var out struct {
+			Jobs []struct {
+				Status       string `json:"status"`
+				StatusReason string `json:"statusReason"`
+				StartedAt    int64  `json:"startedAt"` // Milliseconds since the epoch
+				StoppedAt    int64  `json:"stoppedAt"`
+			} `json:"jobs"`
+		}
+		if err := cli.Run(ctx, &out, "batch", "describe-jobs", "--jobs", jobID); err != nil {
+			return 0, fmt.Errorf("describing batch job: %w", err)
+		}
+		if len(out.Jobs) == 0 {
+			return 0, fmt.Errorf("batch job %s not found", jobID)
+		}
+
+		job := out.Jobs[0]
+		if job.Status != lastStatus {
+			fmt.Printf("Batch job %s: %s\n", jobID, job.Status)
+			lastStatus = job.Status
+		}
+
+		var wallClock time.Duration
+		if job.StartedAt > 0 && job.StoppedAt > job.StartedAt {
+			wallClock = time.Duration(job.StoppedAt-job.StartedAt) * time.Millisecond
+		}
+		switch job.Status {
+		case "SUCCEEDED":
+			return wallClock, nil
+		case "FAILED":
+			// Batch reports containers killed at their memory limit as OutOfMemoryError
+			if strings.HasPrefix(job.StatusReason, "OutOfMemoryError") {
+				return wallClock, fmt.Errorf("batch job %s failed: %w (%s)", jobID, benchmark.ErrOutOfMemory, job.StatusReason)
+			}
+			return wallClock, fmt.Errorf("batch job %s failed: %s", jobID, job.StatusReason)
+		}
+
+		select {
+		case <-ctx.Done():
+			// Don't leave the job running unattended
+			if err := cli.Run(context.Background(), nil, "batch", "terminate-job",
+				"--job-id", jobID, "--reason", "cancelled by submitter"); err != nil {
+				fmt.Printf("Warning: could not terminate batch job %s: %v\n", jobID, err)
+			}
+			return 0, ctx.Err()
+		case <-time.After(30 * time.Second):
+		}
+	}
+}
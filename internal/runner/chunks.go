@@ -0,0 +1,304 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/benchmark"
+)
+
+// Chunk lengths a long run can be split into
+const (
+	ChunkYear  = "year"
+	ChunkMonth = "month"
+)
+
+// restartSizeTolerance is how far a boundary restart's size may drift from the previous
+// boundary's before the chain is suspect, for restarts written without a summary
+const restartSizeTolerance = 0.1
+
+// ValidateChunk checks a chunk length; empty runs the simulation in one piece
+func ValidateChunk(every string) error {
+	switch every {
+	case "", ChunkYear, ChunkMonth:
+		return nil
+	default:
+		return fmt.Errorf("chunk must be %s or %s, got %s", ChunkYear, ChunkMonth, every)
+	}
+}
+
+// SplitChunks splits a run into consecutive chunks ending on calendar year or month
+// boundaries. Each chunk writes to its own directory under the run's output, named for its
+// start date, so chunks can be verified and skipped independently. Chunks after the first
+// get their restart file when the previous chunk is verified.
+func SplitChunks(config benchmark.Config, every string) ([]benchmark.Config, error) {
+	if err := ValidateChunk(every); err != nil {
+		return nil, err
+	}
+	start, err := time.Parse("2006-01-02", config.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date %s: %w", config.StartDate, err)
+	}
+	end, err := time.Parse("2006-01-02", config.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date %s: %w", config.EndDate, err)
+	}
+	if every == "" {
+		return []benchmark.Config{config}, nil
+	}
+	if !strings.HasPrefix(config.OutputURI, "s3://") {
+		return nil, fmt.Errorf("chunked runs pass restart files through S3 and need an S3 output")
+	}
+
+	var chunks []benchmark.Config
+	for chunkStart := start; chunkStart.Before(end); {
+		chunkEnd := time.Date(chunkStart.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+		if every == ChunkMonth {
+			chunkEnd = time.Date(chunkStart.Year(), chunkStart.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+
+		chunk := config
+		chunk.StartDate = chunkStart.Format("2006-01-02")
+		chunk.EndDate = chunkEnd.Format("2006-01-02")
+		chunk.OutputURI = fmt.Sprintf("%s/chunk-%s", strings.TrimSuffix(config.OutputURI, "/"), chunkStart.Format("20060102"))
+		if len(chunks) > 0 {
+			chunk.RestartURI = ""
+		}
+		chunks = append(chunks, chunk)
+		chunkStart = chunkEnd
+	}
+	return chunks, nil
+}
+
+// ChunkRunner runs chunked simulations, chaining each chunk to the restart file the
+// previous one ended with
+type ChunkRunner struct {
+	cli *awscli.Client
+}
+
+// NewChunkRunner creates a chunk runner that checks restart files with the given credentials
+func NewChunkRunner(profile, region string) *ChunkRunner {
+	return &ChunkRunner{cli: awscli.New(profile, region)}
+}
+
+// boundary is the restart file a chunk ended with
+type boundary struct {
+	uri     string
+	size    int64
+	summary *restartSummary // nil for restarts written by images without restart-summary.py
+}
+
+// restartSummary is what the image records next to each restart file it writes, as <file>.json
+type restartSummary struct {
+	Size      int64    `json:"size"`
+	SHA256    string   `json:"sha256"`
+	Variables []string `json:"variables"`
+	TimeSteps int      `json:"time_steps"`
+}
+
+// Run runs the chunks in order on the scheduler. After each chunk, its restart file is
+// verified before the next chunk starts from it: it must be in the chunk's output, valid
+// for the boundary date, and hold the same variables and time steps as the previous
+// boundary's; the next chunk checks its checksum after downloading it. Chunks whose
+// restart is already in place are skipped, so running the same submission again continues
+// after the last complete chunk. The result covers the chunks run; the first failure or
+// pause stops the chain.
+func (c *ChunkRunner) Run(ctx context.Context, scheduler Scheduler, job Job, chunks []benchmark.Config) *benchmark.Result {
+	if len(chunks) == 1 {
+		return scheduler.Run(ctx, job)
+	}
+
+	totalDays, _ := job.Config.ModelDays()
+	combined := newResult(job)
+	combined.Indexed = true
+	var previous *boundary
+	ran := 0
+	for i, chunk := range chunks {
+		label := fmt.Sprintf("chunk %d/%d (%s to %s)", i+1, len(chunks), chunk.StartDate, chunk.EndDate)
+		if previous != nil {
+			chunk.RestartURI = previous.uri
+		}
+
+		done, err := c.verify(ctx, chunk, previous)
+		if err != nil {
+			combined.Err = fmt.Errorf("%s: %w", label, err)
+			return combined
+		}
+		if done != nil {
+			fmt.Printf("⏭️  Skipping %s: its restart is already at %s\n", label, done.uri)
+			previous = done
+			continue
+		}
+
+		fmt.Printf("\n🧩 Running %s\n", label)
+		chunkJob := job
+		chunkJob.Name = fmt.Sprintf("%s-c%d", job.Name, i+1)
+		chunkJob.Config = chunk
+		if job.TimeLimit > 0 && totalDays > 0 {
+			days, _ := chunk.ModelDays()
+			chunkJob.TimeLimit = max(time.Duration(float64(job.TimeLimit)*days/totalDays), 2*time.Hour)
+		}
+		result := scheduler.Run(ctx, chunkJob)
+		ran++
+
+		combined.InstanceType = result.InstanceType
+		combined.WallClock += result.WallClock
+		combined.ModelDays += result.ModelDays
+		combined.Cost += result.Cost
+		combined.Summary = result.Summary
+		combined.Indexed = combined.Indexed && result.Indexed
+		combined.Resources = result.Resources
+		combined.Checkpoint = result.Checkpoint
+		for name, value := range result.Diagnostics {
+			combined.Diagnostics[name] = value
+		}
+		if result.Err != nil {
+			combined.Err = fmt.Errorf("%s: %w", label, result.Err)
+			break
+		}
+
+		// The next chunk starts only from a restart that continues this one
+		next, err := c.verify(ctx, chunk, previous)
+		if err == nil && next == nil {
+			err = fmt.Errorf("no restart file for %s in %s; the chunk didn't reach its end date", chunk.EndDate, chunk.OutputURI)
+		}
+		if err != nil {
+			combined.Err = fmt.Errorf("%s: %w", label, err)
+			break
+		}
+		previous = next
+	}
+
+	if ran == 0 && combined.Err == nil {
+		fmt.Printf("✅ Every chunk is already complete\n")
+	}
+	if combined.WallClock > 0 {
+		combined.Throughput = combined.ModelDays / (combined.WallClock.Hours() / 24)
+	}
+	return combined
+}
+
+// verify looks for the restart file a chunk ends with. It returns nil when the chunk hasn't
+// finished, and an error when the restart breaks the chain from the previous boundary.
+func (c *ChunkRunner) verify(ctx context.Context, chunk benchmark.Config, previous *boundary) (*boundary, error) {
+	next, err := c.endRestart(ctx, chunk)
+	if err != nil || next == nil {
+		return nil, err
+	}
+	if next.summary != nil {
+		if next.summary.Size != next.size {
+			return nil, fmt.Errorf("restart file %s is %d bytes in S3, but %d bytes were written; the upload is incomplete",
+				next.uri, next.size, next.summary.Size)
+		}
+	}
+	if previous == nil {
+		return next, nil
+	}
+
+	if previous.summary == nil || next.summary == nil {
+		// Without both summaries only the sizes can be compared
+		if previous.size > 0 {
+			drift := float64(next.size-previous.size) / float64(previous.size)
+			if drift > restartSizeTolerance || drift < -restartSizeTolerance {
+				return nil, fmt.Errorf("restart file %s is %d bytes, but the previous boundary's was %d; "+
+					"check the chunk used the same simulation and grid", next.uri, next.size, previous.size)
+			}
+		}
+		return next, nil
+	}
+	if missing, added := diffVariables(previous.summary.Variables, next.summary.Variables); len(missing) > 0 || len(added) > 0 {
+		return nil, fmt.Errorf("restart file %s doesn't hold the previous boundary's variables (missing %s; added %s); "+
+			"check the chunk used the same simulation and grid",
+			next.uri, strings.Join(missing, ", "), strings.Join(added, ", "))
+	}
+	if next.summary.TimeSteps != previous.summary.TimeSteps {
+		return nil, fmt.Errorf("restart file %s holds %d time steps, but the previous boundary's held %d",
+			next.uri, next.summary.TimeSteps, previous.summary.TimeSteps)
+	}
+	return next, nil
+}
+
+// diffVariables returns the variables of a that b lacks and those b adds; both are sorted
+func diffVariables(a, b []string) (missing, added []string) {
+	in := func(list []string, name string) bool {
+		_, found := slices.BinarySearch(list, name)
+		return found
+	}
+	for _, name := range a {
+		if !in(b, name) {
+			missing = append(missing, name)
+		}
+	}
+	for _, name := range b {
+		if !in(a, name) {
+			added = append(added, name)
+		}
+	}
+	return missing, added
+}
+
+// FinalRestart returns the restart file a finished run ended with, the one valid for its end
+// date anywhere under its output, so chunked runs' last chunk is found too. It returns ""
+// when the output holds none.
+func (c *ChunkRunner) FinalRestart(ctx context.Context, config benchmark.Config) (string, error) {
+	restart, err := c.endRestart(ctx, config)
+	if err != nil || restart == nil {
+		return "", err
+	}
+	return restart.uri, nil
+}
+
+// endRestart looks under a run's S3 output for the restart file valid for its end date
+func (c *ChunkRunner) endRestart(ctx context.Context, config benchmark.Config) (*boundary, error) {
+	end, err := time.Parse("2006-01-02", config.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	bucket, prefix, found := strings.Cut(strings.TrimPrefix(config.OutputURI, "s3://"), "/")
+	if !found {
+		return nil, fmt.Errorf("invalid output %s", config.OutputURI)
+	}
+
+	var objects []struct {
+		Key  string
+		Size int64
+	}
+	// A chunk that never started has no objects; the CLI prints null for the query
+	if err := c.cli.Run(ctx, &objects, "s3api", "list-objects-v2",
+		"--bucket", bucket, "--prefix", strings.TrimSuffix(prefix, "/")+"/",
+		"--query", "Contents[?contains(Key, 'GEOSChem.Restart.')].{Key: Key, Size: Size}"); err != nil {
+		return nil, fmt.Errorf("listing restart files in %s: %w", config.OutputURI, err)
+	}
+	keys := make([]string, len(objects))
+	for i, object := range objects {
+		keys[i] = object.Key
+	}
+
+	name := fmt.Sprintf("GEOSChem.Restart.%s_0000z.nc4", end.Format("20060102"))
+	for _, object := range objects {
+		if path.Base(object.Key) != name {
+			continue
+		}
+		if object.Size == 0 {
+			return nil, fmt.Errorf("restart file s3://%s/%s is empty", bucket, object.Key)
+		}
+		restart := &boundary{uri: fmt.Sprintf("s3://%s/%s", bucket, object.Key), size: object.Size}
+		if slices.Contains(keys, object.Key+".json") {
+			var summary restartSummary
+			if err := c.cli.Run(ctx, &summary, "s3", "cp", "--only-show-errors", restart.uri+".json", "-"); err != nil {
+				return nil, fmt.Errorf("reading the summary of %s: %w", restart.uri, err)
+			}
+			restart.summary = &summary
+		}
+		return restart, nil
+	}
+	return nil, nil
+}
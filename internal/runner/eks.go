@@ -0,0 +1,292 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/artifacts"
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/benchmark"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// eksJobRetention is how long finished Jobs and their pod logs stay in the cluster
+const eksJobRetention = 7 * 24 * time.Hour
+
+// EKSScheduler submits each run as a Kubernetes Job on an existing EKS cluster. Pods
+// request most of the predicted instance's CPU and memory and select its architecture,
+// so Karpenter (or a matching managed node group) provisions a suitable node.
+type EKSScheduler struct {
+	cli    *awscli.Client
+	config common.EKSConfig
+}
+
+// NewEKSScheduler creates a scheduler for the cluster in the config's runs.eks section
+func NewEKSScheduler(config *common.BuildConfig) *EKSScheduler {
+	eks := config.Runs.EKS
+	if eks.Namespace == "" {
+		eks.Namespace = "default"
+	}
+	return &EKSScheduler{
+		cli:    awscli.New(config.AWS.Profile, config.AWS.Region),
+		config: eks,
+	}
+}
+
+func (s *EKSScheduler) Name() string {
+	return common.SchedulerEKS
+}
+
+// Run creates the Job with kubectl and polls it until it finishes
+func (s *EKSScheduler) Run(ctx context.Context, job Job) *benchmark.Result {
+	result := newResult(job)
+
+	kubeconfig, err := s.writeKubeconfig(ctx)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer artifacts.Remove(kubeconfig)
+
+	manifest, err := s.jobManifest(job)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	// generateName gives every submission a unique Job name
+	output, err := s.kubectl(ctx, kubeconfig, manifest, "create", "-f", "-", "-o", "name")
+	if err != nil {
+		result.Err = fmt.Errorf("creating Kubernetes job: %w", err)
+		return result
+	}
+	jobName := strings.TrimPrefix(strings.TrimSpace(string(output)), "job.batch/")
+	fmt.Printf("📨 Created Kubernetes job %s/%s on %s (logs: kubectl logs -n %s job/%s)\n",
+		s.config.Namespace, jobName, s.config.Cluster, s.config.Namespace, jobName)
+
+	result.WallClock, result.Err = s.waitForJob(ctx, kubeconfig, jobName)
+	if result.Err == nil {
+		complete(result, job.Config)
+	}
+	return result
+}
+
+// writeKubeconfig writes a private kubeconfig for the cluster so the user's own is untouched
+func (s *EKSScheduler) writeKubeconfig(ctx context.Context) (string, error) {
+	file, err := os.CreateTemp("", "geoschem-kubeconfig-*")
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	artifacts.Track(file.Name())
+
+	if err := s.cli.Run(ctx, nil, "eks", "update-kubeconfig",
+		"--name", s.config.Cluster,
+		"--kubeconfig", file.Name()); err != nil {
+		artifacts.Remove(file.Name())
+		return "", fmt.Errorf("getting credentials for cluster %s: %w", s.config.Cluster, err)
+	}
+	return file.Name(), nil
+}
+
+// jobManifest returns the Job as JSON, which kubectl accepts like YAML
+func (s *EKSScheduler) jobManifest(job Job) ([]byte, error) {
+	instance, err := common.LookupInstance(job.Config.InstanceType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Leave room for the kubelet, system reservations, and daemonsets so the pod
+	// still fits on the instance size it was sized for
+	cpu := fmt.Sprintf("%dm", instance.VCPUs*900)
+	memoryMiB := int(instance.Memory*1024) * 85 / 100
+	memory := fmt.Sprintf("%dMi", memoryMiB)
+
+	nodeSelector := map[string]string{"kubernetes.io/arch": kubernetesArch(instance.Architecture)}
+	if s.config.PinInstance {
+		nodeSelector["node.kubernetes.io/instance-type"] = instance.InstanceType
+	}
+	for label, value := range s.config.NodeSelector {
+		nodeSelector[label] = value
+	}
+
+	var env []map[string]string
+	containerEnv := containerEnvironment(job.Config)
+	for _, name := range environmentNames(containerEnv) {
+		env = append(env, map[string]string{"name": name, "value": containerEnv[name]})
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":       "geoschem",
+		"app.kubernetes.io/managed-by": "geoschem-aws",
+		"geoschem-aws/simulation":      kubernetesName(job.Config.Simulation),
+	}
+
+	podSpec := map[string]interface{}{
+		"restartPolicy": "Never",
+		"nodeSelector":  nodeSelector,
+		"containers": []map[string]interface{}{{
+			"name":  "geoschem",
+			"image": job.Image,
+			"args":  containerArgs(job.Config),
+			"env":   env,
+			"resources": map[string]interface{}{
+				"requests": map[string]string{"cpu": cpu, "memory": memory},
+				"limits":   map[string]string{"cpu": cpu, "memory": memory},
+			},
+			"volumeMounts": []map[string]string{{"name": "dshm", "mountPath": "/dev/shm"}},
+		}},
+		// The container runtime's 64 MB /dev/shm is too small for OpenMP runs
+		"volumes": []map[string]interface{}{{
+			"name":     "dshm",
+			"emptyDir": map[string]string{"medium": "Memory", "sizeLimit": fmt.Sprintf("%dMi", memoryMiB/4)},
+		}},
+	}
+	if s.config.ServiceAccount != "" {
+		podSpec["serviceAccountName"] = s.config.ServiceAccount
+	}
+
+	spec := map[string]interface{}{
+		"backoffLimit":            0, // A failed simulation fails the same way on retry
+		"ttlSecondsAfterFinished": int(eksJobRetention.Seconds()),
+		"template": map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"labels": labels,
+				// Karpenter must not consolidate the node out from under a running simulation
+				"annotations": map[string]string{"karpenter.sh/do-not-disrupt": "true"},
+			},
+			"spec": podSpec,
+		},
+	}
+	if job.TimeLimit > 0 {
+		spec["activeDeadlineSeconds"] = int(job.TimeLimit.Seconds())
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": map[string]interface{}{
+			"generateName": kubernetesName(job.Name) + "-",
+			"namespace":    s.config.Namespace,
+			"labels":       labels,
+		},
+		"spec": spec,
+	})
+}
+
+// waitForJob polls the Job until it completes or fails and returns its running time
+func (s *EKSScheduler) waitForJob(ctx context.Context, kubeconfig, jobName string) (time.Duration, error) {
+	lastState := ""
+	for {
+		output, err := s.kubectl(ctx, kubeconfig, nil, "get", "job", jobName, "-o", "json")
+		if err != nil {
+			return 0, fmt.Errorf("checking Kubernetes job %s: %w", jobName, err)
+		}
+
+		var status struct {
+			Status struct {
+				Active         int       `json:"active"`
+				StartTime      time.Time `json:"startTime"`
+				CompletionTime time.Time `json:"completionTime"`
+				Conditions     []struct {
+					Type    string `json:"type"`
+					Status  string `json:"status"`
+					Reason  string `json:"reason"`
+					Message string `json:"message"`
+				} `json:"conditions"`
+			} `json:"status"`
+		}
+		if err := json.Unmarshal(output, &status); err != nil {
+			return 0, fmt.Errorf("parsing Kubernetes job %s: %w", jobName, err)
+		}
+
+		state := "pending"
+		if status.Status.Active > 0 {
+			state = "active"
+		}
+		for _, condition := range status.Status.Conditions {
+			if condition.Status != "True" {
+				continue
+			}
+			switch condition.Type {
+			case "Complete":
+				elapsed := status.Status.CompletionTime.Sub(status.Status.StartTime)
+				return max(elapsed, 0), nil
+			case "Failed":
+				var elapsed time.Duration
+				if !status.Status.StartTime.IsZero() {
+					elapsed = time.Since(status.Status.StartTime)
+				}
+				// The job only reports its retries ran out; the pods say whether memory did
+				if reasons, err := s.kubectl(ctx, kubeconfig, nil, "get", "pods", "-l", "job-name="+jobName,
+					"-o", "jsonpath={..terminated.reason}"); err == nil && strings.Contains(string(reasons), "OOMKilled") {
+					return elapsed, fmt.Errorf("Kubernetes job %s failed: %w (OOMKilled)", jobName, benchmark.ErrOutOfMemory)
+				}
+				return elapsed, fmt.Errorf("Kubernetes job %s failed: %s %s",
+					jobName, condition.Reason, condition.Message)
+			}
+		}
+		if state != lastState {
+			fmt.Printf("Kubernetes job %s: %s\n", jobName, state)
+			lastState = state
+		}
+
+		select {
+		case <-ctx.Done():
+			// Don't leave the simulation running unattended
+			if output, err := s.kubectl(context.Background(), kubeconfig, nil, "delete", "job", jobName,
+				"--propagation-policy=Background", "--wait=false"); err != nil {
+				fmt.Printf("Warning: could not delete Kubernetes job %s: %v, output: %s\n", jobName, err, output)
+			}
+			return 0, ctx.Err()
+		case <-time.After(30 * time.Second):
+		}
+	}
+}
+
+// kubectl runs kubectl against the scheduler's namespace with the given kubeconfig
+func (s *EKSScheduler) kubectl(ctx context.Context, kubeconfig string, stdin []byte, args ...string) ([]byte, error) {
+	name := args[0]
+	args = append([]string{"--kubeconfig", kubeconfig, "--namespace", s.config.Namespace}, args...)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.Bytes(), fmt.Errorf("kubectl %s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// kubernetesArch maps the instance catalog's architecture to the kubernetes.io/arch label
+func kubernetesArch(architecture string) string {
+	if architecture == "arm64" {
+		return "arm64"
+	}
+	return "amd64"
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// kubernetesName makes a DNS-1123 label, leaving room for generateName's 5-character suffix
+func kubernetesName(name string) string {
+	name = invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	if len(name) > 57 {
+		name = name[:57]
+	}
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return "geoschem"
+	}
+	return name
+}
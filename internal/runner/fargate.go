@@ -0,0 +1,178 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+const (
+	// auxJobDefinition is the job definition family that auxiliary job revisions are registered under
+	auxJobDefinition = "geoschem-aux"
+	// auxJobTimeout stops an auxiliary job that hangs rather than letting it bill indefinitely
+	auxJobTimeout = 2 * time.Hour
+)
+
+// jobNameUnsafe matches what Batch doesn't allow in a job name
+var jobNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// AuxJob is a short container job that supports runs rather than being one: indexing,
+// tabulation, data staging, or a validation check
+type AuxJob struct {
+	Name        string // Job name; defaults to the command
+	Image       string
+	Command     []string
+	Environment map[string]string
+	TimeLimit   time.Duration // 0 uses auxJobTimeout
+}
+
+// FargateRunner runs auxiliary jobs on a Batch queue backed by Fargate, so they need no
+// instances and leave the EC2 vCPU quota to simulations. A job definition revision is
+// registered per image, like BatchScheduler does per run.
+type FargateRunner struct {
+	cli    *awscli.Client
+	region string
+	config common.FargateConfig
+}
+
+// NewFargateRunner creates a runner from the config's runs.fargate section
+func NewFargateRunner(config *common.BuildConfig) (*FargateRunner, error) {
+	fargate := config.Runs.Fargate
+	if !fargate.Enabled() {
+		return nil, fmt.Errorf("no Fargate queue configured; set runs.fargate.job_queue")
+	}
+	if err := fargate.Validate(); err != nil {
+		return nil, fmt.Errorf("runs.%w", err)
+	}
+	if fargate.JobRoleARN == "" {
+		fargate.JobRoleARN = config.Runs.Batch.JobRoleARN
+	}
+	return &FargateRunner{
+		cli:    awscli.New(config.AWS.Profile, config.AWS.Region),
+		region: config.AWS.Region,
+		config: fargate.WithDefaults(),
+	}, nil
+}
+
+// Run registers a job definition for the image, submits the job, and waits for it
+func (f *FargateRunner) Run(ctx context.Context, job AuxJob) error {
+	jobID, err := f.Submit(ctx, job)
+	if err != nil {
+		return err
+	}
+	if _, err := waitForBatchJob(ctx, f.cli, jobID); err != nil {
+		return fmt.Errorf("%w (container output is in the /aws/batch/job log group)", err)
+	}
+	return nil
+}
+
+// Submit registers a job definition for the image and submits the job without waiting
+// for it, returning its Batch job ID
+func (f *FargateRunner) Submit(ctx context.Context, job AuxJob) (string, error) {
+	if len(job.Command) == 0 {
+		return "", fmt.Errorf("auxiliary job has no command")
+	}
+	name := job.Name
+	if name == "" {
+		name = job.Command[0]
+	}
+	name = strings.Trim(jobNameUnsafe.ReplaceAllString(name, "-"), "-")
+	if len(name) > 128 {
+		name = name[:128]
+	}
+
+	jobDefinition, err := f.registerJobDefinition(ctx, job.Image)
+	if err != nil {
+		return "", err
+	}
+
+	env := map[string]string{"AWS_REGION": f.region}
+	for key, value := range job.Environment {
+		env[key] = value
+	}
+	var environment []map[string]string
+	for _, key := range environmentNames(env) {
+		environment = append(environment, map[string]string{"name": key, "value": env[key]})
+	}
+	overrides, err := json.Marshal(map[string]interface{}{
+		"command":     job.Command,
+		"environment": environment,
+	})
+	if err != nil {
+		return "", fmt.Errorf("encoding container overrides: %w", err)
+	}
+
+	timeLimit := job.TimeLimit
+	if timeLimit <= 0 {
+		timeLimit = auxJobTimeout
+	}
+	var submitted struct {
+		JobID string `json:"jobId"`
+	}
+	if err := f.cli.Run(ctx, &submitted, "batch", "submit-job",
+		"--job-name", name,
+		"--job-queue", f.config.JobQueue,
+		"--job-definition", jobDefinition,
+		"--container-overrides", string(overrides),
+		"--timeout", fmt.Sprintf("attemptDurationSeconds=%d", int(timeLimit.Seconds()))); err != nil {
+		return "", fmt.Errorf("submitting Fargate job: %w", err)
+	}
+	fmt.Printf("📨 Submitted Fargate job %s for %s\n", submitted.JobID, name)
+	return submitted.JobID, nil
+}
+
+// RunAnalysis runs a command in the analysis image on Fargate, so indexing and tabulation
+// read output inside AWS instead of through this machine
+func (f *FargateRunner) RunAnalysis(ctx context.Context, analysisImage string, command ...string) error {
+	return f.Run(ctx, AuxJob{Image: analysisImage, Command: command})
+}
+
+// registerJobDefinition registers a Fargate revision running the image at the configured size
+func (f *FargateRunner) registerJobDefinition(ctx context.Context, image string) (string, error) {
+	assignPublicIP := "DISABLED"
+	if f.config.AssignPublicIP {
+		assignPublicIP = "ENABLED"
+	}
+	cpuArchitecture := "X86_64"
+	if f.config.Architecture == "arm64" {
+		cpuArchitecture = "ARM64"
+	}
+
+	properties := map[string]interface{}{
+		"image": image,
+		"resourceRequirements": []map[string]string{
+			{"type": "VCPU", "value": strconv.FormatFloat(f.config.VCPUs, 'f', -1, 64)},
+			{"type": "MEMORY", "value": strconv.Itoa(f.config.MemoryMiB)},
+		},
+		"executionRoleArn":     f.config.ExecutionRoleARN,
+		"networkConfiguration": map[string]string{"assignPublicIp": assignPublicIP},
+		"runtimePlatform":      map[string]string{"operatingSystemFamily": "LINUX", "cpuArchitecture": cpuArchitecture},
+	}
+	if f.config.JobRoleARN != "" {
+		properties["jobRoleArn"] = f.config.JobRoleARN
+	}
+	propertiesJSON, err := json.Marshal(properties)
+	if err != nil {
+		return "", fmt.Errorf("encoding container properties: %w", err)
+	}
+
+	var registered struct {
+		JobDefinitionArn string `json:"jobDefinitionArn"`
+	}
+	if err := f.cli.Run(ctx, &registered, "batch", "register-job-definition",
+		"--job-definition-name", auxJobDefinition,
+		"--type", "container",
+		"--platform-capabilities", "FARGATE",
+		"--container-properties", string(propertiesJSON),
+		"--tags", "Project=geoschem-aws"); err != nil {
+		return "", fmt.Errorf("registering Fargate job definition: %w", err)
+	}
+	return registered.JobDefinitionArn, nil
+}
@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/storage"
+)
+
+// PrepareFSx returns the FSx for Lustre file system runs.fsx gives a run, creating one
+// called name when no file_system_id is configured, and the function that applies
+// after_run once the run is over. Without runs.fsx it returns no file system.
+func PrepareFSx(ctx context.Context, config *common.BuildConfig, name string) (string, func(context.Context) error, error) {
+	fsx := config.Runs.FSx.WithDefaults()
+	if !fsx.Enabled() {
+		return "", func(context.Context) error { return nil }, nil
+	}
+
+	manager := storage.NewFSxManager(config.AWS.Profile, config.AWS.Region)
+	id := fsx.FileSystemID
+	if fsx.Provisioned() {
+		fmt.Printf("💰 FSx for Lustre costs ~$%.2f for each hour it exists\n", fsx.HourlyCost())
+		fileSystem, err := manager.Create(ctx, name, config.AWS.SubnetID, config.AWS.SecurityGroup, fsx)
+		if err != nil {
+			return "", nil, fmt.Errorf("creating FSx file system: %w", err)
+		}
+		id = fileSystem.FileSystemID
+	}
+
+	release := func(ctx context.Context) error {
+		switch fsx.AfterRun {
+		case common.FSxDelete:
+			fmt.Printf("🗑️  Deleting FSx file system %s...\n", id)
+			return manager.Delete(ctx, id)
+		case common.FSxHibernate:
+			fmt.Printf("💤 Hibernating FSx file system %s to its data repository...\n", id)
+			// Exporting a large run directory outlasts the shutdown timeout; a second interrupt still exits
+			return manager.Hibernate(context.WithoutCancel(ctx), id)
+		}
+		fmt.Printf("🗄️  Keeping FSx file system %s; delete it with 'storage fsx delete -id %s'\n", id, id)
+		return nil
+	}
+	return id, release, nil
+}
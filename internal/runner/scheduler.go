@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/benchmark"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/hemco"
+)
+
+// Job is one simulation to execute
+type Job struct {
+	Name      string
+	Image     string
+	Config    benchmark.Config
+	TimeLimit time.Duration // Wall-clock limit enforced by the scheduler; 0 uses the scheduler's default
+	Resources *JobResources // Batch reserves these instead of the whole instance type when set
+}
+
+// Scheduler executes simulations on one execution substrate. Run blocks until the
+// simulation finishes and reports failures in the result.
+type Scheduler interface {
+	Name() string
+	Run(ctx context.Context, job Job) *benchmark.Result
+}
+
+// NewScheduler returns the scheduler selected by the config's runs section
+func NewScheduler(cfg aws.Config, config *common.BuildConfig) (Scheduler, error) {
+	if err := config.Runs.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch config.Runs.SchedulerName() {
+	case common.SchedulerEC2:
+		return &ec2Scheduler{runner: benchmark.NewRunner(cfg, config)}, nil
+	case common.SchedulerBatch:
+		return NewBatchScheduler(config), nil
+	case common.SchedulerSlurm:
+		return NewSlurmScheduler(config.Runs.Slurm), nil
+	case common.SchedulerEKS:
+		return NewEKSScheduler(config), nil
+	default:
+		return nil, fmt.Errorf("unknown scheduler %s", config.Runs.Scheduler)
+	}
+}
+
+// ec2Scheduler launches a dedicated instance per run
+type ec2Scheduler struct {
+	runner *benchmark.Runner
+}
+
+func (s *ec2Scheduler) Name() string {
+	return common.SchedulerEC2
+}
+
+func (s *ec2Scheduler) Run(ctx context.Context, job Job) *benchmark.Result {
+	return s.runner.Run(ctx, job.Config, job.Image)
+}
+
+// containerArgs are the image entrypoint's arguments for the run
+func containerArgs(config benchmark.Config) []string {
+	args := []string{"classic",
+		"--simulation", config.Simulation,
+		"--resolution", config.Resolution,
+		"--start-date", config.StartDate,
+		"--end-date", config.EndDate,
+	}
+	if config.Checkpoint != "" {
+		args = append(args, "--checkpoint-frequency", config.Checkpoint)
+	}
+	if config.EmissionsYear != 0 {
+		args = append(args, "--emissions-year", strconv.Itoa(config.EmissionsYear))
+	}
+	// Runs are validated before reaching a scheduler, so the overrides encode
+	if overrides, _ := hemco.Encode(config.Emissions); len(config.Emissions) > 0 {
+		args = append(args, "--hemco-overrides", overrides)
+	}
+	return args
+}
+
+// containerEnvironment tells the image's entrypoint what to stage in and copy out, for
+// schedulers that cannot prepare host directories before the container starts
+func containerEnvironment(config benchmark.Config) map[string]string {
+	env := make(map[string]string)
+	if config.DataSource != "" {
+		env["GEOSCHEM_DATA_SOURCE"] = config.DataSource
+		// Runs and campaign members check their filters build before reaching a scheduler
+		if filters, _ := config.DataSyncFilters(); len(filters) > 0 {
+			env["GEOSCHEM_DATA_SYNC_FILTERS"] = strings.Join(filters, " ")
+		}
+	}
+	if config.RestartURI != "" {
+		env["GEOSCHEM_RESTART_URI"] = config.RestartURI
+	}
+	if config.OutputURI != "" {
+		env["GEOSCHEM_OUTPUT_URI"] = config.OutputURI
+	}
+	return env
+}
+
+// environmentNames returns the variable names in a stable order
+func environmentNames(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newResult starts a result for a run on a scheduler that reports only timing
+func newResult(job Job) *benchmark.Result {
+	return &benchmark.Result{
+		Image:        job.Image,
+		InstanceType: job.Config.InstanceType,
+		Diagnostics:  make(map[string]float64),
+	}
+}
+
+// complete fills in throughput and cost from the measured wall clock
+func complete(result *benchmark.Result, config benchmark.Config) {
+	result.ModelDays, _ = config.ModelDays()
+	if result.WallClock > 0 {
+		result.Throughput = result.ModelDays / (result.WallClock.Hours() / 24)
+	}
+	if instance, err := common.LookupInstance(config.InstanceType); err == nil {
+		result.Cost = instance.PricePerHour * result.WallClock.Hours()
+	}
+}
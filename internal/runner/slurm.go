@@ -0,0 +1,206 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/benchmark"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/ssh"
+)
+
+// defaultSlurmWorkDir is the shared directory ParallelCluster mounts on every node
+const defaultSlurmWorkDir = "/shared/geoschem"
+
+// defaultSlurmTimeLimit applies when a job has no time limit of its own
+const defaultSlurmTimeLimit = 24 * time.Hour
+
+// SlurmScheduler submits each run with sbatch on a Slurm head node, such as a
+// ParallelCluster cluster, and runs the image with Apptainer or Podman on a compute node
+type SlurmScheduler struct {
+	config common.SlurmConfig
+}
+
+// NewSlurmScheduler creates a scheduler for the cluster in the config's runs.slurm section
+func NewSlurmScheduler(config common.SlurmConfig) *SlurmScheduler {
+	if config.User == "" {
+		config.User = "ec2-user"
+	}
+	if config.Runtime == "" {
+		config.Runtime = "apptainer"
+	}
+	if config.WorkDir == "" {
+		config.WorkDir = defaultSlurmWorkDir
+	}
+	return &SlurmScheduler{config: config}
+}
+
+func (s *SlurmScheduler) Name() string {
+	return common.SchedulerSlurm
+}
+
+// Run writes a batch script on the head node, submits it, and polls sacct until it finishes
+func (s *SlurmScheduler) Run(ctx context.Context, job Job) *benchmark.Result {
+	result := newResult(job)
+
+	client, err := ssh.NewClient(s.config.HeadNode, s.config.User, s.config.KeyFile)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if err := client.Connect(ctx, s.config.HeadNode); err != nil {
+		result.Err = fmt.Errorf("connecting to head node %s: %w", s.config.HeadNode, err)
+		return result
+	}
+	defer client.Close()
+
+	jobDir := path.Join(s.config.WorkDir, job.Name)
+	script := s.batchScript(job, jobDir)
+	writeCmd := fmt.Sprintf("mkdir -p %s/output && cat > %s/job.sbatch <<'GEOSCHEM_EOF'\n%sGEOSCHEM_EOF", jobDir, jobDir, script)
+	if output, err := client.ExecuteCommand(ctx, writeCmd); err != nil {
+		result.Err = fmt.Errorf("writing batch script: %w, output: %s", err, output)
+		return result
+	}
+
+	output, err := client.ExecuteCommand(ctx, fmt.Sprintf("sbatch --parsable %s/job.sbatch", jobDir))
+	if err != nil {
+		result.Err = fmt.Errorf("submitting to Slurm: %w, output: %s", err, output)
+		return result
+	}
+	jobID := strings.Split(strings.TrimSpace(output), ";")[0]
+	fmt.Printf("📨 Submitted Slurm job %s for %s (log: %s/slurm.log)\n", jobID, job.Name, jobDir)
+
+	result.WallClock, result.Err = s.waitForJob(ctx, client, jobID)
+	if result.Err == nil {
+		complete(result, job.Config)
+	}
+	return result
+}
+
+// batchScript returns the sbatch script that runs the image on one exclusive node
+func (s *SlurmScheduler) batchScript(job Job, jobDir string) string {
+	timeLimit := job.TimeLimit
+	if timeLimit <= 0 {
+		timeLimit = defaultSlurmTimeLimit
+	}
+
+	var script strings.Builder
+	script.WriteString("#!/bin/bash\n")
+	script.WriteString(fmt.Sprintf("#SBATCH --job-name=%s\n", job.Name))
+	script.WriteString("#SBATCH --nodes=1\n#SBATCH --exclusive\n")
+	script.WriteString(fmt.Sprintf("#SBATCH --time=%s\n", slurmDuration(timeLimit)))
+	script.WriteString(fmt.Sprintf("#SBATCH --output=%s/slurm.log\n", jobDir))
+	if s.config.Partition != "" {
+		script.WriteString(fmt.Sprintf("#SBATCH --partition=%s\n", s.config.Partition))
+	}
+	script.WriteString("set -euo pipefail\n")
+
+	env := containerEnvironment(job.Config)
+	names := environmentNames(env)
+
+	// Arguments are quoted for the script; emissions overrides are JSON
+	var quoted []string
+	for _, arg := range containerArgs(job.Config) {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	args := strings.Join(quoted, " ")
+	mount := fmt.Sprintf("%s/output:/workspace/output", jobDir)
+	registry := strings.Split(job.Image, "/")[0]
+	ecr := strings.Contains(registry, ".dkr.ecr.")
+
+	switch s.config.Runtime {
+	case "podman":
+		if ecr {
+			script.WriteString(fmt.Sprintf("aws ecr get-login-password --region %s | podman login --username AWS --password-stdin %s\n",
+				ecrRegion(registry), registry))
+		}
+		var envFlags strings.Builder
+		for _, name := range names {
+			envFlags.WriteString(fmt.Sprintf(" -e %s=%s", name, shellQuote(env[name])))
+		}
+		script.WriteString(fmt.Sprintf("podman run --rm -v %s%s %s %s\n", mount, envFlags.String(), job.Image, args))
+	default:
+		if ecr {
+			script.WriteString("export APPTAINER_DOCKER_USERNAME=AWS\n")
+			script.WriteString(fmt.Sprintf("export APPTAINER_DOCKER_PASSWORD=$(aws ecr get-login-password --region %s)\n", ecrRegion(registry)))
+		}
+		for _, name := range names {
+			script.WriteString(fmt.Sprintf("export APPTAINERENV_%s=%s\n", name, shellQuote(env[name])))
+		}
+		// --writable-tmpfs lets the entrypoint create /workspace directories in the read-only image
+		script.WriteString(fmt.Sprintf("apptainer run --writable-tmpfs --bind %s docker://%s %s\n", mount, job.Image, args))
+	}
+	return script.String()
+}
+
+// waitForJob polls sacct until the job reaches a terminal state and returns its elapsed time
+func (s *SlurmScheduler) waitForJob(ctx context.Context, client *ssh.Client, jobID string) (time.Duration, error) {
+	lastState := ""
+	for {
+		output, err := client.ExecuteCommand(ctx, fmt.Sprintf("sacct -j %s -X --noheader --parsable2 --format=State,ElapsedRaw", jobID))
+		if err != nil {
+			return 0, fmt.Errorf("checking Slurm job %s: %w", jobID, err)
+		}
+
+		// States like "CANCELLED by 1000" carry a suffix; sacct prints nothing until the job is recorded
+		fields := strings.Split(strings.TrimSpace(output), "|")
+		state := ""
+		if words := strings.Fields(fields[0]); len(words) > 0 {
+			state = words[0]
+		}
+		if state != "" && state != lastState {
+			fmt.Printf("Slurm job %s: %s\n", jobID, state)
+			lastState = state
+		}
+
+		var elapsed time.Duration
+		if len(fields) > 1 {
+			seconds, _ := strconv.Atoi(fields[1])
+			elapsed = time.Duration(seconds) * time.Second
+		}
+		switch state {
+		case "COMPLETED":
+			return elapsed, nil
+		case "OUT_OF_MEMORY":
+			return elapsed, fmt.Errorf("Slurm job %s ended %s: %w", jobID, state, benchmark.ErrOutOfMemory)
+		case "FAILED", "CANCELLED", "TIMEOUT", "NODE_FAIL", "PREEMPTED", "BOOT_FAIL", "DEADLINE":
+			return elapsed, fmt.Errorf("Slurm job %s ended %s", jobID, state)
+		}
+
+		select {
+		case <-ctx.Done():
+			// Don't leave the simulation running unattended
+			if output, err := client.ExecuteCommand(context.Background(), "scancel "+jobID); err != nil {
+				fmt.Printf("Warning: could not cancel Slurm job %s: %v, output: %s\n", jobID, err, output)
+			}
+			return 0, ctx.Err()
+		case <-time.After(30 * time.Second):
+		}
+	}
+}
+
+// slurmDuration formats a duration as Slurm's D-HH:MM:SS
+func slurmDuration(d time.Duration) string {
+	total := int(d.Round(time.Minute).Seconds())
+	days := total / 86400
+	total %= 86400
+	return fmt.Sprintf("%d-%02d:%02d:%02d", days, total/3600, total%3600/60, total%60)
+}
+
+// ecrRegion extracts the region from an ECR registry host (<account>.dkr.ecr.<region>.amazonaws.com)
+func ecrRegion(registry string) string {
+	parts := strings.Split(registry, ".")
+	if len(parts) < 4 {
+		return ""
+	}
+	return parts[3]
+}
+
+// shellQuote wraps a value in single quotes for the shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
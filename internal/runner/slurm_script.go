@@ -0,0 +1,213 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Model modes the image's entrypoint runs
+const (
+	ModeClassic = "classic"
+	ModeGCHP    = "gchp"
+)
+
+// entrypoint is the image's run script; apptainer exec skips the image's ENTRYPOINT
+const entrypoint = "/usr/local/bin/geoschem-entrypoint.sh"
+
+// gchpExecutable is the GCHP binary each MPI rank runs
+const gchpExecutable = "/opt/geoschem/gchp/bin/gchp"
+
+// srunMPI maps an image's MPI implementation to the srun PMI plugin it bootstraps with
+var srunMPI = map[string]string{
+	"openmpi":  "pmix",
+	"intelmpi": "pmi2",
+	"mpich":    "pmi2",
+}
+
+// SlurmScriptSpec describes a run for a Slurm cluster outside AWS, such as a campus HPC
+// system, where the image runs from a local Apptainer SIF file and inputs are on a shared
+// file system
+type SlurmScriptSpec struct {
+	Image      string // Image reference the SIF is pulled from
+	SIF        string // SIF file on the cluster; pulled from Image when missing
+	Mode       string // ModeClassic (one node, OpenMP) or ModeGCHP (MPI across nodes)
+	Simulation string
+	Resolution string
+	StartDate  string
+	EndDate    string
+
+	Nodes        int    // GCHP nodes
+	TasksPerNode int    // GCHP MPI ranks per node
+	CPUs         int    // Classic OpenMP threads
+	Memory       string // Slurm --mem, e.g. 64G; empty uses the partition default
+	TimeLimit    time.Duration
+	Partition    string
+	Account      string
+	MPI          string // openmpi, intelmpi or mpich; empty guesses from the image tag
+
+	DataDir     string // Input data (ExtData) root
+	OutputDir   string
+	RestartFile string // Optional initial restart file
+	Modules     []string
+}
+
+// Validate checks the spec and fills in its defaults
+func (s *SlurmScriptSpec) Validate() error {
+	if s.Image == "" && s.SIF == "" {
+		return fmt.Errorf("an image or SIF file is required")
+	}
+	if s.Mode == "" {
+		s.Mode = ModeClassic
+	}
+	if s.Mode != ModeClassic && s.Mode != ModeGCHP {
+		return fmt.Errorf("unknown mode %s (expected %s or %s)", s.Mode, ModeClassic, ModeGCHP)
+	}
+	if s.Simulation == "" || s.Resolution == "" {
+		return fmt.Errorf("simulation and resolution are required")
+	}
+	if s.DataDir == "" {
+		return fmt.Errorf("an input data directory is required")
+	}
+	if s.OutputDir == "" {
+		s.OutputDir = "$SLURM_SUBMIT_DIR/output"
+	}
+	if s.SIF == "" {
+		s.SIF = path.Base(strings.ReplaceAll(s.Image, ":", "_")) + ".sif"
+	}
+	if s.TimeLimit <= 0 {
+		s.TimeLimit = defaultSlurmTimeLimit
+	}
+	if s.MPI == "" {
+		s.MPI = imageMPI(s.Image)
+	}
+	if _, ok := srunMPI[s.MPI]; !ok {
+		return fmt.Errorf("unknown MPI %s (expected openmpi, intelmpi or mpich)", s.MPI)
+	}
+
+	if s.Mode == ModeGCHP {
+		s.Nodes = max(s.Nodes, 1)
+		if s.TasksPerNode <= 0 {
+			return fmt.Errorf("GCHP needs MPI ranks per node")
+		}
+		// GCHP decomposes each of the cube's six faces across ranks
+		if s.Nodes*s.TasksPerNode%6 != 0 {
+			return fmt.Errorf("GCHP needs a multiple of 6 MPI ranks, got %d", s.Nodes*s.TasksPerNode)
+		}
+	} else if s.CPUs <= 0 {
+		return fmt.Errorf("classic runs need a CPU count for OpenMP")
+	}
+	return nil
+}
+
+// imageMPI guesses the MPI an image was built with from the MPI name build tags carry
+func imageMPI(image string) string {
+	tag := strings.ToLower(image[strings.LastIndex(image, "/")+1:])
+	for _, name := range []string{"intelmpi", "mpich"} {
+		if strings.Contains(tag, name) {
+			return name
+		}
+	}
+	return "openmpi"
+}
+
+// GenerateSlurmScript renders an sbatch script that runs the spec with Apptainer. Classic runs
+// are one OpenMP process on one node. GCHP runs prepare the run directory with the image's
+// scripts, then launch one container per rank with srun, the hybrid model that lets the
+// cluster's interconnect and Slurm's PMI bootstrap the image's MPI; the cluster's MPI must
+// be ABI compatible with the image's.
+func GenerateSlurmScript(spec SlurmScriptSpec) (string, error) {
+	if err := spec.Validate(); err != nil {
+		return "", err
+	}
+
+	var script strings.Builder
+	script.WriteString("#!/bin/bash\n")
+	fmt.Fprintf(&script, "#SBATCH --job-name=geoschem-%s-%s\n", spec.Simulation, spec.Resolution)
+	if spec.Mode == ModeGCHP {
+		fmt.Fprintf(&script, "#SBATCH --nodes=%d\n#SBATCH --ntasks-per-node=%d\n#SBATCH --cpus-per-task=1\n", spec.Nodes, spec.TasksPerNode)
+	} else {
+		fmt.Fprintf(&script, "#SBATCH --nodes=1\n#SBATCH --ntasks=1\n#SBATCH --cpus-per-task=%d\n", spec.CPUs)
+	}
+	if spec.Memory != "" {
+		fmt.Fprintf(&script, "#SBATCH --mem=%s\n", spec.Memory)
+	}
+	fmt.Fprintf(&script, "#SBATCH --time=%s\n", slurmDuration(spec.TimeLimit))
+	if spec.Partition != "" {
+		fmt.Fprintf(&script, "#SBATCH --partition=%s\n", spec.Partition)
+	}
+	if spec.Account != "" {
+		fmt.Fprintf(&script, "#SBATCH --account=%s\n", spec.Account)
+	}
+	script.WriteString("#SBATCH --output=geoschem-%j.log\n")
+	fmt.Fprintf(&script, "#\n# Generated by geoschem-aws for %s", spec.SIF)
+	if spec.Image != "" {
+		fmt.Fprintf(&script, " (%s)", spec.Image)
+	}
+	script.WriteString("\nset -euo pipefail\n\n")
+
+	for _, module := range spec.Modules {
+		fmt.Fprintf(&script, "module load %s\n", module)
+	}
+
+	fmt.Fprintf(&script, "SIF=%s\nDATA_DIR=%s\nOUTPUT_DIR=%s\n", shellWord(spec.SIF), shellWord(spec.DataDir), shellWord(spec.OutputDir))
+	script.WriteString("mkdir -p \"$OUTPUT_DIR\"\n\n")
+
+	if spec.Image != "" {
+		script.WriteString("# Pull the image once; later jobs reuse the SIF file\n")
+		script.WriteString("if [[ ! -f \"$SIF\" ]]; then\n")
+		if registry := strings.Split(spec.Image, "/")[0]; strings.Contains(registry, ".dkr.ecr.") {
+			script.WriteString("    # ECR needs AWS credentials on the cluster, or pull elsewhere and copy the SIF file\n")
+			script.WriteString("    export APPTAINER_DOCKER_USERNAME=AWS\n")
+			fmt.Fprintf(&script, "    export APPTAINER_DOCKER_PASSWORD=$(aws ecr get-login-password --region %s)\n", ecrRegion(registry))
+		}
+		fmt.Fprintf(&script, "    apptainer pull \"$SIF\" docker://%s\n", spec.Image)
+		script.WriteString("fi\n\n")
+	}
+
+	binds := "\"$DATA_DIR:/workspace/data,$OUTPUT_DIR:/workspace/output"
+	var restartArg string
+	if spec.RestartFile != "" {
+		fmt.Fprintf(&script, "RESTART_FILE=%s\n", shellWord(spec.RestartFile))
+		binds += ",$RESTART_FILE:/workspace/restart/restart.nc4:ro"
+		restartArg = " --restart-file /workspace/restart/restart.nc4"
+	}
+	binds += "\""
+
+	runArgs := fmt.Sprintf("--simulation %s --resolution %s", spec.Simulation, spec.Resolution)
+	if spec.StartDate != "" {
+		runArgs += " --start-date " + spec.StartDate
+	}
+	if spec.EndDate != "" {
+		runArgs += " --end-date " + spec.EndDate
+	}
+	runArgs += restartArg
+
+	if spec.Mode == ModeClassic {
+		script.WriteString("export APPTAINERENV_OMP_NUM_THREADS=$SLURM_CPUS_PER_TASK\n")
+		fmt.Fprintf(&script, "apptainer exec --cleanenv --bind %s \"$SIF\" %s classic %s\n", binds, entrypoint, runArgs)
+		return script.String(), nil
+	}
+
+	// The image's scripts may round the rank count to a decomposition they support, and
+	// report the count they configured
+	script.WriteString("# Prepare the run directory with the image's scripts, without running the model\n")
+	fmt.Fprintf(&script, "TASKS=$(apptainer exec --cleanenv --bind %s \"$SIF\" %s gchp %s --cores $SLURM_NTASKS --dry-run | tee /dev/stderr | "+
+		"sed -n 's/^Processor decomposition: .* = \\([0-9]*\\) cores$/\\1/p')\n", binds, entrypoint, runArgs)
+	script.WriteString("RUN_DIR=$(ls -dt \"$OUTPUT_DIR\"/gchp_* | head -1)\n\n")
+	fmt.Fprintf(&script, "# One container per rank; Slurm's %s bootstraps the image's %s\n", srunMPI[spec.MPI], spec.MPI)
+	script.WriteString("export APPTAINERENV_OMP_NUM_THREADS=1\n")
+	fmt.Fprintf(&script, "srun --mpi=%s --ntasks=\"${TASKS:-$SLURM_NTASKS}\" apptainer exec --cleanenv --bind %s --pwd \"/workspace/output/$(basename \"$RUN_DIR\")\" \"$SIF\" %s\n",
+		srunMPI[spec.MPI], binds, gchpExecutable)
+	return script.String(), nil
+}
+
+// shellWord quotes a value for the shell unless it starts with a variable, such as
+// $SLURM_SUBMIT_DIR, that must expand when the job runs
+func shellWord(value string) string {
+	if strings.HasPrefix(value, "$") {
+		return `"` + value + `"`
+	}
+	return shellQuote(value)
+}
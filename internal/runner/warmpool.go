@@ -0,0 +1,210 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// updateComputeEnvironmentTarget is the EventBridge Scheduler universal target for the
+// Batch UpdateComputeEnvironment API, so no Lambda sits between the schedule and Batch
+const updateComputeEnvironmentTarget = "arn:aws:scheduler:::aws-sdk:batch:updateComputeEnvironment"
+
+// WarmPool raises a Batch compute environment's minimum vCPUs during working hours with a
+// pair of EventBridge schedules, and drops it to zero outside them
+type WarmPool struct {
+	cli    *awscli.Client
+	config common.WarmPoolConfig
+}
+
+// WarmPoolStatus is the compute environment's current capacity and the schedules driving it
+type WarmPoolStatus struct {
+	ComputeEnvironment string
+	State              string
+	MinVCPUs           int
+	DesiredVCPUs       int
+	MaxVCPUs           int
+	Schedules          map[string]string // Schedule name to state, ENABLED or DISABLED; missing when not created
+}
+
+// NewWarmPool creates a warm pool from the config's runs.batch.warm_pool section
+func NewWarmPool(config *common.BuildConfig) (*WarmPool, error) {
+	warmPool := config.Runs.Batch.WarmPool
+	if !warmPool.Enabled() {
+		return nil, fmt.Errorf("no warm pool configured; set runs.batch.warm_pool.compute_environment")
+	}
+	if err := warmPool.Validate(); err != nil {
+		return nil, fmt.Errorf("runs.batch.%w", err)
+	}
+	return &WarmPool{
+		cli:    awscli.New(config.AWS.Profile, config.AWS.Region),
+		config: warmPool.WithDefaults(),
+	}, nil
+}
+
+// ScheduleNames returns the names of the schedules that raise and drop the minimum
+func (w *WarmPool) ScheduleNames() (start, stop string) {
+	// Schedule names are limited to 64 characters
+	name := w.config.ComputeEnvironment
+	if len(name) > 40 {
+		name = name[:40]
+	}
+	return "geoschem-warm-pool-" + name + "-start", "geoschem-warm-pool-" + name + "-stop"
+}
+
+// Apply creates or updates both schedules, then sets the minimum the schedule calls for now
+// so the pool doesn't wait for the next edge of the window
+func (w *WarmPool) Apply(ctx context.Context) error {
+	current, err := w.describe(ctx)
+	if err != nil {
+		return err
+	}
+	if w.config.MinVCPUs > current.MaxVCPUs {
+		return fmt.Errorf("warm_pool.min_vcpus %d exceeds the compute environment's maximum of %d vCPUs",
+			w.config.MinVCPUs, current.MaxVCPUs)
+	}
+
+	start, stop := w.ScheduleNames()
+	if err := w.putSchedule(ctx, start, w.config.StartCron(), w.config.MinVCPUs); err != nil {
+		return err
+	}
+	if err := w.putSchedule(ctx, stop, w.config.StopCron(), 0); err != nil {
+		return err
+	}
+
+	if w.config.Active(time.Now()) {
+		return w.SetMinimum(ctx, w.config.MinVCPUs)
+	}
+	return w.SetMinimum(ctx, 0)
+}
+
+// Remove deletes the schedules and drops the minimum to zero
+func (w *WarmPool) Remove(ctx context.Context) error {
+	start, stop := w.ScheduleNames()
+	for _, name := range []string{start, stop} {
+		err := w.cli.Run(ctx, nil, "scheduler", "delete-schedule", "--name", name)
+		if err != nil && !strings.Contains(err.Error(), "ResourceNotFoundException") {
+			return fmt.Errorf("deleting schedule %s: %w", name, err)
+		}
+	}
+	return w.SetMinimum(ctx, 0)
+}
+
+// SetMinimum sets the compute environment's minimum vCPUs now; Batch raises the desired
+// vCPUs to match, which launches instances
+func (w *WarmPool) SetMinimum(ctx context.Context, vcpus int) error {
+	if err := w.cli.Run(ctx, nil, "batch", "update-compute-environment",
+		"--compute-environment", w.config.ComputeEnvironment,
+		"--compute-resources", fmt.Sprintf("minvCpus=%d", vcpus)); err != nil {
+		return fmt.Errorf("setting minimum vCPUs of %s: %w", w.config.ComputeEnvironment, err)
+	}
+	return nil
+}
+
+// Status returns the compute environment's capacity and the state of both schedules
+func (w *WarmPool) Status(ctx context.Context) (*WarmPoolStatus, error) {
+	status, err := w.describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start, stop := w.ScheduleNames()
+	for _, name := range []string{start, stop} {
+		var schedule struct {
+			State string `json:"State"`
+		}
+		err := w.cli.Run(ctx, &schedule, "scheduler", "get-schedule", "--name", name)
+		if err != nil && strings.Contains(err.Error(), "ResourceNotFoundException") {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading schedule %s: %w", name, err)
+		}
+		status.Schedules[name] = schedule.State
+	}
+	return status, nil
+}
+
+// Config returns the warm pool settings with defaults applied
+func (w *WarmPool) Config() common.WarmPoolConfig {
+	return w.config
+}
+
+func (w *WarmPool) describe(ctx context.Context) (*WarmPoolStatus, error) {
+	var out struct {
+		ComputeEnvironments []struct {
+			State            string `json:"state"`
+			ComputeResources struct {
+				Type         string `json:"type"`
+				MinVCPUs     int    `json:"minvCpus"`
+				DesiredVCPUs int    `json:"desiredvCpus"`
+				MaxVCPUs     int    `json:"maxvCpus"`
+			} `json:"computeResources"`
+		} `json:"computeEnvironments"`
+	}
+	if err := w.cli.Run(ctx, &out, "batch", "describe-compute-environments",
+		"--compute-environments", w.config.ComputeEnvironment); err != nil {
+		return nil, fmt.Errorf("reading compute environment %s: %w", w.config.ComputeEnvironment, err)
+	}
+	if len(out.ComputeEnvironments) == 0 {
+		return nil, fmt.Errorf("compute environment %s doesn't exist", w.config.ComputeEnvironment)
+	}
+
+	environment := out.ComputeEnvironments[0]
+	switch environment.ComputeResources.Type {
+	case "EC2", "SPOT":
+	default:
+		// Fargate environments have no instances to keep warm
+		return nil, fmt.Errorf("compute environment %s is %s; warm pools need a managed EC2 or Spot environment",
+			w.config.ComputeEnvironment, environment.ComputeResources.Type)
+	}
+	return &WarmPoolStatus{
+		ComputeEnvironment: w.config.ComputeEnvironment,
+		State:              environment.State,
+		MinVCPUs:           environment.ComputeResources.MinVCPUs,
+		DesiredVCPUs:       environment.ComputeResources.DesiredVCPUs,
+		MaxVCPUs:           environment.ComputeResources.MaxVCPUs,
+		Schedules:          make(map[string]string),
+	}, nil
+}
+
+// putSchedule creates a schedule that sets the minimum vCPUs, or updates it in place
+func (w *WarmPool) putSchedule(ctx context.Context, name, expression string, vcpus int) error {
+	input, err := json.Marshal(map[string]interface{}{
+		"ComputeEnvironment": w.config.ComputeEnvironment,
+		"ComputeResources":   map[string]int{"MinvCpus": vcpus},
+	})
+	if err != nil {
+		return err
+	}
+	target, err := json.Marshal(map[string]string{
+		"Arn":     updateComputeEnvironmentTarget,
+		"RoleArn": w.config.SchedulerRoleARN,
+		"Input":   string(input),
+	})
+	if err != nil {
+		return err
+	}
+
+	args := []string{
+		"--name", name,
+		"--schedule-expression", expression,
+		"--schedule-expression-timezone", w.config.Timezone,
+		"--flexible-time-window", "Mode=OFF",
+		"--target", string(target),
+		"--description", fmt.Sprintf("Set minimum vCPUs of %s to %d", w.config.ComputeEnvironment, vcpus),
+	}
+	err = w.cli.Run(ctx, nil, append([]string{"scheduler", "create-schedule"}, args...)...)
+	if err != nil && strings.Contains(err.Error(), "ConflictException") {
+		err = w.cli.Run(ctx, nil, append([]string{"scheduler", "update-schedule"}, args...)...)
+	}
+	if err != nil {
+		return fmt.Errorf("scheduling %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Package shutdown cancels work on SIGINT or SIGTERM and runs registered cleanups on a fresh
+// context, so an interrupted command still removes what it created in AWS.
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Timeout bounds each cleanup, which includes waiting for AWS to confirm it
+const Timeout = 10 * time.Minute
+
+// exitInterrupted is the conventional exit status after SIGINT
+const exitInterrupted = 130
+
+type handlerKey struct{}
+
+// task is a registered cleanup. It runs at most once, whether from the handler or its owner.
+type task struct {
+	name string
+	fn   func(ctx context.Context) error
+	once sync.Once
+	err  error
+}
+
+func (t *task) run() error {
+	t.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+		defer cancel()
+		t.err = t.fn(ctx)
+	})
+	return t.err
+}
+
+// Handler traps interrupts for a command. The first signal cancels the command's context
+// and starts the registered cleanups while the command unwinds; a second signal exits
+// immediately, naming the cleanups that had not finished.
+type Handler struct {
+	cancel  context.CancelFunc
+	signals chan os.Signal
+
+	mu       sync.Mutex
+	next     int
+	tasks    map[int]*task
+	cleaning sync.Mutex // Held while cleanups run, so Fatalf waits for an interrupt's cleanup
+}
+
+// Trap returns a context cancelled on SIGINT or SIGTERM and the handler that owns it.
+// Call Stop when the command finishes.
+func Trap(parent context.Context) (context.Context, *Handler) {
+	ctx, cancel := context.WithCancel(parent)
+	h := &Handler{
+		cancel:  cancel,
+		signals: make(chan os.Signal, 2),
+		tasks:   make(map[int]*task),
+	}
+	signal.Notify(h.signals, syscall.SIGINT, syscall.SIGTERM)
+	go h.watch()
+	return context.WithValue(ctx, handlerKey{}, h), h
+}
+
+func (h *Handler) watch() {
+	sig, ok := <-h.signals
+	if !ok {
+		return
+	}
+	fmt.Printf("\n⚠️  Received %s, cleaning up (interrupt again to exit without waiting)...\n", sig)
+	h.cancel()
+	go h.Cleanup()
+
+	if _, ok := <-h.signals; !ok {
+		return
+	}
+	if pending := h.pending(); len(pending) > 0 {
+		fmt.Printf("\n⚠️  Exiting before cleanup finished; check these are removed: %v\n", pending)
+		fmt.Println("💡 Run 'geoschem-aws cleanup' to remove recorded leftovers")
+	}
+	os.Exit(exitInterrupted)
+}
+
+// Stop stops trapping signals and releases the context
+func (h *Handler) Stop() {
+	signal.Stop(h.signals)
+	close(h.signals)
+	h.cancel()
+}
+
+// Register adds a cleanup to run if the command is interrupted or exits through Fatalf.
+// The returned function runs it now, or waits for the run the handler already started,
+// and returns its error; owners call it when they are done with the resource. Without a
+// handler in ctx the cleanup only runs when the owner calls it.
+func Register(ctx context.Context, name string, fn func(ctx context.Context) error) func() error {
+	t := &task{name: name, fn: fn}
+	h, ok := ctx.Value(handlerKey{}).(*Handler)
+	if !ok {
+		return t.run
+	}
+
+	h.mu.Lock()
+	id := h.next
+	h.next++
+	h.tasks[id] = t
+	h.mu.Unlock()
+
+	return func() error {
+		err := t.run()
+		h.mu.Lock()
+		delete(h.tasks, id)
+		h.mu.Unlock()
+		return err
+	}
+}
+
+// Cleanup runs every registered cleanup concurrently on fresh contexts and waits for them
+func (h *Handler) Cleanup() {
+	h.cleaning.Lock()
+	defer h.cleaning.Unlock()
+
+	h.mu.Lock()
+	tasks := make(map[int]*task, len(h.tasks))
+	for id, t := range h.tasks {
+		tasks[id] = t
+	}
+	h.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for id, t := range tasks {
+		wg.Add(1)
+		go func(id int, t *task) {
+			defer wg.Done()
+			if err := t.run(); err != nil {
+				fmt.Printf("⚠️  Cleaning up %s failed: %v\n", t.name, err)
+			}
+			h.mu.Lock()
+			delete(h.tasks, id)
+			h.mu.Unlock()
+		}(id, t)
+	}
+	wg.Wait()
+}
+
+// Fatalf logs like log.Fatalf, but cleans up before exiting
+func (h *Handler) Fatalf(format string, args ...interface{}) {
+	log.Printf(format, args...)
+	h.Cleanup()
+	os.Exit(1)
+}
+
+// pending names the cleanups that have not finished
+func (h *Handler) pending() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var names []string
+	for _, t := range h.tasks {
+		names = append(names, t.name)
+	}
+	sort.Strings(names)
+	return names
+}
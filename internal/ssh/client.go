@@ -2,10 +2,13 @@ package ssh
 
 import (
 	"context"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -14,35 +17,68 @@ import (
 	"time"
 
 	"golang.org/x/crypto/ssh"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+	"github.com/scttfrdmn/geoschem-aws/internal/redact"
 )
 
 type Client struct {
-	client *ssh.Client
-	config *ssh.ClientConfig
+	client        *ssh.Client
+	config        *ssh.ClientConfig
+	beforeConnect func(ctx context.Context) error
+	host          string // Last host connected to, for reconnecting
+	retryAttempts int    // Connection attempts by WaitForConnection
+	retryInterval time.Duration
+	streams       chan struct{}     // Upload slots; see SetTransferLimits
+	bandwidth     *bandwidthLimiter // Shared upload cap, or nil when uncapped
 }
 
+// Default connection retry policy: about five minutes
+const (
+	defaultRetryAttempts = 30
+	defaultRetryInterval = 10 * time.Second
+)
+
 type KeyPair struct {
 	PrivateKey string
 	PublicKey  string
 	KeyName    string
 }
 
-// GenerateKeyPair creates a new RSA key pair for SSH access
-func GenerateKeyPair(keyName string) (*KeyPair, error) {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return nil, fmt.Errorf("generating private key: %w", err)
-	}
-
-	// Encode private key to PEM format
-	privateKeyPEM := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+// GenerateKeyPair creates a new key pair for SSH access of the key type: ed25519 (the
+// default when empty) or rsa4096
+func GenerateKeyPair(keyName, keyType string) (*KeyPair, error) {
+	var signer crypto.Signer
+	var privateKeyPEM *pem.Block
+	switch keyType {
+	case common.KeyTypeEd25519, "":
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generating private key: %w", err)
+		}
+		// PKCS#1 has no ed25519 form; OpenSSH's own format is what ssh-keygen writes
+		privateKeyPEM, err = ssh.MarshalPrivateKey(privateKey, keyName)
+		if err != nil {
+			return nil, fmt.Errorf("encoding private key: %w", err)
+		}
+		signer = privateKey
+	case common.KeyTypeRSA4096:
+		privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
+		if err != nil {
+			return nil, fmt.Errorf("generating private key: %w", err)
+		}
+		privateKeyPEM = &pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}
+		signer = privateKey
+	default:
+		return nil, fmt.Errorf("unknown key type '%s' (expected ed25519 or rsa4096)", keyType)
 	}
 	privateKeyBytes := pem.EncodeToMemory(privateKeyPEM)
 
 	// Generate public key in SSH format
-	publicKey, err := ssh.NewPublicKey(&privateKey.PublicKey)
+	publicKey, err := ssh.NewPublicKey(signer.Public())
 	if err != nil {
 		return nil, fmt.Errorf("generating public key: %w", err)
 	}
@@ -63,6 +99,10 @@ func SaveKeyPairToFile(keyPair *KeyPair, privateKeyPath string) error {
 	if err != nil {
 		return fmt.Errorf("saving private key: %w", err)
 	}
+	// WriteFile keeps the mode of a file that already existed
+	if err := os.Chmod(privateKeyPath, 0600); err != nil {
+		return fmt.Errorf("restricting private key: %w", err)
+	}
 
 	// Save public key
 	publicKeyPath := privateKeyPath + ".pub"
@@ -76,19 +116,36 @@ func SaveKeyPairToFile(keyPair *KeyPair, privateKeyPath string) error {
 
 // NewClient creates a new SSH client
 func NewClient(host, user, privateKeyPath string) (*Client, error) {
+	privateKeyPath, err := common.ExpandHome(privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
 	// Read private key
 	key, err := os.ReadFile(privateKeyPath)
 	if err != nil {
 		return nil, fmt.Errorf("reading private key: %w", err)
 	}
 
-	// Parse private key
+	// Parse private key, decrypting it with the configured passphrase when it is encrypted
 	signer, err := ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		passphrase := os.Getenv(PassphraseEnv)
+		if passphrase == "" {
+			return nil, fmt.Errorf("private key %s is encrypted; set %s", privateKeyPath, PassphraseEnv)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	}
 	if err != nil {
 		return nil, fmt.Errorf("parsing private key: %w", err)
 	}
 
-	// SSH client configuration
+	return newClientWithSigner(user, signer), nil
+}
+
+// newClientWithSigner creates a client authenticating with a parsed private key
+func newClientWithSigner(user string, signer ssh.Signer) *Client {
 	config := &ssh.ClientConfig{
 		User: user,
 		Auth: []ssh.AuthMethod{
@@ -99,12 +156,34 @@ func NewClient(host, user, privateKeyPath string) (*Client, error) {
 	}
 
 	return &Client{
-		config: config,
-	}, nil
+		config:        config,
+		retryAttempts: defaultRetryAttempts,
+		retryInterval: defaultRetryInterval,
+		streams:       make(chan struct{}, common.DefaultTransferStreams),
+	}
+}
+
+// SetRetry sets how many connection attempts WaitForConnection makes and how long it pauses
+// between them, including when reconnecting to a detached command
+func (c *Client) SetRetry(attempts int, interval time.Duration) {
+	c.retryAttempts = attempts
+	c.retryInterval = interval
+}
+
+// SetBeforeConnect registers a hook run before every connection attempt, e.g. to push a
+// short-lived key with EC2 Instance Connect
+func (c *Client) SetBeforeConnect(hook func(ctx context.Context) error) {
+	c.beforeConnect = hook
 }
 
 // Connect establishes SSH connection to the host
 func (c *Client) Connect(ctx context.Context, host string) error {
+	if c.beforeConnect != nil {
+		if err := c.beforeConnect(ctx); err != nil {
+			return err
+		}
+	}
+
 	// Add default SSH port if not specified
 	if !strings.Contains(host, ":") {
 		host = host + ":22"
@@ -132,12 +211,14 @@ func (c *Client) Connect(ctx context.Context, host string) error {
 	}
 
 	c.client = ssh.NewClient(sshConn, chans, reqs)
+	c.host = host
 	return nil
 }
 
-// WaitForConnection waits until SSH connection is available
-func (c *Client) WaitForConnection(ctx context.Context, host string, maxRetries int) error {
+// WaitForConnection waits until SSH connection is available, retrying as set by SetRetry
+func (c *Client) WaitForConnection(ctx context.Context, host string) error {
 	var lastErr error
+	maxRetries := c.retryAttempts
 	
 	for i := 0; i < maxRetries; i++ {
 		select {
@@ -158,14 +239,15 @@ func (c *Client) WaitForConnection(ctx context.Context, host string, maxRetries
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(10 * time.Second):
+		case <-time.After(c.retryInterval):
 		}
 	}
 
 	return fmt.Errorf("failed to establish SSH connection after %d attempts: %w", maxRetries, lastErr)
 }
 
-// ExecuteCommand runs a command over SSH
+// ExecuteCommand runs a command over SSH and returns its combined output. Output beyond
+// 128 KB is returned as its head and tail, with the full output spooled to a local file.
 func (c *Client) ExecuteCommand(ctx context.Context, command string) (string, error) {
 	if c.client == nil {
 		return "", fmt.Errorf("SSH client not connected")
@@ -177,10 +259,13 @@ func (c *Client) ExecuteCommand(ctx context.Context, command string) (string, er
 	}
 	defer session.Close()
 
-	// Set up output capture
-	var output strings.Builder
-	session.Stdout = &output
-	session.Stderr = &output
+	// Capture output with bounded memory; long output is spooled to disk with its
+	// credentials masked
+	output := &spoolWriter{}
+	defer output.Close()
+	redacted := redact.NewWriter(output)
+	session.Stdout = redacted
+	session.Stderr = redacted
 
 	// Execute command with context
 	done := make(chan error, 1)
@@ -193,6 +278,7 @@ func (c *Client) ExecuteCommand(ctx context.Context, command string) (string, er
 		session.Signal(ssh.SIGKILL)
 		return "", ctx.Err()
 	case err := <-done:
+		redacted.Flush()
 		if err != nil {
 			return output.String(), fmt.Errorf("command failed: %w", err)
 		}
@@ -200,8 +286,16 @@ func (c *Client) ExecuteCommand(ctx context.Context, command string) (string, er
 	}
 }
 
-// ExecuteCommandStream runs a command and streams output
+// ExecuteCommandStream runs a command and streams output with its credentials masked
 func (c *Client) ExecuteCommandStream(ctx context.Context, command string, stdout, stderr io.Writer) error {
+	redactedOut, redactedErr := redact.NewWriter(stdout), redact.NewWriter(stderr)
+	defer redactedOut.Flush()
+	defer redactedErr.Flush()
+	return c.executeStream(ctx, command, redactedOut, redactedErr)
+}
+
+// executeStream runs a command and streams its output unchanged
+func (c *Client) executeStream(ctx context.Context, command string, stdout, stderr io.Writer) error {
 	if c.client == nil {
 		return fmt.Errorf("SSH client not connected")
 	}
@@ -231,17 +325,24 @@ func (c *Client) ExecuteCommandStream(ctx context.Context, command string, stdou
 	}
 }
 
-// UploadFile uploads a file via SCP-like functionality
+// UploadFile uploads a file via SCP-like functionality, within the transfer limits
 func (c *Client) UploadFile(ctx context.Context, localPath, remotePath string) error {
 	if c.client == nil {
 		return fmt.Errorf("SSH client not connected")
 	}
 
-	// Read local file
-	content, err := os.ReadFile(localPath)
+	// Stream the local file rather than holding it in memory
+	file, err := os.Open(localPath)
 	if err != nil {
 		return fmt.Errorf("reading local file: %w", err)
 	}
+	defer file.Close()
+
+	release, err := c.acquireStream(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	// Create file on remote system
 	command := fmt.Sprintf("cat > %s", remotePath)
@@ -251,7 +352,7 @@ func (c *Client) UploadFile(ctx context.Context, localPath, remotePath string) e
 	}
 	defer session.Close()
 
-	session.Stdin = strings.NewReader(string(content))
+	session.Stdin = c.throttle(ctx, file)
 
 	done := make(chan error, 1)
 	go func() {
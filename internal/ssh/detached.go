@@ -0,0 +1,143 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/artifacts"
+	"github.com/scttfrdmn/geoschem-aws/internal/redact"
+)
+
+// sessionDir holds each detached command's script, output, and exit status under the login
+// user's home directory
+const sessionDir = ".geoschem-sessions"
+
+var detachedSequence uint64
+
+// ExecuteDetached runs a command in a named tmux session on the host, or in its own process
+// group where tmux isn't installed, and streams its combined output to stdout. The command
+// doesn't depend on the connection: when it drops, the client reconnects and resumes the
+// output where it stopped. Cancelling ctx kills the command.
+func (c *Client) ExecuteDetached(ctx context.Context, command string, stdout io.Writer) error {
+	if c.client == nil {
+		return fmt.Errorf("SSH client not connected")
+	}
+
+	name := fmt.Sprintf("geoschem-%d-%d", time.Now().Unix(), atomic.AddUint64(&detachedSequence, 1))
+	dir := sessionDir + "/" + name
+
+	if err := c.runWithInput(ctx, fmt.Sprintf("mkdir -p ~/%[1]s && cat > ~/%[1]s/run.sh", dir), "cd ~\n"+command+"\n"); err != nil {
+		return fmt.Errorf("writing command script: %w", err)
+	}
+
+	wrapped := "bash run.sh > output.log 2>&1; echo $? > exit-status"
+	start := fmt.Sprintf("cd ~/%s && if command -v tmux >/dev/null 2>&1; then tmux new-session -d -s %s %s; "+
+		"else setsid nohup bash -c %s >/dev/null 2>&1 < /dev/null & echo $! > pid; fi",
+		dir, name, shellQuote(wrapped), shellQuote(wrapped))
+	if output, err := c.ExecuteCommand(ctx, start); err != nil {
+		return fmt.Errorf("starting detached command: %w, output: %s", err, output)
+	}
+	fmt.Printf("(running in session %s; attach on the instance with: tmux attach -t %s)\n", name, name)
+
+	// Follow the output until the exit status appears, then give tail a moment to flush.
+	// Raw bytes are counted so a resumed tail starts where the last one stopped.
+	redacted := redact.NewWriter(stdout)
+	defer redacted.Flush()
+	written := &countingWriter{w: redacted}
+	for {
+		follow := fmt.Sprintf("cd ~/%s && tail -c +%d -F output.log 2>/dev/null & t=$!; "+
+			"while [ ! -f ~/%s/exit-status ]; do sleep 2; done; sleep 2; kill $t",
+			dir, written.n+1, dir)
+		err := c.executeStream(ctx, follow, written, io.Discard)
+		if ctx.Err() != nil {
+			c.killDetached(name, dir)
+			return ctx.Err()
+		}
+		var exitErr *ssh.ExitError
+		if err == nil || errors.As(err, &exitErr) {
+			break
+		}
+
+		fmt.Printf("\n⚠️  Connection lost (%v); reconnecting, %s keeps running...\n", err, name)
+		if err := c.reconnect(ctx); err != nil {
+			return fmt.Errorf("reconnecting to detached command %s: %w", name, err)
+		}
+	}
+
+	output, err := c.ExecuteCommand(ctx, fmt.Sprintf("cat ~/%s/exit-status", dir))
+	if err != nil {
+		return fmt.Errorf("reading exit status of %s: %w", name, err)
+	}
+	status := strings.TrimSpace(output)
+	if status != "0" {
+		return fmt.Errorf("command failed with exit status %s (output kept in ~/%s/output.log)", status, dir)
+	}
+	if remove := artifacts.RemoteCommand("~/" + dir); remove != "" {
+		c.ExecuteCommand(ctx, remove)
+	}
+	return nil
+}
+
+// killDetached stops a detached command after its caller gave up on it
+func (c *Client) killDetached(name, dir string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	c.ExecuteCommand(ctx, fmt.Sprintf("tmux kill-session -t %s 2>/dev/null; [ -f ~/%s/pid ] && kill -- -$(cat ~/%s/pid) 2>/dev/null; true", name, dir, dir))
+}
+
+// reconnect replaces a dropped connection to the same host
+func (c *Client) reconnect(ctx context.Context) error {
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+	}
+	return c.WaitForConnection(ctx, c.host)
+}
+
+// runWithInput runs a command with input on its stdin
+func (c *Client) runWithInput(ctx context.Context, command, input string) error {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("creating session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdin = strings.NewReader(input)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(command)
+	}()
+
+	select {
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		return ctx.Err()
+	case err := <-done:
+		return err
+	}
+}
+
+// countingWriter counts the bytes written through it, so output can resume after a reconnect
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// shellQuote single-quotes a value for a POSIX shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
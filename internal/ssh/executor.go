@@ -0,0 +1,21 @@
+package ssh
+
+import (
+	"context"
+	"io"
+)
+
+// Executor runs commands and copies files on a remote instance. Client implements it over
+// SSH and SSMClient over SSM Run Command, so callers can drive an instance either way.
+type Executor interface {
+	ExecuteCommand(ctx context.Context, command string) (string, error)
+	ExecuteCommandStream(ctx context.Context, command string, stdout, stderr io.Writer) error
+	UploadFile(ctx context.Context, localPath, remotePath string) error
+	TestConnection(ctx context.Context) error
+	Close() error
+}
+
+var (
+	_ Executor = (*Client)(nil)
+	_ Executor = (*SSMClient)(nil)
+)
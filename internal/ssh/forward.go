@@ -0,0 +1,58 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Forward listens on localAddr and tunnels each connection to remoteAddr as seen from the
+// host, like ssh -L, until ctx is cancelled
+func (c *Client) Forward(ctx context.Context, localAddr, remoteAddr string) error {
+	if c.client == nil {
+		return fmt.Errorf("SSH client not connected")
+	}
+
+	listener, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", localAddr, err)
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		local, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("accepting on %s: %w", localAddr, err)
+		}
+		go c.tunnel(local, remoteAddr)
+	}
+}
+
+// tunnel copies one forwarded connection in both directions until either side closes
+func (c *Client) tunnel(local net.Conn, remoteAddr string) {
+	defer local.Close()
+	remote, err := c.client.Dial("tcp", remoteAddr)
+	if err != nil {
+		fmt.Printf("Warning: forwarding to %s failed: %v\n", remoteAddr, err)
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
@@ -0,0 +1,36 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+)
+
+// InstanceConnect pushes public keys with EC2 Instance Connect. A pushed key is accepted
+// for 60 seconds, long enough to authenticate, so no key pair is imported into the account.
+type InstanceConnect struct {
+	cli *awscli.Client
+}
+
+// NewInstanceConnect creates an Instance Connect client for the given AWS profile and region
+func NewInstanceConnect(profile, region string) *InstanceConnect {
+	return &InstanceConnect{cli: awscli.New(profile, region)}
+}
+
+// SendPublicKey authorizes publicKey for osUser on the instance for the next 60 seconds
+func (ic *InstanceConnect) SendPublicKey(ctx context.Context, instanceID, osUser, publicKey string) error {
+	var out struct {
+		Success bool `json:"Success"`
+	}
+	if err := ic.cli.Run(ctx, &out, "ec2-instance-connect", "send-ssh-public-key",
+		"--instance-id", instanceID,
+		"--instance-os-user", osUser,
+		"--ssh-public-key", publicKey); err != nil {
+		return fmt.Errorf("pushing SSH key with EC2 Instance Connect: %w", err)
+	}
+	if !out.Success {
+		return fmt.Errorf("EC2 Instance Connect rejected the SSH key for %s", instanceID)
+	}
+	return nil
+}
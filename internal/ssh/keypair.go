@@ -2,33 +2,223 @@ package ssh
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// Ephemeral key pairs are tagged with this purpose and an expiry so the janitor can sweep them
+const (
+	ephemeralKeyPurpose = "builder-ssh-ephemeral"
+	expiresAtTag        = "ExpiresAt"
 )
 
+// EphemeralKeyTTL is how long a per-build key pair may outlive its build before it is swept
+const EphemeralKeyTTL = 24 * time.Hour
+
 type KeyPairManager struct {
 	ec2Client *ec2.Client
+	keyStore  *KeyStore // Where per-build private keys are kept
+	keyType   string    // Type of generated keys
 }
 
-// NewKeyPairManager creates a new key pair manager
+// NewKeyPairManager creates a new key pair manager that keeps per-build keys in files
 func NewKeyPairManager(ec2Client *ec2.Client) *KeyPairManager {
 	return &KeyPairManager{
 		ec2Client: ec2Client,
+		keyStore:  fileKeyStore(),
+		keyType:   common.KeyTypeEd25519,
 	}
 }
 
+// SetKeyStore sets where per-build private keys are kept and removed from
+func (kpm *KeyPairManager) SetKeyStore(keyStore *KeyStore) {
+	kpm.keyStore = keyStore
+}
+
+// SetKeyType sets the type of key pairs the manager generates: ed25519 or rsa4096
+func (kpm *KeyPairManager) SetKeyType(keyType string) error {
+	if err := (common.AWSConfig{KeyType: keyType}).ValidateKeyType(); err != nil {
+		return err
+	}
+	kpm.keyType = keyType
+	return nil
+}
+
+// DefaultKeyDir returns the private directory for generated keys (keys under common.DataDir)
+func DefaultKeyDir() (string, error) {
+	dir, err := common.PrivateDir("keys")
+	if err != nil {
+		return "", fmt.Errorf("creating key directory: %w", err)
+	}
+	return dir, nil
+}
+
 // CreateKeyPair creates a new key pair in AWS and returns the private key
 func (kpm *KeyPairManager) CreateKeyPair(ctx context.Context, keyName string) (*KeyPair, error) {
+	return kpm.importKeyPair(ctx, keyName, []types.Tag{
+		{Key: aws.String("Purpose"), Value: aws.String("builder-ssh")},
+	})
+}
+
+// UniqueKeyName returns prefix with the current user, a timestamp and a random suffix, unique
+// per build and recognizable by owner in a shared account
+func UniqueKeyName(prefix string) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("generating key name: %w", err)
+	}
+	return fmt.Sprintf("%s-%s-%s-%s", prefix, common.CurrentUser(), time.Now().UTC().Format("20060102-150405"), hex.EncodeToString(suffix)), nil
+}
+
+// CreateEphemeralKeyPair creates a uniquely named key pair for a single build and saves the
+// private key in the key store. It returns the key name and where the private key is kept.
+func (kpm *KeyPairManager) CreateEphemeralKeyPair(ctx context.Context, prefix string) (string, string, error) {
+	keyName, err := UniqueKeyName(prefix)
+	if err != nil {
+		return "", "", err
+	}
+
+	keyPair, err := kpm.importKeyPair(ctx, keyName, []types.Tag{
+		{Key: aws.String("Purpose"), Value: aws.String(ephemeralKeyPurpose)},
+		{Key: aws.String(expiresAtTag), Value: aws.String(time.Now().Add(EphemeralKeyTTL).UTC().Format(time.RFC3339))},
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	keyRef, err := kpm.keyStore.Save(ctx, keyPair)
+	if err != nil {
+		if deleteErr := kpm.DeleteKeyPair(ctx, keyName); deleteErr != nil {
+			return "", "", fmt.Errorf("saving private key: %w (and removing %s from AWS failed: %v)", err, keyName, deleteErr)
+		}
+		return "", "", fmt.Errorf("saving private key: %w", err)
+	}
+
+	return keyName, keyRef, nil
+}
+
+// DeleteEphemeralKeyPair removes a per-build key pair from AWS and its stored private key
+func (kpm *KeyPairManager) DeleteEphemeralKeyPair(ctx context.Context, keyName string) error {
+	if err := kpm.DeleteKeyPair(ctx, keyName); err != nil {
+		return err
+	}
+
+	return kpm.keyStore.Remove(ctx, keyName)
+}
+
+// CreateLocalKey generates a key pair that is never imported into AWS, for keys pushed with
+// EC2 Instance Connect, and saves it in the key store. It returns the key and where the
+// private key is kept.
+func (ks *KeyStore) CreateLocalKey(ctx context.Context, keyName, keyType string) (*KeyPair, string, error) {
+	keyPair, err := GenerateKeyPair(keyName, keyType)
+	if err != nil {
+		return nil, "", fmt.Errorf("generating key pair: %w", err)
+	}
+	keyRef, err := ks.Save(ctx, keyPair)
+	if err != nil {
+		return nil, "", err
+	}
+	return keyPair, keyRef, nil
+}
+
+// RemoveLocalKey deletes a generated key's files from DefaultKeyDir
+func RemoveLocalKey(keyName string) error {
+	keyDir, err := DefaultKeyDir()
+	if err != nil {
+		return err
+	}
+	privateKeyPath := filepath.Join(keyDir, keyName+".pem")
+	for _, path := range []string{privateKeyPath, privateKeyPath + ".pub"} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// StaleLocalKeys lists keys in DefaultKeyDir whose names start with prefix and that were
+// generated more than EphemeralKeyTTL ago, such as Instance Connect keys left by a command
+// that was killed before it cleaned up
+func StaleLocalKeys(prefix string) ([]string, error) {
+	keyDir, err := DefaultKeyDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(keyDir, prefix+"*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err == nil && time.Since(info.ModTime()) > EphemeralKeyTTL {
+			stale = append(stale, strings.TrimSuffix(filepath.Base(path), ".pem"))
+		}
+	}
+	return stale, nil
+}
+
+// ExpiredEphemeralKeyPairs lists the current user's per-build key pairs whose expiry has passed
+func (kpm *KeyPairManager) ExpiredEphemeralKeyPairs(ctx context.Context) ([]string, error) {
+	return kpm.expiredEphemeralKeyPairs(ctx, types.Filter{Name: aws.String("tag:Owner"), Values: []string{common.CurrentUser()}})
+}
+
+// AllExpiredEphemeralKeyPairs lists every user's expired per-build key pairs, for sweeping
+// the account rather than one user's leftovers
+func (kpm *KeyPairManager) AllExpiredEphemeralKeyPairs(ctx context.Context) ([]string, error) {
+	return kpm.expiredEphemeralKeyPairs(ctx)
+}
+
+func (kpm *KeyPairManager) expiredEphemeralKeyPairs(ctx context.Context, filters ...types.Filter) ([]string, error) {
+	result, err := kpm.ec2Client.DescribeKeyPairs(ctx, &ec2.DescribeKeyPairsInput{
+		Filters: append([]types.Filter{
+			{Name: aws.String("tag:Project"), Values: []string{"geoschem-aws"}},
+			{Name: aws.String("tag:Purpose"), Values: []string{ephemeralKeyPurpose}},
+		}, filters...),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing key pairs: %w", err)
+	}
+
+	var expired []string
+	for _, keyPair := range result.KeyPairs {
+		for _, tag := range keyPair.Tags {
+			if aws.ToString(tag.Key) != expiresAtTag {
+				continue
+			}
+			expiresAt, err := time.Parse(time.RFC3339, aws.ToString(tag.Value))
+			if err == nil && time.Now().After(expiresAt) {
+				expired = append(expired, aws.ToString(keyPair.KeyName))
+			}
+		}
+	}
+	return expired, nil
+}
+
+// importKeyPair generates a key pair locally and imports its public key to AWS
+func (kpm *KeyPairManager) importKeyPair(ctx context.Context, keyName string, tags []types.Tag) (*KeyPair, error) {
 	// Generate local key pair first
-	keyPair, err := GenerateKeyPair(keyName)
+	keyPair, err := GenerateKeyPair(keyName, kpm.keyType)
 	if err != nil {
 		return nil, fmt.Errorf("generating key pair: %w", err)
 	}
+	if err := checkImportable(keyPair.PublicKey); err != nil {
+		return nil, err
+	}
 
 	// Import public key to AWS
 	input := &ec2.ImportKeyPairInput{
@@ -37,23 +227,53 @@ func (kpm *KeyPairManager) CreateKeyPair(ctx context.Context, keyName string) (*
 		TagSpecifications: []types.TagSpecification{
 			{
 				ResourceType: types.ResourceTypeKeyPair,
-				Tags: []types.Tag{
+				Tags: append([]types.Tag{
 					{Key: aws.String("Name"), Value: aws.String(keyName)},
 					{Key: aws.String("Project"), Value: aws.String("geoschem-aws")},
-					{Key: aws.String("Purpose"), Value: aws.String("builder-ssh")},
-				},
+					{Key: aws.String("Owner"), Value: aws.String(common.CurrentUser())},
+				}, tags...),
 			},
 		},
 	}
 
 	_, err = kpm.ec2Client.ImportKeyPair(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("importing key pair to AWS: %w", err)
+		return nil, fmt.Errorf("importing %s key pair to AWS: %w", kpm.keyType, err)
 	}
 
 	return keyPair, nil
 }
 
+// checkImportable checks that EC2 accepts a public key for import: ed25519, or RSA of
+// 2048, 3072 or 4096 bits. ImportKeyPair's own error names neither the type nor the size.
+func checkImportable(authorizedKey string) error {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		return fmt.Errorf("parsing generated public key: %w", err)
+	}
+	switch publicKey.Type() {
+	case ssh.KeyAlgoED25519:
+		return nil
+	case ssh.KeyAlgoRSA:
+		cryptoKey, ok := publicKey.(ssh.CryptoPublicKey)
+		if !ok {
+			return fmt.Errorf("reading RSA public key")
+		}
+		rsaKey, ok := cryptoKey.CryptoPublicKey().(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("reading RSA public key")
+		}
+		switch bits := rsaKey.N.BitLen(); bits {
+		case 2048, 3072, 4096:
+			return nil
+		default:
+			return fmt.Errorf("EC2 imports 2048, 3072 or 4096-bit RSA keys, not %d-bit", bits)
+		}
+	default:
+		return fmt.Errorf("EC2 imports ed25519 and RSA keys, not %s", publicKey.Type())
+	}
+}
+
 // KeyPairExists checks if a key pair exists in AWS
 func (kpm *KeyPairManager) KeyPairExists(ctx context.Context, keyName string) (bool, error) {
 	input := &ec2.DescribeKeyPairsInput{
@@ -86,7 +306,7 @@ func (kpm *KeyPairManager) DeleteKeyPair(ctx context.Context, keyName string) er
 	return nil
 }
 
-// ListKeyPairs lists all key pairs with the project tag
+// ListKeyPairs lists the current user's key pairs with the project tag
 func (kpm *KeyPairManager) ListKeyPairs(ctx context.Context) ([]string, error) {
 	input := &ec2.DescribeKeyPairsInput{
 		Filters: []types.Filter{
@@ -94,6 +314,10 @@ func (kpm *KeyPairManager) ListKeyPairs(ctx context.Context) ([]string, error) {
 				Name:   aws.String("tag:Project"),
 				Values: []string{"geoschem-aws"},
 			},
+			{
+				Name:   aws.String("tag:Owner"),
+				Values: []string{common.CurrentUser()},
+			},
 		},
 	}
 
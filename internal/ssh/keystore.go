@@ -0,0 +1,210 @@
+package ssh
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// PassphraseEnv holds the passphrase for passphrase key storage and for encrypted key files
+const PassphraseEnv = "GEOSCHEM_KEY_PASSPHRASE"
+
+// Stored keys are referenced as ssm:<parameter name> or secretsmanager:<secret name> where
+// a file path would otherwise be used
+const (
+	ssmKeyRef    = "ssm:"
+	secretKeyRef = "secretsmanager:"
+	keyNamespace = "geoschem-aws/keys/"
+)
+
+// KeyStore keeps generated private keys in the configured storage: plaintext or
+// passphrase-encrypted files in DefaultKeyDir, or SSM Parameter Store and Secrets Manager
+// (encrypted with KMS) so no private key is written to local disk
+type KeyStore struct {
+	storage  string
+	kmsKeyID string
+	cli      *awscli.Client
+}
+
+// NewKeyStore creates a key store for the AWS config's key_storage. Passphrase storage
+// needs GEOSCHEM_KEY_PASSPHRASE set.
+func NewKeyStore(config common.AWSConfig) (*KeyStore, error) {
+	if err := config.ValidateKeyStorage(); err != nil {
+		return nil, err
+	}
+	if config.KeyStorageName() == common.KeyStoragePassphrase && os.Getenv(PassphraseEnv) == "" {
+		return nil, fmt.Errorf("key_storage passphrase needs %s set", PassphraseEnv)
+	}
+	return &KeyStore{
+		storage:  config.KeyStorageName(),
+		kmsKeyID: config.KeyKMSKeyID,
+		cli:      awscli.New(config.Profile, config.Region),
+	}, nil
+}
+
+// fileKeyStore is the default: plaintext files in DefaultKeyDir
+func fileKeyStore() *KeyStore {
+	return &KeyStore{storage: common.KeyStorageFile}
+}
+
+// sweepStorage marks a key store that removes keys from every storage, for cleaning up keys
+// whose storage isn't known
+const sweepStorage = "any"
+
+// SweepKeyStore returns a key store whose Remove deletes a key wherever it may be stored
+func SweepKeyStore(profile, region string) *KeyStore {
+	return &KeyStore{storage: sweepStorage, cli: awscli.New(profile, region)}
+}
+
+// Save stores a generated key and returns where to load it from: a private key file path,
+// or a reference to the parameter or secret holding it
+func (ks *KeyStore) Save(ctx context.Context, keyPair *KeyPair) (string, error) {
+	switch ks.storage {
+	case common.KeyStorageSSM:
+		name := "/" + keyNamespace + keyPair.KeyName
+		args := []string{"ssm", "put-parameter", "--name", name, "--type", "SecureString", "--value", "file:///dev/stdin",
+			"--description", "GeosChem SSH private key", "--tags", "Key=Project,Value=geoschem-aws"}
+		if ks.kmsKeyID != "" {
+			args = append(args, "--key-id", ks.kmsKeyID)
+		}
+		if err := ks.cli.RunWithInput(ctx, nil, []byte(keyPair.PrivateKey), args...); err != nil {
+			return "", fmt.Errorf("storing private key in Parameter Store: %w", err)
+		}
+		return ssmKeyRef + name, nil
+	case common.KeyStorageSecretsManager:
+		name := keyNamespace + keyPair.KeyName
+		args := []string{"secretsmanager", "create-secret", "--name", name, "--secret-string", "file:///dev/stdin",
+			"--description", "GeosChem SSH private key", "--tags", "Key=Project,Value=geoschem-aws"}
+		if ks.kmsKeyID != "" {
+			args = append(args, "--kms-key-id", ks.kmsKeyID)
+		}
+		if err := ks.cli.RunWithInput(ctx, nil, []byte(keyPair.PrivateKey), args...); err != nil {
+			return "", fmt.Errorf("storing private key in Secrets Manager: %w", err)
+		}
+		return secretKeyRef + name, nil
+	}
+
+	keyDir, err := DefaultKeyDir()
+	if err != nil {
+		return "", err
+	}
+	privateKeyPath := filepath.Join(keyDir, keyPair.KeyName+".pem")
+
+	if ks.storage == common.KeyStoragePassphrase {
+		encrypted, err := encryptPrivateKey(keyPair, []byte(os.Getenv(PassphraseEnv)))
+		if err != nil {
+			return "", err
+		}
+		protected := *keyPair
+		protected.PrivateKey = encrypted
+		keyPair = &protected
+	}
+	if err := SaveKeyPairToFile(keyPair, privateKeyPath); err != nil {
+		return "", err
+	}
+	return privateKeyPath, nil
+}
+
+// Remove deletes a generated key's files, and the parameter or secret holding it
+func (ks *KeyStore) Remove(ctx context.Context, keyName string) error {
+	var errs []error
+	if err := RemoveLocalKey(keyName); err != nil {
+		errs = append(errs, err)
+	}
+
+	if ks.storage == common.KeyStorageSSM || ks.storage == sweepStorage {
+		err := ks.cli.Run(ctx, nil, "ssm", "delete-parameter", "--name", "/"+keyNamespace+keyName)
+		if err != nil && !strings.Contains(err.Error(), "ParameterNotFound") {
+			errs = append(errs, fmt.Errorf("deleting key parameter: %w", err))
+		}
+	}
+	if ks.storage == common.KeyStorageSecretsManager || ks.storage == sweepStorage {
+		// Without the recovery window the name can be reused and nothing lingers
+		err := ks.cli.Run(ctx, nil, "secretsmanager", "delete-secret", "--secret-id", keyNamespace+keyName, "--force-delete-without-recovery")
+		if err != nil && !strings.Contains(err.Error(), "ResourceNotFoundException") {
+			errs = append(errs, fmt.Errorf("deleting key secret: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewClient creates an SSH client with a key from Save or a private key file
+func (ks *KeyStore) NewClient(ctx context.Context, host, user, keyRef string) (*Client, error) {
+	if !IsStoredKey(keyRef) {
+		return NewClient(host, user, keyRef)
+	}
+
+	key, err := ks.fetch(ctx, keyRef)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key from %s: %w", keyRef, err)
+	}
+	return newClientWithSigner(user, signer), nil
+}
+
+// fetch retrieves a stored private key
+func (ks *KeyStore) fetch(ctx context.Context, keyRef string) ([]byte, error) {
+	if name, ok := strings.CutPrefix(keyRef, ssmKeyRef); ok {
+		var out struct {
+			Parameter struct {
+				Value string `json:"Value"`
+			} `json:"Parameter"`
+		}
+		if err := ks.cli.Run(ctx, &out, "ssm", "get-parameter", "--name", name, "--with-decryption"); err != nil {
+			return nil, fmt.Errorf("fetching private key from Parameter Store: %w", err)
+		}
+		return []byte(out.Parameter.Value), nil
+	}
+
+	name := strings.TrimPrefix(keyRef, secretKeyRef)
+	var out struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := ks.cli.Run(ctx, &out, "secretsmanager", "get-secret-value", "--secret-id", name); err != nil {
+		return nil, fmt.Errorf("fetching private key from Secrets Manager: %w", err)
+	}
+	return []byte(out.SecretString), nil
+}
+
+// IsStoredKey reports whether a key reference names a parameter or secret, not a file
+func IsStoredKey(keyRef string) bool {
+	return strings.HasPrefix(keyRef, ssmKeyRef) || strings.HasPrefix(keyRef, secretKeyRef)
+}
+
+// FetchKeyCommand returns an AWS CLI command that writes a stored key to key.pem for manual
+// SSH, or "" for key files
+func FetchKeyCommand(keyRef string) string {
+	if name, ok := strings.CutPrefix(keyRef, ssmKeyRef); ok {
+		return fmt.Sprintf("aws ssm get-parameter --name %s --with-decryption --query Parameter.Value --output text > key.pem && chmod 600 key.pem", name)
+	}
+	if name, ok := strings.CutPrefix(keyRef, secretKeyRef); ok {
+		return fmt.Sprintf("aws secretsmanager get-secret-value --secret-id %s --query SecretString --output text > key.pem && chmod 600 key.pem", name)
+	}
+	return ""
+}
+
+// encryptPrivateKey re-encodes a generated PEM key in OpenSSH format encrypted with the
+// passphrase, which ssh and ssh-add prompt for
+func encryptPrivateKey(keyPair *KeyPair, passphrase []byte) (string, error) {
+	key, err := ssh.ParseRawPrivateKey([]byte(keyPair.PrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("parsing generated key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(key, keyPair.KeyName, passphrase)
+	if err != nil {
+		return "", fmt.Errorf("encrypting private key: %w", err)
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
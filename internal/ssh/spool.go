@@ -0,0 +1,142 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// Output kept in memory for a command's returned string; the rest is spooled to disk
+const (
+	spoolHeadBytes = 64 << 10
+	spoolTailBytes = 64 << 10
+)
+
+// spoolWriter keeps the head and tail of a command's output in memory. Once the output
+// outgrows them, all of it is written to a file under the local logs directory, so memory
+// stays bounded however much a command prints.
+type spoolWriter struct {
+	mu    sync.Mutex // The session copies stdout and stderr concurrently
+	head  []byte
+	tail  []byte // Ring buffer of the last spoolTailBytes, oldest byte at start once full
+	start int
+	total int64
+	file  *os.File
+	err   error
+}
+
+func (w *spoolWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.total += int64(len(p))
+	if w.file == nil && w.err == nil && len(w.head)+len(w.tail)+len(p) > spoolHeadBytes+spoolTailBytes {
+		w.openSpool()
+	}
+	if w.file != nil {
+		if _, err := w.file.Write(p); err != nil {
+			w.closeSpool(err)
+		}
+	}
+
+	rest := p
+	if room := spoolHeadBytes - len(w.head); room > 0 {
+		n := min(room, len(rest))
+		w.head = append(w.head, rest[:n]...)
+		rest = rest[n:]
+	}
+	w.appendTail(rest)
+	return len(p), nil
+}
+
+// appendTail adds bytes to the tail ring buffer
+func (w *spoolWriter) appendTail(p []byte) {
+	if len(p) >= spoolTailBytes {
+		w.tail = append(w.tail[:0], p[len(p)-spoolTailBytes:]...)
+		w.start = 0
+		return
+	}
+	for len(p) > 0 {
+		if len(w.tail) < spoolTailBytes {
+			n := min(spoolTailBytes-len(w.tail), len(p))
+			w.tail = append(w.tail, p[:n]...)
+			p = p[n:]
+			continue
+		}
+		n := copy(w.tail[w.start:], p)
+		w.start = (w.start + n) % spoolTailBytes
+		p = p[n:]
+	}
+}
+
+// openSpool creates the spool file and writes the output kept so far. A spool that can't
+// be created only loses the middle of the output.
+func (w *spoolWriter) openSpool() {
+	dir, err := common.PrivateDir("logs")
+	if err == nil {
+		w.file, err = os.CreateTemp(dir, "ssh-output-*.log")
+	}
+	if err != nil {
+		w.err = err
+		return
+	}
+	if _, err := w.file.Write(w.head); err != nil {
+		w.closeSpool(err)
+		return
+	}
+	if _, err := w.file.Write(w.orderedTail()); err != nil {
+		w.closeSpool(err)
+	}
+}
+
+func (w *spoolWriter) closeSpool(err error) {
+	w.file.Close()
+	os.Remove(w.file.Name())
+	w.file = nil
+	w.err = err
+}
+
+// orderedTail returns the tail buffer oldest byte first
+func (w *spoolWriter) orderedTail() []byte {
+	return append(append([]byte{}, w.tail[w.start:]...), w.tail[:w.start]...)
+}
+
+// String returns the whole output when it fit in memory, and otherwise its head and tail
+// around a note on what was left out and where to find it
+func (w *spoolWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	kept := int64(len(w.head) + len(w.tail))
+	if w.total == kept {
+		return string(w.head) + string(w.orderedTail())
+	}
+
+	var note string
+	switch {
+	case w.file != nil:
+		note = fmt.Sprintf("[%d bytes omitted; full output in %s]", w.total-kept, w.file.Name())
+	case w.err != nil:
+		note = fmt.Sprintf("[%d bytes omitted; spooling failed: %v]", w.total-kept, w.err)
+	default:
+		note = fmt.Sprintf("[%d bytes omitted]", w.total-kept)
+	}
+	var out strings.Builder
+	out.Write(w.head)
+	out.WriteString("\n... " + note + " ...\n")
+	out.Write(w.orderedTail())
+	return out.String()
+}
+
+// Close closes the spool file, which is kept for inspection
+func (w *spoolWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
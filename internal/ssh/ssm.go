@@ -0,0 +1,178 @@
+package ssh
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/redact"
+)
+
+const (
+	// ssmCommandTimeout bounds a single command; container builds are the longest step
+	ssmCommandTimeout = 4 * time.Hour
+	// ssmUploadChunk is the file data sent per command, well under the parameter size limit
+	ssmUploadChunk = 48 * 1024
+)
+
+// SSMClient runs commands on an instance with SSM Run Command instead of SSH, so the
+// instance needs no public IP, key pair or inbound port 22. The instance profile must
+// include AmazonSSMManagedInstanceCore.
+type SSMClient struct {
+	client     *ssm.Client
+	instanceID string
+	user       string // Commands run as this login user, so paths match an SSH session
+}
+
+// NewSSMClient creates a client for an instance, running commands as the given OS user
+func NewSSMClient(cfg aws.Config, instanceID, user string) *SSMClient {
+	return &SSMClient{client: ssm.NewFromConfig(cfg), instanceID: instanceID, user: user}
+}
+
+// WaitForAgent waits for the instance's SSM agent to report online
+func (c *SSMClient) WaitForAgent(ctx context.Context, timeout time.Duration) error {
+	fmt.Println("Waiting for SSM agent...")
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		info, err := c.client.DescribeInstanceInformation(ctx, &ssm.DescribeInstanceInformationInput{
+			Filters: []ssmtypes.InstanceInformationStringFilter{
+				{Key: aws.String("InstanceIds"), Values: []string{c.instanceID}},
+			},
+		})
+		if err == nil && len(info.InstanceInformationList) > 0 &&
+			info.InstanceInformationList[0].PingStatus == ssmtypes.PingStatusOnline {
+			fmt.Println("SSM agent online!")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+		}
+	}
+
+	return fmt.Errorf("SSM agent on %s did not come online; check the instance profile includes AmazonSSMManagedInstanceCore", c.instanceID)
+}
+
+// ExecuteCommand runs a command as the OS user and returns its output
+func (c *SSMClient) ExecuteCommand(ctx context.Context, command string) (string, error) {
+	// Credentials are masked on the instance, since SSM keeps the output and may ship it
+	// to CloudWatch or S3
+	script := fmt.Sprintf("set -o pipefail; runuser -l %s -c %s 2>&1 | sed -u -E %s",
+		c.user, shellQuote(command), shellQuote(redact.SedProgram()))
+
+	sent, err := c.client.SendCommand(ctx, &ssm.SendCommandInput{
+		DocumentName:   aws.String("AWS-RunShellScript"),
+		InstanceIds:    []string{c.instanceID},
+		TimeoutSeconds: aws.Int32(600), // Time allowed for delivery, not execution
+		Parameters: map[string][]string{
+			"commands":         {script},
+			"executionTimeout": {fmt.Sprintf("%d", int(ssmCommandTimeout.Seconds()))},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("sending command: %w", err)
+	}
+
+	return c.waitForCommand(ctx, *sent.Command.CommandId)
+}
+
+// ExecuteCommandStream runs a command and writes its output when it finishes; SSM Run
+// Command has no live output stream. A failed command's output goes to stderr.
+func (c *SSMClient) ExecuteCommandStream(ctx context.Context, command string, stdout, stderr io.Writer) error {
+	output, err := c.ExecuteCommand(ctx, command)
+	if err != nil {
+		fmt.Fprint(stderr, output)
+		return err
+	}
+	fmt.Fprint(stdout, output)
+	return nil
+}
+
+// UploadFile copies a local file to the instance in base64 chunks, one command each
+func (c *SSMClient) UploadFile(ctx context.Context, localPath, remotePath string) error {
+	content, err := os.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("reading local file: %w", err)
+	}
+
+	// Assemble beside the destination so a failed upload never leaves a partial file there
+	partial := remotePath + ".upload"
+	if _, err := c.ExecuteCommand(ctx, "rm -f "+shellQuote(partial)); err != nil {
+		return fmt.Errorf("preparing upload: %w", err)
+	}
+	for start := 0; start < len(content); start += ssmUploadChunk {
+		chunk := base64.StdEncoding.EncodeToString(content[start:min(start+ssmUploadChunk, len(content))])
+		if _, err := c.ExecuteCommand(ctx, fmt.Sprintf("echo %s | base64 -d >> %s", chunk, shellQuote(partial))); err != nil {
+			return fmt.Errorf("uploading %s: %w", localPath, err)
+		}
+	}
+	if _, err := c.ExecuteCommand(ctx, fmt.Sprintf("touch %s && mv %s %s", shellQuote(partial), shellQuote(partial), shellQuote(remotePath))); err != nil {
+		return fmt.Errorf("uploading %s: %w", localPath, err)
+	}
+	return nil
+}
+
+// TestConnection tests if SSM can run commands on the instance
+func (c *SSMClient) TestConnection(ctx context.Context) error {
+	output, err := c.ExecuteCommand(ctx, "echo 'SSM connection successful'")
+	if err != nil {
+		return fmt.Errorf("test command failed: %w", err)
+	}
+
+	if !strings.Contains(output, "SSM connection successful") {
+		return fmt.Errorf("unexpected test output: %s", output)
+	}
+
+	return nil
+}
+
+// Close is a no-op; SSM Run Command holds no connection open
+func (c *SSMClient) Close() error {
+	return nil
+}
+
+// waitForCommand polls a command invocation until it finishes
+func (c *SSMClient) waitForCommand(ctx context.Context, commandID string) (string, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+
+		invocation, err := c.client.GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{
+			CommandId:  aws.String(commandID),
+			InstanceId: aws.String(c.instanceID),
+		})
+		var notYet *ssmtypes.InvocationDoesNotExist
+		if errors.As(err, &notYet) {
+			continue // Invocation isn't registered immediately after SendCommand
+		}
+		if err != nil {
+			return "", fmt.Errorf("checking command status: %w", err)
+		}
+
+		output := redact.String(aws.ToString(invocation.StandardOutputContent) + aws.ToString(invocation.StandardErrorContent))
+		switch invocation.Status {
+		case ssmtypes.CommandInvocationStatusSuccess:
+			return output, nil
+		case ssmtypes.CommandInvocationStatusPending, ssmtypes.CommandInvocationStatusInProgress, ssmtypes.CommandInvocationStatusDelayed:
+			continue
+		default:
+			return output, fmt.Errorf("command %s: %s (exit code %d)", invocation.Status,
+				aws.ToString(invocation.StatusDetails), invocation.ResponseCode)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// Uploads read at most this much before waiting for bandwidth, so a capped upload
+// proceeds in small steps instead of long pauses
+const throttleChunk = 32 * 1024
+
+// bandwidthBurst is how far ahead of the cap uploads may get, smoothing over scheduling delays
+const bandwidthBurst = 250 * time.Millisecond
+
+// Upload is a local file and where to put it on the instance
+type Upload struct {
+	LocalPath  string
+	RemotePath string
+}
+
+// SetTransferLimits caps the bandwidth of all uploads over the connection together and how
+// many run at once. Each upload is its own SSH channel, so they share the one connection.
+func (c *Client) SetTransferLimits(config common.TransferConfig) {
+	c.streams = make(chan struct{}, config.Streams())
+	c.bandwidth = nil
+	if rate := config.BytesPerSecond(); rate > 0 {
+		c.bandwidth = &bandwidthLimiter{rate: rate}
+	}
+}
+
+// UploadFiles uploads files concurrently, as many at a time as the transfer limits allow,
+// and returns the errors of every upload that failed
+func (c *Client) UploadFiles(ctx context.Context, uploads []Upload) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(uploads))
+	for i, upload := range uploads {
+		wg.Add(1)
+		go func(i int, upload Upload) {
+			defer wg.Done()
+			if err := c.UploadFile(ctx, upload.LocalPath, upload.RemotePath); err != nil {
+				errs[i] = fmt.Errorf("uploading %s: %w", upload.LocalPath, err)
+			}
+		}(i, upload)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// acquireStream waits for an upload slot and returns the function that frees it
+func (c *Client) acquireStream(ctx context.Context) (func(), error) {
+	select {
+	case c.streams <- struct{}{}:
+		return func() { <-c.streams }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// throttle limits reads from r to the connection's bandwidth cap, if it has one
+func (c *Client) throttle(ctx context.Context, r io.Reader) io.Reader {
+	if c.bandwidth == nil {
+		return r
+	}
+	return &throttledReader{ctx: ctx, r: r, limiter: c.bandwidth}
+}
+
+// bandwidthLimiter paces readers sharing a cap: each read reserves the time its bytes take
+// at the capped rate, and waits until the reservations before it have passed
+type bandwidthLimiter struct {
+	rate float64 // Bytes per second
+
+	mu   sync.Mutex
+	next time.Time // When the bytes reserved so far will have been sent at the capped rate
+}
+
+// wait blocks until n more bytes fit within the cap
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	delay := l.next.Sub(now) - bandwidthBurst
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttledReader reads through a bandwidthLimiter
+type throttledReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if waitErr := t.limiter.wait(t.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
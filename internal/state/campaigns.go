@@ -0,0 +1,173 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const campaignsCollection = "campaigns"
+
+// Campaign run statuses
+const (
+	CampaignPending = "pending"
+	CampaignRunning = "running"
+	CampaignDone    = "done"
+	CampaignFailed  = "failed"
+)
+
+// CampaignRun is the progress of one run of a campaign
+type CampaignRun struct {
+	ID             string    `json:"id"`
+	Simulation     string    `json:"simulation"`
+	Resolution     string    `json:"resolution"`
+	Scenario       string    `json:"scenario,omitempty"`
+	StartDate      string    `json:"start_date"`
+	EndDate        string    `json:"end_date"`
+	InstanceType   string    `json:"instance_type"`
+	OutputURI      string    `json:"output_uri"`
+	Status         string    `json:"status"`
+	EstimatedCost  float64   `json:"estimated_cost"`
+	EstimatedHours float64   `json:"estimated_hours"`
+	Cost           float64   `json:"cost,omitempty"`
+	Hours          float64   `json:"hours,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	StartedAt      time.Time `json:"started_at,omitempty"`
+	FinishedAt     time.Time `json:"finished_at,omitempty"`
+}
+
+// CampaignRecord tracks the runs of a campaign as a unit
+type CampaignRecord struct {
+	Name      string        `json:"name"`
+	Manifest  string        `json:"manifest"`
+	Image     string        `json:"image"`
+	Scheduler string        `json:"scheduler,omitempty"`
+	Owner     string        `json:"owner,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Runs      []CampaignRun `json:"runs"`
+}
+
+// Counts returns the number of runs in each status
+func (c *CampaignRecord) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, run := range c.Runs {
+		counts[run.Status]++
+	}
+	return counts
+}
+
+// Run returns the run with the ID, or nil
+func (c *CampaignRecord) Run(id string) *CampaignRun {
+	for i := range c.Runs {
+		if c.Runs[i].ID == id {
+			return &c.Runs[i]
+		}
+	}
+	return nil
+}
+
+// Campaigns tracks simulation campaigns in the state store
+type Campaigns struct {
+	store *Store
+	mu    sync.Mutex // Serializes run updates from concurrent runs
+}
+
+// NewCampaigns creates a campaign tracker backed by the store
+func NewCampaigns(store *Store) *Campaigns {
+	return &Campaigns{store: store}
+}
+
+// Get returns a campaign by name, or nil if it has never run
+func (c *Campaigns) Get(name string) (*CampaignRecord, error) {
+	records, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	record, ok := records[name]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+// List returns every campaign, most recently updated first
+func (c *Campaigns) List() ([]CampaignRecord, error) {
+	records, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]CampaignRecord, 0, len(records))
+	for _, record := range records {
+		list = append(list, record)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].UpdatedAt.After(list[j].UpdatedAt)
+	})
+	return list, nil
+}
+
+// Save stores a campaign, replacing any with the same name
+func (c *Campaigns) Save(record CampaignRecord) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records, err := c.load()
+	if err != nil {
+		return err
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	record.UpdatedAt = time.Now().UTC()
+	records[record.Name] = record
+	return c.store.Save(campaignsCollection, records)
+}
+
+// UpdateRun replaces one run of a campaign
+func (c *Campaigns) UpdateRun(name string, run CampaignRun) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records, err := c.load()
+	if err != nil {
+		return err
+	}
+	record, ok := records[name]
+	if !ok {
+		return fmt.Errorf("campaign %s not found", name)
+	}
+	existing := record.Run(run.ID)
+	if existing == nil {
+		return fmt.Errorf("campaign %s has no run %s", name, run.ID)
+	}
+	*existing = run
+	record.UpdatedAt = time.Now().UTC()
+	records[name] = record
+	return c.store.Save(campaignsCollection, records)
+}
+
+// Delete removes a campaign's record; its output is left in place
+func (c *Campaigns) Delete(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records, err := c.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := records[name]; !ok {
+		return fmt.Errorf("campaign %s not found", name)
+	}
+	delete(records, name)
+	return c.store.Save(campaignsCollection, records)
+}
+
+func (c *Campaigns) load() (map[string]CampaignRecord, error) {
+	records := make(map[string]CampaignRecord)
+	if err := c.store.Load(campaignsCollection, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,118 @@
+package state
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const matrixBuildsCollection = "matrix-builds"
+
+// Matrix combination outcomes
+const (
+	CombinationBuilt  = "built"
+	CombinationFailed = "failed"
+)
+
+// MatrixCombination is the outcome of one arch/compiler/mpi combination of a matrix build
+type MatrixCombination struct {
+	Status     string        `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	FinishedAt time.Time     `json:"finished_at"`
+}
+
+// MatrixBuild records which combinations of a matrix build finished, so an interrupted
+// build can resume without rebuilding them
+type MatrixBuild struct {
+	Scope        string                       `json:"scope"`       // Owner, region and architectures built
+	ConfigHash   string                       `json:"config_hash"` // Fingerprint of the build settings
+	StartedAt    time.Time                    `json:"started_at"`
+	UpdatedAt    time.Time                    `json:"updated_at"`
+	Combinations map[string]MatrixCombination `json:"combinations"` // By arch/compiler/mpi
+}
+
+// Built returns the combinations that built, by arch/compiler/mpi
+func (m *MatrixBuild) Built() map[string]MatrixCombination {
+	built := make(map[string]MatrixCombination)
+	for name, combination := range m.Combinations {
+		if combination.Status == CombinationBuilt {
+			built[name] = combination
+		}
+	}
+	return built
+}
+
+// MatrixBuilds tracks the latest matrix build of each scope in the state store
+type MatrixBuilds struct {
+	store *Store
+	mu    sync.Mutex // Serializes updates from concurrent builds
+}
+
+// NewMatrixBuilds creates a matrix build tracker backed by the store
+func NewMatrixBuilds(store *Store) *MatrixBuilds {
+	return &MatrixBuilds{store: store}
+}
+
+// Get returns the latest matrix build of a scope, or nil if there is none
+func (m *MatrixBuilds) Get(scope string) (*MatrixBuild, error) {
+	records, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	record, ok := records[scope]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+// Start stores a matrix build, replacing the scope's previous one
+func (m *MatrixBuilds) Start(build MatrixBuild) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records, err := m.load()
+	if err != nil {
+		return err
+	}
+	if build.StartedAt.IsZero() {
+		build.StartedAt = time.Now().UTC()
+	}
+	if build.Combinations == nil {
+		build.Combinations = make(map[string]MatrixCombination)
+	}
+	build.UpdatedAt = time.Now().UTC()
+	records[build.Scope] = build
+	return m.store.Save(matrixBuildsCollection, records)
+}
+
+// Record stores the outcome of one combination of a scope's matrix build
+func (m *MatrixBuilds) Record(scope, name string, combination MatrixCombination) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records, err := m.load()
+	if err != nil {
+		return err
+	}
+	record, ok := records[scope]
+	if !ok {
+		return fmt.Errorf("no matrix build recorded for %s", scope)
+	}
+	if combination.FinishedAt.IsZero() {
+		combination.FinishedAt = time.Now().UTC()
+	}
+	record.Combinations[name] = combination
+	record.UpdatedAt = time.Now().UTC()
+	records[scope] = record
+	return m.store.Save(matrixBuildsCollection, records)
+}
+
+func (m *MatrixBuilds) load() (map[string]MatrixBuild, error) {
+	records := make(map[string]MatrixBuild)
+	if err := m.store.Load(matrixBuildsCollection, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
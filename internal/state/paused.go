@@ -0,0 +1,102 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const pausedCollection = "paused"
+
+// PausedRun is a simulation stopped at a restart checkpoint, waiting to resume
+type PausedRun struct {
+	ID          string    `json:"id"`
+	Description string    `json:"description"`
+	Args        []string  `json:"args"`        // The run's command-line flags, replayed on resume
+	RestartID   string    `json:"restart_id"`  // Checkpoint in the restart registry
+	ResumeDate  string    `json:"resume_date"` // YYYY-MM-DD the resumed run starts at
+	EndDate     string    `json:"end_date"`
+	InstanceID  string    `json:"instance_id,omitempty"` // Stopped instance, terminated on resume
+	Profile     string    `json:"profile"`
+	Region      string    `json:"region"`
+	Owner       string    `json:"owner,omitempty"`
+	PausedAt    time.Time `json:"paused_at"`
+}
+
+// PausedRuns tracks paused simulations until they resume
+type PausedRuns struct {
+	store *Store
+}
+
+// NewPausedRuns creates a paused run list backed by the store
+func NewPausedRuns(store *Store) *PausedRuns {
+	return &PausedRuns{store: store}
+}
+
+// Add records a paused run and returns it with its ID
+func (p *PausedRuns) Add(run PausedRun) (*PausedRun, error) {
+	if run.PausedAt.IsZero() {
+		run.PausedAt = time.Now().UTC()
+	}
+	sum := sha256.Sum256([]byte(run.RestartID + "\x00" + run.PausedAt.Format(time.RFC3339Nano)))
+	run.ID = "paused-" + hex.EncodeToString(sum[:4])
+
+	runs, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	runs[run.ID] = run
+	if err := p.store.Save(pausedCollection, runs); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// Get returns a paused run by ID
+func (p *PausedRuns) Get(id string) (*PausedRun, error) {
+	runs, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	run, ok := runs[id]
+	if !ok {
+		return nil, fmt.Errorf("paused run %s not found", id)
+	}
+	return &run, nil
+}
+
+// List returns the paused runs, oldest first
+func (p *PausedRuns) List() ([]PausedRun, error) {
+	runs, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]PausedRun, 0, len(runs))
+	for _, run := range runs {
+		list = append(list, run)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].PausedAt.Before(list[j].PausedAt)
+	})
+	return list, nil
+}
+
+// Remove drops a paused run once it has resumed
+func (p *PausedRuns) Remove(id string) error {
+	runs, err := p.load()
+	if err != nil {
+		return err
+	}
+	delete(runs, id)
+	return p.store.Save(pausedCollection, runs)
+}
+
+func (p *PausedRuns) load() (map[string]PausedRun, error) {
+	runs := make(map[string]PausedRun)
+	if err := p.store.Load(pausedCollection, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
@@ -0,0 +1,71 @@
+package state
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+const performanceCollection = "performance"
+
+// PerformanceLog keeps benchmark and run measurements locally for wall-time prediction
+type PerformanceLog struct {
+	store *Store
+}
+
+// NewPerformanceLog creates a log backed by the store
+func NewPerformanceLog(store *Store) *PerformanceLog {
+	return &PerformanceLog{store: store}
+}
+
+// Append adds validated records to the log
+func (l *PerformanceLog) Append(records ...common.PerformanceRecord) error {
+	existing, err := l.Records()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if err := record.Validate(); err != nil {
+			return fmt.Errorf("invalid performance record: %w", err)
+		}
+		existing = append(existing, record)
+	}
+
+	return l.store.Save(performanceCollection, existing)
+}
+
+// Records returns every recorded measurement, oldest first
+func (l *PerformanceLog) Records() ([]common.PerformanceRecord, error) {
+	var records []common.PerformanceRecord
+	if err := l.store.Load(performanceCollection, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// LoadPredictor builds a predictor from the log and an optional shared dataset file of
+// newline-delimited records
+func (l *PerformanceLog) LoadPredictor(datasetPath string) (*common.Predictor, error) {
+	records, err := l.Records()
+	if err != nil {
+		return nil, err
+	}
+
+	if datasetPath != "" {
+		file, err := os.Open(datasetPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		shared, err := common.LoadPerformanceRecords(file)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, shared...)
+	}
+
+	return common.NewPredictor(records), nil
+}
@@ -0,0 +1,97 @@
+package state
+
+import (
+	"sort"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+const resourcesCollection = "resources"
+
+// Kinds of AWS resources the platform creates and may need to clean up later
+const (
+	ResourceInstance = "instance"
+	ResourceKeyPair  = "key-pair"
+)
+
+// ResourceRecord describes a resource that a failed cleanup left behind
+type ResourceRecord struct {
+	Kind       string    `json:"kind"`
+	ID         string    `json:"id"`
+	Region     string    `json:"region"`
+	Reason     string    `json:"reason"`
+	Owner      string    `json:"owner,omitempty"` // User whose run left the resource behind
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// ResourceLedger tracks leftover resources until the janitor removes them
+type ResourceLedger struct {
+	store *Store
+}
+
+// NewResourceLedger creates a ledger backed by the store
+func NewResourceLedger(store *Store) *ResourceLedger {
+	return &ResourceLedger{store: store}
+}
+
+// Record adds resources to the ledger, replacing earlier entries for the same resource.
+// Records without an owner are owned by the current user.
+func (l *ResourceLedger) Record(records ...ResourceRecord) error {
+	existing, err := l.load()
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.Owner == "" {
+			record.Owner = common.CurrentUser()
+		}
+		existing[resourceKey(record.Kind, record.ID)] = record
+	}
+	return l.store.Save(resourcesCollection, existing)
+}
+
+// Records returns every leftover resource, oldest first
+func (l *ResourceLedger) Records() ([]ResourceRecord, error) {
+	existing, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]ResourceRecord, 0, len(existing))
+	for _, record := range existing {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].RecordedAt.Before(records[j].RecordedAt)
+	})
+	return records, nil
+}
+
+// OwnedBy reports whether the record belongs to user. Records made before resources were
+// namespaced have no owner and belong to everyone.
+func (r ResourceRecord) OwnedBy(user string) bool {
+	return r.Owner == "" || r.Owner == user
+}
+
+// Remove drops a resource from the ledger once it has been cleaned up
+func (l *ResourceLedger) Remove(kind, id string) error {
+	existing, err := l.load()
+	if err != nil {
+		return err
+	}
+	delete(existing, resourceKey(kind, id))
+	return l.store.Save(resourcesCollection, existing)
+}
+
+func (l *ResourceLedger) load() (map[string]ResourceRecord, error) {
+	records := make(map[string]ResourceRecord)
+	if err := l.store.Load(resourcesCollection, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func resourceKey(kind, id string) string {
+	return kind + "/" + id
+}
@@ -0,0 +1,190 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const restartsCollection = "restarts"
+
+// RestartRecord describes a restart file produced by a run
+type RestartRecord struct {
+	ID           string    `json:"id"`
+	S3URI        string    `json:"s3_uri"`
+	ModelDate    time.Time `json:"model_date"` // Model time the restart is valid for
+	Simulation   string    `json:"simulation"`
+	Resolution   string    `json:"resolution"`
+	SpeciesCount int       `json:"species_count,omitempty"`
+	ConfigHash   string    `json:"config_hash"`
+	Image        string    `json:"image,omitempty"`
+	RunID        string    `json:"run_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RestartRequest describes what a new run needs from a restart file
+type RestartRequest struct {
+	Simulation   string
+	Resolution   string
+	StartDate    time.Time
+	SpeciesCount int // 0 skips the species check
+}
+
+// RestartRegistry tracks restart files in the state store
+type RestartRegistry struct {
+	store *Store
+}
+
+// NewRestartRegistry creates a registry backed by the store
+func NewRestartRegistry(store *Store) *RestartRegistry {
+	return &RestartRegistry{store: store}
+}
+
+// ConfigHash fingerprints the run configuration that produced a restart or output: the image
+// and every setting that shapes the results (benchmark.Config.ResultSettings). Records
+// registered by hand, which only know their simulation and resolution, hash just those.
+func ConfigHash(image string, settings map[string]string) string {
+	lines := make([]string, 0, len(settings))
+	for name, value := range settings {
+		lines = append(lines, name+"="+value)
+	}
+	sort.Strings(lines)
+	lines = append([]string{"image=" + image}, lines...)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// basicSettings are the settings of a record that only knows its simulation and resolution
+func basicSettings(simulation, resolution string) map[string]string {
+	return map[string]string{"simulation": simulation, "resolution": resolution}
+}
+
+// restartID derives a stable ID so re-registering the same file is idempotent
+func restartID(s3URI string, modelDate time.Time) string {
+	sum := sha256.Sum256([]byte(s3URI + "\x00" + modelDate.UTC().Format(time.RFC3339)))
+	return "rst-" + hex.EncodeToString(sum[:6])
+}
+
+// Register records a restart file and returns the stored record
+func (r *RestartRegistry) Register(record RestartRecord) (*RestartRecord, error) {
+	if !strings.HasPrefix(record.S3URI, "s3://") {
+		return nil, fmt.Errorf("restart location must be an s3:// URI, got %s", record.S3URI)
+	}
+	if record.Simulation == "" || record.Resolution == "" {
+		return nil, fmt.Errorf("simulation and resolution are required")
+	}
+	if record.ModelDate.IsZero() {
+		return nil, fmt.Errorf("model date is required")
+	}
+
+	if record.ConfigHash == "" {
+		record.ConfigHash = ConfigHash(record.Image, basicSettings(record.Simulation, record.Resolution))
+	}
+	record.ID = restartID(record.S3URI, record.ModelDate)
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+
+	records, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	records[record.ID] = record
+
+	if err := r.store.Save(restartsCollection, records); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Get returns a restart by ID
+func (r *RestartRegistry) Get(id string) (*RestartRecord, error) {
+	records, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	record, ok := records[id]
+	if !ok {
+		return nil, fmt.Errorf("restart %s not found", id)
+	}
+	return &record, nil
+}
+
+// List returns restarts matching the optional simulation/resolution filters, newest model date first
+func (r *RestartRegistry) List(simulation, resolution string) ([]RestartRecord, error) {
+	records, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []RestartRecord
+	for _, record := range records {
+		if simulation != "" && record.Simulation != simulation {
+			continue
+		}
+		if resolution != "" && record.Resolution != resolution {
+			continue
+		}
+		matches = append(matches, record)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].ModelDate.After(matches[j].ModelDate)
+	})
+	return matches, nil
+}
+
+// Delete removes a restart from the registry (the S3 object is left in place)
+func (r *RestartRegistry) Delete(id string) error {
+	records, err := r.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := records[id]; !ok {
+		return fmt.Errorf("restart %s not found", id)
+	}
+	delete(records, id)
+	return r.store.Save(restartsCollection, records)
+}
+
+// Resolve looks up a restart by ID and validates it against the requested run
+func (r *RestartRegistry) Resolve(id string, request RestartRequest) (*RestartRecord, error) {
+	record, err := r.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := record.Validate(request); err != nil {
+		return nil, fmt.Errorf("restart %s cannot be used: %w", id, err)
+	}
+	return record, nil
+}
+
+// Validate checks that the restart matches the requested grid, species, and start date
+func (rr *RestartRecord) Validate(request RestartRequest) error {
+	if request.Resolution != "" && rr.Resolution != request.Resolution {
+		return fmt.Errorf("grid mismatch: restart is %s, run is %s", rr.Resolution, request.Resolution)
+	}
+	if request.Simulation != "" && rr.Simulation != request.Simulation {
+		return fmt.Errorf("species mismatch: restart is from a %s simulation, run is %s", rr.Simulation, request.Simulation)
+	}
+	if request.SpeciesCount > 0 && rr.SpeciesCount > 0 && rr.SpeciesCount != request.SpeciesCount {
+		return fmt.Errorf("species mismatch: restart has %d species, run expects %d", rr.SpeciesCount, request.SpeciesCount)
+	}
+	if !request.StartDate.IsZero() && !rr.ModelDate.Equal(request.StartDate) {
+		return fmt.Errorf("date mismatch: restart is valid for %s, run starts %s",
+			rr.ModelDate.Format("2006-01-02 15:04"), request.StartDate.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+func (r *RestartRegistry) load() (map[string]RestartRecord, error) {
+	records := make(map[string]RestartRecord)
+	if err := r.store.Load(restartsCollection, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,80 @@
+package state
+
+import (
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+const sizingCollection = "sizing"
+
+// maxSizingRecords is how many measured runs are kept per workload; older runs drop off so
+// suggestions follow the workload as it changes
+const maxSizingRecords = 20
+
+// SizingLog keeps each run's right-sizing suggestion so instance recommendations for a
+// workload improve as it runs
+type SizingLog struct {
+	store *Store
+}
+
+// NewSizingLog creates a log backed by the store
+func NewSizingLog(store *Store) *SizingLog {
+	return &SizingLog{store: store}
+}
+
+// Append records a run's right-sizing suggestion
+func (l *SizingLog) Append(record common.SizingRecord) error {
+	records, err := l.records()
+	if err != nil {
+		return err
+	}
+	if record.RecordedAt.IsZero() {
+		record.RecordedAt = time.Now().UTC()
+	}
+
+	// Drop the workload's oldest runs beyond the limit
+	excess := 1 - maxSizingRecords
+	for _, existing := range records {
+		if sameWorkload(existing, record) {
+			excess++
+		}
+	}
+	kept := make([]common.SizingRecord, 0, len(records)+1)
+	for _, existing := range records {
+		if excess > 0 && sameWorkload(existing, record) {
+			excess--
+			continue
+		}
+		kept = append(kept, existing)
+	}
+	return l.store.Save(sizingCollection, append(kept, record))
+}
+
+// Lookup returns the recorded runs of a workload, oldest first
+func (l *SizingLog) Lookup(simulation, resolution, nestedDomain string) ([]common.SizingRecord, error) {
+	records, err := l.records()
+	if err != nil {
+		return nil, err
+	}
+	workload := common.SizingRecord{Simulation: simulation, Resolution: resolution, NestedDomain: nestedDomain}
+	var matching []common.SizingRecord
+	for _, record := range records {
+		if sameWorkload(record, workload) {
+			matching = append(matching, record)
+		}
+	}
+	return matching, nil
+}
+
+func (l *SizingLog) records() ([]common.SizingRecord, error) {
+	var records []common.SizingRecord
+	if err := l.store.Load(sizingCollection, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func sameWorkload(a, b common.SizingRecord) bool {
+	return a.Simulation == b.Simulation && a.Resolution == b.Resolution && a.NestedDomain == b.NestedDomain
+}
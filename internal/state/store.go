@@ -0,0 +1,91 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// Store persists named JSON collections in a local directory
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// DefaultDir returns the directory used for platform state (state under common.DataDir)
+func DefaultDir() (string, error) {
+	dir, err := common.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state"), nil
+}
+
+// Open creates a store rooted at dir, creating the directory if needed
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating state directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// OpenDefault opens the store in DefaultDir
+func OpenDefault() (*Store, error) {
+	dir, err := DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return Open(dir)
+}
+
+// Dir returns the store's directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Load reads a collection into v. A missing collection leaves v untouched.
+func (s *Store) Load(collection string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path(collection))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", collection, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parsing %s: %w", collection, err)
+	}
+	return nil
+}
+
+// Save writes a collection atomically
+func (s *Store) Save(collection string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", collection, err)
+	}
+
+	tmp := s.path(collection) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing %s: %w", collection, err)
+	}
+	if err := os.Rename(tmp, s.path(collection)); err != nil {
+		return fmt.Errorf("replacing %s: %w", collection, err)
+	}
+	return nil
+}
+
+func (s *Store) path(collection string) string {
+	return filepath.Join(s.dir, collection+".json")
+}
@@ -0,0 +1,79 @@
+package state
+
+import "time"
+
+const workloadsCollection = "workloads"
+
+// WorkloadRecord is what runs have taught about a workload's resource needs
+type WorkloadRecord struct {
+	Simulation   string `json:"simulation"`
+	Resolution   string `json:"resolution"`
+	NestedDomain string `json:"nested_domain,omitempty"`
+	// InsufficientMemoryGB is the most memory a run of the workload has been killed with
+	InsufficientMemoryGB float64   `json:"insufficient_memory_gb"`
+	OOMInstanceType      string    `json:"oom_instance_type"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// WorkloadProfiles keeps learned workload requirements so instance selection doesn't repeat
+// a choice that already failed
+type WorkloadProfiles struct {
+	store *Store
+}
+
+// NewWorkloadProfiles creates profiles backed by the store
+func NewWorkloadProfiles(store *Store) *WorkloadProfiles {
+	return &WorkloadProfiles{store: store}
+}
+
+// RecordOOM notes that a run of the workload ran out of memory on an instance with memoryGB
+func (w *WorkloadProfiles) RecordOOM(simulation, resolution, nestedDomain, instanceType string, memoryGB float64) error {
+	records, err := w.records()
+	if err != nil {
+		return err
+	}
+
+	for i := range records {
+		record := &records[i]
+		if record.Simulation == simulation && record.Resolution == resolution && record.NestedDomain == nestedDomain {
+			if memoryGB > record.InsufficientMemoryGB {
+				record.InsufficientMemoryGB = memoryGB
+				record.OOMInstanceType = instanceType
+			}
+			record.UpdatedAt = time.Now().UTC()
+			return w.store.Save(workloadsCollection, records)
+		}
+	}
+
+	records = append(records, WorkloadRecord{
+		Simulation:           simulation,
+		Resolution:           resolution,
+		NestedDomain:         nestedDomain,
+		InsufficientMemoryGB: memoryGB,
+		OOMInstanceType:      instanceType,
+		UpdatedAt:            time.Now().UTC(),
+	})
+	return w.store.Save(workloadsCollection, records)
+}
+
+// Lookup returns the learned requirements of a workload, or nil if no run has taught any
+func (w *WorkloadProfiles) Lookup(simulation, resolution, nestedDomain string) (*WorkloadRecord, error) {
+	records, err := w.records()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.Simulation == simulation && record.Resolution == resolution && record.NestedDomain == nestedDomain {
+			return &record, nil
+		}
+	}
+	return nil, nil
+}
+
+func (w *WorkloadProfiles) records() ([]WorkloadRecord, error) {
+	var records []WorkloadRecord
+	if err := w.store.Load(workloadsCollection, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+)
+
+// DefaultEFSMountPath is where shared EFS storage is mounted on run instances
+const DefaultEFSMountPath = "/mnt/efs"
+
+// FileSystem describes an EFS file system
+type FileSystem struct {
+	FileSystemID   string `json:"FileSystemId"`
+	Name           string `json:"Name"`
+	LifeCycleState string `json:"LifeCycleState"`
+	SizeInBytes    struct {
+		Value int64 `json:"Value"`
+	} `json:"SizeInBytes"`
+	ThroughputMode string `json:"ThroughputMode"`
+}
+
+// mountTarget describes an EFS mount target
+type mountTarget struct {
+	MountTargetID  string `json:"MountTargetId"`
+	SubnetID       string `json:"SubnetId"`
+	LifeCycleState string `json:"LifeCycleState"`
+}
+
+// EFSManager provisions shared EFS storage with the AWS CLI
+type EFSManager struct {
+	cli *awscli.Client
+}
+
+// NewEFSManager creates a manager for the given AWS profile and region
+func NewEFSManager(profile, region string) *EFSManager {
+	return &EFSManager{cli: awscli.New(profile, region)}
+}
+
+// Create provisions an encrypted, elastic-throughput file system with a mount target in the subnet.
+// The name doubles as the creation token, so creating the same name twice returns the existing file system.
+// The security group must allow NFS (TCP 2049) from the run instances.
+func (m *EFSManager) Create(ctx context.Context, name, subnetID, securityGroupID string) (*FileSystem, error) {
+	fmt.Printf("🗄️  Creating EFS file system %s...\n", name)
+
+	var fs FileSystem
+	err := m.cli.Run(ctx, &fs, "efs", "create-file-system",
+		"--creation-token", name,
+		"--performance-mode", "generalPurpose",
+		"--throughput-mode", "elastic",
+		"--encrypted",
+		"--tags", "Key=Name,Value="+name, "Key=Project,Value=geoschem-aws")
+	if err != nil && !strings.Contains(err.Error(), "FileSystemAlreadyExists") {
+		return nil, fmt.Errorf("creating file system: %w", err)
+	}
+	if err != nil {
+		existing, findErr := m.findByCreationToken(ctx, name)
+		if findErr != nil {
+			return nil, findErr
+		}
+		fs = *existing
+	}
+
+	if err := m.waitForFileSystem(ctx, fs.FileSystemID); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("🔌 Creating mount target in %s...\n", subnetID)
+	var target mountTarget
+	err = m.cli.Run(ctx, &target, "efs", "create-mount-target",
+		"--file-system-id", fs.FileSystemID,
+		"--subnet-id", subnetID,
+		"--security-groups", securityGroupID)
+	if err != nil && !strings.Contains(err.Error(), "MountTargetConflict") {
+		return nil, fmt.Errorf("creating mount target: %w", err)
+	}
+
+	if err := m.waitForMountTargets(ctx, fs.FileSystemID); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("✅ EFS file system %s is available\n", fs.FileSystemID)
+	return m.Describe(ctx, fs.FileSystemID)
+}
+
+// Describe returns the current state of a file system
+func (m *EFSManager) Describe(ctx context.Context, fileSystemID string) (*FileSystem, error) {
+	var out struct {
+		FileSystems []FileSystem `json:"FileSystems"`
+	}
+	if err := m.cli.Run(ctx, &out, "efs", "describe-file-systems", "--file-system-id", fileSystemID); err != nil {
+		return nil, fmt.Errorf("describing file system: %w", err)
+	}
+	if len(out.FileSystems) == 0 {
+		return nil, fmt.Errorf("file system %s not found", fileSystemID)
+	}
+	return &out.FileSystems[0], nil
+}
+
+// Delete removes the file system's mount targets and then the file system. All data is lost.
+func (m *EFSManager) Delete(ctx context.Context, fileSystemID string) error {
+	targets, err := m.mountTargets(ctx, fileSystemID)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if err := m.cli.Run(ctx, nil, "efs", "delete-mount-target", "--mount-target-id", target.MountTargetID); err != nil {
+			return fmt.Errorf("deleting mount target %s: %w", target.MountTargetID, err)
+		}
+	}
+
+	// The file system can only be deleted once its mount targets are gone
+	for {
+		targets, err := m.mountTargets(ctx, fileSystemID)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+		}
+	}
+
+	if err := m.cli.Run(ctx, nil, "efs", "delete-file-system", "--file-system-id", fileSystemID); err != nil {
+		return fmt.Errorf("deleting file system: %w", err)
+	}
+	return nil
+}
+
+// MountCommand returns the shell command that mounts a file system on a run instance over NFS
+func MountCommand(fileSystemID, region, mountPath string) string {
+	return fmt.Sprintf("(command -v mount.nfs4 >/dev/null || sudo dnf install -y -q nfs-utils || sudo apt-get install -y -qq nfs-common) && "+
+		"sudo mkdir -p %[3]s && "+
+		"(mountpoint -q %[3]s || sudo mount -t nfs4 -o nfsvers=4.1,rsize=1048576,wsize=1048576,hard,timeo=600,retrans=2,noresvport %[1]s.efs.%[2]s.amazonaws.com:/ %[3]s) && "+
+		"sudo chown $(id -u):$(id -g) %[3]s",
+		fileSystemID, region, mountPath)
+}
+
+func (m *EFSManager) findByCreationToken(ctx context.Context, token string) (*FileSystem, error) {
+	var out struct {
+		FileSystems []FileSystem `json:"FileSystems"`
+	}
+	if err := m.cli.Run(ctx, &out, "efs", "describe-file-systems", "--creation-token", token); err != nil {
+		return nil, fmt.Errorf("finding file system %s: %w", token, err)
+	}
+	if len(out.FileSystems) == 0 {
+		return nil, fmt.Errorf("file system %s not found", token)
+	}
+	return &out.FileSystems[0], nil
+}
+
+func (m *EFSManager) mountTargets(ctx context.Context, fileSystemID string) ([]mountTarget, error) {
+	var out struct {
+		MountTargets []mountTarget `json:"MountTargets"`
+	}
+	if err := m.cli.Run(ctx, &out, "efs", "describe-mount-targets", "--file-system-id", fileSystemID); err != nil {
+		return nil, fmt.Errorf("listing mount targets: %w", err)
+	}
+	return out.MountTargets, nil
+}
+
+func (m *EFSManager) waitForFileSystem(ctx context.Context, fileSystemID string) error {
+	return poll(ctx, 5*time.Minute, func() (bool, error) {
+		fs, err := m.Describe(ctx, fileSystemID)
+		if err != nil {
+			return false, err
+		}
+		return fs.LifeCycleState == "available", nil
+	})
+}
+
+func (m *EFSManager) waitForMountTargets(ctx context.Context, fileSystemID string) error {
+	return poll(ctx, 10*time.Minute, func() (bool, error) {
+		targets, err := m.mountTargets(ctx, fileSystemID)
+		if err != nil {
+			return false, err
+		}
+		for _, target := range targets {
+			if target.LifeCycleState != "available" {
+				return false, nil
+			}
+		}
+		return len(targets) > 0, nil
+	})
+}
+
+// poll calls check every 10 seconds until it reports done, fails, or the timeout expires
+func poll(ctx context.Context, timeout time.Duration, check func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v", timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
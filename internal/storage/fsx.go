@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/scttfrdmn/geoschem-aws/internal/awscli"
+	"github.com/scttfrdmn/geoschem-aws/internal/common"
+)
+
+// DefaultFSxMountPath is where FSx for Lustre is mounted on run instances and Batch hosts
+const DefaultFSxMountPath = "/fsx"
+
+// LustreFileSystem describes an FSx for Lustre file system
+type LustreFileSystem struct {
+	FileSystemID        string   `json:"FileSystemId"`
+	Lifecycle           string   `json:"Lifecycle"`
+	DNSName             string   `json:"DNSName"`
+	StorageCapacity     int      `json:"StorageCapacity"` // GiB
+	SubnetIDs           []string `json:"SubnetIds"`
+	LustreConfiguration struct {
+		MountName                   string `json:"MountName"`
+		DeploymentType              string `json:"DeploymentType"`
+		DataRepositoryConfiguration struct {
+			ImportPath string `json:"ImportPath"`
+		} `json:"DataRepositoryConfiguration"`
+	} `json:"LustreConfiguration"`
+	Tags []struct {
+		Key   string `json:"Key"`
+		Value string `json:"Value"`
+	} `json:"Tags"`
+}
+
+// Name returns the file system's Name tag
+func (fs *LustreFileSystem) Name() string {
+	for _, tag := range fs.Tags {
+		if tag.Key == "Name" {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// FSxManager provisions FSx for Lustre file systems with the AWS CLI
+type FSxManager struct {
+	cli *awscli.Client
+}
+
+// NewFSxManager creates a manager for the given AWS profile and region
+func NewFSxManager(profile, region string) *FSxManager {
+	return &FSxManager{cli: awscli.New(profile, region)}
+}
+
+// Create provisions a file system in the subnet linked to config.DataRepository: files in
+// the repository appear in the file system and load when first read, and Export writes
+// files created or changed in it back. An existing file system with the same name is
+// returned instead of creating another. The security group must allow Lustre (TCP 988 and
+// 1018-1023) between its members and the instances that mount it.
+func (m *FSxManager) Create(ctx context.Context, name, subnetID, securityGroupID string, config common.FSxConfig) (*LustreFileSystem, error) {
+	config = config.WithDefaults()
+	if existing, err := m.findByName(ctx, name); err != nil {
+		return nil, err
+	} else if existing != nil {
+		fmt.Printf("🗄️  Using existing FSx for Lustre file system %s (%s)\n", existing.FileSystemID, name)
+		return existing, m.waitForFileSystem(ctx, existing.FileSystemID)
+	}
+
+	fmt.Printf("🗄️  Creating %d GiB %s FSx for Lustre file system %s linked to %s...\n",
+		config.StorageGiB, config.DeploymentType, name, config.DataRepository)
+	lustre := map[string]interface{}{
+		"DeploymentType":      config.DeploymentType,
+		"DataCompressionType": "LZ4",
+	}
+	if config.DeploymentType == common.FSxScratch {
+		// Scratch file systems link their repository at creation
+		lustre["ImportPath"] = config.DataRepository
+		lustre["ExportPath"] = config.DataRepository
+		lustre["AutoImportPolicy"] = "NEW_CHANGED_DELETED"
+	} else {
+		lustre["PerUnitStorageThroughput"] = config.ThroughputPerTiB
+	}
+	lustreJSON, err := json.Marshal(lustre)
+	if err != nil {
+		return nil, fmt.Errorf("encoding Lustre configuration: %w", err)
+	}
+
+	var created struct {
+		FileSystem LustreFileSystem `json:"FileSystem"`
+	}
+	if err := m.cli.Run(ctx, &created, "fsx", "create-file-system",
+		"--file-system-type", "LUSTRE",
+		"--file-system-type-version", "2.15",
+		"--storage-capacity", strconv.Itoa(config.StorageGiB),
+		"--subnet-ids", subnetID,
+		"--security-group-ids", securityGroupID,
+		"--lustre-configuration", string(lustreJSON),
+		"--tags", "Key=Name,Value="+name, "Key=Project,Value=geoschem-aws"); err != nil {
+		return nil, fmt.Errorf("creating file system: %w", err)
+	}
+	id := created.FileSystem.FileSystemID
+	fmt.Printf("⏳ Waiting for %s, which takes several minutes (billed from now)...\n", id)
+
+	if err := m.waitForFileSystem(ctx, id); err != nil {
+		return nil, fmt.Errorf("waiting for %s: %w", id, err)
+	}
+	if config.DeploymentType != common.FSxScratch {
+		if err := m.associateRepository(ctx, id, config.DataRepository); err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Printf("✅ FSx for Lustre file system %s is available\n", id)
+	return m.Describe(ctx, id)
+}
+
+// associateRepository links a persistent file system's root to an S3 prefix, importing and
+// exporting changes automatically
+func (m *FSxManager) associateRepository(ctx context.Context, fileSystemID, repository string) error {
+	fmt.Printf("🔗 Linking %s to %s...\n", fileSystemID, repository)
+	events := []string{"NEW", "CHANGED", "DELETED"}
+	policies, err := json.Marshal(map[string]interface{}{
+		"AutoImportPolicy": map[string][]string{"Events": events},
+		"AutoExportPolicy": map[string][]string{"Events": events},
+	})
+	if err != nil {
+		return fmt.Errorf("encoding repository policies: %w", err)
+	}
+	var created struct {
+		Association struct {
+			AssociationID string `json:"AssociationId"`
+		} `json:"Association"`
+	}
+	if err := m.cli.Run(ctx, &created, "fsx", "create-data-repository-association",
+		"--file-system-id", fileSystemID,
+		"--file-system-path", "/",
+		"--data-repository-path", repository,
+		"--batch-import-meta-data-on-create",
+		"--s3", string(policies)); err != nil {
+		return fmt.Errorf("linking %s: %w", repository, err)
+	}
+
+	return poll(ctx, 30*time.Minute, func() (bool, error) {
+		var out struct {
+			Associations []struct {
+				Lifecycle string `json:"Lifecycle"`
+			} `json:"Associations"`
+		}
+		if err := m.cli.Run(ctx, &out, "fsx", "describe-data-repository-associations",
+			"--association-ids", created.Association.AssociationID); err != nil {
+			return false, fmt.Errorf("describing repository link: %w", err)
+		}
+		if len(out.Associations) == 0 {
+			return false, fmt.Errorf("repository link %s not found", created.Association.AssociationID)
+		}
+		switch lifecycle := out.Associations[0].Lifecycle; lifecycle {
+		case "AVAILABLE":
+			return true, nil
+		case "FAILED", "MISCONFIGURED":
+			return false, fmt.Errorf("linking %s: %s", repository, lifecycle)
+		}
+		return false, nil
+	})
+}
+
+// Describe returns the current state of a file system
+func (m *FSxManager) Describe(ctx context.Context, fileSystemID string) (*LustreFileSystem, error) {
+	var out struct {
+		FileSystems []LustreFileSystem `json:"FileSystems"`
+	}
+	if err := m.cli.Run(ctx, &out, "fsx", "describe-file-systems", "--file-system-ids", fileSystemID); err != nil {
+		return nil, fmt.Errorf("describing file system: %w", err)
+	}
+	if len(out.FileSystems) == 0 {
+		return nil, fmt.Errorf("file system %s not found", fileSystemID)
+	}
+	return &out.FileSystems[0], nil
+}
+
+// Export writes files created or changed in the file system to its data repository and
+// waits for the export to finish
+func (m *FSxManager) Export(ctx context.Context, fileSystemID string) error {
+	fmt.Printf("📤 Exporting %s to its data repository...\n", fileSystemID)
+	var created struct {
+		DataRepositoryTask struct {
+			TaskID string `json:"TaskId"`
+		} `json:"DataRepositoryTask"`
+	}
+	if err := m.cli.Run(ctx, &created, "fsx", "create-data-repository-task",
+		"--file-system-id", fileSystemID,
+		"--type", "EXPORT_TO_REPOSITORY",
+		"--report", "Enabled=false"); err != nil {
+		return fmt.Errorf("exporting %s: %w", fileSystemID, err)
+	}
+
+	taskID := created.DataRepositoryTask.TaskID
+	return poll(ctx, 6*time.Hour, func() (bool, error) {
+		var out struct {
+			DataRepositoryTasks []struct {
+				Lifecycle      string `json:"Lifecycle"`
+				FailureDetails struct {
+					Message string `json:"Message"`
+				} `json:"FailureDetails"`
+			} `json:"DataRepositoryTasks"`
+		}
+		if err := m.cli.Run(ctx, &out, "fsx", "describe-data-repository-tasks", "--task-ids", taskID); err != nil {
+			return false, fmt.Errorf("describing export %s: %w", taskID, err)
+		}
+		if len(out.DataRepositoryTasks) == 0 {
+			return false, fmt.Errorf("export %s not found", taskID)
+		}
+		switch task := out.DataRepositoryTasks[0]; task.Lifecycle {
+		case "SUCCEEDED":
+			return true, nil
+		case "FAILED", "CANCELED":
+			return false, fmt.Errorf("export %s %s: %s", taskID, task.Lifecycle, task.FailureDetails.Message)
+		}
+		return false, nil
+	})
+}
+
+// Delete deletes the file system. Files not exported to its data repository are lost.
+func (m *FSxManager) Delete(ctx context.Context, fileSystemID string) error {
+	if err := m.cli.Run(ctx, nil, "fsx", "delete-file-system", "--file-system-id", fileSystemID); err != nil {
+		return fmt.Errorf("deleting file system: %w", err)
+	}
+	return nil
+}
+
+// Hibernate exports the file system to its data repository and deletes it, which stops
+// its charges; creating it again from the same repository brings the files back. FSx for
+// Lustre can't be stopped, so this is how a file system is set aside between runs.
+func (m *FSxManager) Hibernate(ctx context.Context, fileSystemID string) error {
+	if err := m.Export(ctx, fileSystemID); err != nil {
+		return err
+	}
+	return m.Delete(ctx, fileSystemID)
+}
+
+// LustreMountCommand returns the shell command that installs the Lustre client if needed and
+// mounts the file system. It runs on run instances and in Batch hosts' user data.
+func LustreMountCommand(fs *LustreFileSystem, mountPath string) string {
+	return fmt.Sprintf("(command -v mount.lustre >/dev/null || sudo dnf install -y -q lustre-client || "+
+		"(sudo curl -sf -o /etc/yum.repos.d/aws-fsx.repo https://fsx-lustre-client-repo.s3.amazonaws.com/el/$(rpm -E %%rhel)/fsx-lustre-client.repo && "+
+		"sudo rpm --import https://fsx-lustre-client-repo-public-keys.s3.amazonaws.com/fsx-rpm-public-key.asc && "+
+		"sudo dnf install -y -q kmod-lustre-client lustre-client) || "+
+		"sudo apt-get install -y -qq lustre-client-modules-$(uname -r)) && "+
+		"sudo mkdir -p %[3]s && "+
+		"(mountpoint -q %[3]s || sudo mount -t lustre -o relatime,flock %[1]s@tcp:/%[2]s %[3]s) && "+
+		"sudo chown $(id -u):$(id -g) %[3]s",
+		fs.DNSName, fs.LustreConfiguration.MountName, mountPath)
+}
+
+// findByName returns the file system tagged with name that isn't being deleted, or nil
+func (m *FSxManager) findByName(ctx context.Context, name string) (*LustreFileSystem, error) {
+	var out struct {
+		FileSystems []LustreFileSystem `json:"FileSystems"`
+	}
+	if err := m.cli.Run(ctx, &out, "fsx", "describe-file-systems"); err != nil {
+		return nil, fmt.Errorf("listing file systems: %w", err)
+	}
+	for i, fs := range out.FileSystems {
+		if fs.Name() == name && fs.Lifecycle != "DELETING" && fs.Lifecycle != "FAILED" {
+			return &out.FileSystems[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *FSxManager) waitForFileSystem(ctx context.Context, fileSystemID string) error {
+	return poll(ctx, 30*time.Minute, func() (bool, error) {
+		fs, err := m.Describe(ctx, fileSystemID)
+		if err != nil {
+			return false, err
+		}
+		if fs.Lifecycle == "FAILED" || fs.Lifecycle == "MISCONFIGURED" {
+			return false, fmt.Errorf("file system %s is %s", fileSystemID, fs.Lifecycle)
+		}
+		return fs.Lifecycle == "AVAILABLE", nil
+	})
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mountpointPackage is the mountpoint-s3 RPM for an architecture (x86_64 or arm64)
+const mountpointPackage = "https://s3.amazonaws.com/mountpoint-s3-release/latest/%s/mount-s3.rpm"
+
+// S3MountCommand returns the shell command that mounts an s3:// prefix read-only at mountPath
+// with mountpoint-s3, readable by container users other than the login user. The instance
+// profile needs s3:ListBucket and s3:GetObject on the prefix.
+func S3MountCommand(uri, mountPath, arch string) (string, error) {
+	bucket, prefix, ok := strings.Cut(strings.TrimPrefix(uri, "s3://"), "/")
+	if !strings.HasPrefix(uri, "s3://") || bucket == "" {
+		return "", fmt.Errorf("expected an s3:// URI, got %s", uri)
+	}
+	prefixArg := ""
+	if ok && strings.Trim(prefix, "/") != "" {
+		prefixArg = " --prefix " + strings.Trim(prefix, "/") + "/"
+	}
+
+	return fmt.Sprintf("(command -v mount-s3 >/dev/null || sudo dnf install -y -q "+mountpointPackage+") && "+
+		"(grep -q '^user_allow_other' /etc/fuse.conf || echo user_allow_other | sudo tee -a /etc/fuse.conf >/dev/null) && "+
+		"mkdir -p %[2]s && (mountpoint -q %[2]s || mount-s3 --read-only --allow-other%[3]s %[4]s %[2]s)",
+		arch, mountPath, prefixArg, bucket), nil
+}
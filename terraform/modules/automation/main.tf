@@ -0,0 +1,252 @@
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+  }
+}
+
+data "aws_caller_identity" "current" {}
+data "aws_region" "current" {}
+
+locals {
+  # Handlers in cmd/lambda, with how long each may run
+  functions = merge(
+    {
+      "janitor"           = { timeout = 300, description = "Removes leftover GeosChem build instances and key pairs" }
+      "spot-interruption" = { timeout = 60, description = "Notifies owners of GeosChem Spot interruptions" }
+      "build-completed"   = { timeout = 60, description = "Announces GeosChem image pushes" }
+    },
+    var.output_bucket != "" ? {
+      "output-written" = { timeout = 300, description = "Catalogs, indexes and announces new GeosChem outputs" }
+    } : {}
+  )
+
+  environment = {
+    GEOSCHEM_NOTIFY_TOPICS              = join(",", var.notify_topic_arns)
+    GEOSCHEM_REPOSITORY                 = var.repository_name
+    GEOSCHEM_JANITOR_MAX_AGE            = var.janitor_max_age
+    GEOSCHEM_JANITOR_DRY_RUN            = tostring(var.janitor_dry_run)
+    GEOSCHEM_CATALOG_TABLE              = var.catalog_table
+    GEOSCHEM_ANALYSIS_IMAGE             = var.analysis_image
+    GEOSCHEM_FARGATE_JOB_QUEUE          = var.fargate_job_queue
+    GEOSCHEM_FARGATE_EXECUTION_ROLE_ARN = var.fargate_execution_role_arn
+    GEOSCHEM_FARGATE_JOB_ROLE_ARN       = var.fargate_job_role_arn
+  }
+
+  account = data.aws_caller_identity.current.account_id
+  region  = data.aws_region.current.name
+}
+
+# Role the functions run as
+resource "aws_iam_role" "automation" {
+  name = "${var.name_prefix}-automation-role"
+
+  assume_role_policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action = "sts:AssumeRole"
+        Effect = "Allow"
+        Principal = {
+          Service = "lambda.amazonaws.com"
+        }
+      }
+    ]
+  })
+
+  tags = var.tags
+}
+
+resource "aws_iam_role_policy_attachment" "automation_logs" {
+  role       = aws_iam_role.automation.name
+  policy_arn = "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"
+}
+
+resource "aws_iam_role_policy" "automation" {
+  name = "${var.name_prefix}-automation"
+  role = aws_iam_role.automation.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = concat(
+      [
+        {
+          Effect   = "Allow"
+          Action   = ["ec2:DescribeInstances", "ec2:DescribeKeyPairs", "ec2:DeleteKeyPair"]
+          Resource = "*"
+        },
+        {
+          # The janitor only terminates instances the platform tagged
+          Effect   = "Allow"
+          Action   = "ec2:TerminateInstances"
+          Resource = "*"
+          Condition = {
+            StringEquals = { "aws:ResourceTag/Project" = "geoschem-aws" }
+          }
+        },
+        {
+          # Private keys of expired per-build key pairs
+          Effect = "Allow"
+          Action = ["ssm:DeleteParameter", "secretsmanager:DeleteSecret"]
+          Resource = [
+            "arn:aws:ssm:${local.region}:${local.account}:parameter/geoschem-aws/keys/*",
+            "arn:aws:secretsmanager:${local.region}:${local.account}:secret:geoschem-aws/keys/*"
+          ]
+        }
+      ],
+      length(var.notify_topic_arns) > 0 ? [
+        {
+          Effect   = "Allow"
+          Action   = "sns:Publish"
+          Resource = var.notify_topic_arns
+        }
+      ] : [],
+      var.output_bucket != "" ? [
+        {
+          Effect   = "Allow"
+          Action   = ["s3:GetObject", "s3:ListBucket"]
+          Resource = ["arn:aws:s3:::${var.output_bucket}", "arn:aws:s3:::${var.output_bucket}/*"]
+        }
+      ] : [],
+      var.catalog_table != "" ? [
+        {
+          Effect   = "Allow"
+          Action   = "dynamodb:PutItem"
+          Resource = "arn:aws:dynamodb:${local.region}:${local.account}:table/${var.catalog_table}"
+        },
+        {
+          # Resolves the digest of the image that wrote an output
+          Effect   = "Allow"
+          Action   = "ecr:DescribeImages"
+          Resource = "*"
+        }
+      ] : [],
+      var.fargate_job_queue != "" ? [
+        {
+          Effect   = "Allow"
+          Action   = ["batch:RegisterJobDefinition", "batch:SubmitJob", "batch:TagResource"]
+          Resource = "*"
+        },
+        {
+          Effect   = "Allow"
+          Action   = "iam:PassRole"
+          Resource = compact([var.fargate_execution_role_arn, var.fargate_job_role_arn])
+        }
+      ] : []
+    )
+  })
+}
+
+resource "aws_lambda_function" "automation" {
+  for_each = local.functions
+
+  function_name = "${var.name_prefix}-${each.key}"
+  description   = each.value.description
+  role          = aws_iam_role.automation.arn
+  package_type  = "Image"
+  image_uri     = var.image_uri
+  architectures = [var.architecture]
+  timeout       = each.value.timeout
+  memory_size   = 512
+
+  image_config {
+    command = [each.key]
+  }
+
+  environment {
+    variables = local.environment
+  }
+
+  tags = var.tags
+}
+
+# Janitor on a schedule
+resource "aws_cloudwatch_event_rule" "janitor" {
+  name                = "${var.name_prefix}-janitor"
+  description         = "Sweeps leftover GeosChem resources"
+  schedule_expression = var.janitor_schedule
+
+  tags = var.tags
+}
+
+# Two-minute warnings before EC2 reclaims a Spot instance
+resource "aws_cloudwatch_event_rule" "spot_interruption" {
+  name        = "${var.name_prefix}-spot-interruption"
+  description = "EC2 Spot interruption warnings"
+
+  event_pattern = jsonencode({
+    source      = ["aws.ec2"]
+    detail-type = ["EC2 Spot Instance Interruption Warning"]
+  })
+
+  tags = var.tags
+}
+
+# Successful pushes to the GeosChem repository
+resource "aws_cloudwatch_event_rule" "build_completed" {
+  name        = "${var.name_prefix}-build-completed"
+  description = "GeosChem image pushes"
+
+  event_pattern = jsonencode({
+    source      = ["aws.ecr"]
+    detail-type = ["ECR Image Action"]
+    detail = {
+      action-type     = ["PUSH"]
+      result          = ["SUCCESS"]
+      repository-name = [var.repository_name]
+    }
+  })
+
+  tags = var.tags
+}
+
+locals {
+  event_rules = {
+    "janitor"           = aws_cloudwatch_event_rule.janitor
+    "spot-interruption" = aws_cloudwatch_event_rule.spot_interruption
+    "build-completed"   = aws_cloudwatch_event_rule.build_completed
+  }
+}
+
+resource "aws_cloudwatch_event_target" "automation" {
+  for_each = local.event_rules
+
+  rule = each.value.name
+  arn  = aws_lambda_function.automation[each.key].arn
+}
+
+resource "aws_lambda_permission" "events" {
+  for_each = local.event_rules
+
+  statement_id  = "AllowEventBridge"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.automation[each.key].function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = each.value.arn
+}
+
+# Runs write their provenance sidecar last, so its creation marks a complete output
+resource "aws_lambda_permission" "output_bucket" {
+  count = var.output_bucket != "" ? 1 : 0
+
+  statement_id  = "AllowS3"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.automation["output-written"].function_name
+  principal     = "s3.amazonaws.com"
+  source_arn    = "arn:aws:s3:::${var.output_bucket}"
+}
+
+resource "aws_s3_bucket_notification" "output_written" {
+  count  = var.output_bucket != "" ? 1 : 0
+  bucket = var.output_bucket
+
+  lambda_function {
+    lambda_function_arn = aws_lambda_function.automation["output-written"].arn
+    events              = ["s3:ObjectCreated:*"]
+    filter_suffix       = "provenance.json"
+  }
+
+  depends_on = [aws_lambda_permission.output_bucket]
+}
@@ -0,0 +1,9 @@
+output "function_arns" {
+  description = "ARNs of the automation functions by handler"
+  value       = { for name, function in aws_lambda_function.automation : name => function.arn }
+}
+
+output "role_arn" {
+  description = "ARN of the role the functions run as"
+  value       = aws_iam_role.automation.arn
+}
@@ -0,0 +1,91 @@
+variable "name_prefix" {
+  description = "Prefix for the functions, their role and event rules"
+  type        = string
+  default     = "geoschem"
+}
+
+variable "image_uri" {
+  description = "ECR URI of the automation image built from docker/lambda/Dockerfile"
+  type        = string
+}
+
+variable "architecture" {
+  description = "Architecture the image was built for: x86_64 or arm64"
+  type        = string
+  default     = "x86_64"
+}
+
+variable "notify_topic_arns" {
+  description = "SNS topics every notification fans out to"
+  type        = list(string)
+  default     = []
+}
+
+variable "repository_name" {
+  description = "ECR repository whose pushes are announced as completed builds"
+  type        = string
+  default     = "geoschem"
+}
+
+variable "janitor_schedule" {
+  description = "EventBridge schedule expression for the janitor"
+  type        = string
+  default     = "rate(6 hours)"
+}
+
+variable "janitor_max_age" {
+  description = "How long a build instance may run before the janitor terminates it (Go duration)"
+  type        = string
+  default     = "24h"
+}
+
+variable "janitor_dry_run" {
+  description = "Only report leftover resources instead of removing them"
+  type        = bool
+  default     = false
+}
+
+variable "output_bucket" {
+  description = "Bucket whose run outputs are post-processed. This replaces the bucket's existing event notifications; leave empty to skip post-processing."
+  type        = string
+  default     = ""
+}
+
+variable "catalog_table" {
+  description = "Results catalog table new outputs are recorded in (empty to skip)"
+  type        = string
+  default     = ""
+}
+
+variable "analysis_image" {
+  description = "GCPy analysis image that indexes new outputs (empty to skip indexing)"
+  type        = string
+  default     = ""
+}
+
+variable "fargate_job_queue" {
+  description = "Batch Fargate job queue the indexing jobs run on"
+  type        = string
+  default     = ""
+}
+
+variable "fargate_execution_role_arn" {
+  description = "Role Fargate pulls the analysis image and writes logs with"
+  type        = string
+  default     = ""
+}
+
+variable "fargate_job_role_arn" {
+  description = "Role the indexing container reads and writes S3 with"
+  type        = string
+  default     = ""
+}
+
+variable "tags" {
+  description = "Tags to apply to all automation resources"
+  type        = map(string)
+  default = {
+    Project   = "geoschem-aws-platform"
+    Terraform = "true"
+  }
+}